@@ -7,3 +7,7 @@ import (
 func NewCLI() *cli.CLI {
 	return cli.NewCLI()
 }
+
+func ExitCode(err error) int {
+	return cli.ExitCode(err)
+}
@@ -1,24 +1,47 @@
 package constants
 
+import "time"
+
 // Application Configuration
 const (
-	AppName       = "hexwarden"
-	AppVersion    = "1.1"
-	FileExtension = ".hex"
+	AppName            = "hexwarden"
+	AppVersion         = "1.1"
+	FileExtension      = ".hex"
+	DecryptedExtension = ".dec"                             // Appended when a decrypted name cannot be derived by stripping FileExtension
+	ConfigFileName     = ".hexwarden"                       // Option defaults, read as ConfigFileName + .yaml, .yml or .toml
+	IndexFileName      = ".hexwarden-index" + FileExtension // Encrypted tree index written by encrypt --index
+	PepperEnvVar       = "HEXWARDEN_PEPPER"                 // Secret mixed into every password before key derivation
+)
+
+// Process Exit Codes
+const (
+	ExitSuccess     = 0   // Everything succeeded
+	ExitFailure     = 1   // Any error not covered below, such as invalid flags
+	ExitAuthFailed  = 2   // Wrong password, or associated data that does not match
+	ExitCorrupt     = 3   // The file is damaged, truncated or not a hexwarden file
+	ExitIOError     = 4   // A file could not be found, read or written
+	ExitCanceled    = 5   // The user canceled at a prompt
+	ExitInterrupted = 130 // Stopped by SIGINT or SIGTERM, as shells report it
 )
 
 // Processing Configuration
 const (
-	DefaultChunkSize = 1 * 1024 * 1024 // 1MB chunks
-	MaxConcurrency   = 8               // Max worker threads
-	QueueSize        = 100             // Task queue buffer size
-	OverwritePasses  = 3               // Secure deletion passes
+	DefaultChunkSize = 1 * 1024 * 1024   // 1MB chunks
+	MaxConcurrency   = 8                 // Max worker threads
+	QueueSize        = 100               // Task queue buffer size
+	OverwritePasses  = 3                 // Secure deletion passes
+	WipeStateSuffix  = ".hexwarden-wipe" // Hidden record of the secure deletion passes completed, kept next to the file
+	MaxRawBodySize   = DefaultChunkSize  // Largest source the small-file fast path accepts
+	LogChunkInterval = 64                // Chunks between verbose throughput lines
+	WriteBufferSize  = 4 * 1024 * 1024   // Encrypted output collected before each write, more than one framed chunk
 )
 
 // Cryptographic Configuration
 const (
-	SaltSize = 32 // Argon2id salt size
-	KeySize  = 32 // AES-256 key size
+	SaltSize       = 32   // Argon2id salt size
+	KeySize        = 32   // AES-256 key size
+	MinDerivedSize = 16   // Shortest keying material DeriveKeyN produces
+	MaxDerivedSize = 1024 // Longest keying material DeriveKeyN produces
 )
 
 // File Processing Configuration
@@ -34,28 +57,63 @@ var (
 	}
 )
 
+// Password Retry Configuration
+const (
+	PasswordAttempts   = 3           // Tries allowed for a typed decryption password
+	PasswordRetryDelay = time.Second // Wait after the first wrong password, doubled after each further one
+	WeakPasswordLength = 8           // Encryption passwords shorter than this are reported as weak
+)
+
 // Argon2id Parameters
 const (
 	ArgonTime    uint32 = 3         // Time cost
 	ArgonMemory  uint32 = 64 * 1024 // Memory cost (64MB)
 	ArgonThreads uint8  = 4         // Parallelism
+
+	// Upper bounds on the cost a header may ask for. Parameters are read before the
+	// header can be authenticated, so without them one crafted file could make any
+	// reader allocate terabytes or run billions of passes. Threads are bounded by
+	// their single byte
+	MaxArgonTime   uint32 = 16          // Most passes over memory
+	MaxArgonMemory uint32 = 1024 * 1024 // Most memory in KiB (1 GiB), 16 times the default
 )
 
 // Header Format Constants
 const (
-	MagicBytes        = "HWX2" // File type identifier
-	SaltSizeBytes     = 32     // Salt for KDF
-	OriginalSizeBytes = 8      // Size of original plaintext
-	NonceSizeBytes    = 16     // Nonce for AEAD encryption
-	IntegritySize     = 32     // SHA-256 hash size
-	AuthSize          = 32     // HMAC-SHA256 tag size
-	ChecksumSize      = 4      // CRC32 checksum size
-	TotalHeaderSize   = 128    // Fixed header size
+	MagicBytes                   = "HWX2"  // File type identifier (fixed-size header)
+	MagicBytesV3                 = "HWX3"  // File type identifier (header with metadata block)
+	MagicBytesV4                 = "HWX4"  // File type identifier (header with metadata block and extended nonce)
+	SaltSizeBytes                = 32      // Salt for KDF
+	OriginalSizeBytes            = 8       // Size of original plaintext
+	NonceSizeBytes               = 16      // Nonce for AEAD encryption
+	ExtendedNonceSize            = 24      // Nonce in format version 4 headers, matching XChaCha20-Poly1305
+	IntegritySize                = 32      // Integrity hash size (SHA-256 or BLAKE2b-256)
+	AuthSize                     = 32      // Authentication tag size (HMAC over the same hash)
+	ChecksumSize                 = 4       // CRC32 checksum size
+	TotalHeaderSize              = 128     // Fixed header size
+	MetadataLenSize              = 4       // Size of the metadata block length prefix
+	MaxMetadataSize              = 1 << 20 // Maximum metadata block size (1MB)
+	MetadataCompressionThreshold = 256     // Smallest metadata block worth compressing when compression is asked for
+	MaxFilenameLength            = 1024    // Maximum stored filename length
+	MaxHintLength                = 256     // Maximum password hint length
+	MaxCommentLength             = 4096    // Maximum encrypted comment length
+	MaxThumbnailSize             = 32768   // Maximum encrypted thumbnail length
+)
+
+// Header Format Versions
+const (
+	FormatVersion2 uint8 = 2 // Fixed 128-byte header (MagicBytes)
+	FormatVersion3 uint8 = 3 // Header with authenticated metadata block (MagicBytesV3)
+	FormatVersion4 uint8 = 4 // Version 3 with a 24-byte nonce, for XChaCha20-Poly1305 bodies (MagicBytesV4)
 )
 
 // Stream Processing Constants
 const (
-	ChunkHeaderSize = 4 // Size of chunk length header in bytes
+	ChunkHeaderSize   = 4                               // Size of chunk length header in bytes
+	ChunkFooterMarker = 0xFFFFFFFF                      // Chunk size that ends the chunks of a body with a size footer or output padding
+	SizeFooterSize    = LogRecordSaltSize + 8 + 16      // Sealed size footer: salt, plaintext size and AES-GCM tag
+	PaddingRecordSize = LogRecordSaltSize + 8 + 32 + 16 // Sealed padding record: salt, filler length, filler SHA-256 and AES-GCM tag
+	UnknownSize       = -1                              // Source size given to EncryptStream when it is not known in advance
 )
 
 // Reed-Solomon Encoding Configuration
@@ -65,7 +123,72 @@ const (
 	MaxDataLen   = 1 << 30 // Maximum data length (1GB)
 )
 
+// Decompression Configuration
+const (
+	DecompressionTolerance = 64 * 1024 // Plaintext allowed beyond the declared original size before decryption is aborted
+)
+
+// Bundle Configuration
+const (
+	MaxBundleMembers   = 1 << 16 // Maximum number of files in a bundle
+	MaxBundleIndexSize = 1 << 26 // Maximum sealed bundle index size (64MB)
+	BundleTrailerSize  = 4       // Size of the index length stored at the end of a bundle
+)
+
+// Append-Only Log Configuration
+const (
+	LogRecordHeaderSize = 4       // Size of the length stored before each log record
+	LogRecordSaltSize   = 16      // Random salt the key and nonce of a log record are derived from
+	MaxLogRecordSize    = 1 << 26 // Maximum plaintext size of one log record (64MB)
+)
+
+// Merkle Tree Configuration
+const (
+	MerkleHashSize   = 32      // SHA-256 node size
+	MerkleTreeMagic  = "HWMT"  // Identifies a Merkle tree sidecar file
+	MerkleTreeFormat = 1       // Sidecar format version
+	MaxMerkleLeaves  = 1 << 30 // Largest leaf count a sidecar may declare
+)
+
 // Padding Configuration
 const (
 	PaddingSize = 16 // Block size for padding
 )
+
+// Thumbnail Configuration
+const (
+	ThumbnailMaxDim          = 160       // Longest side of a generated thumbnail in pixels
+	ThumbnailQuality         = 75        // JPEG quality of generated thumbnails
+	MaxThumbnailSourcePixels = 100 << 20 // Largest image decoded for a thumbnail (100 megapixels)
+)
+
+// Recovery Code Configuration
+const (
+	RecoveryCodeSize  = 20 // Random bytes in a recovery code (160 bits)
+	RecoveryCodeGroup = 4  // Characters between the dashes of a printed recovery code
+	RecoverySaltSize  = 16 // Random salt the recovery key is derived with
+)
+
+// Key Escrow Configuration
+const (
+	EscrowMagic   = "HWKE" // Identifies an escrowed file key exported by encrypt --export-key-to
+	EscrowKeySize = 32     // X25519 public key of the escrow holder and of the ephemeral key
+)
+
+// Nonce Counter Configuration
+const (
+	NonceCounterIDSize = 8 // Random id of a nonce counter state file, the start of every nonce taken from it
+	NonceCounterSize   = 8 // Big-endian counter at the end of a counter nonce
+)
+
+// Key Slot Configuration
+const (
+	MaxKeySlots = 8 // Extra passwords a file can be unlocked with besides the one it was encrypted with
+)
+
+// Entropy Self-Check Configuration
+const (
+	EntropyCheckReads   = 4                // Salt-sized reads the self-check takes, which must all differ
+	EntropyMinDistinct  = 32               // Fewest distinct byte values the reads may hold together; random data has about 100
+	EntropyCheckTimeout = 10 * time.Second // Longest the self-check waits for the random source before giving up
+)
@@ -10,9 +10,12 @@ var (
 	ErrEmptyCiphertext     = errors.New("ciphertext cannot be empty")
 	ErrEncryptionFailed    = errors.New("encryption operation failed")
 	ErrDecryptionFailed    = errors.New("decryption operation failed")
+	ErrCiphertextTooShort  = errors.New("ciphertext too short to contain nonce and tag; the data is truncated")
+	ErrTagMismatch         = errors.New("authentication tag mismatch; wrong key or corrupted data")
 	ErrCompressionFailed   = errors.New("compression operation failed")
 	ErrDecompressionFailed = errors.New("decompression operation failed")
 	ErrEncodingFailed      = errors.New("encoding operation failed")
+	ErrSelfCheckFailed     = errors.New("encoded chunk does not decode back to its input")
 	ErrDecodingFailed      = errors.New("decoding operation failed")
 	ErrPaddingFailed       = errors.New("padding operation failed")
 	ErrUnpaddingFailed     = errors.New("unpadding operation failed")
@@ -20,9 +23,15 @@ var (
 
 // KDF Errors
 var (
-	ErrEmptyPassword  = errors.New("password cannot be empty")
-	ErrInvalidSalt    = errors.New("invalid salt length")
-	ErrSaltGeneration = errors.New("failed to generate salt")
+	ErrEmptyPassword    = errors.New("password cannot be empty")
+	ErrInvalidSalt      = errors.New("invalid salt length")
+	ErrSaltGeneration   = errors.New("failed to generate salt")
+	ErrWeakRandom       = errors.New("random source produced a predictable value")
+	ErrEntropyCheck     = errors.New("random source failed the entropy self-check")
+	ErrInvalidKDFParams = errors.New("invalid key derivation parameters")
+	ErrInvalidKeyLength = errors.New("invalid derived key length")
+	ErrNoKDFParams      = errors.New("no key derivation parameters fit the time target")
+	ErrKDFLimit         = errors.New("key derivation cost above the limit")
 )
 
 // Header Errors
@@ -38,36 +47,114 @@ var (
 	ErrIncompleteWrite  = errors.New("incomplete header write")
 	ErrIncompleteRead   = errors.New("incomplete header read")
 	ErrTampering        = errors.New("header tampering detected")
+	ErrInvalidMetadata  = errors.New("invalid header metadata")
+	ErrInvalidOption    = errors.New("invalid header option")
+	ErrTooManyAttempts  = errors.New("too many wrong passwords")
+	ErrPepperRequired   = errors.New("file was encrypted with a pepper; set HEXWARDEN_PEPPER")
+	ErrRecoveryCode     = errors.New("invalid recovery code")
+	ErrNoRecoveryCode   = errors.New("file has no recovery code; encrypt it with --recovery-code")
+	ErrEscrowKey        = errors.New("invalid escrow key")
+	ErrEscrowMismatch   = errors.New("escrowed key belongs to a different file")
+	ErrTooManyKeySlots  = errors.New("file already has the maximum number of key slots")
+	ErrNoKeySlot        = errors.New("no key slot opens with that password")
+	ErrPrimaryKey       = errors.New("the password the file was encrypted with cannot be removed; re-encrypt the file instead")
 )
 
 // Data Layer Errors
 var (
 	ErrFileNotFound       = errors.New("file not found")
 	ErrFileExists         = errors.New("file already exists")
+	ErrNoFreeName         = errors.New("no free file name found")
 	ErrFileEmpty          = errors.New("file is empty")
 	ErrInvalidPath        = errors.New("invalid file path")
+	ErrUnsafePath         = errors.New("name would be written outside the output directory")
 	ErrFileCreateFailed   = errors.New("failed to create file")
 	ErrFileOpenFailed     = errors.New("failed to open file")
 	ErrFileReadFailed     = errors.New("failed to read file")
 	ErrFileWriteFailed    = errors.New("failed to write file")
 	ErrSecureDeleteFailed = errors.New("secure deletion failed")
+	ErrNotRegularFile     = errors.New("not a regular file")
+	ErrUnknownPattern     = errors.New("unknown secure-delete pattern")
+	ErrUnknownSortOrder   = errors.New("unknown sort order")
+	ErrInvalidTimeFilter  = errors.New("invalid time filter")
+	ErrInsufficientSpace  = errors.New("insufficient disk space")
+	ErrSpaceUnavailable   = errors.New("free space cannot be determined")
+	ErrUnsupportedURL     = errors.New("unsupported output URL")
+	ErrUploadFailed       = errors.New("upload failed")
+	ErrFileInUse          = errors.New("file in use by another process")
+	ErrNotAnImage         = errors.New("not a supported image")
+	ErrNoThumbnail        = errors.New("file has no thumbnail")
+	ErrNoStoredName       = errors.New("file has no stored filename")
+	ErrNonceState         = errors.New("invalid nonce counter state file")
+	ErrNonceExhausted     = errors.New("nonce counter exhausted")
+)
+
+// Keychain Errors
+var (
+	ErrSecretNotFound       = errors.New("password not found in keychain")
+	ErrKeychainUnavailable  = errors.New("keychain is not available")
+	ErrKeychainReadOnly     = errors.New("keychain backend is read-only")
+	ErrInvalidKeychainEntry = errors.New("invalid keychain entry name")
 )
 
 // Stream Processing Errors
 var (
-	ErrNilStream     = errors.New("input and output streams must not be nil")
-	ErrCanceled      = errors.New("operation was canceled")
-	ErrChunkTooLarge = errors.New("chunk size exceeds maximum allowed")
+	ErrNilStream         = errors.New("input and output streams must not be nil")
+	ErrCanceled          = errors.New("operation was canceled")
+	ErrStalled           = errors.New("stream stalled")
+	ErrChunkTooLarge     = errors.New("chunk size exceeds maximum allowed")
+	ErrUnrecoverable     = errors.New("one or more chunks could not be recovered")
+	ErrDecompressionBomb = errors.New("decrypted data exceeds the declared original size")
+	ErrParityMismatch    = errors.New("shards fail Reed-Solomon parity verification")
+	ErrUnknownFormat     = errors.New("no chunk framing known for this format version")
+	ErrRawBodySize       = errors.New("raw body length does not match the original size")
+	ErrMissingFooter     = errors.New("body ended without its size footer; it is truncated")
+	ErrFooterMismatch    = errors.New("decrypted body does not match the size in its footer")
+	ErrSizeMismatch      = errors.New("decrypted data does not match the declared original size")
+	ErrPaddingMismatch   = errors.New("output padding does not match its sealed record")
 )
 
 // Business Layer Errors
 var (
 	ErrPasswordMismatch = errors.New("passwords do not match")
+	ErrPasswordTooShort = errors.New("password is shorter than the required minimum")
+	ErrDigestMismatch   = errors.New("encrypted file digest does not match")
+	ErrSourceChanged    = errors.New("source changed size during encryption")
+	ErrPaddingTarget    = errors.New("encrypted output is larger than the padding target")
+	ErrBundle           = errors.New("file is a bundle; extract its members with --extract")
+	ErrNotBundle        = errors.New("file is not a bundle")
+	ErrReference        = errors.New("file is a dedup reference; decrypt it by path")
+	ErrInvalidReference = errors.New("invalid dedup reference")
+	ErrVectorMismatch   = errors.New("known-answer vector does not decrypt to its recorded plaintext")
+	ErrInvalidBundle    = errors.New("invalid bundle index")
+	ErrMemberNotFound   = errors.New("bundle has no such member")
+	ErrNoMerkleRoot     = errors.New("file has no Merkle root; encrypt it with --merkle")
+	ErrMerkleMismatch   = errors.New("chunk does not match the Merkle root")
+	ErrInvalidMerkle    = errors.New("invalid Merkle tree")
+	ErrInvalidManifest  = errors.New("invalid chunk manifest")
+	ErrManifestMismatch = errors.New("chunk does not match the manifest")
+	ErrChunkNotFound    = errors.New("file has no such chunk")
+	ErrNotSeekable      = errors.New("output must be a seekable file")
+	ErrNoOriginalHash   = errors.New("file has no stored SHA-256; encrypt it with --hash-original")
+	ErrOriginalMismatch = errors.New("decrypted output does not match the stored SHA-256")
+	ErrTimeLocked       = errors.New("file is time-locked; use --ignore-timelock to decrypt it anyway")
+	ErrAADMismatch      = errors.New("associated data does not match the file")
+	ErrUnencrypted      = errors.New("file is not encrypted; use recover")
+	ErrEncrypted        = errors.New("file is encrypted; use decrypt")
+	ErrNotLog           = errors.New("file is not an append-only log")
+	ErrInvalidLog       = errors.New("invalid log record")
+	ErrInvalidRange     = errors.New("byte range is outside the file")
+	ErrUnevenChunks     = errors.New("chunks are not evenly sized; decrypt the whole file instead")
+	ErrInvalidIndex     = errors.New("invalid tree index")
 )
 
 // Presentation Layer Errors
 var (
 	ErrUserCanceled     = errors.New("operation canceled by user")
+	ErrFileSkipped      = errors.New("file skipped")
 	ErrNoFilesAvailable = errors.New("no files available for selection")
+	ErrNoFilesSelected  = errors.New("no files selected")
+	ErrWarnings         = errors.New("warnings were reported and --fail-on-warning is set")
 	ErrPromptFailed     = errors.New("user prompt failed")
+	ErrInvalidConfig    = errors.New("invalid configuration file")
 )
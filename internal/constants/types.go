@@ -20,6 +20,46 @@ const (
 	DeleteSecure DeleteOption = "Secure Delete (slower, but unrecoverable)"
 )
 
+// ExistsAction is what to do when an output file already exists
+type ExistsAction string
+
+const (
+	// ExistsOverwrite replaces the existing file
+	ExistsOverwrite ExistsAction = "Overwrite"
+	// ExistsRename writes to the first free name, like "file (1).hex"
+	ExistsRename ExistsAction = "Rename output"
+	// ExistsSkip leaves this file unprocessed and moves on
+	ExistsSkip ExistsAction = "Skip this file"
+	// ExistsCancel stops processing
+	ExistsCancel ExistsAction = "Cancel"
+)
+
+// OverwritePattern selects the passes written over a file before secure deletion
+type OverwritePattern string
+
+const (
+	// PatternRandom writes OverwritePasses passes of random data
+	PatternRandom OverwritePattern = "random"
+	// PatternDoD writes 0x00, then 0xFF, then random data, after DoD 5220.22-M
+	PatternDoD OverwritePattern = "dod"
+	// PatternZero writes a single pass of zeros
+	PatternZero OverwritePattern = "zero"
+)
+
+// SortOrder selects the order a batch run processes its files in
+type SortOrder string
+
+const (
+	// SortNone keeps the order the files were found or listed in
+	SortNone SortOrder = ""
+	// SortName orders files by path
+	SortName SortOrder = "name"
+	// SortSize orders files from smallest to largest
+	SortSize SortOrder = "size"
+	// SortModTime orders files from least to most recently modified
+	SortModTime SortOrder = "mtime"
+)
+
 // Processing represents the stream processing operation type
 type Processing int
 
@@ -76,3 +116,205 @@ type FileInfo struct {
 	IsEncrypted bool
 	IsEligible  bool
 }
+
+// MetadataTag identifies a field in the header metadata block
+type MetadataTag uint8
+
+const (
+	// TagKDFParams stores the Argon2id cost parameters
+	TagKDFParams MetadataTag = 1
+	// TagFilename stores the encrypted original filename
+	TagFilename MetadataTag = 2
+	// TagHint stores the public password hint
+	TagHint MetadataTag = 3
+	// TagComment stores the encrypted free-text comment
+	TagComment MetadataTag = 4
+	// TagHashAlgorithm selects the header integrity hash and authentication algorithm
+	TagHashAlgorithm MetadataTag = 5
+	// TagFlags stores HeaderFlags describing how the body was written
+	TagFlags MetadataTag = 6
+	// TagThumbnail stores an encrypted JPEG preview of an image
+	TagThumbnail MetadataTag = 7
+	// TagMerkleRoot stores the root of the Merkle tree over the encrypted chunks
+	TagMerkleRoot MetadataTag = 8
+	// TagOriginalSHA256 stores the encrypted SHA-256 of the original file
+	TagOriginalSHA256 MetadataTag = 9
+	// TagCipher selects the AEAD cipher the chunks are encrypted with
+	TagCipher MetadataTag = 10
+	// TagNotBefore stores the Unix time before which decryption is refused
+	TagNotBefore MetadataTag = 11
+	// TagAADHash stores the SHA-256 of the associated data the body is bound to
+	TagAADHash MetadataTag = 12
+	// TagCompression stores the chunk compression format when it is not gzip
+	TagCompression MetadataTag = 13
+	// TagRecoveryKey stores the file key wrapped under a key derived from a recovery code
+	TagRecoveryKey MetadataTag = 14
+	// TagKeySlots stores the file key wrapped under keys derived from extra passwords
+	TagKeySlots MetadataTag = 15
+	// TagCompressedMetadata holds every other entry, deflate-compressed; it is then the only entry
+	TagCompressedMetadata MetadataTag = 16
+	// TagNonceCounter stores the id of the state file a counter nonce was taken from
+	TagNonceCounter MetadataTag = 17
+	// TagPaddedOutput stores the size a file was padded to; its chunks then end at the
+	// footer marker and are followed by random filler and a sealed padding record
+	TagPaddedOutput MetadataTag = 18
+)
+
+// NonceStrategy selects how the header nonce is generated
+type NonceStrategy uint8
+
+const (
+	// NonceRandom draws every header nonce from the random source, the default
+	NonceRandom NonceStrategy = 0
+	// NonceCounter builds the header nonce from the id of a state file and a
+	// counter that is persisted there before each use, so header nonces never
+	// repeat. Chunks are then sealed under a prefix derived from the header
+	// nonce followed by a chunk counter instead of random nonces
+	NonceCounter NonceStrategy = 1
+)
+
+func (s NonceStrategy) String() string {
+	switch s {
+	case NonceRandom:
+		return "random"
+	case NonceCounter:
+		return "counter"
+	default:
+		return "unknown"
+	}
+}
+
+// HeaderFlags is a bit set describing how the body following the header was written
+type HeaderFlags uint8
+
+const (
+	// FlagRawBody marks a body sealed as one AES-GCM message, without compression,
+	// padding, Reed-Solomon or chunk framing
+	FlagRawBody HeaderFlags = 1 << 0
+
+	// FlagBundle marks a body holding several members, each encrypted as its own
+	// chunk stream, followed by a sealed index of the members
+	FlagBundle HeaderFlags = 1 << 1
+
+	// FlagUnencrypted marks a body written by protect: chunks are compressed,
+	// padded and Reed-Solomon encoded but not encrypted
+	FlagUnencrypted HeaderFlags = 1 << 2
+
+	// FlagLog marks an append-only log: independently sealed records follow the
+	// header, which is never rewritten, so the original size is left at 0
+	FlagLog HeaderFlags = 1 << 3
+
+	// FlagPeppered marks a key derived from the password mixed with a secret
+	// pepper that is not stored in the file
+	FlagPeppered HeaderFlags = 1 << 4
+
+	// FlagReference marks a dedup reference: the body names another encrypted
+	// file holding the same plaintext instead of carrying it
+	FlagReference HeaderFlags = 1 << 5
+
+	// FlagSizeFooter marks a body encrypted from a source of unknown size: the
+	// original size is left at 0 and the chunks end with a sealed footer holding it
+	FlagSizeFooter HeaderFlags = 1 << 6
+
+	// KnownHeaderFlags holds every flag this version understands
+	KnownHeaderFlags = FlagRawBody | FlagBundle | FlagUnencrypted | FlagLog | FlagPeppered | FlagReference | FlagSizeFooter
+)
+
+// HashAlgorithm identifies the hash behind the header integrity hash and authentication tag
+type HashAlgorithm uint8
+
+const (
+	// HashSHA256 uses SHA-256 and HMAC-SHA256, the default for every format version
+	HashSHA256 HashAlgorithm = 0
+	// HashBLAKE2b uses BLAKE2b-256 and HMAC-BLAKE2b-256; requires format version 3
+	HashBLAKE2b HashAlgorithm = 1
+)
+
+func (a HashAlgorithm) String() string {
+	switch a {
+	case HashSHA256:
+		return "SHA-256"
+	case HashBLAKE2b:
+		return "BLAKE2b-256"
+	default:
+		return "unknown"
+	}
+}
+
+// Argon2Variant identifies the Argon2 variant keys are derived with
+type Argon2Variant uint8
+
+const (
+	// Argon2id mixes data-independent and data-dependent memory access, the default
+	Argon2id Argon2Variant = 0
+	// Argon2i only uses data-independent memory access, resisting side-channel attacks
+	Argon2i Argon2Variant = 1
+)
+
+func (v Argon2Variant) String() string {
+	switch v {
+	case Argon2id:
+		return "Argon2id"
+	case Argon2i:
+		return "Argon2i"
+	default:
+		return "unknown"
+	}
+}
+
+// CipherAlgorithm identifies the AEAD cipher the body is encrypted with
+type CipherAlgorithm uint8
+
+const (
+	// CipherAESGCM uses AES-256-GCM, the default for every format version
+	CipherAESGCM CipherAlgorithm = 0
+	// CipherChaCha20Poly1305 uses ChaCha20-Poly1305, which is faster without AES hardware; requires format version 3
+	CipherChaCha20Poly1305 CipherAlgorithm = 1
+	// CipherXChaCha20Poly1305 uses XChaCha20-Poly1305, whose 24-byte nonces are safe to draw at random
+	// for any number of chunks; requires format version 4
+	CipherXChaCha20Poly1305 CipherAlgorithm = 2
+	// CipherAuto picks AES-GCM or ChaCha20-Poly1305 by CPU support; it is resolved before encrypting and never stored
+	CipherAuto CipherAlgorithm = 0xFF
+)
+
+func (a CipherAlgorithm) String() string {
+	switch a {
+	case CipherAESGCM:
+		return "AES-256-GCM"
+	case CipherChaCha20Poly1305:
+		return "ChaCha20-Poly1305"
+	case CipherXChaCha20Poly1305:
+		return "XChaCha20-Poly1305"
+	case CipherAuto:
+		return "auto"
+	default:
+		return "unknown"
+	}
+}
+
+// CompressionFormat identifies how each chunk is compressed before encryption
+type CompressionFormat uint8
+
+const (
+	// CompressionGzip wraps each chunk's deflate stream in gzip framing, the default
+	CompressionGzip CompressionFormat = 0
+	// CompressionRawDeflate stores the bare deflate stream, 18 bytes smaller per
+	// chunk; the chunk framing already records its length; requires format version 3
+	CompressionRawDeflate CompressionFormat = 1
+	// CompressionStored skips compression: each chunk is a single marker byte
+	// followed by the data as is, so no deflate or gzip framing is written
+	CompressionStored CompressionFormat = 2
+)
+
+func (f CompressionFormat) String() string {
+	switch f {
+	case CompressionGzip:
+		return "gzip"
+	case CompressionRawDeflate:
+		return "raw deflate"
+	case CompressionStored:
+		return "stored"
+	default:
+		return "unknown"
+	}
+}
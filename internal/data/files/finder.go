@@ -1,9 +1,12 @@
 package files
 
 import (
+	"errors"
+	"io/fs"
 	"os"
 	"path/filepath"
 	"strings"
+	"time"
 
 	"github.com/hambosto/hexwarden/internal/constants"
 )
@@ -11,28 +14,58 @@ import (
 // Finder is responsible for finding files eligible for processing
 type Finder struct{}
 
+// SkippedPath is a file or directory a walk could not read and left out
+type SkippedPath struct {
+	Path string
+	Err  error
+}
+
 // NewFinder creates a new file finder instance
 func NewFinder() *Finder {
 	return &Finder{}
 }
 
 // FindEligibleFiles walks the current directory tree and returns a list of files
-// eligible for encryption or decryption, based on the specified mode
-func (f *Finder) FindEligibleFiles(mode constants.ProcessorMode) ([]string, error) {
-	var files []string
+// eligible for encryption or decryption, based on the specified mode, along with
+// the paths that were skipped because they could not be read
+func (f *Finder) FindEligibleFiles(mode constants.ProcessorMode) ([]string, []SkippedPath, error) {
+	return f.FindEligibleFilesIn(".", mode, time.Time{})
+}
 
-	// Walk through all files and directories starting from current directory
-	err := filepath.Walk(".", func(path string, info os.FileInfo, err error) error {
+// FindEligibleFilesIn walks the directory tree rooted at root and returns the files
+// eligible for the given mode. When since is non-zero, files whose modification
+// time is not after since are skipped. A path below root that permission is
+// denied on is left out, with its whole subtree, and returned among the skipped
+// paths instead of ending the walk; any other error still does
+func (f *Finder) FindEligibleFilesIn(root string, mode constants.ProcessorMode, since time.Time) ([]string, []SkippedPath, error) {
+	var (
+		files   []string
+		skipped []SkippedPath
+	)
+
+	err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
 		if err != nil {
-			return err
+			if path == root || !errors.Is(err, fs.ErrPermission) {
+				return err
+			}
+			skipped = append(skipped, SkippedPath{Path: path, Err: err})
+			// SkipDir on a file would also skip the rest of its directory
+			if info != nil && info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
 		}
-		if f.isFileEligible(path, info, mode) {
-			files = append(files, path)
+		if !f.isFileEligible(path, info, mode) {
+			return nil
 		}
+		if !since.IsZero() && !info.ModTime().After(since) {
+			return nil
+		}
+		files = append(files, path)
 		return nil
 	})
 
-	return files, err
+	return files, skipped, err
 }
 
 // isFileEligible checks if a given file should be processed based on its extension,
@@ -81,10 +114,26 @@ func (f *Finder) IsEncryptedFile(path string) bool {
 
 // GetOutputPath determines the output path based on the operation mode
 func (f *Finder) GetOutputPath(inputPath string, mode constants.ProcessorMode) string {
+	return f.GetOutputPathWithSuffix(inputPath, mode, "")
+}
+
+// GetOutputPathWithSuffix determines the output path, appending suffix to decrypted names.
+// The result never equals inputPath: when the encrypted extension cannot be stripped and
+// no suffix is given, DecryptedExtension is appended instead
+func (f *Finder) GetOutputPathWithSuffix(inputPath string, mode constants.ProcessorMode, suffix string) string {
 	if mode == constants.ModeEncrypt {
 		return inputPath + constants.FileExtension
 	}
-	return strings.TrimSuffix(inputPath, constants.FileExtension)
+
+	base, found := strings.CutSuffix(inputPath, constants.FileExtension)
+	if !found || filepath.Base(inputPath) == constants.FileExtension {
+		base = inputPath
+	}
+
+	if output := base + suffix; output != inputPath {
+		return output
+	}
+	return inputPath + constants.DecryptedExtension
 }
 
 // GetFileInfo returns detailed information about files
@@ -0,0 +1,56 @@
+package files
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/hambosto/hexwarden/internal/constants"
+)
+
+// ReadPathList reads newline-separated paths, as printed by find or ls.
+// Lines are kept verbatim so paths may contain spaces; blank lines and a
+// trailing carriage return are ignored
+func (f *Finder) ReadPathList(r io.Reader) ([]string, error) {
+	var paths []string
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSuffix(scanner.Text(), "\r")
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		paths = append(paths, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("%w: path list: %v", constants.ErrFileReadFailed, err)
+	}
+
+	return paths, nil
+}
+
+// CheckListedFile validates a path the user named explicitly for the given mode.
+// Unlike a directory walk, hidden and excluded files are accepted
+func (f *Finder) CheckListedFile(path string, mode constants.ProcessorMode) error {
+	info, err := os.Stat(path)
+	if os.IsNotExist(err) {
+		return fmt.Errorf("%w: %s", constants.ErrFileNotFound, path)
+	}
+	if err != nil {
+		return fmt.Errorf("%w: %v", constants.ErrFileOpenFailed, err)
+	}
+	if !info.Mode().IsRegular() {
+		return fmt.Errorf("%w: not a regular file: %s", constants.ErrInvalidPath, path)
+	}
+
+	encrypted := f.IsEncryptedFile(path)
+	if mode == constants.ModeEncrypt && encrypted {
+		return fmt.Errorf("%w: already encrypted: %s", constants.ErrInvalidPath, path)
+	}
+	if mode == constants.ModeDecrypt && !encrypted {
+		return fmt.Errorf("%w: missing %s extension: %s", constants.ErrInvalidPath, constants.FileExtension, path)
+	}
+	return nil
+}
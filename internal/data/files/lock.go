@@ -0,0 +1,57 @@
+package files
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/hambosto/hexwarden/internal/constants"
+)
+
+// FileLock is an exclusive advisory lock held on a file. The operating system
+// drops it when the process exits, including when it is killed by a signal
+type FileLock struct {
+	file *os.File
+}
+
+// LockFile takes an exclusive advisory lock on an existing file without waiting.
+// It fails with ErrFileInUse when another process, or another hexwarden run, holds it
+func (m *Manager) LockFile(path string) (*FileLock, error) {
+	file, err := os.Open(filepath.Clean(path))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", constants.ErrFileOpenFailed, err)
+	}
+	return lockOpened(file, path)
+}
+
+// LockNewFile creates an empty file at path and locks it, claiming a destination
+// before it is written. It fails with ErrFileExists when the path is already taken
+func (m *Manager) LockNewFile(path string) (*FileLock, error) {
+	file, err := os.OpenFile(filepath.Clean(path), os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+	if errors.Is(err, os.ErrExist) {
+		return nil, fmt.Errorf("%w: %s", constants.ErrFileExists, path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", constants.ErrFileCreateFailed, err)
+	}
+	return lockOpened(file, path)
+}
+
+// lockOpened locks an open file, closing it when the lock is not granted
+func lockOpened(file *os.File, path string) (*FileLock, error) {
+	if err := lockFile(file); err != nil {
+		_ = file.Close()
+		return nil, fmt.Errorf("%w: %s", err, path)
+	}
+	return &FileLock{file: file}, nil
+}
+
+// Unlock releases the lock
+func (l *FileLock) Unlock() error {
+	unlockErr := unlockFile(l.file)
+	if err := l.file.Close(); err != nil && unlockErr == nil {
+		return err
+	}
+	return unlockErr
+}
@@ -0,0 +1,15 @@
+//go:build !linux && !darwin && !freebsd && !windows
+
+package files
+
+import "os"
+
+// lockFile is not implemented on this platform; --lock then only claims the destination name
+func lockFile(*os.File) error {
+	return nil
+}
+
+// unlockFile is not implemented on this platform
+func unlockFile(*os.File) error {
+	return nil
+}
@@ -0,0 +1,35 @@
+//go:build windows
+
+package files
+
+import (
+	"errors"
+	"os"
+
+	"golang.org/x/sys/windows"
+
+	"github.com/hambosto/hexwarden/internal/constants"
+)
+
+// lockOffsetHigh places the locked byte far past the end of any real file. Windows
+// byte-range locks are mandatory, so locking actual data would also block the
+// handle that encrypts or decrypts it
+const lockOffsetHigh = 0x7FFFFFFF
+
+// lockFile takes a non-blocking exclusive LockFileEx lock on file
+func lockFile(file *os.File) error {
+	overlapped := &windows.Overlapped{OffsetHigh: lockOffsetHigh}
+	flags := uint32(windows.LOCKFILE_EXCLUSIVE_LOCK | windows.LOCKFILE_FAIL_IMMEDIATELY)
+
+	err := windows.LockFileEx(windows.Handle(file.Fd()), flags, 0, 1, 0, overlapped)
+	if errors.Is(err, windows.ERROR_LOCK_VIOLATION) {
+		return constants.ErrFileInUse
+	}
+	return err
+}
+
+// unlockFile releases the lock taken by lockFile
+func unlockFile(file *os.File) error {
+	overlapped := &windows.Overlapped{OffsetHigh: lockOffsetHigh}
+	return windows.UnlockFileEx(windows.Handle(file.Fd()), 0, 1, 0, overlapped)
+}
@@ -1,21 +1,31 @@
 package files
 
 import (
-	"crypto/rand"
+	"errors"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
+	"strings"
 
 	"github.com/hambosto/hexwarden/internal/constants"
-	"github.com/hambosto/hexwarden/internal/infrastructure/utils"
 )
 
 // Manager handles file creation, deletion (standard and secure), and validation
-type Manager struct{}
+type Manager struct {
+	pattern       constants.OverwritePattern
+	overwriteHook func(io.WriteSeeker) io.WriteSeeker
+}
 
 // NewManager creates a new file manager instance
 func NewManager() *Manager {
-	return &Manager{}
+	return &Manager{pattern: constants.PatternRandom}
+}
+
+// NewManagerWithPattern creates a file manager whose secure deletion writes the
+// given overwrite pattern
+func NewManagerWithPattern(pattern constants.OverwritePattern) *Manager {
+	return &Manager{pattern: pattern}
 }
 
 // Remove deletes the file at the given path using the provided deletion option
@@ -30,8 +40,17 @@ func (m *Manager) Remove(path string, option constants.DeleteOption) error {
 	}
 }
 
-// CreateFile creates and returns a new file at the given path
+// CreateFile creates and returns a new file at the given path.
+// A named pipe or character device is opened for writing as it is
 func (m *Manager) CreateFile(path string) (*os.File, error) {
+	if m.IsStreamTarget(path) {
+		output, err := os.OpenFile(filepath.Clean(path), os.O_WRONLY, 0)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", constants.ErrFileCreateFailed, err)
+		}
+		return output, nil
+	}
+
 	output, err := os.Create(filepath.Clean(path))
 	if err != nil {
 		return nil, fmt.Errorf("%w: %v", constants.ErrFileCreateFailed, err)
@@ -54,6 +73,10 @@ func (m *Manager) ValidatePath(path string, mustExist bool) error {
 			return fmt.Errorf("%w: %s", constants.ErrFileEmpty, path)
 		}
 	} else {
+		// Pipes and devices already exist by design; writing to them replaces nothing
+		if err == nil && fileInfo.Mode()&(os.ModeNamedPipe|os.ModeCharDevice) != 0 {
+			return nil
+		}
 		if err == nil {
 			return fmt.Errorf("%w: %s", constants.ErrFileExists, path)
 		}
@@ -64,6 +87,27 @@ func (m *Manager) ValidatePath(path string, mustExist bool) error {
 	return nil
 }
 
+// maxFreeNameAttempts bounds the numbered names FreePath tries
+const maxFreeNameAttempts = 10000
+
+// FreePath returns path when nothing exists there, otherwise the first numbered
+// variant that is free, with the number before the extension: "file (1).hex"
+func (m *Manager) FreePath(path string) (string, error) {
+	if _, err := os.Lstat(path); os.IsNotExist(err) {
+		return path, nil
+	}
+
+	ext := filepath.Ext(path)
+	base := strings.TrimSuffix(path, ext)
+	for n := 1; n <= maxFreeNameAttempts; n++ {
+		candidate := fmt.Sprintf("%s (%d)%s", base, n, ext)
+		if _, err := os.Lstat(candidate); os.IsNotExist(err) {
+			return candidate, nil
+		}
+	}
+	return "", fmt.Errorf("%w: %s", constants.ErrNoFreeName, path)
+}
+
 // OpenFile opens a file and returns both the file handle and its metadata
 func (m *Manager) OpenFile(path string) (*os.File, os.FileInfo, error) {
 	file, err := os.Open(filepath.Clean(path))
@@ -101,9 +145,30 @@ func (m *Manager) FileExists(path string) bool {
 	return err == nil
 }
 
-// secureDelete securely deletes a file by overwriting its contents with random data
+// secureDelete securely deletes a file by overwriting its contents with the
+// manager's pattern. Only regular files are overwritten: a symlink is refused
+// rather than followed, and devices, pipes and directories are never opened.
+// Each completed pass is recorded next to the file, so calling it again after
+// an interruption continues with the next pass instead of starting over. A file
+// truncated since then only gets its remaining bytes overwritten
 func (m *Manager) secureDelete(path string) error {
-	file, err := os.OpenFile(filepath.Clean(path), os.O_WRONLY, 0)
+	path = filepath.Clean(path)
+	state, resumed := readWipeState(path)
+
+	target, err := os.Lstat(path)
+	if os.IsNotExist(err) && resumed {
+		// Every pass was written and the file removed before the record was
+		clearWipeState(path)
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("%w: failed to get file info: %v", constants.ErrSecureDeleteFailed, err)
+	}
+	if !target.Mode().IsRegular() {
+		return fmt.Errorf("%w: %w: %s is a %s", constants.ErrSecureDeleteFailed, constants.ErrNotRegularFile, path, describeMode(target.Mode()))
+	}
+
+	file, err := os.OpenFile(path, os.O_WRONLY, 0)
 	if err != nil {
 		return fmt.Errorf("%w: failed to open file for secure deletion: %v", constants.ErrSecureDeleteFailed, err)
 	}
@@ -113,49 +178,78 @@ func (m *Manager) secureDelete(path string) error {
 	if err != nil {
 		return fmt.Errorf("%w: failed to get file info: %v", constants.ErrSecureDeleteFailed, err)
 	}
-
-	// Perform multiple overwrite passes
-	for pass := 0; pass < constants.OverwritePasses; pass++ {
-		if err := m.randomOverwrite(file, info.Size()); err != nil {
-			return fmt.Errorf("%w: secure overwrite pass %d failed: %v", constants.ErrSecureDeleteFailed, pass+1, err)
-		}
+	// The path may have been replaced, e.g. by a symlink, since it was checked
+	if !os.SameFile(target, info) {
+		return fmt.Errorf("%w: %w: %s changed while it was being opened", constants.ErrSecureDeleteFailed, constants.ErrNotRegularFile, path)
 	}
 
-	// Close the file before removing it
-	if err := file.Close(); err != nil {
-		return fmt.Errorf("%w: failed to close file before removal: %v", constants.ErrSecureDeleteFailed, err)
+	passes, err := overwritePasses(m.pattern)
+	if err != nil {
+		return fmt.Errorf("%w: %w", constants.ErrSecureDeleteFailed, err)
 	}
 
-	// Finally remove the file
-	if err := os.Remove(path); err != nil {
-		return fmt.Errorf("%w: failed to remove file: %v", constants.ErrSecureDeleteFailed, err)
+	pattern := m.pattern
+	if pattern == "" {
+		pattern = constants.PatternRandom
 	}
 
-	return nil
-}
-
-// randomOverwrite writes cryptographically secure random bytes over the file content
-func (m *Manager) randomOverwrite(file *os.File, size int64) error {
-	if _, err := file.Seek(0, 0); err != nil {
-		return fmt.Errorf("failed to seek to file start: %w", err)
+	// A record left by another pattern, or on another file once at this path,
+	// says nothing about this one
+	start := 0
+	if resumed && state.pattern == pattern && state.sameFile(info) {
+		start = min(state.passes, len(passes))
 	}
 
-	buffer := make([]byte, 4096)
-	remaining := size
-
-	for remaining > 0 {
-		writeSize := utils.MinInt64(remaining, int64(len(buffer)))
+	var dst io.WriteSeeker = file
+	if m.overwriteHook != nil {
+		dst = m.overwriteHook(file)
+	}
 
-		if _, err := rand.Read(buffer[:writeSize]); err != nil {
-			return fmt.Errorf("failed to generate random data: %w", err)
+	// Perform one overwrite per remaining pass of the pattern
+	for i := start; i < len(passes); i++ {
+		if err := overwrite(dst, info.Size(), passes[i]); err != nil {
+			passErr := fmt.Errorf("%w: secure overwrite pass %d failed: %v", constants.ErrSecureDeleteFailed, i+1, err)
+			// The file is unlinked all the same, so its name does not outlive a failed wipe
+			_ = file.Close()
+			if err := os.Remove(path); err != nil {
+				return errors.Join(passErr, fmt.Errorf("%w: failed to remove file: %v", constants.ErrSecureDeleteFailed, err))
+			}
+			clearWipeState(path)
+			return passErr
 		}
-
-		if _, err := file.Write(buffer[:writeSize]); err != nil {
-			return fmt.Errorf("failed to write random data: %w", err)
+		// Without the record a later call only starts over, so a failure to write it is not fatal
+		if written, err := file.Stat(); err == nil {
+			_ = writeWipeState(path, newWipeState(pattern, i+1, written))
 		}
+	}
 
-		remaining -= writeSize
+	// Every pass was synced, so the file is removed even when closing it fails
+	closeErr := file.Close()
+	if err := os.Remove(path); err != nil {
+		if closeErr != nil {
+			err = fmt.Errorf("%v (after failing to close it: %v)", err, closeErr)
+		}
+		return fmt.Errorf("%w: failed to remove file: %v", constants.ErrSecureDeleteFailed, err)
 	}
+	clearWipeState(path)
 
-	return file.Sync()
+	return nil
+}
+
+// describeMode names the kind of file a mode describes, for error messages
+func describeMode(mode os.FileMode) string {
+	switch {
+	case mode&os.ModeSymlink != 0:
+		return "symbolic link"
+	case mode.IsDir():
+		return "directory"
+	case mode&os.ModeNamedPipe != 0:
+		return "named pipe"
+	case mode&os.ModeSocket != 0:
+		return "socket"
+	case mode&os.ModeDevice != 0:
+		return "device"
+	default:
+		return "special file"
+	}
 }
@@ -0,0 +1,103 @@
+package files
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/hambosto/hexwarden/internal/constants"
+)
+
+// nonceStateFormat is the record a nonce counter state file holds: a marker, the
+// id in hex and the next counter. The counter is zero-padded so every record
+// has the same length and is rewritten in place over the previous one
+const nonceStateFormat = "hexwarden-nonce %x %020d\n"
+
+// maxNonceStateSize bounds how much of a state file is read; a record is far shorter
+const maxNonceStateSize = 1 << 10
+
+// nonceStateMu serializes reservations within the process: the advisory lock
+// only keeps other processes out, and it refuses a second holder instead of waiting
+var nonceStateMu sync.Mutex
+
+// ReserveNonceCounter takes the next counter from the nonce counter state file
+// at path, creating it with a fresh random id when it does not exist, and
+// returns the id with the counter. The following counter is written and
+// flushed to disk before returning, so a crash can skip counters but never
+// hand one out twice. It fails with ErrFileInUse when another process holds
+// the state file, and with ErrNonceState when the file cannot be read back
+func (m *Manager) ReserveNonceCounter(path string) ([]byte, uint64, error) {
+	nonceStateMu.Lock()
+	defer nonceStateMu.Unlock()
+
+	file, err := os.OpenFile(filepath.Clean(path), os.O_RDWR|os.O_CREATE, 0o600)
+	if err != nil {
+		return nil, 0, fmt.Errorf("%w: %v", constants.ErrFileOpenFailed, err)
+	}
+	lock, err := lockOpened(file, path)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer lock.Unlock() //nolint:errcheck
+
+	data, err := io.ReadAll(io.LimitReader(file, maxNonceStateSize))
+	if err != nil {
+		return nil, 0, fmt.Errorf("%w: %v", constants.ErrFileReadFailed, err)
+	}
+
+	// An empty file was just created, or created by a run that crashed before
+	// recording anything, so no counter was handed out under any id yet
+	created := len(data) == 0
+	var id []byte
+	var next uint64
+	if created {
+		id = make([]byte, constants.NonceCounterIDSize)
+		if _, err := rand.Read(id); err != nil {
+			return nil, 0, fmt.Errorf("failed to generate nonce counter id: %w", err)
+		}
+	} else if id, next, err = parseNonceState(data); err != nil {
+		return nil, 0, fmt.Errorf("%w: %s: %v", constants.ErrNonceState, path, err)
+	}
+	if next == math.MaxUint64 {
+		return nil, 0, fmt.Errorf("%w: %s", constants.ErrNonceExhausted, path)
+	}
+
+	if _, err := file.WriteAt(fmt.Appendf(nil, nonceStateFormat, id, next+1), 0); err != nil {
+		return nil, 0, fmt.Errorf("%w: %v", constants.ErrFileWriteFailed, err)
+	}
+	if err := file.Sync(); err != nil {
+		return nil, 0, fmt.Errorf("%w: %v", constants.ErrFileWriteFailed, err)
+	}
+	if created {
+		if err := m.SyncDir(filepath.Dir(path)); err != nil {
+			return nil, 0, err
+		}
+	}
+	return id, next, nil
+}
+
+// parseNonceState reads the id and next counter from a state file record.
+// A record torn by a crash starts with the new record and ends with the old
+// one, so it either fails to parse or holds a counter no lower than the old
+func parseNonceState(data []byte) ([]byte, uint64, error) {
+	fields := strings.Fields(string(data))
+	if len(fields) != 3 || fields[0] != "hexwarden-nonce" {
+		return nil, 0, fmt.Errorf("not a nonce counter state file")
+	}
+	id, err := hex.DecodeString(fields[1])
+	if err != nil || len(id) != constants.NonceCounterIDSize {
+		return nil, 0, fmt.Errorf("id must be %d hex-encoded bytes", constants.NonceCounterIDSize)
+	}
+	next, err := strconv.ParseUint(fields[2], 10, 64)
+	if err != nil {
+		return nil, 0, fmt.Errorf("invalid counter: %v", err)
+	}
+	return id, next, nil
+}
@@ -0,0 +1,107 @@
+package files
+
+import (
+	"crypto/rand"
+	"fmt"
+	"io"
+
+	"github.com/hambosto/hexwarden/internal/constants"
+	"github.com/hambosto/hexwarden/internal/infrastructure/utils"
+)
+
+// overwritePass fills buf with the bytes written during one pass
+type overwritePass func(buf []byte) error
+
+// ParseOverwritePattern validates a secure-delete pattern name. An empty name
+// selects PatternRandom
+func ParseOverwritePattern(name string) (constants.OverwritePattern, error) {
+	pattern := constants.OverwritePattern(name)
+	if pattern == "" {
+		return constants.PatternRandom, nil
+	}
+	if _, err := overwritePasses(pattern); err != nil {
+		return "", err
+	}
+	return pattern, nil
+}
+
+// Overwrite writes size bytes over dst from its start once per pass of the
+// pattern, syncing after each pass when dst supports it
+func Overwrite(dst io.WriteSeeker, size int64, pattern constants.OverwritePattern) error {
+	passes, err := overwritePasses(pattern)
+	if err != nil {
+		return err
+	}
+
+	for i, pass := range passes {
+		if err := overwrite(dst, size, pass); err != nil {
+			return fmt.Errorf("overwrite pass %d failed: %w", i+1, err)
+		}
+	}
+	return nil
+}
+
+// overwritePasses returns the passes that make up pattern
+func overwritePasses(pattern constants.OverwritePattern) ([]overwritePass, error) {
+	switch pattern {
+	case "", constants.PatternRandom:
+		passes := make([]overwritePass, constants.OverwritePasses)
+		for i := range passes {
+			passes[i] = fillRandom
+		}
+		return passes, nil
+	case constants.PatternDoD:
+		return []overwritePass{fillByte(0x00), fillByte(0xFF), fillRandom}, nil
+	case constants.PatternZero:
+		return []overwritePass{fillByte(0x00)}, nil
+	default:
+		return nil, fmt.Errorf("%w: %q (expected random, dod or zero)", constants.ErrUnknownPattern, pattern)
+	}
+}
+
+// overwrite writes size bytes produced by pass over dst from its start
+func overwrite(dst io.WriteSeeker, size int64, pass overwritePass) error {
+	if _, err := dst.Seek(0, io.SeekStart); err != nil {
+		return fmt.Errorf("failed to seek to file start: %w", err)
+	}
+
+	buffer := make([]byte, 4096)
+	remaining := size
+
+	for remaining > 0 {
+		writeSize := utils.MinInt64(remaining, int64(len(buffer)))
+
+		if err := pass(buffer[:writeSize]); err != nil {
+			return err
+		}
+
+		if _, err := dst.Write(buffer[:writeSize]); err != nil {
+			return fmt.Errorf("failed to write overwrite data: %w", err)
+		}
+
+		remaining -= writeSize
+	}
+
+	if syncer, ok := dst.(interface{ Sync() error }); ok {
+		return syncer.Sync()
+	}
+	return nil
+}
+
+// fillByte returns a pass that writes b repeatedly
+func fillByte(b byte) overwritePass {
+	return func(buf []byte) error {
+		for i := range buf {
+			buf[i] = b
+		}
+		return nil
+	}
+}
+
+// fillRandom writes cryptographically secure random bytes
+func fillRandom(buf []byte) error {
+	if _, err := rand.Read(buf); err != nil {
+		return fmt.Errorf("failed to generate random data: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,63 @@
+package files
+
+import (
+	"cmp"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/hambosto/hexwarden/internal/constants"
+)
+
+// ParseSortOrder validates a sort order name. An empty name selects SortNone
+func ParseSortOrder(name string) (constants.SortOrder, error) {
+	order := constants.SortOrder(name)
+	switch order {
+	case constants.SortNone, constants.SortName, constants.SortSize, constants.SortModTime:
+		return order, nil
+	default:
+		return "", fmt.Errorf("%w: %q (expected name, size or mtime)", constants.ErrUnknownSortOrder, name)
+	}
+}
+
+// SortFiles orders paths in place. Ties are broken by path, so the result does
+// not depend on the order the files were found or listed in. A file that cannot
+// be read sorts as empty and oldest, and fails later when it is processed
+func (f *Finder) SortFiles(paths []string, order constants.SortOrder) error {
+	if _, err := ParseSortOrder(string(order)); err != nil {
+		return err
+	}
+	if order == constants.SortNone {
+		return nil
+	}
+
+	type sortKey struct {
+		size    int64
+		modTime time.Time
+	}
+	keys := make(map[string]sortKey, len(paths))
+	if order != constants.SortName {
+		for _, path := range paths {
+			if info, err := os.Stat(path); err == nil {
+				keys[path] = sortKey{size: info.Size(), modTime: info.ModTime()}
+			}
+		}
+	}
+
+	slices.SortStableFunc(paths, func(a, b string) int {
+		var c int
+		switch order {
+		case constants.SortSize:
+			c = cmp.Compare(keys[a].size, keys[b].size)
+		case constants.SortModTime:
+			c = keys[a].modTime.Compare(keys[b].modTime)
+		}
+		if c != 0 {
+			return c
+		}
+		return strings.Compare(a, b)
+	})
+	return nil
+}
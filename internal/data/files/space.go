@@ -0,0 +1,37 @@
+package files
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/hambosto/hexwarden/internal/constants"
+	"github.com/hambosto/hexwarden/internal/infrastructure/utils"
+)
+
+// CheckSpace verifies that the filesystem receiving path has at least required bytes free.
+// The check is best-effort and passes when free space cannot be determined
+func (m *Manager) CheckSpace(path string, required int64) error {
+	if m.IsStreamTarget(path) {
+		return nil // Nothing lands on the filesystem holding the pipe or device
+	}
+
+	available, err := availableSpace(filepath.Dir(filepath.Clean(path)))
+	if err != nil || required <= 0 {
+		return nil
+	}
+
+	if uint64(required) > available {
+		return fmt.Errorf("%w: %s needed, %s available for %s",
+			constants.ErrInsufficientSpace, utils.FormatBytes(required), formatAvailable(available), path)
+	}
+	return nil
+}
+
+// formatAvailable formats a free space value that may exceed the int64 range
+func formatAvailable(available uint64) string {
+	const maxInt64 = 1<<63 - 1
+	if available > maxInt64 {
+		available = maxInt64
+	}
+	return utils.FormatBytes(int64(available))
+}
@@ -0,0 +1,10 @@
+//go:build !linux && !darwin && !freebsd && !windows
+
+package files
+
+import "github.com/hambosto/hexwarden/internal/constants"
+
+// availableSpace is not implemented on this platform
+func availableSpace(string) (uint64, error) {
+	return 0, constants.ErrSpaceUnavailable
+}
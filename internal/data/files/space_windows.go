@@ -0,0 +1,19 @@
+//go:build windows
+
+package files
+
+import "golang.org/x/sys/windows"
+
+// availableSpace returns the bytes available to the current user in dir's volume
+func availableSpace(dir string) (uint64, error) {
+	path, err := windows.UTF16PtrFromString(dir)
+	if err != nil {
+		return 0, err
+	}
+
+	var available uint64
+	if err := windows.GetDiskFreeSpaceEx(path, &available, nil, nil); err != nil {
+		return 0, err
+	}
+	return available, nil
+}
@@ -0,0 +1,14 @@
+package files
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// IsStreamTarget reports whether path is an existing named pipe or character device.
+// Such an output is written in place as a stream: it is never created, truncated,
+// synced, space-checked or removed, so a reader like mpv or tar can consume it directly
+func (m *Manager) IsStreamTarget(path string) bool {
+	info, err := os.Stat(filepath.Clean(path))
+	return err == nil && info.Mode()&(os.ModeNamedPipe|os.ModeCharDevice) != 0
+}
@@ -0,0 +1,36 @@
+package files
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// SyncFile flushes the file at path to stable storage. Pipes and devices hold
+// nothing to flush, and opening a pipe again could block, so they are skipped
+func (m *Manager) SyncFile(path string) error {
+	if m.IsStreamTarget(path) {
+		return nil
+	}
+
+	file, err := os.Open(filepath.Clean(path))
+	if err != nil {
+		return fmt.Errorf("failed to open %s for sync: %w", path, err)
+	}
+	defer file.Close() //nolint:errcheck
+
+	if err := file.Sync(); err != nil {
+		return fmt.Errorf("failed to sync %s: %w", path, err)
+	}
+	return nil
+}
+
+// SyncDir flushes the directory entries of dir to stable storage, so files
+// created or renamed in it survive a crash. Platforms that cannot sync a
+// directory make this a no-op
+func (m *Manager) SyncDir(dir string) error {
+	if err := syncDir(filepath.Clean(dir)); err != nil {
+		return fmt.Errorf("failed to sync directory %s: %w", dir, err)
+	}
+	return nil
+}
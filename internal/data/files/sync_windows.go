@@ -0,0 +1,9 @@
+//go:build windows
+
+package files
+
+// syncDir is a no-op: Windows cannot open a directory for flushing, and
+// NTFS journals directory entries on its own
+func syncDir(string) error {
+	return nil
+}
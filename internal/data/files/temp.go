@@ -0,0 +1,178 @@
+package files
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sync"
+	"syscall"
+
+	"github.com/hambosto/hexwarden/internal/constants"
+)
+
+var (
+	tempMu      sync.Mutex
+	tempDir     string
+	liveTemps   = make(map[string]struct{})
+	signalsOnce sync.Once
+)
+
+// SetTempDir sets the directory temporary files are created in. An empty dir
+// restores the default: $TMPDIR when it is set, otherwise the directory of the
+// file being replaced
+func SetTempDir(dir string) error {
+	if dir != "" {
+		info, err := os.Stat(dir)
+		if err != nil {
+			return fmt.Errorf("invalid temporary directory: %w", err)
+		}
+		if !info.IsDir() {
+			return fmt.Errorf("invalid temporary directory: %s is not a directory", dir)
+		}
+	}
+
+	tempMu.Lock()
+	defer tempMu.Unlock()
+	tempDir = dir
+	return nil
+}
+
+// tempDirFor returns the directory a temporary file replacing target goes in
+func tempDirFor(target string) string {
+	tempMu.Lock()
+	dir := tempDir
+	tempMu.Unlock()
+
+	if dir != "" {
+		return dir
+	}
+	if env := os.Getenv("TMPDIR"); env != "" {
+		return env
+	}
+	return filepath.Dir(target)
+}
+
+// CreateTemp creates a hidden temporary file that will later replace target via
+// CommitTemp. It is removed if the process is interrupted before then
+func (m *Manager) CreateTemp(target string) (*os.File, error) {
+	return createTemp(tempDirFor(target), target)
+}
+
+// CommitTemp moves the closed temporary file tmpPath over target. When the two
+// are on different filesystems the data is first copied next to target, so the
+// final step is still an atomic rename
+func (m *Manager) CommitTemp(tmpPath, target string) error {
+	defer m.DiscardTemp(tmpPath)
+
+	err := os.Rename(tmpPath, target)
+	if err == nil {
+		return nil
+	}
+	if filepath.Dir(filepath.Clean(tmpPath)) == filepath.Dir(filepath.Clean(target)) {
+		return fmt.Errorf("failed to replace %s: %w", target, err)
+	}
+
+	sibling, err := copyToSibling(tmpPath, target)
+	if err != nil {
+		return err
+	}
+	if err := os.Rename(sibling, target); err != nil {
+		m.DiscardTemp(sibling)
+		return fmt.Errorf("failed to replace %s: %w", target, err)
+	}
+	forgetTemp(sibling)
+	return nil
+}
+
+// DiscardTemp removes a temporary file that is no longer needed
+func (m *Manager) DiscardTemp(tmpPath string) {
+	_ = os.Remove(tmpPath)
+	forgetTemp(tmpPath)
+}
+
+// RemoveTempFiles removes every temporary file that has not been committed or
+// discarded yet. It runs on SIGINT and SIGTERM
+func RemoveTempFiles() {
+	tempMu.Lock()
+	defer tempMu.Unlock()
+
+	for path := range liveTemps {
+		_ = os.Remove(path)
+		delete(liveTemps, path)
+	}
+}
+
+// createTemp creates and registers a temporary file in dir named after target
+func createTemp(dir, target string) (*os.File, error) {
+	signalsOnce.Do(removeTempsOnSignal)
+
+	file, err := os.CreateTemp(dir, "."+filepath.Base(target)+".*.tmp")
+	if err != nil {
+		return nil, fmt.Errorf("%w: temporary file: %v", constants.ErrFileCreateFailed, err)
+	}
+
+	tempMu.Lock()
+	liveTemps[file.Name()] = struct{}{}
+	tempMu.Unlock()
+	return file, nil
+}
+
+// copyToSibling copies tmpPath into a new temporary file next to target
+func copyToSibling(tmpPath, target string) (string, error) {
+	src, err := os.Open(filepath.Clean(tmpPath))
+	if err != nil {
+		return "", fmt.Errorf("failed to open temporary file: %w", err)
+	}
+	defer src.Close() //nolint:errcheck
+
+	info, err := src.Stat()
+	if err != nil {
+		return "", fmt.Errorf("failed to stat temporary file: %w", err)
+	}
+
+	dst, err := createTemp(filepath.Dir(target), target)
+	if err != nil {
+		return "", err
+	}
+	fail := func(err error) (string, error) {
+		_ = dst.Close()
+		_ = os.Remove(dst.Name())
+		forgetTemp(dst.Name())
+		return "", err
+	}
+
+	if err := dst.Chmod(info.Mode().Perm()); err != nil {
+		return fail(fmt.Errorf("failed to set permissions: %w", err))
+	}
+	if _, err := io.Copy(dst, src); err != nil {
+		return fail(fmt.Errorf("failed to copy temporary file: %w", err))
+	}
+	if err := dst.Sync(); err != nil {
+		return fail(fmt.Errorf("failed to sync: %w", err))
+	}
+	if err := dst.Close(); err != nil {
+		return fail(fmt.Errorf("failed to write temporary file: %w", err))
+	}
+	return dst.Name(), nil
+}
+
+// forgetTemp stops tracking path
+func forgetTemp(path string) {
+	tempMu.Lock()
+	delete(liveTemps, path)
+	tempMu.Unlock()
+}
+
+// removeTempsOnSignal removes live temporary files before an interrupted
+// process exits
+func removeTempsOnSignal() {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-signals
+		RemoveTempFiles()
+		os.Exit(constants.ExitInterrupted)
+	}()
+}
@@ -0,0 +1,93 @@
+package files
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/hambosto/hexwarden/internal/constants"
+)
+
+// wipeState is the progress of an interrupted secure deletion, and the identity
+// of the file it was made on as it stood after the last pass
+type wipeState struct {
+	pattern constants.OverwritePattern
+	passes  int // Passes completed and synced
+	device  uint64
+	inode   uint64
+	size    int64
+	modTime int64 // Unix nanoseconds
+}
+
+// newWipeState records passes of pattern completed on the file described by info
+func newWipeState(pattern constants.OverwritePattern, passes int, info os.FileInfo) wipeState {
+	device, inode := fileID(info)
+	return wipeState{
+		pattern: pattern,
+		passes:  passes,
+		device:  device,
+		inode:   inode,
+		size:    info.Size(),
+		modTime: info.ModTime().UnixNano(),
+	}
+}
+
+// sameFile reports whether info describes the file the record was made on,
+// untouched since. Anything else at the path must be wiped from the first pass
+func (s wipeState) sameFile(info os.FileInfo) bool {
+	device, inode := fileID(info)
+	return s.device == device && s.inode == inode &&
+		s.size == info.Size() && s.modTime == info.ModTime().UnixNano()
+}
+
+// wipeStatePath returns the hidden file recording the secure deletion progress of path
+func wipeStatePath(path string) string {
+	return filepath.Join(filepath.Dir(path), "."+filepath.Base(path)+constants.WipeStateSuffix)
+}
+
+// readWipeState returns the progress recorded for path, and false when none
+// was recorded or the record cannot be read, so the deletion starts over
+func readWipeState(path string) (wipeState, bool) {
+	data, err := os.ReadFile(wipeStatePath(path))
+	if err != nil {
+		return wipeState{}, false
+	}
+	var state wipeState
+	_, err = fmt.Sscanf(string(data), "%s %d %d %d %d %d",
+		&state.pattern, &state.passes, &state.device, &state.inode, &state.size, &state.modTime)
+	if err != nil || state.passes < 0 {
+		return wipeState{}, false
+	}
+	return state, true
+}
+
+// writeWipeState records that passes of pattern have been written over path
+func writeWipeState(path string, state wipeState) error {
+	file, err := os.OpenFile(wipeStatePath(path), os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o600)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(file, "%s %d %d %d %d %d\n",
+		state.pattern, state.passes, state.device, state.inode, state.size, state.modTime)
+	if err != nil {
+		_ = file.Close()
+		return err
+	}
+	if err := file.Sync(); err != nil {
+		_ = file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// clearWipeState removes the progress record of path
+func clearWipeState(path string) {
+	_ = os.Remove(wipeStatePath(path))
+}
+
+// SetOverwriteHook wraps the file every secure deletion pass writes to, so
+// tests can inject a write failure part way through; nil writes directly
+func (m *Manager) SetOverwriteHook(hook func(io.WriteSeeker) io.WriteSeeker) {
+	m.overwriteHook = hook
+}
@@ -0,0 +1,17 @@
+//go:build unix
+
+package files
+
+import (
+	"os"
+	"syscall"
+)
+
+// fileID returns the device and inode of the file described by info
+func fileID(info os.FileInfo) (device, inode uint64) {
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return 0, 0
+	}
+	return uint64(stat.Dev), uint64(stat.Ino) //nolint:unconvert // Their types differ between platforms
+}
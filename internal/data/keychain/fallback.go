@@ -0,0 +1,90 @@
+package keychain
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/hambosto/hexwarden/internal/constants"
+)
+
+// envPrefix prefixes the environment variables read by EnvBackend
+const envPrefix = "HEXWARDEN_SECRET_"
+
+// EnvBackend reads passwords from HEXWARDEN_SECRET_<NAME> environment variables
+type EnvBackend struct{}
+
+// Get returns the password from the entry's environment variable
+func (EnvBackend) Get(name string) (string, error) {
+	password, ok := os.LookupEnv(EnvVar(name))
+	if !ok || password == "" {
+		return "", constants.ErrSecretNotFound
+	}
+	return password, nil
+}
+
+// Set is not supported because the environment cannot be persisted
+func (EnvBackend) Set(string, string) error {
+	return constants.ErrKeychainReadOnly
+}
+
+// EnvVar returns the environment variable name for an entry, e.g. HEXWARDEN_SECRET_MY_ARCHIVE
+func EnvVar(name string) string {
+	return envPrefix + strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' {
+			return r - 'a' + 'A'
+		}
+		if (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') {
+			return r
+		}
+		return '_'
+	}, name)
+}
+
+// FileBackend stores each password unencrypted in a file readable only by the current user
+type FileBackend struct {
+	dir string
+}
+
+// NewFileBackend creates a file backend storing entries in dir
+func NewFileBackend(dir string) *FileBackend {
+	return &FileBackend{dir: dir}
+}
+
+// NewDefaultFileBackend creates a file backend in the user's configuration directory
+func NewDefaultFileBackend() (*FileBackend, error) {
+	configDir, err := os.UserConfigDir()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", constants.ErrKeychainUnavailable, err)
+	}
+	return NewFileBackend(filepath.Join(configDir, constants.AppName, "secrets")), nil
+}
+
+// Get reads the password stored for name
+func (f *FileBackend) Get(name string) (string, error) {
+	data, err := os.ReadFile(f.path(name))
+	if os.IsNotExist(err) {
+		return "", constants.ErrSecretNotFound
+	}
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", constants.ErrFileReadFailed, err)
+	}
+	return string(data), nil
+}
+
+// Set writes the password for name with owner-only permissions
+func (f *FileBackend) Set(name, password string) error {
+	if err := os.MkdirAll(f.dir, 0o700); err != nil {
+		return fmt.Errorf("%w: %v", constants.ErrFileCreateFailed, err)
+	}
+	if err := os.WriteFile(f.path(name), []byte(password), 0o600); err != nil {
+		return fmt.Errorf("%w: %v", constants.ErrFileWriteFailed, err)
+	}
+	return nil
+}
+
+// path returns the file holding the entry
+func (f *FileBackend) path(name string) string {
+	return filepath.Join(f.dir, name)
+}
@@ -0,0 +1,105 @@
+package keychain
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+
+	"github.com/hambosto/hexwarden/internal/constants"
+)
+
+// service is the name entries are stored under in the OS secret store
+const service = constants.AppName
+
+// entryNamePattern restricts entry names to characters safe for every backend
+var entryNamePattern = regexp.MustCompile(`^[A-Za-z0-9._-]{1,64}$`)
+
+// Backend stores passwords under an entry name
+type Backend interface {
+	// Get returns the password for name, or ErrSecretNotFound
+	Get(name string) (string, error)
+	// Set stores the password for name, replacing any existing one
+	Set(name, password string) error
+}
+
+// Keychain fetches passwords from a chain of backends and saves new ones to the first writable one
+type Keychain struct {
+	backends    []Backend
+	onPlaintext func(path string)
+}
+
+// New creates a keychain that consults the backends in order
+func New(backends ...Backend) *Keychain {
+	return &Keychain{backends: backends}
+}
+
+// NewDefault creates a keychain that checks environment variables first, then the
+// OS secret store, falling back to a file store when no secret store is available
+func NewDefault() (*Keychain, error) {
+	if backend, ok := systemBackend(); ok {
+		return New(EnvBackend{}, backend), nil
+	}
+
+	file, err := NewDefaultFileBackend()
+	if err != nil {
+		return nil, err
+	}
+	return New(EnvBackend{}, file), nil
+}
+
+// OnPlaintext sets a function called with the file's path before a password is
+// written unencrypted to a FileBackend, so the user can be warned first
+func (k *Keychain) OnPlaintext(fn func(path string)) {
+	k.onPlaintext = fn
+}
+
+// Lookup returns the stored password for name, or prompts for one. A prompted
+// password comes with a save function to call once it has been proven correct
+func (k *Keychain) Lookup(name string, prompt func() (string, error)) (string, func() error, error) {
+	if err := ValidateName(name); err != nil {
+		return "", nil, err
+	}
+
+	for _, backend := range k.backends {
+		password, err := backend.Get(name)
+		if err == nil {
+			return password, func() error { return nil }, nil
+		}
+		if !errors.Is(err, constants.ErrSecretNotFound) {
+			return "", nil, err
+		}
+	}
+
+	password, err := prompt()
+	if err != nil {
+		return "", nil, err
+	}
+
+	save := func() error {
+		return k.set(name, password)
+	}
+	return password, save, nil
+}
+
+// set stores the password in the first backend that accepts writes
+func (k *Keychain) set(name, password string) error {
+	for _, backend := range k.backends {
+		if file, ok := backend.(*FileBackend); ok && k.onPlaintext != nil {
+			k.onPlaintext(file.path(name))
+		}
+		err := backend.Set(name, password)
+		if errors.Is(err, constants.ErrKeychainReadOnly) {
+			continue
+		}
+		return err
+	}
+	return constants.ErrKeychainUnavailable
+}
+
+// ValidateName checks that an entry name can be used with every backend
+func ValidateName(name string) error {
+	if !entryNamePattern.MatchString(name) {
+		return fmt.Errorf("%w: %q (use 1-64 letters, digits, '.', '_' or '-')", constants.ErrInvalidKeychainEntry, name)
+	}
+	return nil
+}
@@ -0,0 +1,57 @@
+//go:build darwin
+
+package keychain
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+
+	"github.com/hambosto/hexwarden/internal/constants"
+)
+
+// securityTool is the macOS keychain command line interface
+const securityTool = "/usr/bin/security"
+
+// itemNotFoundStatus is the exit status security uses when no item matches
+const itemNotFoundStatus = 44
+
+// macKeychain stores passwords as generic passwords in the login keychain
+type macKeychain struct{}
+
+// systemBackend returns the macOS keychain backend
+func systemBackend() (Backend, bool) {
+	if _, err := exec.LookPath(securityTool); err != nil {
+		return nil, false
+	}
+	return macKeychain{}, true
+}
+
+// Get reads the generic password for the entry
+func (macKeychain) Get(name string) (string, error) {
+	out, err := exec.Command(securityTool, "find-generic-password", "-s", service, "-a", name, "-w").Output() //nolint:gosec
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && exitErr.ExitCode() == itemNotFoundStatus {
+		return "", constants.ErrSecretNotFound
+	}
+	if err != nil {
+		return "", fmt.Errorf("%w: security: %v", constants.ErrKeychainUnavailable, err)
+	}
+	return strings.TrimSuffix(string(out), "\n"), nil
+}
+
+// Set stores the entry through security's interactive mode, so the
+// password never appears in the process list
+func (macKeychain) Set(name, password string) error {
+	command := fmt.Sprintf("add-generic-password -U -s %s -a %s -X %s\n", service, name, hex.EncodeToString([]byte(password)))
+
+	cmd := exec.Command(securityTool, "-i")
+	cmd.Stdin = strings.NewReader(command)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("%w: security: %v: %s", constants.ErrKeychainUnavailable, err, bytes.TrimSpace(out))
+	}
+	return nil
+}
@@ -0,0 +1,51 @@
+//go:build linux
+
+package keychain
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+
+	"github.com/hambosto/hexwarden/internal/constants"
+)
+
+// secretService stores passwords in the freedesktop Secret Service through secret-tool
+type secretService struct {
+	tool string
+}
+
+// systemBackend returns the Secret Service backend when secret-tool is installed
+func systemBackend() (Backend, bool) {
+	tool, err := exec.LookPath("secret-tool")
+	if err != nil {
+		return nil, false
+	}
+	return &secretService{tool: tool}, true
+}
+
+// Get looks up the entry; secret-tool exits with status 1 when nothing matches
+func (s *secretService) Get(name string) (string, error) {
+	out, err := exec.Command(s.tool, "lookup", "service", service, "account", name).Output() //nolint:gosec
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && exitErr.ExitCode() == 1 && len(out) == 0 {
+		return "", constants.ErrSecretNotFound
+	}
+	if err != nil {
+		return "", fmt.Errorf("%w: secret-tool lookup: %v", constants.ErrKeychainUnavailable, err)
+	}
+	return strings.TrimSuffix(string(out), "\n"), nil
+}
+
+// Set stores the entry, passing the password on standard input
+func (s *secretService) Set(name, password string) error {
+	label := fmt.Sprintf("%s password for %s", constants.AppName, name)
+	cmd := exec.Command(s.tool, "store", "--label", label, "service", service, "account", name) //nolint:gosec
+	cmd.Stdin = bytes.NewBufferString(password)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("%w: secret-tool store: %v: %s", constants.ErrKeychainUnavailable, err, bytes.TrimSpace(out))
+	}
+	return nil
+}
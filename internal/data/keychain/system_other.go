@@ -0,0 +1,8 @@
+//go:build !linux && !darwin && !windows
+
+package keychain
+
+// systemBackend reports that no OS secret store is supported on this platform
+func systemBackend() (Backend, bool) {
+	return nil, false
+}
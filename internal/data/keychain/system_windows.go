@@ -0,0 +1,109 @@
+//go:build windows
+
+package keychain
+
+import (
+	"errors"
+	"fmt"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+
+	"github.com/hambosto/hexwarden/internal/constants"
+)
+
+// Credential Manager constants from wincred.h
+const (
+	credTypeGeneric         = 1
+	credPersistLocalMachine = 2
+)
+
+var (
+	advapi32      = windows.NewLazySystemDLL("advapi32.dll")
+	procCredRead  = advapi32.NewProc("CredReadW")
+	procCredWrite = advapi32.NewProc("CredWriteW")
+	procCredFree  = advapi32.NewProc("CredFree")
+)
+
+// credential mirrors the CREDENTIALW structure
+type credential struct {
+	Flags              uint32
+	Type               uint32
+	TargetName         *uint16
+	Comment            *uint16
+	LastWritten        windows.Filetime
+	CredentialBlobSize uint32
+	CredentialBlob     *byte
+	Persist            uint32
+	AttributeCount     uint32
+	Attributes         uintptr
+	TargetAlias        *uint16
+	UserName           *uint16
+}
+
+// credentialManager stores passwords as generic credentials in Windows Credential Manager
+type credentialManager struct{}
+
+// systemBackend returns the Credential Manager backend
+func systemBackend() (Backend, bool) {
+	if procCredRead.Find() != nil || procCredWrite.Find() != nil {
+		return nil, false
+	}
+	return credentialManager{}, true
+}
+
+// target returns the credential target name for an entry
+func target(name string) string {
+	return service + ":" + name
+}
+
+// Get reads the generic credential for the entry
+func (credentialManager) Get(name string) (string, error) {
+	targetName, err := windows.UTF16PtrFromString(target(name))
+	if err != nil {
+		return "", err
+	}
+
+	var cred *credential
+	ret, _, callErr := procCredRead.Call(uintptr(unsafe.Pointer(targetName)), credTypeGeneric, 0, uintptr(unsafe.Pointer(&cred)))
+	if ret == 0 {
+		if errors.Is(callErr, windows.ERROR_NOT_FOUND) {
+			return "", constants.ErrSecretNotFound
+		}
+		return "", fmt.Errorf("%w: CredRead: %v", constants.ErrKeychainUnavailable, callErr)
+	}
+	defer procCredFree.Call(uintptr(unsafe.Pointer(cred))) //nolint:errcheck
+
+	blob := unsafe.Slice(cred.CredentialBlob, cred.CredentialBlobSize)
+	return string(blob), nil
+}
+
+// Set writes the generic credential for the entry
+func (credentialManager) Set(name, password string) error {
+	targetName, err := windows.UTF16PtrFromString(target(name))
+	if err != nil {
+		return err
+	}
+	userName, err := windows.UTF16PtrFromString(name)
+	if err != nil {
+		return err
+	}
+
+	blob := []byte(password)
+	cred := credential{
+		Type:               credTypeGeneric,
+		TargetName:         targetName,
+		CredentialBlobSize: uint32(len(blob)),
+		Persist:            credPersistLocalMachine,
+		UserName:           userName,
+	}
+	if len(blob) > 0 {
+		cred.CredentialBlob = &blob[0]
+	}
+
+	ret, _, callErr := procCredWrite.Call(uintptr(unsafe.Pointer(&cred)), 0)
+	if ret == 0 {
+		return fmt.Errorf("%w: CredWrite: %v", constants.ErrKeychainUnavailable, callErr)
+	}
+	return nil
+}
@@ -0,0 +1,100 @@
+package remote
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sync"
+
+	"github.com/hambosto/hexwarden/internal/constants"
+)
+
+// Uploader streams written data to a URL with a single HTTP PUT request.
+// It works with any endpoint accepting chunked uploads, such as WebDAV servers
+type Uploader struct {
+	writer *io.PipeWriter
+	done   chan error
+	once   sync.Once
+	err    error
+}
+
+// NewUploader starts an upload to rawURL using the default HTTP client
+func NewUploader(rawURL string) (*Uploader, error) {
+	return NewUploaderWithClient(http.DefaultClient, rawURL)
+}
+
+// NewUploaderWithClient starts an upload to rawURL using the given HTTP client
+func NewUploaderWithClient(client *http.Client, rawURL string) (*Uploader, error) {
+	parsed, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", constants.ErrUnsupportedURL, err)
+	}
+	if parsed.Scheme != "http" && parsed.Scheme != "https" {
+		return nil, fmt.Errorf("%w: scheme %q", constants.ErrUnsupportedURL, parsed.Scheme)
+	}
+
+	reader, writer := io.Pipe()
+	req, err := http.NewRequest(http.MethodPut, parsed.String(), reader)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", constants.ErrUnsupportedURL, err)
+	}
+	req.Header.Set("Content-Type", "application/octet-stream")
+
+	u := &Uploader{
+		writer: writer,
+		done:   make(chan error, 1),
+	}
+
+	go func() {
+		err := send(client, req)
+		// Unblock pending writes if the server finished early
+		reader.CloseWithError(err) //nolint:errcheck
+		u.done <- err
+	}()
+
+	return u, nil
+}
+
+// send performs the request and checks the response status
+func send(client *http.Client, req *http.Request) error {
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("%w: %v", constants.ErrUploadFailed, err)
+	}
+	defer resp.Body.Close() //nolint:errcheck
+
+	_, _ = io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("%w: server responded %s", constants.ErrUploadFailed, resp.Status)
+	}
+	return nil
+}
+
+// Write streams p to the server
+func (u *Uploader) Write(p []byte) (int, error) {
+	return u.writer.Write(p)
+}
+
+// Close completes the upload and waits for the server response
+func (u *Uploader) Close() error {
+	if err := u.writer.Close(); err != nil {
+		return err
+	}
+	return u.wait()
+}
+
+// Abort cancels the upload so the server does not receive a truncated body as complete
+func (u *Uploader) Abort(cause error) {
+	u.writer.CloseWithError(cause) //nolint:errcheck
+	u.wait()                       //nolint:errcheck
+}
+
+// wait returns the result of the request once it has finished
+func (u *Uploader) wait() error {
+	u.once.Do(func() {
+		u.err = <-u.done
+	})
+	return u.err
+}
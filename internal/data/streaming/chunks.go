@@ -0,0 +1,112 @@
+package streaming
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+
+	"github.com/hambosto/hexwarden/internal/constants"
+)
+
+// ChunkReader reads the length-prefixed chunks that follow the header of an encrypted file
+type ChunkReader struct {
+	reader   io.Reader
+	readSize func() (uint32, error) // Reads one size prefix in the file's framing
+	footer   bool                   // The chunks end at ChunkFooterMarker rather than at the end of reader
+	ended    bool                   // The footer marker was read
+	offset   int64                  // Bytes consumed so far
+	start    int64                  // Offset of the size prefix of the chunk being read
+}
+
+// NewChunkReader creates a chunk reader positioned at the first chunk, using the
+// framing of the given header format version
+func NewChunkReader(reader io.Reader, formatVersion uint8) (*ChunkReader, error) {
+	c := &ChunkReader{reader: reader}
+
+	// Every format version so far frames chunks with a 4-byte big-endian size.
+	// A version that changes the framing adds its own size reader here, so
+	// files written by older versions keep decrypting
+	switch formatVersion {
+	case constants.FormatVersion2, constants.FormatVersion3, constants.FormatVersion4:
+		c.readSize = c.readChunkSize
+	default:
+		return nil, fmt.Errorf("%w: %d", constants.ErrUnknownFormat, formatVersion)
+	}
+
+	return c, nil
+}
+
+// ExpectFooter makes the chunks end at the footer marker, which is consumed
+// while the footer after it is left unread. Running out of input before the
+// marker then fails with ErrMissingFooter
+func (c *ChunkReader) ExpectFooter() {
+	c.footer = true
+}
+
+// Next returns the next non-empty chunk, or io.EOF once the stream ends cleanly
+func (c *ChunkReader) Next() ([]byte, error) {
+	if c.ended {
+		return nil, io.EOF
+	}
+	for {
+		c.start = c.offset
+
+		chunkLen, err := c.readSize()
+		if err == io.EOF && c.footer {
+			return nil, fmt.Errorf("%w: no footer after %d bytes of chunks", constants.ErrMissingFooter, c.offset)
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		if c.footer && chunkLen == constants.ChunkFooterMarker {
+			c.ended = true
+			return nil, io.EOF
+		}
+
+		if chunkLen == 0 {
+			continue // Skip empty chunks
+		}
+
+		data, err := c.readChunkData(chunkLen)
+		if err != nil {
+			return nil, err
+		}
+
+		return data, nil
+	}
+}
+
+// Offset returns the position of the chunk last read by Next, relative to the first chunk
+func (c *ChunkReader) Offset() int64 {
+	return c.start
+}
+
+// readChunkSize reads the 4-byte big-endian chunk size header
+func (c *ChunkReader) readChunkSize() (uint32, error) {
+	var sizeBuffer [constants.ChunkHeaderSize]byte
+	_, err := io.ReadFull(c.reader, sizeBuffer[:])
+	if err != nil {
+		if err == io.EOF {
+			return 0, err
+		}
+		return 0, fmt.Errorf("chunk size read failed: %w", err)
+	}
+	c.offset += constants.ChunkHeaderSize
+	return binary.BigEndian.Uint32(sizeBuffer[:]), nil
+}
+
+// readChunkData reads the chunk data of specified length
+func (c *ChunkReader) readChunkData(length uint32) ([]byte, error) {
+	if length > math.MaxInt32 {
+		return nil, constants.ErrChunkTooLarge
+	}
+
+	data := make([]byte, length)
+	if _, err := io.ReadFull(c.reader, data); err != nil {
+		return nil, fmt.Errorf("chunk data read failed: %w", err)
+	}
+	c.offset += int64(length)
+	return data, nil
+}
@@ -3,6 +3,8 @@ package streaming
 import (
 	"context"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/hambosto/hexwarden/internal/constants"
 )
@@ -11,6 +13,44 @@ import (
 type Pool struct {
 	size      int                                       // Number of worker goroutines
 	processor func(constants.Task) constants.TaskResult // Function used to process each Task
+	cpuLimit  int                                       // Percent of the available CPU time workers may use; 0 for no limit
+
+	// Utilization counters, read through Stats
+	tasks    atomic.Int64
+	busy     atomic.Int64 // Nanoseconds spent in processor, summed over workers
+	active   atomic.Int64
+	peak     atomic.Int64
+	maxQueue atomic.Int64
+	elapsed  atomic.Int64 // Nanoseconds Process ran
+}
+
+// PoolStats describes how a Pool was used
+type PoolStats struct {
+	Workers       int           // Worker goroutines started
+	PeakWorkers   int           // Most tasks processed at the same time
+	Tasks         int64         // Tasks processed
+	MaxQueueDepth int           // Most tasks seen waiting in the queue
+	Busy          time.Duration // Time spent processing tasks, summed over workers
+	Elapsed       time.Duration // Wall time the workers ran
+}
+
+// BusyRatio returns the fraction of the available worker time spent processing tasks
+func (s PoolStats) BusyRatio() float64 {
+	capacity := s.Elapsed * time.Duration(s.Workers)
+	if capacity <= 0 {
+		return 0
+	}
+	return float64(s.Busy) / float64(capacity)
+}
+
+// Add merges the stats of another run into s
+func (s *PoolStats) Add(other PoolStats) {
+	s.Workers = max(s.Workers, other.Workers)
+	s.PeakWorkers = max(s.PeakWorkers, other.PeakWorkers)
+	s.Tasks += other.Tasks
+	s.MaxQueueDepth = max(s.MaxQueueDepth, other.MaxQueueDepth)
+	s.Busy += other.Busy
+	s.Elapsed += other.Elapsed
 }
 
 // NewPool creates a new Pool with the given size and task processor
@@ -25,28 +65,50 @@ func NewPool(size int, processor func(constants.Task) constants.TaskResult) *Poo
 	}
 }
 
+// SetCPUPercent limits later runs to percent of the CPU time the workers could
+// use, counting no more cores than GOMAXPROCS. 0 or 100 and above run at full speed
+func (p *Pool) SetCPUPercent(percent int) {
+	p.cpuLimit = max(percent, 0)
+}
+
+// Stats returns the utilization of the pool so far
+func (p *Pool) Stats() PoolStats {
+	return PoolStats{
+		Workers:       p.size,
+		PeakWorkers:   int(p.peak.Load()),
+		Tasks:         p.tasks.Load(),
+		MaxQueueDepth: int(p.maxQueue.Load()),
+		Busy:          time.Duration(p.busy.Load()),
+		Elapsed:       time.Duration(p.elapsed.Load()),
+	}
+}
+
 // Process starts the worker pool to process tasks from the input channel
 // Each task is processed using the processor function and the result is sent to the results channel
 // It blocks until all tasks are processed or the context is cancelled
 func (p *Pool) Process(ctx context.Context, tasks <-chan constants.Task, results chan<- constants.TaskResult) error {
 	var wg sync.WaitGroup
+	start := time.Now()
+	limit := newThrottle(p.cpuLimit, p.size)
 
-	// Start 'size' number of worker goroutines
+	// Start exactly 'size' worker goroutines; no task runs outside them
 	for i := 0; i < p.size; i++ {
 		wg.Add(1)
 		go func() {
 			defer wg.Done()
-			p.worker(ctx, tasks, results)
+			p.worker(ctx, tasks, results, limit)
 		}()
 	}
 
 	// Wait for all workers to finish
 	wg.Wait()
+	p.elapsed.Add(int64(time.Since(start)))
 	return nil
 }
 
-// worker defines the logic each worker runs: continuously read tasks and process them
-func (p *Pool) worker(ctx context.Context, tasks <-chan constants.Task, results chan<- constants.TaskResult) {
+// worker defines the logic each worker runs: continuously read tasks and process them.
+// A non-nil limit paces the tasks to stay within its CPU budget
+func (p *Pool) worker(ctx context.Context, tasks <-chan constants.Task, results chan<- constants.TaskResult, limit *throttle) {
 	for {
 		select {
 		case task, ok := <-tasks:
@@ -54,9 +116,16 @@ func (p *Pool) worker(ctx context.Context, tasks <-chan constants.Task, results
 				// Input channel closed: no more tasks
 				return
 			}
+			raiseTo(&p.maxQueue, int64(len(tasks)))
 
 			// Process the task
-			result := p.processor(task)
+			if limit != nil && !limit.acquire(ctx) {
+				return
+			}
+			result, busy := p.run(task)
+			if limit != nil {
+				limit.release(ctx, busy)
+			}
 
 			// Send the result unless the context is cancelled
 			select {
@@ -71,3 +140,27 @@ func (p *Pool) worker(ctx context.Context, tasks <-chan constants.Task, results
 		}
 	}
 }
+
+// run processes a single task, recording how long it took and how many ran at once
+func (p *Pool) run(task constants.Task) (constants.TaskResult, time.Duration) {
+	raiseTo(&p.peak, p.active.Add(1))
+	start := time.Now()
+
+	result := p.processor(task)
+
+	busy := time.Since(start)
+	p.busy.Add(int64(busy))
+	p.active.Add(-1)
+	p.tasks.Add(1)
+	return result, busy
+}
+
+// raiseTo stores value in counter if it is larger than the current value
+func raiseTo(counter *atomic.Int64, value int64) {
+	for {
+		current := counter.Load()
+		if value <= current || counter.CompareAndSwap(current, value) {
+			return
+		}
+	}
+}
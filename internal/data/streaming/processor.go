@@ -1,16 +1,23 @@
 package streaming
 
 import (
+	"bytes"
+	"cmp"
 	"context"
 	"encoding/binary"
 	"fmt"
 	"io"
+	"log/slog"
 	"math"
 	"runtime"
+	"slices"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/hambosto/hexwarden/internal/constants"
 	"github.com/hambosto/hexwarden/internal/infrastructure"
+	"github.com/hambosto/hexwarden/internal/infrastructure/crypto"
 	"github.com/hambosto/hexwarden/internal/presentation/ui"
 )
 
@@ -20,6 +27,21 @@ type StreamProcessor struct {
 	bar       *ui.ProgressBar
 	config    StreamConfig
 	pool      *Pool
+	written   int64 // Plaintext bytes written so far when decrypting
+	started   time.Time
+	totalSize int64 // Plaintext size when decrypting, bounding the last hole; negative when unknown
+
+	// Progress counters, read from other goroutines through Progress
+	chunks     atomic.Int64 // Chunks written so far
+	processed  atomic.Int64 // Progress bytes written so far
+	lastUpdate atomic.Int64 // Unix nanoseconds of the last chunk written, or of the start
+
+	holesMu sync.Mutex
+	holes   []Hole // Zero-filled chunks when ZeroCorrupt is set
+
+	// Idle watchdog state, used when IdleTimeout is set
+	lastProgress atomic.Int64 // Unix nanoseconds of the last read or write
+	stalled      atomic.Bool
 
 	// Channels for task processing pipeline
 	taskChan   chan constants.Task
@@ -32,11 +54,24 @@ type StreamProcessor struct {
 
 // StreamConfig holds stream processing configuration
 type StreamConfig struct {
-	Key         []byte
-	Processing  constants.Processing
-	Concurrency int
-	QueueSize   int
-	ChunkSize   int
+	Key           []byte
+	Cipher        constants.CipherAlgorithm   // Cipher the chunks are encrypted with
+	AAD           []byte                      // Associated data every chunk is bound to; nil for none
+	Nonces        *crypto.ChunkNonces         // Counter nonces chunks are sealed under when encrypting; nil draws them at random
+	Compression   constants.CompressionFormat // Format chunks are compressed in
+	Processing    constants.Processing
+	Concurrency   int
+	QueueSize     int
+	ChunkSize     int
+	FormatVersion uint8         // Header format version of the input, selecting its chunk framing when decrypting
+	MaxOutputSize int64         // Plaintext limit when decrypting; exceeding it aborts with ErrDecompressionBomb. 0 means no limit
+	Logger        *slog.Logger  // Receives throughput every LogChunkInterval chunks at debug level; nil logs nothing
+	OnChunk       func([]byte)  // Called with each output chunk in file order, after it is written
+	IdleTimeout   time.Duration // Cancels with ErrStalled when no bytes are read or written for this long; 0 waits forever
+	Unencrypted   bool          // Skips the cipher, so chunks are only protected by Reed-Solomon; Key is unused
+	Paranoid      bool          // Decodes every encoded chunk again before writing it, failing with ErrSelfCheckFailed on a mismatch
+	ZeroCorrupt   bool          // Writes zeros for chunks that cannot be decrypted instead of failing, recording them as holes
+	Footer        bool          // The chunks end at ChunkFooterMarker when decrypting, leaving the size footer or output padding unread in the input
 }
 
 // NewStreamProcessor creates a new stream processor instance
@@ -45,10 +80,22 @@ func NewStreamProcessor(config StreamConfig) (*StreamProcessor, error) {
 		return nil, err
 	}
 
-	processor, err := infrastructure.NewProcessor(config.Key)
+	newProcessor := func() (*infrastructure.Processor, error) {
+		return infrastructure.NewProcessorWithAAD(config.Key, config.Cipher, config.AAD)
+	}
+	if config.Unencrypted {
+		newProcessor = infrastructure.NewUnencryptedProcessor
+	}
+
+	processor, err := newProcessor()
 	if err != nil {
 		return nil, fmt.Errorf("failed to create processor: %w", err)
 	}
+	processor.SetParanoid(config.Paranoid)
+	processor.SetNonces(config.Nonces)
+	if err := processor.SetCompression(config.Compression); err != nil {
+		return nil, err
+	}
 
 	config.ApplyDefaults()
 	ctx, cancel := context.WithCancel(context.Background())
@@ -61,12 +108,13 @@ func NewStreamProcessor(config StreamConfig) (*StreamProcessor, error) {
 	}
 
 	s.pool = NewPool(config.Concurrency, s.processTask)
+	s.pool.SetCPUPercent(int(cpuPercent.Load()))
 	return s, nil
 }
 
 // Validate validates the stream configuration
 func (c *StreamConfig) Validate() error {
-	if len(c.Key) != constants.KeySize {
+	if !c.Unencrypted && len(c.Key) != constants.KeySize {
 		return constants.ErrInvalidKey
 	}
 	return nil
@@ -83,6 +131,57 @@ func (c *StreamConfig) ApplyDefaults() {
 	if c.ChunkSize <= 0 {
 		c.ChunkSize = constants.DefaultChunkSize
 	}
+	if c.FormatVersion == 0 {
+		c.FormatVersion = constants.FormatVersion3
+	}
+	if c.Logger == nil {
+		c.Logger = slog.New(slog.DiscardHandler)
+	}
+}
+
+// EstimateOutputSize returns an upper bound on the framed ciphertext produced
+// when encrypting totalSize bytes in chunks of chunkSize
+func EstimateOutputSize(totalSize int64, chunkSize int) int64 {
+	if totalSize <= 0 {
+		return 0
+	}
+	if chunkSize <= 0 {
+		chunkSize = constants.DefaultChunkSize
+	}
+
+	fullChunks := totalSize / int64(chunkSize)
+	remainder := int(totalSize % int64(chunkSize))
+
+	size := fullChunks * int64(constants.ChunkHeaderSize+infrastructure.MaxEncryptedSize(chunkSize))
+	if remainder > 0 {
+		size += int64(constants.ChunkHeaderSize + infrastructure.MaxEncryptedSize(remainder))
+	}
+	return size
+}
+
+// Stats returns the utilization of the worker pool so far
+func (s *StreamProcessor) Stats() PoolStats {
+	return s.pool.Stats()
+}
+
+// Progress is a snapshot of how far a stream has got
+type Progress struct {
+	Bytes      int64     // Plaintext bytes processed so far
+	Chunk      int64     // Index of the chunk being waited on, which is also the number written
+	LastUpdate time.Time // When the last chunk was written, or processing started; zero before Process
+}
+
+// Progress returns how far processing has got. It is safe to call from any
+// goroutine while Process runs, so a server can tell a slow stream from a stuck one
+func (s *StreamProcessor) Progress() Progress {
+	progress := Progress{
+		Bytes: s.processed.Load(),
+		Chunk: s.chunks.Load(),
+	}
+	if nanos := s.lastUpdate.Load(); nanos != 0 {
+		progress.LastUpdate = time.Unix(0, nanos)
+	}
+	return progress
 }
 
 // Cancel cancels the stream processing
@@ -97,7 +196,43 @@ func (s *StreamProcessor) Process(input io.Reader, output io.Writer, totalSize i
 	}
 
 	s.bar = ui.NewProgressBar(totalSize, s.config.Processing.String())
-	return s.runPipeline(input, output)
+	s.totalSize = totalSize
+	s.started = time.Now()
+	s.lastUpdate.Store(s.started.UnixNano())
+
+	if s.config.IdleTimeout > 0 {
+		s.touch()
+		input = progressReader{r: input, onProgress: s.touch}
+		output = progressWriter{w: output, onProgress: s.touch}
+
+		stop := make(chan struct{})
+		defer close(stop)
+		go s.watchIdle(stop)
+	}
+
+	if err := s.runPipeline(input, output); err != nil {
+		return err
+	}
+
+	s.logThroughput("stream finished")
+	return nil
+}
+
+// logThroughput logs the chunks and bytes processed so far and their rate
+func (s *StreamProcessor) logThroughput(msg string) {
+	elapsed := time.Since(s.started)
+	rate := 0.0
+	if seconds := elapsed.Seconds(); seconds > 0 {
+		rate = float64(s.processed.Load()) / (1 << 20) / seconds
+	}
+
+	s.config.Logger.Debug(msg,
+		"stage", "body",
+		"chunks", s.chunks.Load(),
+		"bytes", s.processed.Load(),
+		"elapsed", elapsed.Round(time.Millisecond),
+		"mib_per_sec", fmt.Sprintf("%.1f", rate),
+	)
 }
 
 // processTask processes a single task based on the operation type
@@ -107,9 +242,13 @@ func (s *StreamProcessor) processTask(task constants.Task) constants.TaskResult
 
 	switch s.config.Processing {
 	case constants.Encryption:
-		output, err = s.processor.Encrypt(task.Data)
+		output, err = s.processor.EncryptChunk(task.Data, task.Index)
 	case constants.Decryption:
-		output, err = s.processor.Decrypt(task.Data)
+		// Chunks hold at most ChunkSize bytes, but may be shorter than their
+		// index suggests, so only that is capped here and MaxOutputSize bounds the rest.
+		// A chunk that fails is zero-filled by the writer when ZeroCorrupt is set,
+		// which knows where it starts
+		output, err = s.processor.DecryptSized(task.Data, s.config.ChunkSize)
 	default:
 		err = fmt.Errorf("unknown processing type: %d", s.config.Processing)
 	}
@@ -124,6 +263,46 @@ func (s *StreamProcessor) processTask(task constants.Task) constants.TaskResult
 	}
 }
 
+// Hole is a range of the plaintext written as zeros because its chunk could not be decrypted
+type Hole struct {
+	Offset int64
+	Length int64
+	Err    error // Why the chunk failed
+}
+
+// Holes returns the zero-filled ranges written so far, in file order
+func (s *StreamProcessor) Holes() []Hole {
+	s.holesMu.Lock()
+	defer s.holesMu.Unlock()
+
+	holes := slices.Clone(s.holes)
+	slices.SortFunc(holes, func(a, b Hole) int { return cmp.Compare(a.Offset, b.Offset) })
+	return holes
+}
+
+// fillHole records chunk index, starting at the plaintext written so far, as a
+// hole and returns the zeros standing in for its plaintext
+func (s *StreamProcessor) fillHole(index uint64, err error) []byte {
+	offset := s.written
+	length := s.holeLength(offset)
+	s.config.Logger.Warn("zero-filled a corrupt chunk", "chunk", index, "offset", offset, "length", length, "error", err)
+
+	s.holesMu.Lock()
+	s.holes = append(s.holes, Hole{Offset: offset, Length: length, Err: err})
+	s.holesMu.Unlock()
+	return make([]byte, length)
+}
+
+// holeLength returns the plaintext length of a lost chunk starting at offset.
+// Chunks are written full, so it is a chunk or what is left of the total size.
+// Without a total size every chunk is taken to be full
+func (s *StreamProcessor) holeLength(offset int64) int64 {
+	if s.totalSize < 0 {
+		return int64(s.config.ChunkSize)
+	}
+	return max(min(int64(s.config.ChunkSize), s.totalSize-offset), 0)
+}
+
 // calculateProgressSize determines the size to use for progress tracking
 func (s *StreamProcessor) calculateProgressSize(input, output []byte) int {
 	if s.config.Processing == constants.Encryption {
@@ -222,16 +401,28 @@ func (p *pipeline) waitForCompletion(wg *sync.WaitGroup) error {
 	select {
 	case err := <-p.errChan:
 		p.stream.cancel() // Cancel other goroutines
-		wg.Wait()         // Wait for cleanup
+		if p.stream.stalled.Load() {
+			return p.stream.stalledError()
+		}
+		wg.Wait() // Wait for cleanup
 		return err
 	case <-done:
 		return nil
 	case <-p.stream.ctx.Done():
+		if p.stream.stalled.Load() {
+			return p.stream.stalledError()
+		}
 		wg.Wait()
 		return constants.ErrCanceled
 	}
 }
 
+// stalledError reports an idle timeout. Callers return it without waiting for
+// the pipeline, since the stage blocked in I/O may never return
+func (s *StreamProcessor) stalledError() error {
+	return fmt.Errorf("%w: no data read or written for %s", constants.ErrStalled, s.config.IdleTimeout)
+}
+
 // readTasks reads input based on processing type
 func (s *StreamProcessor) readTasks(input io.Reader) error {
 	switch s.config.Processing {
@@ -254,37 +445,58 @@ func (s *StreamProcessor) readForEncryption(reader io.Reader) error {
 			return err
 		}
 
-		n, err := reader.Read(buffer)
-		if err == io.EOF {
-			return nil
+		// Every chunk but the last is filled, however short the reads, so
+		// chunk index starts at index*ChunkSize in the plaintext
+		n, err := io.ReadFull(reader, buffer)
+		if err == io.ErrUnexpectedEOF {
+			err = io.EOF
 		}
-		if err != nil {
+		if err != nil && err != io.EOF {
 			return fmt.Errorf("read failed: %w", err)
 		}
 
-		task := constants.Task{
-			Data:  make([]byte, n),
-			Index: index,
+		// Only non-empty chunks become tasks, so the writer sees no index gaps
+		if n > 0 {
+			task := constants.Task{
+				Data:  make([]byte, n),
+				Index: index,
+			}
+			copy(task.Data, buffer[:n])
+
+			if err := s.sendTask(task); err != nil {
+				return err
+			}
+			index++
 		}
-		copy(task.Data, buffer[:n])
 
-		if err := s.sendTask(task); err != nil {
-			return err
+		if err == io.EOF {
+			return nil
 		}
-		index++
 	}
 }
 
 // readForDecryption reads data with length prefixes
 func (s *StreamProcessor) readForDecryption(reader io.Reader) error {
-	var index uint64
+	chunks, err := NewChunkReader(reader, s.config.FormatVersion)
+	if err != nil {
+		return err
+	}
+	if s.config.Footer {
+		chunks.ExpectFooter()
+	}
+
+	var (
+		index    uint64
+		previous []byte
+	)
 
 	for {
 		if err := s.checkCancellation(); err != nil {
 			return err
 		}
 
-		chunkLen, err := s.readChunkSize(reader)
+		// Next skips empty chunks, so the index counts only the chunks sent on
+		data, err := chunks.Next()
 		if err == io.EOF {
 			return nil
 		}
@@ -292,14 +504,16 @@ func (s *StreamProcessor) readForDecryption(reader io.Reader) error {
 			return err
 		}
 
-		if chunkLen == 0 {
-			continue // Skip empty chunks
-		}
-
-		data, err := s.readChunkData(reader, chunkLen)
-		if err != nil {
-			return err
+		// A download retried without resuming properly can repeat a whole chunk.
+		// Every encrypted chunk has its own random nonce, so a byte-identical
+		// neighbour is such a repeat: it authenticates exactly as the chunk before
+		// it did, and decrypting it again would only duplicate plaintext. Chunks
+		// without encryption can legitimately repeat, so they are always kept
+		if !s.config.Unencrypted && bytes.Equal(data, previous) {
+			s.config.Logger.Warn("skipped a repeated chunk", "chunk", index-1, "offset", chunks.Offset())
+			continue
 		}
+		previous = data
 
 		task := constants.Task{
 			Data:  data,
@@ -313,32 +527,6 @@ func (s *StreamProcessor) readForDecryption(reader io.Reader) error {
 	}
 }
 
-// readChunkSize reads the 4-byte chunk size header
-func (s *StreamProcessor) readChunkSize(reader io.Reader) (uint32, error) {
-	var sizeBuffer [constants.ChunkHeaderSize]byte
-	_, err := io.ReadFull(reader, sizeBuffer[:])
-	if err != nil {
-		if err == io.EOF {
-			return 0, err
-		}
-		return 0, fmt.Errorf("chunk size read failed: %w", err)
-	}
-	return binary.BigEndian.Uint32(sizeBuffer[:]), nil
-}
-
-// readChunkData reads the chunk data of specified length
-func (s *StreamProcessor) readChunkData(reader io.Reader, length uint32) ([]byte, error) {
-	if length > math.MaxInt32 {
-		return nil, constants.ErrChunkTooLarge
-	}
-
-	data := make([]byte, length)
-	if _, err := io.ReadFull(reader, data); err != nil {
-		return nil, fmt.Errorf("chunk data read failed: %w", err)
-	}
-	return data, nil
-}
-
 // checkCancellation checks if the context is cancelled
 func (s *StreamProcessor) checkCancellation() error {
 	select {
@@ -370,7 +558,7 @@ func (s *StreamProcessor) writeResults(writer io.Writer) error {
 				return s.flushRemainingResults(writer, buffer)
 			}
 
-			if result.Err != nil {
+			if result.Err != nil && !s.zeroFills() {
 				return fmt.Errorf("processing chunk %d: %w", result.Index, result.Err)
 			}
 
@@ -385,6 +573,11 @@ func (s *StreamProcessor) writeResults(writer io.Writer) error {
 	}
 }
 
+// zeroFills reports whether chunks that fail to decrypt are written as holes
+func (s *StreamProcessor) zeroFills() bool {
+	return s.config.ZeroCorrupt && s.config.Processing == constants.Decryption
+}
+
 // flushRemainingResults writes any remaining buffered results
 func (s *StreamProcessor) flushRemainingResults(writer io.Writer, buffer *Buffer) error {
 	remaining := buffer.Flush()
@@ -410,16 +603,48 @@ func (s *StreamProcessor) writeResult(writer io.Writer, result constants.TaskRes
 		}
 	}
 
+	if result.Err != nil {
+		result.Data = s.fillHole(result.Index, result.Err)
+		result.Size = len(result.Data)
+	}
+
+	if err := s.checkOutputSize(result); err != nil {
+		return err
+	}
+
 	// Write chunk data
 	if _, err := writer.Write(result.Data); err != nil {
 		return fmt.Errorf("writing chunk data: %w", err)
 	}
+	if s.config.OnChunk != nil {
+		s.config.OnChunk(result.Data)
+	}
 
 	// Update progress bar
 	if err := s.bar.Add(int64(result.Size)); err != nil {
 		return fmt.Errorf("updating progress: %w", err)
 	}
 
+	s.processed.Add(int64(result.Size))
+	s.lastUpdate.Store(time.Now().UnixNano())
+	if s.chunks.Add(1)%constants.LogChunkInterval == 0 {
+		s.logThroughput("chunks processed")
+	}
+
+	return nil
+}
+
+// checkOutputSize rejects a decrypted chunk that would take the plaintext past
+// MaxOutputSize, before any of it is written
+func (s *StreamProcessor) checkOutputSize(result constants.TaskResult) error {
+	if s.config.Processing != constants.Decryption {
+		return nil
+	}
+
+	s.written += int64(len(result.Data))
+	if s.config.MaxOutputSize > 0 && s.written > s.config.MaxOutputSize {
+		return fmt.Errorf("%w: chunk %d takes the output past %d bytes", constants.ErrDecompressionBomb, result.Index, s.config.MaxOutputSize)
+	}
 	return nil
 }
 
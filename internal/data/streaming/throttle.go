@@ -0,0 +1,80 @@
+package streaming
+
+import (
+	"context"
+	"math"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// cpuPercent is the process-wide limit set by --cpu-percent; 0 runs at full speed
+var cpuPercent atomic.Int64
+
+// SetCPUPercent limits every stream processor created afterwards to percent of
+// the CPU time its workers could use. 0 or 100 and above run at full speed
+func SetCPUPercent(percent int) {
+	cpuPercent.Store(int64(max(percent, 0)))
+}
+
+// throttle keeps the average number of busy workers under a budget of cores.
+// It caps how many tasks run at once and, after each task, makes the worker
+// sleep for as long as the pool has been busy beyond the budget
+type throttle struct {
+	budget float64       // Cores the workers may keep busy on average
+	slots  chan struct{} // One per task allowed to run at once
+	start  time.Time
+
+	mu   sync.Mutex
+	busy time.Duration // Time spent processing tasks, summed over workers
+}
+
+// newThrottle returns a throttle allowing percent of the CPU time available to
+// workers goroutines, or nil when percent leaves them unthrottled
+func newThrottle(percent, workers int) *throttle {
+	if percent <= 0 || percent >= 100 {
+		return nil
+	}
+	// The workers cannot use more cores than GOMAXPROCS lets run at once
+	cores := min(workers, runtime.GOMAXPROCS(0))
+	budget := float64(cores) * float64(percent) / 100
+
+	return &throttle{
+		budget: budget,
+		slots:  make(chan struct{}, int(math.Ceil(budget))),
+		start:  time.Now(),
+	}
+}
+
+// acquire waits for a slot to run a task, reporting false when ctx is done first
+func (t *throttle) acquire(ctx context.Context) bool {
+	select {
+	case t.slots <- struct{}{}:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// release frees the slot of a task that took busy and backs off until the
+// average load is within the budget again
+func (t *throttle) release(ctx context.Context, busy time.Duration) {
+	<-t.slots
+
+	t.mu.Lock()
+	t.busy += busy
+	allowed := time.Duration(t.budget * float64(time.Since(t.start)))
+	ahead := time.Duration(float64(t.busy-allowed) / t.budget)
+	t.mu.Unlock()
+
+	if ahead <= 0 {
+		return
+	}
+	timer := time.NewTimer(ahead)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+	case <-ctx.Done():
+	}
+}
@@ -0,0 +1,63 @@
+package streaming
+
+import (
+	"io"
+	"time"
+)
+
+// progressReader reports every successful read to onProgress
+type progressReader struct {
+	r          io.Reader
+	onProgress func()
+}
+
+func (p progressReader) Read(b []byte) (int, error) {
+	n, err := p.r.Read(b)
+	if n > 0 {
+		p.onProgress()
+	}
+	return n, err
+}
+
+// progressWriter reports every successful write to onProgress
+type progressWriter struct {
+	w          io.Writer
+	onProgress func()
+}
+
+func (p progressWriter) Write(b []byte) (int, error) {
+	n, err := p.w.Write(b)
+	if n > 0 {
+		p.onProgress()
+	}
+	return n, err
+}
+
+// touch records that bytes were read or written just now
+func (s *StreamProcessor) touch() {
+	s.lastProgress.Store(time.Now().UnixNano())
+}
+
+// watchIdle cancels the stream once no bytes have been read or written for
+// IdleTimeout. It returns when stop is closed
+func (s *StreamProcessor) watchIdle(stop <-chan struct{}) {
+	interval := max(s.config.IdleTimeout/4, time.Millisecond)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-s.ctx.Done():
+			return
+		case now := <-ticker.C:
+			idle := now.Sub(time.Unix(0, s.lastProgress.Load()))
+			if idle >= s.config.IdleTimeout {
+				s.stalled.Store(true)
+				s.Cancel()
+				return
+			}
+		}
+	}
+}
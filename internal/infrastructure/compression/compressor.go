@@ -2,45 +2,97 @@ package compression
 
 import (
 	"bytes"
+	"compress/flate"
 	"compress/gzip"
+	"encoding/binary"
+	"fmt"
 	"io"
+	"strings"
 
 	"github.com/hambosto/hexwarden/internal/constants"
 )
 
-// MaxDecompressionSize limits the maximum size of decompressed data to prevent decompression bombs
-const MaxDecompressionSize = 100 * 1024 * 1024 // 100MB
+// storedMarker starts every chunk written in CompressionStored
+const storedMarker byte = 0x00
 
-// Compressor handles data compression and decompression using gzip
+// gzipMinSize is the 10-byte header and 8-byte trailer of a gzip stream
+const gzipMinSize = 18
+
+// Compressor handles data compression and decompression using gzip or raw
+// deflate, or stores data unchanged behind a marker byte
 type Compressor struct {
-	level int
+	level  int
+	format constants.CompressionFormat
 }
 
-// NewCompressor creates a new compressor with the specified compression level
+// NewCompressor creates a new gzip compressor with the specified compression level
 func NewCompressor(level constants.CompressionLevel) (*Compressor, error) {
+	return NewCompressorWithFormat(level, constants.CompressionGzip)
+}
+
+// NewCompressorWithFormat creates a compressor writing and reading the given format
+func NewCompressorWithFormat(level constants.CompressionLevel, format constants.CompressionFormat) (*Compressor, error) {
 	// Validate compression level
 	if level < constants.LevelNoCompression || level > constants.LevelBestCompression {
 		level = constants.LevelDefaultCompression
 	}
+	if format != constants.CompressionGzip && format != constants.CompressionRawDeflate && format != constants.CompressionStored {
+		return nil, fmt.Errorf("%w: unsupported compression %s", constants.ErrInvalidOption, format)
+	}
 
 	return &Compressor{
-		level: int(level),
+		level:  int(level),
+		format: format,
 	}, nil
 }
 
+// ParseFormat parses a compression format name: gzip, deflate for raw deflate,
+// or none to store chunks uncompressed
+func ParseFormat(name string) (constants.CompressionFormat, error) {
+	switch strings.ToLower(strings.ReplaceAll(name, "-", "")) {
+	case "gzip":
+		return constants.CompressionGzip, nil
+	case "deflate", "rawdeflate":
+		return constants.CompressionRawDeflate, nil
+	case "none", "stored":
+		return constants.CompressionStored, nil
+	default:
+		return 0, fmt.Errorf("%w: unknown compression %q", constants.ErrInvalidOption, name)
+	}
+}
+
+// newWriter returns a writer compressing into buf in the compressor's format
+func (c *Compressor) newWriter(buf *bytes.Buffer) (io.WriteCloser, error) {
+	if c.format == constants.CompressionRawDeflate {
+		return flate.NewWriter(buf, c.level)
+	}
+	return gzip.NewWriterLevel(buf, c.level)
+}
+
+// newReader returns a reader decompressing data in the compressor's format
+func (c *Compressor) newReader(data []byte) (io.ReadCloser, error) {
+	if c.format == constants.CompressionRawDeflate {
+		return flate.NewReader(bytes.NewReader(data)), nil
+	}
+	return gzip.NewReader(bytes.NewReader(data))
+}
+
 // NewDefaultCompressor creates a new compressor with default compression level
 func NewDefaultCompressor() (*Compressor, error) {
 	return NewCompressor(constants.LevelDefaultCompression)
 }
 
-// Compress compresses the input data using gzip
+// Compress compresses the input data in the compressor's format
 func (c *Compressor) Compress(data []byte) ([]byte, error) {
 	if len(data) == 0 {
 		return data, nil
 	}
+	if c.format == constants.CompressionStored {
+		return append([]byte{storedMarker}, data...), nil
+	}
 
 	var buf bytes.Buffer
-	writer, err := gzip.NewWriterLevel(&buf, c.level)
+	writer, err := c.newWriter(&buf)
 	if err != nil {
 		return nil, constants.ErrCompressionFailed
 	}
@@ -61,13 +113,32 @@ func (c *Compressor) Compress(data []byte) ([]byte, error) {
 	return buf.Bytes(), nil
 }
 
-// Decompress decompresses the input data using gzip
+// Decompress decompresses the input data in the compressor's format
 func (c *Compressor) Decompress(data []byte) ([]byte, error) {
+	return c.DecompressSized(data, 0)
+}
+
+// DecompressSized decompresses like Decompress, expecting at most size bytes:
+// the plaintext length of the chunk. The buffer is allocated for size bytes up
+// front, and decompression stops with ErrDecompressionBomb as soon as the data
+// inflates past it. Without a size, the cap is a full chunk and gzip's recorded
+// length sizes the buffer
+func (c *Compressor) DecompressSized(data []byte, size int) ([]byte, error) {
 	if len(data) == 0 {
 		return data, nil
 	}
+	if size <= 0 || size > constants.DefaultChunkSize {
+		size = constants.DefaultChunkSize
+	}
+	if c.format == constants.CompressionStored {
+		return unstore(data, size)
+	}
+	capacity := size
+	if size == constants.DefaultChunkSize {
+		capacity = c.recordedSize(data)
+	}
 
-	reader, err := gzip.NewReader(bytes.NewReader(data))
+	reader, err := c.newReader(data)
 	if err != nil {
 		return nil, constants.ErrDecompressionFailed
 	}
@@ -78,12 +149,41 @@ func (c *Compressor) Decompress(data []byte) ([]byte, error) {
 		}
 	}()
 
-	var buf bytes.Buffer
-	// Use LimitReader to prevent decompression bombs
-	limitedReader := io.LimitReader(reader, MaxDecompressionSize)
-	if _, err := io.Copy(&buf, limitedReader); err != nil {
+	// The copy reads until EOF with at least MinRead bytes free, so that much is added
+	buf := bytes.NewBuffer(make([]byte, 0, capacity+bytes.MinRead))
+	// One byte past size is enough to tell a decompression bomb
+	limitedReader := io.LimitReader(reader, int64(size)+1)
+	if _, err := io.Copy(buf, limitedReader); err != nil {
 		return nil, constants.ErrDecompressionFailed
 	}
+	if buf.Len() > size {
+		return nil, fmt.Errorf("%w: chunk inflates past %d bytes", constants.ErrDecompressionBomb, size)
+	}
 
 	return buf.Bytes(), nil
 }
+
+// recordedSize returns the uncompressed length a gzip stream records in its
+// trailer (modulo 2^32), or 0 when the format records none. A stream without
+// encryption could claim any length, so more than a chunk is not trusted
+func (c *Compressor) recordedSize(data []byte) int {
+	if c.format != constants.CompressionGzip || len(data) < gzipMinSize {
+		return 0
+	}
+	size := int(binary.LittleEndian.Uint32(data[len(data)-4:]))
+	if size > constants.DefaultChunkSize {
+		return 0
+	}
+	return size
+}
+
+// unstore checks the marker of a stored chunk and returns the at most size bytes behind it
+func unstore(data []byte, size int) ([]byte, error) {
+	if data[0] != storedMarker {
+		return nil, constants.ErrDecompressionFailed
+	}
+	if len(data)-1 > size {
+		return nil, fmt.Errorf("%w: stored chunk holds more than %d bytes", constants.ErrDecompressionBomb, size)
+	}
+	return data[1:], nil
+}
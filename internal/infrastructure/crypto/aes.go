@@ -4,6 +4,7 @@ import (
 	"crypto/aes"
 	"crypto/cipher"
 	"crypto/rand"
+	"fmt"
 	"io"
 
 	"github.com/hambosto/hexwarden/internal/constants"
@@ -36,36 +37,85 @@ func NewAESCipher(key []byte) (*AESCipher, error) {
 
 // Encrypt encrypts the plaintext and returns the ciphertext with nonce prepended
 func (c *AESCipher) Encrypt(plaintext []byte) ([]byte, error) {
-	if len(plaintext) == 0 {
-		return nil, constants.ErrEmptyPlaintext
-	}
+	return seal(c.aead, plaintext, nil)
+}
+
+// EncryptWithAAD encrypts the plaintext bound to aad, which is authenticated but not stored
+func (c *AESCipher) EncryptWithAAD(plaintext, aad []byte) ([]byte, error) {
+	return seal(c.aead, plaintext, aad)
+}
+
+// EncryptWithNonce encrypts the plaintext bound to aad under nonce, which must be
+// NonceSize bytes and never used with this key before, and prepends the nonce
+func (c *AESCipher) EncryptWithNonce(nonce, plaintext, aad []byte) ([]byte, error) {
+	return sealWithNonce(c.aead, nonce, plaintext, aad)
+}
+
+// Decrypt decrypts the ciphertext (which should have nonce prepended) and returns the plaintext.
+// Both failures wrap ErrDecryptionFailed: ErrCiphertextTooShort when the input cannot even
+// hold the nonce and tag, so it was cut short, and ErrTagMismatch when authentication fails
+func (c *AESCipher) Decrypt(ciphertext []byte) ([]byte, error) {
+	return open(c.aead, ciphertext, nil)
+}
+
+// DecryptWithAAD decrypts a ciphertext from EncryptWithAAD, failing with
+// ErrTagMismatch unless aad is the same
+func (c *AESCipher) DecryptWithAAD(ciphertext, aad []byte) ([]byte, error) {
+	return open(c.aead, ciphertext, aad)
+}
 
-	nonce := make([]byte, c.aead.NonceSize())
+// NonceSize returns the size of the nonce prepended to every ciphertext
+func (c *AESCipher) NonceSize() int {
+	return c.aead.NonceSize()
+}
+
+// Overhead returns the bytes Encrypt adds to the plaintext: the nonce and the tag
+func (c *AESCipher) Overhead() int {
+	return c.aead.NonceSize() + c.aead.Overhead()
+}
+
+// seal encrypts plaintext bound to aad under a random nonce and prepends the nonce
+func seal(aead cipher.AEAD, plaintext, aad []byte) ([]byte, error) {
+	nonce := make([]byte, aead.NonceSize())
 	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
 		return nil, err
 	}
 
-	ciphertext := c.aead.Seal(nonce, nonce, plaintext, nil)
-	return ciphertext, nil
+	return sealWithNonce(aead, nonce, plaintext, aad)
 }
 
-// Decrypt decrypts the ciphertext (which should have nonce prepended) and returns the plaintext
-func (c *AESCipher) Decrypt(ciphertext []byte) ([]byte, error) {
+// sealWithNonce encrypts plaintext bound to aad under nonce and prepends the nonce
+func sealWithNonce(aead cipher.AEAD, nonce, plaintext, aad []byte) ([]byte, error) {
+	if len(plaintext) == 0 {
+		return nil, constants.ErrEmptyPlaintext
+	}
+	if len(nonce) != aead.NonceSize() {
+		return nil, fmt.Errorf("%w: got %d bytes, want %d", constants.ErrInvalidNonce, len(nonce), aead.NonceSize())
+	}
+
+	sealed := make([]byte, len(nonce), len(nonce)+len(plaintext)+aead.Overhead())
+	copy(sealed, nonce)
+	return aead.Seal(sealed, nonce, plaintext, aad), nil
+}
+
+// open decrypts a ciphertext written by seal with the same aad
+func open(aead cipher.AEAD, ciphertext, aad []byte) ([]byte, error) {
 	if len(ciphertext) == 0 {
 		return nil, constants.ErrEmptyCiphertext
 	}
 
-	nonceSize := c.aead.NonceSize()
-	if len(ciphertext) < nonceSize {
-		return nil, constants.ErrDecryptionFailed
+	nonceSize := aead.NonceSize()
+	if minSize := nonceSize + aead.Overhead(); len(ciphertext) < minSize {
+		return nil, fmt.Errorf("%w: %w: %d bytes, at least %d required",
+			constants.ErrDecryptionFailed, constants.ErrCiphertextTooShort, len(ciphertext), minSize)
 	}
 
 	nonce := ciphertext[:nonceSize]
 	ciphertext = ciphertext[nonceSize:]
 
-	plaintext, err := c.aead.Open(nil, nonce, ciphertext, nil)
+	plaintext, err := aead.Open(nil, nonce, ciphertext, aad)
 	if err != nil {
-		return nil, constants.ErrDecryptionFailed
+		return nil, fmt.Errorf("%w: %w", constants.ErrDecryptionFailed, constants.ErrTagMismatch)
 	}
 
 	return plaintext, nil
@@ -0,0 +1,435 @@
+package crypto
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/hambosto/hexwarden/internal/constants"
+)
+
+// HeaderOption configures an optional header field for Build
+type HeaderOption func(*headerBuilder) error
+
+// headerBuilder collects optional header fields before they are validated and sealed
+type headerBuilder struct {
+	kdfParams     *KDFParams
+	filename      *string
+	hint          *string
+	comment       *string
+	hashAlgorithm *constants.HashAlgorithm
+	flags         *constants.HeaderFlags
+	thumbnail     []byte
+	merkleRoot    []byte
+	original      []byte
+	cipher        *constants.CipherAlgorithm
+	notBefore     *time.Time
+	aadHash       []byte
+	compression   *constants.CompressionFormat
+	recoveryCode  []byte
+	keySlots      []keySlotInput
+	compressMeta  bool
+	random        io.Reader
+	nonceID       []byte
+	nonceCounter  uint64
+	paddedSize    *uint64
+}
+
+// WithKDFParams records the Argon2id parameters the key was derived with
+func WithKDFParams(params KDFParams) HeaderOption {
+	return func(b *headerBuilder) error {
+		if b.kdfParams != nil {
+			return fmt.Errorf("%w: kdf parameters set more than once", constants.ErrInvalidOption)
+		}
+		if err := params.Validate(); err != nil {
+			return err
+		}
+		b.kdfParams = &params
+		return nil
+	}
+}
+
+// WithFilename stores the original filename, encrypted under the header key
+func WithFilename(name string) HeaderOption {
+	return func(b *headerBuilder) error {
+		if b.filename != nil {
+			return fmt.Errorf("%w: filename set more than once", constants.ErrInvalidOption)
+		}
+		if name == "" {
+			return fmt.Errorf("%w: filename cannot be empty", constants.ErrInvalidOption)
+		}
+		if len(name) > constants.MaxFilenameLength {
+			return fmt.Errorf("%w: filename exceeds %d bytes", constants.ErrInvalidOption, constants.MaxFilenameLength)
+		}
+		b.filename = &name
+		return nil
+	}
+}
+
+// WithHint stores a public password hint; it is authenticated but readable without the key
+func WithHint(hint string) HeaderOption {
+	return func(b *headerBuilder) error {
+		if b.hint != nil {
+			return fmt.Errorf("%w: hint set more than once", constants.ErrInvalidOption)
+		}
+		if hint == "" {
+			return fmt.Errorf("%w: hint cannot be empty", constants.ErrInvalidOption)
+		}
+		if len(hint) > constants.MaxHintLength {
+			return fmt.Errorf("%w: hint exceeds %d bytes", constants.ErrInvalidOption, constants.MaxHintLength)
+		}
+		if !utf8.ValidString(hint) {
+			return fmt.Errorf("%w: hint must be valid UTF-8", constants.ErrInvalidOption)
+		}
+		b.hint = &hint
+		return nil
+	}
+}
+
+// WithComment stores a free-text comment, encrypted and authenticated under the header key
+func WithComment(comment string) HeaderOption {
+	return func(b *headerBuilder) error {
+		if b.comment != nil {
+			return fmt.Errorf("%w: comment set more than once", constants.ErrInvalidOption)
+		}
+		if comment == "" {
+			return fmt.Errorf("%w: comment cannot be empty", constants.ErrInvalidOption)
+		}
+		if len(comment) > constants.MaxCommentLength {
+			return fmt.Errorf("%w: comment exceeds %d bytes", constants.ErrInvalidOption, constants.MaxCommentLength)
+		}
+		if !utf8.ValidString(comment) {
+			return fmt.Errorf("%w: comment must be valid UTF-8", constants.ErrInvalidOption)
+		}
+		b.comment = &comment
+		return nil
+	}
+}
+
+// WithHashAlgorithm selects the algorithm behind the integrity hash and authentication tag.
+// SHA-256 is the default and keeps the fixed header layout; others are recorded in the metadata block
+func WithHashAlgorithm(alg constants.HashAlgorithm) HeaderOption {
+	return func(b *headerBuilder) error {
+		if b.hashAlgorithm != nil {
+			return fmt.Errorf("%w: hash algorithm set more than once", constants.ErrInvalidOption)
+		}
+		if _, err := hashConstructor(alg); err != nil {
+			return fmt.Errorf("%w: %v", constants.ErrInvalidOption, err)
+		}
+		b.hashAlgorithm = &alg
+		return nil
+	}
+}
+
+// WithCipher records the cipher the body is encrypted with. AES-GCM is the
+// default and is not recorded; CipherAuto must be resolved with SelectCipher first
+func WithCipher(alg constants.CipherAlgorithm) HeaderOption {
+	return func(b *headerBuilder) error {
+		if b.cipher != nil {
+			return fmt.Errorf("%w: cipher set more than once", constants.ErrInvalidOption)
+		}
+		if !isConcreteCipher(alg) {
+			return fmt.Errorf("%w: unsupported cipher %s", constants.ErrInvalidOption, alg)
+		}
+		b.cipher = &alg
+		return nil
+	}
+}
+
+// WithCompression records the format the chunks are compressed with. Gzip is
+// the default and is not recorded
+func WithCompression(format constants.CompressionFormat) HeaderOption {
+	return func(b *headerBuilder) error {
+		if b.compression != nil {
+			return fmt.Errorf("%w: compression set more than once", constants.ErrInvalidOption)
+		}
+		if !isKnownCompression(format) {
+			return fmt.Errorf("%w: unsupported compression %s", constants.ErrInvalidOption, format)
+		}
+		b.compression = &format
+		return nil
+	}
+}
+
+// isKnownCompression reports whether format is a compression format chunks can be stored in
+func isKnownCompression(format constants.CompressionFormat) bool {
+	return format == constants.CompressionGzip || format == constants.CompressionRawDeflate || format == constants.CompressionStored
+}
+
+// WithNotBefore records a time before which decryption should be refused. It is
+// authenticated but readable without the key, and only enforced by the client.
+// It is stored as whole seconds since the Unix epoch, so its zone is not kept
+func WithNotBefore(t time.Time) HeaderOption {
+	return func(b *headerBuilder) error {
+		if b.notBefore != nil {
+			return fmt.Errorf("%w: not-before time set more than once", constants.ErrInvalidOption)
+		}
+		if t.IsZero() {
+			return fmt.Errorf("%w: not-before time cannot be zero", constants.ErrInvalidOption)
+		}
+		b.notBefore = &t
+		return nil
+	}
+}
+
+// WithPaddedOutput records that the file is filled up to size bytes with output
+// padding after its chunks. It is authenticated but readable without the key
+func WithPaddedOutput(size uint64) HeaderOption {
+	return func(b *headerBuilder) error {
+		if b.paddedSize != nil {
+			return fmt.Errorf("%w: padded size set more than once", constants.ErrInvalidOption)
+		}
+		if size == 0 {
+			return fmt.Errorf("%w: padded size cannot be zero", constants.ErrInvalidOption)
+		}
+		b.paddedSize = &size
+		return nil
+	}
+}
+
+// WithAAD records the SHA-256 of the associated data the body is bound to, so
+// decryption can tell a wrong context apart from a damaged body
+func WithAAD(aad []byte) HeaderOption {
+	return func(b *headerBuilder) error {
+		if b.aadHash != nil {
+			return fmt.Errorf("%w: associated data set more than once", constants.ErrInvalidOption)
+		}
+		if len(aad) == 0 {
+			return fmt.Errorf("%w: associated data cannot be empty", constants.ErrInvalidOption)
+		}
+		sum := sha256.Sum256(aad)
+		b.aadHash = sum[:]
+		return nil
+	}
+}
+
+// WithThumbnail stores a JPEG preview, encrypted under the header key
+func WithThumbnail(jpeg []byte) HeaderOption {
+	return func(b *headerBuilder) error {
+		if b.thumbnail != nil {
+			return fmt.Errorf("%w: thumbnail set more than once", constants.ErrInvalidOption)
+		}
+		if len(jpeg) == 0 {
+			return fmt.Errorf("%w: thumbnail cannot be empty", constants.ErrInvalidOption)
+		}
+		if len(jpeg) > constants.MaxThumbnailSize {
+			return fmt.Errorf("%w: thumbnail exceeds %d bytes", constants.ErrInvalidOption, constants.MaxThumbnailSize)
+		}
+		b.thumbnail = jpeg
+		return nil
+	}
+}
+
+// WithMerkleRoot stores the root of the Merkle tree over the encrypted chunks.
+// It is authenticated by the header but not encrypted
+func WithMerkleRoot(root []byte) HeaderOption {
+	return func(b *headerBuilder) error {
+		if b.merkleRoot != nil {
+			return fmt.Errorf("%w: Merkle root set more than once", constants.ErrInvalidOption)
+		}
+		if len(root) != constants.MerkleHashSize {
+			return fmt.Errorf("%w: Merkle root must be %d bytes", constants.ErrInvalidOption, constants.MerkleHashSize)
+		}
+		b.merkleRoot = append([]byte(nil), root...)
+		return nil
+	}
+}
+
+// WithOriginalSHA256 stores the SHA-256 of the original file, encrypted under
+// the header key
+func WithOriginalSHA256(digest []byte) HeaderOption {
+	return func(b *headerBuilder) error {
+		if b.original != nil {
+			return fmt.Errorf("%w: original SHA-256 set more than once", constants.ErrInvalidOption)
+		}
+		if len(digest) != sha256.Size {
+			return fmt.Errorf("%w: original SHA-256 must be %d bytes", constants.ErrInvalidOption, sha256.Size)
+		}
+		b.original = append([]byte(nil), digest...)
+		return nil
+	}
+}
+
+// WithMetadataCompression stores the metadata block deflate-compressed once it
+// reaches MetadataCompressionThreshold bytes and compressing makes it smaller.
+// Sealed fields and key slots hardly compress, so it mostly pays off for long
+// hints. The compressed size depends on the content, so a header written with
+// it cannot be rewritten in place with fields of the same length
+func WithMetadataCompression() HeaderOption {
+	return func(b *headerBuilder) error {
+		b.compressMeta = true
+		return nil
+	}
+}
+
+// WithRandom draws the header nonce from random instead of crypto/rand,
+// for hardware RNGs or reproducible tests
+func WithRandom(random io.Reader) HeaderOption {
+	return func(b *headerBuilder) error {
+		if b.random != nil {
+			return fmt.Errorf("%w: random source set more than once", constants.ErrInvalidOption)
+		}
+		if random == nil {
+			return fmt.Errorf("%w: random source cannot be nil", constants.ErrInvalidOption)
+		}
+		b.random = random
+		return nil
+	}
+}
+
+// WithFlags records how the body following the header was written. Flags
+// given by several options are combined
+func WithFlags(flags constants.HeaderFlags) HeaderOption {
+	return func(b *headerBuilder) error {
+		if flags&^constants.KnownHeaderFlags != 0 {
+			return fmt.Errorf("%w: unknown flags %#x", constants.ErrInvalidOption, byte(flags))
+		}
+		if b.flags != nil {
+			flags |= *b.flags
+		}
+		b.flags = &flags
+		return nil
+	}
+}
+
+// Build creates a header from the required fields and any number of options.
+// Without options the result is identical in format to NewHeader
+func Build(salt []byte, originalSize uint64, key []byte, opts ...HeaderOption) (*Header, error) {
+	if err := validateHeaderInputs(salt, key); err != nil {
+		return nil, err
+	}
+
+	b, err := applyOptions(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	meta, err := b.metadata(key)
+	if err != nil {
+		return nil, err
+	}
+
+	random := b.random
+	if b.nonceID != nil {
+		random = bytes.NewReader(counterNonce(b.nonceID, b.nonceCounter, nonceSize(formatVersion(meta))))
+	}
+	if random == nil {
+		if random, err = systemRandom(); err != nil {
+			return nil, fmt.Errorf("failed to generate nonce: %w", err)
+		}
+	}
+	return newHeader(salt, originalSize, key, meta, random)
+}
+
+// ValidateOptions checks header options without building a header,
+// so callers can reject bad input before doing expensive work
+func ValidateOptions(opts ...HeaderOption) error {
+	_, err := applyOptions(opts)
+	return err
+}
+
+// applyOptions collects the options into a builder and validates the result
+func applyOptions(opts []HeaderOption) (*headerBuilder, error) {
+	b := &headerBuilder{}
+	for _, opt := range opts {
+		if err := opt(b); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := b.validate(); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// validate checks combinations of options that are individually valid
+func (b *headerBuilder) validate() error {
+	// The filename is stored encrypted, so a public hint must not reveal it
+	if b.filename != nil && b.hint != nil && strings.Contains(*b.hint, *b.filename) {
+		return fmt.Errorf("%w: hint must not contain the encrypted filename", constants.ErrInvalidOption)
+	}
+	if b.random != nil && b.nonceID != nil {
+		return fmt.Errorf("%w: a nonce counter cannot be combined with a random source", constants.ErrInvalidOption)
+	}
+	return nil
+}
+
+// metadata converts the collected options into the header metadata block
+func (b *headerBuilder) metadata(key []byte) (*metadata, error) {
+	meta := &metadata{kdfParams: b.kdfParams, compress: b.compressMeta}
+
+	// SHA-256 is implied when no algorithm is recorded
+	if b.hashAlgorithm != nil && *b.hashAlgorithm != constants.HashSHA256 {
+		meta.hashAlgorithm = b.hashAlgorithm
+	}
+	if b.cipher != nil && *b.cipher != constants.CipherAESGCM {
+		meta.cipher = b.cipher
+	}
+	if b.compression != nil && *b.compression != constants.CompressionGzip {
+		meta.compression = b.compression
+	}
+	if b.flags != nil {
+		meta.flags = *b.flags
+	}
+	if b.notBefore != nil {
+		unix := b.notBefore.Unix()
+		meta.notBefore = &unix
+	}
+	meta.paddedSize = b.paddedSize
+
+	if b.filename != nil {
+		sealed, err := sealMetadataField(key, []byte(*b.filename))
+		if err != nil {
+			return nil, fmt.Errorf("failed to encrypt filename: %w", err)
+		}
+		meta.filename = sealed
+	}
+	if b.hint != nil {
+		meta.hint = *b.hint
+	}
+	meta.merkleRoot = b.merkleRoot
+	meta.nonceID = b.nonceID
+	meta.aadHash = b.aadHash
+	if b.comment != nil {
+		sealed, err := sealMetadataField(key, []byte(*b.comment))
+		if err != nil {
+			return nil, fmt.Errorf("failed to encrypt comment: %w", err)
+		}
+		meta.comment = sealed
+	}
+	if b.thumbnail != nil {
+		sealed, err := sealMetadataField(key, b.thumbnail)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encrypt thumbnail: %w", err)
+		}
+		meta.thumbnail = sealed
+	}
+	if b.original != nil {
+		sealed, err := sealMetadataField(key, b.original)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encrypt original SHA-256: %w", err)
+		}
+		meta.original = sealed
+	}
+	if b.recoveryCode != nil {
+		wrapped, err := wrapRecoveryKey(b.recoveryCode, key)
+		if err != nil {
+			return nil, fmt.Errorf("failed to wrap key under recovery code: %w", err)
+		}
+		meta.recoveryKey = wrapped
+	}
+	for _, input := range b.keySlots {
+		slot, err := wrapKeySlot(input.salt, input.kek, key)
+		if err != nil {
+			return nil, fmt.Errorf("failed to wrap key in key slot: %w", err)
+		}
+		meta.keySlots = append(meta.keySlots, slot...)
+	}
+
+	return meta, nil
+}
@@ -0,0 +1,79 @@
+package crypto
+
+import (
+	"crypto/cipher"
+
+	"golang.org/x/crypto/chacha20poly1305"
+
+	"github.com/hambosto/hexwarden/internal/constants"
+)
+
+// ChaCha20Cipher provides ChaCha20-Poly1305 or XChaCha20-Poly1305 encryption and decryption
+type ChaCha20Cipher struct {
+	aead cipher.AEAD
+}
+
+// NewChaCha20Cipher creates a new ChaCha20-Poly1305 cipher with the given 32-byte key
+func NewChaCha20Cipher(key []byte) (*ChaCha20Cipher, error) {
+	if len(key) != chacha20poly1305.KeySize {
+		return nil, constants.ErrInvalidKeySize
+	}
+
+	aead, err := chacha20poly1305.New(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ChaCha20Cipher{aead: aead}, nil
+}
+
+// NewXChaCha20Cipher creates a new XChaCha20-Poly1305 cipher with the given 32-byte key.
+// Its 24-byte nonces are random, so they stay unique without any counter
+func NewXChaCha20Cipher(key []byte) (*ChaCha20Cipher, error) {
+	if len(key) != chacha20poly1305.KeySize {
+		return nil, constants.ErrInvalidKeySize
+	}
+
+	aead, err := chacha20poly1305.NewX(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ChaCha20Cipher{aead: aead}, nil
+}
+
+// Encrypt encrypts the plaintext and returns the ciphertext with nonce prepended
+func (c *ChaCha20Cipher) Encrypt(plaintext []byte) ([]byte, error) {
+	return seal(c.aead, plaintext, nil)
+}
+
+// EncryptWithAAD encrypts the plaintext bound to aad, like AESCipher.EncryptWithAAD
+func (c *ChaCha20Cipher) EncryptWithAAD(plaintext, aad []byte) ([]byte, error) {
+	return seal(c.aead, plaintext, aad)
+}
+
+// EncryptWithNonce encrypts the plaintext bound to aad under nonce, like AESCipher.EncryptWithNonce
+func (c *ChaCha20Cipher) EncryptWithNonce(nonce, plaintext, aad []byte) ([]byte, error) {
+	return sealWithNonce(c.aead, nonce, plaintext, aad)
+}
+
+// Decrypt decrypts the ciphertext (which should have nonce prepended) and returns
+// the plaintext, failing like AESCipher.Decrypt
+func (c *ChaCha20Cipher) Decrypt(ciphertext []byte) ([]byte, error) {
+	return open(c.aead, ciphertext, nil)
+}
+
+// DecryptWithAAD decrypts a ciphertext from EncryptWithAAD with the same aad
+func (c *ChaCha20Cipher) DecryptWithAAD(ciphertext, aad []byte) ([]byte, error) {
+	return open(c.aead, ciphertext, aad)
+}
+
+// NonceSize returns the size of the nonce prepended to every ciphertext
+func (c *ChaCha20Cipher) NonceSize() int {
+	return c.aead.NonceSize()
+}
+
+// Overhead returns the bytes Encrypt adds to the plaintext: the nonce and the tag
+func (c *ChaCha20Cipher) Overhead() int {
+	return c.aead.NonceSize() + c.aead.Overhead()
+}
@@ -0,0 +1,89 @@
+package crypto
+
+import (
+	"fmt"
+	"runtime"
+	"strings"
+
+	"golang.org/x/sys/cpu"
+
+	"github.com/hambosto/hexwarden/internal/constants"
+)
+
+// Cipher encrypts and decrypts chunks with an AEAD, prepending the nonce to the
+// ciphertext. The WithAAD variants bind each message to associated data, which
+// must be given again to decrypt it. EncryptWithNonce seals under a nonce the
+// caller chooses and must never repeat with the same key
+type Cipher interface {
+	Encrypt(plaintext []byte) ([]byte, error)
+	Decrypt(ciphertext []byte) ([]byte, error)
+	EncryptWithAAD(plaintext, aad []byte) ([]byte, error)
+	EncryptWithNonce(nonce, plaintext, aad []byte) ([]byte, error)
+	DecryptWithAAD(ciphertext, aad []byte) ([]byte, error)
+	NonceSize() int
+	Overhead() int
+}
+
+// NewCipher creates the cipher for alg with the given 32-byte key
+func NewCipher(alg constants.CipherAlgorithm, key []byte) (Cipher, error) {
+	switch alg {
+	case constants.CipherAESGCM:
+		return NewAESCipher(key)
+	case constants.CipherChaCha20Poly1305:
+		return NewChaCha20Cipher(key)
+	case constants.CipherXChaCha20Poly1305:
+		return NewXChaCha20Cipher(key)
+	default:
+		return nil, fmt.Errorf("unsupported cipher %d", alg)
+	}
+}
+
+// isConcreteCipher reports whether alg names a cipher that can be stored in a header
+func isConcreteCipher(alg constants.CipherAlgorithm) bool {
+	return alg == constants.CipherAESGCM || alg == constants.CipherChaCha20Poly1305 || alg == constants.CipherXChaCha20Poly1305
+}
+
+// ParseCipher converts a user-supplied name such as "aes-gcm", "chacha20", "xchacha20" or "auto" into a cipher
+func ParseCipher(name string) (constants.CipherAlgorithm, error) {
+	switch strings.ToLower(strings.ReplaceAll(name, "-", "")) {
+	case "auto":
+		return constants.CipherAuto, nil
+	case "aes", "aesgcm", "aes256gcm":
+		return constants.CipherAESGCM, nil
+	case "chacha20", "chacha20poly1305":
+		return constants.CipherChaCha20Poly1305, nil
+	case "xchacha20", "xchacha20poly1305":
+		return constants.CipherXChaCha20Poly1305, nil
+	default:
+		return 0, fmt.Errorf("%w: unknown cipher %q", constants.ErrInvalidOption, name)
+	}
+}
+
+// SelectCipher resolves CipherAuto to AES-GCM when hasAES reports hardware AES
+// support and to ChaCha20-Poly1305 otherwise. Other ciphers are returned as is
+func SelectCipher(alg constants.CipherAlgorithm, hasAES func() bool) constants.CipherAlgorithm {
+	if alg != constants.CipherAuto {
+		return alg
+	}
+	if hasAES() {
+		return constants.CipherAESGCM
+	}
+	return constants.CipherChaCha20Poly1305
+}
+
+// HasAESHardware reports whether the CPU has the instructions Go's constant-time
+// AES-GCM implementation needs; without them ChaCha20-Poly1305 is much faster
+func HasAESHardware() bool {
+	switch runtime.GOARCH {
+	case "amd64", "386":
+		return cpu.X86.HasAES && cpu.X86.HasPCLMULQDQ
+	case "arm64":
+		return cpu.ARM64.HasAES && cpu.ARM64.HasPMULL
+	case "s390x":
+		return cpu.S390X.HasAES && cpu.S390X.HasAESCTR && cpu.S390X.HasGHASH
+	case "ppc64", "ppc64le":
+		return true
+	default:
+		return false
+	}
+}
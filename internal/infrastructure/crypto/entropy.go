@@ -0,0 +1,85 @@
+package crypto
+
+import (
+	"crypto/rand"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/hambosto/hexwarden/internal/constants"
+)
+
+// systemCheck runs the entropy self-check on crypto/rand once per process
+var systemCheck = sync.OnceValue(func() error {
+	return CheckEntropy(rand.Reader, constants.EntropyCheckTimeout)
+})
+
+// systemRandom returns crypto/rand once it has passed the entropy self-check.
+// Every salt and nonce drawn from the system goes through it, so a broken
+// source fails the first one instead of producing predictable files
+func systemRandom() (io.Reader, error) {
+	if err := systemCheck(); err != nil {
+		return nil, err
+	}
+	return rand.Reader, nil
+}
+
+// CheckEntropy reads a few salt-sized blocks from random and fails with
+// ErrEntropyCheck when the source blocks for longer than timeout, returns
+// short reads, or its output looks broken: a block rejected by the weak-salt
+// heuristics, two reads returning the same bytes, or too few distinct byte
+// values overall. It catches sources that are stuck or unseeded, not subtle bias
+func CheckEntropy(random io.Reader, timeout time.Duration) error {
+	type result struct {
+		blocks [][]byte
+		err    error
+	}
+
+	done := make(chan result, 1)
+	go func() {
+		blocks := make([][]byte, constants.EntropyCheckReads)
+		for i := range blocks {
+			blocks[i] = make([]byte, constants.SaltSize)
+			if _, err := io.ReadFull(random, blocks[i]); err != nil {
+				done <- result{err: err}
+				return
+			}
+		}
+		done <- result{blocks: blocks}
+	}()
+
+	var res result
+	select {
+	case res = <-done:
+	case <-time.After(timeout):
+		return fmt.Errorf("%w: no data after %s", constants.ErrEntropyCheck, timeout)
+	}
+	if res.err != nil {
+		return fmt.Errorf("%w: %v", constants.ErrEntropyCheck, res.err)
+	}
+
+	seen := make(map[string]bool, len(res.blocks))
+	var values [256]bool
+	distinct := 0
+	for i, block := range res.blocks {
+		if isWeakSalt(block) {
+			return fmt.Errorf("%w: %w: read %d is patterned", constants.ErrEntropyCheck, constants.ErrWeakRandom, i)
+		}
+		if seen[string(block)] {
+			return fmt.Errorf("%w: %w: read %d repeats an earlier read", constants.ErrEntropyCheck, constants.ErrWeakRandom, i)
+		}
+		seen[string(block)] = true
+
+		for _, b := range block {
+			if !values[b] {
+				values[b] = true
+				distinct++
+			}
+		}
+	}
+	if distinct < constants.EntropyMinDistinct {
+		return fmt.Errorf("%w: %w: only %d distinct byte values", constants.ErrEntropyCheck, constants.ErrWeakRandom, distinct)
+	}
+	return nil
+}
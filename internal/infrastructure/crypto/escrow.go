@@ -0,0 +1,132 @@
+package crypto
+
+import (
+	"bytes"
+	"crypto/ecdh"
+	"crypto/hkdf"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+
+	"github.com/hambosto/hexwarden/internal/constants"
+)
+
+// escrowKeyLabel separates the key wrapping an escrowed file key from anything
+// else derived from an X25519 shared secret
+const escrowKeyLabel = "hexwarden/escrow-key"
+
+// escrowSize is the length of an escrowed key: the magic, the salt of the file
+// it belongs to, the ephemeral public key, then the file key sealed with AES-GCM
+const escrowSize = len(constants.EscrowMagic) + constants.SaltSize + constants.EscrowKeySize + 12 + constants.KeySize + 16
+
+// ParseEscrowPublicKey reads the X25519 public key of an escrow holder from a
+// PEM "PUBLIC KEY" block, as written by openssl pkey -pubout
+func ParseEscrowPublicKey(data []byte) (*ecdh.PublicKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil || block.Type != "PUBLIC KEY" {
+		return nil, fmt.Errorf("%w: expected a PEM PUBLIC KEY block", constants.ErrEscrowKey)
+	}
+	parsed, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", constants.ErrEscrowKey, err)
+	}
+	public, ok := parsed.(*ecdh.PublicKey)
+	if !ok || public.Curve() != ecdh.X25519() {
+		return nil, fmt.Errorf("%w: escrow keys must be X25519", constants.ErrEscrowKey)
+	}
+	return public, nil
+}
+
+// ParseEscrowPrivateKey reads the X25519 private key of an escrow holder from
+// a PEM "PRIVATE KEY" block, as written by openssl genpkey -algorithm X25519
+func ParseEscrowPrivateKey(data []byte) (*ecdh.PrivateKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil || block.Type != "PRIVATE KEY" {
+		return nil, fmt.Errorf("%w: expected a PEM PRIVATE KEY block", constants.ErrEscrowKey)
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", constants.ErrEscrowKey, err)
+	}
+	private, ok := parsed.(*ecdh.PrivateKey)
+	if !ok || private.Curve() != ecdh.X25519() {
+		return nil, fmt.Errorf("%w: escrow keys must be X25519", constants.ErrEscrowKey)
+	}
+	return private, nil
+}
+
+// escrowCipher returns the cipher wrapping the file key under the X25519 shared
+// secret, bound to both public keys so the blob cannot be re-addressed
+func escrowCipher(shared, ephemeral, recipient []byte) (*AESCipher, error) {
+	salt := append(append([]byte(nil), ephemeral...), recipient...)
+	kek, err := hkdf.Key(sha256.New, shared, salt, escrowKeyLabel, constants.KeySize)
+	if err != nil {
+		return nil, fmt.Errorf("failed to derive escrow key: %w", err)
+	}
+	return NewAESCipher(kek)
+}
+
+// EscrowKey wraps key, the file key of the file encrypted with salt, to the
+// escrow holder's public key with a fresh ephemeral X25519 key. Only the holder
+// of the matching private key can open the result with OpenEscrowKey
+func EscrowKey(recipient *ecdh.PublicKey, salt, key []byte) ([]byte, error) {
+	if len(salt) != constants.SaltSize || len(key) != constants.KeySize {
+		return nil, constants.ErrInvalidKey
+	}
+	random, err := systemRandom()
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate escrow key: %w", err)
+	}
+	ephemeral, err := ecdh.X25519().GenerateKey(random)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate escrow key: %w", err)
+	}
+	shared, err := ephemeral.ECDH(recipient)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", constants.ErrEscrowKey, err)
+	}
+
+	ephemeralPublic := ephemeral.PublicKey().Bytes()
+	cipher, err := escrowCipher(shared, ephemeralPublic, recipient.Bytes())
+	if err != nil {
+		return nil, err
+	}
+	prefix := append(append([]byte(constants.EscrowMagic), salt...), ephemeralPublic...)
+	sealed, err := cipher.EncryptWithAAD(key, prefix)
+	if err != nil {
+		return nil, err
+	}
+	return append(prefix, sealed...), nil
+}
+
+// OpenEscrowKey unwraps an escrowed key written by EscrowKey with the escrow
+// holder's private key, returning the salt of the file it belongs to and the
+// file key. It fails with ErrEscrowKey when the blob is malformed or private
+// does not open it
+func OpenEscrowKey(private *ecdh.PrivateKey, escrowed []byte) ([]byte, []byte, error) {
+	if len(escrowed) != escrowSize || !bytes.HasPrefix(escrowed, []byte(constants.EscrowMagic)) {
+		return nil, nil, fmt.Errorf("%w: not an escrowed file key", constants.ErrEscrowKey)
+	}
+	prefixSize := len(constants.EscrowMagic) + constants.SaltSize + constants.EscrowKeySize
+	salt := escrowed[len(constants.EscrowMagic) : len(constants.EscrowMagic)+constants.SaltSize]
+	ephemeralPublic := escrowed[len(constants.EscrowMagic)+constants.SaltSize : prefixSize]
+
+	ephemeral, err := ecdh.X25519().NewPublicKey(ephemeralPublic)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w: %v", constants.ErrEscrowKey, err)
+	}
+	shared, err := private.ECDH(ephemeral)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w: %v", constants.ErrEscrowKey, err)
+	}
+	cipher, err := escrowCipher(shared, ephemeralPublic, private.PublicKey().Bytes())
+	if err != nil {
+		return nil, nil, err
+	}
+	key, err := cipher.DecryptWithAAD(escrowed[prefixSize:], escrowed[:prefixSize])
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w: private key does not open the escrowed key", constants.ErrEscrowKey)
+	}
+	return bytes.Clone(salt), key, nil
+}
@@ -0,0 +1,45 @@
+package crypto
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"hash"
+	"strings"
+
+	"golang.org/x/crypto/blake2b"
+
+	"github.com/hambosto/hexwarden/internal/constants"
+)
+
+// hashConstructor returns the constructor for the given header hash algorithm
+func hashConstructor(alg constants.HashAlgorithm) (func() hash.Hash, error) {
+	switch alg {
+	case constants.HashSHA256:
+		return sha256.New, nil
+	case constants.HashBLAKE2b:
+		return newBLAKE2b256, nil
+	default:
+		return nil, fmt.Errorf("unsupported hash algorithm %d", alg)
+	}
+}
+
+// newBLAKE2b256 returns an unkeyed BLAKE2b-256 hash
+func newBLAKE2b256() hash.Hash {
+	h, err := blake2b.New256(nil)
+	if err != nil {
+		panic(err) // Only fails for keys longer than 64 bytes
+	}
+	return h
+}
+
+// ParseHashAlgorithm converts a user-supplied name such as "sha256" or "blake2b" into an algorithm
+func ParseHashAlgorithm(name string) (constants.HashAlgorithm, error) {
+	switch strings.ToLower(strings.ReplaceAll(name, "-", "")) {
+	case "sha256":
+		return constants.HashSHA256, nil
+	case "blake2b", "blake2b256":
+		return constants.HashBLAKE2b, nil
+	default:
+		return 0, fmt.Errorf("%w: unknown hash algorithm %q", constants.ErrInvalidOption, name)
+	}
+}
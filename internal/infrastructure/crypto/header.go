@@ -3,41 +3,65 @@ package crypto
 import (
 	"bytes"
 	"crypto/hmac"
-	"crypto/rand"
 	"crypto/sha256"
 	"crypto/subtle"
 	"encoding/binary"
 	"fmt"
+	"hash"
 	"hash/crc32"
 	"io"
+	"slices"
+	"time"
 
 	"github.com/hambosto/hexwarden/internal/constants"
 )
 
 // Header represents the metadata prepended to an encrypted file with tamper protection
 type Header struct {
-	salt          []byte // 32 bytes: cryptographically random salt for KDF
-	originalSize  uint64 // 8 bytes: size of original plaintext
-	nonce         []byte // 16 bytes: nonce for AEAD
-	integrityHash []byte // 32 bytes: SHA-256 hash over [Magic, Salt, Size, Nonce]
-	authTag       []byte // 32 bytes: HMAC-SHA256 over [Magic, Salt, Size, Nonce, IntegrityHash] with key
+	version       uint8     // Format version, selected by the magic bytes
+	salt          []byte    // 32 bytes: cryptographically random salt for KDF
+	originalSize  uint64    // 8 bytes: size of original plaintext
+	meta          *metadata // Variable: optional fields (format version 3 only)
+	nonce         []byte    // 16 bytes: nonce for AEAD
+	integrityHash []byte    // 32 bytes: hash over all preceding fields (SHA-256 unless the metadata selects another)
+	authTag       []byte    // 32 bytes: HMAC over all preceding fields and the integrity hash with key
 }
 
 // NewHeader creates a new, fully-hardened header
 func NewHeader(salt []byte, originalSize uint64, key []byte) (*Header, error) {
+	random, err := systemRandom()
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+	return NewHeaderWithRandom(salt, originalSize, key, random)
+}
+
+// NewHeaderWithRandom creates a header like NewHeader, drawing its nonce from random
+func NewHeaderWithRandom(salt []byte, originalSize uint64, key []byte, random io.Reader) (*Header, error) {
+	return newHeader(salt, originalSize, key, &metadata{}, random)
+}
+
+// newHeader creates a header carrying the given metadata, selecting the most
+// compact format version able to represent it
+func newHeader(salt []byte, originalSize uint64, key []byte, meta *metadata, random io.Reader) (*Header, error) {
 	if err := validateHeaderInputs(salt, key); err != nil {
 		return nil, err
 	}
 
-	// Generate cryptographically random nonce
-	nonce := make([]byte, constants.NonceSizeBytes)
-	if _, err := rand.Read(nonce); err != nil {
+	version := formatVersion(meta)
+	nonce := make([]byte, nonceSize(version))
+	if _, err := io.ReadFull(random, nonce); err != nil {
 		return nil, fmt.Errorf("failed to generate nonce: %w", err)
 	}
+	if isWeakRandom(nonce) {
+		return nil, fmt.Errorf("failed to generate nonce: %w", constants.ErrWeakRandom)
+	}
 
 	header := &Header{
+		version:      version,
 		salt:         append([]byte(nil), salt...), // Defensive copy
 		originalSize: originalSize,
+		meta:         meta,
 		nonce:        nonce,
 	}
 
@@ -49,6 +73,11 @@ func NewHeader(salt []byte, originalSize uint64, key []byte) (*Header, error) {
 	return header, nil
 }
 
+// Version returns the header format version
+func (h *Header) Version() uint8 {
+	return h.version
+}
+
 // Salt returns a copy of the header's salt
 func (h *Header) Salt() []byte {
 	return append([]byte(nil), h.salt...)
@@ -64,19 +93,305 @@ func (h *Header) Nonce() []byte {
 	return append([]byte(nil), h.nonce...)
 }
 
-// VerifyKey validates the key against the header, checking full cryptographic integrity and authentication
+// KDFParams returns the key derivation parameters recorded in the header,
+// or the defaults when none were recorded
+func (h *Header) KDFParams() KDFParams {
+	if h.meta.kdfParams == nil {
+		return DefaultKDFParams()
+	}
+	return *h.meta.kdfParams
+}
+
+// HasFilename reports whether the header stores an encrypted original filename
+func (h *Header) HasFilename() bool {
+	return len(h.meta.filename) > 0
+}
+
+// Filename decrypts and returns the stored original filename using the file key
+func (h *Header) Filename(key []byte) (string, error) {
+	if !h.HasFilename() {
+		return "", nil
+	}
+
+	name, err := openMetadataField(key, h.meta.filename)
+	if err != nil {
+		return "", fmt.Errorf("failed to decrypt filename: %w", err)
+	}
+	return string(name), nil
+}
+
+// SetFilename replaces the stored filename and reseals the header with key, which
+// must unlock it. An empty name removes the filename; salt and nonce are kept
+func (h *Header) SetFilename(key []byte, name string) error {
+	if err := h.VerifyKey(key); err != nil {
+		return err
+	}
+
+	meta := *h.meta
+	meta.filename = nil
+	if name != "" {
+		b := &headerBuilder{}
+		if meta.hint != "" {
+			b.hint = &meta.hint
+		}
+		if err := WithFilename(name)(b); err != nil {
+			return err
+		}
+		if err := b.validate(); err != nil {
+			return err
+		}
+
+		sealed, err := sealMetadataField(key, []byte(name))
+		if err != nil {
+			return fmt.Errorf("failed to encrypt filename: %w", err)
+		}
+		meta.filename = sealed
+	}
+
+	return h.reseal(key, &meta)
+}
+
+// StripMetadata removes the fields that can identify the file or its owner,
+// the filename, password hint, comment and thumbnail, and reseals the header
+// with key, which must unlock it. Fields the body depends on are kept. It
+// returns the names of the fields removed
+func (h *Header) StripMetadata(key []byte) ([]string, error) {
+	if err := h.VerifyKey(key); err != nil {
+		return nil, err
+	}
+
+	meta := *h.meta
+	var removed []string
+	if len(meta.filename) > 0 {
+		meta.filename = nil
+		removed = append(removed, "filename")
+	}
+	if meta.hint != "" {
+		meta.hint = ""
+		removed = append(removed, "hint")
+	}
+	if len(meta.comment) > 0 {
+		meta.comment = nil
+		removed = append(removed, "comment")
+	}
+	if len(meta.thumbnail) > 0 {
+		meta.thumbnail = nil
+		removed = append(removed, "thumbnail")
+	}
+
+	if err := h.reseal(key, &meta); err != nil {
+		return nil, err
+	}
+	return removed, nil
+}
+
+// Hint returns the public password hint, if any
+func (h *Header) Hint() string {
+	return h.meta.hint
+}
+
+// NotBefore returns the time before which decryption should be refused, or the
+// zero time when the file is not time-locked
+func (h *Header) NotBefore() time.Time {
+	if h.meta.notBefore == nil {
+		return time.Time{}
+	}
+	return time.Unix(*h.meta.notBefore, 0).UTC()
+}
+
+// PaddedSize returns the size the file was padded to, or false when its chunks
+// are not followed by output padding
+func (h *Header) PaddedSize() (uint64, bool) {
+	if h.meta.paddedSize == nil {
+		return 0, false
+	}
+	return *h.meta.paddedSize, true
+}
+
+// HasAAD reports whether the body is bound to associated data
+func (h *Header) HasAAD() bool {
+	return len(h.meta.aadHash) > 0
+}
+
+// CheckAAD checks aad against the associated data the body was bound to with
+// WithAAD, failing with ErrAADMismatch when they differ. A header bound to none
+// only accepts empty aad
+func (h *Header) CheckAAD(aad []byte) error {
+	if !h.HasAAD() {
+		if len(aad) > 0 {
+			return fmt.Errorf("%w: the file is not bound to any", constants.ErrAADMismatch)
+		}
+		return nil
+	}
+	if len(aad) == 0 {
+		return fmt.Errorf("%w: the file is bound to associated data, but none was given", constants.ErrAADMismatch)
+	}
+
+	sum := sha256.Sum256(aad)
+	if subtle.ConstantTimeCompare(sum[:], h.meta.aadHash) != 1 {
+		return constants.ErrAADMismatch
+	}
+	return nil
+}
+
+// HashAlgorithm returns the algorithm behind the integrity hash and authentication tag
+func (h *Header) HashAlgorithm() constants.HashAlgorithm {
+	return h.meta.headerHash()
+}
+
+// Cipher returns the cipher the body is encrypted with
+func (h *Header) Cipher() constants.CipherAlgorithm {
+	return h.meta.bodyCipher()
+}
+
+// Compression returns the format the chunks are compressed with
+func (h *Header) Compression() constants.CompressionFormat {
+	return h.meta.chunkCompression()
+}
+
+// SetCompression replaces the recorded chunk compression and reseals the header
+// with key, which must unlock it. Only the header changes; the chunks must be
+// rewritten in format to match
+func (h *Header) SetCompression(key []byte, format constants.CompressionFormat) error {
+	if err := h.VerifyKey(key); err != nil {
+		return err
+	}
+	if !isKnownCompression(format) {
+		return fmt.Errorf("%w: unsupported compression %s", constants.ErrInvalidOption, format)
+	}
+
+	meta := *h.meta
+	meta.compression = nil
+	if format != constants.CompressionGzip {
+		meta.compression = &format
+	}
+	return h.reseal(key, &meta)
+}
+
+// HasThumbnail reports whether the header stores an encrypted image preview
+func (h *Header) HasThumbnail() bool {
+	return len(h.meta.thumbnail) > 0
+}
+
+// Thumbnail decrypts and returns the stored JPEG preview using the file key
+func (h *Header) Thumbnail(key []byte) ([]byte, error) {
+	if !h.HasThumbnail() {
+		return nil, nil
+	}
+
+	thumbnail, err := openMetadataField(key, h.meta.thumbnail)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt thumbnail: %w", err)
+	}
+	return thumbnail, nil
+}
+
+// MerkleRoot returns the root of the Merkle tree over the chunks, or nil when none was stored
+func (h *Header) MerkleRoot() []byte {
+	if len(h.meta.merkleRoot) == 0 {
+		return nil
+	}
+	return append([]byte(nil), h.meta.merkleRoot...)
+}
+
+// SetMerkleRoot replaces the stored Merkle root and reseals the header with key,
+// which must unlock it. A header that already held a root keeps its size, so it
+// can be rewritten in place once the chunks it covers have been written
+func (h *Header) SetMerkleRoot(key, root []byte) error {
+	if err := h.VerifyKey(key); err != nil {
+		return err
+	}
+	if len(root) != constants.MerkleHashSize {
+		return fmt.Errorf("%w: Merkle root must be %d bytes", constants.ErrInvalidOption, constants.MerkleHashSize)
+	}
+
+	meta := *h.meta
+	meta.merkleRoot = append([]byte(nil), root...)
+	return h.reseal(key, &meta)
+}
+
+// HasOriginalSHA256 reports whether the header stores the encrypted SHA-256 of the original file
+func (h *Header) HasOriginalSHA256() bool {
+	return len(h.meta.original) > 0
+}
+
+// OriginalSHA256 decrypts and returns the stored SHA-256 of the original file
+// using the file key, or nil when none was stored
+func (h *Header) OriginalSHA256(key []byte) ([]byte, error) {
+	if !h.HasOriginalSHA256() {
+		return nil, nil
+	}
+
+	digest, err := openMetadataField(key, h.meta.original)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt original SHA-256: %w", err)
+	}
+	return digest, nil
+}
+
+// SetOriginalSHA256 replaces the stored SHA-256 of the original file and reseals
+// the header with key, which must unlock it. Like SetMerkleRoot, a header that
+// already held a digest keeps its size
+func (h *Header) SetOriginalSHA256(key, digest []byte) error {
+	if err := h.VerifyKey(key); err != nil {
+		return err
+	}
+	if len(digest) != sha256.Size {
+		return fmt.Errorf("%w: original SHA-256 must be %d bytes", constants.ErrInvalidOption, sha256.Size)
+	}
+
+	sealed, err := sealMetadataField(key, digest)
+	if err != nil {
+		return fmt.Errorf("failed to encrypt original SHA-256: %w", err)
+	}
+
+	meta := *h.meta
+	meta.original = sealed
+	return h.reseal(key, &meta)
+}
+
+// Flags returns the flags describing how the body was written
+func (h *Header) Flags() constants.HeaderFlags {
+	return h.meta.flags
+}
+
+// HasComment reports whether the header stores an encrypted comment
+func (h *Header) HasComment() bool {
+	return len(h.meta.comment) > 0
+}
+
+// Comment decrypts and returns the stored comment using the file key
+func (h *Header) Comment(key []byte) (string, error) {
+	if !h.HasComment() {
+		return "", nil
+	}
+
+	comment, err := openMetadataField(key, h.meta.comment)
+	if err != nil {
+		return "", fmt.Errorf("failed to decrypt comment: %w", err)
+	}
+	return string(comment), nil
+}
+
+// Size returns the serialized size of the header in bytes
+func (h *Header) Size() int {
+	return len(h.marshalCore(nil)) + constants.IntegritySize + constants.AuthSize + constants.ChecksumSize
+}
+
+// VerifyKey validates the key against the header, checking full cryptographic integrity and authentication.
+// Both checks always run and compare in constant time, so the result does not leak through timing
 func (h *Header) VerifyKey(key []byte) error {
 	if key == nil {
 		return fmt.Errorf("key cannot be nil")
 	}
 
-	expectedAuth := h.computeAuthTag(key)
-	if !hmac.Equal(h.authTag, expectedAuth) {
+	authValid := hmac.Equal(h.authTag, h.computeAuthTag(key))
+	integrityValid := subtle.ConstantTimeCompare(h.integrityHash, h.computeIntegrityHash()) == 1
+
+	if !authValid {
 		return constants.ErrAuthFailure
 	}
-
-	expectedIntegrity := h.computeIntegrityHash()
-	if !bytes.Equal(h.integrityHash, expectedIntegrity) {
+	if !integrityValid {
 		return constants.ErrIntegrityFailure
 	}
 
@@ -90,7 +405,7 @@ func (h *Header) WriteTo(w io.Writer) (int64, error) {
 	}
 
 	// Serialize header into a buffer
-	buf := make([]byte, 0, constants.TotalHeaderSize)
+	buf := make([]byte, 0, h.Size())
 	buf = h.marshal(buf)
 
 	n, err := w.Write(buf)
@@ -113,11 +428,33 @@ func (h *Header) Write(w io.Writer) error {
 // ReadHeader reads and parses a header from a reader
 func ReadHeader(r io.Reader) (*Header, error) {
 	buf := make([]byte, constants.TotalHeaderSize)
-	_, err := io.ReadFull(r, buf)
+	n, err := io.ReadFull(r, buf)
 	if err != nil {
+		// A short file that does not start with the magic is not ours at all,
+		// rather than a header cut off
+		if !hasMagicPrefix(buf[:n]) {
+			return nil, constants.ErrInvalidMagic
+		}
 		return nil, fmt.Errorf("%w: %v", constants.ErrIncompleteRead, err)
 	}
 
+	// Format version 3 and 4 headers are longer than the fixed header; read the rest
+	if version := headerVersion(buf); version >= constants.FormatVersion3 {
+		offset := len(constants.MagicBytesV3) + constants.SaltSizeBytes + constants.OriginalSizeBytes
+		metaLen := binary.BigEndian.Uint32(buf[offset : offset+constants.MetadataLenSize])
+		if metaLen > constants.MaxMetadataSize {
+			return nil, fmt.Errorf("%w: metadata block of %d bytes exceeds limit", constants.ErrInvalidHeader, metaLen)
+		}
+
+		total := offset + constants.MetadataLenSize + int(metaLen) + nonceSize(version) +
+			constants.IntegritySize + constants.AuthSize + constants.ChecksumSize
+		rest := make([]byte, total-len(buf))
+		if _, err := io.ReadFull(r, rest); err != nil {
+			return nil, fmt.Errorf("%w: %v", constants.ErrIncompleteRead, err)
+		}
+		buf = append(buf, rest...)
+	}
+
 	header, err := unmarshalHeader(buf)
 	if err != nil {
 		return nil, err
@@ -145,7 +482,7 @@ func (h *Header) validate() error {
 	if len(h.salt) != constants.SaltSizeBytes {
 		return fmt.Errorf("%w: got %d bytes", constants.ErrInvalidSalt, len(h.salt))
 	}
-	if len(h.nonce) != constants.NonceSizeBytes {
+	if len(h.nonce) != nonceSize(h.version) {
 		return fmt.Errorf("%w: got %d bytes", constants.ErrInvalidNonce, len(h.nonce))
 	}
 	if len(h.integrityHash) != constants.IntegritySize {
@@ -157,6 +494,75 @@ func (h *Header) validate() error {
 	return nil
 }
 
+// reseal replaces the metadata, choosing the format version able to hold it,
+// and recomputes the protection with key
+func (h *Header) reseal(key []byte, meta *metadata) error {
+	// The nonce size follows the version, so metadata cannot move a header between them
+	if nonceSize(formatVersion(meta)) != len(h.nonce) {
+		return fmt.Errorf("%w: metadata changes the header nonce size", constants.ErrInvalidHeader)
+	}
+	meta.packed = nil
+	h.meta = meta
+	h.version = formatVersion(meta)
+	return h.computeProtection(key)
+}
+
+// DefaultFormatVersion is the format version written when no option needs a later one
+const DefaultFormatVersion = constants.FormatVersion2
+
+// ReadableFormatVersions returns the format versions ReadHeader accepts, in ascending order
+func ReadableFormatVersions() []uint8 {
+	return []uint8{constants.FormatVersion2, constants.FormatVersion3, constants.FormatVersion4}
+}
+
+// formatVersion returns the most compact format version able to hold meta
+func formatVersion(meta *metadata) uint8 {
+	switch {
+	case meta.bodyCipher() == constants.CipherXChaCha20Poly1305:
+		return constants.FormatVersion4
+	case meta.isEmpty():
+		return DefaultFormatVersion
+	default:
+		return constants.FormatVersion3
+	}
+}
+
+// nonceSize returns the size of the header nonce in the given format version
+func nonceSize(version uint8) int {
+	if version == constants.FormatVersion4 {
+		return constants.ExtendedNonceSize
+	}
+	return constants.NonceSizeBytes
+}
+
+// headerVersion identifies the format version from the magic bytes at the start
+// of data, returning 0 when they are not recognized
+func headerVersion(data []byte) uint8 {
+	magic := data[:len(constants.MagicBytes)]
+	switch {
+	case subtle.ConstantTimeCompare(magic, []byte(constants.MagicBytes)) == 1:
+		return constants.FormatVersion2
+	case subtle.ConstantTimeCompare(magic, []byte(constants.MagicBytesV3)) == 1:
+		return constants.FormatVersion3
+	case subtle.ConstantTimeCompare(magic, []byte(constants.MagicBytesV4)) == 1:
+		return constants.FormatVersion4
+	default:
+		return 0
+	}
+}
+
+// hasMagicPrefix reports whether data, which may be shorter than the magic,
+// starts like the magic of a readable format version
+func hasMagicPrefix(data []byte) bool {
+	for _, magic := range []string{constants.MagicBytes, constants.MagicBytesV3, constants.MagicBytesV4} {
+		n := min(len(data), len(magic))
+		if bytes.Equal(data[:n], []byte(magic)[:n]) {
+			return true
+		}
+	}
+	return false
+}
+
 // computeProtection calculates both the integrity hash and authentication tag
 func (h *Header) computeProtection(key []byte) error {
 	h.integrityHash = h.computeIntegrityHash()
@@ -164,52 +570,72 @@ func (h *Header) computeProtection(key []byte) error {
 	return nil
 }
 
-// computeIntegrityHash returns the SHA-256 hash of the header's critical fields
-func (h *Header) computeIntegrityHash() []byte {
-	hasher := sha256.New()
-	hasher.Write([]byte(constants.MagicBytes))
-	hasher.Write(h.salt)
-
-	sizeBuf := make([]byte, constants.OriginalSizeBytes)
-	binary.BigEndian.PutUint64(sizeBuf, h.originalSize)
-	hasher.Write(sizeBuf)
-
-	hasher.Write(h.nonce)
+// newHash returns the hash constructor selected by the header. The metadata parser
+// and the builder only accept supported algorithms, so the lookup cannot fail
+func (h *Header) newHash() func() hash.Hash {
+	newHash, err := hashConstructor(h.HashAlgorithm())
+	if err != nil {
+		panic(err)
+	}
+	return newHash
+}
 
+// computeIntegrityHash returns the hash of the header's critical fields
+func (h *Header) computeIntegrityHash() []byte {
+	hasher := h.newHash()()
+	hasher.Write(h.marshalCore(nil))
 	return hasher.Sum(nil)
 }
 
-// computeAuthTag computes the HMAC-SHA256 authentication tag over the header fields and integrity hash
+// computeAuthTag computes the HMAC authentication tag over the header fields and integrity hash
 func (h *Header) computeAuthTag(key []byte) []byte {
-	mac := hmac.New(sha256.New, key)
-	mac.Write([]byte(constants.MagicBytes))
-	mac.Write(h.salt)
-
-	sizeBuf := make([]byte, constants.OriginalSizeBytes)
-	binary.BigEndian.PutUint64(sizeBuf, h.originalSize)
-	mac.Write(sizeBuf)
-
-	mac.Write(h.nonce)
+	mac := hmac.New(h.newHash(), key)
+	mac.Write(h.marshalCore(nil))
 	mac.Write(h.integrityHash)
-
 	return mac.Sum(nil)
 }
 
-// marshal serializes the header fields in order into the given buffer
-func (h *Header) marshal(buf []byte) []byte {
-	buf = append(buf, constants.MagicBytes...)
+// magic returns the magic bytes identifying the header's format version
+func (h *Header) magic() string {
+	switch h.version {
+	case constants.FormatVersion3:
+		return constants.MagicBytesV3
+	case constants.FormatVersion4:
+		return constants.MagicBytesV4
+	default:
+		return constants.MagicBytes
+	}
+}
+
+// marshalCore serializes the fields covered by the integrity hash:
+// [Magic, Salt, Size, Nonce] for version 2 and [Magic, Salt, Size, MetaLen, Meta, Nonce] for versions 3 and 4
+func (h *Header) marshalCore(buf []byte) []byte {
+	buf = append(buf, h.magic()...)
 	buf = append(buf, h.salt...)
 
 	sizeBuf := make([]byte, constants.OriginalSizeBytes)
 	binary.BigEndian.PutUint64(sizeBuf, h.originalSize)
 	buf = append(buf, sizeBuf...)
 
-	buf = append(buf, h.nonce...)
+	if h.version >= constants.FormatVersion3 {
+		meta := h.meta.marshal()
+		lenBuf := make([]byte, constants.MetadataLenSize)
+		binary.BigEndian.PutUint32(lenBuf, uint32(len(meta)))
+		buf = append(buf, lenBuf...)
+		buf = append(buf, meta...)
+	}
+
+	return append(buf, h.nonce...)
+}
+
+// marshal serializes the header fields in order into the given buffer
+func (h *Header) marshal(buf []byte) []byte {
+	buf = h.marshalCore(buf)
 	buf = append(buf, h.integrityHash...)
 	buf = append(buf, h.authTag...)
 
 	// Compute CRC32 checksum of everything except magic bytes
-	checksum := crc32.ChecksumIEEE(buf[len(constants.MagicBytes):])
+	checksum := crc32.ChecksumIEEE(buf[len(h.magic()):])
 	checksumBuf := make([]byte, constants.ChecksumSize)
 	binary.BigEndian.PutUint32(checksumBuf, checksum)
 	buf = append(buf, checksumBuf...)
@@ -219,14 +645,18 @@ func (h *Header) marshal(buf []byte) []byte {
 
 // unmarshalHeader deserializes and validates a header from the given byte slice
 func unmarshalHeader(data []byte) (*Header, error) {
-	if len(data) != constants.TotalHeaderSize {
-		return nil, fmt.Errorf("invalid header size: got %d, expected %d", len(data), constants.TotalHeaderSize)
+	if len(data) < constants.TotalHeaderSize {
+		return nil, fmt.Errorf("invalid header size: got %d, expected at least %d", len(data), constants.TotalHeaderSize)
 	}
 
 	// Check magic bytes
-	if subtle.ConstantTimeCompare(data[:len(constants.MagicBytes)], []byte(constants.MagicBytes)) != 1 {
+	version := headerVersion(data)
+	if !slices.Contains(ReadableFormatVersions(), version) {
 		return nil, constants.ErrInvalidMagic
 	}
+	if version == constants.FormatVersion2 && len(data) != constants.TotalHeaderSize {
+		return nil, fmt.Errorf("invalid header size: got %d, expected %d", len(data), constants.TotalHeaderSize)
+	}
 
 	offset := len(constants.MagicBytes)
 
@@ -239,10 +669,25 @@ func unmarshalHeader(data []byte) (*Header, error) {
 	originalSize := binary.BigEndian.Uint64(data[offset : offset+constants.OriginalSizeBytes])
 	offset += constants.OriginalSizeBytes
 
+	// Parse metadata block
+	metaData := []byte{}
+	if version >= constants.FormatVersion3 {
+		metaLen := int(binary.BigEndian.Uint32(data[offset : offset+constants.MetadataLenSize]))
+		offset += constants.MetadataLenSize
+
+		expected := offset + metaLen + nonceSize(version) + constants.IntegritySize + constants.AuthSize + constants.ChecksumSize
+		if metaLen > constants.MaxMetadataSize || len(data) != expected {
+			return nil, fmt.Errorf("%w: metadata length does not match header size", constants.ErrInvalidHeader)
+		}
+
+		metaData = data[offset : offset+metaLen]
+		offset += metaLen
+	}
+
 	// Parse nonce
-	nonce := make([]byte, constants.NonceSizeBytes)
-	copy(nonce, data[offset:offset+constants.NonceSizeBytes])
-	offset += constants.NonceSizeBytes
+	nonce := make([]byte, nonceSize(version))
+	copy(nonce, data[offset:offset+len(nonce)])
+	offset += len(nonce)
 
 	// Parse integrity hash
 	integrityHash := make([]byte, constants.IntegritySize)
@@ -266,9 +711,19 @@ func unmarshalHeader(data []byte) (*Header, error) {
 		return nil, constants.ErrChecksumMismatch
 	}
 
+	meta, err := unmarshalMetadata(metaData)
+	if err != nil {
+		return nil, err
+	}
+	if (version == constants.FormatVersion4) != (meta.bodyCipher() == constants.CipherXChaCha20Poly1305) {
+		return nil, fmt.Errorf("%w: cipher %s does not match format version %d", constants.ErrInvalidHeader, meta.bodyCipher(), version)
+	}
+
 	header := &Header{
+		version:       version,
 		salt:          salt,
 		originalSize:  originalSize,
+		meta:          meta,
 		nonce:         nonce,
 		integrityHash: integrityHash,
 		authTag:       authTag,
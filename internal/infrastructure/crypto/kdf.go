@@ -1,9 +1,9 @@
 package crypto
 
 import (
-	"crypto/rand"
 	"fmt"
 	"io"
+	"strings"
 
 	"golang.org/x/crypto/argon2"
 
@@ -11,32 +11,129 @@ import (
 	"github.com/hambosto/hexwarden/internal/infrastructure/utils"
 )
 
+// KDFParams holds the Argon2 variant and cost parameters used for key derivation
+type KDFParams struct {
+	Time    uint32                  // Number of passes over memory
+	Memory  uint32                  // Memory cost in KiB
+	Threads uint8                   // Degree of parallelism
+	Variant constants.Argon2Variant // Argon2 variant; the zero value is Argon2id
+}
+
+// DefaultKDFParams returns the built-in Argon2id parameters
+func DefaultKDFParams() KDFParams {
+	return KDFParams{
+		Time:    constants.ArgonTime,
+		Memory:  constants.ArgonMemory,
+		Threads: constants.ArgonThreads,
+	}
+}
+
+// Validate checks the parameters against the bounds required by Argon2 and the
+// upper limits any header may ask for
+func (p KDFParams) Validate() error {
+	if p.Variant != constants.Argon2id && p.Variant != constants.Argon2i {
+		return fmt.Errorf("%w: unknown Argon2 variant %d", constants.ErrInvalidKDFParams, p.Variant)
+	}
+	if p.Time == 0 || p.Time > constants.MaxArgonTime {
+		return fmt.Errorf("%w: time cost must be between 1 and %d, got %d", constants.ErrInvalidKDFParams, constants.MaxArgonTime, p.Time)
+	}
+	if p.Memory > constants.MaxArgonMemory {
+		return fmt.Errorf("%w: memory must be at most %d KiB, got %d", constants.ErrInvalidKDFParams, constants.MaxArgonMemory, p.Memory)
+	}
+	if p.Threads == 0 {
+		return fmt.Errorf("%w: threads must be at least 1", constants.ErrInvalidKDFParams)
+	}
+	// Argon2 requires at least 8 KiB of memory per lane
+	if p.Memory < 8*uint32(p.Threads) {
+		return fmt.Errorf("%w: memory must be at least %d KiB for %d threads", constants.ErrInvalidKDFParams, 8*uint32(p.Threads), p.Threads)
+	}
+	return nil
+}
+
 // DeriveKey derives a key from the given password and salt using Argon2id
 func DeriveKey(password, salt []byte) ([]byte, error) {
+	return DeriveKeyWithParams(password, salt, DefaultKDFParams())
+}
+
+// DeriveKeyWithParams derives a key from the given password and salt using Argon2id
+// with explicit cost parameters
+func DeriveKeyWithParams(password, salt []byte, params KDFParams) ([]byte, error) {
+	return DeriveKeyNWithParams(password, salt, constants.KeySize, params)
+}
+
+// DeriveKeyN derives n bytes of keying material from the given password and salt
+// using Argon2id, for ciphers or key-wrapping schemes that need other than KeySize
+func DeriveKeyN(password, salt []byte, n int) ([]byte, error) {
+	return DeriveKeyNWithParams(password, salt, n, DefaultKDFParams())
+}
+
+// DeriveKeyNWithParams derives n bytes of keying material with explicit cost
+// parameters. Argon2id output of different lengths is unrelated, so a 64-byte
+// key does not start with the 32-byte key for the same input
+func DeriveKeyNWithParams(password, salt []byte, n int, params KDFParams) ([]byte, error) {
+	if n < constants.MinDerivedSize || n > constants.MaxDerivedSize {
+		return nil, fmt.Errorf("%w: %d bytes, must be between %d and %d", constants.ErrInvalidKeyLength, n, constants.MinDerivedSize, constants.MaxDerivedSize)
+	}
 	if len(password) == 0 {
 		return nil, constants.ErrEmptyPassword
 	}
 	if len(salt) != constants.SaltSize {
 		return nil, fmt.Errorf("%w: expected %d bytes, got %d", constants.ErrInvalidSalt, constants.SaltSize, len(salt))
 	}
+	if err := params.Validate(); err != nil {
+		return nil, err
+	}
 
+	if params.Variant == constants.Argon2i {
+		return argon2.Key(password, salt, params.Time, params.Memory, params.Threads, uint32(n)), nil
+	}
 	key := argon2.IDKey(
 		password,
 		salt,
-		constants.ArgonTime,
-		constants.ArgonMemory,
-		constants.ArgonThreads,
-		uint32(constants.KeySize),
+		params.Time,
+		params.Memory,
+		params.Threads,
+		uint32(n),
 	)
 	return key, nil
 }
 
-// GenerateSalt generates a new cryptographically secure random salt
+// ParseArgon2Variant converts a user-supplied name such as "id" or "argon2i" into
+// a variant. Argon2d is refused: golang.org/x/crypto/argon2 only implements Argon2i
+// and Argon2id
+func ParseArgon2Variant(name string) (constants.Argon2Variant, error) {
+	switch strings.TrimPrefix(strings.ToLower(name), "argon2") {
+	case "id":
+		return constants.Argon2id, nil
+	case "i":
+		return constants.Argon2i, nil
+	case "d":
+		return 0, fmt.Errorf("%w: Argon2d is not available; use id or i", constants.ErrInvalidKDFParams)
+	default:
+		return 0, fmt.Errorf("%w: unknown Argon2 variant %q, expected id or i", constants.ErrInvalidKDFParams, name)
+	}
+}
+
+// GenerateSalt generates a new cryptographically secure random salt, once the
+// system random source has passed the entropy self-check
 func GenerateSalt() ([]byte, error) {
+	random, err := systemRandom()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", constants.ErrSaltGeneration, err)
+	}
+	return GenerateSaltFrom(random)
+}
+
+// GenerateSaltFrom generates a salt from the given random source, such as a hardware
+// RNG or a seeded reader in tests. Output that looks non-random is rejected
+func GenerateSaltFrom(random io.Reader) ([]byte, error) {
 	salt := make([]byte, constants.SaltSize)
-	if _, err := io.ReadFull(rand.Reader, salt); err != nil {
+	if _, err := io.ReadFull(random, salt); err != nil {
 		return nil, fmt.Errorf("%w: %v", constants.ErrSaltGeneration, err)
 	}
+	if isWeakSalt(salt) {
+		return nil, fmt.Errorf("%w: %w", constants.ErrSaltGeneration, constants.ErrWeakRandom)
+	}
 	return salt, nil
 }
 
@@ -47,7 +144,7 @@ func ValidateSalt(salt []byte) error {
 	}
 
 	if isWeakSalt(salt) {
-		return fmt.Errorf("weak salt detected - use cryptographically random salt")
+		return fmt.Errorf("%w: weak salt detected - use cryptographically random salt", constants.ErrWeakRandom)
 	}
 
 	return nil
@@ -58,7 +155,12 @@ func isWeakSalt(salt []byte) bool {
 	if len(salt) != constants.SaltSize {
 		return true
 	}
+	return isWeakRandom(salt)
+}
 
+// isWeakRandom reports whether random bytes are all zero or one repeated
+// 4-byte pattern, which a working random source practically never produces
+func isWeakRandom(salt []byte) bool {
 	// Check for all zero bytes
 	allZero := true
 	for _, b := range salt {
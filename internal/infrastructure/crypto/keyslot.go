@@ -0,0 +1,141 @@
+package crypto
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/hambosto/hexwarden/internal/constants"
+)
+
+// keySlotSize is the length of one key slot: the Argon2id salt of the extra
+// password, then the file key sealed with AES-GCM under the key derived from it
+const keySlotSize = constants.SaltSize + 12 + constants.KeySize + 16
+
+// keySlotInput is an extra password's salt and the key derived from it, waiting
+// for the file key to be wrapped
+type keySlotInput struct {
+	salt []byte
+	kek  []byte
+}
+
+// validateKeySlotInput checks the salt and key an extra password was derived into
+func validateKeySlotInput(salt, kek []byte) error {
+	if len(salt) != constants.SaltSize {
+		return fmt.Errorf("%w: key slot salt must be %d bytes", constants.ErrInvalidOption, constants.SaltSize)
+	}
+	if len(kek) != constants.KeySize {
+		return fmt.Errorf("%w: key slot key must be %d bytes", constants.ErrInvalidOption, constants.KeySize)
+	}
+	return nil
+}
+
+// wrapKeySlot seals key under kek, prefixed by the salt kek was derived with
+func wrapKeySlot(salt, kek, key []byte) ([]byte, error) {
+	cipher, err := NewAESCipher(kek)
+	if err != nil {
+		return nil, err
+	}
+	sealed, err := cipher.Encrypt(key)
+	if err != nil {
+		return nil, err
+	}
+	return append(append([]byte(nil), salt...), sealed...), nil
+}
+
+// WithKeySlot stores the file key wrapped under kek, the key an extra password
+// derives with salt and the header's KDF parameters, so that password unlocks
+// the file too. The slot is authenticated by the header but readable without the key
+func WithKeySlot(salt, kek []byte) HeaderOption {
+	return func(b *headerBuilder) error {
+		if len(b.keySlots) >= constants.MaxKeySlots {
+			return fmt.Errorf("%w: %w", constants.ErrInvalidOption, constants.ErrTooManyKeySlots)
+		}
+		if err := validateKeySlotInput(salt, kek); err != nil {
+			return err
+		}
+		b.keySlots = append(b.keySlots, keySlotInput{salt: slices.Clone(salt), kek: slices.Clone(kek)})
+		return nil
+	}
+}
+
+// KeySlots returns how many extra passwords the file key is wrapped under
+func (h *Header) KeySlots() int {
+	return len(h.meta.keySlots) / keySlotSize
+}
+
+// keySlot returns slot i, or nil when there is no such slot
+func (h *Header) keySlot(i int) []byte {
+	if i < 0 || i >= h.KeySlots() {
+		return nil
+	}
+	return h.meta.keySlots[i*keySlotSize : (i+1)*keySlotSize]
+}
+
+// KeySlotSalt returns the salt the password of slot i is derived with
+func (h *Header) KeySlotSalt(i int) []byte {
+	slot := h.keySlot(i)
+	if slot == nil {
+		return nil
+	}
+	return slices.Clone(slot[:constants.SaltSize])
+}
+
+// OpenKeySlot unwraps the file key from slot i with kek and verifies it against
+// the header, failing with ErrAuthFailure when kek does not open the slot
+func (h *Header) OpenKeySlot(i int, kek []byte) ([]byte, error) {
+	slot := h.keySlot(i)
+	if slot == nil {
+		return nil, constants.ErrNoKeySlot
+	}
+	cipher, err := NewAESCipher(kek)
+	if err != nil {
+		return nil, err
+	}
+	key, err := cipher.Decrypt(slot[constants.SaltSize:])
+	if err != nil {
+		return nil, fmt.Errorf("%w: password does not open key slot %d", constants.ErrAuthFailure, i)
+	}
+	if err := h.VerifyKey(key); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+// AddKeySlot wraps the file key under kek in a new slot and reseals the header
+// with key, which must be the file key
+func (h *Header) AddKeySlot(key, salt, kek []byte) error {
+	if err := h.VerifyKey(key); err != nil {
+		return err
+	}
+	if h.KeySlots() >= constants.MaxKeySlots {
+		return constants.ErrTooManyKeySlots
+	}
+	if err := validateKeySlotInput(salt, kek); err != nil {
+		return err
+	}
+
+	slot, err := wrapKeySlot(salt, kek, key)
+	if err != nil {
+		return fmt.Errorf("failed to wrap key: %w", err)
+	}
+	meta := *h.meta
+	meta.keySlots = append(slices.Clone(meta.keySlots), slot...)
+	return h.reseal(key, &meta)
+}
+
+// RemoveKeySlot drops slot i and reseals the header with key, which must be the file key
+func (h *Header) RemoveKeySlot(key []byte, i int) error {
+	if err := h.VerifyKey(key); err != nil {
+		return err
+	}
+	if h.keySlot(i) == nil {
+		return constants.ErrNoKeySlot
+	}
+
+	meta := *h.meta
+	meta.keySlots = slices.Delete(slices.Clone(meta.keySlots), i*keySlotSize, (i+1)*keySlotSize)
+	if len(meta.keySlots) == 0 {
+		meta.keySlots = nil
+	}
+	return h.reseal(key, &meta)
+}
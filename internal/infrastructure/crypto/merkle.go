@@ -0,0 +1,170 @@
+package crypto
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"io"
+
+	"github.com/hambosto/hexwarden/internal/constants"
+)
+
+// Domain separation prefixes, so a leaf can never be mistaken for an inner node
+const (
+	merkleLeafPrefix = 0x00
+	merkleNodePrefix = 0x01
+)
+
+// MerkleTree is a binary SHA-256 hash tree over the encrypted chunks of a file.
+// A node without a right sibling is carried up to the next level unchanged
+type MerkleTree struct {
+	levels [][][]byte // levels[0] holds the leaves, the last level the root
+}
+
+// HashMerkleLeaf returns the leaf hash of an encrypted chunk as stored on disk
+func HashMerkleLeaf(chunk []byte) []byte {
+	h := sha256.New()
+	h.Write([]byte{merkleLeafPrefix})
+	h.Write(chunk)
+	return h.Sum(nil)
+}
+
+// hashMerkleNode returns the hash of an inner node with the given children
+func hashMerkleNode(left, right []byte) []byte {
+	h := sha256.New()
+	h.Write([]byte{merkleNodePrefix})
+	h.Write(left)
+	h.Write(right)
+	return h.Sum(nil)
+}
+
+// NewMerkleTree builds the tree over the given leaf hashes
+func NewMerkleTree(leaves [][]byte) *MerkleTree {
+	level := make([][]byte, len(leaves))
+	copy(level, leaves)
+
+	t := &MerkleTree{levels: [][][]byte{level}}
+	for len(level) > 1 {
+		next := make([][]byte, 0, (len(level)+1)/2)
+		for i := 0; i < len(level); i += 2 {
+			if i+1 == len(level) {
+				next = append(next, level[i])
+				continue
+			}
+			next = append(next, hashMerkleNode(level[i], level[i+1]))
+		}
+		t.levels = append(t.levels, next)
+		level = next
+	}
+	return t
+}
+
+// Root returns the root hash. The tree of no chunks has the hash of an empty leaf as root
+func (t *MerkleTree) Root() []byte {
+	top := t.levels[len(t.levels)-1]
+	if len(top) == 0 {
+		return HashMerkleLeaf(nil)
+	}
+	return append([]byte(nil), top[0]...)
+}
+
+// Leaves returns the number of chunks the tree covers
+func (t *MerkleTree) Leaves() int {
+	return len(t.levels[0])
+}
+
+// Proof returns the sibling hashes needed to recompute the root from leaf index,
+// bottom level first. A nil entry marks a level where the node had no sibling
+func (t *MerkleTree) Proof(index int) ([][]byte, error) {
+	if index < 0 || index >= t.Leaves() {
+		return nil, fmt.Errorf("%w: %d, the tree covers %d", constants.ErrChunkNotFound, index, t.Leaves())
+	}
+
+	proof := make([][]byte, 0, len(t.levels)-1)
+	for _, level := range t.levels[:len(t.levels)-1] {
+		sibling := index ^ 1
+		if sibling < len(level) {
+			proof = append(proof, level[sibling])
+		} else {
+			proof = append(proof, nil)
+		}
+		index /= 2
+	}
+	return proof, nil
+}
+
+// VerifyMerkleProof reports whether leaf at index hashes up to root through proof
+func VerifyMerkleProof(root, leaf []byte, index int, proof [][]byte) bool {
+	node := leaf
+	for _, sibling := range proof {
+		switch {
+		case sibling == nil:
+		case index%2 == 0:
+			node = hashMerkleNode(node, sibling)
+		default:
+			node = hashMerkleNode(sibling, node)
+		}
+		index /= 2
+	}
+	return index == 0 && bytes.Equal(node, root)
+}
+
+// WriteTo writes the full tree as a sidecar file: magic, format version, the
+// leaf count and then every level from the leaves up to the root
+func (t *MerkleTree) WriteTo(w io.Writer) (int64, error) {
+	buf := make([]byte, 0, len(constants.MerkleTreeMagic)+1+8)
+	buf = append(buf, constants.MerkleTreeMagic...)
+	buf = append(buf, constants.MerkleTreeFormat)
+	buf = binary.BigEndian.AppendUint64(buf, uint64(t.Leaves()))
+	for _, level := range t.levels {
+		for _, node := range level {
+			buf = append(buf, node...)
+		}
+	}
+
+	n, err := w.Write(buf)
+	if err != nil {
+		return int64(n), fmt.Errorf("write Merkle tree: %w", err)
+	}
+	return int64(n), nil
+}
+
+// ReadMerkleTree reads a sidecar written by WriteTo. Only its shape is checked;
+// a proof taken from it is only meaningful against a root from an authenticated header
+func ReadMerkleTree(r io.Reader) (*MerkleTree, error) {
+	prefix := make([]byte, len(constants.MerkleTreeMagic)+1+8)
+	if _, err := io.ReadFull(r, prefix); err != nil {
+		return nil, fmt.Errorf("%w: %v", constants.ErrInvalidMerkle, err)
+	}
+	if string(prefix[:len(constants.MerkleTreeMagic)]) != constants.MerkleTreeMagic {
+		return nil, fmt.Errorf("%w: bad magic", constants.ErrInvalidMerkle)
+	}
+	if version := prefix[len(constants.MerkleTreeMagic)]; version != constants.MerkleTreeFormat {
+		return nil, fmt.Errorf("%w: unsupported version %d", constants.ErrInvalidMerkle, version)
+	}
+	leaves := binary.BigEndian.Uint64(prefix[len(constants.MerkleTreeMagic)+1:])
+	if leaves > constants.MaxMerkleLeaves {
+		return nil, fmt.Errorf("%w: %d leaves exceeds limit", constants.ErrInvalidMerkle, leaves)
+	}
+
+	t := &MerkleTree{}
+	for width := int(leaves); ; width = (width + 1) / 2 {
+		level := make([][]byte, width)
+		for i := range level {
+			level[i] = make([]byte, constants.MerkleHashSize)
+			if _, err := io.ReadFull(r, level[i]); err != nil {
+				return nil, fmt.Errorf("%w: truncated", constants.ErrInvalidMerkle)
+			}
+		}
+		t.levels = append(t.levels, level)
+		if width <= 1 {
+			break
+		}
+	}
+
+	if n, _ := r.Read(make([]byte, 1)); n != 0 {
+		return nil, fmt.Errorf("%w: trailing data", constants.ErrInvalidMerkle)
+	}
+	return t, nil
+}
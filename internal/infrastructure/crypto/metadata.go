@@ -0,0 +1,408 @@
+package crypto
+
+import (
+	"bytes"
+	"compress/flate"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+
+	"github.com/hambosto/hexwarden/internal/constants"
+)
+
+// metadataKeyLabel separates the metadata sealing key from the header authentication key
+const metadataKeyLabel = "hexwarden/header-metadata"
+
+// metadata holds the optional header fields stored in the metadata block
+type metadata struct {
+	kdfParams     *KDFParams                   // Argon2id parameters, nil when the defaults were used
+	filename      []byte                       // Original filename, sealed with the metadata key
+	hint          string                       // Public password hint, authenticated but not encrypted
+	comment       []byte                       // Free-text comment, sealed with the metadata key
+	hashAlgorithm *constants.HashAlgorithm     // Header hash, nil when SHA-256 was used
+	flags         constants.HeaderFlags        // Body layout flags, 0 when the standard pipeline was used
+	thumbnail     []byte                       // JPEG preview, sealed with the metadata key
+	merkleRoot    []byte                       // Root of the Merkle tree over the chunks, authenticated but not encrypted
+	original      []byte                       // SHA-256 of the original file, sealed with the metadata key
+	cipher        *constants.CipherAlgorithm   // Body cipher, nil when AES-GCM was used
+	notBefore     *int64                       // Unix time before which decryption is refused, authenticated but not encrypted
+	aadHash       []byte                       // SHA-256 of the associated data the body is bound to, authenticated but not encrypted
+	compression   *constants.CompressionFormat // Chunk compression, nil when gzip was used
+	recoveryKey   []byte                       // File key wrapped under a recovery code, authenticated but not encrypted
+	keySlots      []byte                       // File key wrapped under extra passwords, one slot after another, authenticated but not encrypted
+	nonceID       []byte                       // Id of the state file a counter nonce was taken from, nil for a random nonce
+	paddedSize    *uint64                      // Size the file was padded to, nil when it was not padded
+	compress      bool                         // Write the block deflate-compressed when it is large and that makes it smaller
+	packed        []byte                       // The block as read, so a compressed one is hashed exactly as stored; cleared on reseal
+}
+
+// isEmpty reports whether no optional field is set
+func (m *metadata) isEmpty() bool {
+	return m.kdfParams == nil && len(m.filename) == 0 && m.hint == "" && len(m.comment) == 0 && m.hashAlgorithm == nil && m.flags == 0 &&
+		len(m.thumbnail) == 0 && len(m.merkleRoot) == 0 && len(m.original) == 0 && m.cipher == nil && m.notBefore == nil &&
+		len(m.aadHash) == 0 && m.compression == nil && len(m.recoveryKey) == 0 && len(m.keySlots) == 0 && len(m.nonceID) == 0 &&
+		m.paddedSize == nil
+}
+
+// headerHash returns the algorithm protecting the header
+func (m *metadata) headerHash() constants.HashAlgorithm {
+	if m.hashAlgorithm == nil {
+		return constants.HashSHA256
+	}
+	return *m.hashAlgorithm
+}
+
+// bodyCipher returns the cipher the body is encrypted with
+func (m *metadata) bodyCipher() constants.CipherAlgorithm {
+	if m.cipher == nil {
+		return constants.CipherAESGCM
+	}
+	return *m.cipher
+}
+
+// chunkCompression returns the format the chunks are compressed with
+func (m *metadata) chunkCompression() constants.CompressionFormat {
+	if m.compression == nil {
+		return constants.CompressionGzip
+	}
+	return *m.compression
+}
+
+// marshal encodes the metadata as a sequence of tag-length-value entries, or
+// as a single entry holding them compressed when compression is asked for and
+// pays off
+func (m *metadata) marshal() []byte {
+	if m.packed != nil {
+		return m.packed
+	}
+	entries := m.marshalEntries()
+	if !m.compress || len(entries) < constants.MetadataCompressionThreshold {
+		return entries
+	}
+	compressed, err := compressMetadata(entries)
+	if err != nil || len(compressed) > math.MaxUint16 || 3+len(compressed) >= len(entries) {
+		return entries
+	}
+	return appendMetadataEntry(nil, constants.TagCompressedMetadata, compressed)
+}
+
+// marshalEntries encodes every set field as a tag-length-value entry
+func (m *metadata) marshalEntries() []byte {
+	var buf []byte
+
+	if m.kdfParams != nil {
+		value := make([]byte, 9)
+		binary.BigEndian.PutUint32(value[0:4], m.kdfParams.Time)
+		binary.BigEndian.PutUint32(value[4:8], m.kdfParams.Memory)
+		value[8] = m.kdfParams.Threads
+		// Argon2id is implied by the 9-byte form older readers expect
+		if m.kdfParams.Variant != constants.Argon2id {
+			value = append(value, byte(m.kdfParams.Variant))
+		}
+		buf = appendMetadataEntry(buf, constants.TagKDFParams, value)
+	}
+	if len(m.filename) > 0 {
+		buf = appendMetadataEntry(buf, constants.TagFilename, m.filename)
+	}
+	if m.hint != "" {
+		buf = appendMetadataEntry(buf, constants.TagHint, []byte(m.hint))
+	}
+	if len(m.comment) > 0 {
+		buf = appendMetadataEntry(buf, constants.TagComment, m.comment)
+	}
+	if m.hashAlgorithm != nil {
+		buf = appendMetadataEntry(buf, constants.TagHashAlgorithm, []byte{byte(*m.hashAlgorithm)})
+	}
+	if m.flags != 0 {
+		buf = appendMetadataEntry(buf, constants.TagFlags, []byte{byte(m.flags)})
+	}
+	if len(m.thumbnail) > 0 {
+		buf = appendMetadataEntry(buf, constants.TagThumbnail, m.thumbnail)
+	}
+	if len(m.merkleRoot) > 0 {
+		buf = appendMetadataEntry(buf, constants.TagMerkleRoot, m.merkleRoot)
+	}
+	if len(m.original) > 0 {
+		buf = appendMetadataEntry(buf, constants.TagOriginalSHA256, m.original)
+	}
+	if m.cipher != nil {
+		buf = appendMetadataEntry(buf, constants.TagCipher, []byte{byte(*m.cipher)})
+	}
+	if m.notBefore != nil {
+		buf = appendMetadataEntry(buf, constants.TagNotBefore, encodeEpoch(*m.notBefore))
+	}
+	if len(m.aadHash) > 0 {
+		buf = appendMetadataEntry(buf, constants.TagAADHash, m.aadHash)
+	}
+	if m.compression != nil {
+		buf = appendMetadataEntry(buf, constants.TagCompression, []byte{byte(*m.compression)})
+	}
+	if len(m.recoveryKey) > 0 {
+		buf = appendMetadataEntry(buf, constants.TagRecoveryKey, m.recoveryKey)
+	}
+	if len(m.keySlots) > 0 {
+		buf = appendMetadataEntry(buf, constants.TagKeySlots, m.keySlots)
+	}
+	if len(m.nonceID) > 0 {
+		buf = appendMetadataEntry(buf, constants.TagNonceCounter, m.nonceID)
+	}
+	if m.paddedSize != nil {
+		buf = appendMetadataEntry(buf, constants.TagPaddedOutput, binary.BigEndian.AppendUint64(nil, *m.paddedSize))
+	}
+
+	return buf
+}
+
+// appendMetadataEntry appends a single tag-length-value entry to buf
+func appendMetadataEntry(buf []byte, tag constants.MetadataTag, value []byte) []byte {
+	var lenBuf [2]byte
+	binary.BigEndian.PutUint16(lenBuf[:], uint16(len(value)))
+
+	buf = append(buf, byte(tag))
+	buf = append(buf, lenBuf[:]...)
+	return append(buf, value...)
+}
+
+// unmarshalMetadata decodes a metadata block, decompressing it first when it
+// was written compressed
+func unmarshalMetadata(data []byte) (*metadata, error) {
+	if len(data) < 3 || constants.MetadataTag(data[0]) != constants.TagCompressedMetadata {
+		return unmarshalEntries(data)
+	}
+	if length := int(binary.BigEndian.Uint16(data[1:3])); length != len(data)-3 {
+		return nil, fmt.Errorf("%w: compressed metadata must be the only entry", constants.ErrInvalidMetadata)
+	}
+
+	entries, err := decompressMetadata(data[3:])
+	if err != nil {
+		return nil, err
+	}
+	m, err := unmarshalEntries(entries)
+	if err != nil {
+		return nil, err
+	}
+	m.compress = true
+	m.packed = append([]byte(nil), data...)
+	return m, nil
+}
+
+// compressMetadata deflates a metadata block
+func compressMetadata(entries []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	w, err := flate.NewWriter(&buf, flate.BestCompression)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := w.Write(entries); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// decompressMetadata inflates a compressed metadata block, refusing one that
+// expands beyond MaxMetadataSize
+func decompressMetadata(compressed []byte) ([]byte, error) {
+	r := flate.NewReader(bytes.NewReader(compressed))
+	defer r.Close() //nolint:errcheck
+
+	entries, err := io.ReadAll(io.LimitReader(r, constants.MaxMetadataSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("%w: compressed metadata: %v", constants.ErrInvalidMetadata, err)
+	}
+	if len(entries) > constants.MaxMetadataSize {
+		return nil, fmt.Errorf("%w: compressed metadata expands beyond %d bytes", constants.ErrInvalidMetadata, constants.MaxMetadataSize)
+	}
+	return entries, nil
+}
+
+// unmarshalEntries decodes a sequence of metadata entries, rejecting unknown or duplicate tags
+func unmarshalEntries(data []byte) (*metadata, error) {
+	m := &metadata{}
+	seen := make(map[constants.MetadataTag]bool)
+
+	for len(data) > 0 {
+		if len(data) < 3 {
+			return nil, fmt.Errorf("%w: truncated entry", constants.ErrInvalidMetadata)
+		}
+
+		tag := constants.MetadataTag(data[0])
+		length := int(binary.BigEndian.Uint16(data[1:3]))
+		data = data[3:]
+
+		if length > len(data) {
+			return nil, fmt.Errorf("%w: entry %d exceeds block", constants.ErrInvalidMetadata, tag)
+		}
+		if seen[tag] {
+			return nil, fmt.Errorf("%w: duplicate entry %d", constants.ErrInvalidMetadata, tag)
+		}
+		seen[tag] = true
+
+		value := append([]byte(nil), data[:length]...)
+		data = data[length:]
+
+		switch tag {
+		case constants.TagKDFParams:
+			if len(value) != 9 && len(value) != 10 {
+				return nil, fmt.Errorf("%w: kdf parameters must be 9 or 10 bytes", constants.ErrInvalidMetadata)
+			}
+			params := KDFParams{
+				Time:    binary.BigEndian.Uint32(value[0:4]),
+				Memory:  binary.BigEndian.Uint32(value[4:8]),
+				Threads: value[8],
+			}
+			if len(value) == 10 {
+				params.Variant = constants.Argon2Variant(value[9])
+				if params.Variant == constants.Argon2id {
+					return nil, fmt.Errorf("%w: Argon2id must not be recorded", constants.ErrInvalidMetadata)
+				}
+			}
+			if err := params.Validate(); err != nil {
+				return nil, fmt.Errorf("%w: %v", constants.ErrInvalidMetadata, err)
+			}
+			m.kdfParams = &params
+		case constants.TagFilename:
+			m.filename = value
+		case constants.TagHint:
+			m.hint = string(value)
+		case constants.TagComment:
+			m.comment = value
+		case constants.TagHashAlgorithm:
+			if len(value) != 1 {
+				return nil, fmt.Errorf("%w: hash algorithm must be 1 byte", constants.ErrInvalidMetadata)
+			}
+			alg := constants.HashAlgorithm(value[0])
+			if _, err := hashConstructor(alg); err != nil {
+				return nil, fmt.Errorf("%w: %v", constants.ErrInvalidMetadata, err)
+			}
+			m.hashAlgorithm = &alg
+		case constants.TagFlags:
+			if len(value) != 1 {
+				return nil, fmt.Errorf("%w: flags must be 1 byte", constants.ErrInvalidMetadata)
+			}
+			flags := constants.HeaderFlags(value[0])
+			if flags&^constants.KnownHeaderFlags != 0 {
+				return nil, fmt.Errorf("%w: unknown flags %#x", constants.ErrInvalidMetadata, byte(flags))
+			}
+			m.flags = flags
+		case constants.TagThumbnail:
+			m.thumbnail = value
+		case constants.TagMerkleRoot:
+			if len(value) != constants.MerkleHashSize {
+				return nil, fmt.Errorf("%w: Merkle root must be %d bytes", constants.ErrInvalidMetadata, constants.MerkleHashSize)
+			}
+			m.merkleRoot = value
+		case constants.TagOriginalSHA256:
+			m.original = value
+		case constants.TagCipher:
+			if len(value) != 1 {
+				return nil, fmt.Errorf("%w: cipher must be 1 byte", constants.ErrInvalidMetadata)
+			}
+			alg := constants.CipherAlgorithm(value[0])
+			if !isConcreteCipher(alg) {
+				return nil, fmt.Errorf("%w: unsupported cipher %d", constants.ErrInvalidMetadata, alg)
+			}
+			m.cipher = &alg
+		case constants.TagNotBefore:
+			if len(value) != epochSize {
+				return nil, fmt.Errorf("%w: not-before time must be %d bytes", constants.ErrInvalidMetadata, epochSize)
+			}
+			notBefore := decodeEpoch(value)
+			m.notBefore = &notBefore
+		case constants.TagAADHash:
+			if len(value) != sha256.Size {
+				return nil, fmt.Errorf("%w: associated data hash must be %d bytes", constants.ErrInvalidMetadata, sha256.Size)
+			}
+			m.aadHash = value
+		case constants.TagCompression:
+			if len(value) != 1 {
+				return nil, fmt.Errorf("%w: compression must be 1 byte", constants.ErrInvalidMetadata)
+			}
+			format := constants.CompressionFormat(value[0])
+			if !isKnownCompression(format) {
+				return nil, fmt.Errorf("%w: unsupported compression %d", constants.ErrInvalidMetadata, format)
+			}
+			m.compression = &format
+		case constants.TagRecoveryKey:
+			if len(value) != recoveryKeySize {
+				return nil, fmt.Errorf("%w: recovery key must be %d bytes", constants.ErrInvalidMetadata, recoveryKeySize)
+			}
+			m.recoveryKey = value
+		case constants.TagKeySlots:
+			if len(value) == 0 || len(value)%keySlotSize != 0 || len(value) > constants.MaxKeySlots*keySlotSize {
+				return nil, fmt.Errorf("%w: key slots must be 1 to %d entries of %d bytes", constants.ErrInvalidMetadata, constants.MaxKeySlots, keySlotSize)
+			}
+			m.keySlots = value
+		case constants.TagNonceCounter:
+			if len(value) != constants.NonceCounterIDSize {
+				return nil, fmt.Errorf("%w: nonce counter id must be %d bytes", constants.ErrInvalidMetadata, constants.NonceCounterIDSize)
+			}
+			m.nonceID = value
+		case constants.TagPaddedOutput:
+			if len(value) != 8 {
+				return nil, fmt.Errorf("%w: padded size must be 8 bytes", constants.ErrInvalidMetadata)
+			}
+			size := binary.BigEndian.Uint64(value)
+			if size == 0 {
+				return nil, fmt.Errorf("%w: padded size cannot be zero", constants.ErrInvalidMetadata)
+			}
+			m.paddedSize = &size
+		default:
+			return nil, fmt.Errorf("%w: unknown entry %d", constants.ErrInvalidMetadata, tag)
+		}
+	}
+
+	return m, nil
+}
+
+// metadataCipher returns the cipher used to seal confidential metadata fields
+func metadataCipher(key []byte) (*AESCipher, error) {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(metadataKeyLabel))
+	return NewAESCipher(mac.Sum(nil))
+}
+
+// sealMetadataField encrypts a confidential metadata value with the header key
+func sealMetadataField(key, value []byte) ([]byte, error) {
+	cipher, err := metadataCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	sealed, err := cipher.Encrypt(value)
+	if err != nil {
+		return nil, err
+	}
+	if len(sealed) > math.MaxUint16 {
+		return nil, fmt.Errorf("%w: sealed field too large", constants.ErrInvalidOption)
+	}
+	return sealed, nil
+}
+
+// openMetadataField decrypts a confidential metadata value with the header key
+func openMetadataField(key, sealed []byte) ([]byte, error) {
+	cipher, err := metadataCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.Decrypt(sealed)
+}
+
+// epochSize is the length of a stored time: seconds since the Unix epoch as a
+// big-endian int64, which names the same instant in every time zone
+const epochSize = 8
+
+// encodeEpoch stores seconds since the Unix epoch
+func encodeEpoch(seconds int64) []byte {
+	return binary.BigEndian.AppendUint64(nil, uint64(seconds))
+}
+
+// decodeEpoch reads a time stored by encodeEpoch
+func decodeEpoch(value []byte) int64 {
+	return int64(binary.BigEndian.Uint64(value))
+}
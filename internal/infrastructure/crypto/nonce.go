@@ -0,0 +1,117 @@
+package crypto
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"strings"
+	"sync/atomic"
+
+	"github.com/hambosto/hexwarden/internal/constants"
+)
+
+// ParseNonceStrategy converts a nonce strategy name, random or counter, to a NonceStrategy
+func ParseNonceStrategy(name string) (constants.NonceStrategy, error) {
+	switch strings.ToLower(name) {
+	case "", "random":
+		return constants.NonceRandom, nil
+	case "counter":
+		return constants.NonceCounter, nil
+	default:
+		return 0, fmt.Errorf("%w: unknown nonce strategy %q", constants.ErrInvalidOption, name)
+	}
+}
+
+// WithNonceCounter builds the header nonce from id, the random id of a nonce
+// counter state file, and counter, a value taken from it, instead of drawing
+// the nonce at random. The id is recorded in the header. The caller must never
+// use the same counter twice with one id
+func WithNonceCounter(id []byte, counter uint64) HeaderOption {
+	return func(b *headerBuilder) error {
+		if b.nonceID != nil {
+			return fmt.Errorf("%w: nonce counter set more than once", constants.ErrInvalidOption)
+		}
+		if len(id) != constants.NonceCounterIDSize {
+			return fmt.Errorf("%w: nonce counter id must be %d bytes", constants.ErrInvalidOption, constants.NonceCounterIDSize)
+		}
+		if isWeakRandom(id) {
+			return fmt.Errorf("%w: nonce counter id: %w", constants.ErrInvalidOption, constants.ErrWeakRandom)
+		}
+		b.nonceID = bytes.Clone(id)
+		b.nonceCounter = counter
+		return nil
+	}
+}
+
+// counterNonce lays a counter nonce of the given size out as the id, zeros,
+// then the big-endian counter
+func counterNonce(id []byte, counter uint64, size int) []byte {
+	nonce := make([]byte, size)
+	copy(nonce, id)
+	binary.BigEndian.PutUint64(nonce[size-constants.NonceCounterSize:], counter)
+	return nonce
+}
+
+// chunkNonceLabel separates the chunk nonce prefix from other values derived from the file key
+const chunkNonceLabel = "hexwarden chunk nonce prefix"
+
+// ChunkNonces hands out the nonces chunks are sealed under with the counter
+// nonce strategy: a per-file prefix followed by a big-endian chunk counter. The
+// prefix is an HMAC of the header nonce under the file key, and the state file
+// never hands out a header nonce twice, so no two chunks share a nonce
+type ChunkNonces struct {
+	prefix []byte        // HMAC-SHA256 of the header nonce, cut to the nonce size less the counter
+	base   uint64        // Counter of chunk 0 of the current stream
+	next   atomic.Uint64 // One past the highest counter handed out
+}
+
+// NewChunkNonces derives the chunk nonces of a file from its key and header nonce
+func NewChunkNonces(key, headerNonce []byte) *ChunkNonces {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(chunkNonceLabel))
+	mac.Write(headerNonce)
+	return &ChunkNonces{prefix: mac.Sum(nil)}
+}
+
+// Nonce returns the size-byte nonce of chunk index of the current stream. It
+// is safe to call from several goroutines. Size must be at most 40 bytes, far
+// more than any supported cipher uses
+func (n *ChunkNonces) Nonce(index uint64, size int) []byte {
+	counter := n.base + index
+	for next := n.next.Load(); counter >= next; next = n.next.Load() {
+		if n.next.CompareAndSwap(next, counter+1) {
+			break
+		}
+	}
+
+	nonce := make([]byte, size)
+	copy(nonce, n.prefix[:size-constants.NonceCounterSize])
+	binary.BigEndian.PutUint64(nonce[size-constants.NonceCounterSize:], counter)
+	return nonce
+}
+
+// Continue starts a new stream after the highest counter handed out, so the
+// chunk indexes of several streams sealed under one key never share a nonce
+func (n *ChunkNonces) Continue() {
+	n.base = n.next.Load()
+}
+
+// NonceStrategy returns how the header nonce was generated
+func (h *Header) NonceStrategy() constants.NonceStrategy {
+	if len(h.meta.nonceID) > 0 {
+		return constants.NonceCounter
+	}
+	return constants.NonceRandom
+}
+
+// NonceCounter returns the id of the state file a counter nonce was taken from
+// and the counter, or false when the nonce was drawn at random
+func (h *Header) NonceCounter() ([]byte, uint64, bool) {
+	if len(h.meta.nonceID) == 0 {
+		return nil, 0, false
+	}
+	counter := binary.BigEndian.Uint64(h.nonce[len(h.nonce)-constants.NonceCounterSize:])
+	return bytes.Clone(h.meta.nonceID), counter, true
+}
@@ -0,0 +1,19 @@
+package crypto
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+)
+
+// ApplyPepper mixes a secret pepper into password before key derivation, as
+// HMAC-SHA256 of the password keyed with the pepper. The pepper is never
+// stored, so a file and its password alone cannot reproduce the key. Without a
+// pepper the password is returned unchanged
+func ApplyPepper(password, pepper []byte) []byte {
+	if len(pepper) == 0 {
+		return password
+	}
+	mac := hmac.New(sha256.New, pepper)
+	mac.Write(password)
+	return mac.Sum(nil)
+}
@@ -0,0 +1,167 @@
+package crypto
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hkdf"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"io"
+
+	"github.com/hambosto/hexwarden/internal/constants"
+)
+
+// recordKeyLabel separates log record keys from the other keys derived from the file key
+const recordKeyLabel = "hexwarden/log-record"
+
+// footerKeyLabel separates the size footer key from the other keys derived from the file key
+const footerKeyLabel = "hexwarden/size-footer"
+
+// paddingKeyLabel separates the output padding key from the other keys derived from the file key
+const paddingKeyLabel = "hexwarden/output-padding"
+
+// SealRecord encrypts one log record with AES-256-GCM. Its key and nonce are
+// derived with HKDF from key and a random per-record salt, so records never share
+// a nonce, and index is authenticated so records cannot be reordered.
+// The result is the salt followed by the ciphertext and tag
+func SealRecord(key []byte, index uint64, record []byte) ([]byte, error) {
+	random, err := systemRandom()
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate record salt: %w", err)
+	}
+	salt := make([]byte, constants.LogRecordSaltSize)
+	if _, err := io.ReadFull(random, salt); err != nil {
+		return nil, fmt.Errorf("failed to generate record salt: %w", err)
+	}
+
+	aead, nonce, err := recordAEAD(key, salt, recordKeyLabel)
+	if err != nil {
+		return nil, err
+	}
+	return aead.Seal(salt, nonce, record, recordIndex(index)), nil
+}
+
+// OpenRecord decrypts a record sealed by SealRecord at position index
+func OpenRecord(key []byte, index uint64, sealed []byte) ([]byte, error) {
+	if len(sealed) < constants.LogRecordSaltSize {
+		return nil, fmt.Errorf("%w: %w: record %d", constants.ErrDecryptionFailed, constants.ErrCiphertextTooShort, index)
+	}
+
+	salt := sealed[:constants.LogRecordSaltSize]
+	aead, nonce, err := recordAEAD(key, salt, recordKeyLabel)
+	if err != nil {
+		return nil, err
+	}
+	if len(sealed) < len(salt)+aead.Overhead() {
+		return nil, fmt.Errorf("%w: %w: record %d", constants.ErrDecryptionFailed, constants.ErrCiphertextTooShort, index)
+	}
+
+	record, err := aead.Open(nil, nonce, sealed[len(salt):], recordIndex(index))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w: record %d", constants.ErrDecryptionFailed, constants.ErrTagMismatch, index)
+	}
+	return record, nil
+}
+
+// SealFooter seals the plaintext size of a body written from a source of
+// unknown size, like a log record under its own key. The result is
+// SizeFooterSize bytes long
+func SealFooter(key []byte, size uint64) ([]byte, error) {
+	random, err := systemRandom()
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate footer salt: %w", err)
+	}
+	salt := make([]byte, constants.LogRecordSaltSize)
+	if _, err := io.ReadFull(random, salt); err != nil {
+		return nil, fmt.Errorf("failed to generate footer salt: %w", err)
+	}
+
+	aead, nonce, err := recordAEAD(key, salt, footerKeyLabel)
+	if err != nil {
+		return nil, err
+	}
+	return aead.Seal(salt, nonce, binary.BigEndian.AppendUint64(nil, size), nil), nil
+}
+
+// OpenFooter returns the plaintext size sealed by SealFooter
+func OpenFooter(key, sealed []byte) (uint64, error) {
+	if len(sealed) != constants.SizeFooterSize {
+		return 0, fmt.Errorf("%w: footer must be %d bytes", constants.ErrFooterMismatch, constants.SizeFooterSize)
+	}
+
+	salt := sealed[:constants.LogRecordSaltSize]
+	aead, nonce, err := recordAEAD(key, salt, footerKeyLabel)
+	if err != nil {
+		return 0, err
+	}
+	size, err := aead.Open(nil, nonce, sealed[len(salt):], nil)
+	if err != nil {
+		return 0, fmt.Errorf("%w: %w: size footer", constants.ErrDecryptionFailed, constants.ErrTagMismatch)
+	}
+	return binary.BigEndian.Uint64(size), nil
+}
+
+// SealPadding seals the length and SHA-256 of the filler a padded output ends
+// with, like a log record under its own key. The result is PaddingRecordSize bytes long
+func SealPadding(key []byte, length uint64, digest []byte) ([]byte, error) {
+	if len(digest) != sha256.Size {
+		return nil, fmt.Errorf("%w: padding digest must be %d bytes", constants.ErrInvalidOption, sha256.Size)
+	}
+	random, err := systemRandom()
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate padding salt: %w", err)
+	}
+	salt := make([]byte, constants.LogRecordSaltSize)
+	if _, err := io.ReadFull(random, salt); err != nil {
+		return nil, fmt.Errorf("failed to generate padding salt: %w", err)
+	}
+
+	aead, nonce, err := recordAEAD(key, salt, paddingKeyLabel)
+	if err != nil {
+		return nil, err
+	}
+	return aead.Seal(salt, nonce, append(binary.BigEndian.AppendUint64(nil, length), digest...), nil), nil
+}
+
+// OpenPadding returns the filler length and SHA-256 sealed by SealPadding
+func OpenPadding(key, sealed []byte) (uint64, []byte, error) {
+	if len(sealed) != constants.PaddingRecordSize {
+		return 0, nil, fmt.Errorf("%w: record must be %d bytes", constants.ErrPaddingMismatch, constants.PaddingRecordSize)
+	}
+
+	salt := sealed[:constants.LogRecordSaltSize]
+	aead, nonce, err := recordAEAD(key, salt, paddingKeyLabel)
+	if err != nil {
+		return 0, nil, err
+	}
+	record, err := aead.Open(nil, nonce, sealed[len(salt):], nil)
+	if err != nil {
+		return 0, nil, fmt.Errorf("%w: %w: padding record", constants.ErrDecryptionFailed, constants.ErrTagMismatch)
+	}
+	return binary.BigEndian.Uint64(record), record[8:], nil
+}
+
+// recordAEAD derives the cipher and nonce of the record with the given salt,
+// under the key derived with label
+func recordAEAD(key, salt []byte, label string) (cipher.AEAD, []byte, error) {
+	material, err := hkdf.Key(sha256.New, key, salt, label, constants.KeySize+12)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to derive record key: %w", err)
+	}
+
+	block, err := aes.NewCipher(material[:constants.KeySize])
+	if err != nil {
+		return nil, nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, nil, err
+	}
+	return aead, material[constants.KeySize:], nil
+}
+
+// recordIndex encodes a record position as additional authenticated data
+func recordIndex(index uint64) []byte {
+	return binary.BigEndian.AppendUint64(nil, index)
+}
@@ -0,0 +1,150 @@
+package crypto
+
+import (
+	"crypto/hkdf"
+	"crypto/sha256"
+	"encoding/base32"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/hambosto/hexwarden/internal/constants"
+)
+
+// recoveryKeyLabel separates the key wrapping the file key from anything else
+// derived from a recovery code
+const recoveryKeyLabel = "hexwarden/recovery-key"
+
+// recoveryKeySize is the length of a wrapped file key: the salt, then the file
+// key sealed with AES-GCM, nonce and tag included
+const recoveryKeySize = constants.RecoverySaltSize + 12 + constants.KeySize + 16
+
+// recoveryEncoding writes recovery codes in unpadded base32, which survives
+// being read aloud or copied by hand
+var recoveryEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// NewRecoveryCode returns a random recovery code, printed in dash-separated
+// groups such as ABCD-EFGH-...
+func NewRecoveryCode() (string, error) {
+	random, err := systemRandom()
+	if err != nil {
+		return "", fmt.Errorf("failed to generate recovery code: %w", err)
+	}
+	code := make([]byte, constants.RecoveryCodeSize)
+	if _, err := io.ReadFull(random, code); err != nil {
+		return "", fmt.Errorf("failed to generate recovery code: %w", err)
+	}
+	if isWeakRandom(code) {
+		return "", fmt.Errorf("failed to generate recovery code: %w", constants.ErrWeakRandom)
+	}
+
+	encoded := recoveryEncoding.EncodeToString(code)
+	groups := make([]string, 0, len(encoded)/constants.RecoveryCodeGroup)
+	for len(encoded) > 0 {
+		n := min(constants.RecoveryCodeGroup, len(encoded))
+		groups = append(groups, encoded[:n])
+		encoded = encoded[n:]
+	}
+	return strings.Join(groups, "-"), nil
+}
+
+// ParseRecoveryCode decodes a recovery code printed by NewRecoveryCode. Case,
+// dashes and spaces are ignored
+func ParseRecoveryCode(code string) ([]byte, error) {
+	normalized := strings.ToUpper(strings.NewReplacer("-", "", " ", "").Replace(code))
+	decoded, err := recoveryEncoding.DecodeString(normalized)
+	if err != nil {
+		return nil, fmt.Errorf("%w: not a recovery code", constants.ErrRecoveryCode)
+	}
+	if len(decoded) != constants.RecoveryCodeSize {
+		return nil, fmt.Errorf("%w: expected %d characters", constants.ErrRecoveryCode, recoveryEncoding.EncodedLen(constants.RecoveryCodeSize))
+	}
+	return decoded, nil
+}
+
+// recoveryCipher returns the cipher wrapping the file key under code and salt.
+// The code carries 160 random bits, so it needs no costly key derivation
+func recoveryCipher(code, salt []byte) (*AESCipher, error) {
+	kek, err := hkdf.Key(sha256.New, code, salt, recoveryKeyLabel, constants.KeySize)
+	if err != nil {
+		return nil, fmt.Errorf("failed to derive recovery key: %w", err)
+	}
+	return NewAESCipher(kek)
+}
+
+// wrapRecoveryKey seals key under code with a fresh salt
+func wrapRecoveryKey(code, key []byte) ([]byte, error) {
+	random, err := systemRandom()
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate recovery salt: %w", err)
+	}
+	salt := make([]byte, constants.RecoverySaltSize)
+	if _, err := io.ReadFull(random, salt); err != nil {
+		return nil, fmt.Errorf("failed to generate recovery salt: %w", err)
+	}
+
+	cipher, err := recoveryCipher(code, salt)
+	if err != nil {
+		return nil, err
+	}
+	sealed, err := cipher.Encrypt(key)
+	if err != nil {
+		return nil, err
+	}
+	return append(salt, sealed...), nil
+}
+
+// unwrapRecoveryKey opens a key sealed by wrapRecoveryKey, failing when code is wrong
+func unwrapRecoveryKey(code, wrapped []byte) ([]byte, error) {
+	salt := wrapped[:constants.RecoverySaltSize]
+	cipher, err := recoveryCipher(code, salt)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.Decrypt(wrapped[constants.RecoverySaltSize:])
+}
+
+// WithRecoveryCode stores the file key wrapped under a key derived from code,
+// so the file can be unlocked with Header.RecoverKey when the password is lost.
+// The wrapped key is authenticated by the header but readable without the key
+func WithRecoveryCode(code string) HeaderOption {
+	return func(b *headerBuilder) error {
+		if b.recoveryCode != nil {
+			return fmt.Errorf("%w: recovery code set more than once", constants.ErrInvalidOption)
+		}
+		decoded, err := ParseRecoveryCode(code)
+		if err != nil {
+			return fmt.Errorf("%w: %w", constants.ErrInvalidOption, err)
+		}
+		b.recoveryCode = decoded
+		return nil
+	}
+}
+
+// HasRecoveryKey reports whether the file key is also wrapped under a recovery code
+func (h *Header) HasRecoveryKey() bool {
+	return len(h.meta.recoveryKey) > 0
+}
+
+// RecoverKey unwraps the file key with a recovery code printed by
+// NewRecoveryCode and verifies it against the header, in place of deriving it
+// from the password. It fails with ErrNoRecoveryCode when the file holds no
+// wrapped key and ErrAuthFailure when code does not unlock it
+func (h *Header) RecoverKey(code string) ([]byte, error) {
+	if !h.HasRecoveryKey() {
+		return nil, constants.ErrNoRecoveryCode
+	}
+	decoded, err := ParseRecoveryCode(code)
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := unwrapRecoveryKey(decoded, h.meta.recoveryKey)
+	if err != nil {
+		return nil, fmt.Errorf("%w: recovery code does not unlock the file", constants.ErrAuthFailure)
+	}
+	if err := h.VerifyKey(key); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
@@ -0,0 +1,33 @@
+package crypto
+
+import (
+	"fmt"
+
+	"github.com/hambosto/hexwarden/internal/constants"
+)
+
+// UnencryptedKey returns the key that authenticates the headers of files written
+// without encryption. It is public, so such a header detects corruption but not tampering
+func UnencryptedKey() []byte {
+	return make([]byte, constants.KeySize)
+}
+
+// BuildUnencrypted creates the header of a body protected only by Reed-Solomon,
+// marked with FlagUnencrypted and keyed with UnencryptedKey
+func BuildUnencrypted(originalSize uint64, opts ...HeaderOption) (*Header, error) {
+	salt, err := GenerateSalt()
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate salt: %w", err)
+	}
+
+	opts = append(opts, WithFlags(constants.FlagUnencrypted))
+	return Build(salt, originalSize, UnencryptedKey(), opts...)
+}
+
+// VerifyUnencrypted checks a header written by BuildUnencrypted
+func (h *Header) VerifyUnencrypted() error {
+	if h.Flags()&constants.FlagUnencrypted == 0 {
+		return constants.ErrEncrypted
+	}
+	return h.VerifyKey(UnencryptedKey())
+}
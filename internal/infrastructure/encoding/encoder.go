@@ -10,9 +10,12 @@ import (
 
 // Encoder handles Reed-Solomon encoding and decoding operations
 type Encoder struct {
-	dataShards   int
-	parityShards int
-	encoder      reedsolomon.Encoder
+	dataShards    int
+	parityShards  int
+	encoder       reedsolomon.Encoder
+	verifyOnly    bool // Reject shards that fail parity verification instead of decoding them
+	repairCorrupt bool // Locate and rebuild shards that fail parity verification
+	zeroCopy      bool // Decode returns a view into the encoded input instead of a copy
 }
 
 // NewEncoder creates a new Reed-Solomon encoder with the specified number of data and parity shards
@@ -41,21 +44,60 @@ func NewDefaultEncoder() (*Encoder, error) {
 	return NewEncoder(constants.DataShards, constants.ParityShards)
 }
 
-// Encode encodes the input data using Reed-Solomon encoding
+// SetReconstruct selects whether Decode decodes data that fails parity verification.
+// It is enabled by default; when disabled such data is rejected with ErrParityMismatch,
+// so a chunk with too many bad shards can never be "recovered" to the wrong bytes
+func (e *Encoder) SetReconstruct(enabled bool) {
+	e.verifyOnly = !enabled
+}
+
+// SetRepair selects whether Decode locates and rebuilds shards that fail parity
+// verification instead of returning the data shards as stored. It is disabled by
+// default, leaving corrupt encrypted chunks to fail authentication; bodies written
+// without encryption have only the parity to protect them and enable it
+func (e *Encoder) SetRepair(enabled bool) {
+	e.repairCorrupt = enabled
+}
+
+// SetZeroCopy selects whether Decode returns the data shards as a view into the
+// encoded input rather than a copy. The view shares memory with the input, so the
+// caller must not modify or reuse the input while the result is in use. Data that
+// was repaired is always returned as a fresh slice
+func (e *Encoder) SetZeroCopy(enabled bool) {
+	e.zeroCopy = enabled
+}
+
+// Encode encodes the input data using Reed-Solomon encoding. The shards are laid
+// out back to back in the returned slice and parity is written into it in place
 func (e *Encoder) Encode(data []byte) ([]byte, error) {
 	if !e.isValidDataSize(data) {
 		return nil, fmt.Errorf("%w: must be between 1 and %d bytes", constants.ErrEncodingFailed, constants.MaxDataLen)
 	}
 
-	shards := e.splitIntoShards(data)
-	if err := e.encoder.Encode(shards); err != nil {
+	shardSize := (len(data) + e.dataShards - 1) / e.dataShards
+	encoded := make([]byte, shardSize*(e.dataShards+e.parityShards))
+	copy(encoded, data)
+
+	if err := e.encoder.Encode(e.splitEncodedData(encoded)); err != nil {
 		return nil, fmt.Errorf("encoding failed: %w", err)
 	}
 
-	return e.combineShards(shards), nil
+	return encoded, nil
+}
+
+// DecodeReport describes the shards found to disagree with the parity while decoding
+type DecodeReport struct {
+	Corrupted []int // Indices of shards found corrupt, in ascending order
 }
 
-// Decode decodes the Reed-Solomon encoded data
+// Damaged reports whether any shard was found corrupt
+func (r DecodeReport) Damaged() bool {
+	return len(r.Corrupted) > 0
+}
+
+// Decode decodes the Reed-Solomon encoded data. Only missing shards are rebuilt
+// unless SetRepair is enabled, and with reconstruction disabled data that fails
+// parity verification is rejected with ErrParityMismatch
 func (e *Encoder) Decode(encoded []byte) ([]byte, error) {
 	totalShards := e.dataShards + e.parityShards
 
@@ -65,33 +107,216 @@ func (e *Encoder) Decode(encoded []byte) ([]byte, error) {
 
 	shards := e.splitEncodedData(encoded)
 
+	if e.verifyOnly || e.repairCorrupt {
+		ok, err := e.encoder.Verify(shards)
+		if err != nil {
+			return nil, fmt.Errorf("verification failed: %w", err)
+		}
+		if !ok && e.verifyOnly {
+			columns, err := e.mismatchedColumns(shards)
+			if err != nil {
+				return nil, err
+			}
+			return nil, fmt.Errorf("%w: %d byte(s) per shard disagree, reconstruction disabled", constants.ErrParityMismatch, len(columns))
+		}
+		if !ok {
+			repaired, _, err := e.repair(shards)
+			if err != nil {
+				return nil, err
+			}
+			return e.extractData(repaired)
+		}
+	}
+
 	if err := e.encoder.Reconstruct(shards); err != nil {
 		return nil, fmt.Errorf("reconstruction failed: %w", err)
 	}
 
+	if e.zeroCopy {
+		size := len(shards[0]) * e.dataShards
+		return encoded[:size:size], nil
+	}
 	return e.extractData(shards)
 }
 
-// splitIntoShards splits input data into shards for encoding
-func (e *Encoder) splitIntoShards(data []byte) [][]byte {
-	totalShards := e.dataShards + e.parityShards
-	shardSize := (len(data) + e.dataShards - 1) / e.dataShards
+// DecodeVerbose decodes exactly like Decode and also reports which shards disagree
+// with the parity. When verification fails the corrupt shards are located byte
+// column by byte column on a copy, tolerating up to parityShards/2 bad shards per
+// column; beyond that it fails with ErrDecodingFailed
+func (e *Encoder) DecodeVerbose(encoded []byte) ([]byte, DecodeReport, error) {
+	data, err := e.Decode(encoded)
+	if err != nil {
+		return nil, DecodeReport{}, err
+	}
 
-	shards := make([][]byte, totalShards)
+	shards := e.splitEncodedData(encoded)
+	ok, err := e.encoder.Verify(shards)
+	if err != nil {
+		return nil, DecodeReport{}, fmt.Errorf("verification failed: %w", err)
+	}
+	if ok {
+		return data, DecodeReport{}, nil
+	}
+
+	_, corrupted, err := e.repair(shards)
+	if err != nil {
+		return nil, DecodeReport{}, err
+	}
+	return data, DecodeReport{Corrupted: corrupted}, nil
+}
+
+// repair returns a corrected copy of shards and the indices of the shards that were corrupt
+func (e *Encoder) repair(shards [][]byte) ([][]byte, []int, error) {
+	columns, err := e.mismatchedColumns(shards)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Fast path: the shards that are bad in the first column are often bad everywhere
+	suspects, _, ok := e.locateColumn(e.column(shards, columns[0]), nil)
+	if !ok {
+		return nil, nil, fmt.Errorf("%w: too many corrupt shards at byte %d", constants.ErrDecodingFailed, columns[0])
+	}
+	if repaired, ok := e.reconstructWithout(shards, suspects); ok {
+		return repaired, suspects, nil
+	}
+
+	// Slow path: repair each mismatched column on its own
+	repaired := make([][]byte, len(shards))
+	for i, shard := range shards {
+		repaired[i] = append([]byte(nil), shard...)
+	}
+
+	corrupt := make(map[int]bool)
+	for _, col := range columns {
+		indices, fixed, ok := e.locateColumn(e.column(repaired, col), suspects)
+		if !ok {
+			return nil, nil, fmt.Errorf("%w: too many corrupt shards at byte %d", constants.ErrDecodingFailed, col)
+		}
+		for _, i := range indices {
+			repaired[i][col] = fixed[i][0]
+			corrupt[i] = true
+		}
+		suspects = indices
+	}
+
+	corrupted := make([]int, 0, len(corrupt))
 	for i := range shards {
-		shards[i] = make([]byte, shardSize)
+		if corrupt[i] {
+			corrupted = append(corrupted, i)
+		}
+	}
+	return repaired, corrupted, nil
+}
+
+// mismatchedColumns returns the byte offsets at which the stored parity disagrees with the data
+func (e *Encoder) mismatchedColumns(shards [][]byte) ([]int, error) {
+	shardSize := len(shards[0])
+
+	expected := make([][]byte, len(shards))
+	copy(expected, shards[:e.dataShards])
+	for i := e.dataShards; i < len(shards); i++ {
+		expected[i] = make([]byte, shardSize)
 	}
 
-	for i, b := range data {
-		shardIndex := i / shardSize
-		posInShard := i % shardSize
-		shards[shardIndex][posInShard] = b
+	if err := e.encoder.Encode(expected); err != nil {
+		return nil, fmt.Errorf("parity computation failed: %w", err)
 	}
 
-	return shards
+	var columns []int
+	for col := 0; col < shardSize; col++ {
+		for i := e.dataShards; i < len(shards); i++ {
+			if expected[i][col] != shards[i][col] {
+				columns = append(columns, col)
+				break
+			}
+		}
+	}
+	return columns, nil
 }
 
-// splitEncodedData splits encoded data back into individual shards
+// column returns the bytes at offset col of every shard as single-byte shards
+func (e *Encoder) column(shards [][]byte, col int) [][]byte {
+	column := make([][]byte, len(shards))
+	for i, shard := range shards {
+		column[i] = []byte{shard[col]}
+	}
+	return column
+}
+
+// locateColumn finds the smallest set of shards whose removal makes the column consistent,
+// trying the hint first. It returns the set and the reconstructed column
+func (e *Encoder) locateColumn(column [][]byte, hint []int) ([]int, [][]byte, bool) {
+	if len(hint) > 0 {
+		if fixed, ok := e.reconstructWithout(column, hint); ok {
+			return hint, fixed, true
+		}
+	}
+
+	for k := 1; k <= e.parityShards/2; k++ {
+		var found []int
+		var fixed [][]byte
+		forEachCombination(len(column), k, func(indices []int) bool {
+			if result, ok := e.reconstructWithout(column, indices); ok {
+				found = append([]int(nil), indices...)
+				fixed = result
+				return true
+			}
+			return false
+		})
+		if found != nil {
+			return found, fixed, true
+		}
+	}
+	return nil, nil, false
+}
+
+// reconstructWithout rebuilds the given shards from the others and reports whether
+// the result is a consistent codeword. The input shards are not modified
+func (e *Encoder) reconstructWithout(shards [][]byte, indices []int) ([][]byte, bool) {
+	trial := make([][]byte, len(shards))
+	copy(trial, shards)
+	for _, i := range indices {
+		trial[i] = nil
+	}
+
+	if err := e.encoder.Reconstruct(trial); err != nil {
+		return nil, false
+	}
+	ok, err := e.encoder.Verify(trial)
+	return trial, err == nil && ok
+}
+
+// forEachCombination calls fn with every k-element subset of 0..n-1 in
+// lexicographic order until fn returns true
+func forEachCombination(n, k int, fn func([]int) bool) {
+	indices := make([]int, k)
+	for i := range indices {
+		indices[i] = i
+	}
+
+	for {
+		if fn(indices) {
+			return
+		}
+
+		// Advance to the next combination
+		i := k - 1
+		for i >= 0 && indices[i] == n-k+i {
+			i--
+		}
+		if i < 0 {
+			return
+		}
+		indices[i]++
+		for j := i + 1; j < k; j++ {
+			indices[j] = indices[j-1] + 1
+		}
+	}
+}
+
+// splitEncodedData splits encoded data into shards that are views into it, each
+// capped at its own region so appending to one cannot spill into the next
 func (e *Encoder) splitEncodedData(data []byte) [][]byte {
 	totalShards := e.dataShards + e.parityShards
 	shardSize := len(data) / totalShards
@@ -100,28 +325,12 @@ func (e *Encoder) splitEncodedData(data []byte) [][]byte {
 	for i := range shards {
 		start := i * shardSize
 		end := (i + 1) * shardSize
-		shards[i] = data[start:end]
+		shards[i] = data[start:end:end]
 	}
 
 	return shards
 }
 
-// combineShards combines all shards into a single byte slice
-func (e *Encoder) combineShards(shards [][]byte) []byte {
-	if len(shards) == 0 {
-		return nil
-	}
-
-	shardSize := len(shards[0])
-	result := make([]byte, shardSize*len(shards))
-
-	for i, shard := range shards {
-		copy(result[i*shardSize:], shard)
-	}
-
-	return result
-}
-
 // extractData extracts the original data from the data shards
 func (e *Encoder) extractData(shards [][]byte) ([]byte, error) {
 	if len(shards) < e.dataShards {
@@ -1,6 +1,7 @@
 package infrastructure
 
 import (
+	"bytes"
 	"fmt"
 
 	"github.com/hambosto/hexwarden/internal/constants"
@@ -10,29 +11,78 @@ import (
 	"github.com/hambosto/hexwarden/internal/infrastructure/utils"
 )
 
+// Worst-case expansion of each processing stage, used to bound output sizes
+const (
+	gzipOverhead        = 18      // gzip header and trailer
+	storedBlockOverhead = 5       // deflate header per stored block
+	storedBlockSize     = 16384   // smallest stored block the deflate writer emits
+	aeadOverhead        = 12 + 16 // Nonce and tag of AES-GCM and ChaCha20-Poly1305; XChaCha20-Poly1305 nonces are 12 bytes longer
+)
+
+// ChunkEncoder adds Reed-Solomon parity to a chunk and decodes or checks it again
+type ChunkEncoder interface {
+	Encode(data []byte) ([]byte, error)
+	Decode(encoded []byte) ([]byte, error)
+	DecodeVerbose(encoded []byte) ([]byte, encoding.DecodeReport, error)
+	SetReconstruct(enabled bool)
+}
+
 // Processor handles encryption/decryption operations with compression, padding, and encoding
 type Processor struct {
-	cipher     *crypto.AESCipher
-	encoder    *encoding.Encoder
+	cipher     crypto.Cipher       // nil when chunks are only protected by Reed-Solomon
+	aad        []byte              // Associated data every chunk is bound to, nil for none
+	nonces     *crypto.ChunkNonces // Counter nonces EncryptChunk seals under, nil for random ones
+	encoder    ChunkEncoder
 	compressor *compression.Compressor
 	padder     *utils.Padder
+	paranoid   bool // Decode every encoded chunk again before returning it
 }
 
 // NewProcessor creates a new processor with the provided encryption key
 func NewProcessor(key []byte) (*Processor, error) {
+	return NewProcessorWithCipher(key, constants.CipherAESGCM)
+}
+
+// NewProcessorWithCipher creates a processor that encrypts with the given cipher
+func NewProcessorWithCipher(key []byte, alg constants.CipherAlgorithm) (*Processor, error) {
+	return NewProcessorWithAAD(key, alg, nil)
+}
+
+// NewProcessorWithAAD creates a processor that encrypts with the given cipher and
+// binds every chunk to aad, so chunks only decrypt with the same aad
+func NewProcessorWithAAD(key []byte, alg constants.CipherAlgorithm, aad []byte) (*Processor, error) {
 	if len(key) < constants.KeySize {
 		return nil, fmt.Errorf("encryption key must be at least %d bytes long", constants.KeySize)
 	}
 
-	cipher, err := crypto.NewAESCipher(key[:constants.KeySize])
+	cipher, err := crypto.NewCipher(alg, key[:constants.KeySize])
 	if err != nil {
 		return nil, fmt.Errorf("failed to create cipher: %w", err)
 	}
+	processor, err := newProcessor(cipher)
+	if err != nil {
+		return nil, err
+	}
+	processor.aad = aad
+	return processor, nil
+}
 
+// NewUnencryptedProcessor creates a processor that compresses, pads and encodes
+// without encrypting, for bodies protected only by Reed-Solomon
+func NewUnencryptedProcessor() (*Processor, error) {
+	return newProcessor(nil)
+}
+
+// newProcessor creates a processor around cipher, which may be nil
+func newProcessor(cipher crypto.Cipher) (*Processor, error) {
 	encoder, err := encoding.NewDefaultEncoder()
 	if err != nil {
 		return nil, fmt.Errorf("failed to create encoder: %w", err)
 	}
+	// Decryption copies the decoded chunk out, so it can be a view into the input
+	encoder.SetZeroCopy(cipher != nil)
+	// Without authentication the parity is all that catches corruption, so it is repaired
+	encoder.SetRepair(cipher == nil)
 
 	compressor, err := compression.NewDefaultCompressor()
 	if err != nil {
@@ -52,8 +102,58 @@ func NewProcessor(key []byte) (*Processor, error) {
 	}, nil
 }
 
-// Encrypt compresses, pads, encrypts, and encodes the input data
+// SetReconstruct selects whether chunks that fail Reed-Solomon verification are
+// still decoded, the default, or rejected
+func (p *Processor) SetReconstruct(enabled bool) {
+	p.encoder.SetReconstruct(enabled)
+}
+
+// SetCompression selects the format chunks are compressed in. Raw deflate drops
+// the gzip header and CRC, leaving chunk integrity to the cipher
+func (p *Processor) SetCompression(format constants.CompressionFormat) error {
+	compressor, err := compression.NewCompressorWithFormat(constants.LevelDefaultCompression, format)
+	if err != nil {
+		return fmt.Errorf("failed to create compressor: %w", err)
+	}
+	p.compressor = compressor
+	return nil
+}
+
+// SetEncoder replaces the Reed-Solomon encoder, which must use the same shard counts
+func (p *Processor) SetEncoder(encoder ChunkEncoder) {
+	p.encoder = encoder
+}
+
+// SetParanoid selects whether Encrypt decodes each chunk it encodes and fails with
+// ErrSelfCheckFailed unless the shards pass verification and give back the input.
+// It roughly doubles the Reed-Solomon work but no undecodable chunk is returned
+func (p *Processor) SetParanoid(enabled bool) {
+	p.paranoid = enabled
+}
+
+// SetNonces makes EncryptChunk seal each chunk under the counter nonce of its
+// index instead of a random one. Nil restores random nonces
+func (p *Processor) SetNonces(nonces *crypto.ChunkNonces) {
+	p.nonces = nonces
+}
+
+// Encrypt compresses, pads, encrypts, and encodes the input data under a random nonce
 func (p *Processor) Encrypt(data []byte) ([]byte, error) {
+	return p.encrypt(data, nil)
+}
+
+// EncryptChunk encrypts data like Encrypt as chunk index of the stream, sealing
+// it under that chunk's counter nonce when SetNonces was given any
+func (p *Processor) EncryptChunk(data []byte, index uint64) ([]byte, error) {
+	if p.nonces == nil || p.cipher == nil {
+		return p.encrypt(data, nil)
+	}
+	return p.encrypt(data, p.nonces.Nonce(index, p.cipher.NonceSize()))
+}
+
+// encrypt compresses, pads, encrypts under nonce, or a random nonce when it is
+// nil, and encodes data
+func (p *Processor) encrypt(data, nonce []byte) ([]byte, error) {
 	// Step 1: Compress the data
 	compressed, err := p.compressor.Compress(data)
 	if err != nil {
@@ -67,9 +167,16 @@ func (p *Processor) Encrypt(data []byte) ([]byte, error) {
 	}
 
 	// Step 3: Encrypt the padded data
-	encrypted, err := p.cipher.Encrypt(padded)
-	if err != nil {
-		return nil, fmt.Errorf("encryption failed: %w", err)
+	encrypted := padded
+	if p.cipher != nil {
+		if nonce != nil {
+			encrypted, err = p.cipher.EncryptWithNonce(nonce, padded, p.aad)
+		} else {
+			encrypted, err = p.cipher.EncryptWithAAD(padded, p.aad)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("encryption failed: %w", err)
+		}
 	}
 
 	// Step 4: Encode the encrypted data with Reed-Solomon
@@ -78,21 +185,74 @@ func (p *Processor) Encrypt(data []byte) ([]byte, error) {
 		return nil, fmt.Errorf("encoding failed: %w", err)
 	}
 
+	// Step 5: Optionally confirm the shards decode back to the encrypted data
+	if p.paranoid {
+		if err := p.checkEncoded(encrypted, encoded); err != nil {
+			return nil, err
+		}
+	}
+
 	return encoded, nil
 }
 
+// checkEncoded decodes encoded and confirms it holds exactly data without any
+// repair. The data shards are zero-filled past the end of data
+func (p *Processor) checkEncoded(data, encoded []byte) error {
+	decoded, report, err := p.encoder.DecodeVerbose(encoded)
+	if err != nil {
+		return fmt.Errorf("%w: %v", constants.ErrSelfCheckFailed, err)
+	}
+	if report.Damaged() {
+		return fmt.Errorf("%w: shards %v fail parity verification", constants.ErrSelfCheckFailed, report.Corrupted)
+	}
+	if len(decoded) < len(data) || !bytes.Equal(decoded[:len(data)], data) {
+		return fmt.Errorf("%w: decoded data differs from the input", constants.ErrSelfCheckFailed)
+	}
+	for _, b := range decoded[len(data):] {
+		if b != 0 {
+			return fmt.Errorf("%w: decoded data differs from the input", constants.ErrSelfCheckFailed)
+		}
+	}
+	return nil
+}
+
 // Decrypt decodes, decrypts, unpads, and decompresses the input data
 func (p *Processor) Decrypt(data []byte) ([]byte, error) {
+	return p.DecryptSized(data, 0)
+}
+
+// DecryptSized decrypts like Decrypt, allocating size bytes up front for the
+// decompressed output, the plaintext length of the chunk when it is known
+func (p *Processor) DecryptSized(data []byte, size int) ([]byte, error) {
 	// Step 1: Decode the Reed-Solomon encoded data
 	decoded, err := p.encoder.Decode(data)
 	if err != nil {
 		return nil, fmt.Errorf("decoding failed: %w", err)
 	}
+	return p.open(decoded, size)
+}
 
-	// Step 2: Decrypt the decoded data
-	decrypted, err := p.cipher.Decrypt(decoded)
+// DecryptVerbose decrypts like Decrypt and also reports which Reed-Solomon shards disagree with the parity
+func (p *Processor) DecryptVerbose(data []byte) ([]byte, encoding.DecodeReport, error) {
+	// Step 1: Decode the Reed-Solomon encoded data
+	decoded, report, err := p.encoder.DecodeVerbose(data)
 	if err != nil {
-		return nil, fmt.Errorf("decryption failed: %w", err)
+		return nil, report, fmt.Errorf("decoding failed: %w", err)
+	}
+	decrypted, err := p.open(decoded, 0)
+	return decrypted, report, err
+}
+
+// open decrypts, unpads and decompresses decoded chunk data, sizing the output
+// for size bytes when it is set
+func (p *Processor) open(decoded []byte, size int) ([]byte, error) {
+	// Step 2: Decrypt the decoded data
+	decrypted := decoded
+	if p.cipher != nil {
+		var err error
+		if decrypted, err = p.cipher.DecryptWithAAD(decoded, p.aad); err != nil {
+			return nil, fmt.Errorf("decryption failed: %w", err)
+		}
 	}
 
 	// Step 3: Remove padding from decrypted data
@@ -102,10 +262,32 @@ func (p *Processor) Decrypt(data []byte) ([]byte, error) {
 	}
 
 	// Step 4: Decompress the unpadded data
-	decompressed, err := p.compressor.Decompress(unpadded)
+	decompressed, err := p.compressor.DecompressSized(unpadded, size)
 	if err != nil {
 		return nil, fmt.Errorf("decompression failed: %w", err)
 	}
 
 	return decompressed, nil
 }
+
+// MaxEncryptedSize returns an upper bound on the bytes Encrypt produces for n input bytes,
+// assuming the input does not compress at all
+func MaxEncryptedSize(n int) int {
+	return MaxEncryptedSizeWithShards(n, constants.DataShards, constants.ParityShards)
+}
+
+// MaxEncryptedSizeWithShards bounds the output like MaxEncryptedSize for a
+// Reed-Solomon code with the given numbers of data and parity shards
+func MaxEncryptedSizeWithShards(n, dataShards, parityShards int) int {
+	if n <= 0 {
+		return 0
+	}
+
+	// One stored block per storedBlockSize bytes, plus the empty final block Close writes
+	compressed := n + gzipOverhead + storedBlockOverhead*(n/storedBlockSize+2)
+	padded := (compressed/constants.PaddingSize + 1) * constants.PaddingSize
+	encrypted := padded + aeadOverhead
+	shardSize := (encrypted + dataShards - 1) / dataShards
+
+	return shardSize * (dataShards + parityShards)
+}
@@ -1,6 +1,21 @@
 package utils
 
-import "fmt"
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hambosto/hexwarden/internal/constants"
+)
+
+// sinceLayouts lists the absolute timestamp formats accepted by ParseSince and ParseUntil
+var sinceLayouts = []string{
+	time.RFC3339,
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05",
+	"2006-01-02",
+}
 
 // FormatBytes formats bytes into human-readable format
 func FormatBytes(bytes int64) string {
@@ -18,6 +33,12 @@ func FormatBytes(bytes int64) string {
 	return fmt.Sprintf("%.1f %cB", float64(bytes)/float64(div), "KMGTPE"[exp])
 }
 
+// FormatTimestamp formats t for display in the local time zone. Times are stored
+// in UTC; only their display depends on where they are read
+func FormatTimestamp(t time.Time) string {
+	return t.Local().Format(time.RFC3339)
+}
+
 // MinInt64 returns the minimum of two int64 values
 func MinInt64(a, b int64) int64 {
 	if a < b {
@@ -33,3 +54,46 @@ func MinInt(a, b int) int {
 	}
 	return b
 }
+
+// ParseSince converts a cutoff given either as an absolute timestamp (e.g. 2024-01-01,
+// 2024-01-01T15:04:05 or RFC 3339) or as a duration relative to now (e.g. 24h, 90m, 7d)
+// into an absolute time. Timestamps without a zone are interpreted in local time
+func ParseSince(value string, now time.Time) (time.Time, error) {
+	return parseTimeOrOffset(value, now, -1)
+}
+
+// ParseUntil is ParseSince looking forward: durations are added to now, so 7d
+// means a week from now. Absolute timestamps are accepted in the same formats
+func ParseUntil(value string, now time.Time) (time.Time, error) {
+	return parseTimeOrOffset(value, now, 1)
+}
+
+// parseTimeOrOffset parses an absolute timestamp, or a duration moved from now in
+// the direction of sign
+func parseTimeOrOffset(value string, now time.Time, sign int) (time.Time, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return time.Time{}, fmt.Errorf("%w: empty value", constants.ErrInvalidTimeFilter)
+	}
+
+	for _, layout := range sinceLayouts {
+		if t, err := time.ParseInLocation(layout, value, time.Local); err == nil {
+			return t, nil
+		}
+	}
+
+	// Support a day suffix, which time.ParseDuration does not understand
+	if days, ok := strings.CutSuffix(value, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil || n < 0 {
+			return time.Time{}, fmt.Errorf("%w: %q", constants.ErrInvalidTimeFilter, value)
+		}
+		return now.AddDate(0, 0, sign*n), nil
+	}
+
+	d, err := time.ParseDuration(value)
+	if err != nil || d < 0 {
+		return time.Time{}, fmt.Errorf("%w: %q", constants.ErrInvalidTimeFilter, value)
+	}
+	return now.Add(time.Duration(sign) * d), nil
+}
@@ -0,0 +1,29 @@
+package utils
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/hambosto/hexwarden/internal/constants"
+)
+
+// ValidateName checks that a name read from an encrypted file, such as a stored
+// filename or bundle member, is a single path element. Absolute paths, ".."
+// and separators of any platform are rejected, so the name cannot escape the
+// directory it is written to
+func ValidateName(name string) error {
+	if name == "" || name == "." || name == ".." || strings.ContainsAny(name, "/\\\x00") || !filepath.IsLocal(name) {
+		return fmt.Errorf("%w: %q", constants.ErrUnsafePath, name)
+	}
+	return nil
+}
+
+// SafeJoin joins root and a name read from an encrypted file, refusing any name
+// that would place the result outside root
+func SafeJoin(root, name string) (string, error) {
+	if err := ValidateName(name); err != nil {
+		return "", err
+	}
+	return filepath.Join(root, name), nil
+}
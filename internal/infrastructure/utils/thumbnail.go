@@ -0,0 +1,83 @@
+package utils
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"io"
+
+	// Register the decoders for the image formats thumbnails can be made from
+	_ "image/gif"
+	_ "image/png"
+
+	"github.com/hambosto/hexwarden/internal/constants"
+)
+
+// Thumbnail decodes a JPEG, PNG or GIF image and returns a JPEG no larger than
+// maxDim pixels on either side, keeping the aspect ratio. Inputs that are not
+// images fail with ErrNotAnImage
+func Thumbnail(r io.Reader, maxDim int) ([]byte, error) {
+	if maxDim <= 0 {
+		return nil, fmt.Errorf("thumbnail size must be positive: %d", maxDim)
+	}
+
+	// Check the dimensions first so huge images are not decoded needlessly
+	var header bytes.Buffer
+	config, _, err := image.DecodeConfig(io.TeeReader(r, &header))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", constants.ErrNotAnImage, err)
+	}
+	if config.Width*config.Height > constants.MaxThumbnailSourcePixels {
+		return nil, fmt.Errorf("%w: %dx%d exceeds the thumbnail source limit", constants.ErrNotAnImage, config.Width, config.Height)
+	}
+
+	src, _, err := image.Decode(io.MultiReader(&header, r))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", constants.ErrNotAnImage, err)
+	}
+
+	var out bytes.Buffer
+	if err := jpeg.Encode(&out, downscale(src, maxDim), &jpeg.Options{Quality: constants.ThumbnailQuality}); err != nil {
+		return nil, fmt.Errorf("failed to encode thumbnail: %w", err)
+	}
+	return out.Bytes(), nil
+}
+
+// downscale shrinks img to fit within maxDim by averaging the source pixels
+// that fall into each destination pixel. Smaller images are returned as is
+func downscale(img image.Image, maxDim int) image.Image {
+	bounds := img.Bounds()
+	srcW, srcH := bounds.Dx(), bounds.Dy()
+	if srcW <= maxDim && srcH <= maxDim {
+		return img
+	}
+
+	dstW, dstH := maxDim, maxDim
+	if srcW > srcH {
+		dstH = max(1, srcH*maxDim/srcW)
+	} else {
+		dstW = max(1, srcW*maxDim/srcH)
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, dstW, dstH))
+	for y := 0; y < dstH; y++ {
+		y0, y1 := y*srcH/dstH, max((y+1)*srcH/dstH, y*srcH/dstH+1)
+		for x := 0; x < dstW; x++ {
+			x0, x1 := x*srcW/dstW, max((x+1)*srcW/dstW, x*srcW/dstW+1)
+
+			var r, g, b, a, n uint64
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					pr, pg, pb, pa := img.At(bounds.Min.X+sx, bounds.Min.Y+sy).RGBA()
+					r, g, b, a, n = r+uint64(pr), g+uint64(pg), b+uint64(pb), a+uint64(pa), n+1
+				}
+			}
+			dst.Set(x, y, color.RGBA64{
+				R: uint16(r / n), G: uint16(g / n), B: uint16(b / n), A: uint16(a / n),
+			})
+		}
+	}
+	return dst
+}
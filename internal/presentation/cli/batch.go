@@ -0,0 +1,72 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/hambosto/hexwarden/internal/constants"
+)
+
+// FileError records why one file of a batch failed
+type FileError struct {
+	Path string
+	Err  error
+}
+
+func (e *FileError) Error() string {
+	return fmt.Sprintf("%s: %v", e.Path, e.Err)
+}
+
+func (e *FileError) Unwrap() error {
+	return e.Err
+}
+
+// BatchError summarizes the files of a recursive or listed batch that failed.
+// errors.Is and errors.As see through it to each file's error
+type BatchError struct {
+	Mode     constants.ProcessorMode
+	Total    int // Files in the batch, including those that succeeded or were skipped
+	Failures []*FileError
+}
+
+func (e *BatchError) Error() string {
+	return fmt.Sprintf("%d file(s) failed to %s", len(e.Failures), strings.ToLower(string(e.Mode)))
+}
+
+func (e *BatchError) Unwrap() []error {
+	errs := make([]error, len(e.Failures))
+	for i, failure := range e.Failures {
+		errs[i] = failure
+	}
+	return errs
+}
+
+// add records that path failed with err
+func (e *BatchError) add(path string, err error) {
+	e.Failures = append(e.Failures, &FileError{Path: path, Err: err})
+}
+
+// err returns e when any file failed, otherwise nil
+func (e *BatchError) err() error {
+	if len(e.Failures) == 0 {
+		return nil
+	}
+	return e
+}
+
+// printTable writes the failed files and their errors as an aligned table
+func (e *BatchError) printTable(w io.Writer) {
+	if len(e.Failures) == 0 {
+		return
+	}
+
+	fmt.Fprintln(w, "Failed files:")
+	table := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(table, "  FILE\tERROR")
+	for _, failure := range e.Failures {
+		fmt.Fprintf(table, "  %s\t%v\n", failure.Path, failure.Err)
+	}
+	_ = table.Flush()
+}
@@ -2,17 +2,35 @@ package cli
 
 import (
 	"fmt"
+	"io"
+	"log/slog"
 	"os"
+	"path/filepath"
+	"strings"
+	"time"
 
 	"github.com/spf13/cobra"
 
 	"github.com/hambosto/hexwarden/internal/constants"
+	"github.com/hambosto/hexwarden/internal/data/files"
+	"github.com/hambosto/hexwarden/internal/data/streaming"
+	"github.com/hambosto/hexwarden/internal/infrastructure/compression"
+	"github.com/hambosto/hexwarden/internal/infrastructure/crypto"
+	"github.com/hambosto/hexwarden/internal/infrastructure/utils"
 	"github.com/hambosto/hexwarden/internal/presentation/interactive"
+	"github.com/hambosto/hexwarden/internal/presentation/ui"
+	"github.com/hambosto/hexwarden/internal/usecase/operations"
 )
 
 // CLI represents the command-line interface
 type CLI struct {
-	rootCmd *cobra.Command
+	rootCmd       *cobra.Command
+	logger        *slog.Logger  // Stage timings for --verbose; nil logs nothing
+	ioTimeout     time.Duration // Idle limit for --io-timeout; 0 waits forever
+	failOnWarning bool          // --fail-on-warning turns reported warnings into an error
+	showPassword  bool          // --show-password echoes interactive password prompts
+	dryRun        bool          // --dry-run shows the interactive plan without acting on it
+	processor     *CLIProcessor // Last processor created, whose statistics --stats prints and whose warnings are checked
 }
 
 // NewCLI creates a new CLI instance
@@ -39,48 +57,270 @@ It supports both interactive mode (default) and command-line mode for automation
 		Version: constants.AppVersion,
 		Run: func(cmd *cobra.Command, args []string) {
 			// Default behavior: run interactive mode
-			interactiveApp := interactive.NewInteractiveApp()
-			interactiveApp.Run()
+			c.runInteractive()
 		},
 	}
+	c.rootCmd.Flags().BoolVar(&c.showPassword, "show-password", false, "Show passwords as they are typed in interactive mode instead of masking them")
+	c.rootCmd.Flags().BoolVar(&c.dryRun, "dry-run", false, "Show the interactive plan for the chosen files without encrypting, decrypting or deleting anything")
+
+	var (
+		noProgress bool
+		verbose    bool
+		tempDir    string
+		configPath string
+		showStats  bool
+		statsJSON  bool
+		cpuLimit   int
+	)
+	c.rootCmd.PersistentFlags().BoolVar(&noProgress, "no-progress", false, "Do not draw progress bars or spinners (they are only drawn when standard error is a terminal)")
+	c.rootCmd.PersistentFlags().BoolVar(&verbose, "verbose", false, "Log the timing of each pipeline stage to standard error")
+	c.rootCmd.PersistentFlags().StringVar(&tempDir, "temp-dir", "", "Directory for temporary files (default $TMPDIR, or next to the file being replaced)")
+	c.rootCmd.PersistentFlags().StringVar(&configPath, "config", "", "Read option defaults from this YAML or TOML file (default: "+constants.ConfigFileName+".yaml, .yml or .toml in the working or home directory)")
+	c.rootCmd.PersistentFlags().BoolVar(&showStats, "stats", false, "Print worker pool statistics to standard error after encrypting or decrypting")
+	c.rootCmd.PersistentFlags().BoolVar(&statsJSON, "json", false, "Print the --stats statistics as JSON (implies --stats)")
+	c.rootCmd.PersistentFlags().BoolVar(&c.failOnWarning, "fail-on-warning", false, "Exit with an error after finishing when any warning was printed, such as a failed source deletion or a weak password")
+	c.rootCmd.PersistentFlags().DurationVar(&c.ioTimeout, "io-timeout", 0, "Abort when no data is read or written for this long, e.g. 30s (default: wait forever)")
+	c.rootCmd.PersistentFlags().IntVar(&cpuLimit, "cpu-percent", 0, "Throttle the encryption workers to keep their average CPU use under this percentage of the available cores, 1-100 (default: no limit)")
+	c.rootCmd.PersistentPreRunE = func(cmd *cobra.Command, args []string) error {
+		if err := applyConfig(cmd, configPath); err != nil {
+			return err
+		}
+		if noProgress {
+			ui.SetProgressEnabled(false)
+		}
+		if verbose {
+			c.logger = slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelDebug}))
+		}
+		if c.ioTimeout < 0 {
+			return fmt.Errorf("--io-timeout must not be negative")
+		}
+		if cpuLimit < 0 || cpuLimit > 100 {
+			return fmt.Errorf("--cpu-percent must be between 1 and 100")
+		}
+		streaming.SetCPUPercent(cpuLimit)
+		return files.SetTempDir(tempDir)
+	}
+	c.rootCmd.PersistentPostRunE = func(cmd *cobra.Command, args []string) error {
+		if c.processor == nil {
+			return nil
+		}
+		if showStats || statsJSON {
+			if err := c.processor.PrintStats(os.Stderr, statsJSON); err != nil {
+				return err
+			}
+		}
+		return c.processor.CheckWarnings()
+	}
 
 	// Add subcommands
 	c.rootCmd.AddCommand(c.createEncryptCommand())
 	c.rootCmd.AddCommand(c.createDecryptCommand())
+	c.rootCmd.AddCommand(c.createInfoCommand())
+	c.rootCmd.AddCommand(c.createThumbnailCommand())
+	c.rootCmd.AddCommand(c.createSetNameCommand())
+	c.rootCmd.AddCommand(c.createStripMetadataCommand())
+	c.rootCmd.AddCommand(c.createAddKeyCommand())
+	c.rootCmd.AddCommand(c.createRemoveKeyCommand())
+	c.rootCmd.AddCommand(c.createRecompressCommand())
+	c.rootCmd.AddCommand(c.createProtectCommand())
+	c.rootCmd.AddCommand(c.createRecoverCommand())
+	c.rootCmd.AddCommand(c.createAppendCommand())
+	c.rootCmd.AddCommand(c.createScanCommand())
+	c.rootCmd.AddCommand(c.createVerifyCommand())
+	c.rootCmd.AddCommand(c.createMatchCommand())
+	c.rootCmd.AddCommand(c.createKDFBenchCommand())
+	c.rootCmd.AddCommand(c.createSelftestVectorsCommand())
+	c.rootCmd.AddCommand(c.createVersionCommand())
 	c.rootCmd.AddCommand(c.createInteractiveCommand())
 }
 
+// applyConfig fills the flags of cmd not given on the command line from the
+// configuration file at path, or from the default one when path is empty
+func applyConfig(cmd *cobra.Command, path string) error {
+	if path == "" {
+		if path = FindConfig(); path == "" {
+			return nil
+		}
+	}
+
+	config, err := LoadConfig(path)
+	if err != nil {
+		return err
+	}
+	return config.Apply(cmd)
+}
+
 // createEncryptCommand creates the encrypt subcommand
 func (c *CLI) createEncryptCommand() *cobra.Command {
 	var (
-		inputFile    string
-		outputFile   string
-		password     string
-		deleteSource bool
-		secureDelete bool
+		opts              Options
+		since             string
+		headerHash        string
+		cipher            string
+		argon2Variant     string
+		compressionFormat string
+		noCompression     bool
+		notBefore         string
+		deletePattern     string
+		escrowPublicKey   string
+		acknowledgeRisk   bool
+		nonceStrategy     string
+		sortOrder         string
 	)
 
 	cmd := &cobra.Command{
-		Use:   "encrypt [flags]",
+		Use:   "encrypt [flags] [--bundle files...]",
 		Short: "Encrypt a file",
 		Long:  "Encrypt a file using AES-256-GCM with Reed-Solomon error correction",
 		Example: `  hexwarden encrypt -i document.txt -o document.txt.hex
   hexwarden encrypt -i document.txt -p mypassword --delete-source
-  hexwarden encrypt -i document.txt --secure-delete`,
+  hexwarden encrypt -i backup.tar --durable --delete-source
+  hexwarden encrypt -i document.txt --secure-delete
+  hexwarden encrypt -i document.txt --delete-source --secure-delete-pattern dod
+  hexwarden encrypt -i q3.xlsx --comment "Q3 financials, backup copy"
+  hexwarden encrypt -i photo.jpg --thumbnail
+  hexwarden encrypt -i report.pdf -o a1b2c3.hex --store-name
+  hexwarden encrypt --bundle a.txt b.txt -o bundle.hex
+  hexwarden encrypt -i archive.tar --merkle-tree archive.tar.hex.merkle
+  hexwarden encrypt -i archive.tar --manifest archive.tar.hex.manifest
+  hexwarden encrypt -i archive.tar --hash-original
+  hexwarden encrypt -i video.mp4 --no-compression
+  hexwarden encrypt -i announcement.pdf --not-before 2025-03-01T09:00:00Z
+  hexwarden encrypt -i document.txt --min-password-length 12
+  hexwarden encrypt -i vault.kdbx --argon2-variant i
+  echo "$PASSWORD" | hexwarden encrypt -i document.txt
+  hexwarden encrypt -i backup.tar --output-url https://dav.example.com/backup.tar.hex
+  hexwarden encrypt -r ./backups --since 24h
+  hexwarden encrypt -r ./backups --estimate
+  hexwarden encrypt -r ./backups --dedup
+  hexwarden encrypt -r ./backups --sort size
+  hexwarden encrypt -r ./photos --out-dir /mnt/vault --flatten
+  hexwarden encrypt -i archive.tar --recovery-code
+  hexwarden encrypt -i shared.txt -p mine --add-password theirs
+  hexwarden encrypt -r ./logs --nonce-strategy counter --nonce-state ~/.hexwarden-nonce
+  hexwarden encrypt -i ledger.db --export-key-to ledger.db.escrow --escrow-public-key escrow.pub --i-understand-the-risk
+  find . -name '*.log' | hexwarden encrypt --files-from - -p mypassword`,
 		RunE: func(cmd *cobra.Command, args []string) error {
-			return c.runEncrypt(inputFile, outputFile, password, deleteSource, secureDelete)
+			if len(args) > 0 && !opts.Bundle {
+				return fmt.Errorf("unexpected arguments %q; files are only listed after --bundle", args)
+			}
+			opts.BundleInputs = args
+			if err := opts.parseSince(since); err != nil {
+				return err
+			}
+			if err := opts.parseHeaderHash(headerHash); err != nil {
+				return err
+			}
+			if err := opts.parseCipher(cipher); err != nil {
+				return err
+			}
+			if err := opts.parseArgon2Variant(argon2Variant); err != nil {
+				return err
+			}
+			if noCompression {
+				compressionFormat = "none"
+			}
+			if err := opts.parseCompression(compressionFormat); err != nil {
+				return err
+			}
+			if err := opts.parseNotBefore(notBefore); err != nil {
+				return err
+			}
+			if err := opts.parseDeletePattern(deletePattern, cmd.Flags().Changed("secure-delete-pattern")); err != nil {
+				return err
+			}
+			if err := opts.parseEscrow(escrowPublicKey, acknowledgeRisk); err != nil {
+				return err
+			}
+			if err := opts.parseNonceStrategy(nonceStrategy); err != nil {
+				return err
+			}
+			if err := opts.parseSort(sortOrder); err != nil {
+				return err
+			}
+			return c.runEncrypt(opts)
 		},
 	}
 
-	cmd.Flags().StringVarP(&inputFile, "input", "i", "", "Input file to encrypt (required)")
-	cmd.Flags().StringVarP(&outputFile, "output", "o", "", "Output encrypted file (default: input + .hex)")
-	cmd.Flags().StringVarP(&password, "password", "p", "", "Encryption password (will prompt if not provided)")
-	cmd.Flags().BoolVar(&deleteSource, "delete-source", false, "Delete source file after encryption")
-	cmd.Flags().BoolVar(&secureDelete, "secure-delete", false, "Use secure deletion (slower but unrecoverable)")
+	cmd.Flags().StringVarP(&opts.InputFile, "input", "i", "", "Input file to encrypt")
+	cmd.Flags().StringVarP(&opts.OutputFile, "output", "o", "", "Output encrypted file (default: input + .hex)")
+	cmd.Flags().StringVar(&opts.Comment, "comment", "", "Attach a comment that is stored encrypted and shown after decryption")
+	cmd.Flags().BoolVar(&opts.Thumbnail, "thumbnail", false, "Store an encrypted JPEG preview when the input is a PNG, JPEG or GIF image")
+	cmd.Flags().BoolVar(&opts.StoreName, "store-name", false, "Store the input file name encrypted in the header, for decrypt --restore-name")
+	cmd.Flags().StringVar(&opts.OutputURL, "output-url", "", "Upload the encrypted file to an http(s) URL with PUT instead of writing it locally")
+	cmd.Flags().StringVarP(&opts.Password, "password", "p", "", "Encryption password (will prompt if not provided, or read from piped stdin)")
+	cmd.Flags().StringVar(&opts.Keychain, "use-keychain", "", "Fetch the password from the OS keychain entry with this name, saving it there on first use")
+	cmd.Flags().BoolVar(&opts.NoConfirm, "no-confirm", false, "Do not ask to confirm a typed password")
+	cmd.Flags().IntVar(&opts.MinPasswordLength, "min-password-length", 0, "Refuse passwords shorter than this many characters")
+	cmd.Flags().Int64Var(&opts.SmallFileThreshold, "small-file-threshold", 0, "Skip compression and Reed-Solomon for files smaller than this many bytes, e.g. 4096 (0 disables)")
+	cmd.Flags().Int64Var(&opts.PadOutputTo, "pad-output-to", 0, "Fill every encrypted file with authenticated random filler up to exactly this many bytes (0 disables)")
+	cmd.Flags().StringVar(&headerHash, "header-hash", "sha256", "Header integrity hash algorithm: sha256 or blake2b")
+	cmd.Flags().StringVar(&cipher, "cipher", "auto", "Body cipher: aes-gcm, chacha20 (ChaCha20-Poly1305), xchacha20 (XChaCha20-Poly1305, 24-byte nonces), or auto to use AES-GCM only when the CPU has AES instructions")
+	cmd.Flags().Uint32Var(&opts.KDFTime, "kdf-time", 0, fmt.Sprintf("Argon2id passes (default %d, at most %d); see kdf-bench", constants.ArgonTime, constants.MaxArgonTime))
+	cmd.Flags().Uint32Var(&opts.KDFMemory, "kdf-memory", 0, fmt.Sprintf("Argon2id memory in KiB (default %d, at most %d); see kdf-bench", constants.ArgonMemory, constants.MaxArgonMemory))
+	cmd.Flags().StringVar(&argon2Variant, "argon2-variant", "id", "Argon2 variant: id (the default, balanced) or i (data-independent memory access against side channels); recorded in the header")
+	cmd.Flags().Uint8Var(&opts.KDFThreads, "kdf-threads", 0, fmt.Sprintf("Argon2id parallelism (default %d); see kdf-bench", constants.ArgonThreads))
+	cmd.Flags().BoolVar(&opts.Estimate, "estimate", false, "Report the total size, output size, key derivations and rough time without encrypting")
+	cmd.Flags().BoolVar(&opts.NoSpaceCheck, "no-space-check", false, "Skip the free disk space check before encrypting")
+	cmd.Flags().BoolVar(&opts.Durable, "durable", false, "Flush the output file and its directory entry to disk before reporting success")
+	cmd.Flags().BoolVar(&opts.Lock, "lock", false, "Hold advisory locks on the input and output files, failing fast if another process holds them")
+	cmd.Flags().BoolVar(&opts.DeleteSource, "delete-source", false, "Delete source file after encryption")
+	cmd.Flags().BoolVar(&opts.SecureDelete, "secure-delete", false, "Use secure deletion (slower but unrecoverable)")
+	cmd.Flags().StringVar(&deletePattern, "secure-delete-pattern", "random", "Secure deletion passes: random (3 random), dod (0x00, 0xFF, random) or zero (one zero pass); implies --secure-delete")
+	cmd.Flags().StringVarP(&opts.RecursiveDir, "recursive", "r", "", "Encrypt all eligible files under the given directory")
+	cmd.Flags().StringVar(&opts.FilesFrom, "files-from", "", "Encrypt the files listed one per line in this file, or - for standard input")
+	cmd.Flags().BoolVar(&opts.Index, "index", false, "With --recursive, give outputs random names at the top of the tree and record their paths in an encrypted index, for decrypt --with-index")
+	cmd.Flags().StringVar(&opts.OutDir, "out-dir", "", "With --recursive, write outputs under this directory, mirroring the source tree, instead of next to their inputs")
+	cmd.Flags().BoolVar(&opts.Flatten, "flatten", false, "With --out-dir, write every output directly into it, adding a short hash of the source path to names that collide")
+	cmd.Flags().BoolVar(&opts.Dedup, "dedup", false, "With --recursive or --files-from, store files whose content was already encrypted in the batch as small references to the first copy")
+	cmd.Flags().BoolVar(&opts.WithRecoveryCode, "recovery-code", false, "Also lock the output with a random recovery code, printed once, for decrypt --recovery-code if the password is lost")
+	cmd.Flags().BoolVar(&opts.CompressMetadata, "compress-metadata", false, "Deflate the header metadata block when it is large and that makes it smaller; not with --merkle or --hash-original")
+	cmd.Flags().StringArrayVar(&opts.ExtraPasswords, "add-password", nil, "Also let this password unlock the output through a key slot of its own; repeat for more")
+	cmd.Flags().StringVar(&opts.ExportKeyTo, "export-key-to", "", "Write the file key, wrapped to --escrow-public-key, to this file for key escrow; requires --i-understand-the-risk")
+	cmd.Flags().StringVar(&escrowPublicKey, "escrow-public-key", "", "PEM X25519 public key of the escrow holder, e.g. from openssl genpkey -algorithm X25519")
+	cmd.Flags().BoolVar(&acknowledgeRisk, "i-understand-the-risk", false, "Confirm that whoever holds the escrow private key can decrypt the output without the password")
+	cmd.Flags().StringVar(&nonceStrategy, "nonce-strategy", "random", "Nonces: random, or counter to take the header nonce from a counter persisted in --nonce-state and seal chunks under a chunk counter, for many files under one long-lived password")
+	cmd.Flags().StringVar(&opts.NonceState, "nonce-state", "", "State file of the nonce counter, created on first use; share it between runs and never copy it")
+	cmd.Flags().BoolVar(&opts.Bundle, "bundle", false, "Encrypt the files given as arguments into one bundle written to --output")
+	cmd.Flags().StringVar(&since, "since", "", "Only process files modified after a date (2024-01-01) or duration (24h, 7d); requires --recursive")
+	cmd.Flags().StringVar(&sortOrder, "sort", "", "With --recursive or --files-from, process files in a fixed order: name, size (smallest first) or mtime (oldest first)")
+	cmd.Flags().BoolVar(&opts.Merkle, "merkle", false, "Store the root of a Merkle tree over the encrypted chunks, for verify --chunk")
+	cmd.Flags().StringVar(&opts.MerkleTree, "merkle-tree", "", "Also write the full Merkle tree to this sidecar file (implies --merkle)")
+	cmd.Flags().StringVar(&opts.Manifest, "manifest", "", "Write the offset, length, CRC-32 and SHA-256 of every chunk to this JSON sidecar, for verify --manifest and external repair tools")
+	cmd.Flags().BoolVar(&opts.HashOriginal, "hash-original", false, "Store the SHA-256 of the input encrypted in the header, for decrypt --verify-hash")
+	cmd.Flags().StringVar(&opts.AAD, "aad", "", "Bind the encrypted body to this context string, e.g. a dataset or user id; decrypt must pass the same --aad")
+	cmd.Flags().StringVar(&compressionFormat, "compression", "gzip", "Chunk compression: gzip, deflate for bare deflate streams 18 bytes smaller per chunk, or none")
+	cmd.Flags().BoolVar(&noCompression, "no-compression", false, "Store chunks uncompressed, skipping deflate entirely; same as --compression none")
+	cmd.Flags().BoolVar(&opts.Paranoid, "paranoid", false, "Decode every chunk again right after Reed-Solomon encoding and fail unless it gives back the input; slower")
+	cmd.Flags().StringVar(&notBefore, "not-before", "", "Advisory time lock: decrypt refuses the file until this date (2025-03-01T09:00:00Z) or duration from now (72h, 7d)")
 
-	if err := cmd.MarkFlagRequired("input"); err != nil {
-		// This should not happen in normal circumstances
-		panic(fmt.Sprintf("failed to mark input flag as required: %v", err))
+	markInputFlags(cmd, "bundle")
+	for _, flag := range []string{"output-url", "thumbnail", "store-name", "estimate", "lock", "merkle", "merkle-tree", "manifest", "hash-original"} {
+		cmd.MarkFlagsMutuallyExclusive("bundle", flag)
+	}
+	for _, flag := range []string{"output-url", "recursive", "files-from"} {
+		cmd.MarkFlagsMutuallyExclusive("merkle-tree", flag)
+		cmd.MarkFlagsMutuallyExclusive("manifest", flag)
+	}
+	cmd.MarkFlagsMutuallyExclusive("compression", "no-compression")
+	cmd.MarkFlagsMutuallyExclusive("merkle", "output-url")
+	cmd.MarkFlagsMutuallyExclusive("hash-original", "output-url")
+	cmd.MarkFlagsMutuallyExclusive("output", "output-url")
+	cmd.MarkFlagsMutuallyExclusive("output-url", "recursive")
+	cmd.MarkFlagsMutuallyExclusive("output-url", "files-from")
+	cmd.MarkFlagsMutuallyExclusive("output-url", "durable")
+	for _, flag := range []string{"files-from", "store-name"} {
+		cmd.MarkFlagsMutuallyExclusive("index", flag)
+	}
+	for _, flag := range []string{"index", "bundle", "output-url"} {
+		cmd.MarkFlagsMutuallyExclusive("dedup", flag)
+	}
+	// The index already decides where outputs go
+	cmd.MarkFlagsMutuallyExclusive("out-dir", "index")
+	// An escrowed key belongs to a single output
+	cmd.MarkFlagsRequiredTogether("export-key-to", "escrow-public-key")
+	for _, flag := range []string{"bundle", "output-url", "recursive", "files-from"} {
+		cmd.MarkFlagsMutuallyExclusive("export-key-to", flag)
 	}
 
 	return cmd
@@ -89,11 +329,13 @@ func (c *CLI) createEncryptCommand() *cobra.Command {
 // createDecryptCommand creates the decrypt subcommand
 func (c *CLI) createDecryptCommand() *cobra.Command {
 	var (
-		inputFile    string
-		outputFile   string
-		password     string
-		deleteSource bool
-		secureDelete bool
+		opts          Options
+		since         string
+		byteRange     string
+		deletePattern string
+		escrowedKey   string
+		escrowPrivate string
+		sortOrder     string
 	)
 
 	cmd := &cobra.Command{
@@ -102,88 +344,826 @@ func (c *CLI) createDecryptCommand() *cobra.Command {
 		Long:  "Decrypt a file encrypted with HexWarden",
 		Example: `  hexwarden decrypt -i document.txt.hex -o document.txt
   hexwarden decrypt -i document.txt.hex -p mypassword
-  hexwarden decrypt -i document.txt.hex --delete-source`,
+  hexwarden decrypt -i backup.tar.hex -o - | tar -x
+  hexwarden decrypt -i a1b2c3.hex --restore-name
+  hexwarden decrypt -i bundle.hex --extract b.txt
+  hexwarden decrypt -i video.mp4.hex --range 0-1048575 -o - | head -c 64
+  hexwarden decrypt -i archive.tar.hex --verify-hash
+  hexwarden decrypt -i document.txt.hex --delete-source
+  hexwarden decrypt -i archive.tar.hex --use-keychain myarchive
+  hexwarden decrypt -i archive.tar.hex --recovery-code ABCD-EFGH-IJKL-MNOP-QRST-UVWX-YZ23-4567
+  hexwarden decrypt -i ledger.db.hex --escrowed-key ledger.db.escrow --escrow-private-key escrow.key
+  hexwarden decrypt -i archive.tar.hex --expect-sha256 3a7bd3e2360a3d29eea436fcfb7e44c735d117c42d1c1835420b6b9942dd4f1b
+  hexwarden decrypt -r ./backups --since 2024-01-01 --output-suffix .dec
+  hexwarden decrypt --files-from restore.txt --sort name`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := opts.parseSince(since); err != nil {
+				return err
+			}
+			if err := opts.parseRange(byteRange); err != nil {
+				return err
+			}
+			if err := opts.parseDeletePattern(deletePattern, cmd.Flags().Changed("secure-delete-pattern")); err != nil {
+				return err
+			}
+			if err := opts.parseEscrowUnlock(escrowedKey, escrowPrivate); err != nil {
+				return err
+			}
+			if err := opts.parseSort(sortOrder); err != nil {
+				return err
+			}
+			return c.runDecrypt(opts)
+		},
+	}
+
+	cmd.Flags().StringVarP(&opts.InputFile, "input", "i", "", "Input file to decrypt")
+	cmd.Flags().StringVarP(&opts.OutputFile, "output", "o", "", "Output decrypted file, or - for standard output (default: remove .hex extension)")
+	cmd.Flags().StringVar(&opts.OutputSuffix, "output-suffix", "", "Append this suffix to derived output names, e.g. .dec (default: none, or .dec when .hex cannot be removed)")
+	cmd.Flags().BoolVar(&opts.RestoreName, "restore-name", false, "Name the output after the file name stored with --store-name, next to the input")
+	cmd.Flags().StringVar(&opts.Extract, "extract", "", "Decrypt only the named member of a bundle (default output: the member name, next to the input)")
+	cmd.Flags().StringVar(&byteRange, "range", "", "Decrypt only plaintext bytes START-END (inclusive, counted from 0), e.g. 0-1048575; only the chunks holding them are decrypted")
+	cmd.Flags().StringVarP(&opts.Password, "password", "p", "", "Decryption password (will prompt if not provided, or read from piped stdin)")
+	cmd.Flags().StringVar(&opts.Keychain, "use-keychain", "", "Fetch the password from the OS keychain entry with this name, saving it there on first use")
+	cmd.Flags().IntVar(&opts.PasswordAttempts, "password-attempts", constants.PasswordAttempts, "Times a password typed at the terminal may be entered before giving up")
+	cmd.Flags().StringVar(&opts.ExpectSHA256, "expect-sha256", "", "Refuse to decrypt unless the input file has this hex SHA-256 digest")
+	cmd.Flags().BoolVar(&opts.VerifyHash, "verify-hash", false, "Check the output against the SHA-256 stored with encrypt --hash-original, failing on a mismatch")
+	cmd.Flags().BoolVar(&opts.IgnoreTimelock, "ignore-timelock", false, "Decrypt a file stored with encrypt --not-before even though its time has not come")
+	cmd.Flags().StringVar(&opts.AAD, "aad", "", "Context string the file was bound to with encrypt --aad")
+	cmd.Flags().BoolVar(&opts.IgnoreCorrupt, "ignore-corrupt-chunks", false, "Write zeros for chunks that cannot be decrypted instead of failing, and list the offsets of those holes")
+	cmd.Flags().BoolVar(&opts.Durable, "durable", false, "Flush the output file and its directory entry to disk before reporting success")
+	cmd.Flags().BoolVar(&opts.Lock, "lock", false, "Hold advisory locks on the input and output files, failing fast if another process holds them")
+	cmd.Flags().BoolVar(&opts.DeleteSource, "delete-source", false, "Delete source file after decryption")
+	cmd.Flags().BoolVar(&opts.SecureDelete, "secure-delete", false, "Use secure deletion (slower but unrecoverable)")
+	cmd.Flags().StringVar(&deletePattern, "secure-delete-pattern", "random", "Secure deletion passes: random (3 random), dod (0x00, 0xFF, random) or zero (one zero pass); implies --secure-delete")
+	cmd.Flags().StringVarP(&opts.RecursiveDir, "recursive", "r", "", "Decrypt all encrypted files under the given directory")
+	cmd.Flags().StringVar(&opts.FilesFrom, "files-from", "", "Decrypt the files listed one per line in this file, or - for standard input")
+	cmd.Flags().BoolVar(&opts.Index, "with-index", false, "With --recursive, restore the original tree from the index written by encrypt --index")
+	cmd.Flags().StringVar(&opts.RecoveryCode, "recovery-code", "", "Unlock with the recovery code printed by encrypt --recovery-code instead of the password")
+	cmd.Flags().StringVar(&escrowedKey, "escrowed-key", "", "Unlock with the key written by encrypt --export-key-to instead of the password; requires --escrow-private-key")
+	cmd.Flags().StringVar(&escrowPrivate, "escrow-private-key", "", "PEM X25519 private key of the escrow holder that opens --escrowed-key")
+	cmd.Flags().StringVar(&since, "since", "", "Only process files modified after a date (2024-01-01) or duration (24h, 7d); requires --recursive")
+	cmd.Flags().StringVar(&sortOrder, "sort", "", "With --recursive or --files-from, process files in a fixed order: name, size (smallest first) or mtime (oldest first)")
+
+	markInputFlags(cmd)
+	cmd.MarkFlagsMutuallyExclusive("output", "output-suffix")
+	cmd.MarkFlagsMutuallyExclusive("restore-name", "output")
+	cmd.MarkFlagsMutuallyExclusive("verify-hash", "extract")
+	cmd.MarkFlagsMutuallyExclusive("restore-name", "output-suffix")
+	cmd.MarkFlagsMutuallyExclusive("restore-name", "recursive")
+	cmd.MarkFlagsMutuallyExclusive("restore-name", "files-from")
+	for _, flag := range []string{"restore-name", "output-suffix", "recursive", "files-from"} {
+		cmd.MarkFlagsMutuallyExclusive("extract", flag)
+	}
+	cmd.MarkFlagsMutuallyExclusive("expect-sha256", "recursive")
+	cmd.MarkFlagsMutuallyExclusive("expect-sha256", "files-from")
+	for _, flag := range []string{"extract", "verify-hash", "delete-source", "recursive", "files-from"} {
+		cmd.MarkFlagsMutuallyExclusive("range", flag)
+	}
+	for _, flag := range []string{"files-from", "output-suffix", "since"} {
+		cmd.MarkFlagsMutuallyExclusive("with-index", flag)
+	}
+	// A salvaged output is incomplete, so the damaged original must be kept
+	for _, flag := range []string{"delete-source", "range", "extract"} {
+		cmd.MarkFlagsMutuallyExclusive("ignore-corrupt-chunks", flag)
+	}
+	// Updating the index needs the password it is sealed with
+	for _, flag := range []string{"password", "use-keychain", "with-index"} {
+		cmd.MarkFlagsMutuallyExclusive("recovery-code", flag)
+	}
+	// An escrowed key unlocks the single file it was exported for
+	cmd.MarkFlagsRequiredTogether("escrowed-key", "escrow-private-key")
+	for _, flag := range []string{"password", "use-keychain", "with-index", "recovery-code", "recursive", "files-from"} {
+		cmd.MarkFlagsMutuallyExclusive("escrowed-key", flag)
+	}
+
+	return cmd
+}
+
+// createInfoCommand creates the info subcommand
+func (c *CLI) createInfoCommand() *cobra.Command {
+	var inputFile, password string
+
+	cmd := &cobra.Command{
+		Use:   "info [flags]",
+		Short: "Show details of an encrypted file",
+		Long:  "Show the header details of an encrypted file. Encrypted fields such as the comment are shown when the password is given",
+		Example: `  hexwarden info -i document.txt.hex
+  hexwarden info -i document.txt.hex -p mypassword`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return c.newProcessor().Info(inputFile, password)
+		},
+	}
+
+	cmd.Flags().StringVarP(&inputFile, "input", "i", "", "Encrypted file to inspect")
+	cmd.Flags().StringVarP(&password, "password", "p", "", "Password to unlock encrypted fields")
+	_ = cmd.MarkFlagRequired("input")
+
+	return cmd
+}
+
+// createThumbnailCommand creates the thumbnail subcommand
+func (c *CLI) createThumbnailCommand() *cobra.Command {
+	var inputFile, outputFile, password string
+
+	cmd := &cobra.Command{
+		Use:   "thumbnail [flags]",
+		Short: "Extract the image preview of an encrypted file",
+		Long:  "Decrypt the JPEG preview stored with --thumbnail and write it to a file, without decrypting the body",
+		Example: `  hexwarden thumbnail -i photo.jpg.hex -o preview.jpg
+  hexwarden thumbnail -i photo.jpg.hex -o preview.jpg -p mypassword`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return c.newProcessor().Thumbnail(inputFile, outputFile, password)
+		},
+	}
+
+	cmd.Flags().StringVarP(&inputFile, "input", "i", "", "Encrypted file holding the preview")
+	cmd.Flags().StringVarP(&outputFile, "output", "o", "", "JPEG file to write")
+	cmd.Flags().StringVarP(&password, "password", "p", "", "Decryption password (will prompt if not provided)")
+	_ = cmd.MarkFlagRequired("input")
+	_ = cmd.MarkFlagRequired("output")
+
+	return cmd
+}
+
+// createSetNameCommand creates the set-name subcommand
+func (c *CLI) createSetNameCommand() *cobra.Command {
+	var inputFile, name, password string
+
+	cmd := &cobra.Command{
+		Use:   "set-name [flags]",
+		Short: "Change the file name stored in an encrypted file",
+		Long: `Replace the file name restored by decrypt --restore-name, for example after renaming
+the encrypted file. Only the header is rewritten; the encrypted body is left as it is`,
+		Example: `  hexwarden set-name -i b.hex --name b.txt
+  hexwarden set-name -i b.hex --name b.txt -p mypassword`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return c.newProcessor().SetName(inputFile, name, password)
+		},
+	}
+
+	cmd.Flags().StringVarP(&inputFile, "input", "i", "", "Encrypted file to update")
+	cmd.Flags().StringVar(&name, "name", "", "File name to store; an empty name removes it")
+	cmd.Flags().StringVarP(&password, "password", "p", "", "Decryption password (will prompt if not provided)")
+	_ = cmd.MarkFlagRequired("input")
+	_ = cmd.MarkFlagRequired("name")
+
+	return cmd
+}
+
+// createStripMetadataCommand creates the strip-metadata subcommand
+func (c *CLI) createStripMetadataCommand() *cobra.Command {
+	var inputFile, password string
+
+	cmd := &cobra.Command{
+		Use:   "strip-metadata [flags]",
+		Short: "Remove the stored name, hint, comment and thumbnail from an encrypted file",
+		Long: `Remove the metadata that can identify a file or its owner before sharing it: the stored
+file name, password hint, comment and thumbnail. Only the header is rewritten; the encrypted
+body is left as it is and still decrypts with the same password`,
+		Example: `  hexwarden strip-metadata -i report.pdf.hex
+  hexwarden strip-metadata -i report.pdf.hex -p mypassword`,
 		RunE: func(cmd *cobra.Command, args []string) error {
-			return c.runDecrypt(inputFile, outputFile, password, deleteSource, secureDelete)
+			return c.newProcessor().StripMetadata(inputFile, password)
 		},
 	}
 
-	cmd.Flags().StringVarP(&inputFile, "input", "i", "", "Input file to decrypt (required)")
-	cmd.Flags().StringVarP(&outputFile, "output", "o", "", "Output decrypted file (default: remove .hex extension)")
+	cmd.Flags().StringVarP(&inputFile, "input", "i", "", "Encrypted file to strip")
 	cmd.Flags().StringVarP(&password, "password", "p", "", "Decryption password (will prompt if not provided)")
-	cmd.Flags().BoolVar(&deleteSource, "delete-source", false, "Delete source file after decryption")
-	cmd.Flags().BoolVar(&secureDelete, "secure-delete", false, "Use secure deletion (slower but unrecoverable)")
+	_ = cmd.MarkFlagRequired("input")
+
+	return cmd
+}
+
+// createAddKeyCommand creates the add-key subcommand
+func (c *CLI) createAddKeyCommand() *cobra.Command {
+	var inputFile, password, newPassword string
+
+	cmd := &cobra.Command{
+		Use:   "add-key [flags]",
+		Short: "Let another password unlock an encrypted file",
+		Long: `Wrap the file key under a further password in a key slot of its own, so either password
+decrypts the file. Only the header is rewritten; the encrypted body is left as it is`,
+		Example: `  hexwarden add-key -i shared.hex
+  hexwarden add-key -i shared.hex -p mine --new-password theirs`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return c.newProcessor().AddKey(inputFile, password, newPassword)
+		},
+	}
+
+	cmd.Flags().StringVarP(&inputFile, "input", "i", "", "Encrypted file to update")
+	cmd.Flags().StringVarP(&password, "password", "p", "", "A password that already unlocks the file (will prompt if not provided)")
+	cmd.Flags().StringVar(&newPassword, "new-password", "", "Password to add (will prompt if not provided)")
+	_ = cmd.MarkFlagRequired("input")
+
+	return cmd
+}
+
+// createRemoveKeyCommand creates the remove-key subcommand
+func (c *CLI) createRemoveKeyCommand() *cobra.Command {
+	var inputFile, password, removePassword string
+
+	cmd := &cobra.Command{
+		Use:   "remove-key [flags]",
+		Short: "Stop a password added with add-key from unlocking an encrypted file",
+		Long: `Drop the key slot a password opens. The password the file was encrypted with has no slot
+and cannot be removed; re-encrypt the file to change it`,
+		Example: `  hexwarden remove-key -i shared.hex
+  hexwarden remove-key -i shared.hex -p mine --remove-password theirs`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return c.newProcessor().RemoveKey(inputFile, password, removePassword)
+		},
+	}
+
+	cmd.Flags().StringVarP(&inputFile, "input", "i", "", "Encrypted file to update")
+	cmd.Flags().StringVarP(&password, "password", "p", "", "A password that unlocks the file (will prompt if not provided)")
+	cmd.Flags().StringVar(&removePassword, "remove-password", "", "Password to remove (will prompt if not provided)")
+	_ = cmd.MarkFlagRequired("input")
+
+	return cmd
+}
+
+// createRecompressCommand creates the recompress subcommand
+func (c *CLI) createRecompressCommand() *cobra.Command {
+	var inputFile, format, password, aad string
+
+	cmd := &cobra.Command{
+		Use:   "recompress [flags]",
+		Short: "Change the compression of an encrypted file's chunks",
+		Long: `Decrypt every chunk in memory, compress it in the new format and encrypt it again under
+the same password and salt. The file is replaced atomically once the new copy is complete`,
+		Example: `  hexwarden recompress -i archive.tar.hex --compression deflate
+  hexwarden recompress -i archive.tar.hex --compression gzip -p mypassword`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			format, err := compression.ParseFormat(format)
+			if err != nil {
+				return err
+			}
+			processor := c.newProcessor()
+			processor.SetAAD(aad)
+			return processor.Recompress(inputFile, password, format)
+		},
+	}
+
+	cmd.Flags().StringVarP(&inputFile, "input", "i", "", "Encrypted file to recompress in place")
+	cmd.Flags().StringVar(&format, "compression", "", "New chunk compression: gzip, deflate or none")
+	cmd.Flags().StringVarP(&password, "password", "p", "", "Decryption password (will prompt if not provided)")
+	cmd.Flags().StringVar(&aad, "aad", "", "Context string the file was bound to with encrypt --aad")
+	_ = cmd.MarkFlagRequired("input")
+	_ = cmd.MarkFlagRequired("compression")
+
+	return cmd
+}
+
+// createProtectCommand creates the protect subcommand
+func (c *CLI) createProtectCommand() *cobra.Command {
+	var opts Options
+
+	cmd := &cobra.Command{
+		Use:   "protect [flags]",
+		Short: "Add Reed-Solomon protection to a file without encrypting it",
+		Long: `Write a file with the same chunk framing and Reed-Solomon error correction as
+encrypt, but without encryption or a password. Use it for public data that must
+survive corruption; recover rebuilds the original`,
+		Example: `  hexwarden protect -i dataset.csv
+  hexwarden protect -i dataset.csv -o dataset.csv.hex --durable`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return c.runProtect(opts)
+		},
+	}
+
+	cmd.Flags().StringVarP(&opts.InputFile, "input", "i", "", "Input file to protect")
+	cmd.Flags().StringVarP(&opts.OutputFile, "output", "o", "", "Output protected file (default: input + .hex)")
+	cmd.Flags().BoolVar(&opts.Durable, "durable", false, "Flush the output file and its directory entry to disk before reporting success")
+	_ = cmd.MarkFlagRequired("input")
+
+	return cmd
+}
+
+// createRecoverCommand creates the recover subcommand
+func (c *CLI) createRecoverCommand() *cobra.Command {
+	var opts Options
+
+	cmd := &cobra.Command{
+		Use:   "recover [flags]",
+		Short: "Restore a file written by protect, repairing corruption",
+		Long:  "Decode a file written by protect, rebuilding damaged Reed-Solomon shards. No password is needed",
+		Example: `  hexwarden recover -i dataset.csv.hex
+  hexwarden recover -i dataset.csv.hex -o - | head`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return c.runRecover(opts)
+		},
+	}
+
+	cmd.Flags().StringVarP(&opts.InputFile, "input", "i", "", "Protected file to recover")
+	cmd.Flags().StringVarP(&opts.OutputFile, "output", "o", "", "Output file, or - for standard output (default: remove .hex extension)")
+	cmd.Flags().BoolVar(&opts.Durable, "durable", false, "Flush the output file and its directory entry to disk before reporting success")
+	_ = cmd.MarkFlagRequired("input")
+
+	return cmd
+}
+
+// createAppendCommand creates the append subcommand
+func (c *CLI) createAppendCommand() *cobra.Command {
+	var opts Options
+
+	cmd := &cobra.Command{
+		Use:   "append [flags]",
+		Short: "Append a record to an encrypted append-only log",
+		Long: `Seal a record and append it to an encrypted log, creating the log when it does not exist.
+Existing records and the header are left untouched; decrypt returns all records concatenated`,
+		Example: `  echo "2024-05-01 backup ok" | hexwarden append -i events.log.hex -p mypassword
+  hexwarden append -i events.log.hex --record entry.txt --use-keychain events`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return c.runAppend(opts)
+		},
+	}
+
+	cmd.Flags().StringVarP(&opts.InputFile, "input", "i", "", "Log file to append to")
+	cmd.Flags().StringVar(&opts.Record, "record", "", "Read the record from this file instead of standard input")
+	cmd.Flags().StringVarP(&opts.Password, "password", "p", "", "Log password (will prompt if not provided)")
+	cmd.Flags().StringVar(&opts.Keychain, "use-keychain", "", "Fetch the password from the OS keychain entry with this name, saving it there on first use")
+	cmd.Flags().BoolVar(&opts.NoConfirm, "no-confirm", false, "Do not ask to confirm a typed password when creating the log")
+	cmd.Flags().IntVar(&opts.MinPasswordLength, "min-password-length", 0, "Refuse passwords shorter than this many characters when creating the log")
+	cmd.Flags().StringVar(&opts.Comment, "comment", "", "Attach a comment to the header when creating the log")
+	_ = cmd.MarkFlagRequired("input")
+
+	return cmd
+}
+
+// createScanCommand creates the scan subcommand
+func (c *CLI) createScanCommand() *cobra.Command {
+	var (
+		inputFile string
+		scanOpts  operations.ScanOptions
+		null      bool
+	)
+
+	cmd := &cobra.Command{
+		Use:   "scan [flags] [paths...]",
+		Short: "Check encrypted files for corruption without a password",
+		Long: `Check the Reed-Solomon parity of every chunk and report which shards are corrupt.
+Directories given as paths are searched for encrypted files. No password is needed,
+so tampering cannot be detected; use verify for a full check`,
+		Example: `  hexwarden scan -i document.txt.hex
+  hexwarden scan -i document.txt.hex --no-reconstruct
+  hexwarden scan -0 ./backups | xargs -0 -n1 hexwarden verify -i`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			paths := args
+			if inputFile != "" {
+				paths = append([]string{inputFile}, args...)
+			}
+			if len(paths) == 0 {
+				return fmt.Errorf("an encrypted file is required: pass -i or paths")
+			}
+			return c.newProcessor().Scan(paths, scanOpts, null)
+		},
+	}
+
+	cmd.Flags().StringVarP(&inputFile, "input", "i", "", "Encrypted file to scan")
+	cmd.Flags().BoolVar(&scanOpts.NoReconstruct, "no-reconstruct", false, "Only verify the parity; report chunks that fail instead of rebuilding their shards")
+	cmd.Flags().BoolVarP(&null, "null", "0", false, "Print only the paths of damaged files to standard output, each ending in a NUL byte")
+
+	return cmd
+}
+
+// createVerifyCommand creates the verify subcommand
+func (c *CLI) createVerifyCommand() *cobra.Command {
+	var (
+		inputFile, password string
+		treeFile            string
+		manifestFile        string
+		aad                 string
+		chunk               int
+		scanOpts            operations.ScanOptions
+	)
+
+	cmd := &cobra.Command{
+		Use:   "verify [flags]",
+		Short: "Check that an encrypted file decrypts without writing the output",
+		Long:  "Decrypt every chunk in memory, reporting which Reed-Solomon shards are corrupt",
+		Example: `  hexwarden verify -i document.txt.hex
+  hexwarden verify -i document.txt.hex -p mypassword --no-reconstruct
+  hexwarden verify -i archive.tar.hex --chunk 1234 --merkle-tree archive.tar.hex.merkle
+  hexwarden verify -i archive.tar.hex --manifest archive.tar.hex.manifest`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			processor := c.newProcessor()
+			if manifestFile != "" {
+				return processor.VerifyManifest(inputFile, manifestFile)
+			}
+			processor.SetAAD(aad)
+			if cmd.Flags().Changed("chunk") {
+				return processor.VerifyChunk(inputFile, password, chunk, treeFile)
+			}
+			if treeFile != "" {
+				return fmt.Errorf("--merkle-tree can only be used together with --chunk")
+			}
+			return processor.Verify(inputFile, password, scanOpts)
+		},
+	}
+
+	cmd.Flags().StringVarP(&inputFile, "input", "i", "", "Encrypted file to verify")
+	cmd.Flags().StringVarP(&password, "password", "p", "", "Password for decryption (will prompt if not provided)")
+	cmd.Flags().BoolVar(&scanOpts.NoReconstruct, "no-reconstruct", false, "Only verify the parity; report chunks that fail instead of rebuilding their shards")
+	cmd.Flags().IntVar(&chunk, "chunk", 0, "Only check this chunk (from 0) against the Merkle root stored with encrypt --merkle")
+	cmd.Flags().StringVar(&treeFile, "merkle-tree", "", "Merkle tree sidecar written by encrypt --merkle-tree; without it every chunk is hashed")
+	cmd.Flags().StringVar(&manifestFile, "manifest", "", "Check the stored chunks against the sidecar written by encrypt --manifest, without a password")
+	cmd.Flags().StringVar(&aad, "aad", "", "Context string the file was bound to with encrypt --aad")
+	cmd.MarkFlagsMutuallyExclusive("chunk", "no-reconstruct")
+	for _, flag := range []string{"chunk", "no-reconstruct", "merkle-tree", "password", "aad"} {
+		cmd.MarkFlagsMutuallyExclusive("manifest", flag)
+	}
+	_ = cmd.MarkFlagRequired("input")
+
+	return cmd
+}
 
-	if err := cmd.MarkFlagRequired("input"); err != nil {
-		// This should not happen in normal circumstances
-		panic(fmt.Sprintf("failed to mark input flag as required: %v", err))
+// createMatchCommand creates the match subcommand
+func (c *CLI) createMatchCommand() *cobra.Command {
+	var (
+		password, sameSaltAs string
+		null                 bool
+		limit                crypto.KDFParams
+	)
+
+	cmd := &cobra.Command{
+		Use:   "match [flags] paths...",
+		Short: "List the encrypted files a password unlocks",
+		Long: `Check the header of every encrypted file given, searching directories, and list
+those the password unlocks. No body is read, but every file with its own salt costs one full
+key derivation; --same-salt-as limits the check to files sharing a salt, which take one in total.
+Files asking for a costlier derivation than --max-kdf-time and --max-kdf-memory allow are
+skipped with a warning`,
+		Example: `  hexwarden match -p mypassword ./backups
+  hexwarden match -p mypassword --same-salt-as a.txt.hex ./backups
+  hexwarden match -p mypassword -0 ./backups | xargs -0 -n1 hexwarden info -i`,
+		Args: cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return c.newProcessor().Match(args, password, sameSaltAs, null, limit)
+		},
 	}
 
+	cmd.Flags().StringVarP(&password, "password", "p", "", "Password to check (will prompt if not provided)")
+	cmd.Flags().StringVar(&sameSaltAs, "same-salt-as", "", "Only check files whose salt matches this encrypted file's")
+	cmd.Flags().BoolVarP(&null, "null", "0", false, "Print only the matching paths to standard output, each ending in a NUL byte")
+	cmd.Flags().Uint32Var(&limit.Time, "max-kdf-time", constants.ArgonTime, "Skip files asking for more Argon2id passes than this")
+	cmd.Flags().Uint32Var(&limit.Memory, "max-kdf-memory", constants.ArgonMemory, "Skip files asking for more Argon2id memory than this, in KiB")
+
 	return cmd
 }
 
+// createKDFBenchCommand creates the kdf-bench subcommand
+func (c *CLI) createKDFBenchCommand() *cobra.Command {
+	var (
+		target    time.Duration
+		maxMemory uint32
+	)
+
+	cmd := &cobra.Command{
+		Use:   "kdf-bench [flags]",
+		Short: "Time key derivation on this machine and suggest Argon2id parameters",
+		Long: `Time Argon2id key derivation across a grid of memory, pass and thread settings,
+then recommend the costliest settings that stay within the target time`,
+		Example: `  hexwarden kdf-bench
+  hexwarden kdf-bench --target 500ms --max-memory 256`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return c.newProcessor().KDFBench(target, maxMemory*1024)
+		},
+	}
+
+	cmd.Flags().DurationVar(&target, "target", time.Second, "Longest acceptable time for one key derivation")
+	cmd.Flags().Uint32Var(&maxMemory, "max-memory", 1024, "Largest memory setting to try, in MiB")
+
+	return cmd
+}
+
+// createSelftestVectorsCommand creates the selftest-vectors command
+func (c *CLI) createSelftestVectorsCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "selftest-vectors",
+		Short: "Decrypt the built-in known-answer files and check their plaintext",
+		Long: `Decrypt every known-answer file built into the binary, written by earlier versions with
+known passwords, and compare the format version and plaintext with the recorded ones. A
+failure means files written by those versions may no longer decrypt`,
+		Example: `  hexwarden selftest-vectors`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return c.newProcessor().SelftestVectors()
+		},
+	}
+}
+
+// createVersionCommand creates the version command
+func (c *CLI) createVersionCommand() *cobra.Command {
+	var showFormat bool
+
+	cmd := &cobra.Command{
+		Use:   "version [flags]",
+		Short: "Print the version and the file format versions it writes and reads",
+		Long: `Print the application version. With --format, also print the container format
+version new files are written in and the versions this binary can read`,
+		Example: `  hexwarden version
+  hexwarden version --format`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return c.newProcessor().Version(showFormat)
+		},
+	}
+
+	cmd.Flags().BoolVar(&showFormat, "format", false, "Also print format_write=N format_read=[...] for compatibility checks")
+
+	return cmd
+}
+
+// markInputFlags requires exactly one of --input, --recursive, --files-from or the
+// given extra input flags, keeps single-file flags out of batch mode and separates
+// password sources
+func markInputFlags(cmd *cobra.Command, extra ...string) {
+	inputs := append([]string{"input", "recursive", "files-from"}, extra...)
+	cmd.MarkFlagsMutuallyExclusive("password", "use-keychain")
+	cmd.MarkFlagsOneRequired(inputs...)
+	cmd.MarkFlagsMutuallyExclusive(inputs...)
+	cmd.MarkFlagsMutuallyExclusive("output", "recursive")
+	cmd.MarkFlagsMutuallyExclusive("output", "files-from")
+}
+
+// runFileList processes the files named in opts.FilesFrom with the given batch operation
+func runFileList(opts Options, process func(Options, io.Reader) error) error {
+	// Standard input carries the list, so it cannot also carry the password
+	if opts.FilesFrom == "-" && opts.Password == "" && opts.Keychain == "" {
+		return fmt.Errorf("--files-from - reads paths from standard input; pass the password with --password or --use-keychain")
+	}
+
+	return openFileList(opts.FilesFrom, func(list io.Reader) error {
+		return process(opts, list)
+	})
+}
+
+// openFileList calls fn with the path list named by --files-from, where - is standard input
+func openFileList(name string, fn func(io.Reader) error) error {
+	if name == "-" {
+		return fn(os.Stdin)
+	}
+
+	list, err := os.Open(name)
+	if err != nil {
+		return fmt.Errorf("failed to open file list: %w", err)
+	}
+	defer list.Close() //nolint:errcheck
+
+	return fn(list)
+}
+
+// runEstimate collects the files selected by the input flags and estimates the cost of encrypting them
+func runEstimate(processor *CLIProcessor, opts Options) error {
+	var (
+		paths []string
+		err   error
+	)
+
+	switch {
+	case opts.RecursiveDir != "":
+		paths, err = processor.findEligibleFiles(opts.RecursiveDir, constants.ModeEncrypt, opts.Since)
+		if err != nil {
+			return fmt.Errorf("failed to scan directory: %w", err)
+		}
+	case opts.FilesFrom != "":
+		err = openFileList(opts.FilesFrom, func(list io.Reader) error {
+			paths, err = processor.fileFinder.ReadPathList(list)
+			return err
+		})
+		if err != nil {
+			return err
+		}
+	default:
+		paths = []string{opts.InputFile}
+	}
+
+	return processor.Estimate(paths)
+}
+
+// runBundle checks the files given to --bundle and encrypts them into one bundle
+func runBundle(processor *CLIProcessor, opts Options) error {
+	if len(opts.BundleInputs) == 0 {
+		return fmt.Errorf("--bundle needs the files to bundle as arguments")
+	}
+	if opts.OutputFile == "" || opts.toStdout() {
+		return fmt.Errorf("--bundle needs an output file name given with -o")
+	}
+	for _, input := range opts.BundleInputs {
+		if err := processor.fileFinder.CheckListedFile(input, constants.ModeEncrypt); err != nil {
+			return err
+		}
+	}
+	if _, err := os.Stat(opts.OutputFile); err == nil && !processor.fileManager.IsStreamTarget(opts.OutputFile) {
+		return fmt.Errorf("output file already exists: %s", opts.OutputFile)
+	}
+	return processor.EncryptBundle(opts)
+}
+
 // createInteractiveCommand creates the interactive subcommand
 func (c *CLI) createInteractiveCommand() *cobra.Command {
-	return &cobra.Command{
+	cmd := &cobra.Command{
 		Use:   "interactive",
 		Short: "Run in interactive mode",
 		Long:  "Run HexWarden in interactive mode with guided prompts",
 		Run: func(cmd *cobra.Command, args []string) {
-			interactiveApp := interactive.NewInteractiveApp()
-			interactiveApp.Run()
+			c.runInteractive()
 		},
 	}
+	cmd.Flags().BoolVar(&c.showPassword, "show-password", false, "Show passwords as they are typed instead of masking them")
+	cmd.Flags().BoolVar(&c.dryRun, "dry-run", false, "Show the plan for the chosen files without encrypting, decrypting or deleting anything")
+	return cmd
+}
+
+// runInteractive runs the guided interactive mode
+func (c *CLI) runInteractive() {
+	interactiveApp := interactive.NewInteractiveApp()
+	interactiveApp.SetShowPassword(c.showPassword)
+	interactiveApp.SetDryRun(c.dryRun)
+	interactiveApp.Run()
+}
+
+// newProcessor creates a CLI processor that logs to the --verbose logger and
+// applies --io-timeout
+func (c *CLI) newProcessor() *CLIProcessor {
+	processor := NewCLIProcessor()
+	processor.SetLogger(c.logger)
+	processor.SetIOTimeout(c.ioTimeout)
+	processor.SetFailOnWarning(c.failOnWarning)
+	processor.SetPepper(os.Getenv(constants.PepperEnvVar))
+	c.processor = processor
+	return processor
 }
 
 // runEncrypt handles the encrypt command
-func (c *CLI) runEncrypt(inputFile, outputFile, password string, deleteSource, secureDelete bool) error {
+func (c *CLI) runEncrypt(opts Options) error {
+	processor := c.newProcessor()
+
+	if opts.Estimate {
+		return runEstimate(processor, opts)
+	}
+
+	if opts.Index && opts.RecursiveDir == "" {
+		return fmt.Errorf("--index can only be used together with --recursive")
+	}
+	if opts.Dedup && opts.RecursiveDir == "" && opts.FilesFrom == "" {
+		return fmt.Errorf("--dedup can only be used together with --recursive or --files-from")
+	}
+	if opts.OutDir != "" && opts.RecursiveDir == "" {
+		return fmt.Errorf("--out-dir can only be used together with --recursive")
+	}
+	if opts.Flatten && opts.OutDir == "" {
+		return fmt.Errorf("--flatten can only be used together with --out-dir")
+	}
+	// One code is generated per run and unlocks every file it writes
+	if opts.WithRecoveryCode {
+		code, err := crypto.NewRecoveryCode()
+		if err != nil {
+			return err
+		}
+		opts.RecoveryCode = code
+	}
+
+	if opts.RecursiveDir != "" {
+		return processor.EncryptDirectory(opts)
+	}
+	if opts.FilesFrom != "" {
+		return runFileList(opts, processor.EncryptFileList)
+	}
+	if opts.Bundle {
+		return runBundle(processor, opts)
+	}
+
 	// Validate input file
-	if _, err := os.Stat(inputFile); os.IsNotExist(err) {
-		return fmt.Errorf("input file does not exist: %s", inputFile)
+	if _, err := os.Stat(opts.InputFile); os.IsNotExist(err) {
+		return fmt.Errorf("input file does not exist: %s", opts.InputFile)
+	}
+	if opts.toStdout() {
+		return fmt.Errorf("encrypting to standard output is not supported; pass a file name to -o")
 	}
 
-	// Set default output file if not provided
-	if outputFile == "" {
-		outputFile = inputFile + constants.FileExtension
+	// Uploads have no local output file to check
+	if opts.OutputURL != "" {
+		return processor.Encrypt(opts)
 	}
 
-	// Check if output file already exists
-	if _, err := os.Stat(outputFile); err == nil {
-		return fmt.Errorf("output file already exists: %s", outputFile)
+	// Set default output file if not provided
+	if opts.OutputFile == "" {
+		opts.OutputFile = opts.InputFile + constants.FileExtension
 	}
 
-	// Create CLI processor
-	processor := NewCLIProcessor()
+	// Check if output file already exists; a pipe or device is written in place
+	if _, err := os.Stat(opts.OutputFile); err == nil && !processor.fileManager.IsStreamTarget(opts.OutputFile) {
+		return fmt.Errorf("output file already exists: %s", opts.OutputFile)
+	}
 
 	// Run encryption
-	return processor.Encrypt(inputFile, outputFile, password, deleteSource, secureDelete)
+	return processor.Encrypt(opts)
+}
+
+// runProtect handles the protect command
+func (c *CLI) runProtect(opts Options) error {
+	processor := c.newProcessor()
+
+	if _, err := os.Stat(opts.InputFile); os.IsNotExist(err) {
+		return fmt.Errorf("input file does not exist: %s", opts.InputFile)
+	}
+	if opts.toStdout() {
+		return fmt.Errorf("protecting to standard output is not supported; pass a file name to -o")
+	}
+	if opts.OutputFile == "" {
+		opts.OutputFile = opts.InputFile + constants.FileExtension
+	}
+	if _, err := os.Stat(opts.OutputFile); err == nil && !processor.fileManager.IsStreamTarget(opts.OutputFile) {
+		return fmt.Errorf("output file already exists: %s", opts.OutputFile)
+	}
+
+	return processor.Protect(opts)
+}
+
+// runRecover handles the recover command
+func (c *CLI) runRecover(opts Options) error {
+	processor := c.newProcessor()
+
+	if _, err := os.Stat(opts.InputFile); os.IsNotExist(err) {
+		return fmt.Errorf("input file does not exist: %s", opts.InputFile)
+	}
+	if opts.OutputFile == "" {
+		opts.OutputFile = processor.fileFinder.GetOutputPath(opts.InputFile, constants.ModeDecrypt)
+	}
+	if _, err := os.Stat(opts.OutputFile); err == nil && !opts.toStdout() && !processor.fileManager.IsStreamTarget(opts.OutputFile) {
+		return fmt.Errorf("output file already exists: %s", opts.OutputFile)
+	}
+
+	return processor.Recover(opts)
+}
+
+// runAppend handles the append command
+func (c *CLI) runAppend(opts Options) error {
+	processor := c.newProcessor()
+
+	if opts.Record != "" {
+		record, err := os.Open(opts.Record)
+		if err != nil {
+			return fmt.Errorf("failed to open record: %w", err)
+		}
+		defer record.Close() //nolint:errcheck
+		return processor.Append(opts, record)
+	}
+
+	// The record owns standard input, so the password cannot be piped in too
+	if opts.Password == "" && opts.Keychain == "" && !ui.IsTerminal(os.Stdin) {
+		return fmt.Errorf("the record is read from standard input; pass the password with -p or --use-keychain")
+	}
+	return processor.Append(opts, os.Stdin)
 }
 
 // runDecrypt handles the decrypt command
-func (c *CLI) runDecrypt(inputFile, outputFile, password string, deleteSource, secureDelete bool) error {
+func (c *CLI) runDecrypt(opts Options) error {
+	processor := c.newProcessor()
+
+	if strings.ContainsAny(opts.OutputSuffix, `/\`) {
+		return fmt.Errorf("--output-suffix must not contain path separators: %q", opts.OutputSuffix)
+	}
+
+	if opts.Index && opts.RecursiveDir == "" {
+		return fmt.Errorf("--with-index can only be used together with --recursive")
+	}
+	if opts.RecursiveDir != "" {
+		return processor.DecryptDirectory(opts)
+	}
+	if opts.FilesFrom != "" {
+		return runFileList(opts, processor.DecryptFileList)
+	}
+
 	// Validate input file
-	if _, err := os.Stat(inputFile); os.IsNotExist(err) {
-		return fmt.Errorf("input file does not exist: %s", inputFile)
+	if _, err := os.Stat(opts.InputFile); os.IsNotExist(err) {
+		return fmt.Errorf("input file does not exist: %s", opts.InputFile)
 	}
 
-	// Set default output file if not provided
-	if outputFile == "" {
-		if len(inputFile) > len(constants.FileExtension) &&
-			inputFile[len(inputFile)-len(constants.FileExtension):] == constants.FileExtension {
-			outputFile = inputFile[:len(inputFile)-len(constants.FileExtension)]
-		} else {
-			return fmt.Errorf("cannot determine output filename, please specify with -o flag")
+	// The output name is only known once the header is unlocked
+	if opts.RestoreName {
+		return processor.Decrypt(opts)
+	}
+
+	// A bundle member is named after itself unless -o is given
+	if opts.Extract != "" && opts.OutputFile == "" {
+		outputFile, err := utils.SafeJoin(filepath.Dir(opts.InputFile), opts.Extract)
+		if err != nil {
+			return fmt.Errorf("refusing member name: %w", err)
 		}
+		opts.OutputFile = outputFile
 	}
 
-	// Check if output file already exists
-	if _, err := os.Stat(outputFile); err == nil {
-		return fmt.Errorf("output file already exists: %s", outputFile)
+	// Set default output file if not provided; it never equals the input
+	if opts.OutputFile == "" {
+		opts.OutputFile = processor.fileFinder.GetOutputPathWithSuffix(opts.InputFile, constants.ModeDecrypt, opts.OutputSuffix)
 	}
 
-	// Create CLI processor
-	processor := NewCLIProcessor()
+	// Check if output file already exists; a pipe or device is written in place
+	if _, err := os.Stat(opts.OutputFile); err == nil && !opts.toStdout() && !processor.fileManager.IsStreamTarget(opts.OutputFile) {
+		return fmt.Errorf("output file already exists: %s", opts.OutputFile)
+	}
 
 	// Run decryption
-	return processor.Decrypt(inputFile, outputFile, password, deleteSource, secureDelete)
+	return processor.Decrypt(opts)
 }
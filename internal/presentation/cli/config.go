@@ -0,0 +1,287 @@
+package cli
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+
+	"github.com/hambosto/hexwarden/internal/constants"
+)
+
+// unconfigurable lists the flags a configuration file may not set
+var unconfigurable = map[string]string{
+	"password":              "passwords cannot be stored in a configuration file",
+	"add-password":          "passwords cannot be stored in a configuration file",
+	"new-password":          "passwords cannot be stored in a configuration file",
+	"remove-password":       "passwords cannot be stored in a configuration file",
+	"config":                "a configuration file cannot name another one",
+	"i-understand-the-risk": "the risk of exporting a key must be acknowledged on the command line",
+	"help":                  "help cannot be configured",
+	"version":               "version cannot be configured",
+}
+
+// Config holds option defaults read from a configuration file, keyed by flag
+// name. Top-level keys apply to every command with that flag; keys in a section
+// apply only to the command of that name
+type Config struct {
+	Path     string
+	global   map[string]string
+	sections map[string]map[string]string
+}
+
+// FindConfig returns the first configuration file found in the working
+// directory, then the home directory, or "" when there is none
+func FindConfig() string {
+	dirs := []string{"."}
+	if home, err := os.UserHomeDir(); err == nil {
+		dirs = append(dirs, home)
+	}
+
+	for _, dir := range dirs {
+		for _, ext := range []string{".yaml", ".yml", ".toml"} {
+			path := filepath.Join(dir, constants.ConfigFileName+ext)
+			if info, err := os.Stat(path); err == nil && info.Mode().IsRegular() {
+				return path
+			}
+		}
+	}
+	return ""
+}
+
+// LoadConfig reads a YAML or TOML configuration file, chosen by its extension.
+// Only flat keys and one level of per-command sections are supported
+func LoadConfig(path string) (*Config, error) {
+	data, err := os.ReadFile(filepath.Clean(path))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", constants.ErrInvalidConfig, err)
+	}
+
+	config := &Config{Path: path, global: make(map[string]string), sections: make(map[string]map[string]string)}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = config.parse(string(data), parseYAMLLine)
+	case ".toml":
+		err = config.parse(string(data), parseTOMLLine)
+	default:
+		err = fmt.Errorf("unsupported format %q; use .yaml, .yml or .toml", filepath.Ext(path))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s: %v", constants.ErrInvalidConfig, path, err)
+	}
+	return config, nil
+}
+
+// Apply sets every flag of cmd that was not given on the command line to its
+// configured value
+func (c *Config) Apply(cmd *cobra.Command) error {
+	if err := c.checkKeys(cmd.Root()); err != nil {
+		return err
+	}
+
+	values := make(map[string]string)
+	for name, value := range c.global {
+		if cmd.Flags().Lookup(name) != nil {
+			values[name] = value
+		}
+	}
+	for name, value := range c.sections[cmd.Name()] {
+		if cmd.Flags().Lookup(name) == nil {
+			return fmt.Errorf("%w: %s: %s has no --%s option", constants.ErrInvalidConfig, c.Path, cmd.Name(), name)
+		}
+		values[name] = value
+	}
+
+	for name, value := range values {
+		flag := cmd.Flags().Lookup(name)
+		if flag.Changed {
+			continue
+		}
+		if err := flag.Value.Set(value); err != nil {
+			return fmt.Errorf("%w: %s: %s: %v", constants.ErrInvalidConfig, c.Path, name, err)
+		}
+	}
+	return nil
+}
+
+// checkKeys rejects sections that are not commands and top-level keys that are
+// not a flag of any command, so typos do not go unnoticed
+func (c *Config) checkKeys(root *cobra.Command) error {
+	known := make(map[string]bool)
+	collect := func(flags *pflag.FlagSet) {
+		flags.VisitAll(func(flag *pflag.Flag) { known[flag.Name] = true })
+	}
+	collect(root.PersistentFlags())
+
+	commands := make(map[string]bool)
+	for _, sub := range root.Commands() {
+		commands[sub.Name()] = true
+		collect(sub.Flags())
+	}
+
+	for name := range c.global {
+		if !known[name] {
+			return fmt.Errorf("%w: %s: unknown option %q", constants.ErrInvalidConfig, c.Path, name)
+		}
+	}
+	for name := range c.sections {
+		if !commands[name] {
+			return fmt.Errorf("%w: %s: unknown command section %q", constants.ErrInvalidConfig, c.Path, name)
+		}
+	}
+	return nil
+}
+
+// configLine is one parsed line: a section header when key is empty and
+// section is set, otherwise a key and value inside the current section
+type configLine struct {
+	section  string
+	key      string
+	value    string
+	indented bool
+}
+
+// parse reads data line by line, building the global and section maps
+func (c *Config) parse(data string, parseLine func(string) (configLine, bool, error)) error {
+	section := ""
+	scanner := bufio.NewScanner(strings.NewReader(data))
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line, ok, err := parseLine(scanner.Text())
+		if err != nil {
+			return fmt.Errorf("line %d: %v", lineNo, err)
+		}
+		if !ok {
+			continue
+		}
+
+		if line.key == "" {
+			if _, dup := c.sections[line.section]; dup {
+				return fmt.Errorf("line %d: duplicate section %q", lineNo, line.section)
+			}
+			section = line.section
+			c.sections[section] = make(map[string]string)
+			continue
+		}
+		if !line.indented && line.section == "" {
+			section = ""
+		}
+		if reason, denied := unconfigurable[line.key]; denied {
+			return fmt.Errorf("line %d: %s", lineNo, reason)
+		}
+
+		target := c.global
+		if section != "" {
+			target = c.sections[section]
+		}
+		if _, dup := target[line.key]; dup {
+			return fmt.Errorf("line %d: duplicate option %q", lineNo, line.key)
+		}
+		target[line.key] = line.value
+	}
+	return scanner.Err()
+}
+
+// parseYAMLLine parses "key: value" lines, where a key with no value opens a
+// section holding the indented lines below it
+func parseYAMLLine(raw string) (configLine, bool, error) {
+	text := strings.TrimRight(stripComment(raw), " \t")
+	if strings.TrimSpace(text) == "" || strings.TrimSpace(text) == "---" {
+		return configLine{}, false, nil
+	}
+
+	indented := text[0] == ' ' || text[0] == '\t'
+	key, value, found := strings.Cut(strings.TrimSpace(text), ":")
+	key = strings.TrimSpace(key)
+	if !found || key == "" || strings.HasPrefix(key, "-") {
+		return configLine{}, false, fmt.Errorf("expected key: value, got %q", strings.TrimSpace(raw))
+	}
+
+	value = strings.TrimSpace(value)
+	if value == "" {
+		if indented {
+			return configLine{}, false, fmt.Errorf("sections cannot be nested: %q", key)
+		}
+		return configLine{section: key}, true, nil
+	}
+
+	value, err := unquote(value)
+	if err != nil {
+		return configLine{}, false, err
+	}
+	return configLine{key: key, value: value, indented: indented}, true, nil
+}
+
+// parseTOMLLine parses "key = value" lines and "[section]" headers
+func parseTOMLLine(raw string) (configLine, bool, error) {
+	text := strings.TrimSpace(stripComment(raw))
+	if text == "" {
+		return configLine{}, false, nil
+	}
+
+	if strings.HasPrefix(text, "[") {
+		if !strings.HasSuffix(text, "]") || strings.HasPrefix(text, "[[") {
+			return configLine{}, false, fmt.Errorf("invalid section header %q", text)
+		}
+		section := strings.TrimSpace(text[1 : len(text)-1])
+		if section == "" || strings.Contains(section, ".") {
+			return configLine{}, false, fmt.Errorf("invalid section header %q", text)
+		}
+		return configLine{section: section}, true, nil
+	}
+
+	key, value, found := strings.Cut(text, "=")
+	key = strings.TrimSpace(key)
+	if !found || key == "" {
+		return configLine{}, false, fmt.Errorf("expected key = value, got %q", text)
+	}
+
+	value, err := unquote(strings.TrimSpace(value))
+	if err != nil {
+		return configLine{}, false, err
+	}
+	// TOML sections stay open until the next header
+	return configLine{key: key, value: value, indented: true}, true, nil
+}
+
+// stripComment removes a # comment that is not inside quotes
+func stripComment(line string) string {
+	var quote rune
+	for i, r := range line {
+		switch {
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			}
+		case r == '"' || r == '\'':
+			quote = r
+		case r == '#':
+			return line[:i]
+		}
+	}
+	return line
+}
+
+// unquote strips the quotes from a quoted value; bare values are kept as is
+func unquote(value string) (string, error) {
+	if len(value) >= 2 {
+		switch {
+		case value[0] == '"' && value[len(value)-1] == '"':
+			unquoted, err := strconv.Unquote(value)
+			if err != nil {
+				return "", fmt.Errorf("invalid quoted value %s", value)
+			}
+			return unquoted, nil
+		case value[0] == '\'' && value[len(value)-1] == '\'':
+			return value[1 : len(value)-1], nil
+		}
+	}
+	if strings.ContainsAny(value, "\"'") {
+		return "", fmt.Errorf("unbalanced quotes in %s", value)
+	}
+	return value, nil
+}
@@ -0,0 +1,70 @@
+package cli
+
+import (
+	"encoding/hex"
+	"fmt"
+
+	"github.com/hambosto/hexwarden/internal/usecase/operations"
+)
+
+// dedupIndex maps the hex SHA-256 of each plaintext encrypted in a batch to its output
+type dedupIndex map[string]string
+
+// encryptDeduped encrypts opts.InputFile, or with seen set writes a reference
+// to the earlier output of a file with the same content instead. Empty files
+// are always encrypted, as a reference would not be smaller
+func (p *CLIProcessor) encryptDeduped(opts Options, password string, seen dedupIndex) error {
+	if seen == nil {
+		return p.encryptOne(opts, password)
+	}
+	info, err := p.fileManager.GetFileInfo(opts.InputFile)
+	if err != nil {
+		return err
+	}
+	if info.Size() == 0 {
+		return p.encryptOne(opts, password)
+	}
+
+	digest, err := operations.HashFile(opts.InputFile)
+	if err != nil {
+		return err
+	}
+	key := hex.EncodeToString(digest)
+
+	if target, ok := seen[key]; ok && p.fileManager.FileExists(target) {
+		return p.withLocks(opts, func() error {
+			return p.encryptReference(opts, target, digest, password)
+		})
+	}
+	if err := p.encryptOne(opts, password); err != nil {
+		return err
+	}
+	seen[key] = opts.OutputFile
+	return nil
+}
+
+// encryptReference writes opts.OutputFile as a reference to target, the
+// encrypted copy of a file with the same content
+func (p *CLIProcessor) encryptReference(opts Options, target string, digest []byte, password string) error {
+	fmt.Fprintf(p.status, "Referencing: %s -> %s (same content as %s)\n", opts.InputFile, opts.OutputFile, target)
+
+	if _, err := p.encryptor.EncryptReference(opts.OutputFile, target, digest, password, opts.encryptOptions()); err != nil {
+		return fmt.Errorf("encryption failed: %w", err)
+	}
+	return p.makeDurable(opts)
+}
+
+// referencesFirst moves dedup references ahead of the other paths, keeping
+// their order, so every reference is restored while its target still exists.
+// Paths whose header cannot be read stay with the others for decryption to report
+func (p *CLIProcessor) referencesFirst(paths []string) []string {
+	var references, others []string
+	for _, path := range paths {
+		if ok, err := p.decryptor.IsReference(path); err == nil && ok {
+			references = append(references, path)
+		} else {
+			others = append(others, path)
+		}
+	}
+	return append(references, others...)
+}
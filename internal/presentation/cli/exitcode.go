@@ -0,0 +1,115 @@
+package cli
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+
+	"github.com/hambosto/hexwarden/internal/constants"
+)
+
+// Errors grouped by the exit code they map to. A batch failing for several
+// reasons takes the first group that matches, in the order ExitCode checks them
+var (
+	canceledErrors = []error{
+		constants.ErrUserCanceled,
+		constants.ErrPromptFailed,
+		constants.ErrCanceled,
+	}
+
+	authErrors = []error{
+		constants.ErrAuthFailure,
+		constants.ErrTooManyAttempts,
+		constants.ErrAADMismatch,
+		constants.ErrPepperRequired,
+		constants.ErrRecoveryCode,
+		constants.ErrNoRecoveryCode,
+		constants.ErrEscrowKey,
+		constants.ErrEscrowMismatch,
+		constants.ErrNoKeySlot,
+	}
+
+	corruptErrors = []error{
+		constants.ErrInvalidMagic,
+		constants.ErrInvalidHeader,
+		constants.ErrInvalidNonce,
+		constants.ErrInvalidIntegrity,
+		constants.ErrInvalidAuth,
+		constants.ErrChecksumMismatch,
+		constants.ErrIntegrityFailure,
+		constants.ErrTampering,
+		constants.ErrIncompleteRead,
+		constants.ErrInvalidMetadata,
+		constants.ErrDecryptionFailed,
+		constants.ErrCiphertextTooShort,
+		constants.ErrTagMismatch,
+		constants.ErrDecompressionFailed,
+		constants.ErrDecodingFailed,
+		constants.ErrUnpaddingFailed,
+		constants.ErrChunkTooLarge,
+		constants.ErrUnrecoverable,
+		constants.ErrDecompressionBomb,
+		constants.ErrSizeMismatch,
+		constants.ErrParityMismatch,
+		constants.ErrUnknownFormat,
+		constants.ErrRawBodySize,
+		constants.ErrDigestMismatch,
+		constants.ErrInvalidBundle,
+		constants.ErrMerkleMismatch,
+		constants.ErrOriginalMismatch,
+		constants.ErrInvalidLog,
+		constants.ErrInvalidIndex,
+		constants.ErrManifestMismatch,
+		constants.ErrInvalidReference,
+		io.ErrUnexpectedEOF,
+	}
+
+	ioErrors = []error{
+		constants.ErrFileNotFound,
+		constants.ErrFileCreateFailed,
+		constants.ErrFileOpenFailed,
+		constants.ErrFileReadFailed,
+		constants.ErrFileWriteFailed,
+		constants.ErrIncompleteWrite,
+		constants.ErrSecureDeleteFailed,
+		constants.ErrInsufficientSpace,
+		constants.ErrUploadFailed,
+		constants.ErrFileInUse,
+		constants.ErrStalled,
+		fs.ErrNotExist,
+		fs.ErrPermission,
+	}
+)
+
+// ExitCode returns the process exit code for an error returned by Execute:
+// ExitSuccess for nil, ExitCanceled, ExitAuthFailed, ExitCorrupt or ExitIOError
+// for the errors of those kinds, and ExitFailure for anything else
+func ExitCode(err error) int {
+	if err == nil {
+		return constants.ExitSuccess
+	}
+
+	groups := []struct {
+		errs []error
+		code int
+	}{
+		{errs: canceledErrors, code: constants.ExitCanceled},
+		{errs: authErrors, code: constants.ExitAuthFailed},
+		{errs: corruptErrors, code: constants.ExitCorrupt},
+		{errs: ioErrors, code: constants.ExitIOError},
+	}
+	for _, group := range groups {
+		for _, target := range group.errs {
+			if errors.Is(err, target) {
+				return group.code
+			}
+		}
+	}
+
+	// Failed system calls on a path are I/O errors even without a sentinel
+	var pathErr *fs.PathError
+	if errors.As(err, &pathErr) {
+		return constants.ExitIOError
+	}
+	return constants.ExitFailure
+}
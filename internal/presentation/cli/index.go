@@ -0,0 +1,181 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+
+	"github.com/hambosto/hexwarden/internal/constants"
+	"github.com/hambosto/hexwarden/internal/usecase/operations"
+)
+
+// encryptIndexed encrypts every eligible file below opts.RecursiveDir to an
+// opaquely named file at the top of the tree and lists where each came from in
+// the encrypted index. An index left by an earlier run is extended, so a run
+// that failed part way can simply be repeated
+func (p *CLIProcessor) encryptIndexed(opts Options) error {
+	root := opts.RecursiveDir
+	paths, err := p.findEligibleFiles(root, constants.ModeEncrypt, opts.Since)
+	if err != nil {
+		return fmt.Errorf("failed to scan directory: %w", err)
+	}
+	if len(paths) == 0 {
+		fmt.Fprintf(p.status, "No eligible files found in %s\n", root)
+		return nil
+	}
+	if err := p.fileFinder.SortFiles(paths, opts.Sort); err != nil {
+		return err
+	}
+
+	password, save, err := p.resolvePassword(opts, constants.ModeEncrypt)
+	if err != nil {
+		return err
+	}
+
+	p.SetAAD(opts.AAD)
+	indexPath := filepath.Join(root, constants.IndexFileName)
+	index := operations.NewTreeIndex()
+	if p.fileManager.FileExists(indexPath) {
+		if index, err = p.decryptor.ReadIndex(indexPath, password); err != nil {
+			return err
+		}
+	}
+
+	batch := &BatchError{Mode: constants.ModeEncrypt, Total: len(paths)}
+	var done []Options
+	for _, inputFile := range paths {
+		rel, err := filepath.Rel(root, inputFile)
+		if err != nil {
+			batch.add(inputFile, err)
+			continue
+		}
+		rel = filepath.ToSlash(rel)
+		if entry, ok := index.Lookup(rel); ok {
+			p.warn("Skipping %s: already in the index as %s", inputFile, entry.Name)
+			continue
+		}
+
+		name, err := operations.OpaqueName()
+		if err != nil {
+			return err
+		}
+		fileOpts := opts
+		fileOpts.InputFile = inputFile
+		fileOpts.OutputFile = filepath.Join(root, name)
+		// The stored name lets decrypt --restore-name recover the file should the index be lost
+		fileOpts.StoreName = true
+
+		if err := index.Add(name, rel); err != nil {
+			batch.add(inputFile, err)
+			continue
+		}
+		if err := p.encryptOne(fileOpts, password); err != nil {
+			index.Remove(name)
+			fmt.Fprintf(p.status, "✗ %s: %v\n", inputFile, err)
+			batch.add(inputFile, err)
+			continue
+		}
+		done = append(done, fileOpts)
+	}
+
+	if len(done) > 0 {
+		// Sources are only deleted once the index naming their outputs is safely written
+		if err := p.encryptor.WriteIndex(indexPath, index, password, opts.encryptOptions()); err != nil {
+			for _, fileOpts := range done {
+				_ = os.Remove(fileOpts.OutputFile)
+			}
+			return fmt.Errorf("failed to write index: %w", err)
+		}
+		p.savePassword(opts, save)
+		for _, fileOpts := range done {
+			p.deleteSource(fileOpts)
+			if opts.DeleteSource {
+				pruneEmptyDirs(root, filepath.Dir(fileOpts.InputFile))
+			}
+		}
+	}
+
+	fmt.Fprintf(p.status, "✓ %d of %d file(s) processed successfully, index: %s\n", len(done), len(paths), indexPath)
+	if len(done) > 0 {
+		p.printRecoveryCode(opts)
+	}
+	batch.printTable(p.status)
+	return batch.err()
+}
+
+// decryptIndexed restores the tree listed in the index at the top of
+// opts.RecursiveDir. With --delete-source the restored entries are dropped
+// from the index, so a run that failed part way only retries what is left,
+// and the index itself is removed once it is empty
+func (p *CLIProcessor) decryptIndexed(opts Options) error {
+	root := opts.RecursiveDir
+	indexPath := filepath.Join(root, constants.IndexFileName)
+
+	password, save, err := p.resolvePassword(opts, constants.ModeDecrypt)
+	if err != nil {
+		return err
+	}
+	p.SetAAD(opts.AAD)
+	index, err := p.decryptor.ReadIndex(indexPath, password)
+	if err != nil {
+		return err
+	}
+
+	entries := slices.Clone(index.Entries)
+	batch := &BatchError{Mode: constants.ModeDecrypt, Total: len(entries)}
+	var processed int
+	for _, entry := range entries {
+		fileOpts := opts
+		fileOpts.InputFile = filepath.Join(root, entry.Name)
+		fileOpts.OutputFile = entry.LocalPath(root)
+
+		if p.fileManager.FileExists(fileOpts.OutputFile) {
+			p.warn("Skipping %s: output file already exists: %s", fileOpts.InputFile, fileOpts.OutputFile)
+			continue
+		}
+		if err := os.MkdirAll(filepath.Dir(fileOpts.OutputFile), 0o750); err != nil {
+			batch.add(fileOpts.InputFile, fmt.Errorf("%w: %w", constants.ErrFileCreateFailed, err))
+			continue
+		}
+		if err := p.decryptOne(fileOpts, password); err != nil {
+			fmt.Fprintf(p.status, "✗ %s: %v\n", fileOpts.InputFile, err)
+			batch.add(fileOpts.InputFile, err)
+			continue
+		}
+
+		p.deleteSource(fileOpts)
+		if opts.DeleteSource {
+			index.Remove(entry.Name)
+		}
+		processed++
+	}
+
+	if processed > 0 {
+		p.savePassword(opts, save)
+	}
+	if opts.DeleteSource && processed > 0 {
+		if len(index.Entries) == 0 {
+			if err := os.Remove(indexPath); err != nil {
+				p.warn("Failed to delete index: %v", err)
+			}
+		} else if err := p.encryptor.WriteIndex(indexPath, index, password, opts.encryptOptions()); err != nil {
+			p.warn("Failed to update index: %v", err)
+		}
+	}
+
+	fmt.Fprintf(p.status, "✓ %d of %d file(s) restored successfully\n", processed, len(entries))
+	batch.printTable(p.status)
+	return batch.err()
+}
+
+// pruneEmptyDirs removes dir and then each of its parents below root for as
+// long as they are empty, so deleted sources leave no trace of the tree
+func pruneEmptyDirs(root, dir string) {
+	root = filepath.Clean(root)
+	for dir = filepath.Clean(dir); dir != root && dir != "." && dir != filepath.Dir(dir); dir = filepath.Dir(dir) {
+		if os.Remove(dir) != nil {
+			return
+		}
+	}
+}
@@ -0,0 +1,83 @@
+package cli
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/hambosto/hexwarden/internal/constants"
+)
+
+// flattenHashLen is how many hex digits of the source path's SHA-256 tell
+// apart outputs that would share a name under --flatten
+const flattenHashLen = 8
+
+// outputLayout places the outputs of a recursive run under --out-dir, either
+// mirroring the source tree or, with --flatten, all in the one directory
+type outputLayout struct {
+	root    string            // Source tree the inputs were found in
+	outDir  string            // Directory the outputs are written under
+	flatten bool              // Write every output directly into outDir
+	claimed map[string]string // Flattened output names taken so far, mapped to their input
+}
+
+// newOutputLayout returns the layout --out-dir selects, or nil without it
+func newOutputLayout(opts Options) *outputLayout {
+	if opts.OutDir == "" {
+		return nil
+	}
+	return &outputLayout{root: opts.RecursiveDir, outDir: opts.OutDir, flatten: opts.Flatten, claimed: map[string]string{}}
+}
+
+// place returns where the output of inputFile goes, given outputFile, the path
+// it would have next to the input, and creates the directories it needs. A nil
+// layout keeps outputFile
+func (l *outputLayout) place(inputFile, outputFile string) (string, error) {
+	if l == nil {
+		return outputFile, nil
+	}
+
+	rel, err := filepath.Rel(l.root, inputFile)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("%w: %s is outside %s", constants.ErrInvalidPath, inputFile, l.root)
+	}
+
+	placed := filepath.Join(l.outDir, filepath.Dir(rel), filepath.Base(outputFile))
+	if l.flatten {
+		if placed, err = l.flattened(rel, filepath.Base(outputFile)); err != nil {
+			return "", err
+		}
+	}
+
+	if err := os.MkdirAll(filepath.Dir(placed), 0o750); err != nil {
+		return "", fmt.Errorf("%w: %w", constants.ErrFileCreateFailed, err)
+	}
+	return placed, nil
+}
+
+// flattened returns name directly under outDir, or, when another input of the
+// run already took it, name with a short hash of rel before its extensions,
+// such as report-1a2b3c4d.txt.hex. The hash depends only on the source path,
+// so running again picks the same names
+func (l *outputLayout) flattened(rel, name string) (string, error) {
+	placed := filepath.Join(l.outDir, name)
+	if owner, taken := l.claimed[placed]; !taken || owner == rel {
+		l.claimed[placed] = rel
+		return placed, nil
+	}
+
+	sum := sha256.Sum256([]byte(filepath.ToSlash(rel)))
+	stem, ext, _ := strings.Cut(name, ".")
+	if ext != "" {
+		ext = "." + ext
+	}
+	placed = filepath.Join(l.outDir, stem+"-"+hex.EncodeToString(sum[:])[:flattenHashLen]+ext)
+	if owner, taken := l.claimed[placed]; taken && owner != rel {
+		return "", fmt.Errorf("%w: %s", constants.ErrNoFreeName, placed)
+	}
+	l.claimed[placed] = rel
+	return placed, nil
+}
@@ -1,121 +1,1769 @@
 package cli
 
 import (
+	"crypto/ecdh"
+	"errors"
 	"fmt"
-	"syscall"
-
-	"golang.org/x/term"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
 
 	"github.com/hambosto/hexwarden/internal/constants"
 	"github.com/hambosto/hexwarden/internal/data/files"
+	"github.com/hambosto/hexwarden/internal/data/keychain"
+	"github.com/hambosto/hexwarden/internal/data/remote"
+	"github.com/hambosto/hexwarden/internal/data/streaming"
+	"github.com/hambosto/hexwarden/internal/infrastructure/compression"
+	"github.com/hambosto/hexwarden/internal/infrastructure/crypto"
+	"github.com/hambosto/hexwarden/internal/infrastructure/utils"
+	"github.com/hambosto/hexwarden/internal/presentation/ui"
 	"github.com/hambosto/hexwarden/internal/usecase/operations"
 )
 
+// stdoutPath is the output name that selects standard output
+const stdoutPath = "-"
+
+// Options holds the flag values shared by the encrypt and decrypt commands
+type Options struct {
+	InputFile          string
+	OutputFile         string
+	OutputURL          string
+	OutputSuffix       string
+	Password           string
+	Comment            string
+	Keychain           string
+	MinPasswordLength  int
+	PasswordAttempts   int
+	SmallFileThreshold int64
+	PadOutputTo        int64
+	ExpectSHA256       string
+	HeaderHash         constants.HashAlgorithm
+	Cipher             constants.CipherAlgorithm
+	KDFTime            uint32
+	KDFMemory          uint32
+	KDFThreads         uint8
+	KDFVariant         constants.Argon2Variant
+	DeleteSource       bool
+	SecureDelete       bool
+	DeletePattern      constants.OverwritePattern
+	NoConfirm          bool
+	NoSpaceCheck       bool
+	Durable            bool
+	RecursiveDir       string
+	FilesFrom          string
+	Estimate           bool
+	Lock               bool
+	Thumbnail          bool
+	StoreName          bool
+	RestoreName        bool
+	Bundle             bool
+	BundleInputs       []string
+	Extract            string
+	Merkle             bool
+	MerkleTree         string
+	Manifest           string
+	HashOriginal       bool
+	VerifyHash         bool
+	NotBefore          time.Time
+	IgnoreTimelock     bool
+	IgnoreCorrupt      bool
+	Index              bool
+	Dedup              bool
+	WithRecoveryCode   bool
+	RecoveryCode       string
+	ExtraPasswords     []string
+	CompressMetadata   bool
+	OutDir             string
+	Flatten            bool
+	ExportKeyTo        string
+	EscrowPublicKey    *ecdh.PublicKey
+	EscrowedKey        []byte
+	EscrowPrivateKey   *ecdh.PrivateKey
+	NonceState         string
+	Sort               constants.SortOrder
+	AAD                string
+	Compression        constants.CompressionFormat
+	Paranoid           bool
+	Record             string
+	Range              *operations.ByteRange
+	Since              time.Time
+}
+
+// parseSince parses the --since flag value into the Since cutoff
+func (o *Options) parseSince(value string) error {
+	if value == "" {
+		return nil
+	}
+	if o.RecursiveDir == "" {
+		return fmt.Errorf("--since can only be used together with --recursive")
+	}
+
+	since, err := utils.ParseSince(value, time.Now())
+	if err != nil {
+		return err
+	}
+	o.Since = since
+	return nil
+}
+
+// parseEscrow reads the escrow holder's public key for --export-key-to, which
+// writes a key that unlocks the output without the password and so must be
+// acknowledged with --i-understand-the-risk
+func (o *Options) parseEscrow(publicKeyFile string, acknowledged bool) error {
+	if o.ExportKeyTo == "" {
+		return nil
+	}
+	if !acknowledged {
+		return fmt.Errorf("--export-key-to writes a key that unlocks the output without the password; add --i-understand-the-risk to confirm")
+	}
+
+	data, err := os.ReadFile(filepath.Clean(publicKeyFile))
+	if err != nil {
+		return fmt.Errorf("failed to read escrow public key: %w", err)
+	}
+	if o.EscrowPublicKey, err = crypto.ParseEscrowPublicKey(data); err != nil {
+		return err
+	}
+	return nil
+}
+
+// parseEscrowUnlock reads the escrowed key and the escrow holder's private key
+// decryption unlocks the file with in place of the password
+func (o *Options) parseEscrowUnlock(escrowedKeyFile, privateKeyFile string) error {
+	if escrowedKeyFile == "" {
+		return nil
+	}
+
+	escrowed, err := os.ReadFile(filepath.Clean(escrowedKeyFile))
+	if err != nil {
+		return fmt.Errorf("failed to read escrowed key: %w", err)
+	}
+	data, err := os.ReadFile(filepath.Clean(privateKeyFile))
+	if err != nil {
+		return fmt.Errorf("failed to read escrow private key: %w", err)
+	}
+	if o.EscrowPrivateKey, err = crypto.ParseEscrowPrivateKey(data); err != nil {
+		return err
+	}
+	o.EscrowedKey = escrowed
+	return nil
+}
+
+// parseRange parses the --range flag value, START-END with both ends inclusive, into Range
+func (o *Options) parseRange(value string) error {
+	if value == "" {
+		return nil
+	}
+
+	start, end, ok := strings.Cut(value, "-")
+	first, startErr := strconv.ParseUint(strings.TrimSpace(start), 10, 64)
+	last, endErr := strconv.ParseUint(strings.TrimSpace(end), 10, 64)
+	if !ok || startErr != nil || endErr != nil {
+		return fmt.Errorf("invalid --range %q: expected START-END in bytes, e.g. 0-1048575", value)
+	}
+	if first > last {
+		return fmt.Errorf("invalid --range %q: start is after end", value)
+	}
+	o.Range = &operations.ByteRange{Start: first, End: last}
+	return nil
+}
+
+// parseNotBefore parses the --not-before flag value, a timestamp or a duration
+// from now, into NotBefore
+func (o *Options) parseNotBefore(value string) error {
+	if value == "" {
+		return nil
+	}
+
+	notBefore, err := utils.ParseUntil(value, time.Now())
+	if err != nil {
+		return fmt.Errorf("invalid --not-before: %w", err)
+	}
+	o.NotBefore = notBefore
+	return nil
+}
+
+// parseHeaderHash parses the --header-hash flag value into HeaderHash
+func (o *Options) parseHeaderHash(value string) error {
+	alg, err := crypto.ParseHashAlgorithm(value)
+	if err != nil {
+		return err
+	}
+	o.HeaderHash = alg
+	return nil
+}
+
+// parseArgon2Variant parses the --argon2-variant flag value into KDFVariant
+func (o *Options) parseArgon2Variant(value string) error {
+	variant, err := crypto.ParseArgon2Variant(value)
+	if err != nil {
+		return err
+	}
+	o.KDFVariant = variant
+	return nil
+}
+
+// checkKDFFlags checks the --kdf-time and --kdf-memory values against the most any
+// header may record, naming the flag at fault; zero keeps the default. --kdf-threads
+// is bounded by its type
+func (o *Options) checkKDFFlags() error {
+	if o.KDFTime > constants.MaxArgonTime {
+		return fmt.Errorf("%w: --kdf-time must be at most %d, got %d", constants.ErrInvalidKDFParams, constants.MaxArgonTime, o.KDFTime)
+	}
+	if o.KDFMemory > constants.MaxArgonMemory {
+		return fmt.Errorf("%w: --kdf-memory must be at most %d KiB, got %d", constants.ErrInvalidKDFParams, constants.MaxArgonMemory, o.KDFMemory)
+	}
+	return nil
+}
+
+// parseCipher parses the --cipher flag value into Cipher, resolving auto
+// by whether the CPU has AES hardware support
+func (o *Options) parseCipher(value string) error {
+	alg, err := crypto.ParseCipher(value)
+	if err != nil {
+		return err
+	}
+	o.Cipher = crypto.SelectCipher(alg, crypto.HasAESHardware)
+	return nil
+}
+
+// parseCompression parses the --compression flag value into Compression
+func (o *Options) parseCompression(value string) error {
+	format, err := compression.ParseFormat(value)
+	if err != nil {
+		return err
+	}
+	o.Compression = format
+	return nil
+}
+
+// parseNonceStrategy parses the --nonce-strategy flag value. The counter
+// strategy keeps its state in the --nonce-state file, which the random one ignores
+func (o *Options) parseNonceStrategy(value string) error {
+	strategy, err := crypto.ParseNonceStrategy(value)
+	if err != nil {
+		return err
+	}
+	switch {
+	case strategy == constants.NonceCounter && o.NonceState == "":
+		return fmt.Errorf("--nonce-strategy counter requires --nonce-state")
+	case strategy == constants.NonceRandom && o.NonceState != "":
+		return fmt.Errorf("--nonce-state can only be used together with --nonce-strategy counter")
+	}
+	return nil
+}
+
+// parseSort parses the --sort flag value into Sort, the order a batch run
+// processes its files in
+func (o *Options) parseSort(value string) error {
+	order, err := files.ParseSortOrder(value)
+	if err != nil {
+		return err
+	}
+	if order != constants.SortNone && o.RecursiveDir == "" && o.FilesFrom == "" {
+		return fmt.Errorf("--sort can only be used together with --recursive or --files-from")
+	}
+	o.Sort = order
+	return nil
+}
+
+// parseDeletePattern parses the --secure-delete-pattern flag value into
+// DeletePattern. Choosing a pattern implies --secure-delete
+func (o *Options) parseDeletePattern(value string, changed bool) error {
+	pattern, err := files.ParseOverwritePattern(value)
+	if err != nil {
+		return err
+	}
+	o.DeletePattern = pattern
+	if changed {
+		o.SecureDelete = true
+	}
+	return nil
+}
+
+// encryptOptions returns the header options selected by the flags
+func (o *Options) encryptOptions() operations.EncryptOptions {
+	encOpts := operations.EncryptOptions{
+		Comment:            o.Comment,
+		MinPasswordLength:  o.MinPasswordLength,
+		HashAlgorithm:      o.HeaderHash,
+		Cipher:             o.Cipher,
+		SmallFileThreshold: o.SmallFileThreshold,
+		Merkle:             o.Merkle || o.MerkleTree != "",
+		HashOriginal:       o.HashOriginal,
+		NotBefore:          o.NotBefore,
+		Compression:        o.Compression,
+		Paranoid:           o.Paranoid,
+		Manifest:           o.Manifest != "",
+		RecoveryCode:       o.RecoveryCode,
+		ExtraPasswords:     o.ExtraPasswords,
+		CompressMetadata:   o.CompressMetadata,
+		EscrowKey:          o.EscrowPublicKey,
+		NonceState:         o.NonceState,
+		PadOutputTo:        o.PadOutputTo,
+	}
+	if o.AAD != "" {
+		encOpts.AAD = []byte(o.AAD)
+	}
+	if o.KDFTime != 0 || o.KDFMemory != 0 || o.KDFThreads != 0 || o.KDFVariant != constants.Argon2id {
+		// Unset flags keep their default, so --kdf-memory alone only raises memory
+		params := crypto.DefaultKDFParams()
+		if o.KDFTime != 0 {
+			params.Time = o.KDFTime
+		}
+		if o.KDFMemory != 0 {
+			params.Memory = o.KDFMemory
+		}
+		if o.KDFThreads != 0 {
+			params.Threads = o.KDFThreads
+		}
+		params.Variant = o.KDFVariant
+		encOpts.KDFParams = params
+	}
+	if o.StoreName {
+		encOpts.Filename = filepath.Base(o.InputFile)
+	}
+	return encOpts
+}
+
+// toStdout reports whether the output is written to standard output ("-o -")
+func (o *Options) toStdout() bool {
+	return o.OutputFile == stdoutPath
+}
+
+// destination returns where the encrypted or decrypted output is written
+func (o *Options) destination() string {
+	if o.OutputURL != "" {
+		return o.OutputURL
+	}
+	return o.OutputFile
+}
+
+// deleteOption returns the deletion method selected by the flags
+func (o *Options) deleteOption() constants.DeleteOption {
+	if o.SecureDelete {
+		return constants.DeleteSecure
+	}
+	return constants.DeleteStandard
+}
+
+// Syncer flushes written files and their directory entries to stable storage
+type Syncer interface {
+	SyncFile(path string) error
+	SyncDir(dir string) error
+}
+
 // CLIProcessor handles CLI-based encryption and decryption operations
 type CLIProcessor struct {
 	encryptor   *operations.Encryptor
 	decryptor   *operations.Decryptor
 	fileManager *files.Manager
+	fileFinder  *files.Finder
+	passwords   *ui.PasswordReader
+	syncer      Syncer
+	status      io.Writer // Status lines; standard error when the output itself goes to standard output
+	warnings    []string  // Non-fatal problems reported while the command ran
+	failOnWarn  bool      // CheckWarnings fails when any warning was reported
 }
 
 // NewCLIProcessor creates a new CLI processor instance
 func NewCLIProcessor() *CLIProcessor {
+	return NewCLIProcessorWithSyncer(files.NewManager())
+}
+
+// NewCLIProcessorWithSyncer creates a CLI processor that makes --durable output
+// durable through the given syncer
+func NewCLIProcessorWithSyncer(syncer Syncer) *CLIProcessor {
 	return &CLIProcessor{
 		encryptor:   operations.NewEncryptor(),
 		decryptor:   operations.NewDecryptor(),
 		fileManager: files.NewManager(),
+		fileFinder:  files.NewFinder(),
+		passwords:   ui.NewPasswordReader(),
+		syncer:      syncer,
+		status:      os.Stdout,
 	}
 }
 
-// Encrypt encrypts a file using CLI parameters
-func (p *CLIProcessor) Encrypt(inputFile, outputFile, password string, deleteSource, secureDelete bool) error {
-	// Get password if not provided
-	if password == "" {
-		var err error
-		password, err = p.promptPassword("Enter encryption password: ")
-		if err != nil {
-			return fmt.Errorf("failed to get password: %w", err)
-		}
+// SetLogger makes encryption and decryption log stage timings to logger; nil turns logging off
+func (p *CLIProcessor) SetLogger(logger *slog.Logger) {
+	p.encryptor.SetLogger(logger)
+	p.decryptor.SetLogger(logger)
+}
 
-		confirmPassword, err := p.promptPassword("Confirm password: ")
-		if err != nil {
-			return fmt.Errorf("failed to confirm password: %w", err)
-		}
+// SetIOTimeout makes encryption and decryption give up when no data is read or
+// written for timeout; 0 waits forever
+func (p *CLIProcessor) SetIOTimeout(timeout time.Duration) {
+	p.encryptor.SetIOTimeout(timeout)
+	p.decryptor.SetIOTimeout(timeout)
+}
 
-		if password != confirmPassword {
-			return constants.ErrPasswordMismatch
-		}
+// SetPepper sets the secret mixed into passwords when encrypting and when
+// decrypting files that were encrypted with one
+func (p *CLIProcessor) SetPepper(pepper string) {
+	p.encryptor.SetPepper([]byte(pepper))
+	p.decryptor.SetPepper([]byte(pepper))
+}
+
+// SetAAD sets the associated data decryption and verification must match
+func (p *CLIProcessor) SetAAD(aad string) {
+	p.decryptor.SetAAD([]byte(aad))
+}
+
+// SetFailOnWarning makes CheckWarnings fail once any warning has been reported
+func (p *CLIProcessor) SetFailOnWarning(enabled bool) {
+	p.failOnWarn = enabled
+}
+
+// Warnings returns the warnings reported so far
+func (p *CLIProcessor) Warnings() []string {
+	return p.warnings
+}
+
+// CheckWarnings returns ErrWarnings when warnings were reported and SetFailOnWarning
+// is on. It is meant to run after the command finished its work, so a warning
+// changes the exit status without cutting the operation short
+func (p *CLIProcessor) CheckWarnings() error {
+	if !p.failOnWarn || len(p.warnings) == 0 {
+		return nil
 	}
+	return fmt.Errorf("%w: %d warning(s)", constants.ErrWarnings, len(p.warnings))
+}
 
-	fmt.Printf("Encrypting: %s -> %s\n", inputFile, outputFile)
+// warn prints a warning and records it for CheckWarnings
+func (p *CLIProcessor) warn(format string, args ...any) {
+	message := fmt.Sprintf(format, args...)
+	p.warnings = append(p.warnings, message)
+	fmt.Fprintf(p.status, "Warning: %s\n", message)
+}
 
-	// Perform encryption
-	if err := p.encryptor.EncryptFile(inputFile, outputFile, password); err != nil {
-		return fmt.Errorf("encryption failed: %w", err)
+// printRecoveryCode shows the recovery code the files just encrypted can also be
+// unlocked with. It is kept nowhere else, so this is the only time it is seen
+func (p *CLIProcessor) printRecoveryCode(opts Options) {
+	if opts.RecoveryCode == "" {
+		return
 	}
+	fmt.Fprintf(p.status, "Recovery code: %s\n", opts.RecoveryCode)
+	fmt.Fprintln(p.status, "Write it down and keep it safe: it is shown only once and unlocks the output without the password")
+}
 
-	// Handle source file deletion if requested
-	if deleteSource {
-		deleteOption := constants.DeleteStandard
-		if secureDelete {
-			deleteOption = constants.DeleteSecure
-		}
+// Encrypt encrypts a file using CLI parameters
+func (p *CLIProcessor) Encrypt(opts Options) error {
+	password, save, err := p.resolvePassword(opts, constants.ModeEncrypt)
+	if err != nil {
+		return err
+	}
 
-		fmt.Printf("Deleting source file: %s\n", inputFile)
-		if err := p.fileManager.Remove(inputFile, deleteOption); err != nil {
-			fmt.Printf("Warning: Failed to delete source file: %v\n", err)
-		} else {
-			fmt.Printf("Source file deleted successfully\n")
-		}
+	if err := p.encryptOne(opts, password); err != nil {
+		return err
 	}
 
-	fmt.Printf("✓ File encrypted successfully: %s\n", outputFile)
+	p.savePassword(opts, save)
+	p.deleteSource(opts)
+	fmt.Fprintf(p.status, "✓ File encrypted successfully: %s\n", opts.destination())
+	p.printRecoveryCode(opts)
 	return nil
 }
 
 // Decrypt decrypts a file using CLI parameters
-func (p *CLIProcessor) Decrypt(inputFile, outputFile, password string, deleteSource, secureDelete bool) error {
-	// Get password if not provided
-	if password == "" {
-		var err error
-		password, err = p.promptPassword("Enter decryption password: ")
-		if err != nil {
-			return fmt.Errorf("failed to get password: %w", err)
+func (p *CLIProcessor) Decrypt(opts Options) error {
+	// The plaintext owns standard output, so status lines move to standard error
+	// and nothing is drawn that could end up in the stream
+	if opts.toStdout() {
+		p.status = os.Stderr
+		ui.SetProgressEnabled(false)
+	}
+
+	if err := p.checkDigest(opts); err != nil {
+		return err
+	}
+
+	password, save, err := p.resolvePassword(opts, constants.ModeDecrypt)
+	if err != nil {
+		return err
+	}
+
+	if err := p.decryptWithRetry(&opts, password); err != nil {
+		return err
+	}
+
+	p.savePassword(opts, save)
+	p.deleteSource(opts)
+	fmt.Fprintf(p.status, "✓ File decrypted successfully: %s\n", opts.OutputFile)
+	return nil
+}
+
+// Protect writes opts.InputFile to opts.OutputFile with Reed-Solomon protection
+// but no encryption
+func (p *CLIProcessor) Protect(opts Options) error {
+	fmt.Fprintf(p.status, "Protecting: %s -> %s\n", opts.InputFile, opts.OutputFile)
+
+	result, err := p.encryptor.ProtectFile(opts.InputFile, opts.OutputFile)
+	if err != nil {
+		return fmt.Errorf("protection failed: %w", err)
+	}
+	if err := p.makeDurable(opts); err != nil {
+		return err
+	}
+
+	p.printDigest(result)
+	fmt.Fprintf(p.status, "✓ File protected successfully: %s\n", opts.OutputFile)
+	return nil
+}
+
+// Append adds the record read from record to the append-only log at
+// opts.InputFile, creating the log when it does not exist yet
+func (p *CLIProcessor) Append(opts Options, record io.Reader) error {
+	mode := constants.ModeDecrypt
+	if !p.fileManager.FileExists(opts.InputFile) {
+		mode = constants.ModeEncrypt
+	}
+
+	password, save, err := p.resolvePassword(opts, mode)
+	if err != nil {
+		return err
+	}
+
+	data, err := io.ReadAll(io.LimitReader(record, constants.MaxLogRecordSize+1))
+	if err != nil {
+		return fmt.Errorf("failed to read record: %w", err)
+	}
+
+	index, err := p.encryptor.AppendRecord(opts.InputFile, password, data, opts.encryptOptions())
+	if err != nil {
+		return fmt.Errorf("append failed: %w", err)
+	}
+
+	p.savePassword(opts, save)
+	fmt.Fprintf(p.status, "✓ Record %d appended: %s\n", index+1, opts.InputFile)
+	return nil
+}
+
+// Recover restores the file written by Protect at opts.InputFile to
+// opts.OutputFile or standard output
+func (p *CLIProcessor) Recover(opts Options) error {
+	if opts.toStdout() {
+		p.status = os.Stderr
+		ui.SetProgressEnabled(false)
+	}
+
+	fmt.Fprintf(p.status, "Recovering: %s -> %s\n", opts.InputFile, opts.OutputFile)
+
+	if err := p.recoverTo(opts); err != nil {
+		return fmt.Errorf("recovery failed: %w", err)
+	}
+	if err := p.makeDurable(opts); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(p.status, "✓ File recovered successfully: %s\n", opts.OutputFile)
+	return nil
+}
+
+// recoverTo recovers opts.InputFile to opts.OutputFile or standard output
+func (p *CLIProcessor) recoverTo(opts Options) error {
+	if !opts.toStdout() {
+		_, err := p.decryptor.RecoverFile(opts.InputFile, opts.OutputFile)
+		return err
+	}
+
+	src, _, err := p.fileManager.OpenFile(opts.InputFile)
+	if err != nil {
+		return fmt.Errorf("failed to open source file: %w", err)
+	}
+	defer src.Close() //nolint:errcheck
+
+	_, err = p.decryptor.RecoverStream(src, os.Stdout)
+	return err
+}
+
+// EncryptBundle encrypts opts.BundleInputs into the single bundle opts.OutputFile
+func (p *CLIProcessor) EncryptBundle(opts Options) error {
+	password, save, err := p.resolvePassword(opts, constants.ModeEncrypt)
+	if err != nil {
+		return err
+	}
+
+	if !opts.NoSpaceCheck {
+		var total int64
+		for _, input := range opts.BundleInputs {
+			info, err := p.fileManager.GetFileInfo(input)
+			if err != nil {
+				return err
+			}
+			total += operations.EstimateEncryptedSize(info.Size())
+		}
+		if err := p.fileManager.CheckSpace(opts.OutputFile, total); err != nil {
+			return err
 		}
 	}
 
-	fmt.Printf("Decrypting: %s -> %s\n", inputFile, outputFile)
+	fmt.Fprintf(p.status, "Bundling %d file(s) -> %s\n", len(opts.BundleInputs), opts.OutputFile)
 
-	if err := p.decryptor.DecryptFile(inputFile, outputFile, password); err != nil {
-		return fmt.Errorf("decryption failed: %w", err)
+	result, err := p.encryptor.EncryptBundle(opts.BundleInputs, opts.OutputFile, password, opts.encryptOptions())
+	if err != nil {
+		return fmt.Errorf("encryption failed: %w", err)
+	}
+	if err := p.makeDurable(opts); err != nil {
+		return err
+	}
+	p.printDigest(result)
+
+	p.savePassword(opts, save)
+	for _, input := range opts.BundleInputs {
+		fileOpts := opts
+		fileOpts.InputFile = input
+		p.deleteSource(fileOpts)
+	}
+	fmt.Fprintf(p.status, "✓ %d file(s) bundled successfully: %s\n", len(opts.BundleInputs), opts.OutputFile)
+	p.printRecoveryCode(opts)
+	return nil
+}
+
+// EncryptDirectory encrypts every eligible file below opts.RecursiveDir
+func (p *CLIProcessor) EncryptDirectory(opts Options) error {
+	if opts.Index {
+		return p.encryptIndexed(opts)
+	}
+	return p.processDirectory(opts, constants.ModeEncrypt)
+}
+
+// DecryptDirectory decrypts every encrypted file below opts.RecursiveDir
+func (p *CLIProcessor) DecryptDirectory(opts Options) error {
+	if opts.Index {
+		return p.decryptIndexed(opts)
+	}
+	return p.processDirectory(opts, constants.ModeDecrypt)
+}
+
+// EncryptFileList encrypts every path listed in list, one per line
+func (p *CLIProcessor) EncryptFileList(opts Options, list io.Reader) error {
+	return p.processFileList(opts, list, constants.ModeEncrypt)
+}
+
+// DecryptFileList decrypts every path listed in list, one per line
+func (p *CLIProcessor) DecryptFileList(opts Options, list io.Reader) error {
+	return p.processFileList(opts, list, constants.ModeDecrypt)
+}
+
+// findEligibleFiles searches the tree rooted at root for the files eligible for mode,
+// warning about each path that could not be read and was left out
+func (p *CLIProcessor) findEligibleFiles(root string, mode constants.ProcessorMode, since time.Time) ([]string, error) {
+	paths, skipped, err := p.fileFinder.FindEligibleFilesIn(root, mode, since)
+	if err != nil {
+		return nil, err
+	}
+	for _, s := range skipped {
+		p.warn("skipped %s: %v", s.Path, s.Err)
+	}
+	if len(skipped) > 0 {
+		fmt.Fprintf(p.status, "%d unreadable path(s) in %s skipped\n", len(skipped), root)
+	}
+	return paths, nil
+}
+
+// processDirectory runs the given operation over all eligible files in a directory tree
+func (p *CLIProcessor) processDirectory(opts Options, mode constants.ProcessorMode) error {
+	paths, err := p.findEligibleFiles(opts.RecursiveDir, mode, opts.Since)
+	if err != nil {
+		return fmt.Errorf("failed to scan directory: %w", err)
+	}
+
+	if len(paths) == 0 {
+		fmt.Fprintf(p.status, "No eligible files found in %s\n", opts.RecursiveDir)
+		return nil
+	}
+	return p.processPaths(opts, mode, paths)
+}
+
+// processFileList runs the given operation over the paths read from list. The whole
+// list is read before the password is asked for, since both may come from standard input
+func (p *CLIProcessor) processFileList(opts Options, list io.Reader, mode constants.ProcessorMode) error {
+	paths, err := p.fileFinder.ReadPathList(list)
+	if err != nil {
+		return err
+	}
+
+	if len(paths) == 0 {
+		fmt.Fprintln(p.status, "No files listed")
+		return nil
+	}
+	return p.processPaths(opts, mode, paths)
+}
+
+// processPaths runs the given operation over a batch of files, asking for the
+// password once and reporting a summary at the end. When any file fails, the
+// returned *BatchError lists each of them with its error
+func (p *CLIProcessor) processPaths(opts Options, mode constants.ProcessorMode, paths []string) error {
+	password, save, err := p.resolvePassword(opts, mode)
+	if err != nil {
+		return err
+	}
+
+	if err := p.fileFinder.SortFiles(paths, opts.Sort); err != nil {
+		return err
+	}
+
+	var seen dedupIndex
+	if mode == constants.ModeEncrypt && opts.Dedup {
+		seen = dedupIndex{}
+	}
+	// A reference needs its target, which deleting sources would remove first
+	if mode == constants.ModeDecrypt && opts.DeleteSource {
+		paths = p.referencesFirst(paths)
 	}
 
-	// Handle source file deletion if requested
-	if deleteSource {
-		deleteOption := constants.DeleteStandard
-		if secureDelete {
-			deleteOption = constants.DeleteSecure
+	layout := newOutputLayout(opts)
+	batch := &BatchError{Mode: mode, Total: len(paths)}
+	var processed int
+	for _, inputFile := range paths {
+		if err := p.fileFinder.CheckListedFile(inputFile, mode); err != nil {
+			fmt.Fprintf(p.status, "✗ %s: %v\n", inputFile, err)
+			batch.add(inputFile, err)
+			continue
+		}
+
+		outputFile, err := layout.place(inputFile, p.fileFinder.GetOutputPathWithSuffix(inputFile, mode, opts.OutputSuffix))
+		if err != nil {
+			fmt.Fprintf(p.status, "✗ %s: %v\n", inputFile, err)
+			batch.add(inputFile, err)
+			continue
+		}
+		if p.fileManager.FileExists(outputFile) {
+			p.warn("Skipping %s: output file already exists: %s", inputFile, outputFile)
+			continue
 		}
 
-		fmt.Printf("Deleting source file: %s\n", inputFile)
-		if err := p.fileManager.Remove(inputFile, deleteOption); err != nil {
-			fmt.Printf("Warning: Failed to delete source file: %v\n", err)
+		fileOpts := opts
+		fileOpts.InputFile = inputFile
+		fileOpts.OutputFile = outputFile
+
+		if mode == constants.ModeEncrypt {
+			err = p.encryptDeduped(fileOpts, password, seen)
 		} else {
-			fmt.Printf("Source file deleted successfully\n")
+			err = p.decryptOne(fileOpts, password)
+		}
+		if err != nil {
+			fmt.Fprintf(p.status, "✗ %s: %v\n", inputFile, err)
+			batch.add(inputFile, err)
+			continue
 		}
+
+		p.deleteSource(fileOpts)
+		processed++
 	}
 
-	fmt.Printf("✓ File decrypted successfully: %s\n", outputFile)
-	return nil
+	if processed > 0 {
+		p.savePassword(opts, save)
+	}
+
+	fmt.Fprintf(p.status, "✓ %d of %d file(s) processed successfully\n", processed, len(paths))
+	if mode == constants.ModeEncrypt && processed > 0 {
+		p.printRecoveryCode(opts)
+	}
+	batch.printTable(p.status)
+	return batch.err()
 }
 
-// promptPassword prompts for a password without echoing to terminal
-func (p *CLIProcessor) promptPassword(prompt string) (string, error) {
-	fmt.Print(prompt)
-	bytePassword, err := term.ReadPassword(int(syscall.Stdin))
+// Estimate reports what encrypting the given files would cost, without encrypting them.
+// Paths that could not be encrypted are listed and left out of the totals
+func (p *CLIProcessor) Estimate(paths []string) error {
+	var sizes []int64
+	for _, path := range paths {
+		if err := p.fileFinder.CheckListedFile(path, constants.ModeEncrypt); err != nil {
+			fmt.Fprintf(p.status, "Skipping %v\n", err)
+			continue
+		}
+		info, err := p.fileManager.GetFileInfo(path)
+		if err != nil {
+			fmt.Fprintf(p.status, "Skipping %v\n", err)
+			continue
+		}
+		sizes = append(sizes, info.Size())
+	}
+
+	if len(sizes) == 0 {
+		fmt.Fprintln(p.status, "No eligible files to estimate")
+		return nil
+	}
+
+	fmt.Fprintln(p.status, "Probing throughput...")
+	throughput, err := operations.ProbeThroughput()
 	if err != nil {
-		return "", err
+		return err
+	}
+
+	estimate := operations.EstimateFiles(sizes, throughput)
+	fmt.Fprintf(p.status, "Files:           %d\n", estimate.Files)
+	fmt.Fprintf(p.status, "Input size:      %s\n", utils.FormatBytes(estimate.InputBytes))
+	fmt.Fprintf(p.status, "Output size:     up to %s\n", utils.FormatBytes(estimate.OutputBytes))
+	fmt.Fprintf(p.status, "Overhead:        up to %s (%.0f%% of the input)\n", utils.FormatBytes(estimate.Overhead), overheadPercent(estimate))
+	fmt.Fprintf(p.status, "Key derivations: %d (%s each)\n", estimate.KDFRuns, throughput.KDFDuration.Round(time.Millisecond))
+	fmt.Fprintf(p.status, "Estimated time:  ~%s at %s/s\n", estimate.Duration.Round(time.Second), utils.FormatBytes(int64(throughput.BytesPerSecond)))
+	return nil
+}
+
+// Version prints the application version and, with showFormat, the container
+// format version written by default and the versions that can be read
+func (p *CLIProcessor) Version(showFormat bool) error {
+	fmt.Fprintf(p.status, "%s %s\n", constants.AppName, constants.AppVersion)
+	if !showFormat {
+		return nil
+	}
+
+	readable := make([]string, 0, len(crypto.ReadableFormatVersions()))
+	for _, version := range crypto.ReadableFormatVersions() {
+		readable = append(readable, strconv.Itoa(int(version)))
+	}
+	fmt.Fprintf(p.status, "format_write=%d format_read=[%s]\n", crypto.DefaultFormatVersion, strings.Join(readable, ","))
+	return nil
+}
+
+// SelftestVectors decrypts the known-answer files built into the binary and
+// reports each, failing unless all give back their recorded plaintext
+func (p *CLIProcessor) SelftestVectors() error {
+	results, err := p.decryptor.CheckVectors()
+	for _, result := range results {
+		if result.Err != nil {
+			fmt.Fprintf(p.status, "✗ %s (%s): %v\n", result.Vector.File, result.Vector.Description, result.Err)
+		} else {
+			fmt.Fprintf(p.status, "✓ %s (%s)\n", result.Vector.File, result.Vector.Description)
+		}
+	}
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(p.status, "✓ %d known-answer vector(s) passed\n", len(results))
+	return nil
+}
+
+// Match lists which of paths password unlocks, checking headers only.
+// Directories are searched for encrypted files. When sameSaltAs names an
+// encrypted file, only files sharing its salt are checked. With null the
+// matching paths alone go to standard output, each ending in a NUL byte as
+// with find -print0, and status lines move to standard error. Files whose
+// key derivation costs more than limit are skipped with a warning
+func (p *CLIProcessor) Match(paths []string, password, sameSaltAs string, null bool, limit crypto.KDFParams) error {
+	if limit.Time == 0 || limit.Memory == 0 {
+		return fmt.Errorf("--max-kdf-time and --max-kdf-memory must be positive")
+	}
+	if null {
+		p.status = os.Stderr
+	}
+
+	inputs, err := p.collectEncrypted(paths)
+	if err != nil {
+		return err
+	}
+
+	var salt []byte
+	if sameSaltAs != "" {
+		if salt, err = p.decryptor.HeaderSalt(sameSaltAs); err != nil {
+			return fmt.Errorf("%s: %w", sameSaltAs, err)
+		}
+	}
+
+	password, err = p.decryptionPassword(password)
+	if err != nil {
+		return err
+	}
+
+	// Every salt costs a full Argon2id run, which dominates the time taken
+	fmt.Fprintf(p.status, "Checking %d file(s); each one with its own salt takes one key derivation\n", len(inputs))
+	report := p.decryptor.Match(inputs, password, salt, limit)
+	skipped := 0
+	for _, result := range report.Results {
+		switch {
+		case result.Err != nil:
+			p.warn("%s: %v", result.Path, result.Err)
+		case result.Skipped:
+			skipped++
+		case result.Matched && null:
+			if err := printNullPath(result.Path); err != nil {
+				return err
+			}
+		case result.Matched:
+			fmt.Fprintf(p.status, "✓ %s\n", result.Path)
+		}
+	}
+
+	if skipped > 0 {
+		fmt.Fprintf(p.status, "%d file(s) with another salt skipped\n", skipped)
+	}
+	fmt.Fprintf(p.status, "%d of %d file(s) unlock with this password (%d key derivation(s))\n",
+		len(report.Matched()), len(inputs)-skipped, report.Derivations)
+	return nil
+}
+
+// collectEncrypted returns paths with every directory among them replaced by
+// the encrypted files found in it
+func (p *CLIProcessor) collectEncrypted(paths []string) ([]string, error) {
+	var inputs []string
+	for _, path := range paths {
+		info, err := p.fileManager.GetFileInfo(path)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			inputs = append(inputs, path)
+			continue
+		}
+		found, err := p.findEligibleFiles(path, constants.ModeDecrypt, time.Time{})
+		if err != nil {
+			return nil, fmt.Errorf("failed to search %s: %w", path, err)
+		}
+		inputs = append(inputs, found...)
+	}
+	return inputs, nil
+}
+
+// printNullPath writes path to standard output ending in a NUL byte, as with
+// find -print0, for the -0 option of the commands listing paths
+func printNullPath(path string) error {
+	if _, err := fmt.Fprintf(os.Stdout, "%s\x00", path); err != nil {
+		return fmt.Errorf("%w: %v", constants.ErrFileWriteFailed, err)
+	}
+	return nil
+}
+
+// KDFBench times key derivation over a grid of Argon2id settings with at most
+// maxMemory KiB and recommends the costliest one that finishes within target
+func (p *CLIProcessor) KDFBench(target time.Duration, maxMemory uint32) error {
+	if target <= 0 {
+		return fmt.Errorf("--target must be positive: %s", target)
+	}
+
+	grid := operations.DefaultKDFGrid(maxMemory)
+	if len(grid.Memory) == 0 {
+		return fmt.Errorf("--max-memory must be at least 32 MiB")
+	}
+
+	fmt.Fprintf(p.status, "Timing Argon2id key derivation against a %s target...\n", target)
+	fmt.Fprintf(p.status, "%-10s %-6s %-8s %s\n", "Memory", "Time", "Threads", "Duration")
+	results, err := operations.BenchmarkKDF(grid, target, operations.TimeKDF, func(result operations.KDFBenchResult) {
+		marker := ""
+		if result.Duration > target {
+			marker = "  (over target)"
+		}
+		fmt.Fprintf(p.status, "%-10s %-6d %-8d %s%s\n",
+			utils.FormatBytes(int64(result.Params.Memory)*1024), result.Params.Time, result.Params.Threads,
+			result.Duration.Round(time.Millisecond), marker)
+	})
+	if err != nil {
+		return err
+	}
+
+	best, err := operations.RecommendKDF(results, target)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(p.status, "\nRecommended (%s): --kdf-memory %d --kdf-time %d --kdf-threads %d\n",
+		best.Duration.Round(time.Millisecond), best.Params.Memory, best.Params.Time, best.Params.Threads)
+	return nil
+}
+
+// overheadPercent returns the estimated overhead as a percentage of the input
+func overheadPercent(estimate operations.Estimate) float64 {
+	if estimate.InputBytes == 0 {
+		return 0
+	}
+	return float64(estimate.Overhead) / float64(estimate.InputBytes) * 100
+}
+
+// withLocks runs fn while holding advisory locks on opts.InputFile and opts.OutputFile
+// when --lock is set. The output is created empty so it can be locked before it is
+// written, and is removed again when fn fails
+func (p *CLIProcessor) withLocks(opts Options, fn func() error) error {
+	if !opts.Lock {
+		return fn()
+	}
+
+	src, err := p.fileManager.LockFile(opts.InputFile)
+	if err != nil {
+		return err
+	}
+	defer src.Unlock() //nolint:errcheck
+
+	// Uploads and standard output have no local output to lock, and pipes and devices are not claimed
+	if opts.OutputURL != "" || opts.toStdout() || p.fileManager.IsStreamTarget(opts.OutputFile) {
+		return fn()
+	}
+
+	dst, err := p.fileManager.LockNewFile(opts.OutputFile)
+	if err != nil {
+		return err
+	}
+
+	err = fn()
+	_ = dst.Unlock()
+	if err != nil {
+		_ = os.Remove(opts.OutputFile)
+	}
+	return err
+}
+
+// encryptOne encrypts opts.InputFile to opts.OutputFile with an already resolved password
+func (p *CLIProcessor) encryptOne(opts Options, password string) error {
+	return p.withLocks(opts, func() error {
+		return p.encryptUnlocked(opts, password)
+	})
+}
+
+// encryptUnlocked encrypts a single file once any requested locks are held
+func (p *CLIProcessor) encryptUnlocked(opts Options, password string) error {
+	encOpts, err := p.fileEncryptOptions(opts)
+	if err != nil {
+		return err
+	}
+
+	if opts.OutputURL != "" {
+		return p.encryptToURL(opts, password, encOpts)
+	}
+
+	if err := p.checkSpace(opts); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(p.status, "Encrypting: %s -> %s\n", opts.InputFile, opts.OutputFile)
+
+	result, err := p.encryptor.EncryptFile(opts.InputFile, opts.OutputFile, password, encOpts)
+	if err != nil {
+		return fmt.Errorf("encryption failed: %w", err)
+	}
+	if opts.MerkleTree != "" {
+		if err := operations.WriteMerkleTree(opts.MerkleTree, result.Merkle); err != nil {
+			return fmt.Errorf("failed to write Merkle tree: %w", err)
+		}
+	}
+	if opts.Manifest != "" {
+		if err := operations.WriteManifest(opts.Manifest, result.Manifest); err != nil {
+			return fmt.Errorf("failed to write manifest: %w", err)
+		}
+	}
+	if opts.ExportKeyTo != "" {
+		if err := operations.WriteEscrowedKey(opts.ExportKeyTo, result.EscrowedKey); err != nil {
+			return fmt.Errorf("failed to export key: %w", err)
+		}
+		fmt.Fprintf(p.status, "Escrowed key written to %s\n", opts.ExportKeyTo)
+	}
+	if err := p.makeDurable(opts); err != nil {
+		return err
+	}
+
+	p.printDigest(result)
+	return nil
+}
+
+// makeDurable flushes opts.OutputFile and its directory entry when --durable is set,
+// so a reported success survives a crash. It runs before the source is deleted
+func (p *CLIProcessor) makeDurable(opts Options) error {
+	if !opts.Durable || opts.toStdout() {
+		return nil
+	}
+	if err := p.syncer.SyncFile(opts.OutputFile); err != nil {
+		return err
+	}
+	return p.syncer.SyncDir(filepath.Dir(opts.OutputFile))
+}
+
+// fileEncryptOptions returns the encryption options for opts.InputFile, adding a
+// thumbnail when --thumbnail is set and the input is an image
+func (p *CLIProcessor) fileEncryptOptions(opts Options) (operations.EncryptOptions, error) {
+	encOpts := opts.encryptOptions()
+	if !opts.Thumbnail {
+		return encOpts, nil
+	}
+
+	file, _, err := p.fileManager.OpenFile(opts.InputFile)
+	if err != nil {
+		return encOpts, err
+	}
+	defer file.Close() //nolint:errcheck
+
+	thumbnail, err := utils.Thumbnail(file, constants.ThumbnailMaxDim)
+	if errors.Is(err, constants.ErrNotAnImage) {
+		fmt.Fprintf(p.status, "No thumbnail for %s: %v\n", opts.InputFile, err)
+		return encOpts, nil
+	}
+	if err != nil {
+		return encOpts, err
+	}
+
+	encOpts.Thumbnail = thumbnail
+	return encOpts, nil
+}
+
+// encryptToURL encrypts opts.InputFile and uploads the result to opts.OutputURL
+func (p *CLIProcessor) encryptToURL(opts Options, password string, encOpts operations.EncryptOptions) error {
+	fmt.Fprintf(p.status, "Encrypting: %s -> %s\n", opts.InputFile, opts.OutputURL)
+
+	srcFile, srcInfo, err := p.fileManager.OpenFile(opts.InputFile)
+	if err != nil {
+		return fmt.Errorf("failed to open source file: %w", err)
+	}
+	defer srcFile.Close() //nolint:errcheck
+
+	uploader, err := remote.NewUploader(opts.OutputURL)
+	if err != nil {
+		return err
+	}
+
+	result, err := p.encryptor.EncryptStream(srcFile, uploader, srcInfo.Size(), password, encOpts)
+	if err != nil {
+		uploader.Abort(err)
+		return fmt.Errorf("encryption failed: %w", err)
+	}
+	if err := uploader.Close(); err != nil {
+		return err
+	}
+
+	p.printDigest(result)
+	return nil
+}
+
+// printDigest prints the digest of the encrypted output for backup catalogs,
+// when it could be taken while writing
+func (p *CLIProcessor) printDigest(result *operations.EncryptResult) {
+	if result.SHA256 != nil {
+		fmt.Fprintf(p.status, "SHA-256: %x\n", result.SHA256)
+	}
+	if result.OriginalSHA256 != nil {
+		fmt.Fprintf(p.status, "Original SHA-256: %x\n", result.OriginalSHA256)
+	}
+}
+
+// decryptWithRetry decrypts opts.InputFile, asking again when a password typed at
+// the terminal is wrong. Passwords from flags, pipes or the keychain are tried once.
+// With --restore-name, opts.OutputFile is set to the restored name
+func (p *CLIProcessor) decryptWithRetry(opts *Options, password string) error {
+	attempt := func(password string) error {
+		if opts.RestoreName {
+			outputFile, err := p.restoredOutput(opts.InputFile, password)
+			if err != nil {
+				return err
+			}
+			opts.OutputFile = outputFile
+		}
+		return p.decryptOne(*opts, password)
+	}
+
+	if opts.Password != "" || opts.Keychain != "" || opts.RecoveryCode != "" || opts.EscrowPrivateKey != nil || !p.passwords.Interactive() {
+		return attempt(password)
+	}
+
+	policy := operations.DefaultRetryPolicy()
+	if opts.PasswordAttempts > 0 {
+		policy.Attempts = opts.PasswordAttempts
+	}
+	policy.OnRetry = func(remaining int) {
+		fmt.Fprintf(p.status, "Wrong password, %d attempt(s) left\n", remaining)
+	}
+
+	return policy.Run(password, p.passwords.DecryptionPassword, attempt)
+}
+
+// restoredOutput returns the output path named by the filename stored in the header
+// of inputFile, placed next to it. A stored name that is not a plain file name,
+// such as one holding ".." or an absolute path, is refused
+func (p *CLIProcessor) restoredOutput(inputFile, password string) (string, error) {
+	info, err := p.decryptor.Inspect(inputFile, password)
+	if err != nil {
+		return "", err
+	}
+	if !info.HasFilename {
+		return "", fmt.Errorf("%w: %s", constants.ErrNoStoredName, inputFile)
+	}
+
+	outputFile, err := utils.SafeJoin(filepath.Dir(inputFile), info.Filename)
+	if err != nil {
+		return "", fmt.Errorf("refusing stored filename: %w", err)
+	}
+	if p.fileManager.FileExists(outputFile) && !p.fileManager.IsStreamTarget(outputFile) {
+		return "", fmt.Errorf("output file already exists: %s", outputFile)
+	}
+	return outputFile, nil
+}
+
+// decryptOne decrypts opts.InputFile to opts.OutputFile with an already resolved password
+func (p *CLIProcessor) decryptOne(opts Options, password string) error {
+	return p.withLocks(opts, func() error {
+		return p.decryptUnlocked(opts, password)
+	})
+}
+
+// decryptUnlocked decrypts a single file once any requested locks are held
+func (p *CLIProcessor) decryptUnlocked(opts Options, password string) error {
+	fmt.Fprintf(p.status, "Decrypting: %s -> %s\n", opts.InputFile, opts.OutputFile)
+
+	info, err := p.decryptTo(opts, password)
+	if err != nil {
+		return fmt.Errorf("decryption failed: %w", err)
+	}
+	if err := p.makeDurable(opts); err != nil {
+		return err
+	}
+
+	if info != nil && opts.VerifyHash {
+		fmt.Fprintf(p.status, "Original SHA-256 verified: %x\n", info.Original)
+	}
+	if info != nil && len(info.Holes) > 0 {
+		p.reportHoles(opts.OutputFile, info.Holes)
+	}
+	if info != nil && info.Comment != "" {
+		fmt.Fprintf(p.status, "Comment: %s\n", info.Comment)
+	}
+	return nil
+}
+
+// reportHoles lists the ranges of outputFile that were written as zeros because
+// their chunks could not be decrypted
+func (p *CLIProcessor) reportHoles(outputFile string, holes []streaming.Hole) {
+	var lost int64
+	for _, hole := range holes {
+		fmt.Fprintf(p.status, "Hole: offset %d, %d bytes zeroed\n", hole.Offset, hole.Length)
+		lost += hole.Length
+	}
+	p.warn("%s is incomplete: %d corrupt chunk(s), %s zeroed", outputFile, len(holes), utils.FormatBytes(lost))
+}
+
+// decryptTo decrypts opts.InputFile to opts.OutputFile, or streams the plaintext
+// to standard output for "-", where a failure may leave part of it already written
+func (p *CLIProcessor) decryptTo(opts Options, password string) (*operations.HeaderInfo, error) {
+	p.decryptor.SetIgnoreTimelock(opts.IgnoreTimelock)
+	p.decryptor.SetIgnoreCorruptChunks(opts.IgnoreCorrupt)
+	p.SetAAD(opts.AAD)
+	if opts.Extract != "" {
+		return nil, p.extractTo(opts, password)
+	}
+	if opts.Range != nil {
+		return p.decryptRangeTo(opts, password)
+	}
+	p.decryptor.SetVerifyHash(opts.VerifyHash)
+	if !opts.toStdout() {
+		return p.decryptor.DecryptFile(opts.InputFile, opts.OutputFile, password)
+	}
+
+	src, _, err := p.fileManager.OpenFile(opts.InputFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open source file: %w", err)
+	}
+	defer src.Close() //nolint:errcheck
+
+	return p.decryptor.DecryptStream(src, os.Stdout, password)
+}
+
+// decryptRangeTo decrypts the bytes in opts.Range to opts.OutputFile or standard output
+func (p *CLIProcessor) decryptRangeTo(opts Options, password string) (*operations.HeaderInfo, error) {
+	if opts.toStdout() {
+		return p.decryptor.DecryptRangeTo(opts.InputFile, os.Stdout, password, *opts.Range)
+	}
+	return p.decryptor.DecryptRange(opts.InputFile, opts.OutputFile, password, *opts.Range)
+}
+
+// extractTo decrypts the bundle member opts.Extract to opts.OutputFile or standard output
+func (p *CLIProcessor) extractTo(opts Options, password string) error {
+	if opts.toStdout() {
+		_, err := p.decryptor.ExtractMemberTo(opts.InputFile, opts.Extract, os.Stdout, password)
+		return err
+	}
+	_, err := p.decryptor.ExtractMember(opts.InputFile, opts.Extract, opts.OutputFile, password)
+	return err
+}
+
+// Info prints the header details of an encrypted file, unlocking
+// confidential fields when a password is given
+func (p *CLIProcessor) Info(inputFile, password string) error {
+	info, err := p.decryptor.Inspect(inputFile, password)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(p.status, "File:           %s\n", inputFile)
+	fmt.Fprintf(p.status, "Format version: %d\n", info.Version)
+	if info.SizeFooter {
+		fmt.Fprintf(p.status, "Original size:  %s\n", confidential(utils.FormatBytes(int64(info.OriginalSize)), info.Unlocked))
+	} else {
+		fmt.Fprintf(p.status, "Original size:  %s\n", utils.FormatBytes(int64(info.OriginalSize)))
+	}
+	if info.Unencrypted {
+		fmt.Fprintf(p.status, "Encryption:     none (Reed-Solomon only, written by protect)\n")
+	} else {
+		fmt.Fprintf(p.status, "Key derivation: %s (time=%d, memory=%d KiB, threads=%d)\n",
+			info.KDFParams.Variant, info.KDFParams.Time, info.KDFParams.Memory, info.KDFParams.Threads)
+	}
+	fmt.Fprintf(p.status, "Header hash:    %s\n", info.HeaderHash)
+	if !info.Unencrypted {
+		fmt.Fprintf(p.status, "Cipher:         %s\n", info.Cipher)
+	}
+	if info.Compression != constants.CompressionGzip {
+		fmt.Fprintf(p.status, "Compression:    %s\n", info.Compression)
+	}
+	if info.Nonce != constants.NonceRandom {
+		fmt.Fprintf(p.status, "Nonce:          %s %d\n", info.Nonce, info.NonceCounter)
+	}
+	if info.Reference {
+		fmt.Fprintf(p.status, "Body:           dedup reference to another encrypted file\n")
+	} else if info.RawBody {
+		fmt.Fprintf(p.status, "Body:           raw (small-file fast path, no Reed-Solomon parity)\n")
+	}
+	if info.PaddedSize > 0 {
+		fmt.Fprintf(p.status, "Padded output:  to %s (filler after the chunks, checked on decrypt)\n", utils.FormatBytes(int64(info.PaddedSize)))
+	}
+	if info.Log {
+		fmt.Fprintf(p.status, "Body:           append-only log, %d record(s)\n", info.Records)
+	}
+	if info.Bundle {
+		fmt.Fprintf(p.status, "Body:           bundle\n")
+		if !info.Unlocked {
+			fmt.Fprintf(p.status, "Members:        %s\n", confidential("", false))
+		}
+		for _, member := range info.Members {
+			fmt.Fprintf(p.status, "Member:         %s (%s)\n", member.Name, utils.FormatBytes(member.Size))
+		}
+	}
+	if info.Hint != "" {
+		fmt.Fprintf(p.status, "Password hint:  %s\n", info.Hint)
+	}
+	if !info.NotBefore.IsZero() {
+		state := "passed"
+		if time.Now().Before(info.NotBefore) {
+			state = "locked"
+		}
+		fmt.Fprintf(p.status, "Not before:     %s (%s, checked against this machine's clock)\n", utils.FormatTimestamp(info.NotBefore), state)
+	}
+	if info.AAD {
+		fmt.Fprintln(p.status, "Bound to AAD:   yes (decrypt needs the same --aad)")
+	}
+	if info.Peppered {
+		fmt.Fprintln(p.status, "Pepper:         required (set HEXWARDEN_PEPPER to decrypt)")
+	}
+	if info.Recoverable {
+		fmt.Fprintln(p.status, "Recovery code:  yes (decrypt --recovery-code unlocks it without the password)")
+	}
+	if info.KeySlots > 0 {
+		fmt.Fprintf(p.status, "Key slots:      %d (other passwords that unlock it)\n", info.KeySlots)
+	}
+	if info.HasFilename {
+		fmt.Fprintf(p.status, "Filename:       %s\n", confidential(info.Filename, info.Unlocked))
+	}
+	if info.HasComment {
+		fmt.Fprintf(p.status, "Comment:        %s\n", confidential(info.Comment, info.Unlocked))
+	}
+	if info.HasThumbnail {
+		fmt.Fprintf(p.status, "Thumbnail:      %s\n", confidential(utils.FormatBytes(int64(len(info.Thumbnail)))+" JPEG", info.Unlocked))
+	}
+	if info.MerkleRoot != nil {
+		fmt.Fprintf(p.status, "Merkle root:    %x\n", info.MerkleRoot)
+	}
+	if info.HasOriginal {
+		fmt.Fprintf(p.status, "Original hash:  %s\n", confidential(fmt.Sprintf("SHA-256 %x", info.Original), info.Unlocked))
+	}
+	return nil
+}
+
+// Thumbnail writes the encrypted image preview of inputFile to outputFile
+func (p *CLIProcessor) Thumbnail(inputFile, outputFile, password string) error {
+	password, err := p.decryptionPassword(password)
+	if err != nil {
+		return err
+	}
+
+	info, err := p.decryptor.Inspect(inputFile, password)
+	if err != nil {
+		return err
+	}
+	if !info.HasThumbnail {
+		return fmt.Errorf("%w: %s", constants.ErrNoThumbnail, inputFile)
+	}
+
+	if err := os.WriteFile(outputFile, info.Thumbnail, 0o600); err != nil {
+		return fmt.Errorf("%w: %v", constants.ErrFileWriteFailed, err)
+	}
+	fmt.Fprintf(p.status, "✓ Thumbnail written: %s\n", outputFile)
+	return nil
+}
+
+// StripMetadata removes the identifying metadata from the header of inputFile without touching its body
+func (p *CLIProcessor) StripMetadata(inputFile, password string) error {
+	password, err := p.decryptionPassword(password)
+	if err != nil {
+		return err
+	}
+
+	removed, err := p.decryptor.StripMetadata(inputFile, password)
+	if err != nil {
+		return fmt.Errorf("failed to strip metadata: %w", err)
+	}
+	if len(removed) == 0 {
+		fmt.Fprintf(p.status, "✓ No metadata to strip: %s\n", inputFile)
+		return nil
+	}
+	fmt.Fprintf(p.status, "✓ Stripped %s: %s\n", strings.Join(removed, ", "), inputFile)
+	return nil
+}
+
+// SetName replaces the filename stored in the header of inputFile without touching its body
+func (p *CLIProcessor) SetName(inputFile, name, password string) error {
+	if name != "" {
+		if err := utils.ValidateName(name); err != nil {
+			return fmt.Errorf("invalid name: %w", err)
+		}
+	}
+
+	password, err := p.decryptionPassword(password)
+	if err != nil {
+		return err
+	}
+
+	if err := p.decryptor.SetFilename(inputFile, password, name); err != nil {
+		return fmt.Errorf("failed to set name: %w", err)
+	}
+	if name == "" {
+		fmt.Fprintf(p.status, "✓ Stored name removed: %s\n", inputFile)
+		return nil
+	}
+	fmt.Fprintf(p.status, "✓ Stored name set to %s: %s\n", name, inputFile)
+	return nil
+}
+
+// AddKey lets newPassword unlock inputFile too, prompting for it with
+// confirmation when it is not given
+func (p *CLIProcessor) AddKey(inputFile, password, newPassword string) error {
+	password, err := p.decryptionPassword(password)
+	if err != nil {
+		return err
+	}
+	if newPassword == "" {
+		if newPassword, err = p.passwords.EncryptionPassword(true); err != nil {
+			return err
+		}
+	}
+
+	if err := p.decryptor.AddKey(inputFile, password, newPassword); err != nil {
+		return fmt.Errorf("failed to add key: %w", err)
+	}
+	fmt.Fprintf(p.status, "✓ Password added: %s\n", inputFile)
+	return nil
+}
+
+// RemoveKey stops removePassword from unlocking inputFile, prompting for it
+// when it is not given
+func (p *CLIProcessor) RemoveKey(inputFile, password, removePassword string) error {
+	password, err := p.decryptionPassword(password)
+	if err != nil {
+		return err
+	}
+	if removePassword == "" {
+		if removePassword, err = p.passwords.DecryptionPassword(); err != nil {
+			return err
+		}
+	}
+
+	if err := p.decryptor.RemoveKey(inputFile, password, removePassword); err != nil {
+		return fmt.Errorf("failed to remove key: %w", err)
+	}
+	fmt.Fprintf(p.status, "✓ Password removed: %s\n", inputFile)
+	return nil
+}
+
+// Recompress rewrites the chunks of inputFile compressed in format
+func (p *CLIProcessor) Recompress(inputFile, password string, format constants.CompressionFormat) error {
+	password, err := p.decryptionPassword(password)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(p.status, "Recompressing: %s\n", inputFile)
+	if err := p.decryptor.Recompress(inputFile, password, format); err != nil {
+		return fmt.Errorf("recompression failed: %w", err)
+	}
+	fmt.Fprintf(p.status, "✓ Chunks now compressed with %s: %s\n", format, inputFile)
+	return nil
+}
+
+// Scan checks the Reed-Solomon parity of encrypted files and reports corrupt
+// shards. Directories are searched for encrypted files. With null the paths of
+// damaged files alone go to standard output, each ending in a NUL byte, and
+// status lines move to standard error
+func (p *CLIProcessor) Scan(paths []string, opts operations.ScanOptions, null bool) error {
+	if null {
+		p.status = os.Stderr
+	}
+
+	inputs, err := p.collectEncrypted(paths)
+	if err != nil {
+		return err
+	}
+	if len(inputs) == 1 && !null {
+		fmt.Fprintf(p.status, "Scanning: %s\n", inputs[0])
+		report, err := p.decryptor.ScanWithOptions(inputs[0], opts)
+		return p.printScanReport(report, err)
+	}
+
+	// A file that fails does not stop the others. With null the paths of the
+	// damaged and failed files alone go to standard output
+	failed := 0
+	for _, input := range inputs {
+		fmt.Fprintf(p.status, "Scanning: %s\n", input)
+		report, err := p.decryptor.ScanWithOptions(input, opts)
+		if err = p.printScanReport(report, err); err != nil {
+			p.warn("%s: %v", input, err)
+			failed++
+		}
+		if null && (err != nil || len(report.Problems) > 0) {
+			if err := printNullPath(input); err != nil {
+				return err
+			}
+		}
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d of %d file(s) failed the scan", failed, len(inputs))
+	}
+	return nil
+}
+
+// Verify decrypts an encrypted file in memory and reports any corrupt shards it finds
+func (p *CLIProcessor) Verify(inputFile, password string, opts operations.ScanOptions) error {
+	password, err := p.decryptionPassword(password)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(p.status, "Verifying: %s\n", inputFile)
+
+	report, err := p.decryptor.VerifyWithOptions(inputFile, password, opts)
+	return p.printScanReport(report, err)
+}
+
+// VerifyChunk checks a single chunk of inputFile against the Merkle root in its
+// header, using the tree sidecar at treeFile when one is given
+func (p *CLIProcessor) VerifyChunk(inputFile, password string, index int, treeFile string) error {
+	var tree *crypto.MerkleTree
+	if treeFile != "" {
+		var err error
+		if tree, err = operations.ReadMerkleTree(treeFile); err != nil {
+			return err
+		}
+	}
+
+	password, err := p.decryptionPassword(password)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(p.status, "Verifying chunk %d: %s\n", index, inputFile)
+	if err := p.decryptor.VerifyChunk(inputFile, password, index, tree); err != nil {
+		return err
+	}
+	fmt.Fprintf(p.status, "✓ chunk %d matches the Merkle root and decrypts\n", index)
+	return nil
+}
+
+// VerifyManifest checks every chunk of inputFile against the manifest sidecar
+// at manifestFile written by encrypt --manifest. No password is needed
+func (p *CLIProcessor) VerifyManifest(inputFile, manifestFile string) error {
+	manifest, err := operations.ReadManifest(manifestFile)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(p.status, "Checking against manifest: %s\n", inputFile)
+	report, err := p.decryptor.VerifyManifest(inputFile, manifest)
+	if err != nil {
+		return p.printScanReport(report, err)
+	}
+	fmt.Fprintf(p.status, "✓ %d chunk(s) match the manifest\n", report.Chunks)
+	return nil
+}
+
+// printScanReport prints the per-chunk findings and a summary line
+func (p *CLIProcessor) printScanReport(report *operations.ScanReport, err error) error {
+	if report == nil {
+		return err
+	}
+
+	for _, chunk := range report.Problems {
+		if report.Log {
+			fmt.Fprintf(p.status, "✗ %v\n", chunk.Err)
+		} else if chunk.Err != nil {
+			fmt.Fprintf(p.status, "✗ chunk %d (offset %d): %v\n", chunk.Index, chunk.Offset, chunk.Err)
+		} else {
+			fmt.Fprintf(p.status, "! chunk %d (offset %d): corrupt shard(s) %v\n", chunk.Index, chunk.Offset, chunk.Corrupted)
+		}
+	}
+
+	if err != nil {
+		return err
+	}
+
+	if report.Log {
+		if report.Decrypted {
+			fmt.Fprintf(p.status, "✓ append-only log of %d record(s) verified\n", report.Records)
+		} else {
+			fmt.Fprintf(p.status, "✓ append-only log of %d record(s) has no Reed-Solomon parity to check; use verify to authenticate it\n", report.Records)
+		}
+		return nil
+	}
+	if report.RawBody {
+		if report.Decrypted {
+			fmt.Fprintln(p.status, "✓ raw body verified (no Reed-Solomon parity)")
+		} else {
+			fmt.Fprintln(p.status, "✓ raw body has no Reed-Solomon parity to check; use verify to authenticate it")
+		}
+		return nil
+	}
+
+	check := "checked"
+	if report.Decrypted {
+		check = "verified"
+	}
+	fmt.Fprintf(p.status, "✓ %d chunk(s) %s: %d clean, %d damaged\n", report.Chunks, check, report.Clean(), report.Damaged())
+	return nil
+}
+
+// confidential returns an encrypted field's value, or a placeholder while it is locked
+func confidential(value string, unlocked bool) string {
+	if !unlocked {
+		return "(encrypted, pass -p to show)"
+	}
+	return value
+}
+
+// checkDigest rejects an input file whose SHA-256 differs from --expect-sha256,
+// before the password is asked for
+func (p *CLIProcessor) checkDigest(opts Options) error {
+	if opts.ExpectSHA256 == "" {
+		return nil
+	}
+
+	expected, err := operations.ParseDigest(opts.ExpectSHA256)
+	if err != nil {
+		return err
+	}
+	return p.decryptor.CheckDigest(opts.InputFile, expected)
+}
+
+// checkSpace fails early when the output filesystem cannot hold the encrypted file
+func (p *CLIProcessor) checkSpace(opts Options) error {
+	if opts.NoSpaceCheck {
+		return nil
+	}
+
+	info, err := p.fileManager.GetFileInfo(opts.InputFile)
+	if err != nil {
+		return err
+	}
+	return p.fileManager.CheckSpace(opts.OutputFile, operations.EstimateEncryptedSize(info.Size()))
+}
+
+// deleteSource removes the input file when requested, reporting failures as warnings
+func (p *CLIProcessor) deleteSource(opts Options) {
+	if !opts.DeleteSource {
+		return
+	}
+
+	fmt.Fprintf(p.status, "Deleting source file: %s\n", opts.InputFile)
+	if err := files.NewManagerWithPattern(opts.DeletePattern).Remove(opts.InputFile, opts.deleteOption()); err != nil {
+		p.warn("Failed to delete source file: %v", err)
+	} else {
+		fmt.Fprintf(p.status, "Source file deleted successfully\n")
+	}
+}
+
+// resolvePassword returns the password for the operation, taking it from the keychain
+// when --use-keychain is set. The returned function saves a newly entered password
+// to the keychain and should only be called once the password has worked
+func (p *CLIProcessor) resolvePassword(opts Options, mode constants.ProcessorMode) (string, func() error, error) {
+	// Invalid options are reported before the password is asked for
+	if mode == constants.ModeEncrypt {
+		if err := opts.checkKDFFlags(); err != nil {
+			return "", nil, err
+		}
+		if err := opts.encryptOptions().Validate(); err != nil {
+			return "", nil, err
+		}
+	}
+
+	// An escrowed key or a recovery code stands in for the password, so none is asked for
+	if mode == constants.ModeDecrypt && opts.EscrowPrivateKey != nil {
+		p.decryptor.SetEscrowKey(opts.EscrowPrivateKey, opts.EscrowedKey)
+		return "", func() error { return nil }, nil
+	}
+	if mode == constants.ModeDecrypt && opts.RecoveryCode != "" {
+		if _, err := crypto.ParseRecoveryCode(opts.RecoveryCode); err != nil {
+			return "", nil, err
+		}
+		p.decryptor.SetRecoveryCode(opts.RecoveryCode)
+		return "", func() error { return nil }, nil
+	}
+
+	password, save, err := p.fetchPassword(opts, mode)
+	if err != nil {
+		return "", nil, err
+	}
+
+	// Refuse a short password once, before any file is touched
+	if mode == constants.ModeEncrypt {
+		if err := opts.encryptOptions().CheckPassword(password); err != nil {
+			return "", nil, err
+		}
+		if length := utf8.RuneCountInString(password); length < constants.WeakPasswordLength {
+			p.warn("Weak password: %d characters, at least %d recommended", length, constants.WeakPasswordLength)
+		}
+	}
+	return password, save, nil
+}
+
+// fetchPassword reads the password from the flags, the keychain or standard input
+func (p *CLIProcessor) fetchPassword(opts Options, mode constants.ProcessorMode) (string, func() error, error) {
+	prompt := func() (string, error) {
+		if mode == constants.ModeEncrypt {
+			return p.encryptionPassword(opts)
+		}
+		return p.decryptionPassword(opts.Password)
+	}
+
+	if opts.Keychain == "" {
+		password, err := prompt()
+		return password, func() error { return nil }, err
+	}
+
+	kc, err := keychain.NewDefault()
+	if err != nil {
+		return "", nil, err
+	}
+	kc.OnPlaintext(func(path string) {
+		p.warn("No OS secret store is available; saving the password unencrypted to %s", path)
+	})
+	return kc.Lookup(opts.Keychain, prompt)
+}
+
+// savePassword stores a newly entered password in the keychain, reporting failures as warnings
+func (p *CLIProcessor) savePassword(opts Options, save func() error) {
+	if err := save(); err != nil {
+		p.warn("Failed to save password to keychain: %v", err)
+	}
+}
+
+// encryptionPassword returns the password flag, or reads one from standard input.
+// Typed passwords are confirmed unless --no-confirm is set
+func (p *CLIProcessor) encryptionPassword(opts Options) (string, error) {
+	if opts.Password != "" {
+		return opts.Password, nil
+	}
+	return p.passwords.EncryptionPassword(!opts.NoConfirm)
+}
+
+// decryptionPassword returns the given password, or reads one from standard input
+func (p *CLIProcessor) decryptionPassword(password string) (string, error) {
+	if password != "" {
+		return password, nil
 	}
-	fmt.Println() // Add newline after password input
-	return string(bytePassword), nil
+	return p.passwords.DecryptionPassword()
 }
@@ -0,0 +1,48 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/hambosto/hexwarden/internal/data/streaming"
+)
+
+// poolStatsJSON is the --stats --json form of the worker pool statistics
+type poolStatsJSON struct {
+	Workers        int     `json:"workers"`
+	PeakWorkers    int     `json:"peak_workers"`
+	Tasks          int64   `json:"tasks"`
+	MaxQueueDepth  int     `json:"max_queue_depth"`
+	BusySeconds    float64 `json:"busy_seconds"`
+	ElapsedSeconds float64 `json:"elapsed_seconds"`
+	BusyRatio      float64 `json:"busy_ratio"`
+}
+
+// Stats returns the worker pool utilization of every encryption and decryption run so far
+func (p *CLIProcessor) Stats() streaming.PoolStats {
+	stats := p.encryptor.Stats()
+	stats.Add(p.decryptor.Stats())
+	return stats
+}
+
+// PrintStats writes the worker pool statistics to w, as one JSON object when asJSON is set
+func (p *CLIProcessor) PrintStats(w io.Writer, asJSON bool) error {
+	stats := p.Stats()
+
+	if asJSON {
+		return json.NewEncoder(w).Encode(poolStatsJSON{
+			Workers:        stats.Workers,
+			PeakWorkers:    stats.PeakWorkers,
+			Tasks:          stats.Tasks,
+			MaxQueueDepth:  stats.MaxQueueDepth,
+			BusySeconds:    stats.Busy.Seconds(),
+			ElapsedSeconds: stats.Elapsed.Seconds(),
+			BusyRatio:      stats.BusyRatio(),
+		})
+	}
+
+	_, err := fmt.Fprintf(w, "Worker pool: %d workers (peak %d busy), %d tasks, max queue depth %d, %.1f%% busy\n",
+		stats.Workers, stats.PeakWorkers, stats.Tasks, stats.MaxQueueDepth, stats.BusyRatio()*100)
+	return err
+}
@@ -1,6 +1,7 @@
 package interactive
 
 import (
+	"errors"
 	"fmt"
 	"os"
 
@@ -18,20 +19,64 @@ type InteractiveApp struct {
 	fileFinder  *files.Finder
 	encryptor   *operations.Encryptor
 	decryptor   *operations.Decryptor
+	retry       operations.RetryPolicy
+	dryRun      bool
 }
 
+// resumeStep is the step of the session a failed operation returns to
+type resumeStep int
+
+const (
+	resumeNone     resumeStep = iota // The error ends the session
+	resumePassword                   // Ask for the password of the same file again
+	resumeFile                       // Choose the file to process again
+)
+
 // NewInteractiveApp creates a new interactive application instance
 func NewInteractiveApp() *InteractiveApp {
+	app := NewInteractiveAppWithPrompt(ui.NewPrompt())
+	app.SetPepper(os.Getenv(constants.PepperEnvVar))
+	return app
+}
+
+// NewInteractiveAppWithPrompt creates an interactive application that asks its
+// questions through prompt
+func NewInteractiveAppWithPrompt(prompt *ui.Prompt) *InteractiveApp {
 	return &InteractiveApp{
 		terminal:    ui.NewTerminal(),
-		prompt:      ui.NewPrompt(),
+		prompt:      prompt,
 		fileManager: files.NewManager(),
 		fileFinder:  files.NewFinder(),
 		encryptor:   operations.NewEncryptor(),
 		decryptor:   operations.NewDecryptor(),
+		retry:       operations.DefaultRetryPolicy(),
 	}
 }
 
+// SetPepper sets the secret mixed into passwords when encrypting and when
+// decrypting files that were encrypted with one
+func (a *InteractiveApp) SetPepper(pepper string) {
+	a.encryptor.SetPepper([]byte(pepper))
+	a.decryptor.SetPepper([]byte(pepper))
+}
+
+// SetShowPassword makes the password prompts show the password as it is typed
+func (a *InteractiveApp) SetShowPassword(enabled bool) {
+	a.prompt.SetShowPassword(enabled)
+}
+
+// SetRetryPolicy sets how often a wrong decryption password may be typed again
+// before the session goes back to asking for it afresh
+func (a *InteractiveApp) SetRetryPolicy(policy operations.RetryPolicy) {
+	a.retry = policy
+}
+
+// SetDryRun makes ProcessFile show the plan of each file and stop there, without
+// asking for a password or writing anything
+func (a *InteractiveApp) SetDryRun(enabled bool) {
+	a.dryRun = enabled
+}
+
 // Run executes the main interactive application workflow
 func (a *InteractiveApp) Run() {
 	// Set up terminal
@@ -43,9 +88,12 @@ func (a *InteractiveApp) Run() {
 	a.terminal.PrintSeparator()
 
 	// Main application loop
-	if err := a.runInteractiveLoop(); err != nil {
+	if err := a.RunSession(); err != nil {
 		a.handleError(err)
-		os.Exit(1)
+		if errors.Is(err, constants.ErrUserCanceled) {
+			os.Exit(constants.ExitCanceled)
+		}
+		os.Exit(constants.ExitFailure)
 	}
 
 	// Cleanup
@@ -58,50 +106,163 @@ func (a *InteractiveApp) initializeTerminal() {
 	a.terminal.MoveTopLeft()
 }
 
-// runInteractiveLoop executes the main interactive workflow
-func (a *InteractiveApp) runInteractiveLoop() error {
+// RunSession asks for the operation and the files, then processes them. A
+// recoverable failure returns to the step that can put it right instead of
+// ending the session: a wrong or mismatched password is asked for again, and
+// a declined overwrite or plan goes back to choosing the file. Any other
+// error, including an interrupted prompt, is returned
+func (a *InteractiveApp) RunSession() error {
 	// Get processing mode from user
 	operation, err := a.prompt.GetProcessingMode()
 	if err != nil {
 		return fmt.Errorf("failed to get processing mode: %w", err)
 	}
 
-	// Find eligible files
+	for {
+		selectedFiles, multiple, err := a.SelectFiles(operation)
+		if resumeAt(err) == resumeFile {
+			a.prompt.ShowWarning(err.Error())
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if multiple {
+			return a.processFiles(selectedFiles, operation)
+		}
+
+		err = a.processFile(selectedFiles[0], operation)
+		if resumeAt(err) != resumeFile {
+			return err
+		}
+		a.prompt.ShowInfo("Choose a file again, or press Ctrl+C to quit")
+	}
+}
+
+// processFile processes the single selected file, asking for the password again
+// for as long as it is the password that fails
+func (a *InteractiveApp) processFile(selectedFile string, operation constants.ProcessorMode) error {
+	for {
+		// Show processing info
+		a.prompt.ShowProcessingInfo(operation, selectedFile)
+
+		err := a.ProcessFile(selectedFile, operation, a.passwordPrompt(operation))
+		switch {
+		case err == nil:
+			return nil
+		case errors.Is(err, constants.ErrFileSkipped):
+			a.prompt.ShowInfo(fmt.Sprintf("Skipped file '%s'", selectedFile))
+			return err
+		case resumeAt(err) == resumePassword:
+			a.prompt.ShowWarning(fmt.Sprintf("%v; enter the password again", err))
+			continue
+		case resumeAt(err) == resumeFile:
+			a.prompt.ShowInfo(fmt.Sprintf("Not processed: %s", selectedFile))
+			return err
+		}
+		return fmt.Errorf("failed to process file '%s': %w", selectedFile, err)
+	}
+}
+
+// resumeAt returns the step the session returns to after err. An interrupted
+// or broken prompt always ends the session, even when it canceled a step
+func resumeAt(err error) resumeStep {
+	switch {
+	case err == nil, errors.Is(err, constants.ErrPromptFailed):
+		return resumeNone
+	case errors.Is(err, constants.ErrAuthFailure),
+		errors.Is(err, constants.ErrTooManyAttempts),
+		errors.Is(err, constants.ErrPasswordMismatch),
+		errors.Is(err, constants.ErrPasswordTooShort),
+		errors.Is(err, constants.ErrEmptyPassword):
+		return resumePassword
+	case errors.Is(err, constants.ErrFileSkipped),
+		errors.Is(err, constants.ErrUserCanceled),
+		errors.Is(err, constants.ErrNoFilesSelected):
+		return resumeFile
+	}
+	return resumeNone
+}
+
+// SelectFiles lists the files eligible for operation and lets the user choose one,
+// or several when multiple is true. Another process may remove a file while the
+// user is choosing, so when a chosen file is gone the listing is shown again
+func (a *InteractiveApp) SelectFiles(operation constants.ProcessorMode) (selected []string, multiple bool, err error) {
+	for {
+		selected, multiple, err = a.chooseFiles(operation)
+		if err != nil {
+			return nil, false, err
+		}
+
+		vanished := a.vanishedFiles(selected)
+		if len(vanished) == 0 {
+			return selected, multiple, nil
+		}
+		for _, file := range vanished {
+			a.prompt.ShowWarning(fmt.Sprintf("File no longer exists: %s", file))
+		}
+		a.prompt.ShowInfo("Refreshing the file list")
+	}
+}
+
+// chooseFiles shows the eligible files once and returns the user's choice
+func (a *InteractiveApp) chooseFiles(operation constants.ProcessorMode) ([]string, bool, error) {
 	eligibleFiles, err := a.getEligibleFiles(operation)
 	if err != nil {
-		return err
+		return nil, false, err
 	}
 
 	// Show file information
 	fileInfos, err := a.fileFinder.GetFileInfo(eligibleFiles)
 	if err != nil {
-		return fmt.Errorf("failed to get file information: %w", err)
+		return nil, false, fmt.Errorf("failed to get file information: %w", err)
 	}
 	a.prompt.ShowFileInfo(fileInfos)
 
+	// Let user choose several files when there is more than one
+	if len(eligibleFiles) > 1 {
+		multiple, err := a.prompt.ChooseProcessMultiple()
+		if err != nil {
+			return nil, false, fmt.Errorf("failed to choose selection mode: %w", err)
+		}
+		if multiple {
+			selectedFiles, err := a.prompt.ChooseFiles(eligibleFiles)
+			if err != nil {
+				return nil, false, fmt.Errorf("failed to select files: %w", err)
+			}
+			return selectedFiles, true, nil
+		}
+	}
+
 	// Let user choose a file
 	selectedFile, err := a.prompt.ChooseFile(eligibleFiles)
 	if err != nil {
-		return fmt.Errorf("failed to select file: %w", err)
+		return nil, false, fmt.Errorf("failed to select file: %w", err)
 	}
+	return []string{selectedFile}, false, nil
+}
 
-	// Show processing info
-	a.prompt.ShowProcessingInfo(operation, selectedFile)
-
-	// Process the selected file
-	if err := a.processFile(selectedFile, operation); err != nil {
-		return fmt.Errorf("failed to process file '%s': %w", selectedFile, err)
+// vanishedFiles returns the files that no longer exist. Other stat errors are
+// left for processing to report
+func (a *InteractiveApp) vanishedFiles(files []string) []string {
+	var vanished []string
+	for _, file := range files {
+		if _, err := os.Lstat(file); errors.Is(err, os.ErrNotExist) {
+			vanished = append(vanished, file)
+		}
 	}
-
-	return nil
+	return vanished
 }
 
 // getEligibleFiles retrieves files that can be processed based on the operation mode
 func (a *InteractiveApp) getEligibleFiles(operation constants.ProcessorMode) ([]string, error) {
-	eligibleFiles, err := a.fileFinder.FindEligibleFiles(operation)
+	eligibleFiles, skipped, err := a.fileFinder.FindEligibleFiles(operation)
 	if err != nil {
 		return nil, fmt.Errorf("failed to find eligible files: %w", err)
 	}
+	for _, s := range skipped {
+		a.prompt.ShowWarning(fmt.Sprintf("Skipped unreadable path %s: %v", s.Path, s.Err))
+	}
 
 	if len(eligibleFiles) == 0 {
 		return nil, fmt.Errorf("no eligible files found for %s operation", operation)
@@ -110,8 +271,72 @@ func (a *InteractiveApp) getEligibleFiles(operation constants.ProcessorMode) ([]
 	return eligibleFiles, nil
 }
 
-// processFile handles the file processing workflow
-func (a *InteractiveApp) processFile(inputPath string, mode constants.ProcessorMode) error {
+// processFiles processes the selected files one after another with a single
+// password, then summarizes how many succeeded. A failure does not stop the rest
+func (a *InteractiveApp) processFiles(selectedFiles []string, operation constants.ProcessorMode) error {
+	var password string
+	for !a.dryRun {
+		var err error
+		if password, err = a.passwordPrompt(operation)(); err == nil {
+			break
+		}
+		if resumeAt(err) != resumePassword {
+			return fmt.Errorf("password prompt failed: %w", err)
+		}
+		a.prompt.ShowWarning(fmt.Sprintf("%v; enter the password again", err))
+	}
+	samePassword := func() (string, error) { return password, nil }
+
+	var failed []string
+	skipped := 0
+	for _, file := range selectedFiles {
+		a.prompt.ShowProcessingInfo(operation, file)
+		err := a.ProcessFile(file, operation, samePassword)
+		switch {
+		case errors.Is(err, constants.ErrFileSkipped):
+			a.prompt.ShowInfo(fmt.Sprintf("Skipped file '%s'", file))
+			skipped++
+		case err != nil:
+			a.prompt.ShowWarning(fmt.Sprintf("Failed to process file '%s': %v", file, err))
+			failed = append(failed, file)
+		}
+	}
+
+	verb := "Processed"
+	if a.dryRun {
+		verb = "Planned"
+	}
+	fmt.Println()
+	a.prompt.ShowInfo(fmt.Sprintf("%s %d of %d file(s)", verb, len(selectedFiles)-len(failed)-skipped, len(selectedFiles)))
+	if skipped > 0 {
+		a.prompt.ShowInfo(fmt.Sprintf("Skipped %d file(s) whose output already exists", skipped))
+	}
+	for _, file := range failed {
+		a.prompt.ShowWarning(fmt.Sprintf("Failed: %s", file))
+	}
+
+	if len(failed) > 0 {
+		return fmt.Errorf("%d of %d file(s) failed", len(failed), len(selectedFiles))
+	}
+	return nil
+}
+
+// passwordPrompt returns the prompt that asks for the password of operation
+func (a *InteractiveApp) passwordPrompt(operation constants.ProcessorMode) func() (string, error) {
+	if operation == constants.ModeEncrypt {
+		return a.prompt.GetEncryptionPassword
+	}
+	return a.prompt.GetDecryptionPassword
+}
+
+// ProcessFile encrypts or decrypts inputPath. The password from getPassword and
+// whether to delete the source are asked first, then the plan is shown and
+// nothing is written until the user confirms it; declining fails with
+// ErrUserCanceled. In a dry run the plan is shown without asking for a password
+func (a *InteractiveApp) ProcessFile(inputPath string, mode constants.ProcessorMode, getPassword func() (string, error)) error {
+	if mode != constants.ModeEncrypt && mode != constants.ModeDecrypt {
+		return fmt.Errorf("unknown processing mode: %v", mode)
+	}
 	outputPath := a.fileFinder.GetOutputPath(inputPath, mode)
 
 	// Validate paths
@@ -119,34 +344,57 @@ func (a *InteractiveApp) processFile(inputPath string, mode constants.ProcessorM
 		return fmt.Errorf("source validation failed: %w", err)
 	}
 
-	if err := a.fileManager.ValidatePath(outputPath, false); err != nil {
-		if confirm, confirmErr := a.prompt.ConfirmFileOverwrite(outputPath); confirmErr != nil || !confirm {
-			return fmt.Errorf("operation cancelled")
+	outputPath, err := a.ResolveOutputPath(outputPath)
+	if err != nil {
+		return err
+	}
+
+	var password string
+	if !a.dryRun {
+		if password, err = getPassword(); err != nil {
+			return fmt.Errorf("password prompt failed: %w", err)
 		}
 	}
 
-	// Process based on mode
-	var err error
-	switch mode {
-	case constants.ModeEncrypt:
-		err = a.encryptFile(inputPath, outputPath)
-	case constants.ModeDecrypt:
-		err = a.decryptFile(inputPath, outputPath)
-	default:
-		return fmt.Errorf("unknown processing mode: %v", mode)
+	// The source is only deleted once processing succeeds, but the choice is part of the plan
+	fileType := "original"
+	if mode == constants.ModeDecrypt {
+		fileType = "encrypted"
+	}
+	shouldDelete, deleteType, err := a.prompt.ConfirmFileRemoval(inputPath, fmt.Sprintf("Delete %s file", fileType))
+	if err != nil {
+		return fmt.Errorf("%w: %w", constants.ErrUserCanceled, err)
 	}
 
+	plan, err := a.plan(inputPath, outputPath, mode)
 	if err != nil {
 		return err
 	}
+	plan.Delete, plan.DeleteType = shouldDelete, deleteType
+	a.prompt.ShowPlan(plan)
 
-	// Ask to delete source file
-	fileType := "original"
-	if mode == constants.ModeDecrypt {
-		fileType = "encrypted"
+	if a.dryRun {
+		a.prompt.ShowInfo("Dry run: nothing was changed")
+		return nil
+	}
+	proceed, err := a.prompt.ConfirmPlan()
+	if err != nil {
+		return fmt.Errorf("%w: %w", constants.ErrUserCanceled, err)
+	}
+	if !proceed {
+		return constants.ErrUserCanceled
 	}
 
-	if shouldDelete, deleteType, err := a.prompt.ConfirmFileRemoval(inputPath, fmt.Sprintf("Delete %s file", fileType)); err == nil && shouldDelete {
+	if mode == constants.ModeEncrypt {
+		err = a.encryptFile(inputPath, outputPath, password)
+	} else {
+		err = a.decryptFile(inputPath, outputPath, password)
+	}
+	if err != nil {
+		return err
+	}
+
+	if shouldDelete {
 		if err := a.fileManager.Remove(inputPath, deleteType); err != nil {
 			a.prompt.ShowWarning(fmt.Sprintf("Failed to delete source file: %v", err))
 		} else {
@@ -158,33 +406,87 @@ func (a *InteractiveApp) processFile(inputPath string, mode constants.ProcessorM
 	return nil
 }
 
-// encryptFile handles file encryption
-func (a *InteractiveApp) encryptFile(srcPath, destPath string) error {
-	// Get password
-	password, err := a.prompt.GetEncryptionPassword()
+// plan describes processing inputPath into outputPath. The output size is an
+// upper bound when encrypting, and the size recorded in the public header when decrypting
+func (a *InteractiveApp) plan(inputPath, outputPath string, mode constants.ProcessorMode) (ui.Plan, error) {
+	info, err := a.fileManager.GetFileInfo(inputPath)
+	if err != nil {
+		return ui.Plan{}, err
+	}
+
+	plan := ui.Plan{Operation: mode, Input: inputPath, InputSize: info.Size(), Output: outputPath}
+	if mode == constants.ModeEncrypt {
+		plan.OutputSize = operations.EstimateEncryptedSize(info.Size())
+		return plan, nil
+	}
+
+	header, err := a.decryptor.Inspect(inputPath, "")
+	if err != nil {
+		return ui.Plan{}, err
+	}
+	plan.OutputSize = int64(header.OriginalSize)
+	return plan, nil
+}
+
+// ResolveOutputPath returns where to write outputPath. When a file already exists
+// there the user chooses to overwrite it, write to a free numbered name instead,
+// skip the file with ErrFileSkipped, or cancel with ErrUserCanceled
+func (a *InteractiveApp) ResolveOutputPath(outputPath string) (string, error) {
+	if err := a.fileManager.ValidatePath(outputPath, false); err == nil {
+		return outputPath, nil
+	}
+
+	action, err := a.prompt.ChooseExistsAction(outputPath)
 	if err != nil {
-		return fmt.Errorf("password prompt failed: %w", err)
+		return "", fmt.Errorf("%w: %w", constants.ErrUserCanceled, err)
+	}
+
+	switch action {
+	case constants.ExistsOverwrite:
+		return outputPath, nil
+	case constants.ExistsRename:
+		renamed, err := a.fileManager.FreePath(outputPath)
+		if err != nil {
+			return "", err
+		}
+		a.prompt.ShowInfo(fmt.Sprintf("Writing to %s instead", renamed))
+		return renamed, nil
+	case constants.ExistsSkip:
+		return "", fmt.Errorf("%w: %s", constants.ErrFileSkipped, outputPath)
+	default:
+		return "", constants.ErrUserCanceled
 	}
+}
 
-	// Perform encryption
-	if err := a.encryptor.EncryptFile(srcPath, destPath, password); err != nil {
+// encryptFile handles file encryption
+func (a *InteractiveApp) encryptFile(srcPath, destPath, password string) error {
+	result, err := a.encryptor.EncryptFile(srcPath, destPath, password, operations.EncryptOptions{})
+	if err != nil {
 		return fmt.Errorf("encryption failed: %w", err)
 	}
 
+	a.prompt.ShowInfo(fmt.Sprintf("SHA-256: %x", result.SHA256))
 	return nil
 }
 
 // decryptFile handles file decryption
-func (a *InteractiveApp) decryptFile(srcPath, destPath string) error {
-	// Get password
-	password, err := a.prompt.GetDecryptionPassword()
+func (a *InteractiveApp) decryptFile(srcPath, destPath, password string) error {
+	// Perform decryption, asking again if the password is wrong
+	var info *operations.HeaderInfo
+	policy := a.retry
+	policy.OnRetry = func(remaining int) {
+		a.prompt.ShowWarning(fmt.Sprintf("Wrong password, %d attempt(s) left", remaining))
+	}
+	err := policy.Run(password, a.prompt.GetDecryptionPassword, func(password string) (err error) {
+		info, err = a.decryptor.DecryptFile(srcPath, destPath, password)
+		return err
+	})
 	if err != nil {
-		return fmt.Errorf("password prompt failed: %w", err)
+		return fmt.Errorf("decryption failed: %w", err)
 	}
 
-	// Perform decryption
-	if err := a.decryptor.DecryptFile(srcPath, destPath, password); err != nil {
-		return fmt.Errorf("decryption failed: %w", err)
+	if info.Comment != "" {
+		a.prompt.ShowInfo(fmt.Sprintf("Comment: %s", info.Comment))
 	}
 
 	return nil
@@ -200,6 +502,8 @@ func (a *InteractiveApp) handleError(err error) {
 		a.prompt.ShowInfo("Passwords must match exactly. Please try again.")
 	case constants.ErrNoFilesAvailable:
 		a.prompt.ShowInfo("No files found for the selected operation. Make sure you're in the right directory.")
+	case constants.ErrNoFilesSelected:
+		a.prompt.ShowInfo("Select files with the space bar before pressing enter.")
 	case constants.ErrUserCanceled:
 		a.prompt.ShowInfo("Operation cancelled by user.")
 	}
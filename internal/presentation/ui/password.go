@@ -0,0 +1,105 @@
+package ui
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"golang.org/x/term"
+
+	"github.com/hambosto/hexwarden/internal/constants"
+)
+
+// PasswordReader reads passwords from a terminal without echo, or once from a pipe
+type PasswordReader struct {
+	in          io.Reader
+	out         io.Writer
+	interactive bool
+	lines       *bufio.Reader
+}
+
+// NewPasswordReader creates a password reader for standard input. Prompts go to
+// standard error so they never mix with output streamed to standard output
+func NewPasswordReader() *PasswordReader {
+	return NewPasswordReaderFrom(os.Stdin, os.Stderr)
+}
+
+// NewPasswordReaderFrom creates a password reader for in, writing prompts to out.
+// Input that is not a terminal is treated as a non-interactive password source
+func NewPasswordReaderFrom(in io.Reader, out io.Writer) *PasswordReader {
+	return &PasswordReader{
+		in:          in,
+		out:         out,
+		interactive: IsTerminal(in),
+		lines:       bufio.NewReader(in),
+	}
+}
+
+// Interactive reports whether passwords are typed by a user at a terminal
+func (r *PasswordReader) Interactive() bool {
+	return r.interactive
+}
+
+// EncryptionPassword reads a password for encryption. Confirmation is only
+// requested when confirm is set and the password is typed interactively
+func (r *PasswordReader) EncryptionPassword(confirm bool) (string, error) {
+	password, err := r.read("Enter encryption password: ")
+	if err != nil {
+		return "", fmt.Errorf("failed to get password: %w", err)
+	}
+
+	if !confirm || !r.interactive {
+		return password, nil
+	}
+
+	confirmPassword, err := r.read("Confirm password: ")
+	if err != nil {
+		return "", fmt.Errorf("failed to confirm password: %w", err)
+	}
+
+	if password != confirmPassword {
+		return "", constants.ErrPasswordMismatch
+	}
+
+	return password, nil
+}
+
+// DecryptionPassword reads a password for decryption
+func (r *PasswordReader) DecryptionPassword() (string, error) {
+	password, err := r.read("Enter decryption password: ")
+	if err != nil {
+		return "", fmt.Errorf("failed to get password: %w", err)
+	}
+	return password, nil
+}
+
+// read prompts for a password on a terminal, or reads the next line from a pipe
+func (r *PasswordReader) read(prompt string) (string, error) {
+	if !r.interactive {
+		return r.readLine()
+	}
+
+	fmt.Fprint(r.out, prompt)
+	bytePassword, err := term.ReadPassword(int(r.in.(*os.File).Fd()))
+	if err != nil {
+		return "", err
+	}
+	fmt.Fprintln(r.out) // Add newline after password input
+	return string(bytePassword), nil
+}
+
+// readLine reads a single line from a non-interactive source
+func (r *PasswordReader) readLine() (string, error) {
+	line, err := r.lines.ReadString('\n')
+	if err != nil && err != io.EOF {
+		return "", err
+	}
+
+	password := strings.TrimRight(line, "\r\n")
+	if password == "" {
+		return "", constants.ErrEmptyPassword
+	}
+	return password, nil
+}
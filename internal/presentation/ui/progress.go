@@ -1,9 +1,27 @@
 package ui
 
 import (
+	"fmt"
+	"os"
+	"sync/atomic"
+
 	"github.com/schollz/progressbar/v3"
 )
 
+// progressDisabled is set by --no-progress and while plaintext is streamed to standard output
+var progressDisabled atomic.Bool
+
+// SetProgressEnabled shows or hides progress bars and spinners. They are drawn on
+// standard error, so standard output only ever carries results, and only when it is a terminal
+func SetProgressEnabled(enabled bool) {
+	progressDisabled.Store(!enabled)
+}
+
+// progressVisible reports whether progress may be drawn
+func progressVisible() bool {
+	return !progressDisabled.Load() && IsTerminal(os.Stderr)
+}
+
 // ProgressBar provides progress tracking functionality
 type ProgressBar struct {
 	bar         *progressbar.ProgressBar
@@ -14,11 +32,16 @@ type ProgressBar struct {
 func NewProgressBar(totalSize int64, description string) *ProgressBar {
 	bar := progressbar.NewOptions64(
 		totalSize,
+		progressbar.OptionSetWriter(os.Stderr),
+		progressbar.OptionSetVisibility(progressVisible()),
 		progressbar.OptionSetDescription(description),
-		progressbar.OptionEnableColorCodes(true),
+		progressbar.OptionEnableColorCodes(ColorSupported(IsTerminal(os.Stderr))),
 		progressbar.OptionShowCount(),
 		progressbar.OptionFullWidth(),
 		progressbar.OptionShowBytes(true),
+		progressbar.OptionOnCompletion(func() {
+			fmt.Fprintln(os.Stderr) // End the bar's line before the next status line
+		}),
 	)
 
 	return &ProgressBar{
@@ -27,7 +50,12 @@ func NewProgressBar(totalSize int64, description string) *ProgressBar {
 	}
 }
 
-// Add increments the progress bar by the given amount
+// Add increments the progress bar by the given amount, stopping at the total
+// so output within the decompression tolerance does not fail the operation
 func (p *ProgressBar) Add(size int64) error {
+	state := p.bar.State()
+	if remaining := state.Max - state.CurrentNum; state.Max > 0 && size > remaining {
+		size = remaining
+	}
 	return p.bar.Add64(size)
 }
@@ -9,12 +9,23 @@ import (
 	"github.com/hambosto/hexwarden/internal/infrastructure/utils"
 )
 
+// AskFunc asks a survey prompt and stores the answer in response, like survey.AskOne
+type AskFunc func(prompt survey.Prompt, response interface{}, opts ...survey.AskOpt) error
+
 // Prompt provides methods for interactive command-line prompts
-type Prompt struct{}
+type Prompt struct {
+	ask          AskFunc
+	showPassword bool
+}
 
 // NewPrompt creates a new Prompt instance
 func NewPrompt() *Prompt {
-	return &Prompt{}
+	return NewPromptWithAsker(survey.AskOne)
+}
+
+// NewPromptWithAsker creates a Prompt that asks its questions through ask
+func NewPromptWithAsker(ask AskFunc) *Prompt {
+	return &Prompt{ask: ask}
 }
 
 // ConfirmFileOverwrite prompts the user to confirm overwriting an existing file
@@ -24,13 +35,30 @@ func (p *Prompt) ConfirmFileOverwrite(path string) (bool, error) {
 		Message: fmt.Sprintf("Output file %s already exists. Overwrite?", path),
 	}
 
-	if err := survey.AskOne(prompt, &result); err != nil {
+	if err := p.ask(prompt, &result); err != nil {
 		return false, fmt.Errorf("%w: %v", constants.ErrPromptFailed, err)
 	}
 
 	return result, nil
 }
 
+// ChooseExistsAction asks what to do about the existing output file at path
+func (p *Prompt) ChooseExistsAction(path string) (constants.ExistsAction, error) {
+	options := []string{
+		string(constants.ExistsOverwrite),
+		string(constants.ExistsRename),
+		string(constants.ExistsSkip),
+		string(constants.ExistsCancel),
+	}
+
+	selected, err := p.selectFromOptions(fmt.Sprintf("Output file %s already exists:", path), options)
+	if err != nil {
+		return "", err
+	}
+
+	return constants.ExistsAction(selected), nil
+}
+
 // GetEncryptionPassword prompts for and confirms a password for encryption
 func (p *Prompt) GetEncryptionPassword() (string, error) {
 	password, err := p.getPassword("Enter password:")
@@ -55,14 +83,25 @@ func (p *Prompt) GetDecryptionPassword() (string, error) {
 	return p.getPassword("Enter password:")
 }
 
+// SetShowPassword makes password prompts echo what is typed instead of masking it
+func (p *Prompt) SetShowPassword(enabled bool) {
+	p.showPassword = enabled
+}
+
 // getPassword is a helper method to prompt for a password
 func (p *Prompt) getPassword(message string) (string, error) {
 	var password string
-	prompt := &survey.Password{
+	var prompt survey.Prompt = &survey.Password{
 		Message: message,
 	}
+	if p.showPassword {
+		prompt = &survey.Input{
+			Message: message,
+			Help:    "The password is shown as you type it",
+		}
+	}
 
-	if err := survey.AskOne(prompt, &password); err != nil {
+	if err := p.ask(prompt, &password); err != nil {
 		return "", fmt.Errorf("%w: %v", constants.ErrPromptFailed, err)
 	}
 
@@ -95,7 +134,7 @@ func (p *Prompt) confirmAction(message string) (bool, error) {
 		Message: message,
 	}
 
-	if err := survey.AskOne(prompt, &result); err != nil {
+	if err := p.ask(prompt, &result); err != nil {
 		return false, fmt.Errorf("%w: %v", constants.ErrPromptFailed, err)
 	}
 
@@ -146,6 +185,34 @@ func (p *Prompt) ChooseFile(files []string) (string, error) {
 	return selected, nil
 }
 
+// ChooseProcessMultiple asks whether to select several files instead of one
+func (p *Prompt) ChooseProcessMultiple() (bool, error) {
+	return p.confirmAction("Process multiple files?")
+}
+
+// ChooseFiles prompts the user to select one or more files from the provided list,
+// returned in list order
+func (p *Prompt) ChooseFiles(files []string) ([]string, error) {
+	if len(files) == 0 {
+		return nil, constants.ErrNoFilesAvailable
+	}
+
+	var selected []string
+	prompt := &survey.MultiSelect{
+		Message: "Select files (space to toggle, enter to confirm):",
+		Options: files,
+	}
+
+	if err := p.ask(prompt, &selected); err != nil {
+		return nil, fmt.Errorf("file selection failed: %w: %v", constants.ErrPromptFailed, err)
+	}
+	if len(selected) == 0 {
+		return nil, constants.ErrNoFilesSelected
+	}
+
+	return selected, nil
+}
+
 // selectFromOptions is a helper method for selection prompts
 func (p *Prompt) selectFromOptions(message string, options []string) (string, error) {
 	var selected string
@@ -154,7 +221,7 @@ func (p *Prompt) selectFromOptions(message string, options []string) (string, er
 		Options: options,
 	}
 
-	if err := survey.AskOne(prompt, &selected); err != nil {
+	if err := p.ask(prompt, &selected); err != nil {
 		return "", fmt.Errorf("%w: %v", constants.ErrPromptFailed, err)
 	}
 
@@ -195,6 +262,37 @@ func (p *Prompt) ShowProcessingInfo(mode constants.ProcessorMode, file string) {
 	fmt.Printf("\n%s file: %s\n", operation, file)
 }
 
+// Plan summarizes what an interactive operation is about to do
+type Plan struct {
+	Operation  constants.ProcessorMode
+	Input      string
+	InputSize  int64
+	Output     string
+	OutputSize int64 // Estimated size of the output; for encryption an upper bound
+	Delete     bool
+	DeleteType constants.DeleteOption
+}
+
+// ShowPlan displays the plan of an operation before it is confirmed
+func (p *Prompt) ShowPlan(plan Plan) {
+	deletion := "no"
+	if plan.Delete {
+		deletion = fmt.Sprintf("yes (%s)", plan.DeleteType)
+	}
+
+	fmt.Println("\nPlan:")
+	fmt.Printf("  Operation:      %s\n", plan.Operation)
+	fmt.Printf("  Input:          %s (%s)\n", plan.Input, utils.FormatBytes(plan.InputSize))
+	fmt.Printf("  Output:         %s (about %s)\n", plan.Output, utils.FormatBytes(plan.OutputSize))
+	fmt.Printf("  Delete source:  %s\n", deletion)
+	fmt.Println()
+}
+
+// ConfirmPlan asks whether to carry out the plan just shown
+func (p *Prompt) ConfirmPlan() (bool, error) {
+	return p.confirmAction("Proceed?")
+}
+
 // ShowSuccess displays a success message to the user
 func (p *Prompt) ShowSuccess(message string) {
 	fmt.Printf("✓ %s\n", message)
@@ -0,0 +1,60 @@
+package ui
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+)
+
+// spinnerFrames are the animation frames, plain ASCII so every terminal can draw them
+const spinnerFrames = `|/-\`
+
+// Spinner shows an animated message while a slow step runs
+type Spinner struct {
+	out      io.Writer
+	enabled  bool
+	interval time.Duration
+}
+
+// NewSpinner creates a spinner on standard error, shown only when it is a terminal
+// and progress output has not been disabled
+func NewSpinner() *Spinner {
+	return NewSpinnerWriter(os.Stderr, progressVisible())
+}
+
+// NewSpinnerWriter creates a spinner that draws to w when enabled is true
+func NewSpinnerWriter(w io.Writer, enabled bool) *Spinner {
+	return &Spinner{out: w, enabled: enabled, interval: 100 * time.Millisecond}
+}
+
+// Run calls fn in its own goroutine and animates message until it returns.
+// When the spinner is disabled fn simply runs in the caller's goroutine
+func (s *Spinner) Run(message string, fn func()) {
+	if !s.enabled {
+		fn()
+		return
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		fn()
+	}()
+
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for frame := 0; ; frame++ {
+		_, _ = fmt.Fprintf(s.out, "\r%s %c", message, spinnerFrames[frame%len(spinnerFrames)])
+
+		select {
+		case <-done:
+			// Erase the line so following output starts clean
+			_, _ = fmt.Fprintf(s.out, "\r%s\r", strings.Repeat(" ", len(message)+2))
+			return
+		case <-ticker.C:
+		}
+	}
+}
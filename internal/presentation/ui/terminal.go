@@ -2,41 +2,92 @@ package ui
 
 import (
 	"fmt"
+	"io"
+	"os"
 
-	"github.com/hambosto/hexwarden/internal/constants"
 	"github.com/inancgumus/screen"
+	"golang.org/x/term"
+
+	"github.com/hambosto/hexwarden/internal/constants"
 )
 
 // Terminal provides terminal control functionality
-type Terminal struct{}
+type Terminal struct {
+	out   io.Writer
+	plain bool // Disables escape sequences and decorative output
+}
 
-// NewTerminal creates a new Terminal instance
+// NewTerminal creates a new Terminal instance writing to standard output
 func NewTerminal() *Terminal {
-	return &Terminal{}
+	return NewTerminalWriter(os.Stdout)
+}
+
+// NewTerminalWriter creates a Terminal writing to w, falling back to plain
+// text when w does not support escape sequences
+func NewTerminalWriter(w io.Writer) *Terminal {
+	return &Terminal{
+		out:   w,
+		plain: !ColorSupported(IsTerminal(w)),
+	}
+}
+
+// ColorSupported reports whether escape sequences may be emitted to a stream.
+// A non-empty NO_COLOR or TERM=dumb disables them; otherwise the stream must be a terminal
+func ColorSupported(isTerminal bool) bool {
+	if os.Getenv("NO_COLOR") != "" {
+		return false
+	}
+	if os.Getenv("TERM") == "dumb" {
+		return false
+	}
+	return isTerminal
+}
+
+// IsTerminal reports whether stream is a file attached to a terminal
+func IsTerminal(stream any) bool {
+	f, ok := stream.(*os.File)
+	return ok && term.IsTerminal(int(f.Fd()))
 }
 
 // Clear clears the terminal screen
 func (t *Terminal) Clear() {
+	if t.plain {
+		return
+	}
 	screen.Clear()
 }
 
 // MoveTopLeft moves the cursor to the top-left corner
 func (t *Terminal) MoveTopLeft() {
+	if t.plain {
+		return
+	}
 	screen.MoveTopLeft()
 }
 
 // ShowCursor shows the terminal cursor
 func (t *Terminal) ShowCursor() {
-	fmt.Print("\033[?25h")
+	if t.plain {
+		return
+	}
+	fmt.Fprint(t.out, "\033[?25h")
 }
 
 // SetTitle sets the terminal window title
 func (t *Terminal) SetTitle(title string) {
-	fmt.Printf("\033]0;%s\007", title)
+	if t.plain {
+		return
+	}
+	fmt.Fprintf(t.out, "\033]0;%s\007", title)
 }
 
 // PrintBanner prints the application banner
 func (t *Terminal) PrintBanner() {
+	if t.plain {
+		fmt.Fprintf(t.out, "\nHexWarden - Secure File Encryption Tool v%s\n\n", constants.AppVersion)
+		return
+	}
+
 	banner := fmt.Sprintf(`
 ██╗  ██╗███████╗██╗  ██╗██╗    ██╗ █████╗ ██████╗ ██████╗ ███████╗███╗   ██╗
 ██║  ██║██╔════╝╚██╗██╔╝██║    ██║██╔══██╗██╔══██╗██╔══██╗██╔════╝████╗  ██║
@@ -47,36 +98,49 @@ func (t *Terminal) PrintBanner() {
                                                                               
                     Secure File Encryption Tool v%s
 `, constants.AppVersion)
-	fmt.Println(banner)
+	fmt.Fprintln(t.out, banner)
 }
 
 // PrintSeparator prints a visual separator
 func (t *Terminal) PrintSeparator() {
-	fmt.Println("═══════════════════════════════════════════════════════════════════════════════")
+	if t.plain {
+		fmt.Fprintln(t.out, "--------------------------------------------------------------------------------")
+		return
+	}
+	fmt.Fprintln(t.out, "═══════════════════════════════════════════════════════════════════════════════")
 }
 
 // PrintSuccess prints a success message with formatting
 func (t *Terminal) PrintSuccess(message string) {
-	fmt.Printf("✅ %s\n", message)
+	t.printMessage("✅ ", "[OK] ", message)
 }
 
 // PrintError prints an error message with formatting
 func (t *Terminal) PrintError(message string) {
-	fmt.Printf("❌ %s\n", message)
+	t.printMessage("❌ ", "[ERROR] ", message)
 }
 
 // PrintWarning prints a warning message with formatting
 func (t *Terminal) PrintWarning(message string) {
-	fmt.Printf("⚠️  %s\n", message)
+	t.printMessage("⚠️  ", "[WARN] ", message)
 }
 
 // PrintInfo prints an info message with formatting
 func (t *Terminal) PrintInfo(message string) {
-	fmt.Printf("ℹ️  %s\n", message)
+	t.printMessage("ℹ️  ", "[INFO] ", message)
+}
+
+// printMessage prints a message with the decorated or plain prefix
+func (t *Terminal) printMessage(decorated, plain, message string) {
+	prefix := decorated
+	if t.plain {
+		prefix = plain
+	}
+	fmt.Fprintf(t.out, "%s%s\n", prefix, message)
 }
 
 // Cleanup performs terminal cleanup operations
 func (t *Terminal) Cleanup() {
 	t.ShowCursor()
-	fmt.Println() // Add a newline for clean exit
+	fmt.Fprintln(t.out) // Add a newline for clean exit
 }
@@ -0,0 +1,34 @@
+package operations
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+
+	"github.com/hambosto/hexwarden/internal/constants"
+)
+
+// SetWriteBuffer sets how many bytes of encrypted output are collected before
+// they are written, so the size prefix and data of each chunk do not cost a
+// write each; 0 writes every piece as it is produced. The buffer is flushed
+// before the header is rewritten and before EncryptStream returns. On failure
+// it is dropped, since the output is incomplete anyway
+func (e *Encryptor) SetWriteBuffer(size int) {
+	e.writeBuffer = max(size, 0)
+}
+
+// bufferedOutput wraps dst in a buffer of size bytes, returning the writer to use
+// and the function that flushes it. A size of 0 returns dst itself
+func bufferedOutput(dst io.Writer, size int) (io.Writer, func() error) {
+	if size == 0 {
+		return dst, func() error { return nil }
+	}
+
+	buffered := bufio.NewWriterSize(dst, size)
+	return buffered, func() error {
+		if err := buffered.Flush(); err != nil {
+			return fmt.Errorf("%w: %v", constants.ErrFileWriteFailed, err)
+		}
+		return nil
+	}
+}
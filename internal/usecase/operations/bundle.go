@@ -0,0 +1,448 @@
+package operations
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/hambosto/hexwarden/internal/constants"
+	"github.com/hambosto/hexwarden/internal/infrastructure/crypto"
+	"github.com/hambosto/hexwarden/internal/infrastructure/utils"
+)
+
+// bundleIndexKeyLabel separates the bundle index key from the chunk key
+const bundleIndexKeyLabel = "hexwarden/bundle-index"
+
+// BundleMember describes a file stored in a bundle
+type BundleMember struct {
+	Name   string   // Base name of the file when it was bundled
+	Size   int64    // Plaintext size in bytes
+	Offset int64    // Position of the member's chunk stream, counted from the end of the header
+	Length int64    // Length of the member's chunk stream
+	SHA256 [32]byte // Digest of the plaintext, checked after extraction
+}
+
+// EncryptBundle encrypts the files at srcPaths into a single bundle at destPath.
+// Each file is encrypted as its own chunk stream so it can be extracted without
+// decrypting the others; a sealed index of names, offsets and digests follows them
+func (e *Encryptor) EncryptBundle(srcPaths []string, destPath, password string, opts EncryptOptions) (*EncryptResult, error) {
+	if err := opts.validateFor(password); err != nil {
+		return nil, err
+	}
+	if opts.Merkle {
+		return nil, fmt.Errorf("%w: bundles cannot store a Merkle root", constants.ErrInvalidOption)
+	}
+	if opts.HashOriginal {
+		return nil, fmt.Errorf("%w: bundles cannot store an original SHA-256", constants.ErrInvalidOption)
+	}
+	if opts.Manifest {
+		return nil, fmt.Errorf("%w: bundles cannot record a chunk manifest", constants.ErrInvalidOption)
+	}
+	if opts.PadOutputTo > 0 {
+		return nil, fmt.Errorf("%w: bundles cannot be padded to a fixed size", constants.ErrInvalidOption)
+	}
+	if len(srcPaths) == 0 || len(srcPaths) > constants.MaxBundleMembers {
+		return nil, fmt.Errorf("%w: a bundle holds 1 to %d files, got %d", constants.ErrInvalidBundle, constants.MaxBundleMembers, len(srcPaths))
+	}
+
+	members := make([]BundleMember, len(srcPaths))
+	sources := make([]*os.File, len(srcPaths))
+	defer func() {
+		for _, src := range sources {
+			if src != nil {
+				_ = src.Close()
+			}
+		}
+	}()
+
+	var total int64
+	seen := make(map[string]bool)
+	for i, path := range srcPaths {
+		name := filepath.Base(path)
+		if len(name) > constants.MaxFilenameLength {
+			return nil, fmt.Errorf("%w: member name exceeds %d bytes", constants.ErrInvalidBundle, constants.MaxFilenameLength)
+		}
+		if err := utils.ValidateName(name); err != nil {
+			return nil, fmt.Errorf("%w: %w", constants.ErrInvalidBundle, err)
+		}
+		if seen[name] {
+			return nil, fmt.Errorf("%w: duplicate member name %q", constants.ErrInvalidBundle, name)
+		}
+		seen[name] = true
+
+		src, info, err := e.fileManager.OpenFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open source file: %w", err)
+		}
+		sources[i] = src
+		members[i] = BundleMember{Name: name, Size: info.Size()}
+		total += info.Size()
+	}
+
+	destFile, err := e.fileManager.CreateFile(destPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create destination file: %w", err)
+	}
+	defer destFile.Close() //nolint:errcheck
+
+	result, err := e.writeBundle(sources, members, total, destFile, password, opts)
+	if err != nil {
+		// An incomplete bundle would fail to extract later; do not leave it behind
+		_ = destFile.Close()
+		if !e.fileManager.IsStreamTarget(destPath) {
+			_ = os.Remove(destPath)
+		}
+		return nil, err
+	}
+	return result, nil
+}
+
+// writeBundle writes the header, every member and the sealed index to dst
+func (e *Encryptor) writeBundle(sources []*os.File, members []BundleMember, total int64, dst io.Writer, password string, opts EncryptOptions) (*EncryptResult, error) {
+	salt, err := opts.generateSalt()
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate salt: %w", err)
+	}
+
+	key, err := e.deriveKey(e.secret(password), salt, opts.kdfParams())
+	if err != nil {
+		return nil, fmt.Errorf("failed to derive key: %w", err)
+	}
+
+	headerOpts := append(opts.headerOptions(), crypto.WithFlags(constants.FlagBundle))
+	headerOpts = append(headerOpts, e.pepperOptions()...)
+	nonceOpts, err := e.nonceOptions(opts)
+	if err != nil {
+		return nil, err
+	}
+	headerOpts = append(headerOpts, nonceOpts...)
+	header, err := crypto.Build(salt, uint64(total), key, headerOpts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create header: %w", err)
+	}
+	// Every member is sealed under the same key, so they share one chunk counter
+	nonces := chunkNonces(header, key)
+
+	out := newDigestWriter(dst)
+	if err := header.Write(out); err != nil {
+		return nil, fmt.Errorf("failed to write header: %w", err)
+	}
+	bodyStart := out.size
+
+	for i, src := range sources {
+		members[i].Offset = out.size - bodyStart
+
+		digest := sha256.New()
+		plaintext := io.TeeReader(newSizedReader(src, members[i].Size), digest)
+		if err := encryptChunks(plaintext, out, members[i].Size, key, opts.Cipher, opts.Compression, opts.AAD, nonces, opts.Paranoid, e.logger, e.idleTimeout, nil, &e.stats); err != nil {
+			return nil, fmt.Errorf("failed to encrypt %s: %w", members[i].Name, err)
+		}
+
+		members[i].Length = out.size - bodyStart - members[i].Offset
+		copy(members[i].SHA256[:], digest.Sum(nil))
+	}
+
+	sealed, err := sealBundleIndex(key, members)
+	if err != nil {
+		return nil, err
+	}
+
+	trailer := make([]byte, constants.BundleTrailerSize)
+	binary.BigEndian.PutUint32(trailer, uint32(len(sealed)))
+	if _, err := out.Write(append(sealed, trailer...)); err != nil {
+		return nil, fmt.Errorf("failed to write bundle index: %w", err)
+	}
+	return out.result(), nil
+}
+
+// ListBundle returns the members of the bundle at srcPath
+func (d *Decryptor) ListBundle(srcPath, password string) ([]BundleMember, error) {
+	srcFile, _, err := d.fileManager.OpenFile(srcPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open source file: %w", err)
+	}
+	defer srcFile.Close() //nolint:errcheck
+
+	_, _, members, err := d.openBundle(srcFile, password)
+	return members, err
+}
+
+// ExtractMember decrypts the member called name from the bundle at srcPath to destPath
+func (d *Decryptor) ExtractMember(srcPath, name, destPath, password string) (*BundleMember, error) {
+	srcFile, _, err := d.fileManager.OpenFile(srcPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open source file: %w", err)
+	}
+	defer srcFile.Close() //nolint:errcheck
+
+	// Find the member before touching the destination
+	header, key, members, err := d.openBundle(srcFile, password)
+	if err != nil {
+		return nil, err
+	}
+	if err := d.checkTimelock(header); err != nil {
+		return nil, err
+	}
+	member, err := findMember(members, name)
+	if err != nil {
+		return nil, err
+	}
+
+	destFile, err := d.fileManager.CreateFile(destPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create destination file: %w", err)
+	}
+	defer destFile.Close() //nolint:errcheck
+
+	if err := d.extractMember(srcFile, destFile, header, key, member); err != nil {
+		d.discardPartialOutput(destFile, destPath)
+		return nil, err
+	}
+	return member, nil
+}
+
+// ExtractMemberTo decrypts the member called name from the bundle at srcPath to dst.
+// dst is not closed; on failure it may hold partial plaintext the caller must discard
+func (d *Decryptor) ExtractMemberTo(srcPath, name string, dst io.Writer, password string) (*BundleMember, error) {
+	srcFile, _, err := d.fileManager.OpenFile(srcPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open source file: %w", err)
+	}
+	defer srcFile.Close() //nolint:errcheck
+
+	header, key, members, err := d.openBundle(srcFile, password)
+	if err != nil {
+		return nil, err
+	}
+	if err := d.checkTimelock(header); err != nil {
+		return nil, err
+	}
+	member, err := findMember(members, name)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := d.extractMember(srcFile, dst, header, key, member); err != nil {
+		return nil, err
+	}
+	return member, nil
+}
+
+// openBundle unlocks the header of a bundle and reads its index
+func (d *Decryptor) openBundle(srcFile *os.File, password string) (*crypto.Header, []byte, []BundleMember, error) {
+	header, key, err := d.readHeader(srcFile, password)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	if header.Flags()&constants.FlagBundle == 0 {
+		return nil, nil, nil, constants.ErrNotBundle
+	}
+	if err := header.CheckAAD(d.aad); err != nil {
+		return nil, nil, nil, err
+	}
+
+	members, err := readBundleIndex(srcFile, header, key)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	return header, key, members, nil
+}
+
+// findMember returns the member called name
+func findMember(members []BundleMember, name string) (*BundleMember, error) {
+	for i := range members {
+		if members[i].Name == name {
+			return &members[i], nil
+		}
+	}
+	return nil, fmt.Errorf("%w: %q", constants.ErrMemberNotFound, name)
+}
+
+// extractMember decrypts a single member's chunk stream to dst, checking its size and digest
+func (d *Decryptor) extractMember(srcFile *os.File, dst io.Writer, header *crypto.Header, key []byte, member *BundleMember) error {
+	section := io.NewSectionReader(srcFile, int64(header.Size())+member.Offset, member.Length)
+
+	digest := sha256.New()
+	counter := &countingWriter{}
+	out := io.MultiWriter(dst, digest, counter)
+	if err := decryptChunks(section, out, member.Size, false, key, header.Cipher(), header.Compression(), d.aad, header.Version(), d.logger, d.idleTimeout, nil, &d.stats); err != nil {
+		return fmt.Errorf("failed to decrypt %s: %w", member.Name, err)
+	}
+
+	if counter.n != member.Size {
+		return fmt.Errorf("%w: %s decrypted to %d of %d bytes", constants.ErrDecryptionFailed, member.Name, counter.n, member.Size)
+	}
+	if !hmac.Equal(digest.Sum(nil), member.SHA256[:]) {
+		return fmt.Errorf("%w: %s does not match its recorded digest", constants.ErrDecryptionFailed, member.Name)
+	}
+	return nil
+}
+
+// countingWriter counts the bytes written to it
+type countingWriter struct {
+	n int64
+}
+
+// Write counts p and discards it
+func (c *countingWriter) Write(p []byte) (int, error) {
+	c.n += int64(len(p))
+	return len(p), nil
+}
+
+// bundleChunksLength returns the length of the member chunk streams of a bundle,
+// located through the index length at the end of the file. It needs no key
+func bundleChunksLength(srcFile *os.File, header *crypto.Header) (int64, error) {
+	info, err := srcFile.Stat()
+	if err != nil {
+		return 0, fmt.Errorf("failed to stat source file: %w", err)
+	}
+
+	body := info.Size() - int64(header.Size())
+	if body < constants.BundleTrailerSize {
+		return 0, fmt.Errorf("%w: missing index", constants.ErrInvalidBundle)
+	}
+
+	trailer := make([]byte, constants.BundleTrailerSize)
+	if _, err := srcFile.ReadAt(trailer, info.Size()-constants.BundleTrailerSize); err != nil {
+		return 0, fmt.Errorf("failed to read bundle index: %w", err)
+	}
+
+	indexLen := int64(binary.BigEndian.Uint32(trailer))
+	if indexLen > constants.MaxBundleIndexSize || indexLen > body-constants.BundleTrailerSize {
+		return 0, fmt.Errorf("%w: index of %d bytes does not fit", constants.ErrInvalidBundle, indexLen)
+	}
+	return body - constants.BundleTrailerSize - indexLen, nil
+}
+
+// readBundleIndex reads, opens and validates the index at the end of a bundle
+func readBundleIndex(srcFile *os.File, header *crypto.Header, key []byte) ([]BundleMember, error) {
+	chunksLen, err := bundleChunksLength(srcFile, header)
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := srcFile.Stat()
+	if err != nil {
+		return nil, fmt.Errorf("failed to stat source file: %w", err)
+	}
+
+	indexStart := int64(header.Size()) + chunksLen
+	sealed := make([]byte, info.Size()-constants.BundleTrailerSize-indexStart)
+	if _, err := srcFile.ReadAt(sealed, indexStart); err != nil {
+		return nil, fmt.Errorf("failed to read bundle index: %w", err)
+	}
+
+	cipher, err := bundleIndexCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	data, err := cipher.Decrypt(sealed)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", constants.ErrInvalidBundle, err)
+	}
+
+	members, err := unmarshalBundleIndex(data)
+	if err != nil {
+		return nil, err
+	}
+
+	var total uint64
+	for _, m := range members {
+		if err := utils.ValidateName(m.Name); err != nil {
+			return nil, fmt.Errorf("%w: %w", constants.ErrInvalidBundle, err)
+		}
+		if m.Offset < 0 || m.Length < 0 || m.Offset > chunksLen || m.Length > chunksLen-m.Offset {
+			return nil, fmt.Errorf("%w: member %q lies outside the bundle", constants.ErrInvalidBundle, m.Name)
+		}
+		total += uint64(m.Size)
+	}
+	if total != header.OriginalSize() {
+		return nil, fmt.Errorf("%w: members hold %d bytes, header declares %d", constants.ErrInvalidBundle, total, header.OriginalSize())
+	}
+	return members, nil
+}
+
+// bundleIndexCipher returns the cipher sealing the bundle index
+func bundleIndexCipher(key []byte) (*crypto.AESCipher, error) {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(bundleIndexKeyLabel))
+	return crypto.NewAESCipher(mac.Sum(nil))
+}
+
+// sealBundleIndex serializes and encrypts the member index
+func sealBundleIndex(key []byte, members []BundleMember) ([]byte, error) {
+	cipher, err := bundleIndexCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	sealed, err := cipher.Encrypt(marshalBundleIndex(members))
+	if err != nil {
+		return nil, fmt.Errorf("failed to encrypt bundle index: %w", err)
+	}
+	if len(sealed) > constants.MaxBundleIndexSize {
+		return nil, fmt.Errorf("%w: index exceeds %d bytes", constants.ErrInvalidBundle, constants.MaxBundleIndexSize)
+	}
+	return sealed, nil
+}
+
+// marshalBundleIndex encodes the members as a count followed by one entry each:
+// [NameLen(2), Name, Size(8), Offset(8), Length(8), SHA256(32)]
+func marshalBundleIndex(members []BundleMember) []byte {
+	buf := binary.BigEndian.AppendUint32(nil, uint32(len(members)))
+	for _, m := range members {
+		buf = binary.BigEndian.AppendUint16(buf, uint16(len(m.Name)))
+		buf = append(buf, m.Name...)
+		buf = binary.BigEndian.AppendUint64(buf, uint64(m.Size))
+		buf = binary.BigEndian.AppendUint64(buf, uint64(m.Offset))
+		buf = binary.BigEndian.AppendUint64(buf, uint64(m.Length))
+		buf = append(buf, m.SHA256[:]...)
+	}
+	return buf
+}
+
+// unmarshalBundleIndex decodes an index written by marshalBundleIndex
+func unmarshalBundleIndex(data []byte) ([]BundleMember, error) {
+	if len(data) < 4 {
+		return nil, fmt.Errorf("%w: truncated", constants.ErrInvalidBundle)
+	}
+	count := binary.BigEndian.Uint32(data)
+	data = data[4:]
+	if count == 0 || count > constants.MaxBundleMembers {
+		return nil, fmt.Errorf("%w: %d members", constants.ErrInvalidBundle, count)
+	}
+
+	members := make([]BundleMember, 0, count)
+	for range count {
+		if len(data) < 2 {
+			return nil, fmt.Errorf("%w: truncated", constants.ErrInvalidBundle)
+		}
+		nameLen := int(binary.BigEndian.Uint16(data))
+		data = data[2:]
+		if nameLen == 0 || len(data) < nameLen+3*8+sha256.Size {
+			return nil, fmt.Errorf("%w: truncated", constants.ErrInvalidBundle)
+		}
+
+		m := BundleMember{Name: string(data[:nameLen])}
+		data = data[nameLen:]
+		m.Size = int64(binary.BigEndian.Uint64(data[0:8]))
+		m.Offset = int64(binary.BigEndian.Uint64(data[8:16]))
+		m.Length = int64(binary.BigEndian.Uint64(data[16:24]))
+		copy(m.SHA256[:], data[24:24+sha256.Size])
+		data = data[24+sha256.Size:]
+
+		if m.Size < 0 {
+			return nil, fmt.Errorf("%w: member %q has a negative size", constants.ErrInvalidBundle, m.Name)
+		}
+		members = append(members, m)
+	}
+
+	if len(data) != 0 {
+		return nil, fmt.Errorf("%w: %d trailing bytes", constants.ErrInvalidBundle, len(data))
+	}
+	return members, nil
+}
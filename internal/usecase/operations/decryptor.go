@@ -1,74 +1,353 @@
 package operations
 
 import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
 	"fmt"
+	"io"
+	"log/slog"
 	"math"
+	"os"
+	"time"
 
 	"github.com/hambosto/hexwarden/internal/constants"
 	"github.com/hambosto/hexwarden/internal/data/files"
 	"github.com/hambosto/hexwarden/internal/data/streaming"
+	"github.com/hambosto/hexwarden/internal/infrastructure"
 	"github.com/hambosto/hexwarden/internal/infrastructure/crypto"
+	"github.com/hambosto/hexwarden/internal/infrastructure/utils"
+	"github.com/hambosto/hexwarden/internal/presentation/ui"
 )
 
+// KeyDerivationFunc derives an encryption key from a password, salt and cost parameters
+type KeyDerivationFunc func(password, salt []byte, params crypto.KDFParams) ([]byte, error)
+
 // Decryptor handles file decryption operations
 type Decryptor struct {
-	fileManager *files.Manager
-	fileFinder  *files.Finder
+	fileManager    *files.Manager
+	fileFinder     *files.Finder
+	deriveKey      KeyDerivationFunc
+	pepper         []byte
+	recoveryCode   string
+	escrow         *escrowUnlock
+	logger         *slog.Logger
+	idleTimeout    time.Duration
+	verifyHash     bool
+	ignoreTimelock bool
+	aad            []byte
+	zeroCorrupt    bool
+	holes          []streaming.Hole // Zero-filled ranges of the last chunked body decrypted with zeroCorrupt
+	stats          streaming.PoolStats
 }
 
 // NewDecryptor creates a new decryptor instance
 func NewDecryptor() *Decryptor {
+	return NewDecryptorWithKDF(deriveWithSpinner(crypto.DeriveKeyWithParams))
+}
+
+// deriveWithSpinner wraps a key derivation function so a spinner is shown
+// on interactive terminals while it runs, since costly parameters take seconds
+func deriveWithSpinner(derive KeyDerivationFunc) KeyDerivationFunc {
+	return func(password, salt []byte, params crypto.KDFParams) (key []byte, err error) {
+		ui.NewSpinner().Run("Deriving key...", func() {
+			key, err = derive(password, salt, params)
+		})
+		return key, err
+	}
+}
+
+// NewDecryptorWithKDF creates a decryptor that derives keys with the given function
+func NewDecryptorWithKDF(deriveKey KeyDerivationFunc) *Decryptor {
 	return &Decryptor{
 		fileManager: files.NewManager(),
 		fileFinder:  files.NewFinder(),
+		deriveKey:   deriveKey,
+		logger:      loggerOrDiscard(nil),
+	}
+}
+
+// SetVerifyHash makes decryption check the plaintext against the SHA-256 of the
+// original file stored with EncryptOptions.HashOriginal, failing with
+// ErrOriginalMismatch when they differ and ErrNoOriginalHash when none is stored
+func (d *Decryptor) SetVerifyHash(enabled bool) {
+	d.verifyHash = enabled
+}
+
+// SetIgnoreTimelock lets decryption go ahead before the not-before time stored
+// with EncryptOptions.NotBefore, instead of failing with ErrTimeLocked
+func (d *Decryptor) SetIgnoreTimelock(enabled bool) {
+	d.ignoreTimelock = enabled
+}
+
+// SetIgnoreCorruptChunks makes DecryptFile and DecryptStream write zeros for
+// chunks that cannot be decoded or authenticated and carry on, listing them in
+// HeaderInfo.Holes, instead of failing. It is meant for salvaging damaged files:
+// the output is incomplete whenever Holes is not empty
+func (d *Decryptor) SetIgnoreCorruptChunks(enabled bool) {
+	d.zeroCorrupt = enabled
+}
+
+// SetAAD sets the associated data the body was bound to with EncryptOptions.AAD.
+// Decryption fails with ErrAADMismatch unless it matches the file
+func (d *Decryptor) SetAAD(aad []byte) {
+	d.aad = aad
+}
+
+// checkTimelock refuses a header whose not-before time has not passed yet. The
+// time is authenticated by the header, but only this check enforces it
+func (d *Decryptor) checkTimelock(header *crypto.Header) error {
+	notBefore := header.NotBefore()
+	if d.ignoreTimelock || notBefore.IsZero() || !time.Now().Before(notBefore) {
+		return nil
 	}
+	return fmt.Errorf("%w: locked until %s", constants.ErrTimeLocked, utils.FormatTimestamp(notBefore))
 }
 
-// DecryptFile decrypts a file from source to destination
-func (d *Decryptor) DecryptFile(srcPath, destPath, password string) error {
+// DecryptFile decrypts a file from source to destination, returning the unlocked header details
+func (d *Decryptor) DecryptFile(srcPath, destPath, password string) (*HeaderInfo, error) {
 	// Open source file
 	srcFile, _, err := d.fileManager.OpenFile(srcPath)
 	if err != nil {
-		return fmt.Errorf("failed to open source file: %w", err)
+		return nil, fmt.Errorf("failed to open source file: %w", err)
 	}
 	defer srcFile.Close() //nolint:errcheck
 
-	// Read and parse header
-	header, err := crypto.ReadHeader(srcFile)
+	// Verify the password before touching the destination
+	header, key, err := d.readHeader(srcFile, password)
 	if err != nil {
-		return fmt.Errorf("failed to read header: %w", err)
+		return nil, err
 	}
-
-	// Derive key from password and verify
-	key, err := crypto.DeriveKey([]byte(password), header.Salt())
-	if err != nil {
-		return fmt.Errorf("failed to derive key: %w", err)
+	if err := d.checkTimelock(header); err != nil {
+		return nil, err
+	}
+	if err := header.CheckAAD(d.aad); err != nil {
+		return nil, err
 	}
 
-	if err := header.VerifyKey(key); err != nil {
-		return fmt.Errorf("header verification failed: %w", err)
+	info, err := newHeaderInfo(header, key)
+	if err != nil {
+		return nil, err
 	}
 
-	// Validate original size
-	originalSize := header.OriginalSize()
-	if originalSize > math.MaxInt64 {
-		return fmt.Errorf("file too large: %d bytes", originalSize)
+	// A reference holds no plaintext, only the path of a file that does
+	if header.Flags()&constants.FlagReference != 0 {
+		if err := d.decryptReference(srcFile, srcPath, destPath, password, header, key); err != nil {
+			return nil, err
+		}
+		info.Holes = d.holes
+		return info, nil
 	}
 
 	// Create destination file
 	destFile, err := d.fileManager.CreateFile(destPath)
 	if err != nil {
-		return fmt.Errorf("failed to create destination file: %w", err)
+		return nil, fmt.Errorf("failed to create destination file: %w", err)
 	}
 	defer destFile.Close() //nolint:errcheck
 
+	if err := d.decryptBody(srcFile, destFile, header, key); err != nil {
+		d.discardPartialOutput(destFile, destPath)
+		return nil, err
+	}
+	info.Holes = d.holes
+	return info, nil
+}
+
+// DecryptStream decrypts an encrypted file read from src and writes the plaintext to dst.
+// dst is not closed; on failure it may hold partial plaintext the caller must discard
+func (d *Decryptor) DecryptStream(src io.Reader, dst io.Writer, password string) (*HeaderInfo, error) {
+	if src == nil || dst == nil {
+		return nil, constants.ErrNilStream
+	}
+
+	header, key, err := d.readHeader(src, password)
+	if err != nil {
+		return nil, err
+	}
+	if err := d.checkTimelock(header); err != nil {
+		return nil, err
+	}
+	if err := header.CheckAAD(d.aad); err != nil {
+		return nil, err
+	}
+
+	info, err := newHeaderInfo(header, key)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := d.decryptBody(src, dst, header, key); err != nil {
+		return nil, err
+	}
+	info.Holes = d.holes
+	return info, nil
+}
+
+// readHeader parses the header from src and verifies the key derived from
+// password, or unwrapped with the escrowed key or recovery code when one is set.
+//
+// A damaged header and a wrong password must not be distinguishable by how long
+// the failure takes, otherwise an attacker timing attempts learns whether a forged
+// header got past parsing. Every path therefore runs the full KDF before failing
+func (d *Decryptor) readHeader(src io.Reader, password string) (*crypto.Header, []byte, error) {
+	// Read and parse header
+	start := time.Now()
+	header, err := crypto.ReadHeader(src)
+	if err != nil {
+		d.deriveDummyKey(password)
+		return nil, nil, fmt.Errorf("failed to read header: %w", err)
+	}
+	logStage(d.logger, "header read", start)
+
+	// Nothing is secret in a protected file, so there is no timing to hide
+	if header.Flags()&constants.FlagUnencrypted != 0 {
+		return nil, nil, constants.ErrUnencrypted
+	}
+
+	var key []byte
+	switch {
+	case d.escrow != nil:
+		key, err = d.escrowedKey(header)
+	case d.recoveryCode != "":
+		key, err = d.recoverKey(header)
+	default:
+		key, err = d.passwordKey(header, password)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Validate original size
+	if header.OriginalSize() > math.MaxInt64 {
+		return nil, nil, fmt.Errorf("file too large: %d bytes", header.OriginalSize())
+	}
+
+	return header, key, nil
+}
+
+// passwordKey derives the key of header from password and verifies it, falling
+// back to the key slots when password is not the one the file was encrypted with
+func (d *Decryptor) passwordKey(header *crypto.Header, password string) ([]byte, error) {
+	secret, err := headerSecret(header, password, d.pepper)
+	if err != nil {
+		return nil, err
+	}
+
+	start := time.Now()
+	key, err := d.deriveKey(secret, header.Salt(), header.KDFParams())
+	if err != nil {
+		return nil, fmt.Errorf("failed to derive key: %w", err)
+	}
+	err = header.VerifyKey(key)
+	if errors.Is(err, constants.ErrAuthFailure) && header.KeySlots() > 0 {
+		if slotted, _, slotErr := d.slotKey(header, secret); slotErr == nil {
+			key, err = slotted, nil
+		} else if !errors.Is(slotErr, constants.ErrNoKeySlot) {
+			return nil, slotErr
+		}
+	}
+	logStage(d.logger, "kdf", start)
+
+	if err != nil {
+		return nil, fmt.Errorf("header verification failed: %w", err)
+	}
+	return key, nil
+}
+
+// deriveDummyKey runs the KDF with default parameters and discards the result,
+// matching the cost of a real attempt when the header could not be parsed
+func (d *Decryptor) deriveDummyKey(password string) {
+	salt := make([]byte, constants.SaltSize)
+	_, _ = d.deriveKey([]byte(password), salt, crypto.DefaultKDFParams())
+}
+
+// decryptBody decrypts the chunks following the header, checking the output
+// against the stored SHA-256 of the original file when verification is on
+func (d *Decryptor) decryptBody(src io.Reader, dst io.Writer, header *crypto.Header, key []byte) error {
+	if !d.verifyHash {
+		return d.decryptPayload(src, dst, header, key)
+	}
+
+	want, err := header.OriginalSHA256(key)
+	if err != nil {
+		return err
+	}
+	if want == nil {
+		return constants.ErrNoOriginalHash
+	}
+
+	digest := sha256.New()
+	if err := d.decryptPayload(src, io.MultiWriter(dst, digest), header, key); err != nil {
+		return err
+	}
+	if got := digest.Sum(nil); !bytes.Equal(got, want) {
+		return fmt.Errorf("%w: got %x, stored %x", constants.ErrOriginalMismatch, got, want)
+	}
+	return nil
+}
+
+// decryptPayload decrypts the body following the header into dst
+func (d *Decryptor) decryptPayload(src io.Reader, dst io.Writer, header *crypto.Header, key []byte) error {
+	d.holes = nil
+	if header.Flags()&constants.FlagReference != 0 {
+		return constants.ErrReference
+	}
+	if header.Flags()&constants.FlagRawBody != 0 {
+		start := time.Now()
+		if err := decryptRawBody(src, dst, header.OriginalSize(), key, header.Cipher(), d.aad); err != nil {
+			return err
+		}
+		logStage(d.logger, "body", start)
+		return nil
+	}
+	if header.Flags()&constants.FlagBundle != 0 {
+		return constants.ErrBundle
+	}
+	if header.Flags()&constants.FlagLog != 0 {
+		_, err := decryptLog(src, dst, key)
+		return err
+	}
+
+	// Process the remaining data after header
+	var holes *[]streaming.Hole
+	if d.zeroCorrupt {
+		holes = &d.holes
+	}
+	return decryptChunks(src, dst, bodySize(header), isPadded(header), key, header.Cipher(), header.Compression(), d.aad, header.Version(), d.logger, d.idleTimeout, holes, &d.stats)
+}
+
+// decryptChunks decrypts a stream of framed chunks holding size bytes of plaintext,
+// logging throughput to logger and giving up after idleTimeout without progress
+// when it is set, and adding the worker pool utilization to stats. Every chunk
+// must be compressed in format and bound to aad. A nil key reads chunks written
+// without encryption. When holes is set, chunks that cannot be decrypted are
+// written as zeros and stored there instead of failing. The plaintext written
+// must add up to size, failing with ErrSizeMismatch otherwise; a size of
+// UnknownSize reads a body ending in a size footer, checked against it instead.
+// When padded is set the chunks end at the footer marker and the output
+// padding after it is checked too
+func decryptChunks(src io.Reader, dst io.Writer, size int64, padded bool, key []byte, cipher constants.CipherAlgorithm, format constants.CompressionFormat, aad []byte, formatVersion uint8, logger *slog.Logger, idleTimeout time.Duration, holes *[]streaming.Hole, stats *streaming.PoolStats) error {
 	// Create stream processor for decryption
 	config := streaming.StreamConfig{
-		Key:         key,
-		Processing:  constants.Decryption,
-		Concurrency: constants.MaxConcurrency,
-		QueueSize:   constants.QueueSize,
-		ChunkSize:   constants.DefaultChunkSize,
+		Key:           key,
+		Cipher:        cipher,
+		Compression:   format,
+		AAD:           aad,
+		Processing:    constants.Decryption,
+		Concurrency:   constants.MaxConcurrency,
+		QueueSize:     constants.QueueSize,
+		ChunkSize:     constants.DefaultChunkSize,
+		FormatVersion: formatVersion,
+		Logger:        logger,
+		IdleTimeout:   idleTimeout,
+		Unencrypted:   key == nil,
+		ZeroCorrupt:   holes != nil,
+		Footer:        size == constants.UnknownSize || padded,
+	}
+	// Without a declared size the output is only bounded by the footer, checked at the end
+	if size >= 0 {
+		config.MaxOutputSize = maxPlaintextSize(uint64(size))
 	}
 
 	processor, err := streaming.NewStreamProcessor(config)
@@ -76,6 +355,60 @@ func (d *Decryptor) DecryptFile(srcPath, destPath, password string) error {
 		return fmt.Errorf("failed to create stream processor: %w", err)
 	}
 
-	// Process the file (remaining data after header)
-	return processor.Process(srcFile, destFile, int64(originalSize))
+	// Count the plaintext as it is written so a wrong length is caught at the end
+	counter := &countingWriter{}
+	dst = io.MultiWriter(dst, counter)
+
+	defer func() { stats.Add(processor.Stats()) }()
+	if err := processor.Process(src, dst, size); err != nil {
+		return err
+	}
+	if holes != nil {
+		*holes = processor.Holes()
+	}
+	if size == constants.UnknownSize {
+		return checkSizeFooter(src, key, counter.n)
+	}
+	if counter.n != size {
+		return fmt.Errorf("%w: wrote %d bytes, header declares %d", constants.ErrSizeMismatch, counter.n, size)
+	}
+	if padded {
+		return checkOutputPadding(src, key)
+	}
+	return nil
+}
+
+// newBodyProcessor creates a processor for single chunks of the body header
+// describes, encrypted with key and bound to aad
+func newBodyProcessor(header *crypto.Header, key, aad []byte) (*infrastructure.Processor, error) {
+	processor, err := infrastructure.NewProcessorWithAAD(key, header.Cipher(), aad)
+	if err != nil {
+		return nil, err
+	}
+	if err := processor.SetCompression(header.Compression()); err != nil {
+		return nil, err
+	}
+	return processor, nil
+}
+
+// maxPlaintextSize returns how much plaintext a body declaring originalSize
+// bytes may produce before decryption is aborted as a decompression bomb
+func maxPlaintextSize(originalSize uint64) int64 {
+	if originalSize > math.MaxInt64-constants.DecompressionTolerance {
+		return math.MaxInt64
+	}
+	return int64(originalSize) + constants.DecompressionTolerance
+}
+
+// discardPartialOutput removes an incomplete destination, overwriting the
+// plaintext already flushed to it so it cannot be recovered from disk.
+// A pipe or device is left in place since nothing was stored in it
+func (d *Decryptor) discardPartialOutput(destFile *os.File, destPath string) {
+	_ = destFile.Close()
+	if d.fileManager.IsStreamTarget(destPath) {
+		return
+	}
+	if err := d.fileManager.Remove(destPath, constants.DeleteSecure); err != nil {
+		_ = os.Remove(destPath) // Still unlink the file if the overwrite failed
+	}
 }
@@ -0,0 +1,201 @@
+package operations
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/hambosto/hexwarden/internal/constants"
+	"github.com/hambosto/hexwarden/internal/infrastructure/crypto"
+)
+
+// referenceVersion is the layout of a dedup reference body
+const referenceVersion = 1
+
+// Reference is the body of a dedup reference: it names the encrypted file that
+// holds the plaintext and records the plaintext's SHA-256, checked on decryption
+type Reference struct {
+	Version int    `json:"version"`
+	Target  string `json:"target"` // Target relative to the reference's directory, with forward slashes
+	SHA256  string `json:"sha256"` // Hex SHA-256 of the plaintext
+}
+
+// Validate checks the reference names a relative target and holds a SHA-256
+func (r *Reference) Validate() error {
+	if r.Version != referenceVersion {
+		return fmt.Errorf("%w: unsupported version %d", constants.ErrInvalidReference, r.Version)
+	}
+	if r.Target == "" || strings.HasPrefix(r.Target, "/") || filepath.IsAbs(filepath.FromSlash(r.Target)) {
+		return fmt.Errorf("%w: target must be a relative path: %q", constants.ErrInvalidReference, r.Target)
+	}
+	if sum, err := hex.DecodeString(r.SHA256); err != nil || len(sum) != sha256.Size {
+		return fmt.Errorf("%w: invalid SHA-256", constants.ErrInvalidReference)
+	}
+	return nil
+}
+
+// HashFile returns the SHA-256 of the file at path
+func HashFile(path string) ([]byte, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open source file: %w", err)
+	}
+	defer file.Close() //nolint:errcheck
+
+	digest := sha256.New()
+	if _, err := io.Copy(digest, file); err != nil {
+		return nil, fmt.Errorf("failed to hash source file: %w", err)
+	}
+	return digest.Sum(nil), nil
+}
+
+// EncryptReference writes a dedup reference at destPath standing for the
+// plaintext with SHA-256 digest, which targetPath already holds encrypted with
+// the same password. The reference is itself a small encrypted file, so which
+// files share content is not visible without the password. Options needing a
+// chunked body are ignored
+func (e *Encryptor) EncryptReference(destPath, targetPath string, digest []byte, password string, opts EncryptOptions) (*EncryptResult, error) {
+	if len(digest) != sha256.Size {
+		return nil, fmt.Errorf("%w: digest must be %d bytes", constants.ErrInvalidOption, sha256.Size)
+	}
+	target, err := relativeTarget(destPath, targetPath)
+	if err != nil {
+		return nil, err
+	}
+	data, err := json.Marshal(Reference{Version: referenceVersion, Target: target, SHA256: hex.EncodeToString(digest)})
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode reference: %w", err)
+	}
+
+	opts.Thumbnail = nil
+	opts.Merkle, opts.HashOriginal, opts.Manifest = false, false, false
+	opts.PadOutputTo = 0
+	opts.SmallFileThreshold = constants.MaxRawBodySize
+	opts.flags |= constants.FlagReference
+
+	destFile, err := e.fileManager.CreateFile(destPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create destination file: %w", err)
+	}
+	defer destFile.Close() //nolint:errcheck
+
+	result, err := e.EncryptStream(bytes.NewReader(data), destFile, int64(len(data)), password, opts)
+	if err != nil {
+		_ = destFile.Close()
+		_ = os.Remove(destPath)
+		return nil, err
+	}
+	return result, nil
+}
+
+// relativeTarget returns the path of targetPath seen from the directory of destPath
+func relativeTarget(destPath, targetPath string) (string, error) {
+	destAbs, err := filepath.Abs(destPath)
+	if err != nil {
+		return "", err
+	}
+	targetAbs, err := filepath.Abs(targetPath)
+	if err != nil {
+		return "", err
+	}
+	if destAbs == targetAbs {
+		return "", fmt.Errorf("%w: a reference cannot point to itself", constants.ErrInvalidOption)
+	}
+	target, err := filepath.Rel(filepath.Dir(destAbs), targetAbs)
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", constants.ErrInvalidOption, err)
+	}
+	return filepath.ToSlash(target), nil
+}
+
+// readReference decrypts and validates the body of the reference header belongs to
+func (d *Decryptor) readReference(src io.Reader, header *crypto.Header, key []byte) (*Reference, error) {
+	if header.Flags()&constants.FlagRawBody == 0 {
+		return nil, fmt.Errorf("%w: body is not a single message", constants.ErrInvalidReference)
+	}
+
+	var body bytes.Buffer
+	if err := decryptRawBody(src, &body, header.OriginalSize(), key, header.Cipher(), d.aad); err != nil {
+		return nil, err
+	}
+	ref := &Reference{}
+	if err := json.Unmarshal(body.Bytes(), ref); err != nil {
+		return nil, fmt.Errorf("%w: %v", constants.ErrInvalidReference, err)
+	}
+	if err := ref.Validate(); err != nil {
+		return nil, err
+	}
+	return ref, nil
+}
+
+// decryptReference writes the plaintext a reference at srcPath stands for to
+// destPath, decrypting its target with the same password and checking the
+// output against the SHA-256 the reference records. A target that is itself a
+// reference is refused, so references never chain
+func (d *Decryptor) decryptReference(src io.Reader, srcPath, destPath, password string, header *crypto.Header, key []byte) error {
+	ref, err := d.readReference(src, header, key)
+	if err != nil {
+		return err
+	}
+	want, _ := hex.DecodeString(ref.SHA256)
+
+	targetPath := filepath.Join(filepath.Dir(srcPath), filepath.FromSlash(ref.Target))
+	targetFile, _, err := d.fileManager.OpenFile(targetPath)
+	if err != nil {
+		return fmt.Errorf("failed to open reference target: %w", err)
+	}
+	defer targetFile.Close() //nolint:errcheck
+
+	targetHeader, targetKey, err := d.readHeader(targetFile, password)
+	if err != nil {
+		return fmt.Errorf("reference target %s: %w", targetPath, err)
+	}
+	if targetHeader.Flags()&constants.FlagReference != 0 {
+		return fmt.Errorf("%w: target %s is itself a reference", constants.ErrInvalidReference, targetPath)
+	}
+	if err := d.checkTimelock(targetHeader); err != nil {
+		return err
+	}
+	if err := targetHeader.CheckAAD(d.aad); err != nil {
+		return err
+	}
+
+	destFile, err := d.fileManager.CreateFile(destPath)
+	if err != nil {
+		return fmt.Errorf("failed to create destination file: %w", err)
+	}
+	defer destFile.Close() //nolint:errcheck
+
+	digest := sha256.New()
+	if err := d.decryptBody(targetFile, io.MultiWriter(destFile, digest), targetHeader, targetKey); err != nil {
+		d.discardPartialOutput(destFile, destPath)
+		return err
+	}
+	if got := digest.Sum(nil); !bytes.Equal(got, want) {
+		d.discardPartialOutput(destFile, destPath)
+		return fmt.Errorf("%w: target %s no longer holds the same content", constants.ErrInvalidReference, targetPath)
+	}
+	return nil
+}
+
+// IsReference reports whether the encrypted file at path is a dedup reference.
+// The flag is public, so no password is needed
+func (d *Decryptor) IsReference(path string) (bool, error) {
+	srcFile, _, err := d.fileManager.OpenFile(path)
+	if err != nil {
+		return false, fmt.Errorf("failed to open source file: %w", err)
+	}
+	defer srcFile.Close() //nolint:errcheck
+
+	header, err := crypto.ReadHeader(srcFile)
+	if err != nil {
+		return false, fmt.Errorf("failed to read header: %w", err)
+	}
+	return header.Flags()&constants.FlagReference != 0, nil
+}
@@ -0,0 +1,94 @@
+package operations
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"hash"
+	"io"
+
+	"github.com/hambosto/hexwarden/internal/constants"
+	"github.com/hambosto/hexwarden/internal/infrastructure/crypto"
+)
+
+// deferredHeader collects header fields that are only known once the body is
+// written, the Merkle root and the SHA-256 of the source, and rewrites the
+// header with them over its placeholder
+type deferredHeader struct {
+	dst      io.WriteSeeker
+	start    int64     // Offset of the header in dst
+	merkle   bool      // Collect Merkle leaves from the chunks
+	leaves   [][]byte  // Leaf hashes of the chunks written so far
+	original hash.Hash // Hash of the source read so far, nil when not wanted
+}
+
+// newDeferredHeader prepares dst for the deferred fields selected by opts, or
+// returns nil when none is wanted
+func newDeferredHeader(dst io.Writer, opts EncryptOptions) (*deferredHeader, error) {
+	if !opts.Merkle && !opts.HashOriginal {
+		return nil, nil
+	}
+
+	ws, ok := dst.(io.WriteSeeker)
+	if !ok {
+		return nil, fmt.Errorf("%w: the Merkle root and original SHA-256 are written after the body", constants.ErrNotSeekable)
+	}
+	start, err := ws.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", constants.ErrNotSeekable, err)
+	}
+
+	d := &deferredHeader{dst: ws, start: start, merkle: opts.Merkle}
+	if opts.HashOriginal {
+		d.original = sha256.New()
+	}
+	return d, nil
+}
+
+// source returns src, hashing what is read from it when the SHA-256 of the
+// source is wanted
+func (d *deferredHeader) source(src io.Reader) io.Reader {
+	if d == nil || d.original == nil {
+		return src
+	}
+	return io.TeeReader(src, d.original)
+}
+
+// onChunk returns the chunk callback for the stream processor, nil when no
+// Merkle root is wanted
+func (d *deferredHeader) onChunk() func([]byte) {
+	if d == nil || !d.merkle {
+		return nil
+	}
+	return func(chunk []byte) {
+		d.leaves = append(d.leaves, crypto.HashMerkleLeaf(chunk))
+	}
+}
+
+// finish stores the deferred fields in header and their values in result, and
+// patches the header over its placeholder. The output is not read back: the
+// digest taken while writing covered the placeholder, and hashing the finished
+// output would take a second pass over it, so result reports no SHA-256
+func (d *deferredHeader) finish(header *crypto.Header, key []byte, result *EncryptResult) error {
+	result.SHA256 = nil
+
+	if d.merkle {
+		result.Merkle = crypto.NewMerkleTree(d.leaves)
+		if err := header.SetMerkleRoot(key, result.Merkle.Root()); err != nil {
+			return fmt.Errorf("failed to store Merkle root: %w", err)
+		}
+	}
+	if d.original != nil {
+		result.OriginalSHA256 = d.original.Sum(nil)
+		if err := header.SetOriginalSHA256(key, result.OriginalSHA256); err != nil {
+			return fmt.Errorf("failed to store original SHA-256: %w", err)
+		}
+	}
+
+	if _, err := d.dst.Seek(d.start, io.SeekStart); err != nil {
+		return fmt.Errorf("failed to rewrite header: %w", err)
+	}
+	if err := header.Write(d.dst); err != nil {
+		return fmt.Errorf("failed to rewrite header: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,77 @@
+package operations
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+
+	"github.com/hambosto/hexwarden/internal/constants"
+	"github.com/hambosto/hexwarden/internal/infrastructure/crypto"
+)
+
+// EncryptResult describes the output of a successful encryption
+type EncryptResult struct {
+	Size           int64              // Bytes written, including the header
+	SHA256         []byte             // Digest of everything written, for external catalogs; nil when the header was rewritten after the body
+	Merkle         *crypto.MerkleTree // Tree over the chunks when EncryptOptions.Merkle was set
+	OriginalSHA256 []byte             // Digest of the source when EncryptOptions.HashOriginal was set
+	Manifest       *ChunkManifest     // Offsets and checksums of the chunks when EncryptOptions.Manifest was set
+	EscrowedKey    []byte             // File key wrapped to EncryptOptions.EscrowKey when it was set, never the key itself
+}
+
+// digestWriter hashes and counts the bytes passed through to the underlying writer
+type digestWriter struct {
+	writer io.Writer
+	hash   hash.Hash
+	size   int64
+}
+
+// newDigestWriter wraps w so the encrypted output is hashed as it is written
+func newDigestWriter(w io.Writer) *digestWriter {
+	return &digestWriter{writer: w, hash: sha256.New()}
+}
+
+// Write forwards p and hashes the part that was actually written
+func (d *digestWriter) Write(p []byte) (int, error) {
+	n, err := d.writer.Write(p)
+	d.hash.Write(p[:n])
+	d.size += int64(n)
+	return n, err
+}
+
+// result returns the size and digest of everything written so far
+func (d *digestWriter) result() *EncryptResult {
+	return &EncryptResult{Size: d.size, SHA256: d.hash.Sum(nil)}
+}
+
+// ParseDigest decodes a hex-encoded SHA-256 digest
+func ParseDigest(value string) ([]byte, error) {
+	digest, err := hex.DecodeString(value)
+	if err != nil || len(digest) != sha256.Size {
+		return nil, fmt.Errorf("invalid SHA-256 digest: %q", value)
+	}
+	return digest, nil
+}
+
+// CheckDigest verifies that the file at srcPath hashes to expected, so a
+// damaged or substituted file is rejected before decryption starts
+func (d *Decryptor) CheckDigest(srcPath string, expected []byte) error {
+	srcFile, _, err := d.fileManager.OpenFile(srcPath)
+	if err != nil {
+		return fmt.Errorf("failed to open source file: %w", err)
+	}
+	defer srcFile.Close() //nolint:errcheck
+
+	digest := sha256.New()
+	if _, err := io.Copy(digest, srcFile); err != nil {
+		return fmt.Errorf("failed to hash source file: %w", err)
+	}
+
+	if actual := digest.Sum(nil); !bytes.Equal(actual, expected) {
+		return fmt.Errorf("%w: expected %x, got %x", constants.ErrDigestMismatch, expected, actual)
+	}
+	return nil
+}
@@ -2,6 +2,10 @@ package operations
 
 import (
 	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"time"
 
 	"github.com/hambosto/hexwarden/internal/constants"
 	"github.com/hambosto/hexwarden/internal/data/files"
@@ -13,74 +17,238 @@ import (
 type Encryptor struct {
 	fileManager *files.Manager
 	fileFinder  *files.Finder
+	deriveKey   KeyDerivationFunc
+	pepper      []byte
+	logger      *slog.Logger
+	idleTimeout time.Duration
+	writeBuffer int
+	stats       streaming.PoolStats
 }
 
 // NewEncryptor creates a new encryptor instance
 func NewEncryptor() *Encryptor {
+	return NewEncryptorWithKDF(deriveWithSpinner(crypto.DeriveKeyWithParams))
+}
+
+// NewEncryptorWithKDF creates an encryptor that derives keys with the given function
+func NewEncryptorWithKDF(deriveKey KeyDerivationFunc) *Encryptor {
 	return &Encryptor{
 		fileManager: files.NewManager(),
 		fileFinder:  files.NewFinder(),
+		deriveKey:   deriveKey,
+		logger:      loggerOrDiscard(nil),
+		writeBuffer: constants.WriteBufferSize,
 	}
 }
 
-// EncryptFile encrypts a file from source to destination
-func (e *Encryptor) EncryptFile(srcPath, destPath, password string) error {
+// EncryptFile encrypts a file from source to destination, returning the size and digest of the output
+func (e *Encryptor) EncryptFile(srcPath, destPath, password string, opts EncryptOptions) (*EncryptResult, error) {
+	if err := opts.validateFor(password); err != nil {
+		return nil, err
+	}
+
 	// Open source file
 	srcFile, srcInfo, err := e.fileManager.OpenFile(srcPath)
 	if err != nil {
-		return fmt.Errorf("failed to open source file: %w", err)
+		return nil, fmt.Errorf("failed to open source file: %w", err)
 	}
-	srcFile.Close() //nolint:errcheck
+	defer srcFile.Close() //nolint:errcheck
 
 	// Create destination file
 	destFile, err := e.fileManager.CreateFile(destPath)
 	if err != nil {
-		return fmt.Errorf("failed to create destination file: %w", err)
+		return nil, fmt.Errorf("failed to create destination file: %w", err)
 	}
 	defer destFile.Close() //nolint:errcheck
 
+	result, err := e.EncryptStream(srcFile, destFile, srcInfo.Size(), password, opts)
+	if err != nil {
+		// An incomplete file would fail to decrypt later; do not leave it behind
+		_ = destFile.Close()
+		if !e.fileManager.IsStreamTarget(destPath) {
+			_ = os.Remove(destPath)
+		}
+		return nil, err
+	}
+	return result, nil
+}
+
+// EncryptStream encrypts size bytes read from src and writes the encrypted file to dst.
+// dst only needs to be an io.Writer; it is never seeked and is not closed. A size
+// of UnknownSize encrypts src until it ends and records its size in a sealed
+// footer after the chunks, which decryption checks to catch a truncated file.
+// With opts.PadOutputTo set, the output is filled up to exactly that size
+func (e *Encryptor) EncryptStream(src io.Reader, dst io.Writer, size int64, password string, opts EncryptOptions) (*EncryptResult, error) {
+	if src == nil || dst == nil {
+		return nil, constants.ErrNilStream
+	}
+	if err := opts.validateFor(password); err != nil {
+		return nil, err
+	}
+
+	// Validate source size
+	unknownSize := size == constants.UnknownSize
+	if size < 0 && !unknownSize {
+		return nil, fmt.Errorf("invalid file size: %d", size)
+	}
+	if unknownSize && opts.PadOutputTo > 0 {
+		return nil, fmt.Errorf("%w: output padding needs the source size in advance", constants.ErrInvalidOption)
+	}
+
+	// The Merkle root and source digest are only known after the body, so the
+	// header is rewritten
+	deferred, err := newDeferredHeader(dst, opts)
+	if err != nil {
+		return nil, err
+	}
+
 	// Generate salt for key derivation
-	salt, err := crypto.GenerateSalt()
+	salt, err := opts.generateSalt()
 	if err != nil {
-		return fmt.Errorf("failed to generate salt: %w", err)
+		return nil, fmt.Errorf("failed to generate salt: %w", err)
 	}
 
 	// Derive key from password
-	key, err := crypto.DeriveKey([]byte(password), salt)
+	start := time.Now()
+	key, err := e.deriveKey(e.secret(password), salt, opts.kdfParams())
+	if err != nil {
+		return nil, fmt.Errorf("failed to derive key: %w", err)
+	}
+	slotOpts, err := e.keySlotOptions(opts)
 	if err != nil {
-		return fmt.Errorf("failed to derive key: %w", err)
+		return nil, err
 	}
+	logStage(e.logger, "kdf", start)
 
-	// Validate file size
-	originalSize := srcInfo.Size()
-	if originalSize < 0 {
-		return fmt.Errorf("invalid file size: %d", originalSize)
+	var escrowed []byte
+	if opts.EscrowKey != nil {
+		if escrowed, err = crypto.EscrowKey(opts.EscrowKey, salt, key); err != nil {
+			return nil, fmt.Errorf("failed to escrow key: %w", err)
+		}
 	}
 
+	headerOpts := append(opts.headerOptions(), e.pepperOptions()...)
+	headerOpts = append(headerOpts, slotOpts...)
+	if opts.rawBody(size) {
+		headerOpts = append(headerOpts, crypto.WithFlags(constants.FlagRawBody))
+	}
+	if unknownSize {
+		headerOpts = append(headerOpts, crypto.WithFlags(constants.FlagSizeFooter))
+	}
+
+	nonceOpts, err := e.nonceOptions(opts)
+	if err != nil {
+		return nil, err
+	}
+	headerOpts = append(headerOpts, nonceOpts...)
+
 	// Create and write header
-	header, err := crypto.NewHeader(salt, uint64(originalSize), key)
+	start = time.Now()
+	header, err := crypto.Build(salt, uint64(max(size, 0)), key, headerOpts...)
 	if err != nil {
-		return fmt.Errorf("failed to create header: %w", err)
+		return nil, fmt.Errorf("failed to create header: %w", err)
+	}
+	nonces := chunkNonces(header, key)
+
+	// Hash the output as it is written so no second pass is needed
+	buffered, flush := bufferedOutput(dst, e.writeBuffer)
+	out := newDigestWriter(buffered)
+
+	if err := header.Write(out); err != nil {
+		return nil, fmt.Errorf("failed to write header: %w", err)
+	}
+	logStage(e.logger, "header write", start)
+
+	// The header records size, so the body must contain exactly that many bytes.
+	// Otherwise what was read is counted for the footer
+	counter := &countingReader{reader: src}
+	if unknownSize {
+		src = deferred.source(counter)
+	} else {
+		src = deferred.source(newSizedReader(src, size))
+	}
+
+	var manifest *ChunkManifest
+	if opts.Manifest {
+		manifest = newManifest(int64(header.Size()))
+	}
+
+	if opts.rawBody(size) {
+		start = time.Now()
+		if err := encryptRawBody(src, out, size, key, opts.Cipher, opts.AAD, nonces); err != nil {
+			return nil, err
+		}
+		logStage(e.logger, "body", start)
+	} else if err := encryptChunks(src, out, size, key, opts.Cipher, opts.Compression, opts.AAD, nonces, opts.Paranoid, e.logger, e.idleTimeout, chainChunks(deferred.onChunk(), manifest.onChunk()), &e.stats); err != nil {
+		return nil, err
+	}
+	if unknownSize {
+		if err := writeSizeFooter(out, key, counter.n); err != nil {
+			return nil, err
+		}
+	}
+	if opts.PadOutputTo > 0 {
+		if err := writeOutputPadding(out, key, out.size, opts.PadOutputTo); err != nil {
+			return nil, err
+		}
+	}
+	// The header is rewritten in place, so everything must have reached dst first
+	if err := flush(); err != nil {
+		return nil, err
 	}
 
-	if err := header.Write(destFile); err != nil {
-		return fmt.Errorf("failed to write header: %w", err)
+	result := out.result()
+	if deferred != nil {
+		if err := deferred.finish(header, key, result); err != nil {
+			return nil, err
+		}
 	}
+	result.Manifest = manifest
+	result.EscrowedKey = escrowed
+	return result, nil
+}
 
+// encryptChunks encrypts size bytes read from src into a stream of framed chunks,
+// logging throughput to logger, giving up after idleTimeout without progress when
+// it is set, passing each chunk to onChunk when it is set and adding the worker
+// pool utilization to stats. Every chunk is compressed in format, bound to aad,
+// sealed under the next counter nonce from nonces or a random one when it is
+// nil, and checked to decode again when paranoid is set. A nil key writes the
+// chunks without encryption
+func encryptChunks(src io.Reader, dst io.Writer, size int64, key []byte, cipher constants.CipherAlgorithm, format constants.CompressionFormat, aad []byte, nonces *crypto.ChunkNonces, paranoid bool, logger *slog.Logger, idleTimeout time.Duration, onChunk func([]byte), stats *streaming.PoolStats) error {
 	// Create stream processor for encryption
 	config := streaming.StreamConfig{
 		Key:         key,
+		Cipher:      cipher,
+		Compression: format,
+		AAD:         aad,
+		Nonces:      nonces,
 		Processing:  constants.Encryption,
 		Concurrency: constants.MaxConcurrency,
 		QueueSize:   constants.QueueSize,
 		ChunkSize:   constants.DefaultChunkSize,
+		Logger:      logger,
+		OnChunk:     onChunk,
+		IdleTimeout: idleTimeout,
+		Unencrypted: key == nil,
+		Paranoid:    paranoid,
 	}
 
 	processor, err := streaming.NewStreamProcessor(config)
 	if err != nil {
 		return fmt.Errorf("failed to create stream processor: %w", err)
 	}
+	if nonces != nil {
+		nonces.Continue()
+	}
+
+	// Process the data
+	defer func() { stats.Add(processor.Stats()) }()
+	return processor.Process(src, dst, size)
+}
 
-	// Process the file
-	return processor.Process(srcFile, destFile, originalSize)
+// EstimateEncryptedSize returns an upper bound on the size of the encrypted file
+// for a source of the given size, including the header and chunk framing
+func EstimateEncryptedSize(sourceSize int64) int64 {
+	return max(sourceSize, 0) + ContainerOverhead(sourceSize, constants.DefaultChunkSize, constants.DataShards, constants.ParityShards)
 }
@@ -0,0 +1,59 @@
+package operations
+
+import (
+	"bytes"
+	"crypto/ecdh"
+	"fmt"
+	"os"
+
+	"github.com/hambosto/hexwarden/internal/constants"
+	"github.com/hambosto/hexwarden/internal/infrastructure/crypto"
+)
+
+// escrowUnlock holds the escrow holder's private key and the escrowed key of
+// the file to unlock with it
+type escrowUnlock struct {
+	private  *ecdh.PrivateKey
+	escrowed []byte
+}
+
+// WriteEscrowedKey writes an escrowed key from EncryptResult.EscrowedKey to path,
+// readable by the owner only. It holds the file key wrapped to the escrow holder,
+// never the key itself
+func WriteEscrowedKey(path string, escrowed []byte) error {
+	if len(escrowed) == 0 {
+		return fmt.Errorf("%w: nothing was escrowed", constants.ErrEscrowKey)
+	}
+	if err := os.WriteFile(path, escrowed, 0o600); err != nil {
+		return fmt.Errorf("%w: %v", constants.ErrFileWriteFailed, err)
+	}
+	return nil
+}
+
+// SetEscrowKey makes decryption unlock files with an escrowed key written through
+// EncryptOptions.EscrowKey, opened with the escrow holder's private key, ignoring
+// the password. Files other than the one the key was escrowed for fail with
+// ErrEscrowMismatch. A nil private key goes back to unlocking with the password
+func (d *Decryptor) SetEscrowKey(private *ecdh.PrivateKey, escrowed []byte) {
+	if private == nil {
+		d.escrow = nil
+		return
+	}
+	d.escrow = &escrowUnlock{private: private, escrowed: bytes.Clone(escrowed)}
+}
+
+// escrowedKey unwraps the key of header from the escrowed key. Like a recovery
+// code, the escrow key is random rather than chosen, so no KDF is run
+func (d *Decryptor) escrowedKey(header *crypto.Header) ([]byte, error) {
+	salt, key, err := crypto.OpenEscrowKey(d.escrow.private, d.escrow.escrowed)
+	if err != nil {
+		return nil, fmt.Errorf("header verification failed: %w", err)
+	}
+	if !bytes.Equal(salt, header.Salt()) {
+		return nil, fmt.Errorf("header verification failed: %w", constants.ErrEscrowMismatch)
+	}
+	if err := header.VerifyKey(key); err != nil {
+		return nil, fmt.Errorf("header verification failed: %w", err)
+	}
+	return key, nil
+}
@@ -0,0 +1,118 @@
+package operations
+
+import (
+	"crypto/rand"
+	"fmt"
+	"runtime"
+	"time"
+
+	"github.com/hambosto/hexwarden/internal/constants"
+	"github.com/hambosto/hexwarden/internal/infrastructure"
+	"github.com/hambosto/hexwarden/internal/infrastructure/crypto"
+)
+
+// Throughput holds the measured costs used to turn an estimate's sizes into time
+type Throughput struct {
+	BytesPerSecond float64       // Pipeline throughput across all workers
+	KDFDuration    time.Duration // Time for one key derivation with the default parameters
+}
+
+// Estimate is a dry-run cost estimate for encrypting a set of files
+type Estimate struct {
+	Files       int
+	InputBytes  int64
+	OutputBytes int64         // Upper bound including headers, chunk framing and Reed-Solomon parity
+	Overhead    int64         // OutputBytes minus InputBytes, from ContainerOverhead
+	KDFRuns     int           // Each file derives its own key from a fresh salt
+	Duration    time.Duration // Rough wall time, from the probed throughput
+}
+
+// EstimateFiles computes the cost of encrypting files of the given sizes. The result
+// depends only on its inputs, so the same sizes and throughput give the same estimate
+func EstimateFiles(sizes []int64, throughput Throughput) Estimate {
+	estimate := Estimate{Files: len(sizes), KDFRuns: len(sizes)}
+	for _, size := range sizes {
+		overhead := ContainerOverhead(size, constants.DefaultChunkSize, constants.DataShards, constants.ParityShards)
+		estimate.InputBytes += size
+		estimate.Overhead += overhead
+		estimate.OutputBytes += size + overhead
+	}
+
+	estimate.Duration = time.Duration(estimate.KDFRuns) * throughput.KDFDuration
+	if throughput.BytesPerSecond > 0 {
+		estimate.Duration += time.Duration(float64(estimate.InputBytes) / throughput.BytesPerSecond * float64(time.Second))
+	}
+	return estimate
+}
+
+// ContainerOverhead returns the bytes encryption adds to plaintextSize bytes:
+// the fixed header, each chunk's size prefix, and per chunk the gzip framing,
+// padding, nonce and tag, and the Reed-Solomon parity and shard rounding.
+// It is exact for the fixed parts; the compression and padding are counted for
+// data that does not compress, so compressible data comes out smaller. Headers
+// with optional fields, such as a comment, are larger. Non-positive chunk or
+// shard counts fall back to the defaults
+func ContainerOverhead(plaintextSize, chunkSize int64, rsDataShards, rsParityShards int) int64 {
+	if chunkSize <= 0 {
+		chunkSize = constants.DefaultChunkSize
+	}
+	if rsDataShards <= 0 {
+		rsDataShards = constants.DataShards
+	}
+	if rsParityShards <= 0 {
+		rsParityShards = constants.ParityShards
+	}
+
+	overhead := int64(constants.TotalHeaderSize)
+	if plaintextSize <= 0 {
+		return overhead // Empty sources are written as the header alone
+	}
+
+	chunkOverhead := func(n int64) int64 {
+		return constants.ChunkHeaderSize + int64(infrastructure.MaxEncryptedSizeWithShards(int(n), rsDataShards, rsParityShards)) - n
+	}
+	overhead += plaintextSize / chunkSize * chunkOverhead(chunkSize)
+	if rest := plaintextSize % chunkSize; rest > 0 {
+		overhead += chunkOverhead(rest)
+	}
+	return overhead
+}
+
+// ProbeThroughput measures one key derivation and one chunk through the pipeline.
+// Chunks are processed in parallel, so the chunk rate is scaled by the worker count
+func ProbeThroughput() (Throughput, error) {
+	salt, err := crypto.GenerateSalt()
+	if err != nil {
+		return Throughput{}, fmt.Errorf("failed to generate salt: %w", err)
+	}
+
+	start := time.Now()
+	key, err := crypto.DeriveKeyWithParams([]byte("hexwarden-estimate-probe"), salt, crypto.DefaultKDFParams())
+	if err != nil {
+		return Throughput{}, fmt.Errorf("failed to derive key: %w", err)
+	}
+	kdfDuration := time.Since(start)
+
+	processor, err := infrastructure.NewProcessor(key)
+	if err != nil {
+		return Throughput{}, fmt.Errorf("failed to create processor: %w", err)
+	}
+
+	// Random data does not compress, matching the worst case the size bound assumes
+	chunk := make([]byte, constants.DefaultChunkSize)
+	if _, err := rand.Read(chunk); err != nil {
+		return Throughput{}, fmt.Errorf("failed to generate probe data: %w", err)
+	}
+
+	start = time.Now()
+	if _, err := processor.Encrypt(chunk); err != nil {
+		return Throughput{}, fmt.Errorf("probe encryption failed: %w", err)
+	}
+	elapsed := time.Since(start).Seconds()
+
+	workers := min(runtime.NumCPU(), constants.MaxConcurrency)
+	return Throughput{
+		BytesPerSecond: float64(len(chunk)) / elapsed * float64(workers),
+		KDFDuration:    kdfDuration,
+	}, nil
+}
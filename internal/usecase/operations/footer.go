@@ -0,0 +1,92 @@
+package operations
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/hambosto/hexwarden/internal/constants"
+	"github.com/hambosto/hexwarden/internal/infrastructure/crypto"
+)
+
+// countingReader counts the bytes read through it
+type countingReader struct {
+	reader io.Reader
+	n      uint64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.reader.Read(p)
+	c.n += uint64(n)
+	return n, err
+}
+
+// bodySize returns the plaintext size of the chunks following header, or
+// UnknownSize when they end in a size footer instead
+func bodySize(header *crypto.Header) int64 {
+	if header.Flags()&constants.FlagSizeFooter != 0 {
+		return constants.UnknownSize
+	}
+	return int64(header.OriginalSize())
+}
+
+// writeSizeFooter ends the chunks written to dst with the footer marker and
+// size sealed under key
+func writeSizeFooter(dst io.Writer, key []byte, size uint64) error {
+	sealed, err := crypto.SealFooter(key, size)
+	if err != nil {
+		return fmt.Errorf("failed to seal size footer: %w", err)
+	}
+
+	footer := binary.BigEndian.AppendUint32(make([]byte, 0, constants.ChunkHeaderSize+len(sealed)), constants.ChunkFooterMarker)
+	if _, err := dst.Write(append(footer, sealed...)); err != nil {
+		return fmt.Errorf("%w: %v", constants.ErrFileWriteFailed, err)
+	}
+	return nil
+}
+
+// checkSizeFooter reads the sealed footer left in src after the footer marker
+// and checks it records written bytes of plaintext. A footer that is cut off,
+// forged or followed by more data fails
+func checkSizeFooter(src io.Reader, key []byte, written int64) error {
+	sealed := make([]byte, constants.SizeFooterSize)
+	if _, err := io.ReadFull(src, sealed); errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return fmt.Errorf("%w: footer is cut off", constants.ErrMissingFooter)
+	} else if err != nil {
+		return fmt.Errorf("failed to read size footer: %w", err)
+	}
+
+	size, err := crypto.OpenFooter(key, sealed)
+	if err != nil {
+		return err
+	}
+	if uint64(written) != size {
+		return fmt.Errorf("%w: decrypted %d of %d bytes", constants.ErrFooterMismatch, written, size)
+	}
+
+	var extra [1]byte
+	if n, _ := src.Read(extra[:]); n > 0 {
+		return fmt.Errorf("%w: data follows the footer", constants.ErrFooterMismatch)
+	}
+	return nil
+}
+
+// footerSize returns the plaintext size recorded in the footer at the end of
+// srcFile, whose header says the body ends in one
+func footerSize(srcFile *os.File, key []byte) (uint64, error) {
+	info, err := srcFile.Stat()
+	if err != nil {
+		return 0, fmt.Errorf("failed to stat source file: %w", err)
+	}
+	if info.Size() < constants.SizeFooterSize {
+		return 0, constants.ErrMissingFooter
+	}
+
+	sealed := make([]byte, constants.SizeFooterSize)
+	if _, err := srcFile.ReadAt(sealed, info.Size()-constants.SizeFooterSize); err != nil {
+		return 0, fmt.Errorf("failed to read size footer: %w", err)
+	}
+	return crypto.OpenFooter(key, sealed)
+}
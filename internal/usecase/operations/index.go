@@ -0,0 +1,184 @@
+package operations
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/hambosto/hexwarden/internal/constants"
+	"github.com/hambosto/hexwarden/internal/infrastructure/utils"
+)
+
+// indexVersion is the layout of the JSON inside an encrypted tree index
+const indexVersion = 1
+
+// opaqueNameSize is the number of random bytes in an opaque output name
+const opaqueNameSize = 16
+
+// IndexEntry maps one opaquely named encrypted file to its place in the tree
+type IndexEntry struct {
+	Name string `json:"name"` // Base name of the encrypted file, stored next to the index
+	Path string `json:"path"` // Original path relative to the tree root, with forward slashes
+}
+
+// TreeIndex lists the files of a directory tree encrypted under opaque names,
+// so the tree can be rebuilt without its structure being visible on disk
+type TreeIndex struct {
+	Version int          `json:"version"`
+	Entries []IndexEntry `json:"entries"`
+}
+
+// NewTreeIndex returns an empty index
+func NewTreeIndex() *TreeIndex {
+	return &TreeIndex{Version: indexVersion}
+}
+
+// OpaqueName returns a random file name for an encrypted file that reveals
+// nothing about its source
+func OpaqueName() (string, error) {
+	var b [opaqueNameSize]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return "", fmt.Errorf("failed to generate name: %w", err)
+	}
+	return hex.EncodeToString(b[:]) + constants.FileExtension, nil
+}
+
+// Lookup returns the entry for the relative path rel
+func (x *TreeIndex) Lookup(rel string) (IndexEntry, bool) {
+	i := slices.IndexFunc(x.Entries, func(e IndexEntry) bool { return e.Path == rel })
+	if i < 0 {
+		return IndexEntry{}, false
+	}
+	return x.Entries[i], true
+}
+
+// Add records that the file at the relative path rel was encrypted to name
+func (x *TreeIndex) Add(name, rel string) error {
+	entry := IndexEntry{Name: name, Path: rel}
+	if err := entry.validate(); err != nil {
+		return err
+	}
+	for _, e := range x.Entries {
+		if e.Path == rel || e.Name == name {
+			return fmt.Errorf("%w: %s is listed twice", constants.ErrInvalidIndex, rel)
+		}
+	}
+	x.Entries = append(x.Entries, entry)
+	return nil
+}
+
+// Remove drops the entry for the encrypted file name
+func (x *TreeIndex) Remove(name string) {
+	x.Entries = slices.DeleteFunc(x.Entries, func(e IndexEntry) bool { return e.Name == name })
+}
+
+// Validate checks every entry names a plain file beside the index and a path
+// that stays inside the tree, and that nothing is listed twice
+func (x *TreeIndex) Validate() error {
+	if x.Version != indexVersion {
+		return fmt.Errorf("%w: unsupported version %d", constants.ErrInvalidIndex, x.Version)
+	}
+
+	names := make(map[string]bool, len(x.Entries))
+	paths := make(map[string]bool, len(x.Entries))
+	for _, e := range x.Entries {
+		if err := e.validate(); err != nil {
+			return err
+		}
+		if names[e.Name] || paths[e.Path] {
+			return fmt.Errorf("%w: %s is listed twice", constants.ErrInvalidIndex, e.Path)
+		}
+		names[e.Name], paths[e.Path] = true, true
+	}
+	return nil
+}
+
+// validate checks a single entry
+func (e IndexEntry) validate() error {
+	if err := utils.ValidateName(e.Name); err != nil {
+		return fmt.Errorf("%w: %w", constants.ErrInvalidIndex, err)
+	}
+	if !strings.HasSuffix(e.Name, constants.FileExtension) || e.Name == constants.IndexFileName {
+		return fmt.Errorf("%w: %q is not an encrypted file name", constants.ErrInvalidIndex, e.Name)
+	}
+	// Checking each element keeps the path inside the tree on every platform
+	for _, element := range strings.Split(e.Path, "/") {
+		if err := utils.ValidateName(element); err != nil {
+			return fmt.Errorf("%w: path %q: %w", constants.ErrInvalidIndex, e.Path, err)
+		}
+	}
+	return nil
+}
+
+// LocalPath returns where the entry belongs below root
+func (e IndexEntry) LocalPath(root string) string {
+	return filepath.Join(root, filepath.FromSlash(e.Path))
+}
+
+// WriteIndex encrypts index to indexPath with password. Only the options that
+// concern the whole file apply; the per-file comment, name, thumbnail and
+// digests are left out. The index is replaced atomically, so a failure keeps
+// the previous one
+func (e *Encryptor) WriteIndex(indexPath string, index *TreeIndex, password string, opts EncryptOptions) error {
+	if err := index.Validate(); err != nil {
+		return err
+	}
+	data, err := json.Marshal(index)
+	if err != nil {
+		return fmt.Errorf("failed to encode index: %w", err)
+	}
+
+	opts.Comment, opts.Filename, opts.Thumbnail = "", "", nil
+	opts.Merkle, opts.HashOriginal, opts.Manifest = false, false, false
+
+	tmpFile, err := e.fileManager.CreateTemp(indexPath)
+	if err != nil {
+		return err
+	}
+	tmpPath := tmpFile.Name()
+
+	if _, err := e.EncryptStream(bytes.NewReader(data), tmpFile, int64(len(data)), password, opts); err != nil {
+		_ = tmpFile.Close()
+		e.fileManager.DiscardTemp(tmpPath)
+		return fmt.Errorf("failed to encrypt index: %w", err)
+	}
+	if err := tmpFile.Sync(); err != nil {
+		_ = tmpFile.Close()
+		e.fileManager.DiscardTemp(tmpPath)
+		return fmt.Errorf("failed to sync index: %w", err)
+	}
+	if err := tmpFile.Close(); err != nil {
+		e.fileManager.DiscardTemp(tmpPath)
+		return fmt.Errorf("failed to write temporary file: %w", err)
+	}
+	return e.fileManager.CommitTemp(tmpPath, indexPath)
+}
+
+// ReadIndex decrypts and validates the index at indexPath. Decryption
+// authenticates it, so a tampered index is refused rather than misread
+func (d *Decryptor) ReadIndex(indexPath, password string) (*TreeIndex, error) {
+	srcFile, _, err := d.fileManager.OpenFile(indexPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open index: %w", err)
+	}
+	defer srcFile.Close() //nolint:errcheck
+
+	var buf bytes.Buffer
+	if _, err := d.DecryptStream(srcFile, &buf, password); err != nil {
+		return nil, fmt.Errorf("failed to decrypt index: %w", err)
+	}
+
+	index := &TreeIndex{}
+	if err := json.Unmarshal(buf.Bytes(), index); err != nil {
+		return nil, fmt.Errorf("%w: %v", constants.ErrInvalidIndex, err)
+	}
+	if err := index.Validate(); err != nil {
+		return nil, err
+	}
+	return index, nil
+}
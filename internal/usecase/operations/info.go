@@ -0,0 +1,147 @@
+package operations
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/hambosto/hexwarden/internal/constants"
+	"github.com/hambosto/hexwarden/internal/data/streaming"
+	"github.com/hambosto/hexwarden/internal/infrastructure/crypto"
+)
+
+// HeaderInfo describes an encrypted file. Confidential fields are only filled
+// in when the header was unlocked with the correct password
+type HeaderInfo struct {
+	Version      uint8
+	OriginalSize uint64
+	KDFParams    crypto.KDFParams
+	HeaderHash   constants.HashAlgorithm
+	Cipher       constants.CipherAlgorithm
+	Compression  constants.CompressionFormat
+	Nonce        constants.NonceStrategy // How the header nonce was generated
+	NonceCounter uint64                  // Counter the header nonce was built from, for the counter strategy
+	Hint         string
+	HasFilename  bool
+	HasComment   bool
+	HasThumbnail bool
+	RawBody      bool   // Body skipped compression and Reed-Solomon
+	Bundle       bool   // Body holds several files, listed in Members once unlocked
+	Unencrypted  bool   // Body written by protect, with Reed-Solomon but no encryption
+	Log          bool   // Body is an append-only log of independently sealed records
+	Peppered     bool   // Key derivation needs the pepper from HEXWARDEN_PEPPER
+	Reference    bool   // Body names another encrypted file holding the same plaintext
+	Recoverable  bool   // File key is also wrapped under a recovery code
+	KeySlots     int    // Extra passwords the file key is wrapped under
+	SizeFooter   bool   // Original size is sealed in a footer after the chunks, read by Inspect once unlocked
+	PaddedSize   uint64 // Size the file was filled up to with filler after the chunks; 0 when it was not padded
+	Records      uint64 // Records in an append-only log, counted by Inspect
+	Unlocked     bool
+	Filename     string
+	Comment      string
+	Thumbnail    []byte // JPEG preview
+	Members      []BundleMember
+	MerkleRoot   []byte           // Root of the Merkle tree over the chunks; authenticated only once unlocked
+	HasOriginal  bool             // The SHA-256 of the original file is stored
+	Original     []byte           // SHA-256 of the original file
+	NotBefore    time.Time        // Advisory time lock; zero when the file is not time-locked
+	AAD          bool             // Body is bound to associated data that decryption must supply
+	Holes        []streaming.Hole // Corrupt chunks written as zeros when ignoring corrupt chunks
+}
+
+// Inspect reads the header of an encrypted file. Without a password or recovery
+// code only the public fields are returned; with one the header is verified and unlocked
+func (d *Decryptor) Inspect(srcPath, password string) (*HeaderInfo, error) {
+	srcFile, _, err := d.fileManager.OpenFile(srcPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open source file: %w", err)
+	}
+	defer srcFile.Close() //nolint:errcheck
+
+	var header *crypto.Header
+	var key []byte
+	if password == "" && d.recoveryCode == "" && d.escrow == nil {
+		header, err = crypto.ReadHeader(srcFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read header: %w", err)
+		}
+	} else if header, key, err = d.readHeader(srcFile, password); err != nil {
+		return nil, err
+	}
+
+	info, err := newHeaderInfo(header, key)
+	if err != nil {
+		return nil, err
+	}
+	// Record lengths are not secret, so a log can be counted while locked
+	if info.Log {
+		if info.Records, err = forEachRecord(srcFile, nil); err != nil {
+			return nil, err
+		}
+	}
+	if info.Bundle {
+		if info.Members, err = readBundleIndex(srcFile, header, key); err != nil {
+			return nil, err
+		}
+	}
+	if info.SizeFooter && key != nil {
+		if info.OriginalSize, err = footerSize(srcFile, key); err != nil {
+			return nil, err
+		}
+	}
+	return info, nil
+}
+
+// newHeaderInfo collects the header fields, decrypting confidential ones when key is set
+func newHeaderInfo(header *crypto.Header, key []byte) (*HeaderInfo, error) {
+	info := &HeaderInfo{
+		Version:      header.Version(),
+		OriginalSize: header.OriginalSize(),
+		KDFParams:    header.KDFParams(),
+		HeaderHash:   header.HashAlgorithm(),
+		Cipher:       header.Cipher(),
+		Compression:  header.Compression(),
+		Nonce:        header.NonceStrategy(),
+		Hint:         header.Hint(),
+		HasFilename:  header.HasFilename(),
+		HasComment:   header.HasComment(),
+		HasThumbnail: header.HasThumbnail(),
+		RawBody:      header.Flags()&constants.FlagRawBody != 0,
+		Bundle:       header.Flags()&constants.FlagBundle != 0,
+		Unencrypted:  header.Flags()&constants.FlagUnencrypted != 0,
+		Log:          header.Flags()&constants.FlagLog != 0,
+		Peppered:     header.Flags()&constants.FlagPeppered != 0,
+		Reference:    header.Flags()&constants.FlagReference != 0,
+		Recoverable:  header.HasRecoveryKey(),
+		KeySlots:     header.KeySlots(),
+		SizeFooter:   header.Flags()&constants.FlagSizeFooter != 0,
+		Unlocked:     key != nil,
+		MerkleRoot:   header.MerkleRoot(),
+		HasOriginal:  header.HasOriginalSHA256(),
+		NotBefore:    header.NotBefore(),
+		AAD:          header.HasAAD(),
+	}
+	if _, counter, ok := header.NonceCounter(); ok {
+		info.NonceCounter = counter
+	}
+	if size, ok := header.PaddedSize(); ok {
+		info.PaddedSize = size
+	}
+	if key == nil {
+		return info, nil
+	}
+
+	var err error
+	if info.Filename, err = header.Filename(key); err != nil {
+		return nil, err
+	}
+	if info.Comment, err = header.Comment(key); err != nil {
+		return nil, err
+	}
+	if info.Thumbnail, err = header.Thumbnail(key); err != nil {
+		return nil, err
+	}
+	if info.Original, err = header.OriginalSHA256(key); err != nil {
+		return nil, err
+	}
+	return info, nil
+}
@@ -0,0 +1,120 @@
+package operations
+
+import (
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/hambosto/hexwarden/internal/constants"
+	"github.com/hambosto/hexwarden/internal/infrastructure/crypto"
+)
+
+// KDFGrid lists the Argon2id settings a KDF benchmark tries
+type KDFGrid struct {
+	Memory  []uint32 // Memory costs in KiB
+	Time    []uint32 // Numbers of passes
+	Threads []uint8  // Degrees of parallelism
+}
+
+// DefaultKDFGrid returns memory from 32 MiB to maxMemory KiB in doubling steps,
+// one to four passes, and one to four threads. Memory stops at the most a header may record
+func DefaultKDFGrid(maxMemory uint32) KDFGrid {
+	maxMemory = min(maxMemory, constants.MaxArgonMemory)
+	grid := KDFGrid{
+		Time:    []uint32{1, 2, 3, 4},
+		Threads: []uint8{1, 2, 4},
+	}
+	for memory := uint32(32 * 1024); memory <= maxMemory; memory *= 2 {
+		grid.Memory = append(grid.Memory, memory)
+	}
+	return grid
+}
+
+// KDFTimer measures one key derivation with the given parameters
+type KDFTimer func(params crypto.KDFParams) (time.Duration, error)
+
+// KDFBenchResult is the time one parameter set took to derive a key
+type KDFBenchResult struct {
+	Params   crypto.KDFParams
+	Duration time.Duration
+}
+
+// TimeKDF derives a key with params from a fresh salt and returns how long it took
+func TimeKDF(params crypto.KDFParams) (time.Duration, error) {
+	salt, err := crypto.GenerateSalt()
+	if err != nil {
+		return 0, fmt.Errorf("failed to generate salt: %w", err)
+	}
+
+	start := time.Now()
+	if _, err := crypto.DeriveKeyWithParams([]byte("hexwarden-kdf-bench"), salt, params); err != nil {
+		return 0, fmt.Errorf("failed to derive key: %w", err)
+	}
+	return time.Since(start), nil
+}
+
+// BenchmarkKDF times each valid parameter set in grid with timer, passing each
+// result to onResult when it is set. More memory or passes never make derivation
+// faster, so settings costlier than one already over target are not tried
+func BenchmarkKDF(grid KDFGrid, target time.Duration, timer KDFTimer, onResult func(KDFBenchResult)) ([]KDFBenchResult, error) {
+	memories := slices.Sorted(slices.Values(grid.Memory))
+	passes := slices.Sorted(slices.Values(grid.Time))
+
+	var results []KDFBenchResult
+	for _, threads := range grid.Threads {
+	memoryLoop:
+		for _, memory := range memories {
+			for i, pass := range passes {
+				params := crypto.KDFParams{Time: pass, Memory: memory, Threads: threads}
+				if params.Validate() != nil {
+					continue
+				}
+
+				duration, err := timer(params)
+				if err != nil {
+					return nil, err
+				}
+				result := KDFBenchResult{Params: params, Duration: duration}
+				results = append(results, result)
+				if onResult != nil {
+					onResult(result)
+				}
+
+				if duration > target {
+					if i == 0 {
+						break memoryLoop
+					}
+					break
+				}
+			}
+		}
+	}
+	return results, nil
+}
+
+// RecommendKDF picks the result with the highest cost, memory times passes, that
+// stayed within target. Ties go to the faster result
+func RecommendKDF(results []KDFBenchResult, target time.Duration) (KDFBenchResult, error) {
+	cost := func(p crypto.KDFParams) uint64 {
+		return uint64(p.Memory) * uint64(p.Time)
+	}
+
+	var (
+		best  KDFBenchResult
+		found bool
+	)
+	for _, result := range results {
+		if result.Duration > target {
+			continue
+		}
+		if !found || cost(result.Params) > cost(best.Params) ||
+			(cost(result.Params) == cost(best.Params) && result.Duration < best.Duration) {
+			best, found = result, true
+		}
+	}
+
+	if !found {
+		return KDFBenchResult{}, fmt.Errorf("%w: nothing measured finished within %s", constants.ErrNoKDFParams, target)
+	}
+	return best, nil
+}
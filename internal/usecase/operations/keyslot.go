@@ -0,0 +1,97 @@
+package operations
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/hambosto/hexwarden/internal/constants"
+	"github.com/hambosto/hexwarden/internal/infrastructure/crypto"
+)
+
+// keySlotOptions derives a key for every extra password, each with a salt of
+// its own and the file's KDF parameters, and wraps the file key under them
+func (e *Encryptor) keySlotOptions(opts EncryptOptions) ([]crypto.HeaderOption, error) {
+	headerOpts := make([]crypto.HeaderOption, 0, len(opts.ExtraPasswords))
+	for _, password := range opts.ExtraPasswords {
+		salt, err := opts.generateSalt()
+		if err != nil {
+			return nil, fmt.Errorf("failed to generate salt: %w", err)
+		}
+		kek, err := e.deriveKey(e.secret(password), salt, opts.kdfParams())
+		if err != nil {
+			return nil, fmt.Errorf("failed to derive key: %w", err)
+		}
+		headerOpts = append(headerOpts, crypto.WithKeySlot(salt, kek))
+	}
+	return headerOpts, nil
+}
+
+// slotKey tries secret against every key slot of header, returning the file key
+// from the first one it opens. Each slot has its own salt, so each costs a key
+// derivation
+func (d *Decryptor) slotKey(header *crypto.Header, secret []byte) ([]byte, int, error) {
+	for i := range header.KeySlots() {
+		kek, err := d.deriveKey(secret, header.KeySlotSalt(i), header.KDFParams())
+		if err != nil {
+			return nil, 0, fmt.Errorf("failed to derive key: %w", err)
+		}
+		key, err := header.OpenKeySlot(i, kek)
+		if err == nil {
+			return key, i, nil
+		}
+		if !errors.Is(err, constants.ErrAuthFailure) {
+			return nil, 0, err
+		}
+	}
+	return nil, 0, constants.ErrNoKeySlot
+}
+
+// AddKey lets newPassword unlock the encrypted file at path as well, by wrapping
+// its key in a new key slot. password may be the one the file was encrypted with
+// or any other that opens it. Only the header is resealed; the body is copied unchanged
+func (d *Decryptor) AddKey(path, password, newPassword string) error {
+	if newPassword == "" {
+		return fmt.Errorf("%w: new password cannot be empty", constants.ErrInvalidOption)
+	}
+	return d.rewriteHeader(path, password, func(header *crypto.Header, key []byte) error {
+		secret, err := headerSecret(header, newPassword, d.pepper)
+		if err != nil {
+			return err
+		}
+		salt, err := crypto.GenerateSalt()
+		if err != nil {
+			return fmt.Errorf("failed to generate salt: %w", err)
+		}
+		kek, err := d.deriveKey(secret, salt, header.KDFParams())
+		if err != nil {
+			return fmt.Errorf("failed to derive key: %w", err)
+		}
+		return header.AddKeySlot(key, salt, kek)
+	})
+}
+
+// RemoveKey drops the key slot removePassword opens from the encrypted file at
+// path, after unlocking it with password. The password the file was encrypted
+// with has no slot and fails with ErrPrimaryKey; one that opens no slot fails
+// with ErrNoKeySlot
+func (d *Decryptor) RemoveKey(path, password, removePassword string) error {
+	return d.rewriteHeader(path, password, func(header *crypto.Header, key []byte) error {
+		secret, err := headerSecret(header, removePassword, d.pepper)
+		if err != nil {
+			return err
+		}
+		primary, err := d.deriveKey(secret, header.Salt(), header.KDFParams())
+		if err != nil {
+			return fmt.Errorf("failed to derive key: %w", err)
+		}
+		if header.VerifyKey(primary) == nil {
+			return constants.ErrPrimaryKey
+		}
+
+		_, slot, err := d.slotKey(header, secret)
+		if err != nil {
+			return err
+		}
+		return header.RemoveKeySlot(key, slot)
+	})
+}
@@ -0,0 +1,213 @@
+package operations
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/hambosto/hexwarden/internal/constants"
+	"github.com/hambosto/hexwarden/internal/infrastructure/crypto"
+)
+
+// logRecordOverhead is the salt and AES-GCM tag added to every log record
+const logRecordOverhead = constants.LogRecordSaltSize + 16
+
+// AppendRecord seals record and appends it to the append-only log at path,
+// creating the log with opts when it does not exist yet. Every record is framed
+// and authenticated on its own, so appending never rewrites the header or the
+// records already in the log. It returns the position of the record, starting at 0
+func (e *Encryptor) AppendRecord(path, password string, record []byte, opts EncryptOptions) (uint64, error) {
+	if len(record) == 0 || len(record) > constants.MaxLogRecordSize {
+		return 0, fmt.Errorf("%w: record must hold 1 to %d bytes, got %d", constants.ErrInvalidLog, constants.MaxLogRecordSize, len(record))
+	}
+	if opts.Merkle || opts.HashOriginal || opts.Manifest {
+		return 0, fmt.Errorf("%w: a log cannot store a Merkle root, original hash or chunk manifest", constants.ErrInvalidLog)
+	}
+	if opts.PadOutputTo > 0 {
+		return 0, fmt.Errorf("%w: a log grows with every record and cannot be padded to a fixed size", constants.ErrInvalidLog)
+	}
+	if opts.Cipher != constants.CipherAESGCM {
+		return 0, fmt.Errorf("%w: log records are always sealed with AES-256-GCM", constants.ErrInvalidLog)
+	}
+	if len(opts.AAD) > 0 {
+		return 0, fmt.Errorf("%w: log records cannot be bound to associated data", constants.ErrInvalidLog)
+	}
+	if opts.Compression != constants.CompressionGzip {
+		return 0, fmt.Errorf("%w: log records are not compressed", constants.ErrInvalidLog)
+	}
+
+	lock, err := e.fileManager.LockNewFile(path)
+	if err == nil {
+		defer lock.Unlock() //nolint:errcheck
+		if err := e.createLog(path, password, record, opts); err != nil {
+			_ = os.Remove(filepath.Clean(path))
+			return 0, err
+		}
+		return 0, nil
+	}
+	if !errors.Is(err, constants.ErrFileExists) {
+		return 0, err
+	}
+
+	if lock, err = e.fileManager.LockFile(path); err != nil {
+		return 0, err
+	}
+	defer lock.Unlock() //nolint:errcheck
+	return e.appendToLog(path, password, record)
+}
+
+// createLog writes the header of a new log followed by its first record
+func (e *Encryptor) createLog(path, password string, record []byte, opts EncryptOptions) error {
+	if err := opts.validateFor(password); err != nil {
+		return err
+	}
+
+	salt, err := opts.generateSalt()
+	if err != nil {
+		return fmt.Errorf("failed to generate salt: %w", err)
+	}
+
+	key, err := e.deriveKey(e.secret(password), salt, opts.kdfParams())
+	if err != nil {
+		return fmt.Errorf("failed to derive key: %w", err)
+	}
+
+	headerOpts := append(opts.headerOptions(), crypto.WithFlags(constants.FlagLog))
+	headerOpts = append(headerOpts, e.pepperOptions()...)
+	nonceOpts, err := e.nonceOptions(opts)
+	if err != nil {
+		return err
+	}
+	headerOpts = append(headerOpts, nonceOpts...)
+	header, err := crypto.Build(salt, 0, key, headerOpts...)
+	if err != nil {
+		return fmt.Errorf("failed to create header: %w", err)
+	}
+
+	file, err := os.OpenFile(filepath.Clean(path), os.O_WRONLY|os.O_TRUNC, 0o600)
+	if err != nil {
+		return fmt.Errorf("%w: %v", constants.ErrFileCreateFailed, err)
+	}
+	defer file.Close() //nolint:errcheck
+
+	if err := header.Write(file); err != nil {
+		return fmt.Errorf("failed to write header: %w", err)
+	}
+	if err := writeRecord(file, key, 0, record); err != nil {
+		return err
+	}
+	return syncAndClose(file)
+}
+
+// appendToLog unlocks an existing log and adds record after its last record
+func (e *Encryptor) appendToLog(path, password string, record []byte) (uint64, error) {
+	file, err := os.OpenFile(filepath.Clean(path), os.O_RDWR|os.O_APPEND, 0)
+	if err != nil {
+		return 0, fmt.Errorf("%w: %v", constants.ErrFileOpenFailed, err)
+	}
+	defer file.Close() //nolint:errcheck
+
+	header, err := crypto.ReadHeader(file)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read header: %w", err)
+	}
+	if header.Flags()&constants.FlagLog == 0 {
+		return 0, fmt.Errorf("%w: %s", constants.ErrNotLog, path)
+	}
+
+	secret, err := headerSecret(header, password, e.pepper)
+	if err != nil {
+		return 0, err
+	}
+	key, err := e.deriveKey(secret, header.Salt(), header.KDFParams())
+	if err != nil {
+		return 0, fmt.Errorf("failed to derive key: %w", err)
+	}
+	if err := header.VerifyKey(key); err != nil {
+		return 0, fmt.Errorf("header verification failed: %w", err)
+	}
+
+	// Records are bound to their position, so the next index is the record count
+	count, err := forEachRecord(file, nil)
+	if err != nil {
+		return 0, err
+	}
+
+	if err := writeRecord(file, key, count, record); err != nil {
+		return 0, err
+	}
+	return count, syncAndClose(file)
+}
+
+// writeRecord seals record at position index and writes it with its length prefix
+func writeRecord(dst io.Writer, key []byte, index uint64, record []byte) error {
+	sealed, err := crypto.SealRecord(key, index, record)
+	if err != nil {
+		return fmt.Errorf("failed to seal record: %w", err)
+	}
+
+	frame := binary.BigEndian.AppendUint32(make([]byte, 0, constants.LogRecordHeaderSize+len(sealed)), uint32(len(sealed)))
+	if _, err := dst.Write(append(frame, sealed...)); err != nil {
+		return fmt.Errorf("%w: %v", constants.ErrFileWriteFailed, err)
+	}
+	return nil
+}
+
+// decryptLog opens the records of a log in order and writes them to dst back to back
+func decryptLog(src io.Reader, dst io.Writer, key []byte) (uint64, error) {
+	return forEachRecord(src, func(index uint64, sealed []byte) error {
+		record, err := crypto.OpenRecord(key, index, sealed)
+		if err != nil {
+			return err
+		}
+		if _, err := dst.Write(record); err != nil {
+			return fmt.Errorf("%w: %v", constants.ErrFileWriteFailed, err)
+		}
+		return nil
+	})
+}
+
+// forEachRecord reads the framed records of a log body until it ends, passing
+// each to fn unless it is nil, and returns how many there were. A body cut off
+// inside a record fails with ErrInvalidLog rather than dropping the partial record
+func forEachRecord(src io.Reader, fn func(index uint64, sealed []byte) error) (uint64, error) {
+	prefix := make([]byte, constants.LogRecordHeaderSize)
+	for index := uint64(0); ; index++ {
+		if _, err := io.ReadFull(src, prefix); err == io.EOF {
+			return index, nil
+		} else if errors.Is(err, io.ErrUnexpectedEOF) {
+			return index, fmt.Errorf("%w: truncated length of record %d", constants.ErrInvalidLog, index)
+		} else if err != nil {
+			return index, fmt.Errorf("failed to read record %d: %w", index, err)
+		}
+
+		length := binary.BigEndian.Uint32(prefix)
+		if length <= logRecordOverhead || length > constants.MaxLogRecordSize+logRecordOverhead {
+			return index, fmt.Errorf("%w: record %d has invalid length %d", constants.ErrInvalidLog, index, length)
+		}
+
+		sealed := make([]byte, length)
+		if _, err := io.ReadFull(src, sealed); errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			return index, fmt.Errorf("%w: record %d is truncated", constants.ErrInvalidLog, index)
+		} else if err != nil {
+			return index, fmt.Errorf("failed to read record %d: %w", index, err)
+		}
+
+		if fn != nil {
+			if err := fn(index, sealed); err != nil {
+				return index, err
+			}
+		}
+	}
+}
+
+// syncAndClose flushes file to stable storage before closing it
+func syncAndClose(file *os.File) error {
+	if err := file.Sync(); err != nil {
+		return fmt.Errorf("failed to sync: %w", err)
+	}
+	return file.Close()
+}
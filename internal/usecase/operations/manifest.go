@@ -0,0 +1,206 @@
+package operations
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"math"
+	"os"
+
+	"github.com/hambosto/hexwarden/internal/constants"
+)
+
+// manifestVersion is the layout of a chunk manifest sidecar
+const manifestVersion = 1
+
+// ManifestChunk locates one chunk of an encrypted file and records its checksums
+type ManifestChunk struct {
+	Index  int    `json:"index"`  // Position of the chunk in the file, starting at 0
+	Offset int64  `json:"offset"` // Byte offset of the chunk data from the start of the file, after its size prefix
+	Length int64  `json:"length"` // Bytes of chunk data
+	CRC32  string `json:"crc32"`  // Hex CRC-32 (IEEE) of the chunk data
+	SHA256 string `json:"sha256"` // Hex SHA-256 of the chunk data
+}
+
+// ChunkManifest lists every chunk of an encrypted file, so external repair
+// tools can find and fetch only the damaged ones. It covers the stored bytes,
+// not the plaintext, and can be checked without the password
+type ChunkManifest struct {
+	Version int             `json:"version"`
+	Body    int64           `json:"body_offset"` // Offset of the first chunk's size prefix, the header size
+	Size    int64           `json:"size"`        // Size of the whole encrypted file
+	Chunks  []ManifestChunk `json:"chunks"`
+}
+
+// newManifest returns an empty manifest for a body starting at body
+func newManifest(body int64) *ChunkManifest {
+	return &ChunkManifest{Version: manifestVersion, Body: body, Size: body}
+}
+
+// add records the chunk written after the ones already listed
+func (m *ChunkManifest) add(chunk []byte) {
+	sum := sha256.Sum256(chunk)
+	m.Chunks = append(m.Chunks, ManifestChunk{
+		Index:  len(m.Chunks),
+		Offset: m.Size + constants.ChunkHeaderSize,
+		Length: int64(len(chunk)),
+		CRC32:  fmt.Sprintf("%08x", crc32.ChecksumIEEE(chunk)),
+		SHA256: hex.EncodeToString(sum[:]),
+	})
+	m.Size += constants.ChunkHeaderSize + int64(len(chunk))
+}
+
+// onChunk returns the chunk callback recording into m, nil when m is nil
+func (m *ChunkManifest) onChunk() func([]byte) {
+	if m == nil {
+		return nil
+	}
+	return m.add
+}
+
+// Validate checks the chunks follow each other from the body offset to the
+// end of the file with well-formed checksums
+func (m *ChunkManifest) Validate() error {
+	if m.Version != manifestVersion {
+		return fmt.Errorf("%w: unsupported version %d", constants.ErrInvalidManifest, m.Version)
+	}
+	if m.Body < 0 {
+		return fmt.Errorf("%w: negative body offset", constants.ErrInvalidManifest)
+	}
+
+	end := m.Body
+	for i, c := range m.Chunks {
+		if c.Index != i || c.Offset != end+constants.ChunkHeaderSize {
+			return fmt.Errorf("%w: chunk %d is out of place", constants.ErrInvalidManifest, i)
+		}
+		if c.Length <= 0 || c.Length > math.MaxInt32 {
+			return fmt.Errorf("%w: chunk %d has length %d", constants.ErrInvalidManifest, i, c.Length)
+		}
+		if crc, err := hex.DecodeString(c.CRC32); err != nil || len(crc) != crc32.Size {
+			return fmt.Errorf("%w: chunk %d has an invalid CRC-32", constants.ErrInvalidManifest, i)
+		}
+		if sum, err := hex.DecodeString(c.SHA256); err != nil || len(sum) != sha256.Size {
+			return fmt.Errorf("%w: chunk %d has an invalid SHA-256", constants.ErrInvalidManifest, i)
+		}
+		end = c.Offset + c.Length
+	}
+	if m.Size != end {
+		return fmt.Errorf("%w: chunks end at %d, not at the file size %d", constants.ErrInvalidManifest, end, m.Size)
+	}
+	return nil
+}
+
+// chainChunks returns a callback passing each chunk to first and then second,
+// either of which may be nil
+func chainChunks(first, second func([]byte)) func([]byte) {
+	if first == nil {
+		return second
+	}
+	if second == nil {
+		return first
+	}
+	return func(chunk []byte) {
+		first(chunk)
+		second(chunk)
+	}
+}
+
+// VerifyManifest checks every chunk of the file at srcPath against manifest,
+// reading each at the offset the manifest gives so damage to one chunk does not
+// hide the others. No password is needed. Chunks that differ are reported as
+// problems and the returned error wraps ErrManifestMismatch
+func (d *Decryptor) VerifyManifest(srcPath string, manifest *ChunkManifest) (*ScanReport, error) {
+	if err := manifest.Validate(); err != nil {
+		return nil, err
+	}
+
+	srcFile, srcInfo, err := d.fileManager.OpenFile(srcPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open source file: %w", err)
+	}
+	defer srcFile.Close() //nolint:errcheck
+
+	report := &ScanReport{Chunks: len(manifest.Chunks)}
+	for _, c := range manifest.Chunks {
+		if err := checkManifestChunk(srcFile, c); err != nil {
+			report.Problems = append(report.Problems, ChunkReport{
+				Index:  c.Index,
+				Offset: c.Offset - constants.ChunkHeaderSize - manifest.Body,
+				Err:    err,
+			})
+		}
+	}
+	if extra := srcInfo.Size() - manifest.Size; extra > 0 {
+		report.Problems = append(report.Problems, ChunkReport{
+			Index:  len(manifest.Chunks),
+			Offset: manifest.Size - manifest.Body,
+			Err:    fmt.Errorf("%w: %d byte(s) after the last chunk", constants.ErrManifestMismatch, extra),
+		})
+	}
+
+	if len(report.Problems) > 0 {
+		return report, fmt.Errorf("%w: %d problem(s) in %s", constants.ErrManifestMismatch, len(report.Problems), srcPath)
+	}
+	return report, nil
+}
+
+// checkManifestChunk compares the size prefix and checksums of one chunk with the manifest
+func checkManifestChunk(srcFile *os.File, c ManifestChunk) error {
+	var prefix [constants.ChunkHeaderSize]byte
+	if _, err := srcFile.ReadAt(prefix[:], c.Offset-constants.ChunkHeaderSize); err != nil {
+		return fmt.Errorf("%w: size prefix unreadable: %v", constants.ErrManifestMismatch, err)
+	}
+	if length := int64(binary.BigEndian.Uint32(prefix[:])); length != c.Length {
+		return fmt.Errorf("%w: size prefix says %d bytes, the manifest %d", constants.ErrManifestMismatch, length, c.Length)
+	}
+
+	crc, sum := crc32.NewIEEE(), sha256.New()
+	n, err := io.Copy(io.MultiWriter(crc, sum), io.NewSectionReader(srcFile, c.Offset, c.Length))
+	if err != nil {
+		return fmt.Errorf("%w: %v", constants.ErrManifestMismatch, err)
+	}
+	if n != c.Length {
+		return fmt.Errorf("%w: truncated after %d of %d bytes", constants.ErrManifestMismatch, n, c.Length)
+	}
+
+	if got := hex.EncodeToString(crc.Sum(nil)); got != c.CRC32 {
+		return fmt.Errorf("%w: CRC-32 is %s, the manifest has %s", constants.ErrManifestMismatch, got, c.CRC32)
+	}
+	if got := hex.EncodeToString(sum.Sum(nil)); got != c.SHA256 {
+		return fmt.Errorf("%w: SHA-256 is %s, the manifest has %s", constants.ErrManifestMismatch, got, c.SHA256)
+	}
+	return nil
+}
+
+// WriteManifest writes manifest to path as a JSON sidecar
+func WriteManifest(path string, manifest *ChunkManifest) error {
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode manifest: %w", err)
+	}
+	if err := os.WriteFile(path, append(data, '\n'), 0o600); err != nil {
+		return fmt.Errorf("%w: %v", constants.ErrFileWriteFailed, err)
+	}
+	return nil
+}
+
+// ReadManifest reads and validates a sidecar written by WriteManifest
+func ReadManifest(path string) (*ChunkManifest, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open manifest: %w", err)
+	}
+
+	manifest := &ChunkManifest{}
+	if err := json.Unmarshal(data, manifest); err != nil {
+		return nil, fmt.Errorf("%w: %v", constants.ErrInvalidManifest, err)
+	}
+	if err := manifest.Validate(); err != nil {
+		return nil, err
+	}
+	return manifest, nil
+}
@@ -0,0 +1,141 @@
+package operations
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/hambosto/hexwarden/internal/constants"
+	"github.com/hambosto/hexwarden/internal/infrastructure/crypto"
+)
+
+// MatchResult says whether a password unlocks one encrypted file
+type MatchResult struct {
+	Path    string
+	Matched bool
+	Skipped bool  // The salt differs from the one the check was limited to
+	Err     error // Why the header could not be checked; nil for a plain mismatch
+}
+
+// MatchReport lists the result for every file checked by Match
+type MatchReport struct {
+	Results     []MatchResult
+	Derivations int // Key derivations run, one per distinct salt and parameters
+}
+
+// Matched returns the paths the password unlocks
+func (r *MatchReport) Matched() []string {
+	var paths []string
+	for _, result := range r.Results {
+		if result.Matched {
+			paths = append(paths, result.Path)
+		}
+	}
+	return paths
+}
+
+// matchInput is what a derived key depends on, so files agreeing on it share one derivation
+type matchInput struct {
+	salt   string
+	params crypto.KDFParams
+	secret string
+}
+
+// Match reports which of paths password unlocks by verifying their headers with
+// the derived key; no body is read. Every file normally has its own salt and so
+// costs a full key derivation, but files sharing a salt and parameters are
+// checked with one. When salt is set, files with another salt are skipped
+// without deriving anything. The parameters come from headers anyone can write,
+// so files asking for more passes or memory than limit fail with ErrKDFLimit
+// instead of being derived
+func (d *Decryptor) Match(paths []string, password string, salt []byte, limit crypto.KDFParams) *MatchReport {
+	report := &MatchReport{Results: make([]MatchResult, 0, len(paths))}
+	keys := make(map[matchInput][]byte)
+
+	for _, path := range paths {
+		result := MatchResult{Path: path}
+		header, err := d.readMatchHeader(path)
+		switch {
+		case err != nil:
+			result.Err = err
+		case salt != nil && !bytes.Equal(header.Salt(), salt):
+			result.Skipped = true
+		case header.KDFParams().Time > limit.Time || header.KDFParams().Memory > limit.Memory:
+			params := header.KDFParams()
+			result.Err = fmt.Errorf("%w: %d pass(es) over %d KiB, limit is %d over %d KiB",
+				constants.ErrKDFLimit, params.Time, params.Memory, limit.Time, limit.Memory)
+		default:
+			result.Matched, result.Err = d.matchHeader(header, password, keys, &report.Derivations)
+		}
+		report.Results = append(report.Results, result)
+	}
+	return report
+}
+
+// readMatchHeader reads the header of the encrypted file at path
+func (d *Decryptor) readMatchHeader(path string) (*crypto.Header, error) {
+	srcFile, _, err := d.fileManager.OpenFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open source file: %w", err)
+	}
+	defer srcFile.Close() //nolint:errcheck
+
+	header, err := crypto.ReadHeader(srcFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read header: %w", err)
+	}
+	if header.Flags()&constants.FlagUnencrypted != 0 {
+		return nil, constants.ErrUnencrypted
+	}
+	return header, nil
+}
+
+// matchHeader checks password against header and then its key slots, deriving
+// each key only when keys holds none for the same inputs yet and counting the
+// derivations run
+func (d *Decryptor) matchHeader(header *crypto.Header, password string, keys map[matchInput][]byte, derivations *int) (bool, error) {
+	secret, err := headerSecret(header, password, d.pepper)
+	if err != nil {
+		return false, err
+	}
+
+	derive := func(salt []byte) ([]byte, error) {
+		input := matchInput{salt: string(salt), params: header.KDFParams(), secret: string(secret)}
+		key, ok := keys[input]
+		if !ok {
+			if key, err = d.deriveKey(secret, salt, header.KDFParams()); err != nil {
+				return nil, fmt.Errorf("failed to derive key: %w", err)
+			}
+			keys[input] = key
+			*derivations++
+		}
+		return key, nil
+	}
+
+	key, err := derive(header.Salt())
+	if err != nil {
+		return false, err
+	}
+	if header.VerifyKey(key) == nil {
+		return true, nil
+	}
+	for i := range header.KeySlots() {
+		kek, err := derive(header.KeySlotSalt(i))
+		if err != nil {
+			return false, err
+		}
+		if _, err := header.OpenKeySlot(i, kek); err == nil {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// HeaderSalt returns the key derivation salt of the encrypted file at path,
+// which is public, for limiting Match to files sharing it
+func (d *Decryptor) HeaderSalt(path string) ([]byte, error) {
+	header, err := d.readMatchHeader(path)
+	if err != nil {
+		return nil, err
+	}
+	return header.Salt(), nil
+}
@@ -0,0 +1,162 @@
+package operations
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+
+	"github.com/hambosto/hexwarden/internal/constants"
+	"github.com/hambosto/hexwarden/internal/data/streaming"
+	"github.com/hambosto/hexwarden/internal/infrastructure/crypto"
+)
+
+// BuildMerkleTree rebuilds the Merkle tree of an encrypted file from its chunks.
+// No password is needed, but the result is only trustworthy once its root has
+// been compared with the authenticated one in the header
+func (d *Decryptor) BuildMerkleTree(srcPath string) (*crypto.MerkleTree, error) {
+	srcFile, _, err := d.fileManager.OpenFile(srcPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open source file: %w", err)
+	}
+	defer srcFile.Close() //nolint:errcheck
+
+	header, err := crypto.ReadHeader(srcFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read header: %w", err)
+	}
+	return buildMerkleTree(srcFile, header.Version())
+}
+
+// buildMerkleTree hashes every chunk in src into a tree
+func buildMerkleTree(src io.Reader, formatVersion uint8) (*crypto.MerkleTree, error) {
+	chunks, err := streaming.NewChunkReader(src, formatVersion)
+	if err != nil {
+		return nil, err
+	}
+
+	var leaves [][]byte
+	for {
+		chunk, err := chunks.Next()
+		if errors.Is(err, io.EOF) {
+			return crypto.NewMerkleTree(leaves), nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		leaves = append(leaves, crypto.HashMerkleLeaf(chunk))
+	}
+}
+
+// VerifyChunk checks the single chunk at index against the Merkle root stored in
+// the header and decrypts it in memory. With a tree from the sidecar only that
+// chunk is read; without one the tree is rebuilt from every chunk in the file
+func (d *Decryptor) VerifyChunk(srcPath, password string, index int, tree *crypto.MerkleTree) error {
+	srcFile, _, err := d.fileManager.OpenFile(srcPath)
+	if err != nil {
+		return fmt.Errorf("failed to open source file: %w", err)
+	}
+	defer srcFile.Close() //nolint:errcheck
+
+	header, key, err := d.readHeader(srcFile, password)
+	if err != nil {
+		return err
+	}
+	if err := header.CheckAAD(d.aad); err != nil {
+		return err
+	}
+	root := header.MerkleRoot()
+	if root == nil {
+		return fmt.Errorf("%w: %s", constants.ErrNoMerkleRoot, srcPath)
+	}
+
+	if tree == nil {
+		if tree, err = buildMerkleTree(srcFile, header.Version()); err != nil {
+			return err
+		}
+		// A rebuilt tree cannot tell which chunk changed, only that one did
+		if !bytes.Equal(tree.Root(), root) {
+			return fmt.Errorf("%w: the file no longer matches its root; check chunk %d with the tree sidecar", constants.ErrMerkleMismatch, index)
+		}
+	}
+	proof, err := tree.Proof(index)
+	if err != nil {
+		return err
+	}
+
+	chunk, err := readChunkAt(srcFile, int64(header.Size()), index)
+	if err != nil {
+		return err
+	}
+	if !crypto.VerifyMerkleProof(root, crypto.HashMerkleLeaf(chunk), index, proof) {
+		return fmt.Errorf("%w: chunk %d", constants.ErrMerkleMismatch, index)
+	}
+
+	processor, err := newBodyProcessor(header, key, d.aad)
+	if err != nil {
+		return fmt.Errorf("failed to create processor: %w", err)
+	}
+	if _, err := processor.Decrypt(chunk); err != nil {
+		return fmt.Errorf("%w: chunk %d: %v", constants.ErrUnrecoverable, index, err)
+	}
+	return nil
+}
+
+// readChunkAt returns the chunk at index by following the size prefixes from
+// start, skipping over the data of the chunks before it
+func readChunkAt(srcFile *os.File, start int64, index int) ([]byte, error) {
+	var prefix [constants.ChunkHeaderSize]byte
+	offset := start
+
+	for i := 0; ; {
+		if _, err := srcFile.ReadAt(prefix[:], offset); err != nil {
+			return nil, fmt.Errorf("%w: chunk %d", constants.ErrChunkNotFound, index)
+		}
+		offset += constants.ChunkHeaderSize
+
+		length := binary.BigEndian.Uint32(prefix[:])
+		if length == 0 {
+			continue // Empty chunks are skipped, as when decrypting
+		}
+		if length > math.MaxInt32 {
+			return nil, constants.ErrChunkTooLarge
+		}
+
+		if i == index {
+			chunk := make([]byte, length)
+			if _, err := srcFile.ReadAt(chunk, offset); err != nil {
+				return nil, fmt.Errorf("chunk data read failed: %w", err)
+			}
+			return chunk, nil
+		}
+		offset += int64(length)
+		i++
+	}
+}
+
+// WriteMerkleTree writes tree to path as a sidecar for VerifyChunk
+func WriteMerkleTree(path string, tree *crypto.MerkleTree) error {
+	var buf bytes.Buffer
+	if _, err := tree.WriteTo(&buf); err != nil {
+		return err
+	}
+	if err := os.WriteFile(path, buf.Bytes(), 0o600); err != nil {
+		return fmt.Errorf("%w: %v", constants.ErrFileWriteFailed, err)
+	}
+	return nil
+}
+
+// ReadMerkleTree reads a sidecar written by WriteMerkleTree
+func ReadMerkleTree(path string) (*crypto.MerkleTree, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open Merkle tree: %w", err)
+	}
+	defer file.Close() //nolint:errcheck
+
+	return crypto.ReadMerkleTree(bufio.NewReader(file))
+}
@@ -0,0 +1,31 @@
+package operations
+
+import (
+	"fmt"
+
+	"github.com/hambosto/hexwarden/internal/constants"
+	"github.com/hambosto/hexwarden/internal/infrastructure/crypto"
+)
+
+// nonceOptions takes the header nonce from the configured nonce counter state
+// file. It is called right before the header is built, so options that fail
+// validation do not use up a counter
+func (e *Encryptor) nonceOptions(opts EncryptOptions) ([]crypto.HeaderOption, error) {
+	if opts.NonceState == "" {
+		return nil, nil
+	}
+	id, counter, err := e.fileManager.ReserveNonceCounter(opts.NonceState)
+	if err != nil {
+		return nil, fmt.Errorf("failed to reserve nonce counter: %w", err)
+	}
+	return []crypto.HeaderOption{crypto.WithNonceCounter(id, counter)}, nil
+}
+
+// chunkNonces returns the counter nonces the chunks of a file are sealed under
+// when its header nonce was taken from a nonce counter, and nil otherwise
+func chunkNonces(header *crypto.Header, key []byte) *crypto.ChunkNonces {
+	if header.NonceStrategy() != constants.NonceCounter {
+		return nil
+	}
+	return crypto.NewChunkNonces(key, header.Nonce())
+}
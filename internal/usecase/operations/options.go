@@ -0,0 +1,227 @@
+package operations
+
+import (
+	"crypto/ecdh"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"time"
+	"unicode/utf8"
+
+	"github.com/hambosto/hexwarden/internal/constants"
+	"github.com/hambosto/hexwarden/internal/infrastructure/crypto"
+)
+
+// EncryptOptions holds optional settings for an encryption; the zero value encrypts with defaults
+type EncryptOptions struct {
+	Comment            string                      // Free-text note stored encrypted in the header
+	Filename           string                      // Original filename stored encrypted in the header
+	MinPasswordLength  int                         // Reject shorter passwords, counted in characters; 0 disables the check
+	HashAlgorithm      constants.HashAlgorithm     // Header integrity hash; the zero value is SHA-256
+	Cipher             constants.CipherAlgorithm   // Body cipher; the zero value is AES-GCM, and CipherAuto must be resolved with crypto.SelectCipher
+	SmallFileThreshold int64                       // Sources smaller than this skip compression and Reed-Solomon; 0 disables the fast path
+	Thumbnail          []byte                      // JPEG preview stored encrypted in the header
+	Random             io.Reader                   // Source for the salt and header nonce; nil uses crypto/rand
+	Merkle             bool                        // Store the root of a Merkle tree over the chunks; the output must be seekable
+	HashOriginal       bool                        // Store the SHA-256 of the source encrypted, for decryption to verify; the output must be seekable
+	NotBefore          time.Time                   // Advisory time lock: decryption is refused before this time; the zero value disables it
+	KDFParams          crypto.KDFParams            // Argon2id cost; the zero value uses crypto.DefaultKDFParams
+	AAD                []byte                      // Associated data the body is bound to; decryption must supply the same with Decryptor.SetAAD
+	Compression        constants.CompressionFormat // Chunk compression; the zero value is gzip, and raw deflate saves 18 bytes per chunk
+	Paranoid           bool                        // Decode each chunk right after Reed-Solomon encoding and fail unless it gives back the input
+	Manifest           bool                        // Record the offset, length and checksums of every chunk in EncryptResult.Manifest
+	RecoveryCode       string                      // Code from crypto.NewRecoveryCode the file key is also wrapped under, for Decryptor.SetRecoveryCode; empty stores none
+	ExtraPasswords     []string                    // Further passwords that each unlock the file through a key slot of their own
+	CompressMetadata   bool                        // Compress a large header metadata block; cannot be combined with Merkle or HashOriginal
+	EscrowKey          *ecdh.PublicKey             // X25519 key of an escrow holder the file key is wrapped to in EncryptResult.EscrowedKey; nil escrows nothing
+	NonceState         string                      // Nonce counter state file the header nonce is taken from; empty draws it at random
+	PadOutputTo        int64                       // Pad the encrypted file with authenticated random filler to exactly this many bytes; 0 disables padding
+	flags              constants.HeaderFlags       // Extra header flags marking special files such as dedup references
+}
+
+// OptionError reports an encryption option that failed validation
+type OptionError struct {
+	Option string // Name of the EncryptOptions field at fault
+	Err    error
+}
+
+func (e *OptionError) Error() string {
+	return fmt.Sprintf("invalid %s: %v", e.Option, e.Err)
+}
+
+func (e *OptionError) Unwrap() error {
+	return e.Err
+}
+
+// Validate checks every option, and the combinations of them, before any file
+// is created. Each problem is an *OptionError naming the field at fault, and
+// all of them are joined into the returned error so they can be fixed at once
+func (o EncryptOptions) Validate() error {
+	var errs []error
+	check := func(option string, err error) {
+		if err != nil {
+			errs = append(errs, &OptionError{Option: option, Err: err})
+		}
+	}
+
+	if o.MinPasswordLength < 0 {
+		check("MinPasswordLength", fmt.Errorf("cannot be negative: %d", o.MinPasswordLength))
+	}
+	if o.SmallFileThreshold < 0 || o.SmallFileThreshold > constants.MaxRawBodySize {
+		check("SmallFileThreshold", fmt.Errorf("must be between 0 and %d bytes: %d", constants.MaxRawBodySize, o.SmallFileThreshold))
+	}
+	if o.Cipher == constants.CipherAuto {
+		check("Cipher", fmt.Errorf("%w: %s must be resolved with crypto.SelectCipher before encrypting", constants.ErrInvalidOption, o.Cipher))
+	} else if o.Cipher != constants.CipherAESGCM {
+		check("Cipher", crypto.ValidateOptions(crypto.WithCipher(o.Cipher)))
+	}
+	if o.HashAlgorithm != constants.HashSHA256 {
+		check("HashAlgorithm", crypto.ValidateOptions(crypto.WithHashAlgorithm(o.HashAlgorithm)))
+	}
+	if o.Compression != constants.CompressionGzip {
+		check("Compression", crypto.ValidateOptions(crypto.WithCompression(o.Compression)))
+	}
+	if o.KDFParams != (crypto.KDFParams{}) {
+		check("KDFParams", o.KDFParams.Validate())
+	}
+	if o.Comment != "" {
+		check("Comment", crypto.ValidateOptions(crypto.WithComment(o.Comment)))
+	}
+	if o.Filename != "" {
+		check("Filename", crypto.ValidateOptions(crypto.WithFilename(o.Filename)))
+	}
+	if o.Thumbnail != nil {
+		check("Thumbnail", crypto.ValidateOptions(crypto.WithThumbnail(o.Thumbnail)))
+	}
+	if o.AAD != nil {
+		check("AAD", crypto.ValidateOptions(crypto.WithAAD(o.AAD)))
+	}
+	if o.RecoveryCode != "" {
+		check("RecoveryCode", crypto.ValidateOptions(crypto.WithRecoveryCode(o.RecoveryCode)))
+	}
+	if o.CompressMetadata && (o.Merkle || o.HashOriginal) {
+		check("CompressMetadata", fmt.Errorf("%w: a compressed header cannot be rewritten in place with the Merkle root or original SHA-256", constants.ErrInvalidOption))
+	}
+	if len(o.ExtraPasswords) > constants.MaxKeySlots {
+		check("ExtraPasswords", fmt.Errorf("%w: at most %d", constants.ErrTooManyKeySlots, constants.MaxKeySlots))
+	}
+	if slices.Contains(o.ExtraPasswords, "") {
+		check("ExtraPasswords", fmt.Errorf("%w: password cannot be empty", constants.ErrInvalidOption))
+	}
+	if o.PadOutputTo < 0 {
+		check("PadOutputTo", fmt.Errorf("cannot be negative: %d", o.PadOutputTo))
+	}
+	if o.NonceState != "" && o.Random != nil {
+		check("NonceState", fmt.Errorf("%w: a nonce counter cannot be combined with a random source", constants.ErrInvalidOption))
+	}
+	if len(errs) > 0 {
+		return errors.Join(errs...)
+	}
+
+	// Options that are each valid may still clash once combined in the header
+	return crypto.ValidateOptions(o.headerOptions()...)
+}
+
+// CheckPassword enforces the minimum password length
+func (o EncryptOptions) CheckPassword(password string) error {
+	if length := utf8.RuneCountInString(password); length < o.MinPasswordLength {
+		return fmt.Errorf("%w: %d characters, at least %d required", constants.ErrPasswordTooShort, length, o.MinPasswordLength)
+	}
+	return nil
+}
+
+// validateFor checks the options and the passwords before any work is done
+func (o EncryptOptions) validateFor(password string) error {
+	if err := o.Validate(); err != nil {
+		return err
+	}
+	for _, p := range append([]string{password}, o.ExtraPasswords...) {
+		if err := o.CheckPassword(p); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// rawBody reports whether a source of the given size takes the small-file fast path.
+// Empty sources produce no chunks at all, so they gain nothing from it, a
+// Merkle tree or manifest needs chunks to cover, and output padding follows
+// the marker that ends them
+func (o EncryptOptions) rawBody(size int64) bool {
+	return size > 0 && size < o.SmallFileThreshold && !o.Merkle && !o.Manifest && o.PadOutputTo == 0
+}
+
+// kdfParams returns the Argon2id parameters to derive the key with
+func (o EncryptOptions) kdfParams() crypto.KDFParams {
+	if o.KDFParams == (crypto.KDFParams{}) {
+		return crypto.DefaultKDFParams()
+	}
+	return o.KDFParams
+}
+
+// headerOptions converts the options into header builder options
+func (o EncryptOptions) headerOptions() []crypto.HeaderOption {
+	var opts []crypto.HeaderOption
+	if o.Comment != "" {
+		opts = append(opts, crypto.WithComment(o.Comment))
+	}
+	if o.Filename != "" {
+		opts = append(opts, crypto.WithFilename(o.Filename))
+	}
+	if o.Thumbnail != nil {
+		opts = append(opts, crypto.WithThumbnail(o.Thumbnail))
+	}
+	// The defaults are assumed when none are recorded, so the header stays v2
+	if params := o.kdfParams(); params != crypto.DefaultKDFParams() {
+		opts = append(opts, crypto.WithKDFParams(params))
+	}
+	if o.HashAlgorithm != constants.HashSHA256 {
+		opts = append(opts, crypto.WithHashAlgorithm(o.HashAlgorithm))
+	}
+	if o.Cipher != constants.CipherAESGCM {
+		opts = append(opts, crypto.WithCipher(o.Cipher))
+	}
+	if !o.NotBefore.IsZero() {
+		opts = append(opts, crypto.WithNotBefore(o.NotBefore))
+	}
+	if len(o.AAD) > 0 {
+		opts = append(opts, crypto.WithAAD(o.AAD))
+	}
+	if o.Compression != constants.CompressionGzip {
+		opts = append(opts, crypto.WithCompression(o.Compression))
+	}
+	if o.RecoveryCode != "" {
+		opts = append(opts, crypto.WithRecoveryCode(o.RecoveryCode))
+	}
+	if o.CompressMetadata {
+		opts = append(opts, crypto.WithMetadataCompression())
+	}
+	if o.Random != nil {
+		opts = append(opts, crypto.WithRandom(o.Random))
+	}
+	if o.Merkle {
+		// A placeholder of the final size, so the header can be rewritten in place
+		opts = append(opts, crypto.WithMerkleRoot(make([]byte, constants.MerkleHashSize)))
+	}
+	if o.flags != 0 {
+		opts = append(opts, crypto.WithFlags(o.flags))
+	}
+	if o.PadOutputTo > 0 {
+		opts = append(opts, crypto.WithPaddedOutput(uint64(o.PadOutputTo)))
+	}
+	if o.HashOriginal {
+		// Sealing a placeholder of the same size keeps the header size fixed
+		opts = append(opts, crypto.WithOriginalSHA256(make([]byte, sha256.Size)))
+	}
+	return opts
+}
+
+// generateSalt draws a salt from the configured random source
+func (o EncryptOptions) generateSalt() ([]byte, error) {
+	if o.Random != nil {
+		return crypto.GenerateSaltFrom(o.Random)
+	}
+	return crypto.GenerateSalt()
+}
@@ -0,0 +1,93 @@
+package operations
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"io"
+
+	"github.com/hambosto/hexwarden/internal/constants"
+	"github.com/hambosto/hexwarden/internal/infrastructure/crypto"
+)
+
+// paddingOverhead is what padding adds even without filler: the marker ending
+// the chunks and the sealed padding record
+const paddingOverhead = constants.ChunkHeaderSize + constants.PaddingRecordSize
+
+// isPadded reports whether the chunks following header are followed by output padding
+func isPadded(header *crypto.Header) bool {
+	_, padded := header.PaddedSize()
+	return padded
+}
+
+// writeOutputPadding ends the chunks written to dst with the footer marker and
+// fills the output up to target bytes, written counting what dst already holds.
+// The filler is random, so it cannot be told apart from the chunks, and its
+// length and SHA-256 are sealed under key in a record that ends the file. It
+// fails with ErrPaddingTarget when the output does not fit in target
+func writeOutputPadding(dst io.Writer, key []byte, written, target int64) error {
+	filler := target - written - paddingOverhead
+	if filler < 0 {
+		return fmt.Errorf("%w: the encrypted file takes %d bytes with padding, target is %d",
+			constants.ErrPaddingTarget, written+paddingOverhead, target)
+	}
+
+	marker := binary.BigEndian.AppendUint32(nil, constants.ChunkFooterMarker)
+	if _, err := dst.Write(marker); err != nil {
+		return fmt.Errorf("%w: %v", constants.ErrFileWriteFailed, err)
+	}
+
+	digest := sha256.New()
+	if _, err := io.CopyN(io.MultiWriter(dst, digest), rand.Reader, filler); err != nil {
+		return fmt.Errorf("%w: output padding: %v", constants.ErrFileWriteFailed, err)
+	}
+
+	record, err := crypto.SealPadding(key, uint64(filler), digest.Sum(nil))
+	if err != nil {
+		return fmt.Errorf("failed to seal padding record: %w", err)
+	}
+	if _, err := dst.Write(record); err != nil {
+		return fmt.Errorf("%w: %v", constants.ErrFileWriteFailed, err)
+	}
+	return nil
+}
+
+// checkOutputPadding reads the filler and padding record left in src after the
+// footer marker and checks the record is authentic and matches the filler.
+// Only the record is held back in memory, however long the filler is
+func checkOutputPadding(src io.Reader, key []byte) error {
+	digest := sha256.New()
+	var filler uint64
+
+	buffer := make([]byte, 32*1024)
+	tail := make([]byte, 0, len(buffer)+constants.PaddingRecordSize)
+	for {
+		n, err := src.Read(buffer)
+		tail = append(tail, buffer[:n]...)
+		if extra := len(tail) - constants.PaddingRecordSize; extra > 0 {
+			digest.Write(tail[:extra])
+			filler += uint64(extra)
+			tail = append(tail[:0], tail[extra:]...)
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read output padding: %w", err)
+		}
+	}
+	if len(tail) < constants.PaddingRecordSize {
+		return fmt.Errorf("%w: padding record is cut off", constants.ErrPaddingMismatch)
+	}
+
+	length, sum, err := crypto.OpenPadding(key, tail)
+	if err != nil {
+		return err
+	}
+	if length != filler || !bytes.Equal(sum, digest.Sum(nil)) {
+		return fmt.Errorf("%w: %d bytes of filler, record says %d", constants.ErrPaddingMismatch, filler, length)
+	}
+	return nil
+}
@@ -0,0 +1,44 @@
+package operations
+
+import (
+	"github.com/hambosto/hexwarden/internal/constants"
+	"github.com/hambosto/hexwarden/internal/infrastructure/crypto"
+)
+
+// SetPepper sets the secret mixed into every password before key derivation.
+// Files written with a pepper are marked in the header and need the same
+// pepper to decrypt. An empty pepper writes unpeppered files
+func (e *Encryptor) SetPepper(pepper []byte) {
+	e.pepper = pepper
+}
+
+// SetPepper sets the secret mixed into the password of files whose header
+// marks them as peppered. Files without the mark are unlocked without it
+func (d *Decryptor) SetPepper(pepper []byte) {
+	d.pepper = pepper
+}
+
+// secret returns the password to derive a new file's key from, with the pepper mixed in
+func (e *Encryptor) secret(password string) []byte {
+	return crypto.ApplyPepper([]byte(password), e.pepper)
+}
+
+// pepperOptions marks the header of a new file as peppered when a pepper is set
+func (e *Encryptor) pepperOptions() []crypto.HeaderOption {
+	if len(e.pepper) == 0 {
+		return nil
+	}
+	return []crypto.HeaderOption{crypto.WithFlags(constants.FlagPeppered)}
+}
+
+// headerSecret returns the password to derive the key of an existing file
+// from, mixing in pepper when the header asks for one
+func headerSecret(header *crypto.Header, password string, pepper []byte) ([]byte, error) {
+	if header.Flags()&constants.FlagPeppered == 0 {
+		return []byte(password), nil
+	}
+	if len(pepper) == 0 {
+		return nil, constants.ErrPepperRequired
+	}
+	return crypto.ApplyPepper([]byte(password), pepper), nil
+}
@@ -0,0 +1,241 @@
+package operations
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"os"
+
+	"github.com/hambosto/hexwarden/internal/constants"
+	"github.com/hambosto/hexwarden/internal/infrastructure/crypto"
+)
+
+// ByteRange selects the plaintext bytes from Start through End, both inclusive
+type ByteRange struct {
+	Start uint64
+	End   uint64
+}
+
+// Len returns the number of bytes in the range
+func (r ByteRange) Len() uint64 {
+	return r.End - r.Start + 1
+}
+
+// chunkSpan locates one chunk in the body, relative to the start of the file
+type chunkSpan struct {
+	offset int64 // Offset of the chunk data, after its size prefix
+	length uint32
+}
+
+// DecryptRange decrypts only the plaintext bytes in r of the file at srcPath to destPath
+func (d *Decryptor) DecryptRange(srcPath, destPath, password string, r ByteRange) (*HeaderInfo, error) {
+	srcFile, _, err := d.fileManager.OpenFile(srcPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open source file: %w", err)
+	}
+	defer srcFile.Close() //nolint:errcheck
+
+	header, key, err := d.readRangeHeader(srcFile, password, r)
+	if err != nil {
+		return nil, err
+	}
+
+	destFile, err := d.fileManager.CreateFile(destPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create destination file: %w", err)
+	}
+	defer destFile.Close() //nolint:errcheck
+
+	if err := d.decryptRange(srcFile, destFile, header, key, r); err != nil {
+		d.discardPartialOutput(destFile, destPath)
+		return nil, err
+	}
+	return newHeaderInfo(header, key)
+}
+
+// DecryptRangeTo decrypts the plaintext bytes in r of the file at srcPath to dst.
+// dst is not closed; on failure it may hold partial plaintext the caller must discard
+func (d *Decryptor) DecryptRangeTo(srcPath string, dst io.Writer, password string, r ByteRange) (*HeaderInfo, error) {
+	srcFile, _, err := d.fileManager.OpenFile(srcPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open source file: %w", err)
+	}
+	defer srcFile.Close() //nolint:errcheck
+
+	header, key, err := d.readRangeHeader(srcFile, password, r)
+	if err != nil {
+		return nil, err
+	}
+	if err := d.decryptRange(srcFile, dst, header, key, r); err != nil {
+		return nil, err
+	}
+	return newHeaderInfo(header, key)
+}
+
+// readRangeHeader unlocks the header and checks r lies within the original file
+func (d *Decryptor) readRangeHeader(srcFile *os.File, password string, r ByteRange) (*crypto.Header, []byte, error) {
+	header, key, err := d.readHeader(srcFile, password)
+	if err != nil {
+		return nil, nil, err
+	}
+	if header.Flags()&constants.FlagBundle != 0 {
+		return nil, nil, constants.ErrBundle
+	}
+	if header.Flags()&constants.FlagReference != 0 {
+		return nil, nil, constants.ErrReference
+	}
+	if err := d.checkTimelock(header); err != nil {
+		return nil, nil, err
+	}
+	if err := header.CheckAAD(d.aad); err != nil {
+		return nil, nil, err
+	}
+
+	if r.Start > r.End {
+		return nil, nil, fmt.Errorf("%w: %d-%d", constants.ErrInvalidRange, r.Start, r.End)
+	}
+	// A log records no size, so its range is checked as the records are read
+	size := header.OriginalSize()
+	if header.Flags()&constants.FlagSizeFooter != 0 {
+		if size, err = footerSize(srcFile, key); err != nil {
+			return nil, nil, err
+		}
+	}
+	if header.Flags()&constants.FlagLog == 0 && r.End >= size {
+		return nil, nil, fmt.Errorf("%w: %d-%d of a %d-byte file", constants.ErrInvalidRange, r.Start, r.End, size)
+	}
+	return header, key, nil
+}
+
+// decryptRange writes the bytes in r to dst. Chunked bodies only decrypt the
+// chunks overlapping r; other bodies are small or unindexed and are decrypted
+// in full with everything outside r dropped
+func (d *Decryptor) decryptRange(srcFile *os.File, dst io.Writer, header *crypto.Header, key []byte, r ByteRange) error {
+	if header.Flags()&(constants.FlagRawBody|constants.FlagLog|constants.FlagSizeFooter) != 0 || isPadded(header) {
+		out := &rangeWriter{dst: dst, skip: r.Start, remaining: r.Len()}
+		if err := d.decryptPayload(srcFile, out, header, key); err != nil {
+			return err
+		}
+		if out.remaining > 0 {
+			return fmt.Errorf("%w: the file ends %d bytes before the end of the range", constants.ErrInvalidRange, out.remaining)
+		}
+		return nil
+	}
+
+	spans, err := chunkSpans(srcFile, int64(header.Size()))
+	if err != nil {
+		return err
+	}
+
+	// Chunks hold at most DefaultChunkSize bytes. When there are just enough of
+	// them and the last one holds the remainder, every other chunk must be full,
+	// so the chunk holding any offset can be computed without decrypting the rest
+	chunkSize := uint64(constants.DefaultChunkSize)
+	size := header.OriginalSize()
+	count := (size + chunkSize - 1) / chunkSize
+	if uint64(len(spans)) != count {
+		return fmt.Errorf("%w: %d chunks for %d bytes", constants.ErrUnevenChunks, len(spans), size)
+	}
+
+	processor, err := newBodyProcessor(header, key, d.aad)
+	if err != nil {
+		return fmt.Errorf("failed to create processor: %w", err)
+	}
+	decryptSpan := func(index uint64) ([]byte, error) {
+		chunk := make([]byte, spans[index].length)
+		if _, err := srcFile.ReadAt(chunk, spans[index].offset); err != nil {
+			return nil, fmt.Errorf("chunk data read failed: %w", err)
+		}
+		plaintext, err := processor.Decrypt(chunk)
+		if err != nil {
+			return nil, fmt.Errorf("%w: chunk %d: %v", constants.ErrDecryptionFailed, index, err)
+		}
+
+		want := chunkSize
+		if index == count-1 {
+			want = size - (count-1)*chunkSize
+		}
+		if uint64(len(plaintext)) != want {
+			return nil, fmt.Errorf("%w: chunk %d holds %d bytes, %d expected", constants.ErrUnevenChunks, index, len(plaintext), want)
+		}
+		return plaintext, nil
+	}
+
+	first, last := r.Start/chunkSize, r.End/chunkSize
+	if last != count-1 {
+		if _, err := decryptSpan(count - 1); err != nil {
+			return err
+		}
+	}
+
+	for index := first; index <= last; index++ {
+		plaintext, err := decryptSpan(index)
+		if err != nil {
+			return err
+		}
+
+		base := index * chunkSize
+		from := max(r.Start, base) - base
+		to := min(r.End, base+uint64(len(plaintext))-1) - base + 1
+		if _, err := dst.Write(plaintext[from:to]); err != nil {
+			return fmt.Errorf("%w: %v", constants.ErrFileWriteFailed, err)
+		}
+	}
+	return nil
+}
+
+// chunkSpans follows the size prefixes from start to the end of srcFile and
+// returns where each non-empty chunk lies, without reading the chunks themselves
+func chunkSpans(srcFile *os.File, start int64) ([]chunkSpan, error) {
+	var prefix [constants.ChunkHeaderSize]byte
+	var spans []chunkSpan
+
+	for offset := start; ; {
+		n, err := srcFile.ReadAt(prefix[:], offset)
+		if n == 0 && err == io.EOF {
+			return spans, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("chunk size read failed: %w", err)
+		}
+		offset += constants.ChunkHeaderSize
+
+		length := binary.BigEndian.Uint32(prefix[:])
+		if length == 0 {
+			continue // Empty chunks are skipped, as when decrypting
+		}
+		if length > math.MaxInt32 {
+			return nil, constants.ErrChunkTooLarge
+		}
+		spans = append(spans, chunkSpan{offset: offset, length: length})
+		offset += int64(length)
+	}
+}
+
+// rangeWriter passes on remaining bytes after dropping the first skip bytes,
+// and drops everything after them
+type rangeWriter struct {
+	dst       io.Writer
+	skip      uint64
+	remaining uint64
+}
+
+func (w *rangeWriter) Write(p []byte) (int, error) {
+	n := len(p)
+	if w.skip >= uint64(len(p)) {
+		w.skip -= uint64(len(p))
+		return n, nil
+	}
+	p = p[w.skip:]
+	w.skip = 0
+
+	if uint64(len(p)) > w.remaining {
+		p = p[:w.remaining]
+	}
+	w.remaining -= uint64(len(p))
+	if _, err := w.dst.Write(p); err != nil {
+		return 0, err
+	}
+	return n, nil
+}
@@ -0,0 +1,77 @@
+package operations
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/hambosto/hexwarden/internal/constants"
+	"github.com/hambosto/hexwarden/internal/infrastructure/crypto"
+)
+
+// encryptRawBody seals a small source as a single AEAD message. Compression,
+// padding and Reed-Solomon cost more than they save at this size, so the body is
+// still encrypted, authenticated and bound to aad but carries no parity. It is
+// sealed as chunk 0 of nonces when they are set, under a random nonce otherwise
+func encryptRawBody(src io.Reader, dst io.Writer, size int64, key []byte, alg constants.CipherAlgorithm, aad []byte, nonces *crypto.ChunkNonces) error {
+	cipher, err := crypto.NewCipher(alg, key[:constants.KeySize])
+	if err != nil {
+		return fmt.Errorf("failed to create cipher: %w", err)
+	}
+
+	plaintext := make([]byte, size)
+	if _, err := io.ReadFull(src, plaintext); err != nil {
+		return fmt.Errorf("failed to read source: %w", err)
+	}
+	// src is a sizedReader, so this only probes for a source that grew past size
+	if _, err := io.Copy(io.Discard, src); err != nil {
+		return fmt.Errorf("failed to read source: %w", err)
+	}
+
+	var sealed []byte
+	if nonces != nil {
+		sealed, err = cipher.EncryptWithNonce(nonces.Nonce(0, cipher.NonceSize()), plaintext, aad)
+	} else {
+		sealed, err = cipher.EncryptWithAAD(plaintext, aad)
+	}
+	if err != nil {
+		return fmt.Errorf("encryption failed: %w", err)
+	}
+
+	if _, err := dst.Write(sealed); err != nil {
+		return fmt.Errorf("failed to write body: %w", err)
+	}
+	return nil
+}
+
+// decryptRawBody opens a body written by encryptRawBody. The body must hold
+// exactly one sealed message of the original size, bound to the same aad
+func decryptRawBody(src io.Reader, dst io.Writer, size uint64, key []byte, alg constants.CipherAlgorithm, aad []byte) error {
+	if size == 0 || size > constants.MaxRawBodySize {
+		return fmt.Errorf("%w: %d bytes", constants.ErrRawBodySize, size)
+	}
+
+	cipher, err := crypto.NewCipher(alg, key[:constants.KeySize])
+	if err != nil {
+		return fmt.Errorf("failed to create cipher: %w", err)
+	}
+
+	// Read one byte past the expected length to detect trailing data
+	expected := int64(size) + int64(cipher.Overhead())
+	sealed, err := io.ReadAll(io.LimitReader(src, expected+1))
+	if err != nil {
+		return fmt.Errorf("failed to read body: %w", err)
+	}
+	if int64(len(sealed)) != expected {
+		return fmt.Errorf("%w: got %d bytes, expected %d", constants.ErrRawBodySize, len(sealed), expected)
+	}
+
+	plaintext, err := cipher.DecryptWithAAD(sealed, aad)
+	if err != nil {
+		return fmt.Errorf("decryption failed: %w", err)
+	}
+
+	if _, err := dst.Write(plaintext); err != nil {
+		return fmt.Errorf("failed to write output: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,127 @@
+package operations
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/hambosto/hexwarden/internal/constants"
+	"github.com/hambosto/hexwarden/internal/data/streaming"
+	"github.com/hambosto/hexwarden/internal/infrastructure/crypto"
+)
+
+// Recompress rewrites the chunks of the encrypted file at path compressed in
+// format. The file keeps its salt and key, so the password is unchanged and
+// no second key derivation runs; every chunk gets a fresh nonce. The plaintext
+// only passes through memory, and the file is replaced atomically, so a
+// failure leaves it untouched
+func (d *Decryptor) Recompress(path, password string, format constants.CompressionFormat) error {
+	srcFile, srcInfo, err := d.fileManager.OpenFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to open source file: %w", err)
+	}
+	defer srcFile.Close() //nolint:errcheck
+
+	header, key, err := d.readHeader(srcFile, password)
+	if err != nil {
+		return err
+	}
+	if header.Flags()&(constants.FlagRawBody|constants.FlagBundle|constants.FlagLog|constants.FlagSizeFooter) != 0 || isPadded(header) {
+		return fmt.Errorf("%w: only files made of compressed chunks can be recompressed", constants.ErrInvalidOption)
+	}
+	if err := d.checkTimelock(header); err != nil {
+		return err
+	}
+	if err := header.CheckAAD(d.aad); err != nil {
+		return err
+	}
+	from := header.Compression()
+	if from == format {
+		return fmt.Errorf("%w: chunks are already compressed with %s", constants.ErrInvalidOption, format)
+	}
+	if err := header.SetCompression(key, format); err != nil {
+		return err
+	}
+
+	tmpFile, err := d.fileManager.CreateTemp(path)
+	if err != nil {
+		return err
+	}
+	tmpPath := tmpFile.Name()
+
+	if err := d.recompressBody(srcFile, tmpFile, header, key, from, srcInfo.Mode().Perm()); err != nil {
+		_ = tmpFile.Close()
+		d.fileManager.DiscardTemp(tmpPath)
+		return err
+	}
+	if err := tmpFile.Close(); err != nil {
+		d.fileManager.DiscardTemp(tmpPath)
+		return fmt.Errorf("failed to write temporary file: %w", err)
+	}
+	return d.fileManager.CommitTemp(tmpPath, path)
+}
+
+// recompressBody writes header to dst followed by the chunks of src, which are
+// compressed in from, decrypted and encrypted again in the format header now
+// records. A stored Merkle root covers the old chunks, so it is replaced by the
+// root of the new ones and the header rewritten
+func (d *Decryptor) recompressBody(src io.Reader, dst *os.File, header *crypto.Header, key []byte, from constants.CompressionFormat, perm os.FileMode) error {
+	if err := dst.Chmod(perm); err != nil {
+		return fmt.Errorf("failed to set permissions: %w", err)
+	}
+	if err := header.Write(dst); err != nil {
+		return fmt.Errorf("failed to write header: %w", err)
+	}
+
+	var onChunk func([]byte)
+	var leaves [][]byte
+	if header.MerkleRoot() != nil {
+		onChunk = func(chunk []byte) {
+			leaves = append(leaves, crypto.HashMerkleLeaf(chunk))
+		}
+	}
+
+	// The decrypted chunks are fed straight into the encryptor
+	plainReader, plainWriter := io.Pipe()
+	var decryptStats streaming.PoolStats
+	decrypted := make(chan error, 1)
+	go func() {
+		err := decryptChunks(src, plainWriter, int64(header.OriginalSize()), false, key, header.Cipher(), from, d.aad, header.Version(), d.logger, d.idleTimeout, nil, &decryptStats)
+		plainWriter.CloseWithError(err)
+		decrypted <- err
+	}()
+
+	// The header and its nonce are kept, so counter nonces would repeat those of
+	// the old chunks; the new chunks are sealed under random nonces instead
+	size := int64(header.OriginalSize())
+	err := encryptChunks(newSizedReader(plainReader, size), dst, size, key, header.Cipher(), header.Compression(), d.aad, nil, false, d.logger, d.idleTimeout, onChunk, &d.stats)
+	// Unblocks the decryptor when encryption stopped early
+	plainReader.CloseWithError(io.ErrClosedPipe)
+	decryptErr := <-decrypted
+	d.stats.Add(decryptStats)
+
+	// A failed decryption also fails the encryption reading from it, so it is the cause to report
+	if decryptErr != nil && !errors.Is(decryptErr, io.ErrClosedPipe) {
+		return decryptErr
+	}
+	if err != nil {
+		return err
+	}
+
+	if onChunk != nil {
+		if err := header.SetMerkleRoot(key, crypto.NewMerkleTree(leaves).Root()); err != nil {
+			return fmt.Errorf("failed to store Merkle root: %w", err)
+		}
+		if _, err := dst.Seek(0, io.SeekStart); err != nil {
+			return fmt.Errorf("failed to rewrite header: %w", err)
+		}
+		if err := header.Write(dst); err != nil {
+			return fmt.Errorf("failed to rewrite header: %w", err)
+		}
+	}
+	if err := dst.Sync(); err != nil {
+		return fmt.Errorf("failed to sync: %w", err)
+	}
+	return nil
+}
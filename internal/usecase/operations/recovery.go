@@ -0,0 +1,25 @@
+package operations
+
+import (
+	"fmt"
+
+	"github.com/hambosto/hexwarden/internal/infrastructure/crypto"
+)
+
+// SetRecoveryCode makes decryption unlock files with the recovery code they were
+// written with through EncryptOptions.RecoveryCode, ignoring the password. Files
+// written without one fail with ErrNoRecoveryCode. An empty code goes back to
+// unlocking with the password
+func (d *Decryptor) SetRecoveryCode(code string) {
+	d.recoveryCode = code
+}
+
+// recoverKey unwraps the key of header with the recovery code. The code is
+// random rather than chosen, so no KDF is run and no pepper is needed
+func (d *Decryptor) recoverKey(header *crypto.Header) ([]byte, error) {
+	key, err := header.RecoverKey(d.recoveryCode)
+	if err != nil {
+		return nil, fmt.Errorf("header verification failed: %w", err)
+	}
+	return key, nil
+}
@@ -0,0 +1,88 @@
+package operations
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/hambosto/hexwarden/internal/infrastructure/crypto"
+)
+
+// SetFilename replaces the filename stored in the header of an encrypted file.
+// Only the header is resealed; the body is copied unchanged. An empty name
+// removes the stored filename
+func (d *Decryptor) SetFilename(path, password, name string) error {
+	return d.rewriteHeader(path, password, func(header *crypto.Header, key []byte) error {
+		return header.SetFilename(key, name)
+	})
+}
+
+// StripMetadata removes the filename, hint, comment and thumbnail from the
+// header of an encrypted file, returning the names of the fields removed.
+// Only the header is resealed; the body is copied unchanged
+func (d *Decryptor) StripMetadata(path, password string) ([]string, error) {
+	var removed []string
+	err := d.rewriteHeader(path, password, func(header *crypto.Header, key []byte) error {
+		var err error
+		removed, err = header.StripMetadata(key)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return removed, nil
+}
+
+// rewriteHeader unlocks the header of path, lets edit change it and replaces the
+// file with the new header followed by the original body. The replacement is
+// written to a temporary file and renamed over it, so a failure leaves it untouched
+func (d *Decryptor) rewriteHeader(path, password string, edit func(*crypto.Header, []byte) error) error {
+	srcFile, srcInfo, err := d.fileManager.OpenFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to open source file: %w", err)
+	}
+	defer srcFile.Close() //nolint:errcheck
+
+	header, key, err := d.readHeader(srcFile, password)
+	if err != nil {
+		return err
+	}
+	if err := edit(header, key); err != nil {
+		return err
+	}
+
+	tmpFile, err := d.fileManager.CreateTemp(path)
+	if err != nil {
+		return err
+	}
+	tmpPath := tmpFile.Name()
+
+	if err := writeWithHeader(tmpFile, header, srcFile, srcInfo.Mode().Perm()); err != nil {
+		_ = tmpFile.Close()
+		d.fileManager.DiscardTemp(tmpPath)
+		return err
+	}
+	if err := tmpFile.Close(); err != nil {
+		d.fileManager.DiscardTemp(tmpPath)
+		return fmt.Errorf("failed to write temporary file: %w", err)
+	}
+
+	return d.fileManager.CommitTemp(tmpPath, path)
+}
+
+// writeWithHeader writes header and the rest of body to dst, flushing it to disk
+func writeWithHeader(dst *os.File, header *crypto.Header, body io.Reader, perm os.FileMode) error {
+	if err := dst.Chmod(perm); err != nil {
+		return fmt.Errorf("failed to set permissions: %w", err)
+	}
+	if err := header.Write(dst); err != nil {
+		return fmt.Errorf("failed to write header: %w", err)
+	}
+	if _, err := io.Copy(dst, body); err != nil {
+		return fmt.Errorf("failed to copy body: %w", err)
+	}
+	if err := dst.Sync(); err != nil {
+		return fmt.Errorf("failed to sync: %w", err)
+	}
+	return nil
+}
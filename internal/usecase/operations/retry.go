@@ -0,0 +1,54 @@
+package operations
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/hambosto/hexwarden/internal/constants"
+)
+
+// RetryPolicy limits how often a wrong decryption password may be typed again
+type RetryPolicy struct {
+	Attempts int                 // Tries allowed, including the first
+	Delay    time.Duration       // Wait after the first wrong password, doubled after each further one
+	OnRetry  func(remaining int) // Called before prompting again, may be nil
+}
+
+// DefaultRetryPolicy returns the policy used for typed passwords
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		Attempts: constants.PasswordAttempts,
+		Delay:    constants.PasswordRetryDelay,
+	}
+}
+
+// Run calls attempt with password and, while the header rejects it, with passwords
+// from prompt until the attempts are used up. Only a rejected password is retried,
+// and since the header is verified before any output is created, each retry costs
+// no more than the key derivation
+func (r RetryPolicy) Run(password string, prompt func() (string, error), attempt func(password string) error) error {
+	attempts := max(r.Attempts, 1)
+	delay := r.Delay
+
+	for tries := 1; ; tries++ {
+		err := attempt(password)
+		if !errors.Is(err, constants.ErrAuthFailure) {
+			return err
+		}
+		if tries >= attempts {
+			return fmt.Errorf("%w after %d attempts: %w", constants.ErrTooManyAttempts, tries, err)
+		}
+
+		// Slow down guessing; the KDF alone is not a rate limit
+		time.Sleep(delay)
+		delay *= 2
+
+		if r.OnRetry != nil {
+			r.OnRetry(attempts - tries)
+		}
+		if password, err = prompt(); err != nil {
+			return err
+		}
+	}
+}
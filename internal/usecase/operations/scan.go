@@ -0,0 +1,251 @@
+package operations
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/hambosto/hexwarden/internal/constants"
+	"github.com/hambosto/hexwarden/internal/data/streaming"
+	"github.com/hambosto/hexwarden/internal/infrastructure/crypto"
+	"github.com/hambosto/hexwarden/internal/infrastructure/encoding"
+)
+
+// ChunkReport describes a chunk with corrupt shards or that could not be recovered
+type ChunkReport struct {
+	Index     int   // Position of the chunk in the file, starting at 0
+	Offset    int64 // Byte offset of the chunk's size prefix, counted from the end of the header
+	Corrupted []int // Reed-Solomon shards found corrupt
+	Err       error // Set when the chunk could not be recovered
+}
+
+// ScanReport summarises a scan or verify run over an encrypted file
+type ScanReport struct {
+	Chunks    int           // Number of chunks checked
+	Decrypted bool          // Whether chunks were also decrypted and authenticated
+	RawBody   bool          // Body has no chunks or Reed-Solomon parity to check
+	Log       bool          // Body is an append-only log, whose records carry no parity
+	Records   uint64        // Records found in an append-only log
+	Problems  []ChunkReport // Chunks that were damaged or failed, in file order
+}
+
+// ScanOptions configures a scan or verify run
+type ScanOptions struct {
+	NoReconstruct bool // Only verify parity; chunks that fail are reported instead of decoded
+}
+
+// Clean returns the number of chunks whose shards all passed verification
+func (r *ScanReport) Clean() int {
+	return r.Chunks - len(r.Problems)
+}
+
+// Damaged returns the number of chunks with corrupt shards that still checked out
+func (r *ScanReport) Damaged() int {
+	var n int
+	for _, c := range r.Problems {
+		if c.Err == nil {
+			n++
+		}
+	}
+	return n
+}
+
+// Failed returns the number of chunks that could not be recovered
+func (r *ScanReport) Failed() int {
+	return len(r.Problems) - r.Damaged()
+}
+
+// Scan checks the Reed-Solomon parity of every chunk without a password,
+// reporting which shards are corrupt. It cannot detect tampering
+func (d *Decryptor) Scan(srcPath string) (*ScanReport, error) {
+	return d.ScanWithOptions(srcPath, ScanOptions{})
+}
+
+// ScanWithOptions scans like Scan with the given options
+func (d *Decryptor) ScanWithOptions(srcPath string, opts ScanOptions) (*ScanReport, error) {
+	srcFile, _, err := d.fileManager.OpenFile(srcPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open source file: %w", err)
+	}
+	defer srcFile.Close() //nolint:errcheck
+
+	header, err := crypto.ReadHeader(srcFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read header: %w", err)
+	}
+
+	// Without parity there is nothing to check short of decrypting
+	if header.Flags()&constants.FlagRawBody != 0 {
+		return &ScanReport{RawBody: true}, nil
+	}
+	if header.Flags()&constants.FlagLog != 0 {
+		report := &ScanReport{Log: true}
+		report.Records, err = forEachRecord(srcFile, nil)
+		return report, err
+	}
+
+	chunks, err := chunkArea(srcFile, header)
+	if err != nil {
+		return nil, err
+	}
+
+	encoder, err := encoding.NewDefaultEncoder()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create encoder: %w", err)
+	}
+	encoder.SetReconstruct(!opts.NoReconstruct)
+	encoder.SetZeroCopy(true) // The decoded data is discarded
+
+	report := &ScanReport{}
+	return report, checkChunks(chunks, header, report, func(chunk []byte) (encoding.DecodeReport, error) {
+		_, result, err := encoder.DecodeVerbose(chunk)
+		return result, err
+	})
+}
+
+// Verify decrypts every chunk in memory without writing the plaintext,
+// reporting which shards were found corrupt along the way
+func (d *Decryptor) Verify(srcPath, password string) (*ScanReport, error) {
+	return d.VerifyWithOptions(srcPath, password, ScanOptions{})
+}
+
+// VerifyWithOptions verifies like Verify with the given options
+func (d *Decryptor) VerifyWithOptions(srcPath, password string, opts ScanOptions) (*ScanReport, error) {
+	srcFile, _, err := d.fileManager.OpenFile(srcPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open source file: %w", err)
+	}
+	defer srcFile.Close() //nolint:errcheck
+
+	header, key, err := d.readHeader(srcFile, password)
+	if err != nil {
+		return nil, err
+	}
+	if err := header.CheckAAD(d.aad); err != nil {
+		return nil, err
+	}
+
+	if header.Flags()&constants.FlagRawBody != 0 {
+		report := &ScanReport{Decrypted: true, RawBody: true}
+		if err := decryptRawBody(srcFile, io.Discard, header.OriginalSize(), key, header.Cipher(), d.aad); err != nil {
+			report.Problems = append(report.Problems, ChunkReport{Err: err})
+			return report, fmt.Errorf("%w: %v", constants.ErrUnrecoverable, err)
+		}
+		return report, nil
+	}
+	if header.Flags()&constants.FlagLog != 0 {
+		report := &ScanReport{Decrypted: true, Log: true}
+		if report.Records, err = decryptLog(srcFile, io.Discard, key); err != nil {
+			report.Problems = append(report.Problems, ChunkReport{Index: int(report.Records), Err: err})
+			return report, fmt.Errorf("%w: %v", constants.ErrUnrecoverable, err)
+		}
+		return report, nil
+	}
+
+	chunks, err := chunkArea(srcFile, header)
+	if err != nil {
+		return nil, err
+	}
+
+	processor, err := newBodyProcessor(header, key, d.aad)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create processor: %w", err)
+	}
+	processor.SetReconstruct(!opts.NoReconstruct)
+
+	var total uint64
+	report := &ScanReport{Decrypted: true}
+	err = checkChunks(chunks, header, report, func(chunk []byte) (encoding.DecodeReport, error) {
+		plaintext, result, err := processor.DecryptVerbose(chunk)
+		total += uint64(len(plaintext))
+		return result, err
+	})
+	if err != nil {
+		return report, err
+	}
+
+	declared := header.OriginalSize()
+	if header.Flags()&constants.FlagSizeFooter != 0 {
+		if declared, err = footerSize(srcFile, key); err != nil {
+			return report, err
+		}
+	}
+	if total > uint64(maxPlaintextSize(declared)) {
+		return report, fmt.Errorf("%w: decrypted %d bytes, %d declared", constants.ErrDecompressionBomb, total, declared)
+	}
+	if total != declared {
+		return report, fmt.Errorf("%w: decrypted %d of %d bytes", constants.ErrDecryptionFailed, total, declared)
+	}
+	if isPadded(header) {
+		return report, checkOutputPadding(srcFile, key)
+	}
+	return report, nil
+}
+
+// chunkArea returns the chunks following the header of srcFile. The members of a
+// bundle are consecutive chunk streams, so they are checked as one, up to the
+// index. A size footer and its marker are left out
+func chunkArea(srcFile *os.File, header *crypto.Header) (io.Reader, error) {
+	if header.Flags()&constants.FlagSizeFooter != 0 {
+		info, err := srcFile.Stat()
+		if err != nil {
+			return nil, fmt.Errorf("failed to stat source file: %w", err)
+		}
+		length := info.Size() - int64(header.Size()) - constants.ChunkHeaderSize - constants.SizeFooterSize
+		if length < 0 {
+			return nil, constants.ErrMissingFooter
+		}
+		return io.LimitReader(srcFile, length), nil
+	}
+	if header.Flags()&constants.FlagBundle == 0 {
+		return srcFile, nil
+	}
+
+	length, err := bundleChunksLength(srcFile, header)
+	if err != nil {
+		return nil, err
+	}
+	return io.LimitReader(srcFile, length), nil
+}
+
+// checkChunks runs check on every chunk in src, framed as header describes,
+// recording damaged and failed chunks in report. A framing error ends the run
+// since later chunks cannot be located. The output padding of a padded file is
+// left unread after the chunks
+func checkChunks(src io.Reader, header *crypto.Header, report *ScanReport, check func([]byte) (encoding.DecodeReport, error)) error {
+	chunks, err := streaming.NewChunkReader(src, header.Version())
+	if err != nil {
+		return err
+	}
+	if isPadded(header) {
+		chunks.ExpectFooter()
+	}
+
+	for {
+		chunk, err := chunks.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			report.Problems = append(report.Problems, ChunkReport{Index: report.Chunks, Offset: chunks.Offset(), Err: err})
+			report.Chunks++
+			break
+		}
+
+		result, err := check(chunk)
+		if err != nil || result.Damaged() {
+			report.Problems = append(report.Problems, ChunkReport{
+				Index:     report.Chunks,
+				Offset:    chunks.Offset(),
+				Corrupted: result.Corrupted,
+				Err:       err,
+			})
+		}
+		report.Chunks++
+	}
+
+	if report.Failed() > 0 {
+		return fmt.Errorf("%w: %d of %d chunk(s) failed", constants.ErrUnrecoverable, report.Failed(), report.Chunks)
+	}
+	return nil
+}
@@ -0,0 +1,49 @@
+package operations
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/hambosto/hexwarden/internal/constants"
+)
+
+// sizedReader reads exactly the number of bytes declared in the header. A source
+// that grows or shrinks while it is read fails instead of producing a file whose
+// stored size disagrees with its contents
+type sizedReader struct {
+	reader    io.Reader
+	declared  int64
+	remaining int64
+}
+
+// newSizedReader wraps reader so it must yield exactly size bytes
+func newSizedReader(reader io.Reader, size int64) *sizedReader {
+	return &sizedReader{reader: reader, declared: size, remaining: size}
+}
+
+// Read passes through up to the declared size and checks the source ends there
+func (r *sizedReader) Read(p []byte) (int, error) {
+	if r.remaining == 0 {
+		// Probe for data beyond the declared size
+		var extra [1]byte
+		n, err := r.reader.Read(extra[:])
+		if n > 0 {
+			return 0, fmt.Errorf("%w: grew beyond %d bytes", constants.ErrSourceChanged, r.declared)
+		}
+		if err == nil {
+			return 0, nil
+		}
+		return 0, err
+	}
+
+	if int64(len(p)) > r.remaining {
+		p = p[:r.remaining]
+	}
+
+	n, err := r.reader.Read(p)
+	r.remaining -= int64(n)
+	if err == io.EOF && r.remaining > 0 {
+		return n, fmt.Errorf("%w: shrank to %d of %d bytes", constants.ErrSourceChanged, r.declared-r.remaining, r.declared)
+	}
+	return n, err
+}
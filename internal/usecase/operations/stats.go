@@ -0,0 +1,15 @@
+package operations
+
+import "github.com/hambosto/hexwarden/internal/data/streaming"
+
+// Stats returns the worker pool utilization summed over every chunked
+// encryption this encryptor has run
+func (e *Encryptor) Stats() streaming.PoolStats {
+	return e.stats
+}
+
+// Stats returns the worker pool utilization summed over every chunked
+// decryption this decryptor has run
+func (d *Decryptor) Stats() streaming.PoolStats {
+	return d.stats
+}
@@ -0,0 +1,15 @@
+package operations
+
+import "time"
+
+// SetIOTimeout makes encryption give up with ErrStalled when no bytes are read
+// or written for timeout; 0 waits forever
+func (e *Encryptor) SetIOTimeout(timeout time.Duration) {
+	e.idleTimeout = max(timeout, 0)
+}
+
+// SetIOTimeout makes decryption give up with ErrStalled when no bytes are read
+// or written for timeout; 0 waits forever
+func (d *Decryptor) SetIOTimeout(timeout time.Duration) {
+	d.idleTimeout = max(timeout, 0)
+}
@@ -0,0 +1,96 @@
+package operations
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"embed"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/hambosto/hexwarden/internal/constants"
+)
+
+// vectorFiles holds encrypted files written by earlier versions, with the
+// password and plaintext digest of each listed in vectors.json
+//
+//go:embed vectors
+var vectorFiles embed.FS
+
+// Vector is a known-answer test: an encrypted file whose plaintext is known
+type Vector struct {
+	File        string `json:"file"`
+	Description string `json:"description"`
+	Password    string `json:"password"`
+	Version     uint8  `json:"version"` // Header format version the file was written with
+	Size        int64  `json:"size"`    // Plaintext size
+	SHA256      string `json:"sha256"`  // Hex SHA-256 of the plaintext
+}
+
+// VectorResult is the outcome of decrypting one vector
+type VectorResult struct {
+	Vector Vector
+	Err    error // nil when the vector decrypted to its recorded plaintext
+}
+
+// KnownAnswerVectors returns the vectors built into the binary
+func KnownAnswerVectors() ([]Vector, error) {
+	data, err := vectorFiles.ReadFile("vectors/vectors.json")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read vector list: %w", err)
+	}
+	var vectors []Vector
+	if err := json.Unmarshal(data, &vectors); err != nil {
+		return nil, fmt.Errorf("failed to parse vector list: %w", err)
+	}
+	return vectors, nil
+}
+
+// CheckVectors decrypts every built-in vector and compares the header version
+// and plaintext with the recorded ones, so a change that stops older files from
+// decrypting is caught. The returned error wraps ErrVectorMismatch when any fails
+func (d *Decryptor) CheckVectors() ([]VectorResult, error) {
+	vectors, err := KnownAnswerVectors()
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]VectorResult, len(vectors))
+	var failed int
+	for i, vector := range vectors {
+		results[i] = VectorResult{Vector: vector, Err: d.checkVector(vector)}
+		if results[i].Err != nil {
+			failed++
+		}
+	}
+	if failed > 0 {
+		return results, fmt.Errorf("%w: %d of %d vector(s) failed", constants.ErrVectorMismatch, failed, len(vectors))
+	}
+	return results, nil
+}
+
+// checkVector decrypts one vector in memory and compares it with the recorded plaintext
+func (d *Decryptor) checkVector(vector Vector) error {
+	data, err := vectorFiles.ReadFile("vectors/" + vector.File)
+	if err != nil {
+		return fmt.Errorf("failed to read vector: %w", err)
+	}
+
+	digest := sha256.New()
+	counter := &countingWriter{}
+	info, err := d.DecryptStream(bytes.NewReader(data), io.MultiWriter(digest, counter), vector.Password)
+	if err != nil {
+		return err
+	}
+	if info.Version != vector.Version {
+		return fmt.Errorf("%w: format version %d, recorded %d", constants.ErrVectorMismatch, info.Version, vector.Version)
+	}
+	if counter.n != vector.Size {
+		return fmt.Errorf("%w: %d plaintext bytes, recorded %d", constants.ErrVectorMismatch, counter.n, vector.Size)
+	}
+	if got := hex.EncodeToString(digest.Sum(nil)); got != vector.SHA256 {
+		return fmt.Errorf("%w: plaintext SHA-256 %s, recorded %s", constants.ErrVectorMismatch, got, vector.SHA256)
+	}
+	return nil
+}
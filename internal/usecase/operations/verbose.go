@@ -0,0 +1,31 @@
+package operations
+
+import (
+	"log/slog"
+	"time"
+)
+
+// SetLogger makes the encryptor log stage timings at debug level to logger;
+// nil turns logging off
+func (e *Encryptor) SetLogger(logger *slog.Logger) {
+	e.logger = loggerOrDiscard(logger)
+}
+
+// SetLogger makes the decryptor log stage timings at debug level to logger;
+// nil turns logging off
+func (d *Decryptor) SetLogger(logger *slog.Logger) {
+	d.logger = loggerOrDiscard(logger)
+}
+
+// loggerOrDiscard returns logger, or one that drops everything when it is nil
+func loggerOrDiscard(logger *slog.Logger) *slog.Logger {
+	if logger == nil {
+		return slog.New(slog.DiscardHandler)
+	}
+	return logger
+}
+
+// logStage logs how long a pipeline stage took since start
+func logStage(logger *slog.Logger, stage string, start time.Time) {
+	logger.Debug("stage finished", "stage", stage, "elapsed", time.Since(start).Round(time.Microsecond))
+}
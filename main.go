@@ -13,7 +13,7 @@ func main() {
 		// Initialize and execute CLI commands
 		cliApp := cli.NewCLI()
 		if err := cliApp.Execute(); err != nil {
-			os.Exit(1)
+			os.Exit(cli.ExitCode(err))
 		}
 	} else {
 		// No arguments provided, default to interactive mode
@@ -0,0 +1,105 @@
+package business
+
+import (
+	"bytes"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/hambosto/hexwarden/internal/constants"
+	"github.com/hambosto/hexwarden/internal/usecase/operations"
+	"github.com/hambosto/hexwarden/tests/helpers"
+)
+
+func TestAAD_MatchingAndMismatching(t *testing.T) {
+	chunked := bytes.Repeat([]byte("bound to dataset 42 "), constants.DefaultChunkSize/8)
+	small := []byte("small file bound to a context")
+	context := []byte("dataset:42")
+
+	tests := []struct {
+		name    string
+		data    []byte
+		opts    operations.EncryptOptions
+		aad     []byte
+		wantErr error
+	}{
+		{name: "Matching chunks", data: chunked, opts: operations.EncryptOptions{AAD: context}, aad: context},
+		{name: "Matching raw body", data: small, opts: operations.EncryptOptions{AAD: context, SmallFileThreshold: smallFileThreshold}, aad: context},
+		{name: "Matching XChaCha20", data: chunked, opts: operations.EncryptOptions{AAD: context, Cipher: constants.CipherXChaCha20Poly1305}, aad: context},
+		{name: "Different context", data: chunked, opts: operations.EncryptOptions{AAD: context}, aad: []byte("dataset:43"), wantErr: constants.ErrAADMismatch},
+		{name: "Missing context", data: small, opts: operations.EncryptOptions{AAD: context, SmallFileThreshold: smallFileThreshold}, wantErr: constants.ErrAADMismatch},
+		{name: "Context for an unbound file", data: chunked, aad: context, wantErr: constants.ErrAADMismatch},
+		{name: "Unbound file", data: chunked},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			encrypted := encryptBytes(t, tt.data, tt.opts)
+
+			decryptor := operations.NewDecryptorWithKDF(cheapKDF)
+			decryptor.SetAAD(tt.aad)
+
+			var buf bytes.Buffer
+			info, err := decryptor.DecryptStream(bytes.NewReader(encrypted), &buf, testPassword)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("Expected %v, got %v", tt.wantErr, err)
+				}
+				helpers.AssertEqual(t, 0, buf.Len())
+				return
+			}
+
+			helpers.AssertNoError(t, err)
+			helpers.AssertEqual(t, len(tt.opts.AAD) > 0, info.AAD)
+			helpers.AssertBytesEqual(t, tt.data, buf.Bytes())
+		})
+	}
+}
+
+func TestAAD_OtherDecryptPaths(t *testing.T) {
+	tmpDir := helpers.CreateTempDir(t)
+	defer helpers.CleanupTempDir(t, tmpDir)
+
+	context := []byte("user:alice")
+	plaintext := bytes.Repeat([]byte("range and verify honour the context "), constants.DefaultChunkSize/16)
+	inputPath := filepath.Join(tmpDir, "bound.hex")
+	helpers.WriteFileContent(t, inputPath, encryptBytes(t, plaintext, operations.EncryptOptions{AAD: context}))
+
+	// The context is authenticated by the header but readable without the password
+	info, err := operations.NewDecryptorWithKDF(cheapKDF).Inspect(inputPath, "")
+	helpers.AssertNoError(t, err)
+	helpers.AssertEqual(t, true, info.AAD)
+
+	for _, tt := range []struct {
+		name    string
+		aad     []byte
+		wantErr error
+	}{
+		{name: "Matching", aad: context},
+		{name: "Mismatching", aad: []byte("user:bob"), wantErr: constants.ErrAADMismatch},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			decryptor := operations.NewDecryptorWithKDF(cheapKDF)
+			decryptor.SetAAD(tt.aad)
+
+			var buf bytes.Buffer
+			_, rangeErr := decryptor.DecryptRangeTo(inputPath, &buf, testPassword, operations.ByteRange{Start: 10, End: 20})
+			_, verifyErr := decryptor.Verify(inputPath, testPassword)
+			if tt.wantErr != nil {
+				if !errors.Is(rangeErr, tt.wantErr) || !errors.Is(verifyErr, tt.wantErr) {
+					t.Fatalf("Expected %v, got %v and %v", tt.wantErr, rangeErr, verifyErr)
+				}
+				return
+			}
+
+			helpers.AssertNoError(t, rangeErr)
+			helpers.AssertNoError(t, verifyErr)
+			helpers.AssertBytesEqual(t, plaintext[10:21], buf.Bytes())
+		})
+	}
+
+	_, err = operations.NewEncryptorWithKDF(cheapKDF).AppendRecord(filepath.Join(tmpDir, "events.log.hex"), testPassword, []byte("entry"), operations.EncryptOptions{AAD: context})
+	if !errors.Is(err, constants.ErrInvalidLog) {
+		t.Errorf("Expected %v for a bound log, got %v", constants.ErrInvalidLog, err)
+	}
+}
@@ -0,0 +1,46 @@
+package business
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/hambosto/hexwarden/internal/constants"
+	"github.com/hambosto/hexwarden/internal/infrastructure/crypto"
+	"github.com/hambosto/hexwarden/internal/usecase/operations"
+	"github.com/hambosto/hexwarden/tests/helpers"
+)
+
+func TestArgon2Variant_RecordedInHeader(t *testing.T) {
+	plaintext := []byte("derived with Argon2i")
+	params := crypto.KDFParams{Time: 1, Memory: 8 * 1024, Threads: 1, Variant: constants.Argon2i}
+
+	var out bytes.Buffer
+	_, err := operations.NewEncryptorWithKDF(crypto.DeriveKeyWithParams).EncryptStream(bytes.NewReader(plaintext), &out, int64(len(plaintext)), testPassword, operations.EncryptOptions{KDFParams: params})
+	helpers.AssertNoError(t, err)
+	path := filepath.Join(t.TempDir(), "file.hex")
+	helpers.AssertNoError(t, os.WriteFile(path, out.Bytes(), 0o600))
+
+	decryptor := operations.NewDecryptorWithKDF(crypto.DeriveKeyWithParams)
+	info, err := decryptor.Inspect(path, "")
+	helpers.AssertNoError(t, err)
+	helpers.AssertEqual(t, params, info.KDFParams)
+
+	// Decryption follows the variant the header records
+	var decrypted bytes.Buffer
+	_, err = decryptor.DecryptStream(bytes.NewReader(out.Bytes()), &decrypted, testPassword)
+	helpers.AssertNoError(t, err)
+	helpers.AssertBytesEqual(t, plaintext, decrypted.Bytes())
+
+	// The same costs under Argon2id give another key
+	argon2id := operations.NewDecryptorWithKDF(func(password, salt []byte, params crypto.KDFParams) ([]byte, error) {
+		params.Variant = constants.Argon2id
+		return crypto.DeriveKeyWithParams(password, salt, params)
+	})
+	_, err = argon2id.DecryptStream(bytes.NewReader(out.Bytes()), &bytes.Buffer{}, testPassword)
+	if !errors.Is(err, constants.ErrAuthFailure) {
+		t.Fatalf("Expected %v, got %v", constants.ErrAuthFailure, err)
+	}
+}
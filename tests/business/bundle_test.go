@@ -0,0 +1,191 @@
+package business
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/hambosto/hexwarden/internal/constants"
+	"github.com/hambosto/hexwarden/internal/usecase/operations"
+	"github.com/hambosto/hexwarden/tests/helpers"
+)
+
+// writeBundle bundles the given files, keyed by name, into bundlePath
+func writeBundle(t *testing.T, dir, bundlePath string, files map[string][]byte, order []string) {
+	t.Helper()
+
+	var paths []string
+	for _, name := range order {
+		path := filepath.Join(dir, name)
+		helpers.WriteFileContent(t, path, files[name])
+		paths = append(paths, path)
+	}
+
+	_, err := operations.NewEncryptorWithKDF(cheapKDF).EncryptBundle(paths, bundlePath, testPassword, operations.EncryptOptions{})
+	helpers.AssertNoError(t, err)
+}
+
+func TestBundle_ExtractEachMember(t *testing.T) {
+	tmpDir := helpers.CreateTempDir(t)
+	defer helpers.CleanupTempDir(t, tmpDir)
+
+	files := map[string][]byte{
+		"a.txt":     []byte("first member"),
+		"b.bin":     bytes.Repeat([]byte{0xA5, 0x5A, 0x00}, constants.DefaultChunkSize),
+		"empty.txt": {},
+	}
+	order := []string{"a.txt", "b.bin", "empty.txt"}
+	bundlePath := filepath.Join(tmpDir, "bundle.hex")
+	writeBundle(t, tmpDir, bundlePath, files, order)
+
+	decryptor := operations.NewDecryptorWithKDF(cheapKDF)
+
+	members, err := decryptor.ListBundle(bundlePath, testPassword)
+	helpers.AssertNoError(t, err)
+	helpers.AssertEqual(t, len(order), len(members))
+	for i, member := range members {
+		helpers.AssertEqual(t, order[i], member.Name)
+		helpers.AssertEqual(t, int64(len(files[member.Name])), member.Size)
+	}
+
+	// Extract in reverse order to show members do not depend on each other
+	for i := len(order) - 1; i >= 0; i-- {
+		name := order[i]
+		t.Run(name, func(t *testing.T) {
+			outPath := filepath.Join(tmpDir, "out-"+name)
+			_, err := decryptor.ExtractMember(bundlePath, name, outPath, testPassword)
+			helpers.AssertNoError(t, err)
+			helpers.AssertBytesEqual(t, files[name], helpers.ReadFileContent(t, outPath))
+
+			var buf bytes.Buffer
+			_, err = decryptor.ExtractMemberTo(bundlePath, name, &buf, testPassword)
+			helpers.AssertNoError(t, err)
+			helpers.AssertBytesEqual(t, files[name], buf.Bytes())
+		})
+	}
+
+	info, err := decryptor.Inspect(bundlePath, testPassword)
+	helpers.AssertNoError(t, err)
+	helpers.AssertEqual(t, true, info.Bundle)
+	helpers.AssertEqual(t, len(order), len(info.Members))
+}
+
+func TestBundle_Failures(t *testing.T) {
+	tmpDir := helpers.CreateTempDir(t)
+	defer helpers.CleanupTempDir(t, tmpDir)
+
+	files := map[string][]byte{"a.txt": []byte("alpha"), "b.txt": []byte("bravo")}
+	bundlePath := filepath.Join(tmpDir, "bundle.hex")
+	writeBundle(t, tmpDir, bundlePath, files, []string{"a.txt", "b.txt"})
+	original := helpers.ReadFileContent(t, bundlePath)
+
+	decryptor := operations.NewDecryptorWithKDF(cheapKDF)
+	outPath := filepath.Join(tmpDir, "out.txt")
+
+	tests := []struct {
+		name     string
+		data     func() []byte
+		run      func() error
+		expected error
+	}{
+		{
+			name: "Unknown member",
+			run: func() error {
+				_, err := decryptor.ExtractMember(bundlePath, "c.txt", outPath, testPassword)
+				return err
+			},
+			expected: constants.ErrMemberNotFound,
+		},
+		{
+			name: "Wrong password",
+			run: func() error {
+				_, err := decryptor.ExtractMember(bundlePath, "a.txt", outPath, "wrong password")
+				return err
+			},
+			expected: constants.ErrAuthFailure,
+		},
+		{
+			name: "Whole-file decrypt refused",
+			run: func() error {
+				_, err := decryptor.DecryptStream(bytes.NewReader(original), io.Discard, testPassword)
+				return err
+			},
+			expected: constants.ErrBundle,
+		},
+		{
+			name: "Tampered index",
+			data: func() []byte {
+				data := bytes.Clone(original)
+				data[len(data)-constants.BundleTrailerSize-1] ^= 0xFF
+				return data
+			},
+			run:      func() error { _, err := decryptor.ListBundle(bundlePath, testPassword); return err },
+			expected: constants.ErrInvalidBundle,
+		},
+		{
+			name: "Truncated index",
+			data: func() []byte {
+				return original[:len(original)-constants.BundleTrailerSize-2]
+			},
+			run:      func() error { _, err := decryptor.ListBundle(bundlePath, testPassword); return err },
+			expected: constants.ErrInvalidBundle,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := original
+			if tt.data != nil {
+				data = tt.data()
+			}
+			helpers.WriteFileContent(t, bundlePath, data)
+
+			err := tt.run()
+			if !errors.Is(err, tt.expected) {
+				t.Fatalf("Expected %v, got %v", tt.expected, err)
+			}
+			helpers.AssertFileNotExists(t, outPath)
+		})
+	}
+}
+
+func TestBundle_ScanAndVerify(t *testing.T) {
+	tmpDir := helpers.CreateTempDir(t)
+	defer helpers.CleanupTempDir(t, tmpDir)
+
+	files := map[string][]byte{"a.txt": []byte("alpha"), "b.txt": bytes.Repeat([]byte("bravo"), 1000)}
+	bundlePath := filepath.Join(tmpDir, "bundle.hex")
+	writeBundle(t, tmpDir, bundlePath, files, []string{"a.txt", "b.txt"})
+
+	decryptor := operations.NewDecryptorWithKDF(cheapKDF)
+
+	report, err := decryptor.Scan(bundlePath)
+	helpers.AssertNoError(t, err)
+	helpers.AssertEqual(t, 2, report.Chunks)
+
+	report, err = decryptor.Verify(bundlePath, testPassword)
+	helpers.AssertNoError(t, err)
+	helpers.AssertEqual(t, 2, report.Chunks)
+}
+
+func TestBundle_DuplicateNamesRejected(t *testing.T) {
+	tmpDir := helpers.CreateTempDir(t)
+	defer helpers.CleanupTempDir(t, tmpDir)
+
+	first := filepath.Join(tmpDir, "one", "same.txt")
+	second := filepath.Join(tmpDir, "two", "same.txt")
+	helpers.AssertNoError(t, os.Mkdir(filepath.Dir(first), 0o700))
+	helpers.AssertNoError(t, os.Mkdir(filepath.Dir(second), 0o700))
+	helpers.WriteFileContent(t, first, []byte("one"))
+	helpers.WriteFileContent(t, second, []byte("two"))
+
+	bundlePath := filepath.Join(tmpDir, "bundle.hex")
+	_, err := operations.NewEncryptorWithKDF(cheapKDF).EncryptBundle([]string{first, second}, bundlePath, testPassword, operations.EncryptOptions{})
+	if !errors.Is(err, constants.ErrInvalidBundle) {
+		t.Fatalf("Expected ErrInvalidBundle, got %v", err)
+	}
+	helpers.AssertFileNotExists(t, bundlePath)
+}
@@ -0,0 +1,44 @@
+package business
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/hambosto/hexwarden/internal/constants"
+	"github.com/hambosto/hexwarden/internal/usecase/operations"
+	"github.com/hambosto/hexwarden/tests/helpers"
+)
+
+func TestCipher_ChaCha20RoundTrip(t *testing.T) {
+	chunked := bytes.Repeat([]byte("chacha20 chunk body "), constants.DefaultChunkSize/10)
+
+	tests := []struct {
+		name    string
+		data    []byte
+		opts    operations.EncryptOptions
+		version uint8
+	}{
+		{name: "ChaCha20 chunks", data: chunked, opts: operations.EncryptOptions{Cipher: constants.CipherChaCha20Poly1305}, version: constants.FormatVersion3},
+		{name: "ChaCha20 raw body", data: []byte("small file"), opts: operations.EncryptOptions{Cipher: constants.CipherChaCha20Poly1305, SmallFileThreshold: smallFileThreshold}, version: constants.FormatVersion3},
+		{name: "XChaCha20 chunks", data: chunked, opts: operations.EncryptOptions{Cipher: constants.CipherXChaCha20Poly1305}, version: constants.FormatVersion4},
+		{name: "XChaCha20 raw body", data: []byte("small file"), opts: operations.EncryptOptions{Cipher: constants.CipherXChaCha20Poly1305, SmallFileThreshold: smallFileThreshold}, version: constants.FormatVersion4},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			encrypted := encryptBytes(t, tt.data, tt.opts)
+
+			var buf bytes.Buffer
+			info, err := operations.NewDecryptorWithKDF(cheapKDF).DecryptStream(bytes.NewReader(encrypted), &buf, testPassword)
+			helpers.AssertNoError(t, err)
+			helpers.AssertEqual(t, tt.opts.Cipher, info.Cipher)
+			helpers.AssertEqual(t, tt.version, info.Version)
+			helpers.AssertBytesEqual(t, tt.data, buf.Bytes())
+		})
+	}
+
+	if _, err := operations.NewEncryptorWithKDF(cheapKDF).EncryptStream(bytes.NewReader(nil), io.Discard, 0, testPassword, operations.EncryptOptions{Cipher: constants.CipherAuto}); err == nil {
+		t.Error("Expected an unresolved auto cipher to be rejected")
+	}
+}
@@ -0,0 +1,71 @@
+package business
+
+import (
+	"bytes"
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/hambosto/hexwarden/internal/constants"
+	"github.com/hambosto/hexwarden/internal/usecase/operations"
+	"github.com/hambosto/hexwarden/tests/helpers"
+)
+
+func TestComment_RoundTrip(t *testing.T) {
+	tmpDir := helpers.CreateTempDir(t)
+	defer helpers.CleanupTempDir(t, tmpDir)
+
+	const comment = "Q3 financials, backup copy"
+	inputPath := filepath.Join(tmpDir, "q3.xlsx")
+	encryptedPath := inputPath + constants.FileExtension
+	outputPath := filepath.Join(tmpDir, "q3-restored.xlsx")
+
+	helpers.WriteFileContent(t, inputPath, []byte("spreadsheet contents"))
+	_, err := operations.NewEncryptor().EncryptFile(inputPath, encryptedPath, testPassword, operations.EncryptOptions{Comment: comment})
+	helpers.AssertNoError(t, err)
+
+	if bytes.Contains(helpers.ReadFileContent(t, encryptedPath), []byte(comment)) {
+		t.Fatal("Comment must not be stored in plaintext")
+	}
+
+	decryptor := operations.NewDecryptor()
+
+	// Without the password the comment is known to exist but stays locked
+	info, err := decryptor.Inspect(encryptedPath, "")
+	helpers.AssertNoError(t, err)
+	helpers.AssertEqual(t, true, info.HasComment)
+	helpers.AssertEqual(t, false, info.Unlocked)
+	helpers.AssertEqual(t, "", info.Comment)
+
+	// A wrong password cannot unlock it
+	_, err = decryptor.Inspect(encryptedPath, "wrong password")
+	if !errors.Is(err, constants.ErrAuthFailure) {
+		t.Fatalf("Expected %v, got %v", constants.ErrAuthFailure, err)
+	}
+
+	info, err = decryptor.Inspect(encryptedPath, testPassword)
+	helpers.AssertNoError(t, err)
+	helpers.AssertEqual(t, comment, info.Comment)
+
+	info, err = decryptor.DecryptFile(encryptedPath, outputPath, testPassword)
+	helpers.AssertNoError(t, err)
+	helpers.AssertEqual(t, comment, info.Comment)
+	helpers.AssertBytesEqual(t, []byte("spreadsheet contents"), helpers.ReadFileContent(t, outputPath))
+}
+
+func TestComment_TooLongRejectedBeforeWriting(t *testing.T) {
+	tmpDir := helpers.CreateTempDir(t)
+	defer helpers.CleanupTempDir(t, tmpDir)
+
+	inputPath := filepath.Join(tmpDir, "input.txt")
+	encryptedPath := inputPath + constants.FileExtension
+	helpers.WriteFileContent(t, inputPath, []byte("data"))
+
+	opts := operations.EncryptOptions{Comment: strings.Repeat("c", constants.MaxCommentLength+1)}
+	_, err := operations.NewEncryptor().EncryptFile(inputPath, encryptedPath, testPassword, opts)
+	if !errors.Is(err, constants.ErrInvalidOption) {
+		t.Fatalf("Expected %v, got %v", constants.ErrInvalidOption, err)
+	}
+	helpers.AssertFileNotExists(t, encryptedPath)
+}
@@ -0,0 +1,82 @@
+package business
+
+import (
+	"bytes"
+	"path/filepath"
+	"testing"
+
+	"github.com/hambosto/hexwarden/internal/constants"
+	"github.com/hambosto/hexwarden/internal/usecase/operations"
+	"github.com/hambosto/hexwarden/tests/helpers"
+)
+
+func TestCompression_RawDeflateRoundTrip(t *testing.T) {
+	chunkSize := constants.DefaultChunkSize
+	plaintext := bytes.Repeat([]byte("raw deflate chunk "), 3*chunkSize/18)
+
+	rawOpts := operations.EncryptOptions{Compression: constants.CompressionRawDeflate}
+	raw := encryptBytes(t, plaintext, rawOpts)
+	zipped := encryptBytes(t, plaintext, operations.EncryptOptions{Comment: "same header version"})
+	if len(raw) >= len(zipped) {
+		t.Errorf("Expected raw deflate to be smaller than gzip, got %d and %d bytes", len(raw), len(zipped))
+	}
+
+	var buf bytes.Buffer
+	info, err := operations.NewDecryptorWithKDF(cheapKDF).DecryptStream(bytes.NewReader(raw), &buf, testPassword)
+	helpers.AssertNoError(t, err)
+	helpers.AssertBytesEqual(t, plaintext, buf.Bytes())
+	helpers.AssertEqual(t, constants.CompressionRawDeflate, info.Compression)
+	helpers.AssertEqual(t, constants.FormatVersion3, info.Version)
+
+	// Single chunks are decompressed with the recorded format too
+	tmpDir := helpers.CreateTempDir(t)
+	defer helpers.CleanupTempDir(t, tmpDir)
+	path := filepath.Join(tmpDir, "raw.hex")
+	helpers.WriteFileContent(t, path, raw)
+
+	var part bytes.Buffer
+	r := operations.ByteRange{Start: uint64(chunkSize) - 10, End: uint64(chunkSize) + 10}
+	_, err = operations.NewDecryptorWithKDF(cheapKDF).DecryptRangeTo(path, &part, testPassword, r)
+	helpers.AssertNoError(t, err)
+	helpers.AssertBytesEqual(t, plaintext[r.Start:r.End+1], part.Bytes())
+
+	report, err := operations.NewDecryptorWithKDF(cheapKDF).Verify(path, testPassword)
+	helpers.AssertNoError(t, err)
+	helpers.AssertEqual(t, report.Chunks, report.Clean())
+}
+
+func TestCompression_StoredRoundTrip(t *testing.T) {
+	chunkSize := constants.DefaultChunkSize
+	plaintext := bytes.Repeat([]byte("stored chunk "), 3*chunkSize/13)
+
+	stored := encryptBytes(t, plaintext, operations.EncryptOptions{Compression: constants.CompressionStored})
+	zipped := encryptBytes(t, plaintext, operations.EncryptOptions{})
+	if len(stored) <= len(zipped) {
+		t.Errorf("Expected stored chunks to skip compression, got %d and %d bytes", len(stored), len(zipped))
+	}
+
+	var buf bytes.Buffer
+	info, err := operations.NewDecryptorWithKDF(cheapKDF).DecryptStream(bytes.NewReader(stored), &buf, testPassword)
+	helpers.AssertNoError(t, err)
+	helpers.AssertBytesEqual(t, plaintext, buf.Bytes())
+	helpers.AssertEqual(t, constants.CompressionStored, info.Compression)
+
+	tmpDir := helpers.CreateTempDir(t)
+	defer helpers.CleanupTempDir(t, tmpDir)
+	path := filepath.Join(tmpDir, "stored.hex")
+	helpers.WriteFileContent(t, path, stored)
+
+	var part bytes.Buffer
+	r := operations.ByteRange{Start: uint64(chunkSize) - 10, End: uint64(chunkSize) + 10}
+	_, err = operations.NewDecryptorWithKDF(cheapKDF).DecryptRangeTo(path, &part, testPassword, r)
+	helpers.AssertNoError(t, err)
+	helpers.AssertBytesEqual(t, plaintext[r.Start:r.End+1], part.Bytes())
+
+	// Stored chunks can be recompressed like any other format
+	decryptor := operations.NewDecryptorWithKDF(cheapKDF)
+	helpers.AssertNoError(t, decryptor.Recompress(path, testPassword, constants.CompressionGzip))
+	buf.Reset()
+	_, err = decryptor.DecryptStream(bytes.NewReader(helpers.ReadFileContent(t, path)), &buf, testPassword)
+	helpers.AssertNoError(t, err)
+	helpers.AssertBytesEqual(t, plaintext, buf.Bytes())
+}
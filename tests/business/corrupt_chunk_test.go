@@ -0,0 +1,81 @@
+package business
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/hambosto/hexwarden/internal/constants"
+	"github.com/hambosto/hexwarden/internal/usecase/operations"
+	"github.com/hambosto/hexwarden/tests/helpers"
+)
+
+func TestStream_IgnoreCorruptChunks(t *testing.T) {
+	chunkSize := constants.DefaultChunkSize
+	plaintext := make([]byte, 3*chunkSize+100)
+	for i := range plaintext {
+		plaintext[i] = byte(i*13 + i/chunkSize)
+	}
+
+	encrypted := encryptBytes(t, plaintext, operations.EncryptOptions{})
+	header := encrypted[:constants.TotalHeaderSize]
+	frames := splitFrames(t, encrypted)
+	helpers.AssertEqual(t, 4, len(frames))
+
+	tests := []struct {
+		name   string
+		chunk  int
+		offset int64
+		length int64
+	}{
+		{name: "Middle chunk", chunk: 1, offset: int64(chunkSize), length: int64(chunkSize)},
+		{name: "Short last chunk", chunk: 3, offset: int64(3 * chunkSize), length: 100},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Every shard of the chunk is damaged, far beyond what parity can repair
+			stream := append([]byte(nil), header...)
+			for i, frame := range frames {
+				if i == tt.chunk {
+					frame = bytes.Clone(frame)
+					for j := constants.ChunkHeaderSize; j < len(frame); j++ {
+						frame[j] ^= 0x5a
+					}
+				}
+				stream = append(stream, frame...)
+			}
+
+			decryptor := operations.NewDecryptorWithKDF(cheapKDF)
+			if _, err := decryptor.DecryptStream(bytes.NewReader(stream), &bytes.Buffer{}, testPassword); err == nil {
+				t.Fatal("Expected a corrupt chunk to fail by default")
+			}
+
+			decryptor.SetIgnoreCorruptChunks(true)
+			var buf bytes.Buffer
+			info, err := decryptor.DecryptStream(bytes.NewReader(stream), &buf, testPassword)
+			helpers.AssertNoError(t, err)
+
+			helpers.AssertEqual(t, 1, len(info.Holes))
+			helpers.AssertEqual(t, tt.offset, info.Holes[0].Offset)
+			helpers.AssertEqual(t, tt.length, info.Holes[0].Length)
+			if info.Holes[0].Err == nil {
+				t.Error("Expected the hole to record why the chunk failed")
+			}
+
+			expected := bytes.Clone(plaintext)
+			clear(expected[tt.offset : tt.offset+tt.length])
+			helpers.AssertBytesEqual(t, expected, buf.Bytes())
+		})
+	}
+
+	t.Run("Intact file has no holes", func(t *testing.T) {
+		decryptor := operations.NewDecryptorWithKDF(cheapKDF)
+		decryptor.SetIgnoreCorruptChunks(true)
+
+		var buf bytes.Buffer
+		info, err := decryptor.DecryptStream(bytes.NewReader(encrypted), &buf, testPassword)
+		helpers.AssertNoError(t, err)
+		helpers.AssertEqual(t, 0, len(info.Holes))
+		helpers.AssertBytesEqual(t, plaintext, buf.Bytes())
+	})
+}
@@ -0,0 +1,86 @@
+package business
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"path/filepath"
+	"testing"
+
+	"github.com/hambosto/hexwarden/internal/constants"
+	"github.com/hambosto/hexwarden/internal/infrastructure/crypto"
+	"github.com/hambosto/hexwarden/internal/usecase/operations"
+	"github.com/hambosto/hexwarden/tests/helpers"
+)
+
+// craftBomb encrypts plaintext and rewrites the header to declare only
+// declaredSize bytes, keeping the header valid for testPassword
+func craftBomb(t *testing.T, plaintext []byte, declaredSize uint64) []byte {
+	t.Helper()
+
+	src := bytes.NewReader(encryptBytes(t, plaintext, operations.EncryptOptions{}))
+	header, err := crypto.ReadHeader(src)
+	helpers.AssertNoError(t, err)
+
+	key, err := cheapKDF([]byte(testPassword), header.Salt(), header.KDFParams())
+	helpers.AssertNoError(t, err)
+
+	forged, err := crypto.Build(header.Salt(), declaredSize, key)
+	helpers.AssertNoError(t, err)
+
+	var out bytes.Buffer
+	helpers.AssertNoError(t, forged.Write(&out))
+	_, err = io.Copy(&out, src)
+	helpers.AssertNoError(t, err)
+	return out.Bytes()
+}
+
+func TestDecompressionBomb(t *testing.T) {
+	plaintext := make([]byte, 4*constants.DefaultChunkSize) // Zeros compress to a few KB
+
+	tests := []struct {
+		name         string
+		declaredSize uint64
+		expectedErr  error
+	}{
+		{"Declared size matches", uint64(len(plaintext)), nil},
+		{"Within tolerance", uint64(len(plaintext) - constants.DecompressionTolerance), constants.ErrSizeMismatch},
+		{"Overshoots declared size", 1024, constants.ErrDecompressionBomb},
+		{"Overshoots by one chunk", uint64(3 * constants.DefaultChunkSize), constants.ErrDecompressionBomb},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := craftBomb(t, plaintext, tt.declaredSize)
+
+			var out bytes.Buffer
+			_, err := operations.NewDecryptorWithKDF(cheapKDF).DecryptStream(bytes.NewReader(data), &out, testPassword)
+			if !errors.Is(err, tt.expectedErr) {
+				t.Fatalf("Expected %v, got %v", tt.expectedErr, err)
+			}
+			if tt.expectedErr != nil && out.Len() > int(tt.declaredSize)+constants.DecompressionTolerance {
+				t.Fatalf("Wrote %d bytes past the limit", out.Len()-int(tt.declaredSize))
+			}
+		})
+	}
+}
+
+func TestDecompressionBomb_FileOutputRemoved(t *testing.T) {
+	tmpDir := helpers.CreateTempDir(t)
+	defer helpers.CleanupTempDir(t, tmpDir)
+
+	inputPath := filepath.Join(tmpDir, "bomb.hex")
+	outputPath := filepath.Join(tmpDir, "bomb")
+	helpers.WriteFileContent(t, inputPath, craftBomb(t, make([]byte, 2*constants.DefaultChunkSize), 16))
+
+	_, err := operations.NewDecryptorWithKDF(cheapKDF).DecryptFile(inputPath, outputPath, testPassword)
+	if !errors.Is(err, constants.ErrDecompressionBomb) {
+		t.Fatalf("Expected %v, got %v", constants.ErrDecompressionBomb, err)
+	}
+	helpers.AssertFileNotExists(t, outputPath)
+
+	_, err = operations.NewDecryptorWithKDF(cheapKDF).Verify(inputPath, testPassword)
+	if !errors.Is(err, constants.ErrDecompressionBomb) {
+		t.Fatalf("Expected %v, got %v", constants.ErrDecompressionBomb, err)
+	}
+}
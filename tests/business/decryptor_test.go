@@ -0,0 +1,135 @@
+package business
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/hambosto/hexwarden/internal/constants"
+	"github.com/hambosto/hexwarden/internal/infrastructure/crypto"
+	"github.com/hambosto/hexwarden/internal/usecase/operations"
+	"github.com/hambosto/hexwarden/tests/helpers"
+)
+
+const testPassword = "correct horse battery staple"
+
+// lastChunk returns the offset and length of the final chunk's data in an encrypted file
+func lastChunk(t *testing.T, data []byte) (int, int) {
+	t.Helper()
+
+	header, err := crypto.ReadHeader(bytes.NewReader(data))
+	helpers.AssertNoError(t, err)
+
+	offset, last := header.Size(), -1
+	for offset < len(data) {
+		last = offset
+		offset += constants.ChunkHeaderSize + int(binary.BigEndian.Uint32(data[offset:]))
+	}
+	if last < 0 {
+		t.Fatal("Expected encrypted file to contain chunks")
+	}
+
+	start := last + constants.ChunkHeaderSize
+	return start, int(binary.BigEndian.Uint32(data[last:]))
+}
+
+// corruptLastChunk flips the same byte in every shard of the final chunk,
+// more damage than Reed-Solomon can repair
+func corruptLastChunk(t *testing.T, path string) {
+	t.Helper()
+
+	data := helpers.ReadFileContent(t, path)
+	start, length := lastChunk(t, data)
+
+	shardSize := length / (constants.DataShards + constants.ParityShards)
+	for i := 0; i < length; i += shardSize {
+		data[start+i+1] ^= 0xFF
+	}
+	helpers.WriteFileContent(t, path, data)
+}
+
+func TestDecryptFile_PartialOutputIsOverwritten(t *testing.T) {
+	tmpDir := helpers.CreateTempDir(t)
+	defer helpers.CleanupTempDir(t, tmpDir)
+
+	marker := []byte("hexwarden-partial-plaintext-marker\n")
+	plaintext := bytes.Repeat(marker, 8*constants.DefaultChunkSize/len(marker)+1)
+
+	inputPath := filepath.Join(tmpDir, "input.txt")
+	encryptedPath := inputPath + constants.FileExtension
+	outputPath := filepath.Join(tmpDir, "output.txt")
+	linkPath := filepath.Join(tmpDir, "output.link")
+
+	helpers.WriteFileContent(t, inputPath, plaintext)
+	_, err := operations.NewEncryptor().EncryptFile(inputPath, encryptedPath, testPassword, operations.EncryptOptions{})
+	helpers.AssertNoError(t, err)
+	corruptLastChunk(t, encryptedPath)
+
+	// A second link keeps the destination's data reachable after it is unlinked
+	helpers.WriteFileContent(t, outputPath, nil)
+	if err := os.Link(outputPath, linkPath); err != nil {
+		t.Skipf("Hard links not supported: %v", err)
+	}
+
+	_, err = operations.NewDecryptor().DecryptFile(encryptedPath, outputPath, testPassword)
+	if err == nil {
+		t.Fatal("Expected decryption of corrupted file to fail")
+	}
+
+	helpers.AssertFileNotExists(t, outputPath)
+
+	remaining := helpers.ReadFileContent(t, linkPath)
+	if len(remaining) == 0 {
+		t.Skip("No plaintext was flushed before the failure")
+	}
+	if bytes.Contains(remaining, marker) {
+		t.Fatal("Partial plaintext was unlinked without being overwritten")
+	}
+}
+
+func TestDecryptFile_FailuresRunKDF(t *testing.T) {
+	tmpDir := helpers.CreateTempDir(t)
+	defer helpers.CleanupTempDir(t, tmpDir)
+
+	inputPath := filepath.Join(tmpDir, "input.txt")
+	encryptedPath := inputPath + constants.FileExtension
+	helpers.WriteFileContent(t, inputPath, []byte("timing-safe failure"))
+	_, err := operations.NewEncryptor().EncryptFile(inputPath, encryptedPath, testPassword, operations.EncryptOptions{})
+	helpers.AssertNoError(t, err)
+
+	encrypted := helpers.ReadFileContent(t, encryptedPath)
+	corrupted := append([]byte(nil), encrypted...)
+	corrupted[len(constants.MagicBytes)] ^= 0xFF // First salt byte
+	corruptedPath := filepath.Join(tmpDir, "corrupted.hex")
+	helpers.WriteFileContent(t, corruptedPath, corrupted)
+
+	tests := []struct {
+		name        string
+		path        string
+		password    string
+		expectedErr error
+	}{
+		{name: "Wrong password", path: encryptedPath, password: "wrong password", expectedErr: constants.ErrAuthFailure},
+		{name: "Corrupt header", path: corruptedPath, password: testPassword, expectedErr: constants.ErrChecksumMismatch},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls := 0
+			decryptor := operations.NewDecryptorWithKDF(func(password, salt []byte, params crypto.KDFParams) ([]byte, error) {
+				calls++
+				return crypto.DeriveKeyWithParams(password, salt, params)
+			})
+
+			_, err := decryptor.DecryptFile(tt.path, filepath.Join(tmpDir, "output.txt"), tt.password)
+			if !errors.Is(err, tt.expectedErr) {
+				t.Fatalf("Expected %v, got %v", tt.expectedErr, err)
+			}
+			helpers.AssertEqual(t, 1, calls)
+			helpers.AssertFileNotExists(t, filepath.Join(tmpDir, "output.txt"))
+		})
+	}
+}
@@ -0,0 +1,80 @@
+package business
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/hambosto/hexwarden/internal/constants"
+	"github.com/hambosto/hexwarden/internal/usecase/operations"
+	"github.com/hambosto/hexwarden/tests/helpers"
+)
+
+func TestEncryptReference_RoundTrip(t *testing.T) {
+	tmpDir := helpers.CreateTempDir(t)
+	defer helpers.CleanupTempDir(t, tmpDir)
+
+	plaintext := bytes.Repeat([]byte("duplicated content\n"), 10000)
+	inputPath := filepath.Join(tmpDir, "original.txt")
+	targetPath := inputPath + constants.FileExtension
+	helpers.WriteFileContent(t, inputPath, plaintext)
+	helpers.AssertNoError(t, os.Mkdir(filepath.Join(tmpDir, "sub"), 0o750))
+	referencePath := filepath.Join(tmpDir, "sub", "copy.txt.hex")
+
+	encryptor := operations.NewEncryptorWithKDF(cheapKDF)
+	_, err := encryptor.EncryptFile(inputPath, targetPath, testPassword, operations.EncryptOptions{})
+	helpers.AssertNoError(t, err)
+	digest := sha256.Sum256(plaintext)
+	_, err = encryptor.EncryptReference(referencePath, targetPath, digest[:], testPassword, operations.EncryptOptions{})
+	helpers.AssertNoError(t, err)
+
+	decryptor := operations.NewDecryptorWithKDF(cheapKDF)
+	info, err := decryptor.Inspect(referencePath, "")
+	helpers.AssertNoError(t, err)
+	helpers.AssertEqual(t, true, info.Reference)
+
+	outputPath := filepath.Join(tmpDir, "restored.txt")
+	_, err = decryptor.DecryptFile(referencePath, outputPath, testPassword)
+	helpers.AssertNoError(t, err)
+	helpers.AssertBytesEqual(t, plaintext, helpers.ReadFileContent(t, outputPath))
+
+	// A stream has no directory to resolve the target from
+	var out bytes.Buffer
+	_, err = decryptor.DecryptStream(bytes.NewReader(helpers.ReadFileContent(t, referencePath)), &out, testPassword)
+	if !errors.Is(err, constants.ErrReference) {
+		t.Errorf("Expected ErrReference, got %v", err)
+	}
+}
+
+func TestEncryptReference_TargetChanged(t *testing.T) {
+	tmpDir := helpers.CreateTempDir(t)
+	defer helpers.CleanupTempDir(t, tmpDir)
+
+	inputPath := filepath.Join(tmpDir, "original.txt")
+	targetPath := inputPath + constants.FileExtension
+	referencePath := filepath.Join(tmpDir, "copy.txt.hex")
+	helpers.WriteFileContent(t, inputPath, []byte("first version"))
+
+	encryptor := operations.NewEncryptorWithKDF(cheapKDF)
+	_, err := encryptor.EncryptFile(inputPath, targetPath, testPassword, operations.EncryptOptions{})
+	helpers.AssertNoError(t, err)
+	digest := sha256.Sum256([]byte("first version"))
+	_, err = encryptor.EncryptReference(referencePath, targetPath, digest[:], testPassword, operations.EncryptOptions{})
+	helpers.AssertNoError(t, err)
+
+	// The target is replaced by an encryption of other content
+	helpers.AssertNoError(t, os.Remove(targetPath))
+	helpers.WriteFileContent(t, inputPath, []byte("second version"))
+	_, err = encryptor.EncryptFile(inputPath, targetPath, testPassword, operations.EncryptOptions{})
+	helpers.AssertNoError(t, err)
+
+	outputPath := filepath.Join(tmpDir, "restored.txt")
+	_, err = operations.NewDecryptorWithKDF(cheapKDF).DecryptFile(referencePath, outputPath, testPassword)
+	if !errors.Is(err, constants.ErrInvalidReference) {
+		t.Errorf("Expected ErrInvalidReference, got %v", err)
+	}
+	helpers.AssertFileNotExists(t, outputPath)
+}
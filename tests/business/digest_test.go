@@ -0,0 +1,109 @@
+package business
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/hambosto/hexwarden/internal/constants"
+	"github.com/hambosto/hexwarden/internal/usecase/operations"
+	"github.com/hambosto/hexwarden/tests/helpers"
+)
+
+func TestEncryptFile_DigestMatchesOutput(t *testing.T) {
+	tmpDir := helpers.CreateTempDir(t)
+	defer helpers.CleanupTempDir(t, tmpDir)
+
+	inputPath := filepath.Join(tmpDir, "input.txt")
+	encryptedPath := inputPath + constants.FileExtension
+	helpers.WriteFileContent(t, inputPath, bytes.Repeat([]byte("catalogued backup\n"), 100000))
+
+	result, err := operations.NewEncryptor().EncryptFile(inputPath, encryptedPath, testPassword, operations.EncryptOptions{})
+	helpers.AssertNoError(t, err)
+
+	encrypted := helpers.ReadFileContent(t, encryptedPath)
+	expected := sha256.Sum256(encrypted)
+	helpers.AssertBytesEqual(t, expected[:], result.SHA256)
+	helpers.AssertEqual(t, int64(len(encrypted)), result.Size)
+}
+
+func TestEncryptStream_DigestMatchesOutput(t *testing.T) {
+	plaintext := []byte("streamed and hashed in one pass")
+
+	var encrypted bytes.Buffer
+	result, err := operations.NewEncryptor().EncryptStream(
+		bytes.NewReader(plaintext), &encrypted, int64(len(plaintext)), testPassword, operations.EncryptOptions{})
+	helpers.AssertNoError(t, err)
+
+	expected := sha256.Sum256(encrypted.Bytes())
+	helpers.AssertBytesEqual(t, expected[:], result.SHA256)
+}
+
+func TestCheckDigest(t *testing.T) {
+	tmpDir := helpers.CreateTempDir(t)
+	defer helpers.CleanupTempDir(t, tmpDir)
+
+	inputPath := filepath.Join(tmpDir, "input.txt")
+	encryptedPath := inputPath + constants.FileExtension
+	helpers.WriteFileContent(t, inputPath, []byte("digest check data"))
+
+	result, err := operations.NewEncryptor().EncryptFile(inputPath, encryptedPath, testPassword, operations.EncryptOptions{})
+	helpers.AssertNoError(t, err)
+
+	other := sha256.Sum256([]byte("something else"))
+
+	tests := []struct {
+		name        string
+		expected    []byte
+		expectedErr error
+	}{
+		{name: "Matching digest", expected: result.SHA256},
+		{name: "Different digest", expected: other[:], expectedErr: constants.ErrDigestMismatch},
+	}
+
+	decryptor := operations.NewDecryptor()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := decryptor.CheckDigest(encryptedPath, tt.expected)
+			if tt.expectedErr == nil {
+				helpers.AssertNoError(t, err)
+				return
+			}
+			if !errors.Is(err, tt.expectedErr) {
+				t.Fatalf("Expected %v, got %v", tt.expectedErr, err)
+			}
+		})
+	}
+}
+
+func TestParseDigest(t *testing.T) {
+	valid := sha256.Sum256([]byte("x"))
+
+	tests := []struct {
+		name      string
+		value     string
+		expectErr bool
+	}{
+		{name: "Valid lowercase", value: hex.EncodeToString(valid[:])},
+		{name: "Not hex", value: "not-a-digest", expectErr: true},
+		{name: "Wrong length", value: "abcd", expectErr: true},
+		{name: "Empty", value: "", expectErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			digest, err := operations.ParseDigest(tt.value)
+			if tt.expectErr {
+				if err == nil {
+					t.Fatal("Expected an error")
+				}
+				return
+			}
+			helpers.AssertNoError(t, err)
+			helpers.AssertBytesEqual(t, valid[:], digest)
+		})
+	}
+}
@@ -0,0 +1,91 @@
+package business
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/hambosto/hexwarden/internal/constants"
+	"github.com/hambosto/hexwarden/internal/data/streaming"
+	"github.com/hambosto/hexwarden/internal/usecase/operations"
+	"github.com/hambosto/hexwarden/tests/helpers"
+)
+
+// splitFrames returns the body of a file with a fixed-size header as its
+// size-prefixed chunk frames
+func splitFrames(t *testing.T, encrypted []byte) [][]byte {
+	t.Helper()
+	body := encrypted[constants.TotalHeaderSize:]
+
+	chunks, err := streaming.NewChunkReader(bytes.NewReader(body), constants.FormatVersion2)
+	helpers.AssertNoError(t, err)
+
+	var frames [][]byte
+	var starts []int64
+	for {
+		_, err := chunks.Next()
+		if err == io.EOF {
+			break
+		}
+		helpers.AssertNoError(t, err)
+		starts = append(starts, chunks.Offset())
+	}
+	starts = append(starts, int64(len(body)))
+
+	for i := 0; i+1 < len(starts); i++ {
+		frames = append(frames, body[starts[i]:starts[i+1]])
+	}
+	return frames
+}
+
+func TestStream_RepeatedChunksAreSkipped(t *testing.T) {
+	chunkSize := constants.DefaultChunkSize
+	plaintext := make([]byte, 3*chunkSize+100)
+	for i := range plaintext {
+		plaintext[i] = byte(i*7 + i/chunkSize)
+	}
+
+	encrypted := encryptBytes(t, plaintext, operations.EncryptOptions{})
+	header := encrypted[:constants.TotalHeaderSize]
+	frames := splitFrames(t, encrypted)
+	helpers.AssertEqual(t, 4, len(frames))
+
+	tests := []struct {
+		name  string
+		order []int // Frames to write, by index
+	}{
+		{name: "First chunk repeated", order: []int{0, 0, 1, 2, 3}},
+		{name: "Middle chunk repeated", order: []int{0, 1, 1, 2, 3}},
+		{name: "Last chunk repeated", order: []int{0, 1, 2, 3, 3}},
+		{name: "Chunk repeated twice", order: []int{0, 1, 2, 2, 2, 3}},
+		{name: "Every chunk repeated", order: []int{0, 0, 1, 1, 2, 2, 3, 3}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stream := append([]byte(nil), header...)
+			for _, i := range tt.order {
+				stream = append(stream, frames[i]...)
+			}
+
+			var buf bytes.Buffer
+			_, err := operations.NewDecryptorWithKDF(cheapKDF).DecryptStream(bytes.NewReader(stream), &buf, testPassword)
+			helpers.AssertNoError(t, err)
+			helpers.AssertBytesEqual(t, plaintext, buf.Bytes())
+		})
+	}
+
+	// Without encryption identical chunks are legitimate and must all be kept
+	t.Run("Identical unencrypted chunks", func(t *testing.T) {
+		repeated := bytes.Repeat(plaintext[:chunkSize], 3)
+
+		var protected bytes.Buffer
+		_, err := operations.NewEncryptor().ProtectStream(bytes.NewReader(repeated), &protected, int64(len(repeated)))
+		helpers.AssertNoError(t, err)
+
+		var buf bytes.Buffer
+		_, err = operations.NewDecryptor().RecoverStream(&protected, &buf)
+		helpers.AssertNoError(t, err)
+		helpers.AssertBytesEqual(t, repeated, buf.Bytes())
+	})
+}
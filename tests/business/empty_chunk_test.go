@@ -0,0 +1,112 @@
+package business
+
+import (
+	"bytes"
+	"io"
+	"testing"
+	"testing/iotest"
+
+	"github.com/hambosto/hexwarden/internal/constants"
+	"github.com/hambosto/hexwarden/internal/usecase/operations"
+	"github.com/hambosto/hexwarden/tests/helpers"
+)
+
+// pieceReader returns one piece per Read, with io.EOF alongside the last one.
+// Empty pieces are returned as reads of nothing without an error
+type pieceReader struct {
+	pieces [][]byte
+}
+
+func (r *pieceReader) Read(p []byte) (int, error) {
+	if len(r.pieces) == 0 {
+		return 0, io.EOF
+	}
+	n := copy(p, r.pieces[0])
+	r.pieces = r.pieces[1:]
+	if len(r.pieces) == 0 {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+func TestStream_EmptyChunks(t *testing.T) {
+	chunkSize := constants.DefaultChunkSize
+	plaintext := make([]byte, 2*chunkSize+100)
+	for i := range plaintext {
+		plaintext[i] = byte(i*13 + i/chunkSize)
+	}
+	// A zero-length frame, as a writer that flushed an empty chunk would leave
+	emptyFrame := make([]byte, constants.ChunkHeaderSize)
+
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{name: "Trailing partial chunk", data: plaintext},
+		{name: "Whole chunks only", data: plaintext[:2*chunkSize]},
+		{name: "Single chunk", data: plaintext[:chunkSize/2]},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			encrypted := append(encryptBytes(t, tt.data, operations.EncryptOptions{}), emptyFrame...)
+
+			var buf bytes.Buffer
+			_, err := operations.NewDecryptorWithKDF(cheapKDF).DecryptStream(bytes.NewReader(encrypted), &buf, testPassword)
+			helpers.AssertNoError(t, err)
+			helpers.AssertBytesEqual(t, tt.data, buf.Bytes())
+		})
+	}
+
+	t.Run("Empty reads while encrypting", func(t *testing.T) {
+		reader := &pieceReader{pieces: [][]byte{
+			plaintext[:chunkSize],
+			{},
+			plaintext[chunkSize : 2*chunkSize],
+			{},
+			plaintext[2*chunkSize:],
+		}}
+
+		var encrypted bytes.Buffer
+		_, err := operations.NewEncryptorWithKDF(cheapKDF).EncryptStream(reader, &encrypted, int64(len(plaintext)), testPassword, operations.EncryptOptions{})
+		helpers.AssertNoError(t, err)
+
+		var buf bytes.Buffer
+		_, err = operations.NewDecryptorWithKDF(cheapKDF).DecryptStream(&encrypted, &buf, testPassword)
+		helpers.AssertNoError(t, err)
+		helpers.AssertBytesEqual(t, plaintext, buf.Bytes())
+	})
+}
+
+func TestStream_ShortReads(t *testing.T) {
+	chunkSize := constants.DefaultChunkSize
+	plaintext := make([]byte, 3*chunkSize+100)
+	for i := range plaintext {
+		plaintext[i] = byte(i*7 + i/chunkSize)
+	}
+
+	tests := []struct {
+		name   string
+		reader func(io.Reader) io.Reader
+	}{
+		{name: "Half reads", reader: iotest.HalfReader},
+		{name: "One byte reads", reader: iotest.OneByteReader},
+		{name: "Data with EOF", reader: iotest.DataErrReader},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var encrypted bytes.Buffer
+			_, err := operations.NewEncryptorWithKDF(cheapKDF).EncryptStream(tt.reader(bytes.NewReader(plaintext)), &encrypted, int64(len(plaintext)), testPassword, operations.EncryptOptions{})
+			helpers.AssertNoError(t, err)
+
+			// Short reads are gathered into full chunks
+			helpers.AssertEqual(t, 4, len(splitFrames(t, encrypted.Bytes())))
+
+			var buf bytes.Buffer
+			_, err = operations.NewDecryptorWithKDF(cheapKDF).DecryptStream(bytes.NewReader(encrypted.Bytes()), &buf, testPassword)
+			helpers.AssertNoError(t, err)
+			helpers.AssertBytesEqual(t, plaintext, buf.Bytes())
+		})
+	}
+}
@@ -0,0 +1,130 @@
+package business
+
+import (
+	"bytes"
+	"crypto/ecdh"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/hambosto/hexwarden/internal/constants"
+	"github.com/hambosto/hexwarden/internal/infrastructure/crypto"
+	"github.com/hambosto/hexwarden/internal/usecase/operations"
+	"github.com/hambosto/hexwarden/tests/helpers"
+)
+
+// newEscrowKeys generates an escrow key pair and reads it back from PEM, the
+// way the CLI loads the files openssl writes
+func newEscrowKeys(t *testing.T) (*ecdh.PublicKey, *ecdh.PrivateKey) {
+	t.Helper()
+
+	generated, err := ecdh.X25519().GenerateKey(rand.Reader)
+	helpers.AssertNoError(t, err)
+	publicDER, err := x509.MarshalPKIXPublicKey(generated.PublicKey())
+	helpers.AssertNoError(t, err)
+	privateDER, err := x509.MarshalPKCS8PrivateKey(generated)
+	helpers.AssertNoError(t, err)
+
+	public, err := crypto.ParseEscrowPublicKey(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: publicDER}))
+	helpers.AssertNoError(t, err)
+	private, err := crypto.ParseEscrowPrivateKey(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: privateDER}))
+	helpers.AssertNoError(t, err)
+	return public, private
+}
+
+// encryptEscrowed encrypts plaintext with its key escrowed to public
+func encryptEscrowed(t *testing.T, plaintext []byte, public *ecdh.PublicKey) ([]byte, []byte) {
+	t.Helper()
+
+	var out bytes.Buffer
+	result, err := operations.NewEncryptorWithKDF(cheapKDF).EncryptStream(bytes.NewReader(plaintext), &out, int64(len(plaintext)), testPassword, operations.EncryptOptions{EscrowKey: public})
+	helpers.AssertNoError(t, err)
+	return out.Bytes(), result.EscrowedKey
+}
+
+func TestEscrow_UnlocksWithPrivateKey(t *testing.T) {
+	public, private := newEscrowKeys(t)
+	plaintext := bytes.Repeat([]byte("escrowed ledger "), 10000)
+	encrypted, escrowed := encryptEscrowed(t, plaintext, public)
+
+	path := filepath.Join(t.TempDir(), "ledger.escrow")
+	helpers.AssertNoError(t, operations.WriteEscrowedKey(path, escrowed))
+	exported, err := os.ReadFile(path)
+	helpers.AssertNoError(t, err)
+	helpers.AssertBytesEqual(t, escrowed, exported)
+
+	// The raw file key never reaches the exported file
+	header, err := crypto.ReadHeader(bytes.NewReader(encrypted))
+	helpers.AssertNoError(t, err)
+	key, err := cheapKDF([]byte(testPassword), header.Salt(), header.KDFParams())
+	helpers.AssertNoError(t, err)
+	if bytes.Contains(exported, key) {
+		t.Fatal("Expected the exported file not to contain the raw key")
+	}
+
+	salt, unwrapped, err := crypto.OpenEscrowKey(private, exported)
+	helpers.AssertNoError(t, err)
+	helpers.AssertBytesEqual(t, header.Salt(), salt)
+	helpers.AssertBytesEqual(t, key, unwrapped)
+
+	decryptor := operations.NewDecryptorWithKDF(cheapKDF)
+	decryptor.SetEscrowKey(private, exported)
+	var out bytes.Buffer
+	_, err = decryptor.DecryptStream(bytes.NewReader(encrypted), &out, "")
+	helpers.AssertNoError(t, err)
+	helpers.AssertBytesEqual(t, plaintext, out.Bytes())
+}
+
+func TestEscrow_Refused(t *testing.T) {
+	public, private := newEscrowKeys(t)
+	_, otherPrivate := newEscrowKeys(t)
+	plaintext := []byte("escrowed for one file only")
+	encrypted, escrowed := encryptEscrowed(t, plaintext, public)
+	otherFile, _ := encryptEscrowed(t, plaintext, public)
+
+	truncated := escrowed[:len(escrowed)-1]
+	tampered := bytes.Clone(escrowed)
+	tampered[len(tampered)-1] ^= 0x01
+
+	tests := []struct {
+		name     string
+		private  *ecdh.PrivateKey
+		escrowed []byte
+		file     []byte
+		wantErr  error
+	}{
+		{name: "Wrong private key", private: otherPrivate, escrowed: escrowed, file: encrypted, wantErr: constants.ErrEscrowKey},
+		{name: "Truncated escrowed key", private: private, escrowed: truncated, file: encrypted, wantErr: constants.ErrEscrowKey},
+		{name: "Tampered escrowed key", private: private, escrowed: tampered, file: encrypted, wantErr: constants.ErrEscrowKey},
+		{name: "Different file", private: private, escrowed: escrowed, file: otherFile, wantErr: constants.ErrEscrowMismatch},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			decryptor := operations.NewDecryptorWithKDF(cheapKDF)
+			decryptor.SetEscrowKey(tt.private, tt.escrowed)
+			_, err := decryptor.DecryptStream(bytes.NewReader(tt.file), &bytes.Buffer{}, testPassword)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Expected %v, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestEscrow_NothingExportedWithoutKey(t *testing.T) {
+	var out bytes.Buffer
+	result, err := operations.NewEncryptorWithKDF(cheapKDF).EncryptStream(bytes.NewReader([]byte("no escrow")), &out, 9, testPassword, operations.EncryptOptions{})
+	helpers.AssertNoError(t, err)
+	if result.EscrowedKey != nil {
+		t.Fatal("Expected no escrowed key without an escrow public key")
+	}
+
+	err = operations.WriteEscrowedKey(filepath.Join(t.TempDir(), "empty.escrow"), result.EscrowedKey)
+	if !errors.Is(err, constants.ErrEscrowKey) {
+		t.Fatalf("Expected %v, got %v", constants.ErrEscrowKey, err)
+	}
+}
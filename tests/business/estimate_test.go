@@ -0,0 +1,135 @@
+package business
+
+import (
+	"crypto/rand"
+	"testing"
+	"time"
+
+	"github.com/hambosto/hexwarden/internal/constants"
+	"github.com/hambosto/hexwarden/internal/infrastructure"
+	"github.com/hambosto/hexwarden/internal/usecase/operations"
+	"github.com/hambosto/hexwarden/tests/helpers"
+)
+
+func TestEstimateEncryptedSize(t *testing.T) {
+	const header = int64(constants.TotalHeaderSize)
+
+	// One full 1MB chunk: 1048576 + 18 + 5*65 compressed, padded to 1048928,
+	// +28 GCM = 1048956, 262239-byte shards * 14 = 3671346, +4 framing
+	const fullChunk = int64(3671350)
+
+	tests := []struct {
+		name       string
+		sourceSize int64
+		expected   int64
+	}{
+		{name: "Empty file", sourceSize: 0, expected: header},
+		{name: "Single byte", sourceSize: 1, expected: header + 214},
+		{name: "One full chunk", sourceSize: constants.DefaultChunkSize, expected: header + fullChunk},
+		{name: "Two full chunks", sourceSize: 2 * constants.DefaultChunkSize, expected: header + 2*fullChunk},
+		{name: "Full chunk plus one byte", sourceSize: constants.DefaultChunkSize + 1, expected: header + fullChunk + 214},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			helpers.AssertEqual(t, tt.expected, operations.EstimateEncryptedSize(tt.sourceSize))
+		})
+	}
+}
+
+func TestMaxEncryptedSize_BoundsIncompressibleData(t *testing.T) {
+	testData := helpers.NewTestData()
+
+	processor, err := infrastructure.NewProcessor(testData.ValidKey32)
+	helpers.AssertNoError(t, err)
+
+	for _, size := range []int{1, 15, 16, 1000, 4096, 16384, 65535, 65536, 100000, constants.DefaultChunkSize} {
+		data := make([]byte, size)
+		_, err := rand.Read(data)
+		helpers.AssertNoError(t, err)
+
+		encrypted, err := processor.Encrypt(data)
+		helpers.AssertNoError(t, err)
+
+		if bound := infrastructure.MaxEncryptedSize(size); len(encrypted) > bound {
+			t.Errorf("size %d: encrypted %d bytes exceeds bound %d", size, len(encrypted), bound)
+		}
+	}
+}
+
+func TestEstimateFiles(t *testing.T) {
+	const header = int64(constants.TotalHeaderSize)
+	const fullChunk = int64(3671350) // See TestEstimateEncryptedSize
+	probed := operations.Throughput{BytesPerSecond: constants.DefaultChunkSize, KDFDuration: 250 * time.Millisecond}
+
+	tests := []struct {
+		name       string
+		sizes      []int64
+		throughput operations.Throughput
+		expected   operations.Estimate
+	}{
+		{
+			name:       "No files",
+			throughput: probed,
+			expected:   operations.Estimate{},
+		},
+		{
+			name:       "Empty file still derives a key",
+			sizes:      []int64{0},
+			throughput: probed,
+			expected:   operations.Estimate{Files: 1, OutputBytes: header, Overhead: header, KDFRuns: 1, Duration: 250 * time.Millisecond},
+		},
+		{
+			name:       "Mixed sizes",
+			sizes:      []int64{1, constants.DefaultChunkSize, 2 * constants.DefaultChunkSize},
+			throughput: probed,
+			expected: operations.Estimate{
+				Files:       3,
+				InputBytes:  1 + 3*constants.DefaultChunkSize,
+				OutputBytes: 3*header + 214 + 3*fullChunk,
+				Overhead:    3*header + 213 + 3*(fullChunk-constants.DefaultChunkSize),
+				KDFRuns:     3,
+				Duration:    750*time.Millisecond + 3*time.Second + time.Second/constants.DefaultChunkSize,
+			},
+		},
+		{
+			name:       "Unknown throughput counts only key derivations",
+			sizes:      []int64{constants.DefaultChunkSize},
+			throughput: operations.Throughput{KDFDuration: 250 * time.Millisecond},
+			expected: operations.Estimate{
+				Files: 1, InputBytes: constants.DefaultChunkSize, OutputBytes: header + fullChunk, Overhead: header + fullChunk - constants.DefaultChunkSize,
+				KDFRuns: 1, Duration: 250 * time.Millisecond,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			helpers.AssertEqual(t, tt.expected, operations.EstimateFiles(tt.sizes, tt.throughput))
+		})
+	}
+}
+
+func TestContainerOverhead_PredictsEncryptedSize(t *testing.T) {
+	chunkSize := int64(constants.DefaultChunkSize)
+
+	for _, size := range []int64{0, 1, 1000, 100000, chunkSize, chunkSize*2 + chunkSize/2} {
+		plaintext := make([]byte, size)
+		_, err := rand.Read(plaintext)
+		helpers.AssertNoError(t, err)
+
+		actual := int64(len(encryptBytes(t, plaintext, operations.EncryptOptions{})))
+		predicted := size + operations.ContainerOverhead(size, chunkSize, constants.DataShards, constants.ParityShards)
+
+		// Random data does not compress, so only the slack in the gzip block count remains
+		if actual > predicted || predicted-actual > predicted/1000 {
+			t.Errorf("size %d: encrypted %d bytes, predicted %d", size, actual, predicted)
+		}
+		helpers.AssertEqual(t, predicted, operations.EstimateEncryptedSize(size))
+	}
+
+	// 1 byte with 2+2 shards: 1+18+2*5 gzip bytes padded to 32, +28 sealed = 60,
+	// two 30-byte data shards and two parity shards = 120, +4 framing
+	helpers.AssertEqual(t, int64(constants.TotalHeaderSize+123), operations.ContainerOverhead(1, chunkSize, 2, 2))
+	helpers.AssertEqual(t, int64(constants.TotalHeaderSize), operations.ContainerOverhead(0, chunkSize, 2, 2))
+}
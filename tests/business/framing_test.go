@@ -0,0 +1,56 @@
+package business
+
+import (
+	"bytes"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/hambosto/hexwarden/internal/constants"
+	"github.com/hambosto/hexwarden/internal/data/streaming"
+	"github.com/hambosto/hexwarden/internal/usecase/operations"
+	"github.com/hambosto/hexwarden/tests/helpers"
+)
+
+// fixturePassword encrypted the sample-hwx*.hex fixtures in testdata
+const fixturePassword = "fixture password"
+
+func TestDecryptFile_FramingFixtures(t *testing.T) {
+	expected := helpers.ReadFileContent(t, filepath.Join("..", "testdata", "sample.txt"))
+
+	tests := []struct {
+		name            string
+		fixture         string
+		expectedVersion uint8
+	}{
+		{name: "Fixed header", fixture: "sample-hwx2.hex", expectedVersion: constants.FormatVersion2},
+		{name: "Metadata header", fixture: "sample-hwx3.hex", expectedVersion: constants.FormatVersion3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpDir := helpers.CreateTempDir(t)
+			defer helpers.CleanupTempDir(t, tmpDir)
+
+			fixturePath := filepath.Join("..", "testdata", tt.fixture)
+			outputPath := filepath.Join(tmpDir, "sample.txt")
+
+			info, err := operations.NewDecryptor().DecryptFile(fixturePath, outputPath, fixturePassword)
+			helpers.AssertNoError(t, err)
+			helpers.AssertEqual(t, tt.expectedVersion, info.Version)
+
+			if !bytes.Equal(expected, helpers.ReadFileContent(t, outputPath)) {
+				t.Fatal("Decrypted fixture does not match sample.txt")
+			}
+		})
+	}
+}
+
+func TestNewChunkReader_UnknownFormat(t *testing.T) {
+	for _, version := range []uint8{0, 1, 5, 0xFF} {
+		_, err := streaming.NewChunkReader(bytes.NewReader(nil), version)
+		if !errors.Is(err, constants.ErrUnknownFormat) {
+			t.Fatalf("Version %d: expected %v, got %v", version, constants.ErrUnknownFormat, err)
+		}
+	}
+}
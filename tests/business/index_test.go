@@ -0,0 +1,49 @@
+package business
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/hambosto/hexwarden/internal/constants"
+	"github.com/hambosto/hexwarden/internal/usecase/operations"
+)
+
+func TestTreeIndex_Add(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    string
+		path    string
+		wantErr bool
+	}{
+		{name: "Nested path", file: "0123.hex", path: "a/b/c.txt"},
+		{name: "Top-level path", file: "0123.hex", path: "c.txt"},
+		{name: "Parent directory", file: "0123.hex", path: "../c.txt", wantErr: true},
+		{name: "Parent inside path", file: "0123.hex", path: "a/../../c.txt", wantErr: true},
+		{name: "Absolute path", file: "0123.hex", path: "/etc/passwd", wantErr: true},
+		{name: "Backslash separator", file: "0123.hex", path: `a\c.txt`, wantErr: true},
+		{name: "Empty element", file: "0123.hex", path: "a//c.txt", wantErr: true},
+		{name: "Empty path", file: "0123.hex", path: "", wantErr: true},
+		{name: "Encrypted file in a directory", file: "sub/0123.hex", path: "c.txt", wantErr: true},
+		{name: "Encrypted file without extension", file: "0123", path: "c.txt", wantErr: true},
+		{name: "Index as an entry", file: constants.IndexFileName, path: "c.txt", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := operations.NewTreeIndex().Add(tt.file, tt.path)
+			if tt.wantErr != errors.Is(err, constants.ErrInvalidIndex) {
+				t.Errorf("Expected error %v, got %v", tt.wantErr, err)
+			}
+		})
+	}
+
+	index := operations.NewTreeIndex()
+	if err := index.Add("0123.hex", "a.txt"); err != nil {
+		t.Fatal(err)
+	}
+	for _, dup := range [][2]string{{"4567.hex", "a.txt"}, {"0123.hex", "b.txt"}} {
+		if err := index.Add(dup[0], dup[1]); !errors.Is(err, constants.ErrInvalidIndex) {
+			t.Errorf("Expected %v for duplicate %v, got %v", constants.ErrInvalidIndex, dup, err)
+		}
+	}
+}
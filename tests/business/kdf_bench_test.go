@@ -0,0 +1,126 @@
+package business
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/hambosto/hexwarden/internal/constants"
+	"github.com/hambosto/hexwarden/internal/infrastructure/crypto"
+	"github.com/hambosto/hexwarden/internal/usecase/operations"
+	"github.com/hambosto/hexwarden/tests/helpers"
+)
+
+// mockKDFTimer pretends derivation takes 1ms per MiB per pass, split across threads,
+// and records every parameter set it is asked to time
+func mockKDFTimer(timed *[]crypto.KDFParams) operations.KDFTimer {
+	return func(params crypto.KDFParams) (time.Duration, error) {
+		*timed = append(*timed, params)
+		work := time.Duration(params.Memory/1024) * time.Duration(params.Time) * time.Millisecond
+		return work / time.Duration(params.Threads), nil
+	}
+}
+
+func TestKDFBench_RecommendWithinTarget(t *testing.T) {
+	grid := operations.DefaultKDFGrid(1024 * 1024)
+
+	tests := []struct {
+		name     string
+		target   time.Duration
+		expected crypto.KDFParams
+	}{
+		{name: "One second", target: time.Second, expected: crypto.KDFParams{Time: 3, Memory: 1024 * 1024, Threads: 4}},
+		{name: "Quarter second", target: 250 * time.Millisecond, expected: crypto.KDFParams{Time: 3, Memory: 256 * 1024, Threads: 4}},
+		{name: "Only the smallest fits", target: 8 * time.Millisecond, expected: crypto.KDFParams{Time: 1, Memory: 32 * 1024, Threads: 4}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var timed []crypto.KDFParams
+			results, err := operations.BenchmarkKDF(grid, tt.target, mockKDFTimer(&timed), nil)
+			helpers.AssertNoError(t, err)
+			helpers.AssertEqual(t, len(timed), len(results))
+
+			best, err := operations.RecommendKDF(results, tt.target)
+			helpers.AssertNoError(t, err)
+			helpers.AssertEqual(t, tt.expected, best.Params)
+			if best.Duration > tt.target {
+				t.Errorf("Recommended %v takes %s, over the %s target", best.Params, best.Duration, tt.target)
+			}
+		})
+	}
+}
+
+func TestKDFBench_SkipsCostlierSettingsOverTarget(t *testing.T) {
+	grid := operations.KDFGrid{
+		Memory:  []uint32{128 * 1024, 32 * 1024, 64 * 1024},
+		Time:    []uint32{1, 2, 3},
+		Threads: []uint8{1},
+	}
+
+	var timed []crypto.KDFParams
+	results, err := operations.BenchmarkKDF(grid, 100*time.Millisecond, mockKDFTimer(&timed), nil)
+	helpers.AssertNoError(t, err)
+
+	// 32 MiB fits for every pass count, 64 MiB goes over at two passes and
+	// 128 MiB already at one, so nothing costlier is timed after that
+	expected := []crypto.KDFParams{
+		{Time: 1, Memory: 32 * 1024, Threads: 1},
+		{Time: 2, Memory: 32 * 1024, Threads: 1},
+		{Time: 3, Memory: 32 * 1024, Threads: 1},
+		{Time: 1, Memory: 64 * 1024, Threads: 1},
+		{Time: 2, Memory: 64 * 1024, Threads: 1},
+		{Time: 1, Memory: 128 * 1024, Threads: 1},
+	}
+	helpers.AssertEqual(t, len(expected), len(timed))
+	for i := range expected {
+		helpers.AssertEqual(t, expected[i], timed[i])
+	}
+
+	if _, err := operations.RecommendKDF(results, time.Millisecond); !errors.Is(err, constants.ErrNoKDFParams) {
+		t.Errorf("Expected %v, got %v", constants.ErrNoKDFParams, err)
+	}
+}
+
+func TestEncrypt_KDFParamsRecorded(t *testing.T) {
+	plaintext := []byte("derived with custom Argon2id costs")
+
+	tests := []struct {
+		name     string
+		params   crypto.KDFParams
+		expected crypto.KDFParams
+		version  uint8
+	}{
+		{name: "Defaults", expected: crypto.DefaultKDFParams(), version: constants.FormatVersion2},
+		{name: "Custom", params: crypto.KDFParams{Time: 2, Memory: 256 * 1024, Threads: 2}, expected: crypto.KDFParams{Time: 2, Memory: 256 * 1024, Threads: 2}, version: constants.FormatVersion3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var used crypto.KDFParams
+			derive := func(password, salt []byte, params crypto.KDFParams) ([]byte, error) {
+				used = params
+				return cheapKDF(password, salt, params)
+			}
+
+			var encrypted bytes.Buffer
+			_, err := operations.NewEncryptorWithKDF(derive).EncryptStream(bytes.NewReader(plaintext), &encrypted, int64(len(plaintext)), testPassword, operations.EncryptOptions{KDFParams: tt.params})
+			helpers.AssertNoError(t, err)
+			helpers.AssertEqual(t, tt.expected, used)
+
+			var buf bytes.Buffer
+			info, err := operations.NewDecryptorWithKDF(derive).DecryptStream(&encrypted, &buf, testPassword)
+			helpers.AssertNoError(t, err)
+			helpers.AssertEqual(t, tt.expected, info.KDFParams)
+			helpers.AssertEqual(t, tt.expected, used)
+			helpers.AssertEqual(t, tt.version, info.Version)
+			helpers.AssertBytesEqual(t, plaintext, buf.Bytes())
+		})
+	}
+
+	invalid := operations.EncryptOptions{KDFParams: crypto.KDFParams{Time: 1, Memory: 8, Threads: 4}}
+	if err := invalid.Validate(); !errors.Is(err, constants.ErrInvalidKDFParams) {
+		t.Errorf("Expected %v, got %v", constants.ErrInvalidKDFParams, err)
+	}
+}
@@ -0,0 +1,77 @@
+package business
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"math"
+	"testing"
+
+	"github.com/hambosto/hexwarden/internal/constants"
+	"github.com/hambosto/hexwarden/internal/infrastructure/crypto"
+	"github.com/hambosto/hexwarden/internal/usecase/operations"
+	"github.com/hambosto/hexwarden/tests/helpers"
+)
+
+func TestDecrypt_OversizedKDFParamsRefusedBeforeDerivation(t *testing.T) {
+	params := crypto.KDFParams{Time: 2, Memory: 32 * 1024, Threads: 2}
+	encrypted := encryptBytes(t, []byte("crafted cost"), operations.EncryptOptions{KDFParams: params})
+
+	// The TagKDFParams entry as the builder wrote it: tag, length, time, memory, threads
+	entry := []byte{byte(constants.TagKDFParams), 0, 9}
+	entry = binary.BigEndian.AppendUint32(entry, params.Time)
+	entry = binary.BigEndian.AppendUint32(entry, params.Memory)
+	entry = append(entry, params.Threads)
+	at := bytes.Index(encrypted, entry)
+	if at < 0 {
+		t.Fatal("KDF parameters entry not found in the header")
+	}
+	size := headerOf(t, encrypted).Size()
+	core := size - constants.IntegritySize - constants.AuthSize - constants.ChecksumSize
+
+	tests := []struct {
+		name   string
+		time   uint32
+		memory uint32
+	}{
+		{name: "Terabytes of memory", time: 1, memory: math.MaxUint32},
+		{name: "Just over the memory cap", time: 1, memory: constants.MaxArgonMemory + 1},
+		{name: "Four gigabytes of memory", time: 1, memory: 4 * 1024 * 1024},
+		{name: "Billions of passes", time: math.MaxUint32, memory: 32 * 1024},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			crafted := bytes.Clone(encrypted)
+			binary.BigEndian.PutUint32(crafted[at+3:], tt.time)
+			binary.BigEndian.PutUint32(crafted[at+7:], tt.memory)
+
+			// Neither the integrity hash nor the checksum is keyed, so a crafted file carries valid ones
+			integrity := sha256.Sum256(crafted[:core])
+			copy(crafted[core:], integrity[:])
+			checksum := crc32.ChecksumIEEE(crafted[len(constants.MagicBytes) : size-constants.ChecksumSize])
+			binary.BigEndian.PutUint32(crafted[size-constants.ChecksumSize:], checksum)
+
+			_, err := crypto.ReadHeader(bytes.NewReader(crafted))
+			if !errors.Is(err, constants.ErrInvalidMetadata) {
+				t.Fatalf("Expected %v, got %v", constants.ErrInvalidMetadata, err)
+			}
+
+			// Only the fixed-cost dummy derivation may run, never one with the crafted cost
+			derive := func(password, salt []byte, got crypto.KDFParams) ([]byte, error) {
+				if got != crypto.DefaultKDFParams() {
+					t.Errorf("Derived a key with the header's parameters %+v", got)
+				}
+				return cheapKDF(password, salt, got)
+			}
+			var out bytes.Buffer
+			_, err = operations.NewDecryptorWithKDF(derive).DecryptStream(bytes.NewReader(crafted), &out, testPassword)
+			if err == nil {
+				t.Fatal("Expected the crafted header to be refused")
+			}
+			helpers.AssertEqual(t, 0, out.Len())
+		})
+	}
+}
@@ -0,0 +1,120 @@
+package business
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/hambosto/hexwarden/internal/constants"
+	"github.com/hambosto/hexwarden/internal/usecase/operations"
+	"github.com/hambosto/hexwarden/tests/helpers"
+)
+
+func TestKeySlots_AnyPasswordUnlocks(t *testing.T) {
+	plaintext := bytes.Repeat([]byte("shared secret "), 10000)
+	encrypted := encryptBytes(t, plaintext, operations.EncryptOptions{ExtraPasswords: []string{"second password", "third password"}})
+	path := filepath.Join(t.TempDir(), "shared.hex")
+	helpers.AssertNoError(t, os.WriteFile(path, encrypted, 0o600))
+
+	decrypt := func(t *testing.T, password string) error {
+		t.Helper()
+		data, err := os.ReadFile(path)
+		helpers.AssertNoError(t, err)
+		var out bytes.Buffer
+		if _, err := operations.NewDecryptorWithKDF(cheapKDF).DecryptStream(bytes.NewReader(data), &out, password); err != nil {
+			return err
+		}
+		helpers.AssertBytesEqual(t, plaintext, out.Bytes())
+		return nil
+	}
+
+	tests := []struct {
+		name     string
+		password string
+		wantErr  error
+	}{
+		{name: "Primary password", password: testPassword},
+		{name: "First slot", password: "second password"},
+		{name: "Second slot", password: "third password"},
+		{name: "Wrong password", password: "not one of them", wantErr: constants.ErrAuthFailure},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := decrypt(t, tt.password)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("Expected %v, got %v", tt.wantErr, err)
+				}
+				return
+			}
+			helpers.AssertNoError(t, err)
+		})
+	}
+
+	decryptor := operations.NewDecryptorWithKDF(cheapKDF)
+	info, err := decryptor.Inspect(path, "")
+	helpers.AssertNoError(t, err)
+	helpers.AssertEqual(t, 2, info.KeySlots)
+
+	t.Run("Remove a slot", func(t *testing.T) {
+		// A slot password may unlock the file to remove another slot
+		helpers.AssertNoError(t, decryptor.RemoveKey(path, "third password", "second password"))
+
+		if err := decrypt(t, "second password"); !errors.Is(err, constants.ErrAuthFailure) {
+			t.Fatalf("Expected the removed password to be refused, got %v", err)
+		}
+		helpers.AssertNoError(t, decrypt(t, "third password"))
+		helpers.AssertNoError(t, decrypt(t, testPassword))
+	})
+
+	t.Run("Add a slot", func(t *testing.T) {
+		helpers.AssertNoError(t, decryptor.AddKey(path, testPassword, "fourth password"))
+		helpers.AssertNoError(t, decrypt(t, "fourth password"))
+
+		info, err := decryptor.Inspect(path, "")
+		helpers.AssertNoError(t, err)
+		helpers.AssertEqual(t, 2, info.KeySlots)
+	})
+
+	t.Run("Primary password cannot be removed", func(t *testing.T) {
+		err := decryptor.RemoveKey(path, "third password", testPassword)
+		if !errors.Is(err, constants.ErrPrimaryKey) {
+			t.Fatalf("Expected %v, got %v", constants.ErrPrimaryKey, err)
+		}
+		helpers.AssertNoError(t, decrypt(t, testPassword))
+	})
+
+	t.Run("Unknown password cannot be removed", func(t *testing.T) {
+		err := decryptor.RemoveKey(path, testPassword, "never added")
+		if !errors.Is(err, constants.ErrNoKeySlot) {
+			t.Fatalf("Expected %v, got %v", constants.ErrNoKeySlot, err)
+		}
+	})
+}
+
+func TestKeySlots_InvalidOption(t *testing.T) {
+	tests := []struct {
+		name      string
+		passwords []string
+		wantErr   error
+	}{
+		{name: "Empty password", passwords: []string{""}, wantErr: constants.ErrInvalidOption},
+		{name: "Too many passwords", passwords: make([]string, constants.MaxKeySlots+1), wantErr: constants.ErrTooManyKeySlots},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := operations.EncryptOptions{ExtraPasswords: tt.passwords}.Validate()
+			var optErr *operations.OptionError
+			if !errors.As(err, &optErr) || optErr.Option != "ExtraPasswords" {
+				t.Fatalf("Expected an OptionError for ExtraPasswords, got %v", err)
+			}
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("Expected %v, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,154 @@
+package business
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"path/filepath"
+	"testing"
+
+	"github.com/hambosto/hexwarden/internal/constants"
+	"github.com/hambosto/hexwarden/internal/infrastructure/crypto"
+	"github.com/hambosto/hexwarden/internal/usecase/operations"
+	"github.com/hambosto/hexwarden/tests/helpers"
+)
+
+// appendLog appends records to a new log at path with the test password
+func appendLog(t *testing.T, path string, records ...string) {
+	t.Helper()
+	encryptor := operations.NewEncryptorWithKDF(cheapKDF)
+	for i, record := range records {
+		index, err := encryptor.AppendRecord(path, testPassword, []byte(record), operations.EncryptOptions{})
+		helpers.AssertNoError(t, err)
+		helpers.AssertEqual(t, uint64(i), index)
+	}
+}
+
+// logRecords splits the body of the log at path into its framed records
+func logRecords(t *testing.T, path string) (header []byte, records [][]byte) {
+	t.Helper()
+	data := helpers.ReadFileContent(t, path)
+	src := bytes.NewReader(data)
+	if _, err := crypto.ReadHeader(src); err != nil {
+		t.Fatalf("Failed to read header: %v", err)
+	}
+	body := data[len(data)-src.Len():]
+
+	for len(body) > 0 {
+		length := constants.LogRecordHeaderSize + int(binary.BigEndian.Uint32(body))
+		records = append(records, body[:length])
+		body = body[length:]
+	}
+	return data[:len(data)-src.Len()], records
+}
+
+func TestLog_AppendAndDecrypt(t *testing.T) {
+	tmpDir := helpers.CreateTempDir(t)
+	defer helpers.CleanupTempDir(t, tmpDir)
+
+	logPath := filepath.Join(tmpDir, "events.log.hex")
+	records := []string{"first entry\n", "second entry\n", string(bytes.Repeat([]byte("x"), 70000)), "last entry\n"}
+	appendLog(t, logPath, records[:1]...)
+	headerAfterFirst, _ := logRecords(t, logPath)
+
+	encryptor := operations.NewEncryptorWithKDF(cheapKDF)
+	for i, record := range records[1:] {
+		index, err := encryptor.AppendRecord(logPath, testPassword, []byte(record), operations.EncryptOptions{})
+		helpers.AssertNoError(t, err)
+		helpers.AssertEqual(t, uint64(i+1), index)
+	}
+
+	// Appending leaves the header as it was written
+	header, framed := logRecords(t, logPath)
+	helpers.AssertBytesEqual(t, headerAfterFirst, header)
+	helpers.AssertEqual(t, len(records), len(framed))
+
+	var want bytes.Buffer
+	for _, record := range records {
+		want.WriteString(record)
+	}
+
+	decryptor := operations.NewDecryptorWithKDF(cheapKDF)
+	outputPath := filepath.Join(tmpDir, "events.log")
+	info, err := decryptor.DecryptFile(logPath, outputPath, testPassword)
+	helpers.AssertNoError(t, err)
+	helpers.AssertEqual(t, true, info.Log)
+	helpers.AssertBytesEqual(t, want.Bytes(), helpers.ReadFileContent(t, outputPath))
+
+	var buf bytes.Buffer
+	_, err = decryptor.DecryptStream(bytes.NewReader(helpers.ReadFileContent(t, logPath)), &buf, testPassword)
+	helpers.AssertNoError(t, err)
+	helpers.AssertBytesEqual(t, want.Bytes(), buf.Bytes())
+
+	info, err = decryptor.Inspect(logPath, "")
+	helpers.AssertNoError(t, err)
+	helpers.AssertEqual(t, uint64(len(records)), info.Records)
+
+	report, err := decryptor.Verify(logPath, testPassword)
+	helpers.AssertNoError(t, err)
+	helpers.AssertEqual(t, uint64(len(records)), report.Records)
+}
+
+func TestLog_Rejected(t *testing.T) {
+	tmpDir := helpers.CreateTempDir(t)
+	defer helpers.CleanupTempDir(t, tmpDir)
+
+	logPath := filepath.Join(tmpDir, "events.log.hex")
+	appendLog(t, logPath, "alpha\n", "beta\n", "gamma\n")
+	header, records := logRecords(t, logPath)
+
+	tests := []struct {
+		name    string
+		body    [][]byte
+		wantErr error
+	}{
+		{name: "Reordered records", body: [][]byte{records[0], records[2], records[1]}, wantErr: constants.ErrDecryptionFailed},
+		{name: "Dropped middle record", body: [][]byte{records[0], records[2]}, wantErr: constants.ErrDecryptionFailed},
+		{name: "Truncated record", body: [][]byte{records[0], records[1], records[2][:len(records[2])-3]}, wantErr: constants.ErrInvalidLog},
+		{name: "Truncated length", body: [][]byte{records[0], records[1][:2]}, wantErr: constants.ErrInvalidLog},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := append([]byte{}, header...)
+			for _, record := range tt.body {
+				data = append(data, record...)
+			}
+
+			_, err := operations.NewDecryptorWithKDF(cheapKDF).DecryptStream(bytes.NewReader(data), io.Discard, testPassword)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("Expected %v, got %v", tt.wantErr, err)
+			}
+
+			// Nothing can be appended after a partial record
+			if tt.wantErr != constants.ErrInvalidLog {
+				return
+			}
+			brokenPath := filepath.Join(tmpDir, tt.name+".hex")
+			helpers.WriteFileContent(t, brokenPath, data)
+			_, err = operations.NewEncryptorWithKDF(cheapKDF).AppendRecord(brokenPath, testPassword, []byte("delta\n"), operations.EncryptOptions{})
+			if !errors.Is(err, constants.ErrInvalidLog) {
+				t.Errorf("Expected appending to fail with ErrInvalidLog, got %v", err)
+			}
+		})
+	}
+
+	encryptor := operations.NewEncryptorWithKDF(cheapKDF)
+	if _, err := encryptor.AppendRecord(logPath, "wrong password", []byte("delta\n"), operations.EncryptOptions{}); !errors.Is(err, constants.ErrAuthFailure) {
+		t.Errorf("Expected ErrAuthFailure, got %v", err)
+	}
+	if _, err := encryptor.AppendRecord(logPath, testPassword, nil, operations.EncryptOptions{}); !errors.Is(err, constants.ErrInvalidLog) {
+		t.Errorf("Expected an empty record to fail with ErrInvalidLog, got %v", err)
+	}
+
+	encryptedPath := filepath.Join(tmpDir, "plain.hex")
+	helpers.WriteFileContent(t, encryptedPath, encryptBytes(t, []byte("not a log"), operations.EncryptOptions{}))
+	if _, err := encryptor.AppendRecord(encryptedPath, testPassword, []byte("delta\n"), operations.EncryptOptions{}); !errors.Is(err, constants.ErrNotLog) {
+		t.Errorf("Expected ErrNotLog, got %v", err)
+	}
+
+	// A failed append leaves the log as it was
+	_, records = logRecords(t, logPath)
+	helpers.AssertEqual(t, 3, len(records))
+}
@@ -0,0 +1,108 @@
+package business
+
+import (
+	"errors"
+	mathrand "math/rand/v2"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/hambosto/hexwarden/internal/constants"
+	"github.com/hambosto/hexwarden/internal/usecase/operations"
+	"github.com/hambosto/hexwarden/tests/helpers"
+)
+
+func TestManifest_DetectsDamagedChunk(t *testing.T) {
+	tmpDir := helpers.CreateTempDir(t)
+	defer helpers.CleanupTempDir(t, tmpDir)
+
+	// Incompressible data, so each plaintext chunk becomes its own encrypted chunk
+	plaintext := make([]byte, 5*constants.DefaultChunkSize/2)
+	_, _ = mathrand.NewChaCha8([32]byte{2}).Read(plaintext)
+
+	inputPath := filepath.Join(tmpDir, "input.bin")
+	encryptedPath := inputPath + constants.FileExtension
+	manifestPath := encryptedPath + ".manifest"
+	helpers.WriteFileContent(t, inputPath, plaintext)
+
+	result, err := operations.NewEncryptorWithKDF(cheapKDF).EncryptFile(inputPath, encryptedPath, testPassword, operations.EncryptOptions{Manifest: true})
+	helpers.AssertNoError(t, err)
+	helpers.AssertEqual(t, 3, len(result.Manifest.Chunks))
+	helpers.AssertEqual(t, result.Size, result.Manifest.Size)
+	helpers.AssertNoError(t, operations.WriteManifest(manifestPath, result.Manifest))
+
+	manifest, err := operations.ReadManifest(manifestPath)
+	helpers.AssertNoError(t, err)
+
+	decryptor := operations.NewDecryptorWithKDF(cheapKDF)
+	report, err := decryptor.VerifyManifest(encryptedPath, manifest)
+	helpers.AssertNoError(t, err)
+	helpers.AssertEqual(t, 3, report.Chunks)
+	helpers.AssertEqual(t, 0, len(report.Problems))
+
+	// One flipped byte in the middle chunk no longer matches its CRC, and only that chunk is reported
+	data := helpers.ReadFileContent(t, encryptedPath)
+	middle := manifest.Chunks[1]
+	data[middle.Offset+middle.Length/2] ^= 0xFF
+	helpers.WriteFileContent(t, encryptedPath, data)
+
+	report, err = decryptor.VerifyManifest(encryptedPath, manifest)
+	if !errors.Is(err, constants.ErrManifestMismatch) {
+		t.Fatalf("Expected ErrManifestMismatch, got %v", err)
+	}
+	helpers.AssertEqual(t, 1, len(report.Problems))
+	helpers.AssertEqual(t, 1, report.Problems[0].Index)
+	if !strings.Contains(report.Problems[0].Err.Error(), "CRC-32") {
+		t.Errorf("Expected a CRC-32 mismatch, got %v", report.Problems[0].Err)
+	}
+
+	// Trailing bytes are not covered by any chunk
+	data[middle.Offset+middle.Length/2] ^= 0xFF
+	helpers.WriteFileContent(t, encryptedPath, append(data, 0))
+	report, err = decryptor.VerifyManifest(encryptedPath, manifest)
+	if !errors.Is(err, constants.ErrManifestMismatch) {
+		t.Fatalf("Expected ErrManifestMismatch for trailing data, got %v", err)
+	}
+	helpers.AssertEqual(t, 3, report.Problems[0].Index)
+}
+
+func TestManifest_Validate(t *testing.T) {
+	valid := func() *operations.ChunkManifest {
+		return &operations.ChunkManifest{
+			Version: 1,
+			Body:    100,
+			Size:    114,
+			Chunks: []operations.ManifestChunk{{
+				Index:  0,
+				Offset: 104,
+				Length: 10,
+				CRC32:  "0123abcd",
+				SHA256: "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855",
+			}},
+		}
+	}
+	helpers.AssertNoError(t, valid().Validate())
+
+	tests := []struct {
+		name   string
+		modify func(*operations.ChunkManifest)
+	}{
+		{name: "Unknown version", modify: func(m *operations.ChunkManifest) { m.Version = 2 }},
+		{name: "Gap before the chunk", modify: func(m *operations.ChunkManifest) { m.Chunks[0].Offset = 105 }},
+		{name: "Wrong index", modify: func(m *operations.ChunkManifest) { m.Chunks[0].Index = 1 }},
+		{name: "Empty chunk", modify: func(m *operations.ChunkManifest) { m.Chunks[0].Length, m.Size = 0, 104 }},
+		{name: "Short CRC", modify: func(m *operations.ChunkManifest) { m.Chunks[0].CRC32 = "abcd" }},
+		{name: "Bad SHA-256", modify: func(m *operations.ChunkManifest) { m.Chunks[0].SHA256 = "zz" }},
+		{name: "Size past the last chunk", modify: func(m *operations.ChunkManifest) { m.Size = 200 }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			manifest := valid()
+			tt.modify(manifest)
+			if err := manifest.Validate(); !errors.Is(err, constants.ErrInvalidManifest) {
+				t.Errorf("Expected ErrInvalidManifest, got %v", err)
+			}
+		})
+	}
+}
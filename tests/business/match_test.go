@@ -0,0 +1,149 @@
+package business
+
+import (
+	"bytes"
+	"errors"
+	mathrand "math/rand/v2"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/hambosto/hexwarden/internal/constants"
+	"github.com/hambosto/hexwarden/internal/infrastructure/crypto"
+	"github.com/hambosto/hexwarden/internal/usecase/operations"
+	"github.com/hambosto/hexwarden/tests/helpers"
+)
+
+// countingKDF wraps cheapKDF, counting how often it runs
+func countingKDF(runs *int) operations.KeyDerivationFunc {
+	return func(password, salt []byte, params crypto.KDFParams) ([]byte, error) {
+		*runs++
+		return cheapKDF(password, salt, params)
+	}
+}
+
+func TestDecryptor_Match(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, password string, opts operations.EncryptOptions) string {
+		var out bytes.Buffer
+		_, err := operations.NewEncryptorWithKDF(cheapKDF).EncryptStream(bytes.NewReader([]byte(name)), &out, int64(len(name)), password, opts)
+		helpers.AssertNoError(t, err)
+		path := filepath.Join(dir, name)
+		helpers.AssertNoError(t, os.WriteFile(path, out.Bytes(), 0o600))
+		return path
+	}
+	seeded := func() operations.EncryptOptions {
+		return operations.EncryptOptions{Random: mathrand.NewChaCha8([32]byte{7})}
+	}
+
+	mine := []string{write("a.hex", testPassword, operations.EncryptOptions{}), write("b.hex", testPassword, operations.EncryptOptions{})}
+	sharedMine := write("c.hex", testPassword, seeded())
+	sharedOther := write("d.hex", "another password", seeded())
+	other := write("e.hex", "another password", operations.EncryptOptions{})
+	notEncrypted := filepath.Join(dir, "f.hex")
+	helpers.AssertNoError(t, os.WriteFile(notEncrypted, []byte("not a hexwarden file"), 0o600))
+	all := []string{mine[0], mine[1], sharedMine, sharedOther, other, notEncrypted}
+
+	tests := []struct {
+		name        string
+		salt        string
+		wantMatched []string
+		wantSkipped int
+		wantErrors  int
+		wantRuns    int
+	}{
+		{name: "Every file", wantMatched: append(slices.Clone(mine), sharedMine), wantErrors: 1, wantRuns: 4},
+		{name: "Files sharing a salt", salt: sharedMine, wantMatched: []string{sharedMine}, wantSkipped: 3, wantErrors: 1, wantRuns: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			runs := 0
+			decryptor := operations.NewDecryptorWithKDF(countingKDF(&runs))
+
+			var salt []byte
+			if tt.salt != "" {
+				var err error
+				salt, err = decryptor.HeaderSalt(tt.salt)
+				helpers.AssertNoError(t, err)
+			}
+
+			report := decryptor.Match(all, testPassword, salt, crypto.DefaultKDFParams())
+			if got := report.Matched(); !slices.Equal(got, tt.wantMatched) {
+				t.Errorf("Expected %v to match, got %v", tt.wantMatched, got)
+			}
+			helpers.AssertEqual(t, tt.wantRuns, report.Derivations)
+			helpers.AssertEqual(t, tt.wantRuns, runs)
+
+			skipped, failed := 0, 0
+			for _, result := range report.Results {
+				if result.Skipped {
+					skipped++
+				}
+				if result.Err != nil {
+					failed++
+				}
+			}
+			helpers.AssertEqual(t, tt.wantSkipped, skipped)
+			helpers.AssertEqual(t, tt.wantErrors, failed)
+		})
+	}
+
+	t.Run("Bodies are not read", func(t *testing.T) {
+		// A body too damaged to decrypt still matches on its header
+		encrypted, err := os.ReadFile(mine[0])
+		helpers.AssertNoError(t, err)
+		damaged := filepath.Join(t.TempDir(), "damaged.hex")
+		helpers.AssertNoError(t, os.WriteFile(damaged, encrypted[:len(encrypted)-constants.ChunkHeaderSize], 0o600))
+
+		report := operations.NewDecryptorWithKDF(cheapKDF).Match([]string{damaged}, testPassword, nil, crypto.DefaultKDFParams())
+		if got := report.Matched(); !slices.Equal(got, []string{damaged}) {
+			t.Errorf("Expected %s to match, got %v", damaged, got)
+		}
+	})
+}
+
+func TestDecryptor_MatchKDFLimit(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name string, params crypto.KDFParams) string {
+		path := filepath.Join(dir, name)
+		encrypted := encryptBytes(t, []byte(name), operations.EncryptOptions{KDFParams: params})
+		helpers.AssertNoError(t, os.WriteFile(path, encrypted, 0o600))
+		return path
+	}
+
+	defaults := crypto.DefaultKDFParams()
+	moreTime, moreMemory := defaults, defaults
+	moreTime.Time++
+	moreMemory.Memory *= 2
+	paths := []string{write("time.hex", moreTime), write("memory.hex", moreMemory)}
+
+	tests := []struct {
+		name        string
+		limit       crypto.KDFParams
+		wantMatched []string
+		wantRuns    int
+	}{
+		{name: "Local defaults", limit: defaults, wantRuns: 0},
+		{name: "More memory allowed", limit: crypto.KDFParams{Time: defaults.Time, Memory: moreMemory.Memory}, wantMatched: paths[1:], wantRuns: 1},
+		{name: "Both allowed", limit: crypto.KDFParams{Time: moreTime.Time, Memory: moreMemory.Memory}, wantMatched: paths, wantRuns: 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			runs := 0
+			report := operations.NewDecryptorWithKDF(countingKDF(&runs)).Match(paths, testPassword, nil, tt.limit)
+			if got := report.Matched(); !slices.Equal(got, tt.wantMatched) {
+				t.Errorf("Expected %v to match, got %v", tt.wantMatched, got)
+			}
+			helpers.AssertEqual(t, tt.wantRuns, runs)
+
+			for _, result := range report.Results {
+				if !result.Matched && !errors.Is(result.Err, constants.ErrKDFLimit) {
+					t.Errorf("%s: expected %v, got %v", result.Path, constants.ErrKDFLimit, result.Err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,102 @@
+package business
+
+import (
+	"bytes"
+	"errors"
+	mathrand "math/rand/v2"
+	"path/filepath"
+	"testing"
+
+	"github.com/hambosto/hexwarden/internal/constants"
+	"github.com/hambosto/hexwarden/internal/infrastructure/crypto"
+	"github.com/hambosto/hexwarden/internal/usecase/operations"
+	"github.com/hambosto/hexwarden/tests/helpers"
+)
+
+func TestMerkle_VerifySingleChunk(t *testing.T) {
+	tmpDir := helpers.CreateTempDir(t)
+	defer helpers.CleanupTempDir(t, tmpDir)
+
+	// Incompressible data, so each plaintext chunk becomes its own encrypted chunk
+	plaintext := make([]byte, 5*constants.DefaultChunkSize/2)
+	_, _ = mathrand.NewChaCha8([32]byte{1}).Read(plaintext)
+
+	inputPath := filepath.Join(tmpDir, "input.bin")
+	encryptedPath := inputPath + constants.FileExtension
+	treePath := encryptedPath + ".merkle"
+	helpers.WriteFileContent(t, inputPath, plaintext)
+
+	result, err := operations.NewEncryptorWithKDF(cheapKDF).EncryptFile(inputPath, encryptedPath, testPassword, operations.EncryptOptions{Merkle: true})
+	helpers.AssertNoError(t, err)
+	helpers.AssertEqual(t, 3, result.Merkle.Leaves())
+	helpers.AssertNoError(t, operations.WriteMerkleTree(treePath, result.Merkle))
+
+	// The header is patched without reading the output back, so no digest of it is reported
+	if result.SHA256 != nil {
+		t.Errorf("Expected no output digest for a rewritten header, got %x", result.SHA256)
+	}
+
+	decryptor := operations.NewDecryptorWithKDF(cheapKDF)
+	tree, err := operations.ReadMerkleTree(treePath)
+	helpers.AssertNoError(t, err)
+
+	for index := range 3 {
+		helpers.AssertNoError(t, decryptor.VerifyChunk(encryptedPath, testPassword, index, tree))
+		helpers.AssertNoError(t, decryptor.VerifyChunk(encryptedPath, testPassword, index, nil))
+	}
+	if err := decryptor.VerifyChunk(encryptedPath, testPassword, 3, tree); !errors.Is(err, constants.ErrChunkNotFound) {
+		t.Errorf("Expected ErrChunkNotFound, got %v", err)
+	}
+
+	var out bytes.Buffer
+	_, err = decryptor.DecryptStream(bytes.NewReader(helpers.ReadFileContent(t, encryptedPath)), &out, testPassword)
+	helpers.AssertNoError(t, err)
+	helpers.AssertBytesEqual(t, plaintext, out.Bytes())
+
+	// A single flipped byte is caught by the Merkle tree
+	data := helpers.ReadFileContent(t, encryptedPath)
+	start, _ := lastChunk(t, data)
+	data[start+1] ^= 0xFF
+	helpers.WriteFileContent(t, encryptedPath, data)
+
+	if err := decryptor.VerifyChunk(encryptedPath, testPassword, 2, tree); !errors.Is(err, constants.ErrMerkleMismatch) {
+		t.Errorf("Expected ErrMerkleMismatch for the tampered chunk, got %v", err)
+	}
+	helpers.AssertNoError(t, decryptor.VerifyChunk(encryptedPath, testPassword, 0, tree))
+	if err := decryptor.VerifyChunk(encryptedPath, testPassword, 0, nil); !errors.Is(err, constants.ErrMerkleMismatch) {
+		t.Errorf("Expected ErrMerkleMismatch without a sidecar, got %v", err)
+	}
+}
+
+func TestMerkle_Failures(t *testing.T) {
+	tmpDir := helpers.CreateTempDir(t)
+	defer helpers.CleanupTempDir(t, tmpDir)
+
+	plaintext := []byte("no Merkle root here")
+	inputPath := filepath.Join(tmpDir, "input.txt")
+	encryptedPath := inputPath + constants.FileExtension
+	helpers.WriteFileContent(t, inputPath, plaintext)
+
+	_, err := operations.NewEncryptorWithKDF(cheapKDF).EncryptFile(inputPath, encryptedPath, testPassword, operations.EncryptOptions{})
+	helpers.AssertNoError(t, err)
+
+	decryptor := operations.NewDecryptorWithKDF(cheapKDF)
+	if err := decryptor.VerifyChunk(encryptedPath, testPassword, 0, nil); !errors.Is(err, constants.ErrNoMerkleRoot) {
+		t.Errorf("Expected ErrNoMerkleRoot, got %v", err)
+	}
+
+	var out bytes.Buffer
+	_, err = operations.NewEncryptorWithKDF(cheapKDF).EncryptStream(bytes.NewReader(plaintext), &out, int64(len(plaintext)), testPassword, operations.EncryptOptions{Merkle: true})
+	if !errors.Is(err, constants.ErrNotSeekable) {
+		t.Errorf("Expected ErrNotSeekable, got %v", err)
+	}
+	helpers.AssertEqual(t, 0, out.Len())
+
+	// A sidecar is only trusted through the authenticated root
+	wrongTree := crypto.NewMerkleTree([][]byte{crypto.HashMerkleLeaf([]byte("other")), crypto.HashMerkleLeaf([]byte("leaves"))})
+	_, err = operations.NewEncryptorWithKDF(cheapKDF).EncryptFile(inputPath, encryptedPath+".2", testPassword, operations.EncryptOptions{Merkle: true})
+	helpers.AssertNoError(t, err)
+	if err := decryptor.VerifyChunk(encryptedPath+".2", testPassword, 0, wrongTree); !errors.Is(err, constants.ErrMerkleMismatch) {
+		t.Errorf("Expected ErrMerkleMismatch for a foreign tree, got %v", err)
+	}
+}
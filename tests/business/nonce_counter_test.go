@@ -0,0 +1,222 @@
+package business
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/hambosto/hexwarden/internal/constants"
+	"github.com/hambosto/hexwarden/internal/data/files"
+	"github.com/hambosto/hexwarden/internal/data/streaming"
+	"github.com/hambosto/hexwarden/internal/infrastructure/crypto"
+	"github.com/hambosto/hexwarden/internal/infrastructure/encoding"
+	"github.com/hambosto/hexwarden/internal/usecase/operations"
+	"github.com/hambosto/hexwarden/tests/helpers"
+)
+
+// headerOf reads the header of an encrypted file
+func headerOf(t *testing.T, encrypted []byte) *crypto.Header {
+	t.Helper()
+	header, err := crypto.ReadHeader(bytes.NewReader(encrypted))
+	helpers.AssertNoError(t, err)
+	return header
+}
+
+// chunkNoncesOf returns the nonce each chunk of a chunk stream is sealed under
+func chunkNoncesOf(t *testing.T, body []byte, version uint8, nonceSize int) [][]byte {
+	t.Helper()
+
+	chunks, err := streaming.NewChunkReader(bytes.NewReader(body), version)
+	helpers.AssertNoError(t, err)
+	encoder, err := encoding.NewDefaultEncoder()
+	helpers.AssertNoError(t, err)
+
+	var nonces [][]byte
+	for {
+		chunk, err := chunks.Next()
+		if err == io.EOF {
+			return nonces
+		}
+		helpers.AssertNoError(t, err)
+		sealed, err := encoder.Decode(chunk)
+		helpers.AssertNoError(t, err)
+		nonces = append(nonces, sealed[:nonceSize])
+	}
+}
+
+// assertCounterNonces checks that nonces share one prefix and count up from first
+func assertCounterNonces(t *testing.T, nonces [][]byte, first uint64) {
+	t.Helper()
+	for i, nonce := range nonces {
+		split := len(nonce) - constants.NonceCounterSize
+		helpers.AssertBytesEqual(t, nonces[0][:split], nonce[:split])
+		helpers.AssertEqual(t, first+uint64(i), binary.BigEndian.Uint64(nonce[split:]))
+	}
+}
+
+func TestNonceStrategy_RandomByDefault(t *testing.T) {
+	encrypted := encryptBytes(t, []byte("default nonce"), operations.EncryptOptions{})
+	header := headerOf(t, encrypted)
+
+	helpers.AssertEqual(t, constants.NonceRandom, header.NonceStrategy())
+	helpers.AssertEqual(t, constants.FormatVersion2, header.Version())
+	if _, _, ok := header.NonceCounter(); ok {
+		t.Fatal("Expected no nonce counter in a default header")
+	}
+}
+
+func TestNonceStrategy_CounterAcrossInvocations(t *testing.T) {
+	statePath := filepath.Join(t.TempDir(), "nonce.state")
+	plaintext := []byte("one long-lived password, many files")
+
+	tests := []struct {
+		name   string
+		cipher constants.CipherAlgorithm
+	}{
+		{name: "AES-GCM", cipher: constants.CipherAESGCM},
+		{name: "XChaCha20 24-byte nonce", cipher: constants.CipherXChaCha20Poly1305},
+		{name: "AES-GCM again", cipher: constants.CipherAESGCM},
+	}
+
+	var id []byte
+	seen := map[string]bool{}
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// A fresh encryptor each time, as a separate run of the command would use
+			encrypted := encryptBytes(t, plaintext, operations.EncryptOptions{NonceState: statePath, Cipher: tt.cipher})
+			header := headerOf(t, encrypted)
+
+			helpers.AssertEqual(t, constants.NonceCounter, header.NonceStrategy())
+			headerID, counter, ok := header.NonceCounter()
+			if !ok {
+				t.Fatal("Expected a nonce counter in the header")
+			}
+			helpers.AssertEqual(t, uint64(i), counter)
+			if id == nil {
+				id = headerID
+			}
+			helpers.AssertBytesEqual(t, id, headerID)
+
+			var buf bytes.Buffer
+			helpers.AssertNoError(t, header.Write(&buf))
+			written := string(buf.Bytes())
+			if seen[written] {
+				t.Fatal("Expected every header to differ")
+			}
+			seen[written] = true
+
+			var out bytes.Buffer
+			_, err := operations.NewDecryptorWithKDF(cheapKDF).DecryptStream(bytes.NewReader(encrypted), &out, testPassword)
+			helpers.AssertNoError(t, err)
+			helpers.AssertBytesEqual(t, plaintext, out.Bytes())
+		})
+	}
+
+	// The next counter was persisted before the last one was used
+	nextID, next, err := files.NewManager().ReserveNonceCounter(statePath)
+	helpers.AssertNoError(t, err)
+	helpers.AssertBytesEqual(t, id, nextID)
+	helpers.AssertEqual(t, uint64(len(tests)), next)
+}
+
+func TestNonceStrategy_ChunkNoncesNeverRepeat(t *testing.T) {
+	statePath := filepath.Join(t.TempDir(), "nonce.state")
+	plaintext := make([]byte, 2*constants.DefaultChunkSize+100)
+	for i := range plaintext {
+		plaintext[i] = byte(i*31 + i/1024)
+	}
+
+	tests := []struct {
+		name      string
+		cipher    constants.CipherAlgorithm
+		nonceSize int
+	}{
+		{name: "AES-GCM", cipher: constants.CipherAESGCM, nonceSize: 12},
+		{name: "AES-GCM again", cipher: constants.CipherAESGCM, nonceSize: 12},
+		{name: "XChaCha20 24-byte nonce", cipher: constants.CipherXChaCha20Poly1305, nonceSize: 24},
+	}
+
+	seen := map[string]bool{}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			encrypted := encryptBytes(t, plaintext, operations.EncryptOptions{NonceState: statePath, Cipher: tt.cipher})
+			header := headerOf(t, encrypted)
+
+			nonces := chunkNoncesOf(t, encrypted[header.Size():], header.Version(), tt.nonceSize)
+			helpers.AssertEqual(t, 3, len(nonces))
+			assertCounterNonces(t, nonces, 0)
+			for _, nonce := range nonces {
+				if seen[string(nonce)] {
+					t.Fatalf("Chunk nonce %x used twice", nonce)
+				}
+				seen[string(nonce)] = true
+			}
+
+			var out bytes.Buffer
+			_, err := operations.NewDecryptorWithKDF(cheapKDF).DecryptStream(bytes.NewReader(encrypted), &out, testPassword)
+			helpers.AssertNoError(t, err)
+			helpers.AssertBytesEqual(t, plaintext, out.Bytes())
+		})
+	}
+
+	t.Run("Bundle members share one counter", func(t *testing.T) {
+		dir := t.TempDir()
+		var paths []string
+		for _, name := range []string{"a.bin", "b.bin"} {
+			path := filepath.Join(dir, name)
+			helpers.WriteFileContent(t, path, plaintext[:constants.DefaultChunkSize+100])
+			paths = append(paths, path)
+		}
+		bundlePath := filepath.Join(dir, "bundle.hex")
+		opts := operations.EncryptOptions{NonceState: statePath, Cipher: constants.CipherAESGCM}
+		_, err := operations.NewEncryptorWithKDF(cheapKDF).EncryptBundle(paths, bundlePath, testPassword, opts)
+		helpers.AssertNoError(t, err)
+
+		encrypted := helpers.ReadFileContent(t, bundlePath)
+		header := headerOf(t, encrypted)
+		members, err := operations.NewDecryptorWithKDF(cheapKDF).ListBundle(bundlePath, testPassword)
+		helpers.AssertNoError(t, err)
+		helpers.AssertEqual(t, 2, len(members))
+
+		var nonces [][]byte
+		for _, member := range members {
+			start := int64(header.Size()) + member.Offset
+			nonces = append(nonces, chunkNoncesOf(t, encrypted[start:start+member.Length], header.Version(), 12)...)
+		}
+		helpers.AssertEqual(t, 4, len(nonces))
+		assertCounterNonces(t, nonces, 0)
+	})
+}
+
+func TestNonceStrategy_Refused(t *testing.T) {
+	plaintext := []byte("refused nonce counter")
+
+	t.Run("Damaged state file", func(t *testing.T) {
+		statePath := filepath.Join(t.TempDir(), "nonce.state")
+		damaged := []byte("hexwarden-nonce 00112233 12\n")
+		helpers.AssertNoError(t, os.WriteFile(statePath, damaged, 0o600))
+
+		var out bytes.Buffer
+		opts := operations.EncryptOptions{NonceState: statePath}
+		_, err := operations.NewEncryptorWithKDF(cheapKDF).EncryptStream(bytes.NewReader(plaintext), &out, int64(len(plaintext)), testPassword, opts)
+		if !errors.Is(err, constants.ErrNonceState) {
+			t.Fatalf("Expected %v, got %v", constants.ErrNonceState, err)
+		}
+		data, err := os.ReadFile(statePath)
+		helpers.AssertNoError(t, err)
+		helpers.AssertBytesEqual(t, damaged, data)
+	})
+
+	t.Run("Combined with a random source", func(t *testing.T) {
+		statePath := filepath.Join(t.TempDir(), "nonce.state")
+		opts := operations.EncryptOptions{NonceState: statePath, Random: bytes.NewReader(make([]byte, 64))}
+		if err := opts.Validate(); !errors.Is(err, constants.ErrInvalidOption) {
+			t.Fatalf("Expected %v, got %v", constants.ErrInvalidOption, err)
+		}
+		helpers.AssertFileNotExists(t, statePath)
+	})
+}
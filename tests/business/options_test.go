@@ -0,0 +1,83 @@
+package business
+
+import (
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/hambosto/hexwarden/internal/constants"
+	"github.com/hambosto/hexwarden/internal/infrastructure/crypto"
+	"github.com/hambosto/hexwarden/internal/usecase/operations"
+	"github.com/hambosto/hexwarden/tests/helpers"
+)
+
+func TestEncryptOptions_Validate(t *testing.T) {
+	tests := []struct {
+		name        string
+		opts        operations.EncryptOptions
+		option      string
+		expectedErr error
+	}{
+		{name: "Defaults"},
+		{name: "Negative password length", opts: operations.EncryptOptions{MinPasswordLength: -1}, option: "MinPasswordLength"},
+		{name: "Threshold above raw body limit", opts: operations.EncryptOptions{SmallFileThreshold: constants.MaxRawBodySize + 1}, option: "SmallFileThreshold"},
+		{name: "Unresolved auto cipher", opts: operations.EncryptOptions{Cipher: constants.CipherAuto}, option: "Cipher", expectedErr: constants.ErrInvalidOption},
+		{name: "Unknown compression", opts: operations.EncryptOptions{Compression: constants.CompressionFormat(99)}, option: "Compression", expectedErr: constants.ErrInvalidOption},
+		{name: "Too little KDF memory for threads", opts: operations.EncryptOptions{KDFParams: crypto.KDFParams{Time: 1, Memory: 8, Threads: 4}}, option: "KDFParams", expectedErr: constants.ErrInvalidKDFParams},
+		{name: "KDF without passes", opts: operations.EncryptOptions{KDFParams: crypto.KDFParams{Memory: 64 * 1024, Threads: 4}}, option: "KDFParams", expectedErr: constants.ErrInvalidKDFParams},
+		{name: "Comment too long", opts: operations.EncryptOptions{Comment: strings.Repeat("c", constants.MaxCommentLength+1)}, option: "Comment", expectedErr: constants.ErrInvalidOption},
+		{name: "Filename too long", opts: operations.EncryptOptions{Filename: strings.Repeat("f", constants.MaxFilenameLength+1)}, option: "Filename", expectedErr: constants.ErrInvalidOption},
+		{name: "Empty associated data", opts: operations.EncryptOptions{AAD: []byte{}}, option: "AAD", expectedErr: constants.ErrInvalidOption},
+		{name: "Compressed metadata rewritten in place", opts: operations.EncryptOptions{CompressMetadata: true, Merkle: true}, option: "CompressMetadata", expectedErr: constants.ErrInvalidOption},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.opts.Validate()
+			if tt.option == "" {
+				helpers.AssertNoError(t, err)
+				return
+			}
+			if tt.expectedErr != nil && !errors.Is(err, tt.expectedErr) {
+				t.Fatalf("Expected %v, got %v", tt.expectedErr, err)
+			}
+			var optErr *operations.OptionError
+			if !errors.As(err, &optErr) {
+				t.Fatalf("Expected an OptionError, got %T", err)
+			}
+			helpers.AssertEqual(t, tt.option, optErr.Option)
+		})
+	}
+}
+
+func TestEncryptOptions_ValidateReportsEveryProblem(t *testing.T) {
+	opts := operations.EncryptOptions{
+		MinPasswordLength:  -1,
+		SmallFileThreshold: -1,
+		Cipher:             constants.CipherAuto,
+	}
+	err := opts.Validate()
+	helpers.AssertError(t, err, nil)
+	for _, option := range []string{"MinPasswordLength", "SmallFileThreshold", "Cipher"} {
+		if !strings.Contains(err.Error(), "invalid "+option) {
+			t.Errorf("Expected %s to be reported, got %v", option, err)
+		}
+	}
+}
+
+func TestEncryptFile_InvalidOptionsCreateNoOutput(t *testing.T) {
+	tmpDir := helpers.CreateTempDir(t)
+	defer helpers.CleanupTempDir(t, tmpDir)
+
+	inputPath := filepath.Join(tmpDir, "input.txt")
+	outputPath := inputPath + constants.FileExtension
+	helpers.WriteFileContent(t, inputPath, []byte("never encrypted"))
+
+	opts := operations.EncryptOptions{Compression: constants.CompressionFormat(99)}
+	_, err := operations.NewEncryptorWithKDF(cheapKDF).EncryptFile(inputPath, outputPath, testPassword, opts)
+	if !errors.Is(err, constants.ErrInvalidOption) {
+		t.Fatalf("Expected ErrInvalidOption, got %v", err)
+	}
+	helpers.AssertFileNotExists(t, outputPath)
+}
@@ -0,0 +1,174 @@
+package business
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/hambosto/hexwarden/internal/constants"
+	"github.com/hambosto/hexwarden/internal/infrastructure/crypto"
+	"github.com/hambosto/hexwarden/internal/usecase/operations"
+	"github.com/hambosto/hexwarden/tests/helpers"
+)
+
+// replaceOriginalHash rewrites the header of the file at path so it stores digest
+func replaceOriginalHash(t *testing.T, path string, digest []byte) {
+	t.Helper()
+
+	data := helpers.ReadFileContent(t, path)
+	header, err := crypto.ReadHeader(bytes.NewReader(data))
+	helpers.AssertNoError(t, err)
+	key, err := cheapKDF([]byte(testPassword), header.Salt(), header.KDFParams())
+	helpers.AssertNoError(t, err)
+
+	size := header.Size()
+	helpers.AssertNoError(t, header.SetOriginalSHA256(key, digest))
+	helpers.AssertEqual(t, size, header.Size())
+
+	var rewritten bytes.Buffer
+	helpers.AssertNoError(t, header.Write(&rewritten))
+	helpers.WriteFileContent(t, path, append(rewritten.Bytes(), data[size:]...))
+}
+
+func TestHashOriginal_VerifyMatch(t *testing.T) {
+	tests := []struct {
+		name string
+		opts operations.EncryptOptions
+	}{
+		{name: "Chunked", opts: operations.EncryptOptions{HashOriginal: true}},
+		{name: "Raw body", opts: operations.EncryptOptions{HashOriginal: true, SmallFileThreshold: 1 << 20}},
+		{name: "With Merkle root", opts: operations.EncryptOptions{HashOriginal: true, Merkle: true}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpDir := helpers.CreateTempDir(t)
+			defer helpers.CleanupTempDir(t, tmpDir)
+
+			plaintext := bytes.Repeat([]byte("hash the original "), 5000)
+			inputPath := filepath.Join(tmpDir, "input.txt")
+			encryptedPath := inputPath + constants.FileExtension
+			outputPath := filepath.Join(tmpDir, "output.txt")
+			helpers.WriteFileContent(t, inputPath, plaintext)
+
+			result, err := operations.NewEncryptorWithKDF(cheapKDF).EncryptFile(inputPath, encryptedPath, testPassword, tt.opts)
+			helpers.AssertNoError(t, err)
+
+			want := sha256.Sum256(plaintext)
+			helpers.AssertBytesEqual(t, want[:], result.OriginalSHA256)
+			// The header is patched without reading the output back, so no digest of it is reported
+			if result.SHA256 != nil {
+				t.Errorf("Expected no output digest for a rewritten header, got %x", result.SHA256)
+			}
+			helpers.AssertEqual(t, int64(len(helpers.ReadFileContent(t, encryptedPath))), result.Size)
+
+			decryptor := operations.NewDecryptorWithKDF(cheapKDF)
+			decryptor.SetVerifyHash(true)
+			info, err := decryptor.DecryptFile(encryptedPath, outputPath, testPassword)
+			helpers.AssertNoError(t, err)
+			helpers.AssertBytesEqual(t, want[:], info.Original)
+
+			output := helpers.ReadFileContent(t, outputPath)
+			helpers.AssertBytesEqual(t, plaintext, output)
+			got := sha256.Sum256(output)
+			helpers.AssertBytesEqual(t, info.Original, got[:])
+		})
+	}
+}
+
+func TestHashOriginal_VerifyFailures(t *testing.T) {
+	tmpDir := helpers.CreateTempDir(t)
+	defer helpers.CleanupTempDir(t, tmpDir)
+
+	plaintext := []byte("deliberately mismatched digest")
+	inputPath := filepath.Join(tmpDir, "input.txt")
+	helpers.WriteFileContent(t, inputPath, plaintext)
+
+	hashedPath := filepath.Join(tmpDir, "hashed.hex")
+	_, err := operations.NewEncryptorWithKDF(cheapKDF).EncryptFile(inputPath, hashedPath, testPassword, operations.EncryptOptions{HashOriginal: true})
+	helpers.AssertNoError(t, err)
+	replaceOriginalHash(t, hashedPath, bytes.Repeat([]byte{0xAB}, sha256.Size))
+
+	plainPath := filepath.Join(tmpDir, "plain.hex")
+	_, err = operations.NewEncryptorWithKDF(cheapKDF).EncryptFile(inputPath, plainPath, testPassword, operations.EncryptOptions{})
+	helpers.AssertNoError(t, err)
+
+	tests := []struct {
+		name     string
+		path     string
+		expected error
+	}{
+		{name: "Mismatch", path: hashedPath, expected: constants.ErrOriginalMismatch},
+		{name: "No stored hash", path: plainPath, expected: constants.ErrNoOriginalHash},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			outputPath := filepath.Join(tmpDir, "output.txt")
+			decryptor := operations.NewDecryptorWithKDF(cheapKDF)
+			decryptor.SetVerifyHash(true)
+
+			_, err := decryptor.DecryptFile(tt.path, outputPath, testPassword)
+			if !errors.Is(err, tt.expected) {
+				t.Fatalf("Expected %v, got %v", tt.expected, err)
+			}
+			helpers.AssertFileNotExists(t, outputPath)
+
+			// Without verification the same file decrypts
+			_, err = operations.NewDecryptorWithKDF(cheapKDF).DecryptFile(tt.path, outputPath, testPassword)
+			helpers.AssertNoError(t, err)
+			helpers.AssertBytesEqual(t, plaintext, helpers.ReadFileContent(t, outputPath))
+			helpers.AssertNoError(t, os.Remove(outputPath))
+		})
+	}
+}
+
+func TestHashOriginal_NeedsSeekableOutput(t *testing.T) {
+	plaintext := []byte("streamed output cannot be rewritten")
+
+	var out bytes.Buffer
+	_, err := operations.NewEncryptorWithKDF(cheapKDF).EncryptStream(
+		bytes.NewReader(plaintext), &out, int64(len(plaintext)), testPassword, operations.EncryptOptions{HashOriginal: true})
+	if !errors.Is(err, constants.ErrNotSeekable) {
+		t.Fatalf("Expected ErrNotSeekable, got %v", err)
+	}
+	helpers.AssertEqual(t, 0, out.Len())
+}
+
+// writeSeeker hides every method of the file but Write and Seek
+type writeSeeker struct {
+	file *os.File
+}
+
+func (w writeSeeker) Write(p []byte) (int, error) { return w.file.Write(p) }
+func (w writeSeeker) Seek(offset int64, whence int) (int64, error) {
+	return w.file.Seek(offset, whence)
+}
+
+func TestHashOriginal_OutputNotReadBack(t *testing.T) {
+	plaintext := bytes.Repeat([]byte("patched in place "), 3*constants.DefaultChunkSize/17)
+	encryptedPath := filepath.Join(t.TempDir(), "output.hex")
+
+	file, err := os.Create(encryptedPath)
+	helpers.AssertNoError(t, err)
+	defer file.Close() //nolint:errcheck
+
+	opts := operations.EncryptOptions{HashOriginal: true, Merkle: true}
+	result, err := operations.NewEncryptorWithKDF(cheapKDF).EncryptStream(
+		bytes.NewReader(plaintext), writeSeeker{file}, int64(len(plaintext)), testPassword, opts)
+	helpers.AssertNoError(t, err)
+	helpers.AssertNoError(t, file.Close())
+
+	want := sha256.Sum256(plaintext)
+	helpers.AssertBytesEqual(t, want[:], result.OriginalSHA256)
+
+	decryptor := operations.NewDecryptorWithKDF(cheapKDF)
+	decryptor.SetVerifyHash(true)
+	var out bytes.Buffer
+	_, err = decryptor.DecryptStream(bytes.NewReader(helpers.ReadFileContent(t, encryptedPath)), &out, testPassword)
+	helpers.AssertNoError(t, err)
+	helpers.AssertBytesEqual(t, plaintext, out.Bytes())
+}
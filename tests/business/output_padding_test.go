@@ -0,0 +1,123 @@
+package business
+
+import (
+	"bytes"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/hambosto/hexwarden/internal/constants"
+	"github.com/hambosto/hexwarden/internal/usecase/operations"
+	"github.com/hambosto/hexwarden/tests/helpers"
+)
+
+func TestPadOutputTo_FixedSizeRoundTrip(t *testing.T) {
+	const target = 1 << 20
+
+	tests := []struct {
+		name string
+		opts operations.EncryptOptions
+		size int
+	}{
+		{name: "Empty", size: 0},
+		{name: "Below the small-file threshold", size: 100, opts: operations.EncryptOptions{SmallFileThreshold: 4096}},
+		{name: "Several chunks", size: 3*constants.DefaultChunkSize + 17},
+		{name: "XChaCha20 with Merkle root", size: 2 * constants.DefaultChunkSize, opts: operations.EncryptOptions{Cipher: constants.CipherXChaCha20Poly1305, Merkle: true}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			plaintext := bytes.Repeat([]byte("fixed size "), tt.size/11+1)[:tt.size]
+			opts := tt.opts
+			opts.PadOutputTo = target
+
+			// A file, so a Merkle root can be written back into the header
+			dir := t.TempDir()
+			inputPath := filepath.Join(dir, "input")
+			encryptedPath := inputPath + constants.FileExtension
+			helpers.WriteFileContent(t, inputPath, plaintext)
+			_, err := operations.NewEncryptorWithKDF(cheapKDF).EncryptFile(inputPath, encryptedPath, testPassword, opts)
+			helpers.AssertNoError(t, err)
+			encrypted := helpers.ReadFileContent(t, encryptedPath)
+			helpers.AssertEqual(t, target, len(encrypted))
+			paddedSize, padded := headerOf(t, encrypted).PaddedSize()
+			helpers.AssertEqual(t, true, padded)
+			helpers.AssertEqual(t, uint64(target), paddedSize)
+
+			var out bytes.Buffer
+			_, err = operations.NewDecryptorWithKDF(cheapKDF).DecryptStream(bytes.NewReader(encrypted), &out, testPassword)
+			helpers.AssertNoError(t, err)
+			helpers.AssertBytesEqual(t, plaintext, out.Bytes())
+
+			// Scan and verify read the chunks up to the marker; verify also checks the padding
+			_, err = operations.NewDecryptorWithKDF(cheapKDF).Scan(encryptedPath)
+			helpers.AssertNoError(t, err)
+			_, err = operations.NewDecryptorWithKDF(cheapKDF).Verify(encryptedPath, testPassword)
+			helpers.AssertNoError(t, err)
+		})
+	}
+}
+
+func TestPadOutputTo_Refused(t *testing.T) {
+	plaintext := bytes.Repeat([]byte("padding checks "), constants.DefaultChunkSize/15)
+	padded := encryptBytes(t, plaintext, operations.EncryptOptions{PadOutputTo: 2 * constants.DefaultChunkSize})
+
+	t.Run("Target below the natural size", func(t *testing.T) {
+		var out bytes.Buffer
+		opts := operations.EncryptOptions{PadOutputTo: 64} // Smaller than the header alone
+		_, err := operations.NewEncryptorWithKDF(cheapKDF).EncryptStream(bytes.NewReader(plaintext), &out, int64(len(plaintext)), testPassword, opts)
+		if !errors.Is(err, constants.ErrPaddingTarget) {
+			t.Fatalf("Expected %v, got %v", constants.ErrPaddingTarget, err)
+		}
+	})
+
+	t.Run("Unknown source size", func(t *testing.T) {
+		var out bytes.Buffer
+		opts := operations.EncryptOptions{PadOutputTo: 2 * constants.DefaultChunkSize}
+		_, err := operations.NewEncryptorWithKDF(cheapKDF).EncryptStream(bytes.NewReader(plaintext), &out, constants.UnknownSize, testPassword, opts)
+		if !errors.Is(err, constants.ErrInvalidOption) {
+			t.Fatalf("Expected %v, got %v", constants.ErrInvalidOption, err)
+		}
+	})
+
+	tests := []struct {
+		name        string
+		tamper      func([]byte) []byte
+		expectedErr error
+	}{
+		{
+			name:        "Filler byte flipped",
+			tamper:      func(data []byte) []byte { data[len(data)-constants.PaddingRecordSize-1] ^= 0x01; return data },
+			expectedErr: constants.ErrPaddingMismatch,
+		},
+		{
+			name: "Filler cut short",
+			tamper: func(data []byte) []byte {
+				return append(data[:len(data)-constants.PaddingRecordSize-100], data[len(data)-constants.PaddingRecordSize:]...)
+			},
+			expectedErr: constants.ErrPaddingMismatch,
+		},
+		{
+			name:        "Record forged",
+			tamper:      func(data []byte) []byte { data[len(data)-1] ^= 0x01; return data },
+			expectedErr: constants.ErrTagMismatch,
+		},
+		{
+			name:        "Record cut off",
+			tamper:      func(data []byte) []byte { return data[:len(data)-constants.PaddingRecordSize/2] },
+			expectedErr: constants.ErrTagMismatch,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := tt.tamper(bytes.Clone(padded))
+
+			var out bytes.Buffer
+			_, err := operations.NewDecryptorWithKDF(cheapKDF).DecryptStream(bytes.NewReader(data), &out, testPassword)
+			if !errors.Is(err, tt.expectedErr) {
+				t.Fatalf("Expected %v, got %v", tt.expectedErr, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,86 @@
+package business
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/hambosto/hexwarden/internal/constants"
+	"github.com/hambosto/hexwarden/internal/infrastructure"
+	"github.com/hambosto/hexwarden/internal/infrastructure/encoding"
+	"github.com/hambosto/hexwarden/internal/usecase/operations"
+	"github.com/hambosto/hexwarden/tests/helpers"
+)
+
+// brokenEncoder is a real encoder whose Encode output is damaged by breakFn
+type brokenEncoder struct {
+	*encoding.Encoder
+	breakFn func(data, encoded []byte) []byte
+}
+
+func (e brokenEncoder) Encode(data []byte) ([]byte, error) {
+	encoded, err := e.Encoder.Encode(data)
+	if err != nil {
+		return nil, err
+	}
+	return e.breakFn(data, encoded), nil
+}
+
+func TestProcessor_ParanoidDetectsBrokenEncoder(t *testing.T) {
+	testData := helpers.NewTestData()
+	plaintext := bytes.Repeat([]byte("paranoid chunk "), 1000)
+
+	tests := []struct {
+		name    string
+		breakFn func(data, encoded []byte) []byte
+	}{
+		{name: "Single flipped byte", breakFn: func(_, encoded []byte) []byte {
+			encoded[0] ^= 0xFF
+			return encoded
+		}},
+		{name: "Zeroed parity", breakFn: func(_, encoded []byte) []byte {
+			parity := len(encoded) / (constants.DataShards + constants.ParityShards) * constants.DataShards
+			clear(encoded[parity:])
+			return encoded
+		}},
+		{name: "Consistent parity over the wrong data", breakFn: func(data, _ []byte) []byte {
+			wrong := append([]byte(nil), data...)
+			wrong[len(wrong)/2] ^= 0x01
+			encoder, err := encoding.NewDefaultEncoder()
+			helpers.AssertNoError(t, err)
+			encoded, err := encoder.Encode(wrong)
+			helpers.AssertNoError(t, err)
+			return encoded
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			encoder, err := encoding.NewDefaultEncoder()
+			helpers.AssertNoError(t, err)
+			processor, err := infrastructure.NewProcessor(testData.ValidKey32)
+			helpers.AssertNoError(t, err)
+			processor.SetEncoder(brokenEncoder{Encoder: encoder, breakFn: tt.breakFn})
+
+			// Without the self-check the damaged chunk is returned as if it were fine
+			_, err = processor.Encrypt(plaintext)
+			helpers.AssertNoError(t, err)
+
+			processor.SetParanoid(true)
+			if _, err := processor.Encrypt(plaintext); !errors.Is(err, constants.ErrSelfCheckFailed) {
+				t.Fatalf("Expected %v, got %v", constants.ErrSelfCheckFailed, err)
+			}
+		})
+	}
+}
+
+func TestEncrypt_ParanoidRoundTrip(t *testing.T) {
+	plaintext := bytes.Repeat([]byte("checked twice "), constants.DefaultChunkSize/7)
+
+	encrypted := encryptBytes(t, plaintext, operations.EncryptOptions{Paranoid: true})
+
+	var buf bytes.Buffer
+	_, err := operations.NewDecryptorWithKDF(cheapKDF).DecryptStream(bytes.NewReader(encrypted), &buf, testPassword)
+	helpers.AssertNoError(t, err)
+	helpers.AssertBytesEqual(t, plaintext, buf.Bytes())
+}
@@ -0,0 +1,58 @@
+package business
+
+import (
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/hambosto/hexwarden/internal/constants"
+	"github.com/hambosto/hexwarden/internal/usecase/operations"
+	"github.com/hambosto/hexwarden/tests/helpers"
+)
+
+func TestEncryptFile_MinPasswordLength(t *testing.T) {
+	const minLength = 12
+
+	tests := []struct {
+		name        string
+		password    string
+		expectedErr error
+	}{
+		{name: "One below minimum", password: strings.Repeat("a", minLength-1), expectedErr: constants.ErrPasswordTooShort},
+		{name: "Exactly minimum", password: strings.Repeat("a", minLength)},
+		{name: "Above minimum", password: strings.Repeat("a", minLength+1)},
+		{name: "Multibyte characters count once", password: strings.Repeat("é", minLength-1), expectedErr: constants.ErrPasswordTooShort},
+		{name: "Multibyte characters at minimum", password: strings.Repeat("é", minLength)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpDir := helpers.CreateTempDir(t)
+			defer helpers.CleanupTempDir(t, tmpDir)
+
+			inputPath := filepath.Join(tmpDir, "input.txt")
+			encryptedPath := inputPath + constants.FileExtension
+			helpers.WriteFileContent(t, inputPath, []byte("policy test data"))
+
+			opts := operations.EncryptOptions{MinPasswordLength: minLength}
+			_, err := operations.NewEncryptor().EncryptFile(inputPath, encryptedPath, tt.password, opts)
+
+			if tt.expectedErr == nil {
+				helpers.AssertNoError(t, err)
+				return
+			}
+			if !errors.Is(err, tt.expectedErr) {
+				t.Fatalf("Expected %v, got %v", tt.expectedErr, err)
+			}
+			helpers.AssertFileNotExists(t, encryptedPath)
+		})
+	}
+}
+
+func TestEncryptOptions_NegativeMinPasswordLength(t *testing.T) {
+	opts := operations.EncryptOptions{MinPasswordLength: -1}
+	if err := opts.Validate(); err == nil {
+		t.Fatal("Expected a negative minimum length to be rejected")
+	}
+}
@@ -0,0 +1,73 @@
+package business
+
+import (
+	"bytes"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/hambosto/hexwarden/internal/constants"
+	"github.com/hambosto/hexwarden/internal/usecase/operations"
+	"github.com/hambosto/hexwarden/tests/helpers"
+)
+
+func TestPepper_RequiredToDecrypt(t *testing.T) {
+	plaintext := bytes.Repeat([]byte("peppered "), 1000)
+	pepper := []byte("server-side secret")
+
+	encryptor := operations.NewEncryptorWithKDF(cheapKDF)
+	encryptor.SetPepper(pepper)
+	var encrypted bytes.Buffer
+	_, err := encryptor.EncryptStream(bytes.NewReader(plaintext), &encrypted, int64(len(plaintext)), testPassword, operations.EncryptOptions{})
+	helpers.AssertNoError(t, err)
+
+	tests := []struct {
+		name    string
+		pepper  []byte
+		wantErr error
+	}{
+		{name: "Same pepper", pepper: pepper},
+		{name: "Different pepper", pepper: []byte("another secret"), wantErr: constants.ErrAuthFailure},
+		{name: "No pepper", wantErr: constants.ErrPepperRequired},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			decryptor := operations.NewDecryptorWithKDF(cheapKDF)
+			decryptor.SetPepper(tt.pepper)
+
+			var out bytes.Buffer
+			info, err := decryptor.DecryptStream(bytes.NewReader(encrypted.Bytes()), &out, testPassword)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("Expected %v, got %v", tt.wantErr, err)
+				}
+				return
+			}
+			helpers.AssertNoError(t, err)
+			helpers.AssertEqual(t, true, info.Peppered)
+			helpers.AssertBytesEqual(t, plaintext, out.Bytes())
+		})
+	}
+
+	// The mark is public, so a locked file tells the user a pepper is needed
+	path := filepath.Join(t.TempDir(), "peppered.hex")
+	helpers.WriteFileContent(t, path, encrypted.Bytes())
+	info, err := operations.NewDecryptorWithKDF(cheapKDF).Inspect(path, "")
+	helpers.AssertNoError(t, err)
+	helpers.AssertEqual(t, true, info.Peppered)
+}
+
+func TestPepper_UnpepperedFilesIgnoreIt(t *testing.T) {
+	plaintext := []byte("written before the pepper was introduced")
+	encrypted := encryptBytes(t, plaintext, operations.EncryptOptions{})
+
+	decryptor := operations.NewDecryptorWithKDF(cheapKDF)
+	decryptor.SetPepper([]byte("server-side secret"))
+
+	var out bytes.Buffer
+	info, err := decryptor.DecryptStream(bytes.NewReader(encrypted), &out, testPassword)
+	helpers.AssertNoError(t, err)
+	helpers.AssertEqual(t, false, info.Peppered)
+	helpers.AssertBytesEqual(t, plaintext, out.Bytes())
+}
@@ -0,0 +1,109 @@
+package business
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"path/filepath"
+	"testing"
+
+	"github.com/hambosto/hexwarden/internal/constants"
+	"github.com/hambosto/hexwarden/internal/usecase/operations"
+	"github.com/hambosto/hexwarden/tests/helpers"
+)
+
+func TestProtect_RoundTrip(t *testing.T) {
+	tmpDir := helpers.CreateTempDir(t)
+	defer helpers.CleanupTempDir(t, tmpDir)
+
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{name: "Empty", data: []byte{}},
+		{name: "Small", data: []byte("public dataset row 1")},
+		{name: "Multiple chunks", data: bytes.Repeat([]byte("0123456789abcdef"), constants.DefaultChunkSize/8+3)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			inputPath := filepath.Join(tmpDir, tt.name+".bin")
+			protectedPath := inputPath + constants.FileExtension
+			outputPath := filepath.Join(tmpDir, tt.name+".out")
+			helpers.WriteFileContent(t, inputPath, tt.data)
+
+			result, err := operations.NewEncryptor().ProtectFile(inputPath, protectedPath)
+			helpers.AssertNoError(t, err)
+			helpers.AssertEqual(t, int64(len(helpers.ReadFileContent(t, protectedPath))), result.Size)
+
+			info, err := operations.NewDecryptor().RecoverFile(protectedPath, outputPath)
+			helpers.AssertNoError(t, err)
+			helpers.AssertEqual(t, true, info.Unencrypted)
+			helpers.AssertEqual(t, uint64(len(tt.data)), info.OriginalSize)
+			helpers.AssertBytesEqual(t, tt.data, helpers.ReadFileContent(t, outputPath))
+
+			var buf bytes.Buffer
+			_, err = operations.NewDecryptor().RecoverStream(bytes.NewReader(helpers.ReadFileContent(t, protectedPath)), &buf)
+			helpers.AssertNoError(t, err)
+			helpers.AssertBytesEqual(t, tt.data, buf.Bytes())
+		})
+	}
+}
+
+func TestProtect_RecoversCorruption(t *testing.T) {
+	tmpDir := helpers.CreateTempDir(t)
+	defer helpers.CleanupTempDir(t, tmpDir)
+
+	plaintext := bytes.Repeat([]byte("archival copy of a public dataset\n"), 5000)
+	inputPath := filepath.Join(tmpDir, "input.txt")
+	protectedPath := inputPath + constants.FileExtension
+	helpers.WriteFileContent(t, inputPath, plaintext)
+
+	_, err := operations.NewEncryptor().ProtectFile(inputPath, protectedPath)
+	helpers.AssertNoError(t, err)
+
+	// Damage two shards of the chunk, within what Reed-Solomon can repair
+	data := helpers.ReadFileContent(t, protectedPath)
+	start, length := lastChunk(t, data)
+	shardSize := length / (constants.DataShards + constants.ParityShards)
+	data[start+1] ^= 0xFF
+	data[start+3*shardSize+shardSize/2] ^= 0x5A
+	helpers.WriteFileContent(t, protectedPath, data)
+
+	decryptor := operations.NewDecryptor()
+	report, err := decryptor.Scan(protectedPath)
+	helpers.AssertNoError(t, err)
+	helpers.AssertEqual(t, 1, report.Damaged())
+
+	outputPath := filepath.Join(tmpDir, "output.txt")
+	_, err = decryptor.RecoverFile(protectedPath, outputPath)
+	helpers.AssertNoError(t, err)
+	helpers.AssertBytesEqual(t, plaintext, helpers.ReadFileContent(t, outputPath))
+
+	// Beyond repair the output is refused rather than written wrong
+	corruptLastChunk(t, protectedPath)
+	_, err = decryptor.RecoverFile(protectedPath, filepath.Join(tmpDir, "broken.txt"))
+	if err == nil {
+		t.Fatal("Expected recovery of an unrepairable chunk to fail")
+	}
+	helpers.AssertFileNotExists(t, filepath.Join(tmpDir, "broken.txt"))
+}
+
+func TestProtect_WrongCommand(t *testing.T) {
+	plaintext := []byte("not a secret")
+	protected := &bytes.Buffer{}
+	_, err := operations.NewEncryptor().ProtectStream(bytes.NewReader(plaintext), protected, int64(len(plaintext)))
+	helpers.AssertNoError(t, err)
+
+	encrypted := encryptBytes(t, plaintext, operations.EncryptOptions{})
+
+	_, err = operations.NewDecryptorWithKDF(cheapKDF).DecryptStream(bytes.NewReader(protected.Bytes()), io.Discard, testPassword)
+	if !errors.Is(err, constants.ErrUnencrypted) {
+		t.Errorf("Expected ErrUnencrypted, got %v", err)
+	}
+
+	_, err = operations.NewDecryptor().RecoverStream(bytes.NewReader(encrypted), io.Discard)
+	if !errors.Is(err, constants.ErrEncrypted) {
+		t.Errorf("Expected ErrEncrypted, got %v", err)
+	}
+}
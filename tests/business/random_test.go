@@ -0,0 +1,54 @@
+package business
+
+import (
+	"bytes"
+	"errors"
+	mathrand "math/rand/v2"
+	"testing"
+
+	"github.com/hambosto/hexwarden/internal/constants"
+	"github.com/hambosto/hexwarden/internal/infrastructure/crypto"
+	"github.com/hambosto/hexwarden/internal/usecase/operations"
+)
+
+func TestEncryptOptions_Random(t *testing.T) {
+	plaintext := []byte("seeded random source")
+
+	t.Run("Seeded source reproduces the header", func(t *testing.T) {
+		header := func() []byte {
+			encrypted := encryptBytes(t, plaintext, operations.EncryptOptions{Random: mathrand.NewChaCha8([32]byte{9})})
+			h, err := crypto.ReadHeader(bytes.NewReader(encrypted))
+			if err != nil {
+				t.Fatalf("ReadHeader failed: %v", err)
+			}
+			var buf bytes.Buffer
+			if err := h.Write(&buf); err != nil {
+				t.Fatalf("Write failed: %v", err)
+			}
+			return buf.Bytes()
+		}
+		if !bytes.Equal(header(), header()) {
+			t.Error("headers from the same seed should be identical")
+		}
+	})
+
+	t.Run("Output still decrypts", func(t *testing.T) {
+		encrypted := encryptBytes(t, plaintext, operations.EncryptOptions{Random: mathrand.NewChaCha8([32]byte{9})})
+		var out bytes.Buffer
+		if _, err := operations.NewDecryptorWithKDF(cheapKDF).DecryptStream(bytes.NewReader(encrypted), &out, testPassword); err != nil {
+			t.Fatalf("Decryption failed: %v", err)
+		}
+		if !bytes.Equal(out.Bytes(), plaintext) {
+			t.Error("decrypted output does not match")
+		}
+	})
+
+	t.Run("Weak source is rejected", func(t *testing.T) {
+		var out bytes.Buffer
+		opts := operations.EncryptOptions{Random: bytes.NewReader(make([]byte, 1024))}
+		_, err := operations.NewEncryptorWithKDF(cheapKDF).EncryptStream(bytes.NewReader(plaintext), &out, int64(len(plaintext)), testPassword, opts)
+		if !errors.Is(err, constants.ErrWeakRandom) {
+			t.Fatalf("expected ErrWeakRandom, got %v", err)
+		}
+	})
+}
@@ -0,0 +1,69 @@
+package business
+
+import (
+	"bytes"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/hambosto/hexwarden/internal/constants"
+	"github.com/hambosto/hexwarden/internal/usecase/operations"
+	"github.com/hambosto/hexwarden/tests/helpers"
+)
+
+func TestDecryptRange_MatchesPlaintextSlice(t *testing.T) {
+	tmpDir := helpers.CreateTempDir(t)
+	defer helpers.CleanupTempDir(t, tmpDir)
+
+	// Bytes that differ from chunk to chunk, so a slice from the wrong chunk shows
+	chunkSize := uint64(constants.DefaultChunkSize)
+	plaintext := make([]byte, 3*chunkSize+chunkSize/2)
+	for i := range plaintext {
+		plaintext[i] = byte(i*7 + i/int(chunkSize))
+	}
+	chunkedPath := filepath.Join(tmpDir, "chunked.hex")
+	helpers.WriteFileContent(t, chunkedPath, encryptBytes(t, plaintext, operations.EncryptOptions{}))
+
+	small := []byte("a small file kept in a single raw body")
+	rawPath := filepath.Join(tmpDir, "raw.hex")
+	helpers.WriteFileContent(t, rawPath, encryptBytes(t, small, operations.EncryptOptions{SmallFileThreshold: smallFileThreshold}))
+
+	size := uint64(len(plaintext))
+	tests := []struct {
+		name string
+		path string
+		data []byte
+		rng  operations.ByteRange
+	}{
+		{name: "Mid-file across chunk boundaries", path: chunkedPath, data: plaintext, rng: operations.ByteRange{Start: chunkSize/2 + 3, End: 2*chunkSize + 100}},
+		{name: "Inside one chunk", path: chunkedPath, data: plaintext, rng: operations.ByteRange{Start: chunkSize + 10, End: chunkSize + 20}},
+		{name: "First byte", path: chunkedPath, data: plaintext, rng: operations.ByteRange{Start: 0, End: 0}},
+		{name: "Exactly one chunk", path: chunkedPath, data: plaintext, rng: operations.ByteRange{Start: chunkSize, End: 2*chunkSize - 1}},
+		{name: "Tail of the last chunk", path: chunkedPath, data: plaintext, rng: operations.ByteRange{Start: size - 5, End: size - 1}},
+		{name: "Raw body", path: rawPath, data: small, rng: operations.ByteRange{Start: 2, End: 6}},
+	}
+
+	decryptor := operations.NewDecryptorWithKDF(cheapKDF)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			want := tt.data[tt.rng.Start : tt.rng.End+1]
+
+			outputPath := filepath.Join(tmpDir, "range.out")
+			_, err := decryptor.DecryptRange(tt.path, outputPath, testPassword, tt.rng)
+			helpers.AssertNoError(t, err)
+			helpers.AssertBytesEqual(t, want, helpers.ReadFileContent(t, outputPath))
+
+			var buf bytes.Buffer
+			_, err = decryptor.DecryptRangeTo(tt.path, &buf, testPassword, tt.rng)
+			helpers.AssertNoError(t, err)
+			helpers.AssertBytesEqual(t, want, buf.Bytes())
+		})
+	}
+
+	for _, rng := range []operations.ByteRange{{Start: 0, End: size}, {Start: 10, End: 5}} {
+		var buf bytes.Buffer
+		if _, err := decryptor.DecryptRangeTo(chunkedPath, &buf, testPassword, rng); !errors.Is(err, constants.ErrInvalidRange) {
+			t.Errorf("Expected %v for %d-%d, got %v", constants.ErrInvalidRange, rng.Start, rng.End, err)
+		}
+	}
+}
@@ -0,0 +1,89 @@
+package business
+
+import (
+	"bytes"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/hambosto/hexwarden/internal/constants"
+	"github.com/hambosto/hexwarden/internal/infrastructure/crypto"
+	"github.com/hambosto/hexwarden/internal/usecase/operations"
+	"github.com/hambosto/hexwarden/tests/helpers"
+)
+
+func TestRecompress_RoundTrip(t *testing.T) {
+	tmpDir := helpers.CreateTempDir(t)
+	defer helpers.CleanupTempDir(t, tmpDir)
+
+	plaintext := bytes.Repeat([]byte("recompressed without a second key derivation\n"), 3*constants.DefaultChunkSize/40)
+	inputPath := filepath.Join(tmpDir, "input.txt")
+	encryptedPath := inputPath + constants.FileExtension
+	helpers.WriteFileContent(t, inputPath, plaintext)
+
+	opts := operations.EncryptOptions{Compression: constants.CompressionRawDeflate, Merkle: true, Comment: "kept"}
+	_, err := operations.NewEncryptorWithKDF(cheapKDF).EncryptFile(inputPath, encryptedPath, testPassword, opts)
+	helpers.AssertNoError(t, err)
+
+	saltBefore := readSalt(t, encryptedPath)
+	decryptor := operations.NewDecryptorWithKDF(cheapKDF)
+	before, err := decryptor.Inspect(encryptedPath, testPassword)
+	helpers.AssertNoError(t, err)
+	helpers.AssertEqual(t, constants.CompressionRawDeflate, before.Compression)
+
+	helpers.AssertNoError(t, decryptor.Recompress(encryptedPath, testPassword, constants.CompressionGzip))
+
+	after, err := decryptor.Inspect(encryptedPath, testPassword)
+	helpers.AssertNoError(t, err)
+	helpers.AssertEqual(t, constants.CompressionGzip, after.Compression)
+	helpers.AssertBytesEqual(t, saltBefore, readSalt(t, encryptedPath))
+	helpers.AssertEqual(t, "kept", after.Comment)
+	helpers.AssertBytesNotEqual(t, before.MerkleRoot, after.MerkleRoot)
+
+	// The new Merkle root covers the rewritten chunks
+	helpers.AssertNoError(t, decryptor.VerifyChunk(encryptedPath, testPassword, 0, nil))
+
+	var out bytes.Buffer
+	_, err = decryptor.DecryptStream(bytes.NewReader(helpers.ReadFileContent(t, encryptedPath)), &out, testPassword)
+	helpers.AssertNoError(t, err)
+	helpers.AssertBytesEqual(t, plaintext, out.Bytes())
+}
+
+func TestRecompress_Failures(t *testing.T) {
+	tmpDir := helpers.CreateTempDir(t)
+	defer helpers.CleanupTempDir(t, tmpDir)
+
+	inputPath := filepath.Join(tmpDir, "input.txt")
+	encryptedPath := inputPath + constants.FileExtension
+	helpers.WriteFileContent(t, inputPath, bytes.Repeat([]byte("x"), 4096))
+
+	_, err := operations.NewEncryptorWithKDF(cheapKDF).EncryptFile(inputPath, encryptedPath, testPassword, operations.EncryptOptions{})
+	helpers.AssertNoError(t, err)
+	original := helpers.ReadFileContent(t, encryptedPath)
+
+	decryptor := operations.NewDecryptorWithKDF(cheapKDF)
+	if err := decryptor.Recompress(encryptedPath, testPassword, constants.CompressionGzip); !errors.Is(err, constants.ErrInvalidOption) {
+		t.Errorf("Expected ErrInvalidOption for an unchanged format, got %v", err)
+	}
+	if err := decryptor.Recompress(encryptedPath, "wrong password", constants.CompressionRawDeflate); !errors.Is(err, constants.ErrAuthFailure) {
+		t.Errorf("Expected ErrAuthFailure, got %v", err)
+	}
+	helpers.AssertBytesEqual(t, original, helpers.ReadFileContent(t, encryptedPath))
+
+	smallPath := filepath.Join(tmpDir, "small.txt")
+	rawPath := smallPath + constants.FileExtension
+	helpers.WriteFileContent(t, smallPath, []byte("too small for chunks"))
+	_, err = operations.NewEncryptorWithKDF(cheapKDF).EncryptFile(smallPath, rawPath, testPassword, operations.EncryptOptions{SmallFileThreshold: smallFileThreshold})
+	helpers.AssertNoError(t, err)
+	if err := decryptor.Recompress(rawPath, testPassword, constants.CompressionRawDeflate); !errors.Is(err, constants.ErrInvalidOption) {
+		t.Errorf("Expected ErrInvalidOption for a raw body, got %v", err)
+	}
+}
+
+// readSalt returns the salt in the header of the encrypted file at path
+func readSalt(t *testing.T, path string) []byte {
+	t.Helper()
+	header, err := crypto.ReadHeader(bytes.NewReader(helpers.ReadFileContent(t, path)))
+	helpers.AssertNoError(t, err)
+	return header.Salt()
+}
@@ -0,0 +1,94 @@
+package business
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/hambosto/hexwarden/internal/constants"
+	"github.com/hambosto/hexwarden/internal/infrastructure/crypto"
+	"github.com/hambosto/hexwarden/internal/usecase/operations"
+	"github.com/hambosto/hexwarden/tests/helpers"
+)
+
+func TestRecoveryCode_Unlock(t *testing.T) {
+	plaintext := bytes.Repeat([]byte("recoverable "), 10000)
+	code, err := crypto.NewRecoveryCode()
+	helpers.AssertNoError(t, err)
+	other, err := crypto.NewRecoveryCode()
+	helpers.AssertNoError(t, err)
+
+	recoverable := encryptBytes(t, plaintext, operations.EncryptOptions{RecoveryCode: code})
+	plain := encryptBytes(t, plaintext, operations.EncryptOptions{})
+
+	tests := []struct {
+		name      string
+		encrypted []byte
+		password  string
+		code      string
+		wantErr   error
+	}{
+		{name: "Password", encrypted: recoverable, password: testPassword},
+		{name: "Recovery code", encrypted: recoverable, password: "forgotten", code: code},
+		{name: "Recovery code without dashes in lower case", encrypted: recoverable, code: strings.ToLower(strings.ReplaceAll(code, "-", ""))},
+		{name: "Wrong password", encrypted: recoverable, password: "forgotten", wantErr: constants.ErrAuthFailure},
+		{name: "Wrong recovery code", encrypted: recoverable, code: other, wantErr: constants.ErrAuthFailure},
+		{name: "Malformed recovery code", encrypted: recoverable, code: "ABCD-EFGH", wantErr: constants.ErrRecoveryCode},
+		{name: "Opted out, password", encrypted: plain, password: testPassword},
+		{name: "Opted out, recovery code", encrypted: plain, code: code, wantErr: constants.ErrNoRecoveryCode},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			decryptor := operations.NewDecryptorWithKDF(cheapKDF)
+			decryptor.SetRecoveryCode(tt.code)
+
+			var out bytes.Buffer
+			info, err := decryptor.DecryptStream(bytes.NewReader(tt.encrypted), &out, tt.password)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("Expected %v, got %v", tt.wantErr, err)
+				}
+				return
+			}
+			helpers.AssertNoError(t, err)
+			helpers.AssertBytesEqual(t, plaintext, out.Bytes())
+			helpers.AssertEqual(t, bytes.Equal(tt.encrypted, recoverable), info.Recoverable)
+		})
+	}
+}
+
+func TestRecoveryCode_TamperedWrapRejected(t *testing.T) {
+	code, err := crypto.NewRecoveryCode()
+	helpers.AssertNoError(t, err)
+	encrypted := encryptBytes(t, []byte("secret"), operations.EncryptOptions{RecoveryCode: code})
+
+	// The wrapped key is authenticated by the header, so the password no longer
+	// unlocks a file whose wrap was swapped either
+	header, err := crypto.ReadHeader(bytes.NewReader(encrypted))
+	helpers.AssertNoError(t, err)
+	// Salt, GCM nonce, key and GCM tag
+	wrappedSize := constants.RecoverySaltSize + 12 + constants.KeySize + 16
+	index := bytes.Index(encrypted[:header.Size()], []byte{byte(constants.TagRecoveryKey), 0, byte(wrappedSize)})
+	if index < 0 {
+		t.Fatal("Expected the header to hold a recovery key entry")
+	}
+	encrypted[index+3+constants.RecoverySaltSize] ^= 0x01
+
+	_, err = operations.NewDecryptorWithKDF(cheapKDF).DecryptStream(bytes.NewReader(encrypted), &bytes.Buffer{}, testPassword)
+	if err == nil {
+		t.Fatal("Expected a tampered recovery key to be rejected")
+	}
+}
+
+func TestRecoveryCode_InvalidOption(t *testing.T) {
+	err := operations.EncryptOptions{RecoveryCode: "not a code"}.Validate()
+	var optErr *operations.OptionError
+	if !errors.As(err, &optErr) || optErr.Option != "RecoveryCode" {
+		t.Fatalf("Expected an OptionError for RecoveryCode, got %v", err)
+	}
+	if !errors.Is(err, constants.ErrRecoveryCode) {
+		t.Errorf("Expected ErrRecoveryCode, got %v", err)
+	}
+}
@@ -0,0 +1,110 @@
+package business
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/hambosto/hexwarden/internal/constants"
+	"github.com/hambosto/hexwarden/internal/usecase/operations"
+	"github.com/hambosto/hexwarden/tests/helpers"
+)
+
+// passwordQueue returns a prompt that hands out passwords in order, counting the prompts
+func passwordQueue(passwords ...string) (func() (string, error), *int) {
+	prompts := 0
+	return func() (string, error) {
+		if prompts == len(passwords) {
+			return "", constants.ErrPromptFailed
+		}
+		prompts++
+		return passwords[prompts-1], nil
+	}, &prompts
+}
+
+func TestRetryPolicy_DecryptFile(t *testing.T) {
+	tmpDir := helpers.CreateTempDir(t)
+	defer helpers.CleanupTempDir(t, tmpDir)
+
+	plaintext := []byte("retry test contents")
+	encryptedPath := filepath.Join(tmpDir, "secret.txt.hex")
+	helpers.WriteFileContent(t, encryptedPath, encryptBytes(t, plaintext, operations.EncryptOptions{}))
+
+	tests := []struct {
+		name            string
+		first           string
+		retries         []string
+		expectedErr     error
+		expectedPrompts int
+		expectedRetries []int
+	}{
+		{
+			name:            "Two wrong then correct",
+			first:           "wrong one",
+			retries:         []string{"wrong two", testPassword},
+			expectedPrompts: 2,
+			expectedRetries: []int{2, 1},
+		},
+		{
+			name:            "Correct first time",
+			first:           testPassword,
+			expectedPrompts: 0,
+		},
+		{
+			name:            "Gives up after three attempts",
+			first:           "wrong one",
+			retries:         []string{"wrong two", "wrong three", testPassword},
+			expectedErr:     constants.ErrTooManyAttempts,
+			expectedPrompts: 2,
+			expectedRetries: []int{2, 1},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			outputPath := filepath.Join(tmpDir, tt.name+".txt")
+			prompt, prompts := passwordQueue(tt.retries...)
+			var retries []int
+			policy := operations.RetryPolicy{
+				Attempts: 3,
+				OnRetry:  func(remaining int) { retries = append(retries, remaining) },
+			}
+
+			decryptor := operations.NewDecryptorWithKDF(cheapKDF)
+			err := policy.Run(tt.first, prompt, func(password string) error {
+				_, err := decryptor.DecryptFile(encryptedPath, outputPath, password)
+				return err
+			})
+			if !errors.Is(err, tt.expectedErr) {
+				t.Fatalf("Expected %v, got %v", tt.expectedErr, err)
+			}
+			helpers.AssertEqual(t, tt.expectedPrompts, *prompts)
+			helpers.AssertEqual(t, len(tt.expectedRetries), len(retries))
+			for i := range retries {
+				helpers.AssertEqual(t, tt.expectedRetries[i], retries[i])
+			}
+
+			if tt.expectedErr != nil {
+				if !errors.Is(err, constants.ErrAuthFailure) {
+					t.Errorf("Expected %v to also be reported, got %v", constants.ErrAuthFailure, err)
+				}
+				helpers.AssertFileNotExists(t, outputPath)
+				return
+			}
+			helpers.AssertBytesEqual(t, plaintext, helpers.ReadFileContent(t, outputPath))
+		})
+	}
+}
+
+func TestRetryPolicy_OtherErrorsNotRetried(t *testing.T) {
+	prompt, prompts := passwordQueue(testPassword)
+	policy := operations.RetryPolicy{Attempts: 3}
+
+	err := policy.Run(testPassword, prompt, func(string) error {
+		return constants.ErrFileNotFound
+	})
+	if !errors.Is(err, constants.ErrFileNotFound) {
+		t.Fatalf("Expected %v, got %v", constants.ErrFileNotFound, err)
+	}
+	helpers.AssertEqual(t, 0, *prompts)
+}
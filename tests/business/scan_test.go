@@ -0,0 +1,145 @@
+package business
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"github.com/hambosto/hexwarden/internal/constants"
+	"github.com/hambosto/hexwarden/internal/usecase/operations"
+	"github.com/hambosto/hexwarden/tests/helpers"
+)
+
+func TestScanAndVerify_ReportCorruptShard(t *testing.T) {
+	tmpDir := helpers.CreateTempDir(t)
+	defer helpers.CleanupTempDir(t, tmpDir)
+
+	inputPath := filepath.Join(tmpDir, "input.txt")
+	encryptedPath := inputPath + constants.FileExtension
+	helpers.WriteFileContent(t, inputPath, []byte("shard location reporting test data"))
+
+	encryptor := operations.NewEncryptor()
+	_, err := encryptor.EncryptFile(inputPath, encryptedPath, testPassword, operations.EncryptOptions{})
+	helpers.AssertNoError(t, err)
+
+	decryptor := operations.NewDecryptor()
+
+	report, err := decryptor.Scan(encryptedPath)
+	helpers.AssertNoError(t, err)
+	helpers.AssertEqual(t, 1, report.Chunks)
+	helpers.AssertEqual(t, 0, len(report.Problems))
+
+	// Damage one byte of shard 6 in the only chunk
+	const corruptShard = 6
+	data := helpers.ReadFileContent(t, encryptedPath)
+	start, length := lastChunk(t, data)
+	shardSize := length / (constants.DataShards + constants.ParityShards)
+	data[start+corruptShard*shardSize+2] ^= 0xFF
+	helpers.WriteFileContent(t, encryptedPath, data)
+
+	tests := []struct {
+		name      string
+		run       func() (*operations.ScanReport, error)
+		decrypted bool
+	}{
+		{name: "Scan", run: func() (*operations.ScanReport, error) { return decryptor.Scan(encryptedPath) }},
+		{name: "Verify", run: func() (*operations.ScanReport, error) { return decryptor.Verify(encryptedPath, testPassword) }, decrypted: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			report, err := tt.run()
+			helpers.AssertNoError(t, err)
+			helpers.AssertEqual(t, tt.decrypted, report.Decrypted)
+			helpers.AssertEqual(t, 1, report.Damaged())
+			helpers.AssertEqual(t, 0, report.Failed())
+			helpers.AssertEqual(t, 0, report.Problems[0].Index)
+			helpers.AssertEqual(t, fmt.Sprint([]int{corruptShard}), fmt.Sprint(report.Problems[0].Corrupted))
+		})
+	}
+}
+
+func TestVerify_UnrecoverableChunk(t *testing.T) {
+	tmpDir := helpers.CreateTempDir(t)
+	defer helpers.CleanupTempDir(t, tmpDir)
+
+	inputPath := filepath.Join(tmpDir, "input.txt")
+	encryptedPath := inputPath + constants.FileExtension
+	helpers.WriteFileContent(t, inputPath, []byte("unrecoverable chunk test data"))
+
+	encryptor := operations.NewEncryptor()
+	_, err := encryptor.EncryptFile(inputPath, encryptedPath, testPassword, operations.EncryptOptions{})
+	helpers.AssertNoError(t, err)
+	corruptLastChunk(t, encryptedPath)
+
+	report, err := operations.NewDecryptor().Verify(encryptedPath, testPassword)
+	if !errors.Is(err, constants.ErrUnrecoverable) {
+		t.Fatalf("Expected %v, got %v", constants.ErrUnrecoverable, err)
+	}
+	helpers.AssertEqual(t, 1, report.Failed())
+}
+
+func TestScanAndVerify_ReconstructionToggle(t *testing.T) {
+	tmpDir := helpers.CreateTempDir(t)
+	defer helpers.CleanupTempDir(t, tmpDir)
+
+	inputPath := filepath.Join(tmpDir, "input.txt")
+	cleanPath := filepath.Join(tmpDir, "clean.hex")
+	corruptPath := filepath.Join(tmpDir, "corrupt.hex")
+	helpers.WriteFileContent(t, inputPath, []byte("reconstruction toggle test data"))
+
+	_, err := operations.NewEncryptor().EncryptFile(inputPath, cleanPath, testPassword, operations.EncryptOptions{})
+	helpers.AssertNoError(t, err)
+
+	// Damage one byte of parity shard 9, so the data shards still decrypt
+	data := helpers.ReadFileContent(t, cleanPath)
+	start, length := lastChunk(t, data)
+	shardSize := length / (constants.DataShards + constants.ParityShards)
+	data[start+9*shardSize] ^= 0xFF
+	helpers.WriteFileContent(t, corruptPath, data)
+
+	decryptor := operations.NewDecryptor()
+	scan := func(path string, opts operations.ScanOptions) (*operations.ScanReport, error) {
+		return decryptor.ScanWithOptions(path, opts)
+	}
+	verify := func(path string, opts operations.ScanOptions) (*operations.ScanReport, error) {
+		return decryptor.VerifyWithOptions(path, testPassword, opts)
+	}
+
+	tests := []struct {
+		name          string
+		run           func(string, operations.ScanOptions) (*operations.ScanReport, error)
+		path          string
+		noReconstruct bool
+		clean         int
+		damaged       int
+		expectedErr   error
+	}{
+		{name: "Scan clean file", run: scan, path: cleanPath, clean: 1},
+		{name: "Scan clean file without reconstruction", run: scan, path: cleanPath, noReconstruct: true, clean: 1},
+		{name: "Scan corrupt file", run: scan, path: corruptPath, damaged: 1},
+		{name: "Scan corrupt file without reconstruction", run: scan, path: corruptPath, noReconstruct: true, expectedErr: constants.ErrUnrecoverable},
+		{name: "Verify clean file", run: verify, path: cleanPath, clean: 1},
+		{name: "Verify corrupt file", run: verify, path: corruptPath, damaged: 1},
+		{name: "Verify corrupt file without reconstruction", run: verify, path: corruptPath, noReconstruct: true, expectedErr: constants.ErrUnrecoverable},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			report, err := tt.run(tt.path, operations.ScanOptions{NoReconstruct: tt.noReconstruct})
+			if !errors.Is(err, tt.expectedErr) {
+				t.Fatalf("Expected %v, got %v", tt.expectedErr, err)
+			}
+			helpers.AssertEqual(t, tt.clean, report.Clean())
+			helpers.AssertEqual(t, tt.damaged, report.Damaged())
+
+			if tt.expectedErr != nil {
+				helpers.AssertEqual(t, 1, report.Failed())
+				if !errors.Is(report.Problems[0].Err, constants.ErrParityMismatch) {
+					t.Errorf("Expected %v, got %v", constants.ErrParityMismatch, report.Problems[0].Err)
+				}
+			}
+		})
+	}
+}
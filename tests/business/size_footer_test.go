@@ -0,0 +1,116 @@
+package business
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/hambosto/hexwarden/internal/constants"
+	"github.com/hambosto/hexwarden/internal/usecase/operations"
+	"github.com/hambosto/hexwarden/tests/helpers"
+)
+
+// encryptUnknownSize encrypts plaintext as a source whose size is not known in advance
+func encryptUnknownSize(tb testing.TB, plaintext []byte) []byte {
+	tb.Helper()
+
+	var out bytes.Buffer
+	_, err := operations.NewEncryptorWithKDF(cheapKDF).EncryptStream(readerOnly{bytes.NewReader(plaintext)}, &out, constants.UnknownSize, testPassword, operations.EncryptOptions{})
+	if err != nil {
+		tb.Fatalf("Encryption failed: %v", err)
+	}
+	return out.Bytes()
+}
+
+func TestSizeFooter_RoundTrip(t *testing.T) {
+	tests := []struct {
+		name    string
+		size    int
+		unknown bool
+	}{
+		{name: "Known size has no footer", size: 100_000},
+		{name: "Empty stream", unknown: true},
+		{name: "Short stream", size: 100, unknown: true},
+		{name: "Several chunks", size: 2*constants.DefaultChunkSize + 17, unknown: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			plaintext := bytes.Repeat([]byte("unknown length\n"), tt.size/15+1)[:tt.size]
+			encrypted := encryptBytes(t, plaintext, operations.EncryptOptions{})
+			if tt.unknown {
+				encrypted = encryptUnknownSize(t, plaintext)
+			}
+
+			path := filepath.Join(t.TempDir(), "file.hex")
+			helpers.AssertNoError(t, os.WriteFile(path, encrypted, 0o600))
+
+			decryptor := operations.NewDecryptorWithKDF(cheapKDF)
+			locked, err := decryptor.Inspect(path, "")
+			helpers.AssertNoError(t, err)
+			helpers.AssertEqual(t, tt.unknown, locked.SizeFooter)
+
+			unlocked, err := decryptor.Inspect(path, testPassword)
+			helpers.AssertNoError(t, err)
+			helpers.AssertEqual(t, uint64(tt.size), unlocked.OriginalSize)
+
+			var decrypted bytes.Buffer
+			_, err = decryptor.DecryptStream(readerOnly{bytes.NewReader(encrypted)}, &decrypted, testPassword)
+			helpers.AssertNoError(t, err)
+			helpers.AssertBytesEqual(t, plaintext, decrypted.Bytes())
+
+			report, err := decryptor.Verify(path, testPassword)
+			helpers.AssertNoError(t, err)
+			helpers.AssertEqual(t, 0, report.Failed())
+		})
+	}
+}
+
+func TestSizeFooter_DamagedEnd(t *testing.T) {
+	plaintext := bytes.Repeat([]byte("cut short\n"), 20_000)
+	encrypted := encryptUnknownSize(t, plaintext)
+	footerStart := len(encrypted) - constants.SizeFooterSize
+
+	tests := []struct {
+		name    string
+		damage  func([]byte) []byte
+		wantErr error
+	}{
+		{
+			name:    "Footer and marker removed",
+			damage:  func(b []byte) []byte { return b[:footerStart-constants.ChunkHeaderSize] },
+			wantErr: constants.ErrMissingFooter,
+		},
+		{
+			name:    "Footer cut off",
+			damage:  func(b []byte) []byte { return b[:footerStart+5] },
+			wantErr: constants.ErrMissingFooter,
+		},
+		{
+			name: "Footer forged",
+			damage: func(b []byte) []byte {
+				b[len(b)-1] ^= 0xFF
+				return b
+			},
+			wantErr: constants.ErrDecryptionFailed,
+		},
+		{
+			name:    "Data after footer",
+			damage:  func(b []byte) []byte { return append(b, 0) },
+			wantErr: constants.ErrFooterMismatch,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			damaged := tt.damage(bytes.Clone(encrypted))
+
+			_, err := operations.NewDecryptorWithKDF(cheapKDF).DecryptStream(bytes.NewReader(damaged), &bytes.Buffer{}, testPassword)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Expected %v, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,54 @@
+package business
+
+import (
+	"bytes"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/hambosto/hexwarden/internal/constants"
+	"github.com/hambosto/hexwarden/internal/usecase/operations"
+	"github.com/hambosto/hexwarden/tests/helpers"
+)
+
+func TestDecrypt_SizeMismatch(t *testing.T) {
+	plaintext := bytes.Repeat([]byte("declared size guard "), 3*constants.DefaultChunkSize/20)
+
+	tests := []struct {
+		name         string
+		declaredSize uint64
+		expectedErr  error
+	}{
+		{"Declared size matches", uint64(len(plaintext)), nil},
+		{"Declared one byte short", uint64(len(plaintext) - 1), constants.ErrSizeMismatch},
+		{"Declared one byte long", uint64(len(plaintext) + 1), constants.ErrSizeMismatch},
+		{"Declared a chunk long", uint64(len(plaintext) + constants.DefaultChunkSize), constants.ErrSizeMismatch},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := craftBomb(t, plaintext, tt.declaredSize)
+
+			var out bytes.Buffer
+			_, err := operations.NewDecryptorWithKDF(cheapKDF).DecryptStream(bytes.NewReader(data), &out, testPassword)
+			if !errors.Is(err, tt.expectedErr) {
+				t.Fatalf("Expected %v, got %v", tt.expectedErr, err)
+			}
+
+			// The file path must not leave the wrongly sized output behind
+			dir := t.TempDir()
+			inputPath := filepath.Join(dir, "tampered.hex")
+			outputPath := filepath.Join(dir, "tampered")
+			helpers.WriteFileContent(t, inputPath, data)
+			_, err = operations.NewDecryptorWithKDF(cheapKDF).DecryptFile(inputPath, outputPath, testPassword)
+			if !errors.Is(err, tt.expectedErr) {
+				t.Fatalf("Expected %v, got %v", tt.expectedErr, err)
+			}
+			if tt.expectedErr != nil {
+				helpers.AssertFileNotExists(t, outputPath)
+			} else {
+				helpers.AssertFileExists(t, outputPath)
+			}
+		})
+	}
+}
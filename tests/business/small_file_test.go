@@ -0,0 +1,138 @@
+package business
+
+import (
+	"bytes"
+	"crypto/rand"
+	"errors"
+	"testing"
+
+	"github.com/hambosto/hexwarden/internal/constants"
+	"github.com/hambosto/hexwarden/internal/infrastructure/crypto"
+	"github.com/hambosto/hexwarden/internal/usecase/operations"
+	"github.com/hambosto/hexwarden/tests/helpers"
+)
+
+const smallFileThreshold = 4096
+
+// cheapKDF keeps the many small encryptions in these tests fast
+func cheapKDF(password, salt []byte, _ crypto.KDFParams) ([]byte, error) {
+	return crypto.DeriveKeyWithParams(password, salt, crypto.KDFParams{Time: 1, Memory: 8 * 1024, Threads: 1})
+}
+
+// encryptBytes encrypts plaintext in memory with the given options
+func encryptBytes(tb testing.TB, plaintext []byte, opts operations.EncryptOptions) []byte {
+	tb.Helper()
+
+	var out bytes.Buffer
+	_, err := operations.NewEncryptorWithKDF(cheapKDF).EncryptStream(bytes.NewReader(plaintext), &out, int64(len(plaintext)), testPassword, opts)
+	if err != nil {
+		tb.Fatalf("Encryption failed: %v", err)
+	}
+	return out.Bytes()
+}
+
+func TestSmallFile_RoundTripAndSmallerOutput(t *testing.T) {
+	tests := []struct {
+		name    string
+		size    int
+		rawBody bool
+	}{
+		{name: "Single byte", size: 1, rawBody: true},
+		{name: "Short text", size: 100, rawBody: true},
+		{name: "Just below threshold", size: smallFileThreshold - 1, rawBody: true},
+		{name: "At threshold uses full pipeline", size: smallFileThreshold, rawBody: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			plaintext := make([]byte, tt.size)
+			_, _ = rand.Read(plaintext)
+
+			fast := encryptBytes(t, plaintext, operations.EncryptOptions{SmallFileThreshold: smallFileThreshold})
+			full := encryptBytes(t, plaintext, operations.EncryptOptions{})
+
+			var out bytes.Buffer
+			info, err := operations.NewDecryptorWithKDF(cheapKDF).DecryptStream(bytes.NewReader(fast), &out, testPassword)
+			helpers.AssertNoError(t, err)
+			helpers.AssertBytesEqual(t, plaintext, out.Bytes())
+			helpers.AssertEqual(t, tt.rawBody, info.RawBody)
+
+			if tt.rawBody && len(fast) >= len(full) {
+				t.Fatalf("Expected fast path output (%d bytes) to be smaller than full pipeline output (%d bytes)", len(fast), len(full))
+			}
+		})
+	}
+}
+
+func TestSmallFile_EmptySourceUsesFullPipeline(t *testing.T) {
+	encrypted := encryptBytes(t, nil, operations.EncryptOptions{SmallFileThreshold: smallFileThreshold})
+
+	header, err := crypto.ReadHeader(bytes.NewReader(encrypted))
+	helpers.AssertNoError(t, err)
+	helpers.AssertEqual(t, constants.HeaderFlags(0), header.Flags())
+}
+
+func TestSmallFile_TamperingDetected(t *testing.T) {
+	plaintext := []byte("small but still authenticated")
+	encrypted := encryptBytes(t, plaintext, operations.EncryptOptions{SmallFileThreshold: smallFileThreshold})
+
+	header, err := crypto.ReadHeader(bytes.NewReader(encrypted))
+	helpers.AssertNoError(t, err)
+
+	tests := []struct {
+		name        string
+		data        []byte
+		expectedErr error
+	}{
+		{
+			name:        "Flipped body byte",
+			data:        func() []byte { d := bytes.Clone(encrypted); d[len(d)-1] ^= 0xFF; return d }(),
+			expectedErr: constants.ErrDecryptionFailed,
+		},
+		{name: "Truncated body", data: encrypted[:len(encrypted)-1], expectedErr: constants.ErrRawBodySize},
+		{name: "Trailing data", data: append(bytes.Clone(encrypted), 0), expectedErr: constants.ErrRawBodySize},
+		{name: "Missing body", data: encrypted[:header.Size()], expectedErr: constants.ErrRawBodySize},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			_, err := operations.NewDecryptorWithKDF(cheapKDF).DecryptStream(bytes.NewReader(tt.data), &out, testPassword)
+			if !errors.Is(err, tt.expectedErr) {
+				t.Fatalf("Expected %v, got %v", tt.expectedErr, err)
+			}
+		})
+	}
+}
+
+func TestSmallFile_InvalidThreshold(t *testing.T) {
+	for _, threshold := range []int64{-1, constants.MaxRawBodySize + 1} {
+		opts := operations.EncryptOptions{SmallFileThreshold: threshold}
+		if err := opts.Validate(); err == nil {
+			t.Fatalf("Expected threshold %d to be rejected", threshold)
+		}
+	}
+}
+
+func BenchmarkEncryptStream_SmallFile(b *testing.B) {
+	plaintext := make([]byte, 1024)
+	_, _ = rand.Read(plaintext)
+
+	benchmarks := []struct {
+		name string
+		opts operations.EncryptOptions
+	}{
+		{name: "FullPipeline", opts: operations.EncryptOptions{}},
+		{name: "FastPath", opts: operations.EncryptOptions{SmallFileThreshold: smallFileThreshold}},
+	}
+
+	for _, bm := range benchmarks {
+		b.Run(bm.name, func(b *testing.B) {
+			var size int
+			for i := 0; i < b.N; i++ {
+				size = len(encryptBytes(b, plaintext, bm.opts))
+			}
+			b.ReportMetric(float64(size), "bytes/file")
+		})
+	}
+}
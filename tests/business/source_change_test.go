@@ -0,0 +1,98 @@
+package business
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/hambosto/hexwarden/internal/constants"
+	"github.com/hambosto/hexwarden/internal/usecase/operations"
+	"github.com/hambosto/hexwarden/tests/helpers"
+)
+
+// appendOnFirstRead appends to the file behind it the first time it is read,
+// like a log file written to while it is being encrypted
+type appendOnFirstRead struct {
+	file     *os.File
+	path     string
+	appended bool
+}
+
+func (r *appendOnFirstRead) Read(p []byte) (int, error) {
+	if !r.appended {
+		r.appended = true
+		f, err := os.OpenFile(r.path, os.O_APPEND|os.O_WRONLY, 0)
+		if err != nil {
+			return 0, err
+		}
+		_, err = f.Write([]byte("late log line\n"))
+		if closeErr := f.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			return 0, err
+		}
+	}
+	return r.file.Read(p)
+}
+
+func TestEncryptStream_SourceGrowsMidStream(t *testing.T) {
+	tmpDir := helpers.CreateTempDir(t)
+	defer helpers.CleanupTempDir(t, tmpDir)
+
+	tests := []struct {
+		name string
+		opts operations.EncryptOptions
+	}{
+		{name: "Chunked body", opts: operations.EncryptOptions{}},
+		{name: "Raw body", opts: operations.EncryptOptions{SmallFileThreshold: 4096}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			inputPath := filepath.Join(tmpDir, "growing.log")
+			helpers.WriteFileContent(t, inputPath, []byte("first log line\n"))
+
+			file, err := os.Open(inputPath)
+			helpers.AssertNoError(t, err)
+			defer file.Close() //nolint:errcheck
+
+			info, err := file.Stat()
+			helpers.AssertNoError(t, err)
+
+			src := &appendOnFirstRead{file: file, path: inputPath}
+			_, err = operations.NewEncryptorWithKDF(cheapKDF).EncryptStream(src, io.Discard, info.Size(), testPassword, tt.opts)
+			if !errors.Is(err, constants.ErrSourceChanged) {
+				t.Fatalf("Expected %v, got %v", constants.ErrSourceChanged, err)
+			}
+		})
+	}
+}
+
+func TestEncryptStream_SourceShrinks(t *testing.T) {
+	data := []byte("shorter than declared")
+
+	_, err := operations.NewEncryptorWithKDF(cheapKDF).EncryptStream(bytes.NewReader(data), io.Discard, int64(len(data))+10, testPassword, operations.EncryptOptions{})
+	if !errors.Is(err, constants.ErrSourceChanged) {
+		t.Fatalf("Expected %v, got %v", constants.ErrSourceChanged, err)
+	}
+}
+
+func TestEncryptFile_FailureRemovesOutput(t *testing.T) {
+	tmpDir := helpers.CreateTempDir(t)
+	defer helpers.CleanupTempDir(t, tmpDir)
+
+	// A directory opens fine but cannot be read, failing mid-encryption
+	inputPath := filepath.Join(tmpDir, "not-a-file")
+	helpers.AssertNoError(t, os.Mkdir(inputPath, 0o700))
+	outputPath := filepath.Join(tmpDir, "output.hex")
+
+	_, err := operations.NewEncryptorWithKDF(cheapKDF).EncryptFile(inputPath, outputPath, testPassword, operations.EncryptOptions{})
+	if err == nil {
+		t.Fatal("Expected encryption of a directory to fail")
+	}
+	helpers.AssertFileNotExists(t, outputPath)
+}
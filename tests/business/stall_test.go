@@ -0,0 +1,107 @@
+package business
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/hambosto/hexwarden/internal/constants"
+	"github.com/hambosto/hexwarden/internal/usecase/operations"
+	"github.com/hambosto/hexwarden/tests/helpers"
+)
+
+// stallingReader returns data and then blocks until release is closed
+type stallingReader struct {
+	data    io.Reader
+	release chan struct{}
+}
+
+// newStallingReader returns a reader that stalls after data, released when the
+// test finishes
+func newStallingReader(t *testing.T, data []byte) *stallingReader {
+	r := &stallingReader{data: bytes.NewReader(data), release: make(chan struct{})}
+	t.Cleanup(func() { close(r.release) })
+	return r
+}
+
+func (r *stallingReader) Read(p []byte) (int, error) {
+	n, err := r.data.Read(p)
+	if err == io.EOF {
+		<-r.release
+	}
+	return n, err
+}
+
+func TestIOTimeout_CancelsStalledStream(t *testing.T) {
+	const timeout = 100 * time.Millisecond
+
+	plaintext := bytes.Repeat([]byte("stalled mount "), 2*constants.DefaultChunkSize/14)
+	encrypted := encryptBytes(t, plaintext, operations.EncryptOptions{})
+
+	tests := []struct {
+		name  string
+		input []byte // Delivered before the reader stalls
+		run   func(r io.Reader) error
+	}{
+		{
+			name:  "Encrypt",
+			input: plaintext[:constants.DefaultChunkSize/2],
+			run: func(r io.Reader) error {
+				encryptor := operations.NewEncryptorWithKDF(cheapKDF)
+				encryptor.SetIOTimeout(timeout)
+				// Declares more input than the reader delivers before stalling
+				_, err := encryptor.EncryptStream(r, io.Discard, int64(len(plaintext)), testPassword, operations.EncryptOptions{})
+				return err
+			},
+		},
+		{
+			name:  "Decrypt",
+			input: encrypted[:len(encrypted)/2],
+			run: func(r io.Reader) error {
+				decryptor := operations.NewDecryptorWithKDF(cheapKDF)
+				decryptor.SetIOTimeout(timeout)
+				_, err := decryptor.DecryptStream(r, io.Discard, testPassword)
+				return err
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reader := newStallingReader(t, tt.input)
+
+			errCh := make(chan error, 1)
+			go func() { errCh <- tt.run(reader) }()
+
+			select {
+			case err := <-errCh:
+				if !errors.Is(err, constants.ErrStalled) {
+					t.Fatalf("Expected ErrStalled, got %v", err)
+				}
+			case <-time.After(10 * time.Second):
+				t.Fatal("Stalled stream was not cancelled")
+			}
+		})
+	}
+}
+
+func TestIOTimeout_ProgressingStreamCompletes(t *testing.T) {
+	plaintext := bytes.Repeat([]byte("steady progress "), 1000)
+
+	encryptor := operations.NewEncryptorWithKDF(cheapKDF)
+	encryptor.SetIOTimeout(time.Minute)
+
+	var encrypted bytes.Buffer
+	_, err := encryptor.EncryptStream(bytes.NewReader(plaintext), &encrypted, int64(len(plaintext)), testPassword, operations.EncryptOptions{})
+	helpers.AssertNoError(t, err)
+
+	decryptor := operations.NewDecryptorWithKDF(cheapKDF)
+	decryptor.SetIOTimeout(time.Minute)
+
+	var decrypted bytes.Buffer
+	_, err = decryptor.DecryptStream(bytes.NewReader(encrypted.Bytes()), &decrypted, testPassword)
+	helpers.AssertNoError(t, err)
+	helpers.AssertBytesEqual(t, plaintext, decrypted.Bytes())
+}
@@ -0,0 +1,67 @@
+package business
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/hambosto/hexwarden/internal/constants"
+	"github.com/hambosto/hexwarden/internal/usecase/operations"
+	"github.com/hambosto/hexwarden/tests/helpers"
+)
+
+// drainPipe reads r in the background, returning a channel that yields everything read
+func drainPipe(r *os.File) <-chan []byte {
+	done := make(chan []byte, 1)
+	go func() {
+		data, _ := io.ReadAll(r)
+		done <- data
+	}()
+	return done
+}
+
+func TestDecryptStream_IntoPipe(t *testing.T) {
+	plaintext := bytes.Repeat([]byte("streamed media "), constants.DefaultChunkSize/8)
+	encrypted := encryptBytes(t, plaintext, operations.EncryptOptions{})
+
+	r, w, err := os.Pipe()
+	helpers.AssertNoError(t, err)
+	defer r.Close() //nolint:errcheck
+	received := drainPipe(r)
+
+	_, err = operations.NewDecryptorWithKDF(cheapKDF).DecryptStream(bytes.NewReader(encrypted), w, testPassword)
+	helpers.AssertNoError(t, err)
+	helpers.AssertNoError(t, w.Close())
+
+	helpers.AssertBytesEqual(t, plaintext, <-received)
+}
+
+func TestDecryptFile_IntoPipePath(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skipf("Opening a pipe through /proc/self/fd is Linux specific, not %s", runtime.GOOS)
+	}
+
+	tmpDir := helpers.CreateTempDir(t)
+	defer helpers.CleanupTempDir(t, tmpDir)
+
+	plaintext := bytes.Repeat([]byte("streamed media "), constants.DefaultChunkSize/8)
+	encryptedPath := filepath.Join(tmpDir, "movie.mkv.hex")
+	helpers.WriteFileContent(t, encryptedPath, encryptBytes(t, plaintext, operations.EncryptOptions{}))
+
+	r, w, err := os.Pipe()
+	helpers.AssertNoError(t, err)
+	defer r.Close() //nolint:errcheck
+	received := drainPipe(r)
+
+	// The pipe already exists, which must not stop decryption into it
+	pipePath := fmt.Sprintf("/proc/self/fd/%d", w.Fd())
+	_, err = operations.NewDecryptorWithKDF(cheapKDF).DecryptFile(encryptedPath, pipePath, testPassword)
+	helpers.AssertNoError(t, err)
+	helpers.AssertNoError(t, w.Close())
+
+	helpers.AssertBytesEqual(t, plaintext, <-received)
+}
@@ -0,0 +1,58 @@
+package business
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/hambosto/hexwarden/internal/constants"
+	"github.com/hambosto/hexwarden/internal/usecase/operations"
+	"github.com/hambosto/hexwarden/tests/helpers"
+)
+
+// writerOnly hides every method except Write, so nothing can seek or close it
+type writerOnly struct {
+	w io.Writer
+}
+
+func (w writerOnly) Write(p []byte) (int, error) {
+	return w.w.Write(p)
+}
+
+// readerOnly hides every method except Read
+type readerOnly struct {
+	r io.Reader
+}
+
+func (r readerOnly) Read(p []byte) (int, error) {
+	return r.r.Read(p)
+}
+
+func TestStream_RoundTripThroughPlainWriter(t *testing.T) {
+	plaintext := bytes.Repeat([]byte("streamed to a plain io.Writer\n"), 50000)
+
+	var encrypted bytes.Buffer
+	_, err := operations.NewEncryptor().EncryptStream(
+		readerOnly{bytes.NewReader(plaintext)}, writerOnly{&encrypted}, int64(len(plaintext)), testPassword, operations.EncryptOptions{})
+	helpers.AssertNoError(t, err)
+
+	var decrypted bytes.Buffer
+	_, err = operations.NewDecryptor().DecryptStream(readerOnly{&encrypted}, writerOnly{&decrypted}, testPassword)
+	helpers.AssertNoError(t, err)
+	helpers.AssertBytesEqual(t, plaintext, decrypted.Bytes())
+}
+
+func TestStream_NilStreams(t *testing.T) {
+	encryptor := operations.NewEncryptor()
+	decryptor := operations.NewDecryptor()
+
+	_, err := encryptor.EncryptStream(nil, io.Discard, 0, testPassword, operations.EncryptOptions{})
+	helpers.AssertError(t, err, constants.ErrNilStream)
+	_, err = encryptor.EncryptStream(bytes.NewReader(nil), nil, 0, testPassword, operations.EncryptOptions{})
+	helpers.AssertError(t, err, constants.ErrNilStream)
+
+	_, err = decryptor.DecryptStream(nil, io.Discard, testPassword)
+	helpers.AssertError(t, err, constants.ErrNilStream)
+	_, err = decryptor.DecryptStream(bytes.NewReader(nil), nil, testPassword)
+	helpers.AssertError(t, err, constants.ErrNilStream)
+}
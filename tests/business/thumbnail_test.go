@@ -0,0 +1,104 @@
+package business
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"path/filepath"
+	"testing"
+
+	"github.com/hambosto/hexwarden/internal/constants"
+	"github.com/hambosto/hexwarden/internal/infrastructure/utils"
+	"github.com/hambosto/hexwarden/internal/usecase/operations"
+	"github.com/hambosto/hexwarden/tests/helpers"
+)
+
+// samplePNG returns a PNG image with a colour gradient of the given size
+func samplePNG(t *testing.T, width, height int) []byte {
+	t.Helper()
+
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			img.Set(x, y, color.RGBA{R: uint8(x), G: uint8(y), B: 128, A: 255})
+		}
+	}
+
+	var buf bytes.Buffer
+	helpers.AssertNoError(t, png.Encode(&buf, img))
+	return buf.Bytes()
+}
+
+func TestThumbnail_RoundTrip(t *testing.T) {
+	tmpDir := helpers.CreateTempDir(t)
+	defer helpers.CleanupTempDir(t, tmpDir)
+
+	source := samplePNG(t, 640, 480)
+	inputPath := filepath.Join(tmpDir, "photo.png")
+	encryptedPath := inputPath + constants.FileExtension
+	outputPath := filepath.Join(tmpDir, "photo-restored.png")
+	helpers.WriteFileContent(t, inputPath, source)
+
+	thumbnail, err := utils.Thumbnail(bytes.NewReader(source), constants.ThumbnailMaxDim)
+	helpers.AssertNoError(t, err)
+
+	opts := operations.EncryptOptions{Thumbnail: thumbnail}
+	_, err = operations.NewEncryptor().EncryptFile(inputPath, encryptedPath, testPassword, opts)
+	helpers.AssertNoError(t, err)
+
+	if bytes.Contains(helpers.ReadFileContent(t, encryptedPath), thumbnail) {
+		t.Fatal("Thumbnail must not be stored in plaintext")
+	}
+
+	decryptor := operations.NewDecryptor()
+
+	info, err := decryptor.Inspect(encryptedPath, "")
+	helpers.AssertNoError(t, err)
+	helpers.AssertEqual(t, true, info.HasThumbnail)
+	helpers.AssertEqual(t, 0, len(info.Thumbnail))
+
+	info, err = decryptor.Inspect(encryptedPath, testPassword)
+	helpers.AssertNoError(t, err)
+	helpers.AssertBytesEqual(t, thumbnail, info.Thumbnail)
+
+	preview, err := jpeg.Decode(bytes.NewReader(info.Thumbnail))
+	helpers.AssertNoError(t, err)
+	helpers.AssertEqual(t, image.Rect(0, 0, 160, 120), preview.Bounds())
+
+	_, err = decryptor.DecryptFile(encryptedPath, outputPath, testPassword)
+	helpers.AssertNoError(t, err)
+	helpers.AssertBytesEqual(t, source, helpers.ReadFileContent(t, outputPath))
+}
+
+func TestThumbnail_Dimensions(t *testing.T) {
+	tests := []struct {
+		name          string
+		width, height int
+		want          image.Rectangle
+	}{
+		{"landscape", 640, 480, image.Rect(0, 0, 160, 120)},
+		{"portrait", 300, 600, image.Rect(0, 0, 80, 160)},
+		{"smaller than limit", 100, 50, image.Rect(0, 0, 100, 50)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			thumbnail, err := utils.Thumbnail(bytes.NewReader(samplePNG(t, tt.width, tt.height)), constants.ThumbnailMaxDim)
+			helpers.AssertNoError(t, err)
+
+			preview, err := jpeg.Decode(bytes.NewReader(thumbnail))
+			helpers.AssertNoError(t, err)
+			helpers.AssertEqual(t, tt.want, preview.Bounds())
+		})
+	}
+}
+
+func TestThumbnail_NotAnImage(t *testing.T) {
+	_, err := utils.Thumbnail(bytes.NewReader([]byte("plain text, not an image")), constants.ThumbnailMaxDim)
+	if !errors.Is(err, constants.ErrNotAnImage) {
+		t.Fatalf("Expected %v, got %v", constants.ErrNotAnImage, err)
+	}
+}
@@ -0,0 +1,80 @@
+package business
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/hambosto/hexwarden/internal/constants"
+	"github.com/hambosto/hexwarden/internal/usecase/operations"
+	"github.com/hambosto/hexwarden/tests/helpers"
+)
+
+func TestTimelock_NotBefore(t *testing.T) {
+	tmpDir := helpers.CreateTempDir(t)
+	defer helpers.CleanupTempDir(t, tmpDir)
+
+	plaintext := []byte("scheduled disclosure")
+
+	tests := []struct {
+		name      string
+		notBefore time.Time
+		ignore    bool
+		wantErr   error
+	}{
+		{name: "Before the unlock time", notBefore: time.Now().Add(time.Hour), wantErr: constants.ErrTimeLocked},
+		{name: "After the unlock time", notBefore: time.Now().Add(-time.Hour)},
+		{name: "Override before the unlock time", notBefore: time.Now().Add(time.Hour), ignore: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			encrypted := encryptBytes(t, plaintext, operations.EncryptOptions{NotBefore: tt.notBefore})
+			inputPath := filepath.Join(tmpDir, "locked.hex")
+			outputPath := filepath.Join(tmpDir, "locked.txt")
+			helpers.WriteFileContent(t, inputPath, encrypted)
+			_ = os.Remove(outputPath)
+
+			decryptor := operations.NewDecryptorWithKDF(cheapKDF)
+			decryptor.SetIgnoreTimelock(tt.ignore)
+
+			var buf bytes.Buffer
+			_, streamErr := decryptor.DecryptStream(bytes.NewReader(encrypted), &buf, testPassword)
+			_, fileErr := decryptor.DecryptFile(inputPath, outputPath, testPassword)
+			if tt.wantErr != nil {
+				if !errors.Is(streamErr, tt.wantErr) || !errors.Is(fileErr, tt.wantErr) {
+					t.Fatalf("Expected %v, got %v and %v", tt.wantErr, streamErr, fileErr)
+				}
+				helpers.AssertEqual(t, 0, buf.Len())
+				if _, err := os.Stat(outputPath); !os.IsNotExist(err) {
+					t.Errorf("Expected no output file for a time-locked file, got %v", err)
+				}
+				return
+			}
+
+			helpers.AssertNoError(t, streamErr)
+			helpers.AssertNoError(t, fileErr)
+			helpers.AssertBytesEqual(t, plaintext, buf.Bytes())
+			helpers.AssertBytesEqual(t, plaintext, helpers.ReadFileContent(t, outputPath))
+		})
+	}
+}
+
+func TestTimelock_ReadableWithoutPassword(t *testing.T) {
+	tmpDir := helpers.CreateTempDir(t)
+	defer helpers.CleanupTempDir(t, tmpDir)
+
+	notBefore := time.Date(2031, 3, 1, 9, 0, 0, 0, time.UTC)
+	inputPath := filepath.Join(tmpDir, "locked.hex")
+	helpers.WriteFileContent(t, inputPath, encryptBytes(t, []byte("later"), operations.EncryptOptions{NotBefore: notBefore}))
+
+	info, err := operations.NewDecryptorWithKDF(cheapKDF).Inspect(inputPath, "")
+	helpers.AssertNoError(t, err)
+	if !info.NotBefore.Equal(notBefore) {
+		t.Errorf("Expected not-before %v, got %v", notBefore, info.NotBefore)
+	}
+	helpers.AssertEqual(t, constants.FormatVersion3, info.Version)
+}
@@ -0,0 +1,30 @@
+package business
+
+import (
+	"testing"
+
+	"github.com/hambosto/hexwarden/internal/infrastructure/crypto"
+	"github.com/hambosto/hexwarden/internal/usecase/operations"
+	"github.com/hambosto/hexwarden/tests/helpers"
+)
+
+func TestCheckVectors(t *testing.T) {
+	results, err := operations.NewDecryptor().CheckVectors()
+	for _, result := range results {
+		if result.Err != nil {
+			t.Errorf("%s (%s): %v", result.Vector.File, result.Vector.Description, result.Err)
+		}
+	}
+	helpers.AssertNoError(t, err)
+
+	// Every format version still readable keeps a vector
+	covered := map[uint8]bool{}
+	for _, result := range results {
+		covered[result.Vector.Version] = true
+	}
+	for _, version := range crypto.ReadableFormatVersions() {
+		if !covered[version] {
+			t.Errorf("Expected a known-answer vector for format version %d", version)
+		}
+	}
+}
@@ -0,0 +1,56 @@
+package business
+
+import (
+	"bytes"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"github.com/hambosto/hexwarden/internal/usecase/operations"
+	"github.com/hambosto/hexwarden/tests/helpers"
+)
+
+// debugLogger returns a logger writing debug lines to buf
+func debugLogger(buf *bytes.Buffer) *slog.Logger {
+	return slog.New(slog.NewTextHandler(buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+}
+
+func TestVerbose_LogsStages(t *testing.T) {
+	plaintext := bytes.Repeat([]byte("verbose stage logging "), 1000)
+
+	var encryptLog bytes.Buffer
+	encryptor := operations.NewEncryptorWithKDF(cheapKDF)
+	encryptor.SetLogger(debugLogger(&encryptLog))
+
+	var encrypted bytes.Buffer
+	_, err := encryptor.EncryptStream(bytes.NewReader(plaintext), &encrypted, int64(len(plaintext)), testPassword, operations.EncryptOptions{})
+	helpers.AssertNoError(t, err)
+
+	var decryptLog bytes.Buffer
+	decryptor := operations.NewDecryptorWithKDF(cheapKDF)
+	decryptor.SetLogger(debugLogger(&decryptLog))
+
+	var decrypted bytes.Buffer
+	_, err = decryptor.DecryptStream(bytes.NewReader(encrypted.Bytes()), &decrypted, testPassword)
+	helpers.AssertNoError(t, err)
+	helpers.AssertBytesEqual(t, plaintext, decrypted.Bytes())
+
+	tests := []struct {
+		name  string
+		log   string
+		lines []string
+	}{
+		{"Encrypt", encryptLog.String(), []string{"stage=kdf", `stage="header write"`, `msg="stream finished" stage=body chunks=1`}},
+		{"Decrypt", decryptLog.String(), []string{`stage="header read"`, "stage=kdf", `msg="stream finished" stage=body chunks=1`}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, line := range tt.lines {
+				if !strings.Contains(tt.log, line) {
+					t.Errorf("Expected log to contain %q, got:\n%s", line, tt.log)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,83 @@
+package business
+
+import (
+	"bytes"
+	"crypto/rand"
+	"testing"
+
+	"github.com/hambosto/hexwarden/internal/constants"
+	"github.com/hambosto/hexwarden/internal/usecase/operations"
+	"github.com/hambosto/hexwarden/tests/helpers"
+)
+
+// writeCounter counts the writes reaching it, standing in for write syscalls
+type writeCounter struct {
+	bytes.Buffer
+	writes int
+}
+
+func (w *writeCounter) Write(p []byte) (int, error) {
+	w.writes++
+	return w.Buffer.Write(p)
+}
+
+// encryptCounted encrypts plaintext with a write buffer of size bytes into a writeCounter
+func encryptCounted(tb testing.TB, plaintext []byte, size int) *writeCounter {
+	tb.Helper()
+
+	encryptor := operations.NewEncryptorWithKDF(cheapKDF)
+	encryptor.SetWriteBuffer(size)
+	out := &writeCounter{}
+	if _, err := encryptor.EncryptStream(bytes.NewReader(plaintext), out, int64(len(plaintext)), testPassword, operations.EncryptOptions{}); err != nil {
+		tb.Fatalf("Encryption failed: %v", err)
+	}
+	return out
+}
+
+func TestEncryptor_SetWriteBuffer(t *testing.T) {
+	plaintext := bytes.Repeat([]byte("coalesced writes\n"), 3*constants.DefaultChunkSize/17)
+
+	unbuffered := encryptCounted(t, plaintext, 0)
+	buffered := encryptCounted(t, plaintext, constants.WriteBufferSize)
+	if buffered.writes >= unbuffered.writes {
+		t.Errorf("Expected fewer writes with a buffer, got %d buffered and %d unbuffered", buffered.writes, unbuffered.writes)
+	}
+
+	var decrypted bytes.Buffer
+	_, err := operations.NewDecryptorWithKDF(cheapKDF).DecryptStream(&buffered.Buffer, &decrypted, testPassword)
+	helpers.AssertNoError(t, err)
+	helpers.AssertBytesEqual(t, plaintext, decrypted.Bytes())
+}
+
+func BenchmarkEncryptStream_WriteBuffer(b *testing.B) {
+	sizes := []struct {
+		name string
+		size int
+	}{
+		{name: "SingleChunk", size: 64 * 1024},
+		{name: "ManyChunks", size: 8 * constants.DefaultChunkSize},
+	}
+	buffers := []struct {
+		name string
+		size int
+	}{
+		{name: "Unbuffered", size: 0},
+		{name: "Buffered", size: constants.WriteBufferSize},
+	}
+
+	for _, s := range sizes {
+		plaintext := make([]byte, s.size)
+		_, _ = rand.Read(plaintext)
+
+		for _, buf := range buffers {
+			b.Run(s.name+"/"+buf.name, func(b *testing.B) {
+				b.SetBytes(int64(len(plaintext)))
+				var writes int
+				for i := 0; i < b.N; i++ {
+					writes = encryptCounted(b, plaintext, buf.size).writes
+				}
+				b.ReportMetric(float64(writes), "writes/op")
+			})
+		}
+	}
+}
@@ -1,6 +1,8 @@
 package compression
 
 import (
+	"bytes"
+	"errors"
 	"fmt"
 	"testing"
 
@@ -421,3 +423,169 @@ func corruptCompressedData(data []byte) []byte {
 	}
 	return corrupted
 }
+
+func TestCompressor_RawDeflateSmallInputs(t *testing.T) {
+	gzipCompressor, err := compression.NewCompressorWithFormat(constants.LevelDefaultCompression, constants.CompressionGzip)
+	helpers.AssertNoError(t, err)
+	rawCompressor, err := compression.NewCompressorWithFormat(constants.LevelDefaultCompression, constants.CompressionRawDeflate)
+	helpers.AssertNoError(t, err)
+
+	testData := helpers.NewTestData()
+	for _, size := range []int{1, 16, 100, 1000} {
+		t.Run(fmt.Sprintf("%d bytes", size), func(t *testing.T) {
+			data := testData.LargeData[:size]
+
+			zipped, err := gzipCompressor.Compress(data)
+			helpers.AssertNoError(t, err)
+			raw, err := rawCompressor.Compress(data)
+			helpers.AssertNoError(t, err)
+
+			// The same deflate stream without the 10-byte gzip header and 8-byte trailer
+			helpers.AssertEqual(t, len(zipped)-18, len(raw))
+
+			decompressed, err := rawCompressor.Decompress(raw)
+			helpers.AssertNoError(t, err)
+			helpers.AssertBytesEqual(t, data, decompressed)
+
+			if _, err := gzipCompressor.Decompress(raw); err == nil {
+				t.Error("Expected gzip to reject a raw deflate stream")
+			}
+		})
+	}
+
+	if _, err := compression.NewCompressorWithFormat(constants.LevelDefaultCompression, constants.CompressionFormat(9)); err == nil {
+		t.Error("Expected an unknown compression format to be rejected")
+	}
+}
+
+func TestCompressor_Stored(t *testing.T) {
+	compressor, err := compression.NewCompressorWithFormat(constants.LevelDefaultCompression, constants.CompressionStored)
+	helpers.AssertNoError(t, err)
+
+	data := bytes.Repeat([]byte("compressible "), 1000)
+	stored, err := compressor.Compress(data)
+	helpers.AssertNoError(t, err)
+
+	// One marker byte and the data itself, with no gzip or deflate framing
+	helpers.AssertEqual(t, len(data)+1, len(stored))
+	helpers.AssertEqual(t, byte(0), stored[0])
+	helpers.AssertBytesEqual(t, data, stored[1:])
+
+	restored, err := compressor.Decompress(stored)
+	helpers.AssertNoError(t, err)
+	helpers.AssertBytesEqual(t, data, restored)
+
+	gzipCompressor, err := compression.NewCompressorWithFormat(constants.LevelDefaultCompression, constants.CompressionGzip)
+	helpers.AssertNoError(t, err)
+	zipped, err := gzipCompressor.Compress(data)
+	helpers.AssertNoError(t, err)
+	if _, err := compressor.Decompress(zipped); err == nil {
+		t.Error("Expected a gzip stream to be rejected as a stored chunk")
+	}
+}
+
+func TestParseFormat(t *testing.T) {
+	tests := []struct {
+		name     string
+		expected constants.CompressionFormat
+	}{
+		{name: "gzip", expected: constants.CompressionGzip},
+		{name: "GZIP", expected: constants.CompressionGzip},
+		{name: "deflate", expected: constants.CompressionRawDeflate},
+		{name: "raw-deflate", expected: constants.CompressionRawDeflate},
+		{name: "none", expected: constants.CompressionStored},
+		{name: "stored", expected: constants.CompressionStored},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			format, err := compression.ParseFormat(tt.name)
+			helpers.AssertNoError(t, err)
+			helpers.AssertEqual(t, tt.expected, format)
+		})
+	}
+
+	if _, err := compression.ParseFormat("zstd"); err == nil {
+		t.Error("Expected an unknown format name to be rejected")
+	}
+}
+
+func TestCompressor_DecompressSized(t *testing.T) {
+	data := bytes.Repeat([]byte("sized chunk "), 20000)
+
+	for _, format := range []constants.CompressionFormat{constants.CompressionGzip, constants.CompressionRawDeflate, constants.CompressionStored} {
+		compressor, err := compression.NewCompressorWithFormat(constants.LevelDefaultCompression, format)
+		helpers.AssertNoError(t, err)
+		compressed, err := compressor.Compress(data)
+		helpers.AssertNoError(t, err)
+
+		// A size above the data's length only costs allocations
+		for _, size := range []int{0, len(data), 4 * len(data), -1} {
+			t.Run(fmt.Sprintf("%s size %d", format, size), func(t *testing.T) {
+				decompressed, err := compressor.DecompressSized(compressed, size)
+				helpers.AssertNoError(t, err)
+				helpers.AssertBytesEqual(t, data, decompressed)
+			})
+		}
+
+		// One below is a decompression bomb
+		for _, size := range []int{10, len(data) - 1} {
+			t.Run(fmt.Sprintf("%s size %d", format, size), func(t *testing.T) {
+				_, err := compressor.DecompressSized(compressed, size)
+				if !errors.Is(err, constants.ErrDecompressionBomb) {
+					t.Fatalf("Expected %v, got %v", constants.ErrDecompressionBomb, err)
+				}
+			})
+		}
+	}
+}
+
+func TestCompressor_DecompressCapsAtChunk(t *testing.T) {
+	data := make([]byte, constants.DefaultChunkSize+1) // Zeros compress to a few KB
+
+	for _, format := range []constants.CompressionFormat{constants.CompressionGzip, constants.CompressionRawDeflate, constants.CompressionStored} {
+		t.Run(format.String(), func(t *testing.T) {
+			compressor, err := compression.NewCompressorWithFormat(constants.LevelDefaultCompression, format)
+			helpers.AssertNoError(t, err)
+			compressed, err := compressor.Compress(data)
+			helpers.AssertNoError(t, err)
+
+			// No chunk holds more than DefaultChunkSize, whatever size is asked for
+			for _, size := range []int{0, 2 * len(data)} {
+				_, err := compressor.DecompressSized(compressed, size)
+				if !errors.Is(err, constants.ErrDecompressionBomb) {
+					t.Fatalf("Expected %v, got %v", constants.ErrDecompressionBomb, err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkCompressor_DecompressSized(b *testing.B) {
+	data := bytes.Repeat([]byte("benchmark chunk "), constants.DefaultChunkSize/16)
+	compressor, err := compression.NewCompressorWithFormat(constants.LevelDefaultCompression, constants.CompressionRawDeflate)
+	if err != nil {
+		b.Fatal(err)
+	}
+	compressed, err := compressor.Compress(data)
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	b.Run("growing", func(b *testing.B) {
+		b.ReportAllocs()
+		for b.Loop() {
+			if _, err := compressor.Decompress(compressed); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("sized", func(b *testing.B) {
+		b.ReportAllocs()
+		for b.Loop() {
+			if _, err := compressor.DecompressSized(compressed, len(data)); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
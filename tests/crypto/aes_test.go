@@ -1,6 +1,7 @@
 package crypto
 
 import (
+	"errors"
 	"testing"
 
 	"github.com/hambosto/hexwarden/internal/constants"
@@ -175,19 +176,31 @@ func TestAESCipher_Decrypt(t *testing.T) {
 			name:        "Too short ciphertext",
 			ciphertext:  []byte{0x01, 0x02},
 			expectError: true,
-			expectedErr: constants.ErrDecryptionFailed,
+			expectedErr: constants.ErrCiphertextTooShort,
+		},
+		{
+			name:        "Nonce and partial tag",
+			ciphertext:  validCiphertext[:27],
+			expectError: true,
+			expectedErr: constants.ErrCiphertextTooShort,
+		},
+		{
+			name:        "Nonce and tag without data",
+			ciphertext:  validCiphertext[:28],
+			expectError: true,
+			expectedErr: constants.ErrTagMismatch,
 		},
 		{
 			name:        "Invalid ciphertext",
 			ciphertext:  []byte("this is not valid ciphertext data"),
 			expectError: true,
-			expectedErr: constants.ErrDecryptionFailed,
+			expectedErr: constants.ErrTagMismatch,
 		},
 		{
 			name:        "Corrupted ciphertext",
 			ciphertext:  corruptData(validCiphertext),
 			expectError: true,
-			expectedErr: constants.ErrDecryptionFailed,
+			expectedErr: constants.ErrTagMismatch,
 		},
 	}
 
@@ -196,7 +209,9 @@ func TestAESCipher_Decrypt(t *testing.T) {
 			plaintext, err := cipher.Decrypt(tt.ciphertext)
 
 			if tt.expectError {
-				helpers.AssertError(t, err, tt.expectedErr)
+				if !errors.Is(err, tt.expectedErr) {
+					t.Errorf("Expected error %v, got %v", tt.expectedErr, err)
+				}
 				if plaintext != nil {
 					t.Error("Expected plaintext to be nil when error occurs")
 				}
@@ -329,12 +344,42 @@ func TestAESCipher_DifferentKeysProduceDifferentResults(t *testing.T) {
 	helpers.AssertNoError(t, err)
 	helpers.AssertBytesEqual(t, testData.TestData, decrypted2)
 
-	// Cross-decryption should fail
-	_, err = cipher1.Decrypt(ciphertext2)
-	helpers.AssertError(t, err, constants.ErrDecryptionFailed)
+	// Cross-decryption should fail authentication
+	for _, err := range []error{
+		func() error { _, err := cipher1.Decrypt(ciphertext2); return err }(),
+		func() error { _, err := cipher2.Decrypt(ciphertext1); return err }(),
+	} {
+		if !errors.Is(err, constants.ErrTagMismatch) {
+			t.Errorf("Expected ErrTagMismatch, got %v", err)
+		}
+	}
+}
+
+func TestAESCipher_DecryptDistinguishesTruncationFromAuthFailure(t *testing.T) {
+	testData := helpers.NewTestData()
+
+	cipher, err := crypto.NewAESCipher(testData.ValidKey32)
+	helpers.AssertNoError(t, err)
+	ciphertext, err := cipher.Encrypt(testData.TestData)
+	helpers.AssertNoError(t, err)
 
-	_, err = cipher2.Decrypt(ciphertext1)
-	helpers.AssertError(t, err, constants.ErrDecryptionFailed)
+	_, truncatedErr := cipher.Decrypt(ciphertext[:12])
+	wrongKey, err := crypto.NewAESCipher(testData.ValidKey16)
+	helpers.AssertNoError(t, err)
+	_, authErr := wrongKey.Decrypt(ciphertext)
+
+	// Both remain decryption failures for callers that do not care which
+	for _, err := range []error{truncatedErr, authErr} {
+		if !errors.Is(err, constants.ErrDecryptionFailed) {
+			t.Errorf("Expected ErrDecryptionFailed, got %v", err)
+		}
+	}
+	if !errors.Is(truncatedErr, constants.ErrCiphertextTooShort) || errors.Is(truncatedErr, constants.ErrTagMismatch) {
+		t.Errorf("Expected only ErrCiphertextTooShort for truncated input, got %v", truncatedErr)
+	}
+	if !errors.Is(authErr, constants.ErrTagMismatch) || errors.Is(authErr, constants.ErrCiphertextTooShort) {
+		t.Errorf("Expected only ErrTagMismatch for a wrong key, got %v", authErr)
+	}
 }
 
 // BenchmarkAESCipher_Encrypt benchmarks encryption performance
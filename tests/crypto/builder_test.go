@@ -0,0 +1,550 @@
+package crypto
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hambosto/hexwarden/internal/constants"
+	"github.com/hambosto/hexwarden/internal/infrastructure/crypto"
+	"github.com/hambosto/hexwarden/internal/infrastructure/utils"
+	"github.com/hambosto/hexwarden/tests/helpers"
+)
+
+func TestBuild_OptionCombinations(t *testing.T) {
+	testData := helpers.NewTestData()
+	params := crypto.KDFParams{Time: 2, Memory: 32 * 1024, Threads: 2}
+
+	tests := []struct {
+		name            string
+		opts            []crypto.HeaderOption
+		expectedVersion uint8
+		expectedParams  crypto.KDFParams
+		expectedName    string
+		expectedHint    string
+	}{
+		{
+			name:            "No options keeps fixed header",
+			opts:            nil,
+			expectedVersion: constants.FormatVersion2,
+			expectedParams:  crypto.DefaultKDFParams(),
+		},
+		{
+			name:            "KDF params only",
+			opts:            []crypto.HeaderOption{crypto.WithKDFParams(params)},
+			expectedVersion: constants.FormatVersion3,
+			expectedParams:  params,
+		},
+		{
+			name:            "Argon2i variant",
+			opts:            []crypto.HeaderOption{crypto.WithKDFParams(crypto.KDFParams{Time: 2, Memory: 32 * 1024, Threads: 2, Variant: constants.Argon2i})},
+			expectedVersion: constants.FormatVersion3,
+			expectedParams:  crypto.KDFParams{Time: 2, Memory: 32 * 1024, Threads: 2, Variant: constants.Argon2i},
+		},
+		{
+			name:            "Filename only",
+			opts:            []crypto.HeaderOption{crypto.WithFilename("report.pdf")},
+			expectedVersion: constants.FormatVersion3,
+			expectedParams:  crypto.DefaultKDFParams(),
+			expectedName:    "report.pdf",
+		},
+		{
+			name:            "Hint only",
+			opts:            []crypto.HeaderOption{crypto.WithHint("favourite band")},
+			expectedVersion: constants.FormatVersion3,
+			expectedParams:  crypto.DefaultKDFParams(),
+			expectedHint:    "favourite band",
+		},
+		{
+			name: "All options",
+			opts: []crypto.HeaderOption{
+				crypto.WithKDFParams(params),
+				crypto.WithFilename("photos/holiday.jpg"),
+				crypto.WithHint("first pet"),
+			},
+			expectedVersion: constants.FormatVersion3,
+			expectedParams:  params,
+			expectedName:    "photos/holiday.jpg",
+			expectedHint:    "first pet",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			header, err := crypto.Build(testData.ValidSalt, 4096, testData.ValidKey32, tt.opts...)
+			helpers.AssertNoError(t, err)
+
+			var buf bytes.Buffer
+			n, err := header.WriteTo(&buf)
+			helpers.AssertNoError(t, err)
+			helpers.AssertEqual(t, int64(header.Size()), n)
+
+			readHeader, err := crypto.ReadHeader(&buf)
+			helpers.AssertNoError(t, err)
+			helpers.AssertNoError(t, readHeader.VerifyKey(testData.ValidKey32))
+
+			helpers.AssertEqual(t, tt.expectedVersion, readHeader.Version())
+			helpers.AssertEqual(t, uint64(4096), readHeader.OriginalSize())
+			helpers.AssertEqual(t, tt.expectedParams, readHeader.KDFParams())
+			helpers.AssertEqual(t, tt.expectedHint, readHeader.Hint())
+
+			name, err := readHeader.Filename(testData.ValidKey32)
+			helpers.AssertNoError(t, err)
+			helpers.AssertEqual(t, tt.expectedName, name)
+		})
+	}
+}
+
+func TestBuild_NoOptionsMatchesNewHeaderLayout(t *testing.T) {
+	testData := helpers.NewTestData()
+
+	header, err := crypto.Build(testData.ValidSalt, 1024, testData.ValidKey32)
+	helpers.AssertNoError(t, err)
+
+	var buf bytes.Buffer
+	helpers.AssertNoError(t, header.Write(&buf))
+	helpers.AssertEqual(t, constants.TotalHeaderSize, buf.Len())
+	helpers.AssertEqual(t, constants.MagicBytes, string(buf.Bytes()[:len(constants.MagicBytes)]))
+}
+
+func TestBuild_InvalidOptions(t *testing.T) {
+	testData := helpers.NewTestData()
+
+	tests := []struct {
+		name        string
+		opts        []crypto.HeaderOption
+		expectedErr error
+	}{
+		{
+			name:        "Zero time cost",
+			opts:        []crypto.HeaderOption{crypto.WithKDFParams(crypto.KDFParams{Time: 0, Memory: 64 * 1024, Threads: 4})},
+			expectedErr: constants.ErrInvalidKDFParams,
+		},
+		{
+			name:        "Time cost above the limit",
+			opts:        []crypto.HeaderOption{crypto.WithKDFParams(crypto.KDFParams{Time: constants.MaxArgonTime + 1, Memory: 64 * 1024, Threads: 4})},
+			expectedErr: constants.ErrInvalidKDFParams,
+		},
+		{
+			name:        "Memory above the limit",
+			opts:        []crypto.HeaderOption{crypto.WithKDFParams(crypto.KDFParams{Time: 1, Memory: constants.MaxArgonMemory + 1, Threads: 4})},
+			expectedErr: constants.ErrInvalidKDFParams,
+		},
+		{
+			name:        "Memory too small for threads",
+			opts:        []crypto.HeaderOption{crypto.WithKDFParams(crypto.KDFParams{Time: 1, Memory: 16, Threads: 4})},
+			expectedErr: constants.ErrInvalidKDFParams,
+		},
+		{
+			name:        "Empty filename",
+			opts:        []crypto.HeaderOption{crypto.WithFilename("")},
+			expectedErr: constants.ErrInvalidOption,
+		},
+		{
+			name:        "Filename too long",
+			opts:        []crypto.HeaderOption{crypto.WithFilename(strings.Repeat("a", constants.MaxFilenameLength+1))},
+			expectedErr: constants.ErrInvalidOption,
+		},
+		{
+			name:        "Hint too long",
+			opts:        []crypto.HeaderOption{crypto.WithHint(strings.Repeat("h", constants.MaxHintLength+1))},
+			expectedErr: constants.ErrInvalidOption,
+		},
+		{
+			name:        "Empty comment",
+			opts:        []crypto.HeaderOption{crypto.WithComment("")},
+			expectedErr: constants.ErrInvalidOption,
+		},
+		{
+			name:        "Comment too long",
+			opts:        []crypto.HeaderOption{crypto.WithComment(strings.Repeat("c", constants.MaxCommentLength+1))},
+			expectedErr: constants.ErrInvalidOption,
+		},
+		{
+			name:        "Empty thumbnail",
+			opts:        []crypto.HeaderOption{crypto.WithThumbnail(nil)},
+			expectedErr: constants.ErrInvalidOption,
+		},
+		{
+			name:        "Thumbnail too large",
+			opts:        []crypto.HeaderOption{crypto.WithThumbnail(make([]byte, constants.MaxThumbnailSize+1))},
+			expectedErr: constants.ErrInvalidOption,
+		},
+		{
+			name:        "Duplicate option",
+			opts:        []crypto.HeaderOption{crypto.WithHint("one"), crypto.WithHint("two")},
+			expectedErr: constants.ErrInvalidOption,
+		},
+		{
+			name:        "Hint leaks filename",
+			opts:        []crypto.HeaderOption{crypto.WithFilename("salary.xlsx"), crypto.WithHint("the salary.xlsx password")},
+			expectedErr: constants.ErrInvalidOption,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			header, err := crypto.Build(testData.ValidSalt, 1024, testData.ValidKey32, tt.opts...)
+			if !errors.Is(err, tt.expectedErr) {
+				t.Fatalf("Expected %v, got %v", tt.expectedErr, err)
+			}
+			if header != nil {
+				t.Error("Expected header to be nil when error occurs")
+			}
+		})
+	}
+}
+
+func TestBuild_FilenameRequiresKey(t *testing.T) {
+	testData := helpers.NewTestData()
+
+	header, err := crypto.Build(testData.ValidSalt, 1024, testData.ValidKey32, crypto.WithFilename("secret-plans.txt"))
+	helpers.AssertNoError(t, err)
+
+	var buf bytes.Buffer
+	helpers.AssertNoError(t, header.Write(&buf))
+	if bytes.Contains(buf.Bytes(), []byte("secret-plans.txt")) {
+		t.Fatal("Filename must not appear in plaintext in the serialized header")
+	}
+
+	readHeader, err := crypto.ReadHeader(&buf)
+	helpers.AssertNoError(t, err)
+	helpers.AssertEqual(t, true, readHeader.HasFilename())
+
+	_, err = readHeader.Filename(testData.ValidKey24)
+	if err == nil {
+		t.Fatal("Expected filename decryption to fail with the wrong key")
+	}
+}
+
+func TestBuild_MetadataTamperDetection(t *testing.T) {
+	testData := helpers.NewTestData()
+
+	header, err := crypto.Build(testData.ValidSalt, 1024, testData.ValidKey32, crypto.WithHint("my hint"))
+	helpers.AssertNoError(t, err)
+
+	var buf bytes.Buffer
+	helpers.AssertNoError(t, header.Write(&buf))
+	data := buf.Bytes()
+
+	// Flip a byte inside the hint value
+	idx := bytes.Index(data, []byte("my hint"))
+	if idx < 0 {
+		t.Fatal("Expected hint to be stored in plaintext")
+	}
+	data[idx] ^= 0xFF
+
+	_, err = crypto.ReadHeader(bytes.NewReader(data))
+	helpers.AssertError(t, err, constants.ErrChecksumMismatch)
+}
+
+func TestBuild_CommentRequiresKey(t *testing.T) {
+	testData := helpers.NewTestData()
+
+	header, err := crypto.Build(testData.ValidSalt, 1024, testData.ValidKey32, crypto.WithComment("backup copy"))
+	helpers.AssertNoError(t, err)
+
+	var buf bytes.Buffer
+	helpers.AssertNoError(t, header.Write(&buf))
+	if bytes.Contains(buf.Bytes(), []byte("backup copy")) {
+		t.Fatal("Comment must not appear in plaintext in the serialized header")
+	}
+
+	readHeader, err := crypto.ReadHeader(&buf)
+	helpers.AssertNoError(t, err)
+	helpers.AssertEqual(t, true, readHeader.HasComment())
+
+	comment, err := readHeader.Comment(testData.ValidKey32)
+	helpers.AssertNoError(t, err)
+	helpers.AssertEqual(t, "backup copy", comment)
+
+	_, err = readHeader.Comment(testData.ValidKey24)
+	if err == nil {
+		t.Fatal("Expected comment decryption to fail with the wrong key")
+	}
+}
+
+func TestBuild_HashAlgorithmRoundTrip(t *testing.T) {
+	testData := helpers.NewTestData()
+
+	tests := []struct {
+		name            string
+		opts            []crypto.HeaderOption
+		expectedAlg     constants.HashAlgorithm
+		expectedVersion uint8
+	}{
+		{
+			name:            "Default is SHA-256 with fixed header",
+			opts:            nil,
+			expectedAlg:     constants.HashSHA256,
+			expectedVersion: constants.FormatVersion2,
+		},
+		{
+			name:            "Explicit SHA-256 keeps fixed header",
+			opts:            []crypto.HeaderOption{crypto.WithHashAlgorithm(constants.HashSHA256)},
+			expectedAlg:     constants.HashSHA256,
+			expectedVersion: constants.FormatVersion2,
+		},
+		{
+			name:            "BLAKE2b requires metadata block",
+			opts:            []crypto.HeaderOption{crypto.WithHashAlgorithm(constants.HashBLAKE2b)},
+			expectedAlg:     constants.HashBLAKE2b,
+			expectedVersion: constants.FormatVersion3,
+		},
+		{
+			name:            "BLAKE2b with other metadata",
+			opts:            []crypto.HeaderOption{crypto.WithHashAlgorithm(constants.HashBLAKE2b), crypto.WithComment("note"), crypto.WithHint("hint")},
+			expectedAlg:     constants.HashBLAKE2b,
+			expectedVersion: constants.FormatVersion3,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			header, err := crypto.Build(testData.ValidSalt, 2048, testData.ValidKey32, tt.opts...)
+			helpers.AssertNoError(t, err)
+
+			var buf bytes.Buffer
+			helpers.AssertNoError(t, header.Write(&buf))
+
+			readHeader, err := crypto.ReadHeader(&buf)
+			helpers.AssertNoError(t, err)
+			helpers.AssertNoError(t, readHeader.VerifyKey(testData.ValidKey32))
+			helpers.AssertEqual(t, tt.expectedAlg, readHeader.HashAlgorithm())
+			helpers.AssertEqual(t, tt.expectedVersion, readHeader.Version())
+			helpers.AssertEqual(t, uint64(2048), readHeader.OriginalSize())
+
+			if err := readHeader.VerifyKey(testData.ValidKey24); !errors.Is(err, constants.ErrAuthFailure) {
+				t.Fatalf("Expected %v with the wrong key, got %v", constants.ErrAuthFailure, err)
+			}
+		})
+	}
+}
+
+func TestBuild_HashAlgorithmAddsMetadataEntry(t *testing.T) {
+	testData := helpers.NewTestData()
+
+	sha, err := crypto.Build(testData.ValidSalt, 1, testData.ValidKey32, crypto.WithComment("same"))
+	helpers.AssertNoError(t, err)
+	blake, err := crypto.Build(testData.ValidSalt, 1, testData.ValidKey32, crypto.WithComment("same"), crypto.WithHashAlgorithm(constants.HashBLAKE2b))
+	helpers.AssertNoError(t, err)
+
+	var shaBuf, blakeBuf bytes.Buffer
+	helpers.AssertNoError(t, sha.Write(&shaBuf))
+	helpers.AssertNoError(t, blake.Write(&blakeBuf))
+
+	// The BLAKE2b header carries one more metadata entry of 4 bytes
+	helpers.AssertEqual(t, shaBuf.Len()+4, blakeBuf.Len())
+}
+
+func TestBuild_InvalidHashAlgorithm(t *testing.T) {
+	testData := helpers.NewTestData()
+
+	_, err := crypto.Build(testData.ValidSalt, 1, testData.ValidKey32, crypto.WithHashAlgorithm(constants.HashAlgorithm(99)))
+	if !errors.Is(err, constants.ErrInvalidOption) {
+		t.Fatalf("Expected %v, got %v", constants.ErrInvalidOption, err)
+	}
+
+	_, err = crypto.ParseHashAlgorithm("md5")
+	if !errors.Is(err, constants.ErrInvalidOption) {
+		t.Fatalf("Expected %v, got %v", constants.ErrInvalidOption, err)
+	}
+
+	alg, err := crypto.ParseHashAlgorithm("BLAKE2b-256")
+	helpers.AssertNoError(t, err)
+	helpers.AssertEqual(t, constants.HashBLAKE2b, alg)
+}
+
+func TestBuild_Flags(t *testing.T) {
+	testData := helpers.NewTestData()
+
+	header, err := crypto.Build(testData.ValidSalt, 10, testData.ValidKey32, crypto.WithFlags(constants.FlagRawBody))
+	helpers.AssertNoError(t, err)
+
+	var buf bytes.Buffer
+	helpers.AssertNoError(t, header.Write(&buf))
+
+	readHeader, err := crypto.ReadHeader(&buf)
+	helpers.AssertNoError(t, err)
+	helpers.AssertNoError(t, readHeader.VerifyKey(testData.ValidKey32))
+	helpers.AssertEqual(t, constants.FormatVersion3, readHeader.Version())
+	helpers.AssertEqual(t, constants.FlagRawBody, readHeader.Flags())
+
+	_, err = crypto.Build(testData.ValidSalt, 10, testData.ValidKey32, crypto.WithFlags(constants.HeaderFlags(0x80)))
+	if !errors.Is(err, constants.ErrInvalidOption) {
+		t.Fatalf("Expected %v, got %v", constants.ErrInvalidOption, err)
+	}
+}
+
+func TestHeader_SetFilename(t *testing.T) {
+	testData := helpers.NewTestData()
+
+	tests := []struct {
+		name            string
+		opts            []crypto.HeaderOption
+		newName         string
+		expectedVersion uint8
+	}{
+		{
+			name:            "Replace stored name",
+			opts:            []crypto.HeaderOption{crypto.WithFilename("a.txt")},
+			newName:         "b.txt",
+			expectedVersion: constants.FormatVersion3,
+		},
+		{
+			name:            "Add name to fixed header",
+			newName:         "b.txt",
+			expectedVersion: constants.FormatVersion3,
+		},
+		{
+			name:            "Remove only field returns to fixed header",
+			opts:            []crypto.HeaderOption{crypto.WithFilename("a.txt")},
+			expectedVersion: constants.FormatVersion2,
+		},
+		{
+			name:            "Remove name keeps other fields",
+			opts:            []crypto.HeaderOption{crypto.WithFilename("a.txt"), crypto.WithHint("the usual")},
+			expectedVersion: constants.FormatVersion3,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			header, err := crypto.Build(testData.ValidSalt, 1024, testData.ValidKey32, tt.opts...)
+			helpers.AssertNoError(t, err)
+			nonce := header.Nonce()
+
+			helpers.AssertNoError(t, header.SetFilename(testData.ValidKey32, tt.newName))
+
+			var buf bytes.Buffer
+			helpers.AssertNoError(t, header.Write(&buf))
+			readHeader, err := crypto.ReadHeader(&buf)
+			helpers.AssertNoError(t, err)
+			helpers.AssertNoError(t, readHeader.VerifyKey(testData.ValidKey32))
+
+			helpers.AssertEqual(t, tt.expectedVersion, readHeader.Version())
+			helpers.AssertEqual(t, tt.newName != "", readHeader.HasFilename())
+			helpers.AssertBytesEqual(t, nonce, readHeader.Nonce())
+			helpers.AssertBytesEqual(t, testData.ValidSalt, readHeader.Salt())
+
+			name, err := readHeader.Filename(testData.ValidKey32)
+			helpers.AssertNoError(t, err)
+			helpers.AssertEqual(t, tt.newName, name)
+		})
+	}
+}
+
+func TestHeader_SetFilenameRejected(t *testing.T) {
+	testData := helpers.NewTestData()
+
+	header, err := crypto.Build(testData.ValidSalt, 1024, testData.ValidKey32, crypto.WithFilename("a.txt"), crypto.WithHint("kept in b.txt"))
+	helpers.AssertNoError(t, err)
+
+	if err := header.SetFilename(testData.ValidKey24, "c.txt"); !errors.Is(err, constants.ErrAuthFailure) {
+		t.Errorf("Expected ErrAuthFailure for a wrong key, got %v", err)
+	}
+	if err := header.SetFilename(testData.ValidKey32, "b.txt"); !errors.Is(err, constants.ErrInvalidOption) {
+		t.Errorf("Expected ErrInvalidOption for a name revealed by the hint, got %v", err)
+	}
+	if err := header.SetFilename(testData.ValidKey32, strings.Repeat("x", constants.MaxFilenameLength+1)); !errors.Is(err, constants.ErrInvalidOption) {
+		t.Errorf("Expected ErrInvalidOption for an overlong name, got %v", err)
+	}
+
+	// A rejected change leaves the header as it was
+	name, err := header.Filename(testData.ValidKey32)
+	helpers.AssertNoError(t, err)
+	helpers.AssertEqual(t, "a.txt", name)
+	helpers.AssertNoError(t, header.VerifyKey(testData.ValidKey32))
+}
+
+func TestHeader_SetMerkleRootKeepsSize(t *testing.T) {
+	testData := helpers.NewTestData()
+
+	placeholder := make([]byte, constants.MerkleHashSize)
+	header, err := crypto.Build(testData.ValidSalt, 1024, testData.ValidKey32, crypto.WithMerkleRoot(placeholder))
+	helpers.AssertNoError(t, err)
+	size := header.Size()
+
+	root := bytes.Repeat([]byte{0xAB}, constants.MerkleHashSize)
+	helpers.AssertNoError(t, header.SetMerkleRoot(testData.ValidKey32, root))
+	helpers.AssertEqual(t, size, header.Size())
+
+	var buf bytes.Buffer
+	helpers.AssertNoError(t, header.Write(&buf))
+	readHeader, err := crypto.ReadHeader(&buf)
+	helpers.AssertNoError(t, err)
+	helpers.AssertNoError(t, readHeader.VerifyKey(testData.ValidKey32))
+	helpers.AssertBytesEqual(t, root, readHeader.MerkleRoot())
+
+	if err := header.SetMerkleRoot(testData.ValidKey24, root); !errors.Is(err, constants.ErrAuthFailure) {
+		t.Errorf("Expected ErrAuthFailure for a wrong key, got %v", err)
+	}
+	if err := header.SetMerkleRoot(testData.ValidKey32, root[:16]); !errors.Is(err, constants.ErrInvalidOption) {
+		t.Errorf("Expected ErrInvalidOption for a short root, got %v", err)
+	}
+}
+
+func TestHeader_SetOriginalSHA256KeepsSize(t *testing.T) {
+	testData := helpers.NewTestData()
+
+	placeholder := make([]byte, sha256.Size)
+	header, err := crypto.Build(testData.ValidSalt, 1024, testData.ValidKey32, crypto.WithOriginalSHA256(placeholder))
+	helpers.AssertNoError(t, err)
+	size := header.Size()
+
+	digest := sha256.Sum256([]byte("original file"))
+	helpers.AssertNoError(t, header.SetOriginalSHA256(testData.ValidKey32, digest[:]))
+	helpers.AssertEqual(t, size, header.Size())
+
+	var buf bytes.Buffer
+	helpers.AssertNoError(t, header.Write(&buf))
+	readHeader, err := crypto.ReadHeader(&buf)
+	helpers.AssertNoError(t, err)
+	helpers.AssertNoError(t, readHeader.VerifyKey(testData.ValidKey32))
+	helpers.AssertEqual(t, true, readHeader.HasOriginalSHA256())
+
+	stored, err := readHeader.OriginalSHA256(testData.ValidKey32)
+	helpers.AssertNoError(t, err)
+	helpers.AssertBytesEqual(t, digest[:], stored)
+
+	// The digest is sealed, not stored in the clear
+	if bytes.Contains(buf.Bytes(), digest[:]) {
+		t.Error("Original SHA-256 is readable without the key")
+	}
+	if err := header.SetOriginalSHA256(testData.ValidKey32, digest[:16]); !errors.Is(err, constants.ErrInvalidOption) {
+		t.Errorf("Expected ErrInvalidOption for a short digest, got %v", err)
+	}
+}
+
+func TestBuild_NotBeforeStoredAsUTCEpoch(t *testing.T) {
+	testData := helpers.NewTestData()
+	writer := time.FixedZone("UTC+9", 9*60*60)
+	reader := time.FixedZone("UTC-5", -5*60*60)
+	notBefore := time.Date(2031, 3, 1, 18, 30, 0, 0, writer)
+
+	original := time.Local
+	time.Local = writer
+	defer func() { time.Local = original }()
+
+	header, err := crypto.Build(testData.ValidSalt, 2048, testData.ValidKey32, crypto.WithNotBefore(notBefore))
+	helpers.AssertNoError(t, err)
+	var buf bytes.Buffer
+	helpers.AssertNoError(t, header.Write(&buf))
+
+	// The stored value is the epoch in big-endian, whatever zone wrote it
+	epoch := binary.BigEndian.AppendUint64(nil, uint64(notBefore.Unix()))
+	if !bytes.Contains(buf.Bytes(), epoch) {
+		t.Fatalf("Expected the header to store %x", epoch)
+	}
+
+	time.Local = reader
+	readHeader, err := crypto.ReadHeader(bytes.NewReader(buf.Bytes()))
+	helpers.AssertNoError(t, err)
+	helpers.AssertEqual(t, notBefore.Unix(), readHeader.NotBefore().Unix())
+	helpers.AssertEqual(t, time.UTC, readHeader.NotBefore().Location())
+	helpers.AssertEqual(t, "2031-03-01T04:30:00-05:00", utils.FormatTimestamp(readHeader.NotBefore()))
+}
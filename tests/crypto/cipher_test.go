@@ -0,0 +1,203 @@
+package crypto
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/hambosto/hexwarden/internal/constants"
+	"github.com/hambosto/hexwarden/internal/infrastructure/crypto"
+	"github.com/hambosto/hexwarden/tests/helpers"
+)
+
+func TestSelectCipher(t *testing.T) {
+	withAES := func() bool { return true }
+	withoutAES := func() bool { return false }
+
+	tests := []struct {
+		name     string
+		alg      constants.CipherAlgorithm
+		hasAES   func() bool
+		expected constants.CipherAlgorithm
+	}{
+		{name: "Auto with AES hardware", alg: constants.CipherAuto, hasAES: withAES, expected: constants.CipherAESGCM},
+		{name: "Auto without AES hardware", alg: constants.CipherAuto, hasAES: withoutAES, expected: constants.CipherChaCha20Poly1305},
+		{name: "Explicit AES-GCM without AES hardware", alg: constants.CipherAESGCM, hasAES: withoutAES, expected: constants.CipherAESGCM},
+		{name: "Explicit ChaCha20 with AES hardware", alg: constants.CipherChaCha20Poly1305, hasAES: withAES, expected: constants.CipherChaCha20Poly1305},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			helpers.AssertEqual(t, tt.expected, crypto.SelectCipher(tt.alg, tt.hasAES))
+		})
+	}
+}
+
+func TestParseCipher(t *testing.T) {
+	tests := []struct {
+		name     string
+		expected constants.CipherAlgorithm
+	}{
+		{name: "auto", expected: constants.CipherAuto},
+		{name: "aes-gcm", expected: constants.CipherAESGCM},
+		{name: "AES-256-GCM", expected: constants.CipherAESGCM},
+		{name: "chacha20", expected: constants.CipherChaCha20Poly1305},
+		{name: "ChaCha20-Poly1305", expected: constants.CipherChaCha20Poly1305},
+		{name: "xchacha20", expected: constants.CipherXChaCha20Poly1305},
+		{name: "XChaCha20-Poly1305", expected: constants.CipherXChaCha20Poly1305},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			alg, err := crypto.ParseCipher(tt.name)
+			helpers.AssertNoError(t, err)
+			helpers.AssertEqual(t, tt.expected, alg)
+		})
+	}
+
+	if _, err := crypto.ParseCipher("des"); !errors.Is(err, constants.ErrInvalidOption) {
+		t.Fatalf("Expected %v, got %v", constants.ErrInvalidOption, err)
+	}
+}
+
+func TestNewCipher_RoundTrip(t *testing.T) {
+	testData := helpers.NewTestData()
+	plaintext := []byte("the same chunk under either cipher")
+
+	tests := []struct {
+		alg       constants.CipherAlgorithm
+		nonceSize int
+	}{
+		{alg: constants.CipherAESGCM, nonceSize: 12},
+		{alg: constants.CipherChaCha20Poly1305, nonceSize: 12},
+		{alg: constants.CipherXChaCha20Poly1305, nonceSize: 24},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.alg.String(), func(t *testing.T) {
+			cipher, err := crypto.NewCipher(tt.alg, testData.ValidKey32)
+			helpers.AssertNoError(t, err)
+			helpers.AssertEqual(t, tt.nonceSize+16, cipher.Overhead())
+
+			ciphertext, err := cipher.Encrypt(plaintext)
+			helpers.AssertNoError(t, err)
+			helpers.AssertEqual(t, len(plaintext)+tt.nonceSize+16, len(ciphertext))
+
+			decrypted, err := cipher.Decrypt(ciphertext)
+			helpers.AssertNoError(t, err)
+			helpers.AssertBytesEqual(t, plaintext, decrypted)
+
+			ciphertext[len(ciphertext)-1] ^= 0x01
+			if _, err := cipher.Decrypt(ciphertext); !errors.Is(err, constants.ErrTagMismatch) {
+				t.Fatalf("Expected %v, got %v", constants.ErrTagMismatch, err)
+			}
+		})
+	}
+
+	if _, err := crypto.NewCipher(constants.CipherAuto, testData.ValidKey32); err == nil {
+		t.Fatal("Expected auto to be rejected as a concrete cipher")
+	}
+}
+
+func TestNewCipher_AADBinding(t *testing.T) {
+	testData := helpers.NewTestData()
+	plaintext := []byte("bound to a context")
+	aad := []byte("dataset:42")
+
+	for _, alg := range []constants.CipherAlgorithm{constants.CipherAESGCM, constants.CipherChaCha20Poly1305, constants.CipherXChaCha20Poly1305} {
+		t.Run(alg.String(), func(t *testing.T) {
+			cipher, err := crypto.NewCipher(alg, testData.ValidKey32)
+			helpers.AssertNoError(t, err)
+
+			ciphertext, err := cipher.EncryptWithAAD(plaintext, aad)
+			helpers.AssertNoError(t, err)
+
+			decrypted, err := cipher.DecryptWithAAD(ciphertext, aad)
+			helpers.AssertNoError(t, err)
+			helpers.AssertBytesEqual(t, plaintext, decrypted)
+
+			for _, other := range [][]byte{nil, []byte("dataset:43")} {
+				if _, err := cipher.DecryptWithAAD(ciphertext, other); !errors.Is(err, constants.ErrTagMismatch) {
+					t.Errorf("Expected %v for aad %q, got %v", constants.ErrTagMismatch, other, err)
+				}
+			}
+		})
+	}
+}
+
+func TestNewCipher_EncryptWithNonce(t *testing.T) {
+	testData := helpers.NewTestData()
+	plaintext := []byte("sealed under a chosen nonce")
+	aad := []byte("dataset:42")
+
+	for _, alg := range []constants.CipherAlgorithm{constants.CipherAESGCM, constants.CipherChaCha20Poly1305, constants.CipherXChaCha20Poly1305} {
+		t.Run(alg.String(), func(t *testing.T) {
+			cipher, err := crypto.NewCipher(alg, testData.ValidKey32)
+			helpers.AssertNoError(t, err)
+
+			nonce := bytes.Repeat([]byte{0x5A}, cipher.NonceSize())
+			ciphertext, err := cipher.EncryptWithNonce(nonce, plaintext, aad)
+			helpers.AssertNoError(t, err)
+			helpers.AssertBytesEqual(t, nonce, ciphertext[:len(nonce)])
+
+			decrypted, err := cipher.DecryptWithAAD(ciphertext, aad)
+			helpers.AssertNoError(t, err)
+			helpers.AssertBytesEqual(t, plaintext, decrypted)
+
+			if _, err := cipher.EncryptWithNonce(nonce[1:], plaintext, aad); !errors.Is(err, constants.ErrInvalidNonce) {
+				t.Errorf("Expected %v for a short nonce, got %v", constants.ErrInvalidNonce, err)
+			}
+		})
+	}
+}
+
+func TestBuild_CipherRoundTrip(t *testing.T) {
+	testData := helpers.NewTestData()
+
+	tests := []struct {
+		name            string
+		opts            []crypto.HeaderOption
+		expectedCipher  constants.CipherAlgorithm
+		expectedVersion uint8
+		nonceSize       int
+	}{
+		{name: "Default is AES-GCM with fixed header", expectedCipher: constants.CipherAESGCM, expectedVersion: constants.FormatVersion2, nonceSize: 16},
+		{name: "Explicit AES-GCM keeps fixed header", opts: []crypto.HeaderOption{crypto.WithCipher(constants.CipherAESGCM)}, expectedCipher: constants.CipherAESGCM, expectedVersion: constants.FormatVersion2, nonceSize: 16},
+		{name: "ChaCha20 requires metadata block", opts: []crypto.HeaderOption{crypto.WithCipher(constants.CipherChaCha20Poly1305)}, expectedCipher: constants.CipherChaCha20Poly1305, expectedVersion: constants.FormatVersion3, nonceSize: 16},
+		{name: "XChaCha20 widens the nonce", opts: []crypto.HeaderOption{crypto.WithCipher(constants.CipherXChaCha20Poly1305)}, expectedCipher: constants.CipherXChaCha20Poly1305, expectedVersion: constants.FormatVersion4, nonceSize: 24},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			header, err := crypto.Build(testData.ValidSalt, 2048, testData.ValidKey32, tt.opts...)
+			helpers.AssertNoError(t, err)
+
+			var buf bytes.Buffer
+			helpers.AssertNoError(t, header.Write(&buf))
+
+			readHeader, err := crypto.ReadHeader(&buf)
+			helpers.AssertNoError(t, err)
+			helpers.AssertNoError(t, readHeader.VerifyKey(testData.ValidKey32))
+			helpers.AssertEqual(t, tt.expectedCipher, readHeader.Cipher())
+			helpers.AssertEqual(t, tt.expectedVersion, readHeader.Version())
+			helpers.AssertEqual(t, tt.nonceSize, len(readHeader.Nonce()))
+			helpers.AssertEqual(t, 0, buf.Len())
+		})
+	}
+
+	// The magic fixes the nonce size, so relabelling the version cannot be parsed
+	header, err := crypto.Build(testData.ValidSalt, 2048, testData.ValidKey32, crypto.WithCipher(constants.CipherXChaCha20Poly1305))
+	helpers.AssertNoError(t, err)
+	var buf bytes.Buffer
+	helpers.AssertNoError(t, header.Write(&buf))
+	relabelled := append([]byte(constants.MagicBytesV3), buf.Bytes()[len(constants.MagicBytesV4):]...)
+	if _, err := crypto.ReadHeader(bytes.NewReader(relabelled)); err == nil {
+		t.Error("Expected an XChaCha20 header relabelled as HWX3 to be rejected")
+	}
+
+	// Auto is resolved before building, so it never reaches a header
+	_, err = crypto.Build(testData.ValidSalt, 1, testData.ValidKey32, crypto.WithCipher(constants.CipherAuto))
+	if !errors.Is(err, constants.ErrInvalidOption) {
+		t.Fatalf("Expected %v, got %v", constants.ErrInvalidOption, err)
+	}
+}
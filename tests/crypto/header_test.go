@@ -257,8 +257,23 @@ func TestReadHeader_InvalidData(t *testing.T) {
 			expectedErr: constants.ErrIncompleteRead,
 		},
 		{
-			name:        "Too short data",
+			name:        "Too short data without magic",
 			data:        make([]byte, constants.TotalHeaderSize-1),
+			expectedErr: constants.ErrInvalidMagic,
+		},
+		{
+			name:        "Two random bytes",
+			data:        []byte{0x8f, 0x3c},
+			expectedErr: constants.ErrInvalidMagic,
+		},
+		{
+			name:        "Magic prefix only",
+			data:        []byte(constants.MagicBytes[:2]),
+			expectedErr: constants.ErrIncompleteRead,
+		},
+		{
+			name:        "Magic followed by a truncated header",
+			data:        append([]byte(constants.MagicBytesV3), make([]byte, 40)...),
 			expectedErr: constants.ErrIncompleteRead,
 		},
 		{
@@ -505,3 +520,45 @@ func createTamperedHeader() []byte {
 	data[10] ^= 0xFF
 	return data
 }
+
+func TestReadableFormatVersions_MatchReadHeader(t *testing.T) {
+	testData := helpers.NewTestData()
+
+	// One header of each version this binary can write
+	written := make(map[uint8][]byte)
+	for _, opts := range [][]crypto.HeaderOption{
+		nil,
+		{crypto.WithCipher(constants.CipherChaCha20Poly1305)},
+		{crypto.WithCipher(constants.CipherXChaCha20Poly1305)},
+	} {
+		header, err := crypto.Build(testData.ValidSalt, 2048, testData.ValidKey32, opts...)
+		helpers.AssertNoError(t, err)
+		var buf bytes.Buffer
+		helpers.AssertNoError(t, header.Write(&buf))
+		written[header.Version()] = buf.Bytes()
+	}
+
+	// Relabel every header with every HWX magic and collect the versions read back
+	var accepted []uint8
+	for digit := byte('0'); digit <= '9'; digit++ {
+		for _, data := range written {
+			relabelled := append([]byte("HWX"+string(digit)), data[len(constants.MagicBytes):]...)
+			header, err := crypto.ReadHeader(bytes.NewReader(relabelled))
+			if err != nil {
+				continue
+			}
+			helpers.AssertEqual(t, digit-'0', header.Version())
+			accepted = append(accepted, header.Version())
+		}
+	}
+
+	readable := crypto.ReadableFormatVersions()
+	helpers.AssertEqual(t, len(readable), len(accepted))
+	for i := range readable {
+		helpers.AssertEqual(t, readable[i], accepted[i])
+	}
+
+	header, err := crypto.Build(testData.ValidSalt, 2048, testData.ValidKey32)
+	helpers.AssertNoError(t, err)
+	helpers.AssertEqual(t, crypto.DefaultFormatVersion, header.Version())
+}
@@ -2,6 +2,9 @@ package crypto
 
 import (
 	"bytes"
+	"encoding/hex"
+	"errors"
+	"fmt"
 	"testing"
 
 	"github.com/hambosto/hexwarden/internal/constants"
@@ -384,3 +387,116 @@ func TestDeriveKey_EdgeCases(t *testing.T) {
 		helpers.AssertEqual(t, constants.KeySize, len(key))
 	})
 }
+
+func TestDeriveKeyN(t *testing.T) {
+	testData := helpers.NewTestData()
+	password := []byte(testData.TestPassword)
+	salt := testData.ValidSalt
+	params := crypto.KDFParams{Time: 1, Memory: 64, Threads: 1}
+
+	for _, n := range []int{16, 32, 64} {
+		t.Run(fmt.Sprintf("%d bytes", n), func(t *testing.T) {
+			first, err := crypto.DeriveKeyNWithParams(password, salt, n, params)
+			helpers.AssertNoError(t, err)
+			helpers.AssertEqual(t, n, len(first))
+
+			second, err := crypto.DeriveKeyNWithParams(password, salt, n, params)
+			helpers.AssertNoError(t, err)
+			helpers.AssertBytesEqual(t, first, second)
+		})
+	}
+
+	t.Run("Default length matches DeriveKey", func(t *testing.T) {
+		key, err := crypto.DeriveKeyWithParams(password, salt, params)
+		helpers.AssertNoError(t, err)
+		keyN, err := crypto.DeriveKeyNWithParams(password, salt, constants.KeySize, params)
+		helpers.AssertNoError(t, err)
+		helpers.AssertBytesEqual(t, key, keyN)
+	})
+}
+
+func TestDeriveKeyN_InvalidLength(t *testing.T) {
+	testData := helpers.NewTestData()
+
+	tests := []struct {
+		name string
+		n    int
+	}{
+		{"Zero", 0},
+		{"Negative", -1},
+		{"Below minimum", constants.MinDerivedSize - 1},
+		{"Above maximum", constants.MaxDerivedSize + 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			key, err := crypto.DeriveKeyN([]byte(testData.TestPassword), testData.ValidSalt, tt.n)
+			if !errors.Is(err, constants.ErrInvalidKeyLength) {
+				t.Fatalf("Expected ErrInvalidKeyLength, got %v", err)
+			}
+			if key != nil {
+				t.Error("Expected no key on error")
+			}
+		})
+	}
+}
+
+func TestApplyPepper(t *testing.T) {
+	password := []byte("correct horse")
+
+	helpers.AssertBytesEqual(t, password, crypto.ApplyPepper(password, nil))
+
+	first := crypto.ApplyPepper(password, []byte("pepper one"))
+	helpers.AssertBytesEqual(t, first, crypto.ApplyPepper(password, []byte("pepper one")))
+	if bytes.Equal(first, crypto.ApplyPepper(password, []byte("pepper two"))) {
+		t.Error("Expected different peppers to give different secrets")
+	}
+	if bytes.Equal(first, password) {
+		t.Error("Expected the pepper to change the secret")
+	}
+}
+
+func TestDeriveKey_Argon2Variants(t *testing.T) {
+	salt := make([]byte, constants.SaltSize)
+	for i := range salt {
+		salt[i] = byte(i)
+	}
+
+	tests := []struct {
+		name    string
+		variant string
+		want    string
+		wantErr error
+	}{
+		{name: "Argon2id", variant: "id", want: "c69292eade4d82142df1f938d162cc8e1ede3e2a2c2e61fad8eb43a19b94760a"},
+		{name: "Argon2i", variant: "argon2i", want: "20d06181d7d1f10dff62fde1ab54390e2b7e4d66790f7d23bea152656b6968e7"},
+		{name: "Argon2d is not available", variant: "d", wantErr: constants.ErrInvalidKDFParams},
+		{name: "Unknown variant", variant: "x", wantErr: constants.ErrInvalidKDFParams},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			variant, err := crypto.ParseArgon2Variant(tt.variant)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("Expected %v, got %v", tt.wantErr, err)
+				}
+				return
+			}
+			helpers.AssertNoError(t, err)
+
+			params := crypto.KDFParams{Time: 2, Memory: 64, Threads: 1, Variant: variant}
+			key, err := crypto.DeriveKeyWithParams([]byte("password"), salt, params)
+			helpers.AssertNoError(t, err)
+			helpers.AssertEqual(t, tt.want, hex.EncodeToString(key))
+		})
+	}
+
+	t.Run("Unknown variant value", func(t *testing.T) {
+		params := crypto.KDFParams{Time: 2, Memory: 64, Threads: 1, Variant: constants.Argon2Variant(9)}
+		_, err := crypto.DeriveKeyWithParams([]byte("password"), salt, params)
+		if !errors.Is(err, constants.ErrInvalidKDFParams) {
+			t.Fatalf("Expected %v, got %v", constants.ErrInvalidKDFParams, err)
+		}
+	})
+}
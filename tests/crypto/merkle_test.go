@@ -0,0 +1,96 @@
+package crypto
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/hambosto/hexwarden/internal/constants"
+	"github.com/hambosto/hexwarden/internal/infrastructure/crypto"
+	"github.com/hambosto/hexwarden/tests/helpers"
+)
+
+// merkleLeaves returns n distinct leaf hashes
+func merkleLeaves(n int) [][]byte {
+	leaves := make([][]byte, n)
+	for i := range leaves {
+		leaves[i] = crypto.HashMerkleLeaf([]byte(fmt.Sprintf("chunk %d", i)))
+	}
+	return leaves
+}
+
+func TestMerkleTree_Proofs(t *testing.T) {
+	for _, n := range []int{1, 2, 3, 4, 5, 7, 8, 9, 16, 17} {
+		t.Run(fmt.Sprintf("%d leaves", n), func(t *testing.T) {
+			leaves := merkleLeaves(n)
+			tree := crypto.NewMerkleTree(leaves)
+			root := tree.Root()
+			helpers.AssertEqual(t, n, tree.Leaves())
+
+			for i, leaf := range leaves {
+				proof, err := tree.Proof(i)
+				helpers.AssertNoError(t, err)
+				if !crypto.VerifyMerkleProof(root, leaf, i, proof) {
+					t.Fatalf("Proof for leaf %d did not verify", i)
+				}
+
+				tampered := crypto.HashMerkleLeaf([]byte("tampered"))
+				if crypto.VerifyMerkleProof(root, tampered, i, proof) {
+					t.Fatalf("Tampered leaf %d verified", i)
+				}
+				if n > 1 && crypto.VerifyMerkleProof(root, leaf, (i+1)%n, proof) {
+					t.Fatalf("Leaf %d verified at the wrong position", i)
+				}
+			}
+
+			if _, err := tree.Proof(n); !errors.Is(err, constants.ErrChunkNotFound) {
+				t.Errorf("Expected ErrChunkNotFound, got %v", err)
+			}
+		})
+	}
+}
+
+func TestMerkleTree_SidecarRoundTrip(t *testing.T) {
+	for _, n := range []int{0, 1, 6} {
+		t.Run(fmt.Sprintf("%d leaves", n), func(t *testing.T) {
+			tree := crypto.NewMerkleTree(merkleLeaves(n))
+
+			var buf bytes.Buffer
+			_, err := tree.WriteTo(&buf)
+			helpers.AssertNoError(t, err)
+
+			read, err := crypto.ReadMerkleTree(bytes.NewReader(buf.Bytes()))
+			helpers.AssertNoError(t, err)
+			helpers.AssertEqual(t, n, read.Leaves())
+			helpers.AssertBytesEqual(t, tree.Root(), read.Root())
+		})
+	}
+}
+
+func TestReadMerkleTree_Invalid(t *testing.T) {
+	var buf bytes.Buffer
+	_, err := crypto.NewMerkleTree(merkleLeaves(3)).WriteTo(&buf)
+	helpers.AssertNoError(t, err)
+	valid := buf.Bytes()
+
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"Empty", nil},
+		{"Bad magic", append([]byte("XXXX"), valid[4:]...)},
+		{"Unknown version", append(append([]byte(constants.MerkleTreeMagic), 99), valid[5:]...)},
+		{"Truncated", valid[:len(valid)-1]},
+		{"Trailing data", append(bytes.Clone(valid), 0)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := crypto.ReadMerkleTree(bytes.NewReader(tt.data))
+			if !errors.Is(err, constants.ErrInvalidMerkle) {
+				t.Errorf("Expected ErrInvalidMerkle, got %v", err)
+			}
+		})
+	}
+}
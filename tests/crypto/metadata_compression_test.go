@@ -0,0 +1,146 @@
+package crypto
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/hambosto/hexwarden/internal/constants"
+	"github.com/hambosto/hexwarden/internal/infrastructure/crypto"
+	"github.com/hambosto/hexwarden/tests/helpers"
+)
+
+// largeMetadataOptions fills the metadata block with every kind of field,
+// including a hint long and repetitive enough to compress well
+func largeMetadataOptions(t *testing.T, kek []byte) []crypto.HeaderOption {
+	t.Helper()
+
+	slotSalt := bytes.Repeat([]byte{0x5a, 0xc3}, constants.SaltSize/2)
+	return []crypto.HeaderOption{
+		crypto.WithKDFParams(crypto.KDFParams{Time: 2, Memory: 32 * 1024, Threads: 2}),
+		crypto.WithFilename("quarterly/report-final.pdf"),
+		crypto.WithHint(strings.Repeat("the street we lived on, ", 10)),
+		crypto.WithComment("Board pack for the third quarter"),
+		crypto.WithKeySlot(slotSalt, kek),
+		crypto.WithKeySlot(slotSalt, kek),
+	}
+}
+
+func TestMetadataCompression_RoundTrip(t *testing.T) {
+	testData := helpers.NewTestData()
+	kek := bytes.Repeat([]byte{0x42}, constants.KeySize)
+
+	tests := []struct {
+		name        string
+		opts        []crypto.HeaderOption
+		wantVersion uint8
+		wantHint    string
+		wantName    string
+		wantSlots   int
+	}{
+		{
+			name:        "Minimal header keeps the fixed layout",
+			opts:        []crypto.HeaderOption{crypto.WithMetadataCompression()},
+			wantVersion: constants.FormatVersion2,
+		},
+		{
+			name:        "Small block stays uncompressed",
+			opts:        []crypto.HeaderOption{crypto.WithMetadataCompression(), crypto.WithHint("first pet")},
+			wantVersion: constants.FormatVersion3,
+			wantHint:    "first pet",
+		},
+		{
+			name:        "Large block",
+			opts:        append(largeMetadataOptions(t, kek), crypto.WithMetadataCompression()),
+			wantVersion: constants.FormatVersion3,
+			wantHint:    strings.Repeat("the street we lived on, ", 10),
+			wantName:    "quarterly/report-final.pdf",
+			wantSlots:   2,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			header, err := crypto.Build(testData.ValidSalt, 4096, testData.ValidKey32, tt.opts...)
+			helpers.AssertNoError(t, err)
+
+			var buf bytes.Buffer
+			helpers.AssertNoError(t, header.Write(&buf))
+			helpers.AssertEqual(t, header.Size(), buf.Len())
+			if tt.wantVersion == constants.FormatVersion2 {
+				helpers.AssertEqual(t, constants.TotalHeaderSize, buf.Len())
+			}
+
+			readHeader, err := crypto.ReadHeader(&buf)
+			helpers.AssertNoError(t, err)
+			helpers.AssertNoError(t, readHeader.VerifyKey(testData.ValidKey32))
+			helpers.AssertEqual(t, tt.wantVersion, readHeader.Version())
+			helpers.AssertEqual(t, tt.wantHint, readHeader.Hint())
+			helpers.AssertEqual(t, tt.wantSlots, readHeader.KeySlots())
+
+			name, err := readHeader.Filename(testData.ValidKey32)
+			helpers.AssertNoError(t, err)
+			helpers.AssertEqual(t, tt.wantName, name)
+
+			if tt.wantSlots > 0 {
+				key, err := readHeader.OpenKeySlot(1, kek)
+				helpers.AssertNoError(t, err)
+				helpers.AssertBytesEqual(t, testData.ValidKey32, key)
+			}
+		})
+	}
+}
+
+func TestMetadataCompression_Shrinks(t *testing.T) {
+	testData := helpers.NewTestData()
+	kek := bytes.Repeat([]byte{0x42}, constants.KeySize)
+
+	plain, err := crypto.Build(testData.ValidSalt, 4096, testData.ValidKey32, largeMetadataOptions(t, kek)...)
+	helpers.AssertNoError(t, err)
+	compressed, err := crypto.Build(testData.ValidSalt, 4096, testData.ValidKey32, append(largeMetadataOptions(t, kek), crypto.WithMetadataCompression())...)
+	helpers.AssertNoError(t, err)
+
+	if compressed.Size() >= plain.Size() {
+		t.Fatalf("Expected the compressed header to be smaller, got %d and %d bytes", compressed.Size(), plain.Size())
+	}
+
+	var buf bytes.Buffer
+	helpers.AssertNoError(t, compressed.Write(&buf))
+	data := buf.Bytes()
+	if bytes.Contains(data, []byte("the street we lived on")) {
+		t.Error("Expected the hint to be stored compressed")
+	}
+
+	// The compressed block is covered by the checksum like an uncompressed one
+	data[len(constants.MagicBytesV3)+constants.SaltSizeBytes+constants.OriginalSizeBytes+constants.MetadataLenSize+10] ^= 0xFF
+	_, err = crypto.ReadHeader(bytes.NewReader(data))
+	helpers.AssertError(t, err, constants.ErrChecksumMismatch)
+}
+
+func TestMetadataCompression_Reseal(t *testing.T) {
+	testData := helpers.NewTestData()
+	kek := bytes.Repeat([]byte{0x42}, constants.KeySize)
+
+	header, err := crypto.Build(testData.ValidSalt, 4096, testData.ValidKey32, append(largeMetadataOptions(t, kek), crypto.WithMetadataCompression())...)
+	helpers.AssertNoError(t, err)
+	var buf bytes.Buffer
+	helpers.AssertNoError(t, header.Write(&buf))
+
+	// A header read back stays compressed when one of its fields changes
+	readHeader, err := crypto.ReadHeader(&buf)
+	helpers.AssertNoError(t, err)
+	helpers.AssertNoError(t, readHeader.SetFilename(testData.ValidKey32, "renamed.pdf"))
+	buf.Reset()
+	helpers.AssertNoError(t, readHeader.Write(&buf))
+	if bytes.Contains(buf.Bytes(), []byte("the street we lived on")) {
+		t.Error("Expected the resealed hint to stay compressed")
+	}
+
+	resealed, err := crypto.ReadHeader(&buf)
+	helpers.AssertNoError(t, err)
+	helpers.AssertNoError(t, resealed.VerifyKey(testData.ValidKey32))
+	name, err := resealed.Filename(testData.ValidKey32)
+	helpers.AssertNoError(t, err)
+	helpers.AssertEqual(t, "renamed.pdf", name)
+	helpers.AssertEqual(t, 2, resealed.KeySlots())
+}
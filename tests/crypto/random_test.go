@@ -0,0 +1,174 @@
+package crypto
+
+import (
+	"bytes"
+	"crypto/rand"
+	"errors"
+	"io"
+	mathrand "math/rand/v2"
+	"testing"
+	"time"
+
+	"github.com/hambosto/hexwarden/internal/constants"
+	"github.com/hambosto/hexwarden/internal/infrastructure/crypto"
+	"github.com/hambosto/hexwarden/tests/helpers"
+)
+
+// seededReader returns a deterministic random stream for reproducible output
+func seededReader(seed byte) io.Reader {
+	var key [32]byte
+	key[0] = seed
+	return mathrand.NewChaCha8(key)
+}
+
+func TestGenerateSaltFrom_SeededReaderIsReproducible(t *testing.T) {
+	first, err := crypto.GenerateSaltFrom(seededReader(1))
+	helpers.AssertNoError(t, err)
+	second, err := crypto.GenerateSaltFrom(seededReader(1))
+	helpers.AssertNoError(t, err)
+	other, err := crypto.GenerateSaltFrom(seededReader(2))
+	helpers.AssertNoError(t, err)
+
+	helpers.AssertBytesEqual(t, first, second)
+	helpers.AssertBytesNotEqual(t, first, other)
+	helpers.AssertNoError(t, crypto.ValidateSalt(first))
+}
+
+func TestNewHeaderWithRandom_SeededReaderIsReproducible(t *testing.T) {
+	testData := helpers.NewTestData()
+
+	encode := func() []byte {
+		header, err := crypto.NewHeaderWithRandom(testData.ValidSalt, 1024, testData.ValidKey32, seededReader(7))
+		helpers.AssertNoError(t, err)
+		var buf bytes.Buffer
+		helpers.AssertNoError(t, header.Write(&buf))
+		return buf.Bytes()
+	}
+
+	helpers.AssertBytesEqual(t, encode(), encode())
+}
+
+func TestBuild_WithRandom(t *testing.T) {
+	testData := helpers.NewTestData()
+
+	first, err := crypto.Build(testData.ValidSalt, 1024, testData.ValidKey32, crypto.WithRandom(seededReader(3)))
+	helpers.AssertNoError(t, err)
+	second, err := crypto.Build(testData.ValidSalt, 1024, testData.ValidKey32, crypto.WithRandom(seededReader(3)))
+	helpers.AssertNoError(t, err)
+	helpers.AssertBytesEqual(t, first.Nonce(), second.Nonce())
+
+	_, err = crypto.Build(testData.ValidSalt, 1024, testData.ValidKey32, crypto.WithRandom(nil))
+	assertErrorIs(t, err, constants.ErrInvalidOption)
+}
+
+func TestInjectedRandom_WeakSourcesRejected(t *testing.T) {
+	testData := helpers.NewTestData()
+
+	tests := []struct {
+		name   string
+		random func() io.Reader
+	}{
+		{
+			name:   "All zeros",
+			random: func() io.Reader { return bytes.NewReader(make([]byte, 1024)) },
+		},
+		{
+			name:   "Repeating pattern",
+			random: func() io.Reader { return bytes.NewReader(bytes.Repeat([]byte{0xAB, 0xCD, 0xEF, 0x12}, 256)) },
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := crypto.GenerateSaltFrom(tt.random())
+			assertErrorIs(t, err, constants.ErrWeakRandom)
+
+			_, err = crypto.NewHeaderWithRandom(testData.ValidSalt, 1024, testData.ValidKey32, tt.random())
+			assertErrorIs(t, err, constants.ErrWeakRandom)
+
+			_, err = crypto.Build(testData.ValidSalt, 1024, testData.ValidKey32, crypto.WithRandom(tt.random()))
+			assertErrorIs(t, err, constants.ErrWeakRandom)
+		})
+	}
+}
+
+func TestInjectedRandom_ShortReadFails(t *testing.T) {
+	testData := helpers.NewTestData()
+
+	_, err := crypto.GenerateSaltFrom(bytes.NewReader([]byte{1, 2, 3}))
+	assertErrorIs(t, err, constants.ErrSaltGeneration)
+
+	_, err = crypto.NewHeaderWithRandom(testData.ValidSalt, 1024, testData.ValidKey32, bytes.NewReader([]byte{1, 2, 3}))
+	helpers.AssertError(t, err, nil)
+}
+
+func TestValidateSalt_WeakSaltIsWeakRandom(t *testing.T) {
+	testData := helpers.NewTestData()
+	assertErrorIs(t, crypto.ValidateSalt(testData.WeakSalt), constants.ErrWeakRandom)
+}
+
+// lowDiversityReader returns random bytes drawn from only eight values
+type lowDiversityReader struct{ src io.Reader }
+
+func (r lowDiversityReader) Read(p []byte) (int, error) {
+	n, err := r.src.Read(p)
+	for i := range p[:n] {
+		p[i] &= 0x07
+	}
+	return n, err
+}
+
+// blockingReader never returns, like an RNG waiting for entropy early in boot
+type blockingReader struct{ release chan struct{} }
+
+func (r blockingReader) Read(p []byte) (int, error) {
+	<-r.release
+	return 0, io.EOF
+}
+
+func TestCheckEntropy(t *testing.T) {
+	stuck := make([]byte, constants.SaltSize)
+	_, err := io.ReadFull(seededReader(9), stuck)
+	helpers.AssertNoError(t, err)
+
+	tests := []struct {
+		name    string
+		random  io.Reader
+		wantErr bool
+	}{
+		{name: "System source", random: rand.Reader},
+		{name: "Seeded source", random: seededReader(4)},
+		{name: "All zeros", random: bytes.NewReader(make([]byte, 1024)), wantErr: true},
+		{name: "Repeating pattern", random: bytes.NewReader(bytes.Repeat([]byte{0xAB, 0xCD, 0xEF, 0x12}, 256)), wantErr: true},
+		{name: "Stuck source", random: bytes.NewReader(bytes.Repeat(stuck, 8)), wantErr: true},
+		{name: "Few byte values", random: lowDiversityReader{seededReader(5)}, wantErr: true},
+		{name: "Short read", random: bytes.NewReader([]byte{1, 2, 3}), wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := crypto.CheckEntropy(tt.random, time.Second)
+			if !tt.wantErr {
+				helpers.AssertNoError(t, err)
+				return
+			}
+			assertErrorIs(t, err, constants.ErrEntropyCheck)
+		})
+	}
+}
+
+func TestCheckEntropy_BlockedSourceTimesOut(t *testing.T) {
+	random := blockingReader{release: make(chan struct{})}
+	defer close(random.release)
+
+	err := crypto.CheckEntropy(random, 20*time.Millisecond)
+	assertErrorIs(t, err, constants.ErrEntropyCheck)
+}
+
+// assertErrorIs fails unless err wraps target
+func assertErrorIs(t *testing.T, err, target error) {
+	t.Helper()
+	if !errors.Is(err, target) {
+		t.Fatalf("expected %v, got %v", target, err)
+	}
+}
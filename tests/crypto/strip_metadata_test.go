@@ -0,0 +1,98 @@
+package crypto
+
+import (
+	"bytes"
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/hambosto/hexwarden/internal/constants"
+	"github.com/hambosto/hexwarden/internal/infrastructure/crypto"
+	"github.com/hambosto/hexwarden/tests/helpers"
+)
+
+func TestHeader_StripMetadata(t *testing.T) {
+	testData := helpers.NewTestData()
+	params := crypto.KDFParams{Time: 2, Memory: 32 * 1024, Threads: 2}
+	identifying := []crypto.HeaderOption{
+		crypto.WithFilename("payroll.xlsx"),
+		crypto.WithHint("the usual"),
+		crypto.WithComment("Q3 numbers for Dana"),
+		crypto.WithThumbnail([]byte{0xFF, 0xD8, 0xFF, 0xD9}),
+	}
+
+	tests := []struct {
+		name            string
+		opts            []crypto.HeaderOption
+		expectedRemoved []string
+		expectedVersion uint8
+	}{
+		{
+			name:            "Only identifying fields returns to fixed header",
+			opts:            identifying,
+			expectedRemoved: []string{"filename", "hint", "comment", "thumbnail"},
+			expectedVersion: constants.FormatVersion2,
+		},
+		{
+			name:            "Key derivation parameters are kept",
+			opts:            append(slices.Clone(identifying), crypto.WithKDFParams(params)),
+			expectedRemoved: []string{"filename", "hint", "comment", "thumbnail"},
+			expectedVersion: constants.FormatVersion3,
+		},
+		{
+			name:            "Hint only",
+			opts:            []crypto.HeaderOption{crypto.WithHint("the usual"), crypto.WithCipher(constants.CipherXChaCha20Poly1305)},
+			expectedRemoved: []string{"hint"},
+			expectedVersion: constants.FormatVersion4,
+		},
+		{
+			name:            "Nothing to strip",
+			expectedVersion: constants.FormatVersion2,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			header, err := crypto.Build(testData.ValidSalt, 1024, testData.ValidKey32, tt.opts...)
+			helpers.AssertNoError(t, err)
+			nonce := header.Nonce()
+			kdfParams := header.KDFParams()
+			cipher := header.Cipher()
+
+			removed, err := header.StripMetadata(testData.ValidKey32)
+			helpers.AssertNoError(t, err)
+			if !slices.Equal(tt.expectedRemoved, removed) {
+				t.Fatalf("Expected %v removed, got %v", tt.expectedRemoved, removed)
+			}
+
+			var buf bytes.Buffer
+			helpers.AssertNoError(t, header.Write(&buf))
+			readHeader, err := crypto.ReadHeader(&buf)
+			helpers.AssertNoError(t, err)
+			helpers.AssertNoError(t, readHeader.VerifyKey(testData.ValidKey32))
+
+			helpers.AssertEqual(t, tt.expectedVersion, readHeader.Version())
+			helpers.AssertEqual(t, false, readHeader.HasFilename())
+			helpers.AssertEqual(t, false, readHeader.HasComment())
+			helpers.AssertEqual(t, false, readHeader.HasThumbnail())
+			helpers.AssertEqual(t, "", readHeader.Hint())
+			helpers.AssertEqual(t, kdfParams, readHeader.KDFParams())
+			helpers.AssertEqual(t, cipher, readHeader.Cipher())
+			helpers.AssertBytesEqual(t, nonce, readHeader.Nonce())
+			helpers.AssertBytesEqual(t, testData.ValidSalt, readHeader.Salt())
+		})
+	}
+}
+
+func TestHeader_StripMetadataRejected(t *testing.T) {
+	testData := helpers.NewTestData()
+
+	header, err := crypto.Build(testData.ValidSalt, 1024, testData.ValidKey32, crypto.WithHint("the usual"))
+	helpers.AssertNoError(t, err)
+
+	if _, err := header.StripMetadata(testData.ValidKey24); !errors.Is(err, constants.ErrAuthFailure) {
+		t.Fatalf("Expected ErrAuthFailure for a wrong key, got %v", err)
+	}
+	helpers.AssertEqual(t, "the usual", header.Hint())
+	helpers.AssertNoError(t, header.VerifyKey(testData.ValidKey32))
+}
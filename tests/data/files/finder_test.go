@@ -1,11 +1,14 @@
 package files
 
 import (
+	"errors"
 	"fmt"
+	"io/fs"
 	"os"
 	"path/filepath"
 	"slices"
 	"testing"
+	"time"
 
 	"github.com/hambosto/hexwarden/internal/constants"
 	"github.com/hambosto/hexwarden/internal/data/files"
@@ -44,9 +47,9 @@ func TestFinder_FindEligibleFiles(t *testing.T) {
 		"another.pdf" + constants.FileExtension:   []byte("encrypted pdf"),
 		"subdir/nested.txt":                       []byte("nested content"),
 		"subdir/nested" + constants.FileExtension: []byte("nested encrypted"),
-		".hidden.txt": []byte("hidden file"),
-		"test.go":     []byte("go source file"), // Should be excluded
-		"README.md":   []byte("readme content"),
+		".hidden.txt":                             []byte("hidden file"),
+		"test.go":                                 []byte("go source file"), // Should be excluded
+		"README.md":                               []byte("readme content"),
 	}
 
 	helpers.CreateTestFiles(t, tmpDir, testFiles)
@@ -54,7 +57,7 @@ func TestFinder_FindEligibleFiles(t *testing.T) {
 	finder := files.NewFinder()
 
 	t.Run("Encrypt mode", func(t *testing.T) {
-		eligibleFiles, err := finder.FindEligibleFiles(constants.ModeEncrypt)
+		eligibleFiles, _, err := finder.FindEligibleFiles(constants.ModeEncrypt)
 		helpers.AssertNoError(t, err)
 
 		// Should find unencrypted files, excluding hidden files and excluded extensions
@@ -79,7 +82,7 @@ func TestFinder_FindEligibleFiles(t *testing.T) {
 	})
 
 	t.Run("Decrypt mode", func(t *testing.T) {
-		eligibleFiles, err := finder.FindEligibleFiles(constants.ModeDecrypt)
+		eligibleFiles, _, err := finder.FindEligibleFiles(constants.ModeDecrypt)
 		helpers.AssertNoError(t, err)
 
 		// Should find encrypted files only
@@ -188,7 +191,13 @@ func TestFinder_GetOutputPath(t *testing.T) {
 			name:     "Decrypt mode without extension",
 			input:    "test.txt",
 			mode:     constants.ModeDecrypt,
-			expected: "test.txt", // Should return as-is if no extension to remove
+			expected: "test.txt" + constants.DecryptedExtension, // Never the input path itself
+		},
+		{
+			name:     "Decrypt mode with bare extension",
+			input:    filepath.Join("dir", constants.FileExtension),
+			mode:     constants.ModeDecrypt,
+			expected: filepath.Join("dir", constants.FileExtension) + constants.DecryptedExtension,
 		},
 	}
 
@@ -200,6 +209,61 @@ func TestFinder_GetOutputPath(t *testing.T) {
 	}
 }
 
+func TestFinder_GetOutputPathWithSuffix(t *testing.T) {
+	finder := files.NewFinder()
+
+	tests := []struct {
+		name     string
+		input    string
+		mode     constants.ProcessorMode
+		suffix   string
+		expected string
+	}{
+		{
+			name:     "Suffix replaces extension",
+			input:    "report.pdf" + constants.FileExtension,
+			mode:     constants.ModeDecrypt,
+			suffix:   ".dec",
+			expected: "report.pdf.dec",
+		},
+		{
+			name:     "Suffix appended without extension",
+			input:    "report.pdf",
+			mode:     constants.ModeDecrypt,
+			suffix:   ".dec",
+			expected: "report.pdf.dec",
+		},
+		{
+			name:     "Suffix equal to extension does not reuse input",
+			input:    "report.pdf" + constants.FileExtension,
+			mode:     constants.ModeDecrypt,
+			suffix:   constants.FileExtension,
+			expected: "report.pdf" + constants.FileExtension + constants.DecryptedExtension,
+		},
+		{
+			name:     "Empty suffix strips extension",
+			input:    filepath.Join("dir", "report.pdf") + constants.FileExtension,
+			mode:     constants.ModeDecrypt,
+			expected: filepath.Join("dir", "report.pdf"),
+		},
+		{
+			name:     "Suffix ignored when encrypting",
+			input:    "report.pdf",
+			mode:     constants.ModeEncrypt,
+			suffix:   ".dec",
+			expected: "report.pdf" + constants.FileExtension,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := finder.GetOutputPathWithSuffix(tt.input, tt.mode, tt.suffix)
+			helpers.AssertEqual(t, tt.expected, result)
+			helpers.AssertNotEqual(t, tt.input, result)
+		})
+	}
+}
+
 func TestFinder_GetFileInfo(t *testing.T) {
 	// Create temporary files for testing
 	tmpDir := helpers.CreateTempDir(t)
@@ -287,7 +351,7 @@ func TestFinder_ExclusionRules(t *testing.T) {
 	helpers.CreateTestFiles(t, tmpDir, testFiles)
 
 	finder := files.NewFinder()
-	eligibleFiles, err := finder.FindEligibleFiles(constants.ModeEncrypt)
+	eligibleFiles, _, err := finder.FindEligibleFiles(constants.ModeEncrypt)
 	helpers.AssertNoError(t, err)
 
 	// Should only find normal.txt
@@ -315,13 +379,13 @@ func TestFinder_EmptyDirectory(t *testing.T) {
 	finder := files.NewFinder()
 
 	t.Run("Encrypt mode - empty directory", func(t *testing.T) {
-		eligibleFiles, err := finder.FindEligibleFiles(constants.ModeEncrypt)
+		eligibleFiles, _, err := finder.FindEligibleFiles(constants.ModeEncrypt)
 		helpers.AssertNoError(t, err)
 		helpers.AssertEqual(t, 0, len(eligibleFiles))
 	})
 
 	t.Run("Decrypt mode - empty directory", func(t *testing.T) {
-		eligibleFiles, err := finder.FindEligibleFiles(constants.ModeDecrypt)
+		eligibleFiles, _, err := finder.FindEligibleFiles(constants.ModeDecrypt)
 		helpers.AssertNoError(t, err)
 		helpers.AssertEqual(t, 0, len(eligibleFiles))
 	})
@@ -358,7 +422,7 @@ func TestFinder_NestedDirectories(t *testing.T) {
 	finder := files.NewFinder()
 
 	t.Run("Find all unencrypted files", func(t *testing.T) {
-		eligibleFiles, err := finder.FindEligibleFiles(constants.ModeEncrypt)
+		eligibleFiles, _, err := finder.FindEligibleFiles(constants.ModeEncrypt)
 		helpers.AssertNoError(t, err)
 
 		expectedFiles := []string{
@@ -380,7 +444,7 @@ func TestFinder_NestedDirectories(t *testing.T) {
 	})
 
 	t.Run("Find encrypted files", func(t *testing.T) {
-		eligibleFiles, err := finder.FindEligibleFiles(constants.ModeDecrypt)
+		eligibleFiles, _, err := finder.FindEligibleFiles(constants.ModeDecrypt)
 		helpers.AssertNoError(t, err)
 
 		expectedFiles := []string{
@@ -392,6 +456,129 @@ func TestFinder_NestedDirectories(t *testing.T) {
 	})
 }
 
+func TestFinder_FindEligibleFilesIn_Since(t *testing.T) {
+	tmpDir := helpers.CreateTempDir(t)
+	defer helpers.CleanupTempDir(t, tmpDir)
+
+	testFiles := map[string][]byte{
+		"old.txt":                                []byte("old content"),
+		"recent.txt":                             []byte("recent content"),
+		"nested/older.txt":                       []byte("older nested content"),
+		"nested/fresh.txt":                       []byte("fresh nested content"),
+		"nested/fresh" + constants.FileExtension: []byte("fresh encrypted"),
+	}
+	helpers.CreateTestFiles(t, tmpDir, testFiles)
+
+	now := time.Now()
+	mtimes := map[string]time.Time{
+		"old.txt":                                now.Add(-72 * time.Hour),
+		"recent.txt":                             now.Add(-1 * time.Hour),
+		"nested/older.txt":                       now.Add(-30 * 24 * time.Hour),
+		"nested/fresh.txt":                       now.Add(-10 * time.Minute),
+		"nested/fresh" + constants.FileExtension: now.Add(-5 * time.Minute),
+	}
+	for name, mtime := range mtimes {
+		err := os.Chtimes(filepath.Join(tmpDir, name), mtime, mtime)
+		helpers.AssertNoError(t, err)
+	}
+
+	finder := files.NewFinder()
+
+	tests := []struct {
+		name     string
+		mode     constants.ProcessorMode
+		since    time.Time
+		expected []string
+	}{
+		{
+			name:  "No cutoff returns all files",
+			mode:  constants.ModeEncrypt,
+			since: time.Time{},
+			expected: []string{
+				"old.txt", "recent.txt",
+				filepath.Join("nested", "older.txt"), filepath.Join("nested", "fresh.txt"),
+			},
+		},
+		{
+			name:     "Last 24 hours",
+			mode:     constants.ModeEncrypt,
+			since:    now.Add(-24 * time.Hour),
+			expected: []string{"recent.txt", filepath.Join("nested", "fresh.txt")},
+		},
+		{
+			name:     "Last 30 minutes",
+			mode:     constants.ModeEncrypt,
+			since:    now.Add(-30 * time.Minute),
+			expected: []string{filepath.Join("nested", "fresh.txt")},
+		},
+		{
+			name:     "Cutoff in the future",
+			mode:     constants.ModeEncrypt,
+			since:    now.Add(time.Hour),
+			expected: []string{},
+		},
+		{
+			name:     "Decrypt mode honors cutoff",
+			mode:     constants.ModeDecrypt,
+			since:    now.Add(-24 * time.Hour),
+			expected: []string{filepath.Join("nested", "fresh"+constants.FileExtension)},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			eligibleFiles, _, err := finder.FindEligibleFilesIn(tmpDir, tt.mode, tt.since)
+			helpers.AssertNoError(t, err)
+
+			if len(eligibleFiles) != len(tt.expected) {
+				t.Fatalf("Expected %d files, got %d: %v", len(tt.expected), len(eligibleFiles), eligibleFiles)
+			}
+			for _, expected := range tt.expected {
+				if !slices.Contains(eligibleFiles, filepath.Join(tmpDir, expected)) {
+					t.Errorf("Expected file %s not found in eligible files %v", expected, eligibleFiles)
+				}
+			}
+		})
+	}
+}
+
+func TestFinder_FindEligibleFilesIn_PermissionDenied(t *testing.T) {
+	tmpDir := helpers.CreateTempDir(t)
+	defer helpers.CleanupTempDir(t, tmpDir)
+
+	helpers.CreateTestFiles(t, tmpDir, map[string][]byte{
+		"before/readable.txt": []byte("found before"),
+		"locked/hidden.txt":   []byte("never listed"),
+		"zafter/readable.txt": []byte("found after"),
+	})
+	locked := filepath.Join(tmpDir, "locked")
+	helpers.AssertNoError(t, os.Chmod(locked, 0o000))
+	defer os.Chmod(locked, 0o700) //nolint:errcheck
+	if _, err := os.ReadDir(locked); err == nil {
+		t.Skip("Directory permissions are not enforced here, as when running as root")
+	}
+
+	eligibleFiles, skipped, err := files.NewFinder().FindEligibleFilesIn(tmpDir, constants.ModeEncrypt, time.Time{})
+	helpers.AssertNoError(t, err)
+
+	expected := []string{
+		filepath.Join(tmpDir, "before", "readable.txt"),
+		filepath.Join(tmpDir, "zafter", "readable.txt"),
+	}
+	slices.Sort(eligibleFiles)
+	if !slices.Equal(expected, eligibleFiles) {
+		t.Fatalf("Expected %v, got %v", expected, eligibleFiles)
+	}
+
+	if len(skipped) != 1 {
+		t.Fatalf("Expected one skipped path, got %v", skipped)
+	}
+	helpers.AssertEqual(t, locked, skipped[0].Path)
+	if !errors.Is(skipped[0].Err, fs.ErrPermission) {
+		t.Errorf("Expected a permission error, got %v", skipped[0].Err)
+	}
+}
+
 // BenchmarkFinder_FindEligibleFiles benchmarks file finding performance
 func BenchmarkFinder_FindEligibleFiles(b *testing.B) {
 	// Create a temporary directory with many files
@@ -424,7 +611,7 @@ func BenchmarkFinder_FindEligibleFiles(b *testing.B) {
 	finder := files.NewFinder()
 
 	for b.Loop() {
-		_, err := finder.FindEligibleFiles(constants.ModeEncrypt)
+		_, _, err := finder.FindEligibleFiles(constants.ModeEncrypt)
 		if err != nil {
 			b.Fatal(err)
 		}
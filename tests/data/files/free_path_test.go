@@ -0,0 +1,37 @@
+package files
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/hambosto/hexwarden/internal/data/files"
+	"github.com/hambosto/hexwarden/tests/helpers"
+)
+
+func TestManager_FreePath(t *testing.T) {
+	tests := []struct {
+		name     string
+		path     string
+		existing []string
+		expected string
+	}{
+		{name: "Free path is kept", path: "report.pdf", expected: "report.pdf"},
+		{name: "Number goes before the extension", path: "report.pdf.hex", existing: []string{"report.pdf.hex"}, expected: "report.pdf (1).hex"},
+		{name: "Taken numbers are skipped", path: "report.pdf", existing: []string{"report.pdf", "report (1).pdf"}, expected: "report (2).pdf"},
+		{name: "No extension", path: "notes", existing: []string{"notes"}, expected: "notes (1)"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			for _, name := range tt.existing {
+				helpers.AssertNoError(t, os.WriteFile(filepath.Join(dir, name), nil, 0o600))
+			}
+
+			path, err := files.NewManager().FreePath(filepath.Join(dir, tt.path))
+			helpers.AssertNoError(t, err)
+			helpers.AssertEqual(t, filepath.Join(dir, tt.expected), path)
+		})
+	}
+}
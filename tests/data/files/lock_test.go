@@ -0,0 +1,55 @@
+package files
+
+import (
+	"errors"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/hambosto/hexwarden/internal/constants"
+	"github.com/hambosto/hexwarden/internal/data/files"
+	"github.com/hambosto/hexwarden/tests/helpers"
+)
+
+func TestManager_LockFile(t *testing.T) {
+	if runtime.GOOS != "linux" && runtime.GOOS != "darwin" && runtime.GOOS != "freebsd" && runtime.GOOS != "windows" {
+		t.Skipf("File locking is not implemented on %s", runtime.GOOS)
+	}
+
+	tmpDir := helpers.CreateTempDir(t)
+	defer helpers.CleanupTempDir(t, tmpDir)
+
+	path := filepath.Join(tmpDir, "locked.txt")
+	helpers.WriteFileContent(t, path, []byte("in use"))
+
+	manager := files.NewManager()
+	lock, err := manager.LockFile(path)
+	helpers.AssertNoError(t, err)
+
+	if _, err := manager.LockFile(path); !errors.Is(err, constants.ErrFileInUse) {
+		t.Fatalf("Expected %v while the lock is held, got %v", constants.ErrFileInUse, err)
+	}
+
+	helpers.AssertNoError(t, lock.Unlock())
+
+	relocked, err := manager.LockFile(path)
+	helpers.AssertNoError(t, err)
+	helpers.AssertNoError(t, relocked.Unlock())
+}
+
+func TestManager_LockNewFile(t *testing.T) {
+	tmpDir := helpers.CreateTempDir(t)
+	defer helpers.CleanupTempDir(t, tmpDir)
+
+	path := filepath.Join(tmpDir, "claimed.hex")
+	manager := files.NewManager()
+
+	lock, err := manager.LockNewFile(path)
+	helpers.AssertNoError(t, err)
+	defer lock.Unlock() //nolint:errcheck
+
+	helpers.AssertFileExists(t, path)
+	if _, err := manager.LockNewFile(path); !errors.Is(err, constants.ErrFileExists) {
+		t.Fatalf("Expected %v for a claimed destination, got %v", constants.ErrFileExists, err)
+	}
+}
@@ -0,0 +1,128 @@
+package files
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/hambosto/hexwarden/internal/constants"
+	"github.com/hambosto/hexwarden/internal/data/files"
+	"github.com/hambosto/hexwarden/tests/helpers"
+)
+
+// passRecorder records the bytes written during each overwrite pass; every
+// seek back to the start begins a new pass
+type passRecorder struct {
+	passes [][]byte
+}
+
+func (r *passRecorder) Seek(offset int64, whence int) (int64, error) {
+	if offset != 0 || whence != io.SeekStart {
+		return 0, errors.New("unexpected seek")
+	}
+	r.passes = append(r.passes, nil)
+	return 0, nil
+}
+
+func (r *passRecorder) Write(p []byte) (int, error) {
+	if len(r.passes) == 0 {
+		return 0, errors.New("write before seek")
+	}
+	last := len(r.passes) - 1
+	r.passes[last] = append(r.passes[last], p...)
+	return len(p), nil
+}
+
+// isRandom reports whether data looks random rather than a repeated byte
+func isRandom(data []byte) bool {
+	return !bytes.Equal(data, bytes.Repeat([]byte{0x00}, len(data))) &&
+		!bytes.Equal(data, bytes.Repeat([]byte{0xFF}, len(data)))
+}
+
+func TestOverwrite_Patterns(t *testing.T) {
+	const size = 10000 // Spans several write buffers
+
+	zeros := bytes.Repeat([]byte{0x00}, size)
+	ones := bytes.Repeat([]byte{0xFF}, size)
+
+	tests := []struct {
+		name    string
+		pattern constants.OverwritePattern
+		passes  [][]byte // nil marks a random pass
+	}{
+		{name: "Random", pattern: constants.PatternRandom, passes: [][]byte{nil, nil, nil}},
+		{name: "Default", pattern: "", passes: [][]byte{nil, nil, nil}},
+		{name: "DoD", pattern: constants.PatternDoD, passes: [][]byte{zeros, ones, nil}},
+		{name: "Zero", pattern: constants.PatternZero, passes: [][]byte{zeros}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recorder := &passRecorder{}
+			helpers.AssertNoError(t, files.Overwrite(recorder, size, tt.pattern))
+			helpers.AssertEqual(t, len(tt.passes), len(recorder.passes))
+
+			for i, want := range tt.passes {
+				got := recorder.passes[i]
+				helpers.AssertEqual(t, size, len(got))
+				if want == nil {
+					if !isRandom(got) {
+						t.Errorf("Pass %d: expected random data", i+1)
+					}
+					continue
+				}
+				helpers.AssertBytesEqual(t, want, got)
+			}
+		})
+	}
+}
+
+func TestParseOverwritePattern(t *testing.T) {
+	for _, name := range []string{"random", "dod", "zero"} {
+		pattern, err := files.ParseOverwritePattern(name)
+		helpers.AssertNoError(t, err)
+		helpers.AssertEqual(t, constants.OverwritePattern(name), pattern)
+	}
+
+	pattern, err := files.ParseOverwritePattern("")
+	helpers.AssertNoError(t, err)
+	helpers.AssertEqual(t, constants.PatternRandom, pattern)
+
+	for _, name := range []string{"gutmann", "Zero", "dod "} {
+		if _, err := files.ParseOverwritePattern(name); !errors.Is(err, constants.ErrUnknownPattern) {
+			t.Errorf("%q: expected ErrUnknownPattern, got %v", name, err)
+		}
+	}
+	if err := files.Overwrite(&passRecorder{}, 1, "gutmann"); !errors.Is(err, constants.ErrUnknownPattern) {
+		t.Errorf("Expected ErrUnknownPattern, got %v", err)
+	}
+}
+
+func TestManager_SecureDeleteWithPattern(t *testing.T) {
+	tmpDir := helpers.CreateTempDir(t)
+	defer helpers.CleanupTempDir(t, tmpDir)
+
+	path := filepath.Join(tmpDir, "secret.txt")
+	helpers.WriteFileContent(t, path, []byte("sensitive data"))
+
+	manager := files.NewManagerWithPattern(constants.PatternDoD)
+	helpers.AssertNoError(t, manager.Remove(path, constants.DeleteSecure))
+	helpers.AssertFileNotExists(t, path)
+}
+
+func TestManager_SecureDeleteRefusesDirectory(t *testing.T) {
+	tmpDir := helpers.CreateTempDir(t)
+	defer helpers.CleanupTempDir(t, tmpDir)
+
+	dir := filepath.Join(tmpDir, "subdir")
+	helpers.AssertNoError(t, os.Mkdir(dir, 0o700))
+
+	err := files.NewManager().Remove(dir, constants.DeleteSecure)
+	if !errors.Is(err, constants.ErrNotRegularFile) {
+		t.Fatalf("Expected %v, got %v", constants.ErrNotRegularFile, err)
+	}
+	helpers.AssertFileExists(t, dir)
+}
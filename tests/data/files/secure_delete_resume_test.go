@@ -0,0 +1,149 @@
+package files
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/hambosto/hexwarden/internal/constants"
+	"github.com/hambosto/hexwarden/internal/data/files"
+	"github.com/hambosto/hexwarden/tests/helpers"
+)
+
+// passCounter counts the overwrite passes reaching the file, each starting
+// with a seek back to the start. Once failFrom passes have begun every write
+// fails, and once crashFrom have begun it panics as if the process were killed
+type passCounter struct {
+	io.WriteSeeker
+	passes    int
+	failFrom  int
+	crashFrom int
+}
+
+// errKilled is what a crashing passCounter panics with
+var errKilled = errors.New("killed")
+
+func (c *passCounter) Seek(offset int64, whence int) (int64, error) {
+	c.passes++
+	return c.WriteSeeker.Seek(offset, whence)
+}
+
+func (c *passCounter) Write(p []byte) (int, error) {
+	if c.crashFrom > 0 && c.passes >= c.crashFrom {
+		panic(errKilled)
+	}
+	if c.failFrom > 0 && c.passes >= c.failFrom {
+		return 0, errors.New("interrupted")
+	}
+	return c.WriteSeeker.Write(p)
+}
+
+// hookedManager returns a manager for pattern whose passes go through counter
+func hookedManager(pattern constants.OverwritePattern, counter *passCounter) *files.Manager {
+	manager := files.NewManagerWithPattern(pattern)
+	manager.SetOverwriteHook(func(file io.WriteSeeker) io.WriteSeeker {
+		counter.WriteSeeker = file
+		return counter
+	})
+	return manager
+}
+
+// killedWipe starts a DoD secure deletion of path that dies during its second
+// pass, leaving the file and the progress record behind
+func killedWipe(t *testing.T, path string) {
+	t.Helper()
+	defer func() {
+		if r := recover(); r != errKilled {
+			t.Fatalf("Expected the deletion to be killed, got %v", r)
+		}
+	}()
+	_ = hookedManager(constants.PatternDoD, &passCounter{crashFrom: 2}).Remove(path, constants.DeleteSecure)
+}
+
+func TestManager_SecureDeleteResumes(t *testing.T) {
+	const size = 10000
+	content := bytes.Repeat([]byte("secret"), size/6+1)[:size]
+
+	tests := []struct {
+		name       string
+		between    func(t *testing.T, path string)
+		wantPasses int
+	}{
+		{name: "Continues with the next pass", wantPasses: 2},
+		{
+			name: "File truncated meanwhile",
+			between: func(t *testing.T, path string) {
+				helpers.AssertNoError(t, os.Truncate(path, 0))
+			},
+			wantPasses: 3,
+		},
+		{
+			name: "Another file at the same path",
+			between: func(t *testing.T, path string) {
+				helpers.AssertNoError(t, os.Remove(path))
+				helpers.WriteFileContent(t, path, content)
+			},
+			wantPasses: 3,
+		},
+		{
+			name: "File already removed",
+			between: func(t *testing.T, path string) {
+				helpers.AssertNoError(t, os.Remove(path))
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			path := filepath.Join(dir, "secret.txt")
+			statePath := filepath.Join(dir, ".secret.txt"+constants.WipeStateSuffix)
+			helpers.WriteFileContent(t, path, content)
+
+			// The first pass completed before the process died in the second
+			killedWipe(t, path)
+			helpers.AssertBytesEqual(t, make([]byte, size), helpers.ReadFileContent(t, path))
+			helpers.AssertFileExists(t, statePath)
+
+			if tt.between != nil {
+				tt.between(t, path)
+			}
+
+			resumed := &passCounter{}
+			helpers.AssertNoError(t, hookedManager(constants.PatternDoD, resumed).Remove(path, constants.DeleteSecure))
+			helpers.AssertEqual(t, tt.wantPasses, resumed.passes)
+			helpers.AssertFileNotExists(t, path)
+			helpers.AssertFileNotExists(t, statePath)
+		})
+	}
+
+	t.Run("Another pattern starts over", func(t *testing.T) {
+		dir := t.TempDir()
+		path := filepath.Join(dir, "secret.txt")
+		helpers.WriteFileContent(t, path, content)
+		killedWipe(t, path)
+
+		restarted := &passCounter{}
+		helpers.AssertNoError(t, hookedManager(constants.PatternRandom, restarted).Remove(path, constants.DeleteSecure))
+		helpers.AssertEqual(t, constants.OverwritePasses, restarted.passes)
+		helpers.AssertFileNotExists(t, path)
+	})
+}
+
+func TestManager_SecureDeleteFailedPassStillRemoves(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "secret.txt")
+	statePath := filepath.Join(dir, ".secret.txt"+constants.WipeStateSuffix)
+	helpers.WriteFileContent(t, path, []byte("secret content"))
+
+	// The second pass fails after the first has completed
+	err := hookedManager(constants.PatternDoD, &passCounter{failFrom: 2}).Remove(path, constants.DeleteSecure)
+	if !errors.Is(err, constants.ErrSecureDeleteFailed) {
+		t.Fatalf("Expected %v, got %v", constants.ErrSecureDeleteFailed, err)
+	}
+	helpers.AssertFileNotExists(t, path)
+	helpers.AssertFileNotExists(t, statePath)
+}
@@ -0,0 +1,49 @@
+//go:build unix
+
+package files
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+
+	"github.com/hambosto/hexwarden/internal/constants"
+	"github.com/hambosto/hexwarden/internal/data/files"
+	"github.com/hambosto/hexwarden/tests/helpers"
+)
+
+func TestManager_SecureDeleteRefusesSpecialFiles(t *testing.T) {
+	tmpDir := helpers.CreateTempDir(t)
+	defer helpers.CleanupTempDir(t, tmpDir)
+
+	fifo := filepath.Join(tmpDir, "pipe")
+	helpers.AssertNoError(t, syscall.Mkfifo(fifo, 0o600))
+
+	target := filepath.Join(tmpDir, "target.txt")
+	helpers.WriteFileContent(t, target, []byte("must survive"))
+	link := filepath.Join(tmpDir, "link.txt")
+	helpers.AssertNoError(t, os.Symlink(target, link))
+
+	for _, tt := range []struct {
+		name string
+		path string
+	}{
+		{name: "Named pipe", path: fifo},
+		{name: "Symbolic link", path: link},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			// Opening a pipe for writing would block without a reader, so a hang fails the test too
+			err := files.NewManager().Remove(tt.path, constants.DeleteSecure)
+			if !errors.Is(err, constants.ErrNotRegularFile) {
+				t.Fatalf("Expected %v, got %v", constants.ErrNotRegularFile, err)
+			}
+			if _, err := os.Lstat(tt.path); err != nil {
+				t.Errorf("Expected %s to be left in place, got %v", tt.path, err)
+			}
+		})
+	}
+
+	helpers.AssertBytesEqual(t, []byte("must survive"), helpers.ReadFileContent(t, target))
+}
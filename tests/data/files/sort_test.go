@@ -0,0 +1,95 @@
+package files
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/hambosto/hexwarden/internal/constants"
+	"github.com/hambosto/hexwarden/internal/data/files"
+	"github.com/hambosto/hexwarden/tests/helpers"
+)
+
+func TestFinder_SortFiles(t *testing.T) {
+	dir := t.TempDir()
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	// Name, size and age each give a different order; tied.txt ties with b.txt
+	// on size and with c.txt on modification time, so ties fall back to the path
+	entries := []struct {
+		name    string
+		size    int
+		modTime time.Time
+	}{
+		{"c.txt", 30, base.Add(1 * time.Hour)},
+		{"a.txt", 20, base.Add(3 * time.Hour)},
+		{"b.txt", 10, base.Add(2 * time.Hour)},
+		{"tied.txt", 10, base.Add(1 * time.Hour)},
+		{"sub/d.txt", 40, base},
+	}
+	found := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		path := filepath.Join(dir, filepath.FromSlash(entry.name))
+		helpers.AssertNoError(t, os.MkdirAll(filepath.Dir(path), 0o750))
+		helpers.AssertNoError(t, os.WriteFile(path, make([]byte, entry.size), 0o600))
+		helpers.AssertNoError(t, os.Chtimes(path, entry.modTime, entry.modTime))
+		found = append(found, path)
+	}
+
+	tests := []struct {
+		name  string
+		order constants.SortOrder
+		want  []string
+	}{
+		{"None keeps the order given", constants.SortNone, nil},
+		{"Name", constants.SortName, []string{"a.txt", "b.txt", "c.txt", "sub/d.txt", "tied.txt"}},
+		{"Size", constants.SortSize, []string{"b.txt", "tied.txt", "a.txt", "c.txt", "sub/d.txt"}},
+		{"Modification time", constants.SortModTime, []string{"sub/d.txt", "c.txt", "tied.txt", "b.txt", "a.txt"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			want := make([]string, len(tt.want))
+			for i, name := range tt.want {
+				want[i] = filepath.Join(dir, filepath.FromSlash(name))
+			}
+
+			// Every starting order gives the same result
+			for _, start := range [][]string{slices.Clone(found), reversed(found)} {
+				expected := want
+				if tt.order == constants.SortNone {
+					expected = slices.Clone(start)
+				}
+				helpers.AssertNoError(t, files.NewFinder().SortFiles(start, tt.order))
+				if !slices.Equal(expected, start) {
+					t.Fatalf("Expected %v, got %v", expected, start)
+				}
+			}
+		})
+	}
+}
+
+func TestParseSortOrder(t *testing.T) {
+	for _, name := range []string{"", "name", "size", "mtime"} {
+		order, err := files.ParseSortOrder(name)
+		helpers.AssertNoError(t, err)
+		helpers.AssertEqual(t, constants.SortOrder(name), order)
+	}
+
+	if _, err := files.ParseSortOrder("random"); !errors.Is(err, constants.ErrUnknownSortOrder) {
+		t.Fatalf("Expected %v, got %v", constants.ErrUnknownSortOrder, err)
+	}
+	if err := files.NewFinder().SortFiles([]string{"a"}, "ctime"); !errors.Is(err, constants.ErrUnknownSortOrder) {
+		t.Fatalf("Expected %v, got %v", constants.ErrUnknownSortOrder, err)
+	}
+}
+
+// reversed returns a reversed copy of paths
+func reversed(paths []string) []string {
+	out := slices.Clone(paths)
+	slices.Reverse(out)
+	return out
+}
@@ -0,0 +1,54 @@
+package files
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/hambosto/hexwarden/internal/data/files"
+	"github.com/hambosto/hexwarden/tests/helpers"
+)
+
+func TestManager_StreamTarget(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("/dev/null does not exist on windows")
+	}
+
+	tmpDir := helpers.CreateTempDir(t)
+	defer helpers.CleanupTempDir(t, tmpDir)
+
+	regular := filepath.Join(tmpDir, "regular.txt")
+	helpers.WriteFileContent(t, regular, []byte("data"))
+
+	manager := files.NewManager()
+
+	tests := []struct {
+		name         string
+		path         string
+		streamTarget bool
+	}{
+		{"Character device", os.DevNull, true},
+		{"Regular file", regular, false},
+		{"Missing file", filepath.Join(tmpDir, "missing"), false},
+		{"Directory", tmpDir, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			helpers.AssertEqual(t, tt.streamTarget, manager.IsStreamTarget(tt.path))
+		})
+	}
+
+	// An existing device passes the must-not-exist check and is written in place
+	helpers.AssertNoError(t, manager.ValidatePath(os.DevNull, false))
+	helpers.AssertNoError(t, manager.CheckSpace(os.DevNull, 1<<62))
+	helpers.AssertNoError(t, manager.SyncFile(os.DevNull))
+
+	device, err := manager.CreateFile(os.DevNull)
+	helpers.AssertNoError(t, err)
+	_, err = device.Write([]byte("discarded"))
+	helpers.AssertNoError(t, err)
+	helpers.AssertNoError(t, device.Close())
+	helpers.AssertFileExists(t, os.DevNull)
+}
@@ -0,0 +1,25 @@
+package files
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/hambosto/hexwarden/internal/data/files"
+	"github.com/hambosto/hexwarden/tests/helpers"
+)
+
+func TestManager_Sync(t *testing.T) {
+	tmpDir := helpers.CreateTempDir(t)
+	defer helpers.CleanupTempDir(t, tmpDir)
+
+	path := filepath.Join(tmpDir, "synced.txt")
+	helpers.WriteFileContent(t, path, []byte("flush me"))
+
+	manager := files.NewManager()
+	helpers.AssertNoError(t, manager.SyncFile(path))
+	helpers.AssertNoError(t, manager.SyncDir(tmpDir))
+
+	if err := manager.SyncFile(filepath.Join(tmpDir, "missing.txt")); err == nil {
+		t.Fatal("Expected syncing a missing file to fail")
+	}
+}
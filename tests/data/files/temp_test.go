@@ -0,0 +1,110 @@
+package files
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/hambosto/hexwarden/internal/data/files"
+	"github.com/hambosto/hexwarden/tests/helpers"
+)
+
+// useTempDir points temporary files at dir for the duration of the test
+func useTempDir(t *testing.T, dir string) {
+	t.Helper()
+
+	helpers.AssertNoError(t, files.SetTempDir(dir))
+	t.Cleanup(func() { _ = files.SetTempDir("") })
+}
+
+// assertEmptyDir fails the test if dir holds any entries
+func assertEmptyDir(t *testing.T, dir string) {
+	t.Helper()
+
+	entries, err := os.ReadDir(dir)
+	helpers.AssertNoError(t, err)
+	for _, entry := range entries {
+		t.Errorf("Unexpected leftover file %s", entry.Name())
+	}
+}
+
+func TestManager_TempFiles(t *testing.T) {
+	targetDir := helpers.CreateTempDir(t)
+	defer helpers.CleanupTempDir(t, targetDir)
+	tempDir := helpers.CreateTempDir(t)
+	defer helpers.CleanupTempDir(t, tempDir)
+
+	useTempDir(t, tempDir)
+	manager := files.NewManager()
+	target := filepath.Join(targetDir, "target.hex")
+
+	tests := []struct {
+		name   string
+		finish func(tmpPath string) error
+		target []byte
+	}{
+		{
+			name:   "Commit",
+			finish: func(tmpPath string) error { return manager.CommitTemp(tmpPath, target) },
+			target: []byte("replacement"),
+		},
+		{
+			name:   "Discard",
+			finish: func(tmpPath string) error { manager.DiscardTemp(tmpPath); return nil },
+			target: []byte("original"),
+		},
+		{
+			name:   "Interrupted",
+			finish: func(string) error { files.RemoveTempFiles(); return nil },
+			target: []byte("original"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			helpers.WriteFileContent(t, target, []byte("original"))
+
+			tmpFile, err := manager.CreateTemp(target)
+			helpers.AssertNoError(t, err)
+			helpers.AssertEqual(t, tempDir, filepath.Dir(tmpFile.Name()))
+
+			_, err = tmpFile.WriteString("replacement")
+			helpers.AssertNoError(t, err)
+			helpers.AssertNoError(t, tmpFile.Close())
+
+			helpers.AssertNoError(t, tt.finish(tmpFile.Name()))
+			helpers.AssertBytesEqual(t, tt.target, helpers.ReadFileContent(t, target))
+			assertEmptyDir(t, tempDir)
+		})
+	}
+}
+
+func TestManager_TempFilesDefaultNextToTarget(t *testing.T) {
+	targetDir := helpers.CreateTempDir(t)
+	defer helpers.CleanupTempDir(t, targetDir)
+
+	t.Setenv("TMPDIR", "")
+	useTempDir(t, "")
+
+	target := filepath.Join(targetDir, "target.hex")
+	tmpFile, err := files.NewManager().CreateTemp(target)
+	helpers.AssertNoError(t, err)
+	defer files.NewManager().DiscardTemp(tmpFile.Name())
+	defer tmpFile.Close() //nolint:errcheck
+
+	helpers.AssertEqual(t, targetDir, filepath.Dir(tmpFile.Name()))
+}
+
+func TestSetTempDir_Invalid(t *testing.T) {
+	tmpDir := helpers.CreateTempDir(t)
+	defer helpers.CleanupTempDir(t, tmpDir)
+
+	regular := filepath.Join(tmpDir, "regular.txt")
+	helpers.WriteFileContent(t, regular, []byte("data"))
+
+	for _, dir := range []string{regular, filepath.Join(tmpDir, "missing")} {
+		if err := files.SetTempDir(dir); err == nil {
+			t.Errorf("Expected an error for %s", dir)
+		}
+	}
+}
@@ -0,0 +1,167 @@
+package keychain
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/hambosto/hexwarden/internal/constants"
+	"github.com/hambosto/hexwarden/internal/data/keychain"
+	"github.com/hambosto/hexwarden/tests/helpers"
+)
+
+// mockBackend keeps entries in memory
+type mockBackend struct {
+	entries map[string]string
+	sets    int
+}
+
+func newMockBackend() *mockBackend {
+	return &mockBackend{entries: make(map[string]string)}
+}
+
+func (m *mockBackend) Get(name string) (string, error) {
+	password, ok := m.entries[name]
+	if !ok {
+		return "", constants.ErrSecretNotFound
+	}
+	return password, nil
+}
+
+func (m *mockBackend) Set(name, password string) error {
+	m.entries[name] = password
+	m.sets++
+	return nil
+}
+
+// countingPrompt returns a prompt that answers with password and counts its calls
+func countingPrompt(password string, calls *int) func() (string, error) {
+	return func() (string, error) {
+		*calls++
+		return password, nil
+	}
+}
+
+func TestKeychain_StoreThenFetch(t *testing.T) {
+	backend := newMockBackend()
+	kc := keychain.New(backend)
+	prompts := 0
+
+	// First use prompts, and nothing is stored until the password is confirmed to work
+	password, save, err := kc.Lookup("myarchive", countingPrompt("s3cret", &prompts))
+	helpers.AssertNoError(t, err)
+	helpers.AssertEqual(t, "s3cret", password)
+	helpers.AssertEqual(t, 1, prompts)
+	helpers.AssertEqual(t, 0, backend.sets)
+
+	helpers.AssertNoError(t, save())
+	helpers.AssertEqual(t, "s3cret", backend.entries["myarchive"])
+
+	// Second use fetches without prompting
+	password, save, err = kc.Lookup("myarchive", countingPrompt("other", &prompts))
+	helpers.AssertNoError(t, err)
+	helpers.AssertEqual(t, "s3cret", password)
+	helpers.AssertEqual(t, 1, prompts)
+
+	helpers.AssertNoError(t, save())
+	helpers.AssertEqual(t, 1, backend.sets)
+}
+
+func TestKeychain_EnvTakesPrecedenceAndIsReadOnly(t *testing.T) {
+	t.Setenv(keychain.EnvVar("my-archive.v2"), "from-env")
+	helpers.AssertEqual(t, "HEXWARDEN_SECRET_MY_ARCHIVE_V2", keychain.EnvVar("my-archive.v2"))
+
+	backend := newMockBackend()
+	backend.entries["my-archive.v2"] = "from-store"
+	kc := keychain.New(keychain.EnvBackend{}, backend)
+
+	prompts := 0
+	password, _, err := kc.Lookup("my-archive.v2", countingPrompt("typed", &prompts))
+	helpers.AssertNoError(t, err)
+	helpers.AssertEqual(t, "from-env", password)
+	helpers.AssertEqual(t, 0, prompts)
+
+	// Saving skips the read-only environment and lands in the next backend
+	_, save, err := keychain.New(keychain.EnvBackend{}, backend).Lookup("fresh", countingPrompt("typed", &prompts))
+	helpers.AssertNoError(t, err)
+	helpers.AssertNoError(t, save())
+	helpers.AssertEqual(t, "typed", backend.entries["fresh"])
+}
+
+func TestKeychain_PromptErrorIsReturned(t *testing.T) {
+	promptErr := errors.New("prompt failed")
+	kc := keychain.New(newMockBackend())
+
+	_, _, err := kc.Lookup("entry", func() (string, error) { return "", promptErr })
+	helpers.AssertError(t, err, promptErr)
+}
+
+func TestKeychain_InvalidNames(t *testing.T) {
+	kc := keychain.New(newMockBackend())
+
+	for _, name := range []string{"", "../escape", "with space", "a/b", string(make([]byte, 65))} {
+		_, _, err := kc.Lookup(name, func() (string, error) { return "pw", nil })
+		if !errors.Is(err, constants.ErrInvalidKeychainEntry) {
+			t.Errorf("%q: expected %v, got %v", name, constants.ErrInvalidKeychainEntry, err)
+		}
+	}
+}
+
+func TestFileBackend_StoreThenFetch(t *testing.T) {
+	tmpDir := helpers.CreateTempDir(t)
+	defer helpers.CleanupTempDir(t, tmpDir)
+
+	dir := filepath.Join(tmpDir, "secrets")
+	backend := keychain.NewFileBackend(dir)
+
+	_, err := backend.Get("entry")
+	helpers.AssertError(t, err, constants.ErrSecretNotFound)
+
+	helpers.AssertNoError(t, backend.Set("entry", "s3cret"))
+	password, err := backend.Get("entry")
+	helpers.AssertNoError(t, err)
+	helpers.AssertEqual(t, "s3cret", password)
+
+	info, err := os.Stat(filepath.Join(dir, "entry"))
+	helpers.AssertNoError(t, err)
+	if info.Mode().Perm()&0o077 != 0 {
+		t.Errorf("Expected owner-only permissions, got %v", info.Mode().Perm())
+	}
+}
+
+func TestKeychain_WarnsBeforePlaintextWrite(t *testing.T) {
+	dir := t.TempDir()
+	kc := keychain.New(keychain.EnvBackend{}, keychain.NewFileBackend(dir))
+
+	var warned []string
+	kc.OnPlaintext(func(path string) {
+		// Called before the file is written
+		if _, err := os.Stat(path); !os.IsNotExist(err) {
+			t.Errorf("Expected %s not to exist yet, got %v", path, err)
+		}
+		warned = append(warned, path)
+	})
+
+	prompts := 0
+	_, save, err := kc.Lookup("plain", countingPrompt("s3cret", &prompts))
+	helpers.AssertNoError(t, err)
+	helpers.AssertEqual(t, 0, len(warned))
+	helpers.AssertNoError(t, save())
+	helpers.AssertEqual(t, 1, len(warned))
+	helpers.AssertEqual(t, filepath.Join(dir, "plain"), warned[0])
+
+	// Fetching the saved password writes nothing
+	_, save, err = kc.Lookup("plain", countingPrompt("other", &prompts))
+	helpers.AssertNoError(t, err)
+	helpers.AssertNoError(t, save())
+	helpers.AssertEqual(t, 1, len(warned))
+
+	// Other backends are not warned about
+	kc = keychain.New(newMockBackend())
+	kc.OnPlaintext(func(path string) { warned = append(warned, path) })
+	_, save, err = kc.Lookup("plain", countingPrompt("s3cret", &prompts))
+	helpers.AssertNoError(t, err)
+	helpers.AssertNoError(t, save())
+	helpers.AssertEqual(t, 1, len(warned))
+}
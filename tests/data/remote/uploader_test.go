@@ -0,0 +1,77 @@
+package remote
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/hambosto/hexwarden/internal/constants"
+	"github.com/hambosto/hexwarden/internal/data/remote"
+	"github.com/hambosto/hexwarden/tests/helpers"
+)
+
+func TestUploader_StreamsBody(t *testing.T) {
+	var received []byte
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		helpers.AssertEqual(t, http.MethodPut, r.Method)
+		received, _ = io.ReadAll(r.Body)
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer server.Close()
+
+	uploader, err := remote.NewUploaderWithClient(server.Client(), server.URL+"/backup.hex")
+	helpers.AssertNoError(t, err)
+
+	payload := bytes.Repeat([]byte("ciphertext"), 10000)
+	_, err = io.Copy(uploader, bytes.NewReader(payload))
+	helpers.AssertNoError(t, err)
+	helpers.AssertNoError(t, uploader.Close())
+	helpers.AssertBytesEqual(t, payload, received)
+}
+
+func TestUploader_ServerError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.Copy(io.Discard, r.Body)
+		w.WriteHeader(http.StatusForbidden)
+	}))
+	defer server.Close()
+
+	uploader, err := remote.NewUploaderWithClient(server.Client(), server.URL)
+	helpers.AssertNoError(t, err)
+
+	_, _ = uploader.Write([]byte("data"))
+	if err := uploader.Close(); !errors.Is(err, constants.ErrUploadFailed) {
+		t.Fatalf("Expected %v, got %v", constants.ErrUploadFailed, err)
+	}
+}
+
+func TestUploader_AbortDoesNotCompleteUpload(t *testing.T) {
+	completed := make(chan bool, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, err := io.ReadAll(r.Body)
+		completed <- err == nil
+	}))
+	defer server.Close()
+
+	uploader, err := remote.NewUploaderWithClient(server.Client(), server.URL)
+	helpers.AssertNoError(t, err)
+
+	_, _ = uploader.Write([]byte("partial"))
+	uploader.Abort(errors.New("encryption failed"))
+
+	if <-completed {
+		t.Fatal("Expected the server to see an incomplete request body")
+	}
+}
+
+func TestUploader_UnsupportedScheme(t *testing.T) {
+	for _, rawURL := range []string{"ftp://example.com/file", "s3://bucket/key", "file:///tmp/out", "://bad"} {
+		_, err := remote.NewUploader(rawURL)
+		if !errors.Is(err, constants.ErrUnsupportedURL) {
+			t.Errorf("%s: expected %v, got %v", rawURL, constants.ErrUnsupportedURL, err)
+		}
+	}
+}
@@ -0,0 +1,127 @@
+package streaming
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"runtime"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/hambosto/hexwarden/internal/constants"
+	"github.com/hambosto/hexwarden/internal/data/streaming"
+	"github.com/hambosto/hexwarden/tests/helpers"
+)
+
+func TestPool_HonorsWorkerCount(t *testing.T) {
+	const taskCount = 40
+
+	for _, size := range []int{1, 3, 8} {
+		t.Run(fmt.Sprintf("%d workers", size), func(t *testing.T) {
+			var running, peak atomic.Int64
+			pool := streaming.NewPool(size, func(task constants.Task) constants.TaskResult {
+				n := running.Add(1)
+				for current := peak.Load(); n > current && !peak.CompareAndSwap(current, n); current = peak.Load() {
+				}
+				time.Sleep(2 * time.Millisecond)
+				running.Add(-1)
+				return constants.TaskResult{Index: task.Index, Data: task.Data}
+			})
+
+			tasks := make(chan constants.Task, taskCount)
+			results := make(chan constants.TaskResult, taskCount)
+			for i := range taskCount {
+				tasks <- constants.Task{Index: uint64(i), Data: []byte{byte(i)}}
+			}
+			close(tasks)
+
+			helpers.AssertNoError(t, pool.Process(context.Background(), tasks, results))
+			close(results)
+
+			seen := 0
+			for range results {
+				seen++
+			}
+			helpers.AssertEqual(t, taskCount, seen)
+
+			if peak.Load() > int64(size) {
+				t.Errorf("Expected at most %d tasks at once, saw %d", size, peak.Load())
+			}
+
+			stats := pool.Stats()
+			helpers.AssertEqual(t, size, stats.Workers)
+			helpers.AssertEqual(t, int64(taskCount), stats.Tasks)
+			if stats.PeakWorkers < 1 || stats.PeakWorkers > size {
+				t.Errorf("Expected a peak between 1 and %d workers, got %d", size, stats.PeakWorkers)
+			}
+			if stats.MaxQueueDepth < 1 || stats.MaxQueueDepth >= taskCount {
+				t.Errorf("Expected a queue depth between 1 and %d, got %d", taskCount-1, stats.MaxQueueDepth)
+			}
+			if ratio := stats.BusyRatio(); ratio <= 0 || ratio > 1 {
+				t.Errorf("Expected a busy ratio in (0, 1], got %f", ratio)
+			}
+		})
+	}
+}
+
+func TestPoolStats_Add(t *testing.T) {
+	stats := streaming.PoolStats{Workers: 4, PeakWorkers: 2, Tasks: 3, MaxQueueDepth: 5, Busy: time.Second, Elapsed: time.Second}
+	stats.Add(streaming.PoolStats{Workers: 8, PeakWorkers: 1, Tasks: 7, MaxQueueDepth: 2, Busy: 3 * time.Second, Elapsed: time.Second})
+
+	helpers.AssertEqual(t, 8, stats.Workers)
+	helpers.AssertEqual(t, 2, stats.PeakWorkers)
+	helpers.AssertEqual(t, int64(10), stats.Tasks)
+	helpers.AssertEqual(t, 5, stats.MaxQueueDepth)
+	helpers.AssertEqual(t, 0.25, stats.BusyRatio())
+	helpers.AssertEqual(t, 0.0, streaming.PoolStats{}.BusyRatio())
+}
+
+func TestPool_CPUPercent(t *testing.T) {
+	const (
+		size      = 8
+		taskCount = 40
+	)
+
+	run := func(percent int) streaming.PoolStats {
+		pool := streaming.NewPool(size, func(task constants.Task) constants.TaskResult {
+			time.Sleep(2 * time.Millisecond)
+			return constants.TaskResult{Index: task.Index, Data: task.Data}
+		})
+		pool.SetCPUPercent(percent)
+
+		tasks := make(chan constants.Task, taskCount)
+		results := make(chan constants.TaskResult, taskCount)
+		for i := range taskCount {
+			tasks <- constants.Task{Index: uint64(i), Data: []byte{byte(i)}}
+		}
+		close(tasks)
+
+		helpers.AssertNoError(t, pool.Process(context.Background(), tasks, results))
+		helpers.AssertEqual(t, taskCount, len(results))
+		return pool.Stats()
+	}
+
+	// Average concurrency is the busy time spread over the wall time
+	concurrency := func(stats streaming.PoolStats) float64 {
+		return stats.BusyRatio() * float64(stats.Workers)
+	}
+
+	full := run(0)
+	throttled := run(10)
+
+	budget := float64(min(size, runtime.GOMAXPROCS(0))) * 0.1
+	if throttled.PeakWorkers > int(math.Ceil(budget)) {
+		t.Errorf("Expected at most %d tasks at once, saw %d", int(math.Ceil(budget)), throttled.PeakWorkers)
+	}
+	if throttled.PeakWorkers >= full.PeakWorkers {
+		t.Errorf("Expected the throttled peak of %d workers to be below the full-speed %d", throttled.PeakWorkers, full.PeakWorkers)
+	}
+	if concurrency(throttled) >= concurrency(full) {
+		t.Errorf("Expected throttled concurrency %.2f to be below the full-speed %.2f", concurrency(throttled), concurrency(full))
+	}
+	// The last task may leave the pool ahead of the budget, so allow some slack
+	if concurrency(throttled) > budget*1.5+0.1 {
+		t.Errorf("Expected average concurrency near %.2f, got %.2f", budget, concurrency(throttled))
+	}
+}
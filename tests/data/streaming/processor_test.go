@@ -0,0 +1,78 @@
+package streaming
+
+import (
+	"bytes"
+	"io"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hambosto/hexwarden/internal/constants"
+	"github.com/hambosto/hexwarden/internal/data/streaming"
+	"github.com/hambosto/hexwarden/tests/helpers"
+)
+
+// slowWriter delays every write so a stream stays in progress long enough to observe
+type slowWriter struct {
+	w     io.Writer
+	delay time.Duration
+}
+
+func (s slowWriter) Write(b []byte) (int, error) {
+	time.Sleep(s.delay)
+	return s.w.Write(b)
+}
+
+// TestStreamProcessor_ProgressConcurrent reads the snapshot while the stream
+// runs; run it with -race to check the counters are safe to share
+func TestStreamProcessor_ProgressConcurrent(t *testing.T) {
+	const chunkSize = 16 * 1024
+	const chunks = 12
+	input := bytes.Repeat([]byte("progress"), chunks*chunkSize/8)
+
+	processor, err := streaming.NewStreamProcessor(streaming.StreamConfig{
+		Key:         make([]byte, constants.KeySize),
+		Processing:  constants.Encryption,
+		Concurrency: 2,
+		ChunkSize:   chunkSize,
+	})
+	helpers.AssertNoError(t, err)
+	helpers.AssertEqual(t, streaming.Progress{}, processor.Progress())
+
+	done := make(chan struct{})
+	var snapshots []streaming.Progress
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for {
+			snapshots = append(snapshots, processor.Progress())
+			select {
+			case <-done:
+				return
+			case <-time.After(time.Millisecond):
+			}
+		}
+	}()
+
+	var out bytes.Buffer
+	err = processor.Process(bytes.NewReader(input), slowWriter{w: &out, delay: 2 * time.Millisecond}, int64(len(input)))
+	close(done)
+	wg.Wait()
+	helpers.AssertNoError(t, err)
+
+	// Counters only ever grow, and the timestamp follows them
+	for i := 1; i < len(snapshots); i++ {
+		prev, cur := snapshots[i-1], snapshots[i]
+		if cur.Bytes < prev.Bytes || cur.Chunk < prev.Chunk || cur.LastUpdate.Before(prev.LastUpdate) {
+			t.Fatalf("Expected progress to be monotonic, got %+v after %+v", cur, prev)
+		}
+	}
+
+	final := processor.Progress()
+	helpers.AssertEqual(t, int64(len(input)), final.Bytes)
+	helpers.AssertEqual(t, int64(chunks), final.Chunk)
+	if final.LastUpdate.IsZero() || time.Since(final.LastUpdate) > time.Minute {
+		t.Errorf("Expected a recent last update, got %v", final.LastUpdate)
+	}
+}
@@ -1,6 +1,7 @@
 package encoding
 
 import (
+	"errors"
 	"fmt"
 	"strings"
 	"testing"
@@ -393,6 +394,109 @@ func TestEncoder_ErrorRecovery(t *testing.T) {
 	// the specific Reed-Solomon configuration
 }
 
+func TestEncoder_DecodeVerbose(t *testing.T) {
+	encoder, err := encoding.NewDefaultEncoder()
+	helpers.AssertNoError(t, err)
+
+	testData := createRepetitiveData(4096)
+	encoded, err := encoder.Encode(testData)
+	helpers.AssertNoError(t, err)
+
+	totalShards := constants.DataShards + constants.ParityShards
+	shardSize := len(encoded) / totalShards
+
+	// corrupt flips the byte at the given offset of each listed shard
+	corrupt := func(offset int, shards ...int) []byte {
+		corrupted := append([]byte(nil), encoded...)
+		for _, shard := range shards {
+			corrupted[shard*shardSize+offset] ^= 0xFF
+		}
+		return corrupted
+	}
+
+	wholeShard := append([]byte(nil), encoded...)
+	for i := range shardSize {
+		wholeShard[7*shardSize+i] = 0
+	}
+
+	scattered := corrupt(0, 1)
+	scattered[9*shardSize+3] ^= 0xFF
+
+	tests := []struct {
+		name              string
+		input             []byte
+		expectedCorrupted []int
+	}{
+		{name: "Intact data", input: encoded, expectedCorrupted: nil},
+		{name: "Single data shard byte", input: corrupt(10, 2), expectedCorrupted: []int{2}},
+		{name: "Single parity shard byte", input: corrupt(10, 12), expectedCorrupted: []int{12}},
+		{name: "Whole shard overwritten", input: wholeShard, expectedCorrupted: []int{7}},
+		{name: "Different shards in different columns", input: scattered, expectedCorrupted: []int{1, 9}},
+		{name: "Maximum correctable shards", input: corrupt(20, 0, 3, 5, 8, 13), expectedCorrupted: []int{0, 3, 5, 8, 13}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			decoded, report, err := encoder.DecodeVerbose(tt.input)
+			helpers.AssertNoError(t, err)
+			helpers.AssertEqual(t, len(tt.expectedCorrupted) > 0, report.Damaged())
+
+			// Only reported: the data is what Decode returns, corrupt shards included
+			expected, err := encoder.Decode(tt.input)
+			helpers.AssertNoError(t, err)
+			helpers.AssertBytesEqual(t, expected, decoded)
+			helpers.AssertEqual(t, fmt.Sprint(tt.expectedCorrupted), fmt.Sprint(report.Corrupted))
+		})
+	}
+
+	t.Run("Too many corrupt shards", func(t *testing.T) {
+		_, _, err := encoder.DecodeVerbose(corrupt(20, 0, 2, 4, 6, 8, 10))
+		if !errors.Is(err, constants.ErrDecodingFailed) {
+			t.Fatalf("Expected %v, got %v", constants.ErrDecodingFailed, err)
+		}
+	})
+
+	t.Run("Input is not modified", func(t *testing.T) {
+		input := corrupt(10, 2)
+		before := append([]byte(nil), input...)
+		_, _, err := encoder.DecodeVerbose(input)
+		helpers.AssertNoError(t, err)
+		helpers.AssertBytesEqual(t, before, input)
+	})
+}
+
+func TestEncoder_SetReconstruct(t *testing.T) {
+	encoder, err := encoding.NewDefaultEncoder()
+	helpers.AssertNoError(t, err)
+
+	testData := createRepetitiveData(4096)
+	encoded, err := encoder.Encode(testData)
+	helpers.AssertNoError(t, err)
+
+	shardSize := len(encoded) / (constants.DataShards + constants.ParityShards)
+	corrupted := append([]byte(nil), encoded...)
+	corrupted[3*shardSize+5] ^= 0xFF
+
+	encoder.SetReconstruct(false)
+
+	decoded, report, err := encoder.DecodeVerbose(encoded)
+	helpers.AssertNoError(t, err)
+	helpers.AssertBytesEqual(t, testData, decoded[:len(testData)])
+	helpers.AssertEqual(t, false, report.Damaged())
+
+	_, _, err = encoder.DecodeVerbose(corrupted)
+	if !errors.Is(err, constants.ErrParityMismatch) {
+		t.Fatalf("Expected %v, got %v", constants.ErrParityMismatch, err)
+	}
+
+	encoder.SetReconstruct(true)
+
+	decoded, report, err = encoder.DecodeVerbose(corrupted)
+	helpers.AssertNoError(t, err)
+	helpers.AssertBytesEqual(t, corrupted[:len(testData)], decoded[:len(testData)])
+	helpers.AssertEqual(t, fmt.Sprint([]int{3}), fmt.Sprint(report.Corrupted))
+}
+
 func TestEncoder_DataSizeValidation(t *testing.T) {
 	encoder, err := encoding.NewDefaultEncoder()
 	helpers.AssertNoError(t, err)
@@ -0,0 +1,146 @@
+package encoding
+
+import (
+	"bytes"
+	"crypto/rand"
+	"testing"
+
+	"github.com/klauspost/reedsolomon"
+
+	"github.com/hambosto/hexwarden/internal/constants"
+	"github.com/hambosto/hexwarden/internal/infrastructure/encoding"
+	"github.com/hambosto/hexwarden/tests/helpers"
+)
+
+// referenceEncode encodes data with one allocation per shard and joins the
+// shards afterwards, the layout Encode must keep producing byte for byte
+func referenceEncode(t *testing.T, data []byte) []byte {
+	t.Helper()
+
+	enc, err := reedsolomon.New(constants.DataShards, constants.ParityShards)
+	helpers.AssertNoError(t, err)
+
+	shardSize := (len(data) + constants.DataShards - 1) / constants.DataShards
+	shards := make([][]byte, constants.DataShards+constants.ParityShards)
+	for i := range shards {
+		shards[i] = make([]byte, shardSize)
+		if start := i * shardSize; start < len(data) {
+			copy(shards[i], data[start:])
+		}
+	}
+	helpers.AssertNoError(t, enc.Encode(shards))
+	return bytes.Join(shards, nil)
+}
+
+func TestEncoder_ZeroCopyRoundTrip(t *testing.T) {
+	tests := []struct {
+		name string
+		size int
+	}{
+		{name: "Single byte", size: 1},
+		{name: "Uneven shards", size: 1001},
+		{name: "Exact shards", size: 4 * constants.DataShards * 64},
+		{name: "Full chunk", size: constants.DefaultChunkSize},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := make([]byte, tt.size)
+			_, _ = rand.Read(data)
+
+			encoder, err := encoding.NewDefaultEncoder()
+			helpers.AssertNoError(t, err)
+			encoded, err := encoder.Encode(data)
+			helpers.AssertNoError(t, err)
+			helpers.AssertBytesEqual(t, referenceEncode(t, data), encoded)
+
+			copied, err := encoder.Decode(encoded)
+			helpers.AssertNoError(t, err)
+
+			encoder.SetZeroCopy(true)
+			viewed, err := encoder.Decode(encoded)
+			helpers.AssertNoError(t, err)
+			helpers.AssertBytesEqual(t, copied, viewed)
+			helpers.AssertBytesEqual(t, data, viewed[:len(data)])
+
+			// The view shares the input's memory and cannot grow into the parity
+			if &viewed[0] != &encoded[0] {
+				t.Error("Expected the zero-copy result to share memory with the input")
+			}
+			if cap(viewed) != len(viewed) {
+				t.Errorf("Expected the view to be capped at %d bytes, got capacity %d", len(viewed), cap(viewed))
+			}
+		})
+	}
+}
+
+func TestEncoder_ZeroCopyRepair(t *testing.T) {
+	data := createRepetitiveData(4096)
+
+	encoder, err := encoding.NewDefaultEncoder()
+	helpers.AssertNoError(t, err)
+	encoder.SetZeroCopy(true)
+	encoder.SetRepair(true)
+	encoded, err := encoder.Encode(data)
+	helpers.AssertNoError(t, err)
+
+	corrupted := corruptEncodedData(encoded)
+	decoded, err := encoder.Decode(corrupted)
+	helpers.AssertNoError(t, err)
+	helpers.AssertBytesEqual(t, data, decoded[:len(data)])
+
+	// Repaired data is a fresh slice and leaves the corrupt input as it was
+	if &decoded[0] == &corrupted[0] {
+		t.Error("Expected repaired data not to share memory with the input")
+	}
+	if bytes.Equal(corrupted, encoded) {
+		t.Error("Expected the corrupt input to be left unchanged")
+	}
+}
+
+// BenchmarkEncoder_ZeroCopy reports the allocations of encoding a full chunk
+// into a single backing array, and compares decoding it into a copy with
+// returning a view into the encoded input
+func BenchmarkEncoder_ZeroCopy(b *testing.B) {
+	data := make([]byte, constants.DefaultChunkSize)
+	_, _ = rand.Read(data)
+
+	encoder, err := encoding.NewDefaultEncoder()
+	if err != nil {
+		b.Fatal(err)
+	}
+	encoded, err := encoder.Encode(data)
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	b.Run("Encode", func(b *testing.B) {
+		b.ReportAllocs()
+		b.SetBytes(int64(len(data)))
+		for b.Loop() {
+			if _, err := encoder.Encode(data); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+
+	modes := []struct {
+		name     string
+		zeroCopy bool
+	}{
+		{name: "Copy", zeroCopy: false},
+		{name: "ZeroCopy", zeroCopy: true},
+	}
+	for _, mode := range modes {
+		b.Run("Decode/"+mode.name, func(b *testing.B) {
+			encoder.SetZeroCopy(mode.zeroCopy)
+			b.ReportAllocs()
+			b.SetBytes(int64(len(data)))
+			for b.Loop() {
+				if _, err := encoder.Decode(encoded); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
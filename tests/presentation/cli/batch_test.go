@@ -0,0 +1,92 @@
+package cli
+
+import (
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/hambosto/hexwarden/internal/constants"
+	"github.com/hambosto/hexwarden/internal/presentation/cli"
+	"github.com/hambosto/hexwarden/tests/helpers"
+)
+
+func TestDecryptDirectory_AggregatesFailures(t *testing.T) {
+	tmpDir := helpers.CreateTempDir(t)
+	defer helpers.CleanupTempDir(t, tmpDir)
+
+	passwords := map[string]string{
+		"a.txt":     testPassword,
+		"b.txt":     testPassword,
+		"other.txt": "a different password",
+	}
+	for name, password := range passwords {
+		inputPath := filepath.Join(tmpDir, name)
+		helpers.WriteFileContent(t, inputPath, []byte("contents of "+name))
+		helpers.AssertNoError(t, cli.NewCLIProcessor().Encrypt(cli.Options{
+			InputFile: inputPath, OutputFile: inputPath + constants.FileExtension, Password: password, DeleteSource: true,
+		}))
+	}
+	garbled := filepath.Join(tmpDir, "garbled.txt"+constants.FileExtension)
+	helpers.WriteFileContent(t, garbled, []byte("this is not an encrypted file"))
+
+	var err error
+	stdout, _ := captureOutput(t, func() {
+		err = cli.NewCLIProcessor().DecryptDirectory(cli.Options{RecursiveDir: tmpDir, Password: testPassword})
+	})
+
+	var batch *cli.BatchError
+	if !errors.As(err, &batch) {
+		t.Fatalf("Expected a *BatchError, got %v", err)
+	}
+	helpers.AssertEqual(t, constants.ModeDecrypt, batch.Mode)
+	helpers.AssertEqual(t, 4, batch.Total)
+	helpers.AssertEqual(t, 2, len(batch.Failures))
+	helpers.AssertEqual(t, "2 file(s) failed to decrypt", err.Error())
+
+	failed := map[string]error{}
+	for _, failure := range batch.Failures {
+		failed[filepath.Base(failure.Path)] = failure.Err
+	}
+	if _, ok := failed["garbled.txt.hex"]; !ok {
+		t.Errorf("Expected garbled.txt.hex among the failures, got %v", failed)
+	}
+	if !errors.Is(failed["other.txt.hex"], constants.ErrAuthFailure) {
+		t.Errorf("Expected ErrAuthFailure for other.txt.hex, got %v", failed["other.txt.hex"])
+	}
+	// The per-file errors are reachable through the summary
+	if !errors.Is(err, constants.ErrAuthFailure) {
+		t.Errorf("Expected the summary to wrap ErrAuthFailure")
+	}
+
+	for _, name := range []string{"a.txt", "b.txt"} {
+		helpers.AssertBytesEqual(t, []byte("contents of "+name), helpers.ReadFileContent(t, filepath.Join(tmpDir, name)))
+	}
+
+	output := string(stdout)
+	for _, want := range []string{"2 of 4 file(s) processed successfully", "Failed files:", "FILE", "ERROR", garbled, filepath.Join(tmpDir, "other.txt.hex")} {
+		if !strings.Contains(output, want) {
+			t.Errorf("Expected %q in the output:\n%s", want, output)
+		}
+	}
+}
+
+func TestDecryptDirectory_NoFailuresReturnsNil(t *testing.T) {
+	tmpDir := helpers.CreateTempDir(t)
+	defer helpers.CleanupTempDir(t, tmpDir)
+
+	inputPath := filepath.Join(tmpDir, "only.txt")
+	helpers.WriteFileContent(t, inputPath, []byte("only file"))
+	helpers.AssertNoError(t, cli.NewCLIProcessor().Encrypt(cli.Options{
+		InputFile: inputPath, OutputFile: inputPath + constants.FileExtension, Password: testPassword, DeleteSource: true,
+	}))
+
+	var err error
+	stdout, _ := captureOutput(t, func() {
+		err = cli.NewCLIProcessor().DecryptDirectory(cli.Options{RecursiveDir: tmpDir, Password: testPassword})
+	})
+	helpers.AssertNoError(t, err)
+	if strings.Contains(string(stdout), "Failed files:") {
+		t.Errorf("Unexpected failure table:\n%s", stdout)
+	}
+}
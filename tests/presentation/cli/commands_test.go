@@ -0,0 +1,17 @@
+package cli
+
+import (
+	"testing"
+
+	"github.com/hambosto/hexwarden/internal/presentation/cli"
+)
+
+func TestNewCLI_RegistersFlags(t *testing.T) {
+	// cobra panics when a command registers the same flag twice
+	defer func() {
+		if r := recover(); r != nil {
+			t.Fatalf("Building the command tree panicked: %v", r)
+		}
+	}()
+	cli.NewCLI()
+}
@@ -0,0 +1,147 @@
+package cli
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/hambosto/hexwarden/internal/constants"
+	"github.com/hambosto/hexwarden/internal/presentation/cli"
+	"github.com/hambosto/hexwarden/tests/helpers"
+)
+
+// configOptions are the values a config test reads back from its commands
+type configOptions struct {
+	ioTimeout  time.Duration
+	headerHash string
+	minLength  int
+	durable    bool
+	attempts   int
+}
+
+// newConfigCommands builds a root command with encrypt and decrypt
+// subcommands whose flags mirror the real ones
+func newConfigCommands(opts *configOptions) (*cobra.Command, *cobra.Command) {
+	root := &cobra.Command{Use: "hexwarden"}
+	root.PersistentFlags().DurationVar(&opts.ioTimeout, "io-timeout", 0, "")
+	root.PersistentFlags().String("config", "", "")
+
+	encrypt := &cobra.Command{Use: "encrypt", Run: func(*cobra.Command, []string) {}}
+	encrypt.Flags().StringP("password", "p", "", "")
+	encrypt.Flags().StringVar(&opts.headerHash, "header-hash", "sha256", "")
+	encrypt.Flags().IntVar(&opts.minLength, "min-password-length", 0, "")
+	encrypt.Flags().BoolVar(&opts.durable, "durable", false, "")
+	encrypt.Flags().Bool("i-understand-the-risk", false, "")
+
+	decrypt := &cobra.Command{Use: "decrypt", Run: func(*cobra.Command, []string) {}}
+	decrypt.Flags().IntVar(&opts.attempts, "password-attempts", 3, "")
+	decrypt.Flags().BoolVar(&opts.durable, "durable", false, "")
+
+	root.AddCommand(encrypt, decrypt)
+	return root, encrypt
+}
+
+// applyConfigTo parses args for the encrypt command and applies the config at path
+func applyConfigTo(t *testing.T, path string, args ...string) (configOptions, error) {
+	t.Helper()
+
+	var opts configOptions
+	_, encrypt := newConfigCommands(&opts)
+	helpers.AssertNoError(t, encrypt.ParseFlags(args))
+
+	config, err := cli.LoadConfig(path)
+	if err != nil {
+		return opts, err
+	}
+	return opts, config.Apply(encrypt)
+}
+
+func TestConfig_EffectiveOptions(t *testing.T) {
+	tmpDir := helpers.CreateTempDir(t)
+	defer helpers.CleanupTempDir(t, tmpDir)
+
+	files := map[string]string{
+		"config.yaml": `# defaults for every run
+io-timeout: 30s
+durable: true
+
+encrypt:
+  header-hash: "blake2b"
+  min-password-length: 12 # team policy
+decrypt:
+  password-attempts: 5
+`,
+		"config.toml": `io-timeout = "30s"
+durable = true
+
+[encrypt]
+header-hash = 'blake2b'
+min-password-length = 12
+
+[decrypt]
+password-attempts = 5
+`,
+	}
+
+	for name, content := range files {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(tmpDir, name)
+			helpers.WriteFileContent(t, path, []byte(content))
+
+			opts, err := applyConfigTo(t, path)
+			helpers.AssertNoError(t, err)
+			helpers.AssertEqual(t, 30*time.Second, opts.ioTimeout)
+			helpers.AssertEqual(t, true, opts.durable)
+			helpers.AssertEqual(t, "blake2b", opts.headerHash)
+			helpers.AssertEqual(t, 12, opts.minLength)
+			// Sections for other commands leave encrypt alone
+			helpers.AssertEqual(t, 3, opts.attempts)
+
+			opts, err = applyConfigTo(t, path, "--header-hash", "sha256", "--min-password-length=8", "--durable=false")
+			helpers.AssertNoError(t, err)
+			helpers.AssertEqual(t, "sha256", opts.headerHash)
+			helpers.AssertEqual(t, 8, opts.minLength)
+			helpers.AssertEqual(t, false, opts.durable)
+			helpers.AssertEqual(t, 30*time.Second, opts.ioTimeout)
+		})
+	}
+}
+
+func TestConfig_Rejected(t *testing.T) {
+	tmpDir := helpers.CreateTempDir(t)
+	defer helpers.CleanupTempDir(t, tmpDir)
+
+	tests := []struct {
+		name    string
+		file    string
+		content string
+	}{
+		{name: "Password", file: "c.yaml", content: "encrypt:\n  password: hunter2\n"},
+		{name: "Top-level password", file: "c.toml", content: "password = \"hunter2\"\n"},
+		{name: "Risk acknowledgement", file: "c.yaml", content: "encrypt:\n  i-understand-the-risk: true\n"},
+		{name: "Unknown option", file: "c.yaml", content: "header-hsah: blake2b\n"},
+		{name: "Unknown section", file: "c.toml", content: "[encrpyt]\ndurable = true\n"},
+		{name: "Option of another command", file: "c.yaml", content: "encrypt:\n  password-attempts: 5\n"},
+		{name: "Invalid value", file: "c.yaml", content: "encrypt:\n  min-password-length: twelve\n"},
+		{name: "Duplicate option", file: "c.yaml", content: "durable: true\ndurable: false\n"},
+		{name: "Nested section", file: "c.yaml", content: "encrypt:\n  extra:\n    durable: true\n"},
+		{name: "Unsupported format", file: "c.json", content: "{}"},
+		{name: "Missing file", file: "missing.yaml"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(tmpDir, tt.file)
+			if tt.content != "" {
+				helpers.WriteFileContent(t, path, []byte(tt.content))
+			}
+
+			if _, err := applyConfigTo(t, path); !errors.Is(err, constants.ErrInvalidConfig) {
+				t.Errorf("Expected ErrInvalidConfig, got %v", err)
+			}
+		})
+	}
+}
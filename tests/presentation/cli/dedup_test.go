@@ -0,0 +1,64 @@
+package cli
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/hambosto/hexwarden/internal/constants"
+	"github.com/hambosto/hexwarden/internal/presentation/cli"
+	"github.com/hambosto/hexwarden/internal/usecase/operations"
+	"github.com/hambosto/hexwarden/tests/helpers"
+)
+
+func TestDedup_DuplicatesBecomeReferences(t *testing.T) {
+	tmpDir := helpers.CreateTempDir(t)
+	defer helpers.CleanupTempDir(t, tmpDir)
+
+	shared := strings.Repeat("the same backup payload\n", 4096)
+	tree := map[string]string{
+		"a.txt":               shared,
+		"copies/b.txt":        shared,
+		"copies/nested/c.txt": shared,
+		"other.txt":           "different content",
+	}
+	writeTree(t, tmpDir, tree)
+	opts := cli.Options{RecursiveDir: tmpDir, Password: testPassword, Dedup: true, DeleteSource: true}
+
+	var err error
+	captureOutput(t, func() { err = cli.NewCLIProcessor().EncryptDirectory(opts) })
+	helpers.AssertNoError(t, err)
+
+	// Only one of the three copies is stored in full
+	decryptor := operations.NewDecryptor()
+	var full, references int
+	for rel, content := range tree {
+		path := filepath.Join(tmpDir, filepath.FromSlash(rel)) + constants.FileExtension
+		isReference, err := decryptor.IsReference(path)
+		helpers.AssertNoError(t, err)
+		if isReference {
+			references++
+			info, err := os.Stat(path)
+			helpers.AssertNoError(t, err)
+			if info.Size() >= int64(len(content)) {
+				t.Errorf("Expected %s to be a small reference, got %d bytes", rel, info.Size())
+			}
+		} else if content == shared {
+			full++
+		}
+	}
+	helpers.AssertEqual(t, 1, full)
+	helpers.AssertEqual(t, 2, references)
+
+	// References are restored before their target is deleted
+	captureOutput(t, func() { err = cli.NewCLIProcessor().DecryptDirectory(opts) })
+	helpers.AssertNoError(t, err)
+	for rel, content := range tree {
+		got := helpers.ReadFileContent(t, filepath.Join(tmpDir, filepath.FromSlash(rel)))
+		if !bytes.Equal([]byte(content), got) {
+			t.Errorf("Expected %s to be restored", rel)
+		}
+	}
+}
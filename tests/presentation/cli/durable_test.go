@@ -0,0 +1,91 @@
+package cli
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/hambosto/hexwarden/internal/constants"
+	"github.com/hambosto/hexwarden/internal/presentation/cli"
+	"github.com/hambosto/hexwarden/tests/helpers"
+)
+
+const testPassword = "cli test password"
+
+// recordingSyncer records sync calls instead of flushing anything
+type recordingSyncer struct {
+	calls []string
+	err   error
+}
+
+func (s *recordingSyncer) SyncFile(path string) error {
+	s.calls = append(s.calls, "file:"+path)
+	return s.err
+}
+
+func (s *recordingSyncer) SyncDir(dir string) error {
+	s.calls = append(s.calls, "dir:"+dir)
+	return s.err
+}
+
+func TestDurable_SyncsOutputAndDirectory(t *testing.T) {
+	tmpDir := helpers.CreateTempDir(t)
+	defer helpers.CleanupTempDir(t, tmpDir)
+
+	inputPath := filepath.Join(tmpDir, "input.txt")
+	encryptedPath := inputPath + constants.FileExtension
+	decryptedPath := filepath.Join(tmpDir, "output.txt")
+	helpers.WriteFileContent(t, inputPath, []byte("durable output test data"))
+
+	tests := []struct {
+		name     string
+		durable  bool
+		expected []string
+	}{
+		{name: "Disabled", durable: false, expected: nil},
+		{name: "Enabled", durable: true, expected: []string{
+			"file:" + encryptedPath, "dir:" + tmpDir,
+			"file:" + decryptedPath, "dir:" + tmpDir,
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			syncer := &recordingSyncer{}
+			processor := cli.NewCLIProcessorWithSyncer(syncer)
+
+			helpers.AssertNoError(t, processor.Encrypt(cli.Options{
+				InputFile: inputPath, OutputFile: encryptedPath, Password: testPassword, Durable: tt.durable,
+			}))
+			helpers.AssertNoError(t, processor.Decrypt(cli.Options{
+				InputFile: encryptedPath, OutputFile: decryptedPath, Password: testPassword, Durable: tt.durable,
+			}))
+
+			helpers.AssertEqual(t, len(tt.expected), len(syncer.calls))
+			for i := range tt.expected {
+				helpers.AssertEqual(t, tt.expected[i], syncer.calls[i])
+			}
+		})
+	}
+}
+
+func TestDurable_SyncFailureKeepsSource(t *testing.T) {
+	tmpDir := helpers.CreateTempDir(t)
+	defer helpers.CleanupTempDir(t, tmpDir)
+
+	inputPath := filepath.Join(tmpDir, "input.txt")
+	helpers.WriteFileContent(t, inputPath, []byte("must survive a failed sync"))
+
+	syncErr := errors.New("disk gone")
+	err := cli.NewCLIProcessorWithSyncer(&recordingSyncer{err: syncErr}).Encrypt(cli.Options{
+		InputFile:    inputPath,
+		OutputFile:   inputPath + constants.FileExtension,
+		Password:     testPassword,
+		Durable:      true,
+		DeleteSource: true,
+	})
+	if !errors.Is(err, syncErr) {
+		t.Fatalf("Expected %v, got %v", syncErr, err)
+	}
+	helpers.AssertFileExists(t, inputPath)
+}
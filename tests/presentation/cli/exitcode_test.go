@@ -0,0 +1,74 @@
+package cli
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/AlecAivazis/survey/v2"
+
+	"github.com/hambosto/hexwarden/internal/constants"
+	"github.com/hambosto/hexwarden/internal/presentation/cli"
+	"github.com/hambosto/hexwarden/internal/presentation/interactive"
+	"github.com/hambosto/hexwarden/internal/presentation/ui"
+	"github.com/hambosto/hexwarden/tests/helpers"
+)
+
+func TestExitCode(t *testing.T) {
+	tmpDir := helpers.CreateTempDir(t)
+	defer helpers.CleanupTempDir(t, tmpDir)
+
+	inputPath := filepath.Join(tmpDir, "input.txt")
+	encryptedPath := inputPath + constants.FileExtension
+	helpers.WriteFileContent(t, inputPath, []byte("exit code source"))
+	helpers.AssertNoError(t, cli.NewCLIProcessor().Encrypt(cli.Options{InputFile: inputPath, OutputFile: encryptedPath, Password: testPassword}))
+
+	encrypted, err := os.ReadFile(encryptedPath)
+	helpers.AssertNoError(t, err)
+	truncatedPath := filepath.Join(tmpDir, "truncated.txt.hex")
+	helpers.WriteFileContent(t, truncatedPath, encrypted[:len(encrypted)/2])
+	plainPath := filepath.Join(tmpDir, "plain.txt.hex")
+	helpers.WriteFileContent(t, plainPath, []byte("not an encrypted file"))
+
+	decrypt := func(input, password string) func() error {
+		return func() error {
+			return cli.NewCLIProcessor().Decrypt(cli.Options{InputFile: input, OutputFile: filepath.Join(tmpDir, "out"), Password: password})
+		}
+	}
+
+	tests := []struct {
+		name     string
+		run      func() error
+		expected int
+	}{
+		{name: "Success", run: decrypt(encryptedPath, testPassword), expected: constants.ExitSuccess},
+		{name: "Wrong password", run: decrypt(encryptedPath, "wrong password"), expected: constants.ExitAuthFailed},
+		{name: "Wrong associated data", run: func() error {
+			return cli.NewCLIProcessor().Decrypt(cli.Options{InputFile: encryptedPath, OutputFile: filepath.Join(tmpDir, "aad"), Password: testPassword, AAD: "other"})
+		}, expected: constants.ExitAuthFailed},
+		{name: "Not an encrypted file", run: decrypt(plainPath, testPassword), expected: constants.ExitCorrupt},
+		{name: "Truncated file", run: decrypt(truncatedPath, testPassword), expected: constants.ExitCorrupt},
+		{name: "Missing input", run: decrypt(filepath.Join(tmpDir, "missing.hex"), testPassword), expected: constants.ExitIOError},
+		{name: "Canceled at a prompt", run: func() error {
+			cancel := func(_ survey.Prompt, response interface{}, _ ...survey.AskOpt) error {
+				*response.(*string) = string(constants.ExistsCancel)
+				return nil
+			}
+			_, err := interactive.NewInteractiveAppWithPrompt(ui.NewPromptWithAsker(cancel)).ResolveOutputPath(encryptedPath)
+			return err
+		}, expected: constants.ExitCanceled},
+		{name: "Other failure", run: func() error {
+			return cli.NewCLIProcessor().Encrypt(cli.Options{InputFile: inputPath, OutputFile: filepath.Join(tmpDir, "short.hex"), Password: "short", MinPasswordLength: 12})
+		}, expected: constants.ExitFailure},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var err error
+			captureOutput(t, func() { err = tt.run() })
+			if code := cli.ExitCode(err); code != tt.expected {
+				t.Errorf("Expected exit code %d, got %d for %v", tt.expected, code, err)
+			}
+		})
+	}
+}
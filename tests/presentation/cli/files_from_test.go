@@ -0,0 +1,76 @@
+package cli
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/hambosto/hexwarden/internal/constants"
+	"github.com/hambosto/hexwarden/internal/presentation/cli"
+	"github.com/hambosto/hexwarden/tests/helpers"
+)
+
+func TestEncryptFileList_ProcessesEachListedPath(t *testing.T) {
+	tmpDir := helpers.CreateTempDir(t)
+	defer helpers.CleanupTempDir(t, tmpDir)
+
+	plain := filepath.Join(tmpDir, "app.log")
+	spaced := filepath.Join(tmpDir, "with space", "old app.log")
+	hidden := filepath.Join(tmpDir, ".hidden.log")
+	helpers.CreateTestFiles(t, tmpDir, map[string][]byte{
+		"app.log":                []byte("first log"),
+		"with space/old app.log": []byte("second log"),
+		".hidden.log":            []byte("listed explicitly"),
+		"unlisted.log":           []byte("not in the list"),
+		"already.log.hex":        []byte("not a source"),
+	})
+
+	list := strings.Join([]string{
+		plain,
+		"",
+		spaced + "\r",
+		"   ",
+		hidden,
+	}, "\n") + "\n"
+
+	processor := cli.NewCLIProcessor()
+	helpers.AssertNoError(t, processor.EncryptFileList(cli.Options{Password: testPassword}, strings.NewReader(list)))
+
+	for _, path := range []string{plain, spaced, hidden} {
+		helpers.AssertFileExists(t, path+constants.FileExtension)
+	}
+	helpers.AssertFileNotExists(t, filepath.Join(tmpDir, "unlisted.log"+constants.FileExtension))
+
+	// The encrypted files round-trip through a decrypt list
+	for _, path := range []string{plain, spaced, hidden} {
+		helpers.AssertNoError(t, os.Remove(path))
+	}
+	decryptList := strings.Join([]string{plain + constants.FileExtension, spaced + constants.FileExtension, hidden + constants.FileExtension}, "\n")
+	helpers.AssertNoError(t, processor.DecryptFileList(cli.Options{Password: testPassword}, strings.NewReader(decryptList)))
+
+	helpers.AssertBytesEqual(t, []byte("second log"), helpers.ReadFileContent(t, spaced))
+}
+
+func TestEncryptFileList_InvalidPathsFailButOthersProceed(t *testing.T) {
+	tmpDir := helpers.CreateTempDir(t)
+	defer helpers.CleanupTempDir(t, tmpDir)
+
+	valid := filepath.Join(tmpDir, "valid.txt")
+	encrypted := filepath.Join(tmpDir, "done.txt"+constants.FileExtension)
+	helpers.WriteFileContent(t, valid, []byte("valid entry"))
+	helpers.WriteFileContent(t, encrypted, []byte("already encrypted"))
+
+	list := strings.Join([]string{
+		filepath.Join(tmpDir, "missing.txt"),
+		tmpDir,
+		encrypted,
+		valid,
+	}, "\n")
+
+	err := cli.NewCLIProcessor().EncryptFileList(cli.Options{Password: testPassword}, strings.NewReader(list))
+	if err == nil || !strings.Contains(err.Error(), "3 file(s) failed") {
+		t.Fatalf("Expected three failures to be reported, got %v", err)
+	}
+	helpers.AssertFileExists(t, valid+constants.FileExtension)
+}
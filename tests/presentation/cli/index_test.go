@@ -0,0 +1,151 @@
+package cli
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/hambosto/hexwarden/internal/constants"
+	"github.com/hambosto/hexwarden/internal/presentation/cli"
+	"github.com/hambosto/hexwarden/internal/usecase/operations"
+	"github.com/hambosto/hexwarden/tests/helpers"
+)
+
+// writeTree creates files, keyed by slash-separated relative path, below root
+func writeTree(t *testing.T, root string, files map[string]string) {
+	t.Helper()
+	for rel, content := range files {
+		path := filepath.Join(root, filepath.FromSlash(rel))
+		helpers.AssertNoError(t, os.MkdirAll(filepath.Dir(path), 0o750))
+		helpers.WriteFileContent(t, path, []byte(content))
+	}
+}
+
+// listTree returns the slash-separated paths of every file and directory below root
+func listTree(t *testing.T, root string) []string {
+	t.Helper()
+	var paths []string
+	err := filepath.Walk(root, func(path string, _ os.FileInfo, err error) error {
+		if err != nil || path == root {
+			return err
+		}
+		rel, err := filepath.Rel(root, path)
+		paths = append(paths, filepath.ToSlash(rel))
+		return err
+	})
+	helpers.AssertNoError(t, err)
+	return paths
+}
+
+func TestIndex_EncryptAndRestoreTree(t *testing.T) {
+	tmpDir := helpers.CreateTempDir(t)
+	defer helpers.CleanupTempDir(t, tmpDir)
+
+	tree := map[string]string{
+		"a.txt":                  "top level",
+		"reports/q1.txt":         "first quarter",
+		"reports/archive/q4.txt": "last quarter",
+	}
+	writeTree(t, tmpDir, tree)
+	opts := cli.Options{RecursiveDir: tmpDir, Password: testPassword, Index: true, DeleteSource: true}
+
+	var err error
+	captureOutput(t, func() { err = cli.NewCLIProcessor().EncryptDirectory(opts) })
+	helpers.AssertNoError(t, err)
+
+	// Only opaque names and the index remain; no directory or file name survives
+	listing := listTree(t, tmpDir)
+	helpers.AssertEqual(t, len(tree)+1, len(listing))
+	for _, name := range listing {
+		if strings.Contains(name, "/") || strings.Contains(name, "reports") || strings.Contains(name, "q1") {
+			t.Errorf("Expected only opaque names at the top, found %s", name)
+		}
+	}
+	helpers.AssertFileExists(t, filepath.Join(tmpDir, constants.IndexFileName))
+
+	// A file added later joins the existing index on the next run
+	writeTree(t, tmpDir, map[string]string{"reports/q2.txt": "second quarter"})
+	tree["reports/q2.txt"] = "second quarter"
+	captureOutput(t, func() { err = cli.NewCLIProcessor().EncryptDirectory(opts) })
+	helpers.AssertNoError(t, err)
+
+	index, err := operations.NewDecryptor().ReadIndex(filepath.Join(tmpDir, constants.IndexFileName), testPassword)
+	helpers.AssertNoError(t, err)
+	helpers.AssertEqual(t, len(tree), len(index.Entries))
+
+	captureOutput(t, func() { err = cli.NewCLIProcessor().DecryptDirectory(opts) })
+	helpers.AssertNoError(t, err)
+
+	for rel, content := range tree {
+		helpers.AssertBytesEqual(t, []byte(content), helpers.ReadFileContent(t, filepath.Join(tmpDir, filepath.FromSlash(rel))))
+	}
+	// The encrypted files and the emptied index are gone
+	helpers.AssertEqual(t, len(tree)+2, len(listTree(t, tmpDir)))
+	helpers.AssertFileNotExists(t, filepath.Join(tmpDir, constants.IndexFileName))
+}
+
+func TestIndex_PartialRestoreKeepsRemainingEntries(t *testing.T) {
+	tmpDir := helpers.CreateTempDir(t)
+	defer helpers.CleanupTempDir(t, tmpDir)
+
+	writeTree(t, tmpDir, map[string]string{"a.txt": "alpha", "nested/b.txt": "bravo"})
+	opts := cli.Options{RecursiveDir: tmpDir, Password: testPassword, Index: true, DeleteSource: true}
+
+	var err error
+	captureOutput(t, func() { err = cli.NewCLIProcessor().EncryptDirectory(opts) })
+	helpers.AssertNoError(t, err)
+
+	indexPath := filepath.Join(tmpDir, constants.IndexFileName)
+	index, err := operations.NewDecryptor().ReadIndex(indexPath, testPassword)
+	helpers.AssertNoError(t, err)
+	entry, ok := index.Lookup("nested/b.txt")
+	if !ok {
+		t.Fatalf("Expected nested/b.txt in the index, got %v", index.Entries)
+	}
+
+	// Damage one encrypted file so its restore fails
+	damaged := filepath.Join(tmpDir, entry.Name)
+	helpers.WriteFileContent(t, damaged, []byte("not an encrypted file"))
+
+	captureOutput(t, func() { err = cli.NewCLIProcessor().DecryptDirectory(opts) })
+	var batch *cli.BatchError
+	if !errors.As(err, &batch) || len(batch.Failures) != 1 {
+		t.Fatalf("Expected one failed file, got %v", err)
+	}
+	helpers.AssertBytesEqual(t, []byte("alpha"), helpers.ReadFileContent(t, filepath.Join(tmpDir, "a.txt")))
+
+	// Only the failed entry is left to retry
+	index, err = operations.NewDecryptor().ReadIndex(indexPath, testPassword)
+	helpers.AssertNoError(t, err)
+	helpers.AssertEqual(t, 1, len(index.Entries))
+	helpers.AssertEqual(t, entry, index.Entries[0])
+}
+
+func TestIndex_TamperedIndexIsRefused(t *testing.T) {
+	tmpDir := helpers.CreateTempDir(t)
+	defer helpers.CleanupTempDir(t, tmpDir)
+
+	writeTree(t, tmpDir, map[string]string{"a.txt": "alpha"})
+	opts := cli.Options{RecursiveDir: tmpDir, Password: testPassword, Index: true}
+
+	var err error
+	captureOutput(t, func() { err = cli.NewCLIProcessor().EncryptDirectory(opts) })
+	helpers.AssertNoError(t, err)
+
+	indexPath := filepath.Join(tmpDir, constants.IndexFileName)
+	data := helpers.ReadFileContent(t, indexPath)
+	// Damage the whole body, beyond what Reed-Solomon can repair
+	for i := constants.TotalHeaderSize + constants.ChunkHeaderSize; i < len(data); i++ {
+		data[i] ^= 0x5a
+	}
+	helpers.WriteFileContent(t, indexPath, data)
+	helpers.AssertNoError(t, os.Remove(filepath.Join(tmpDir, "a.txt")))
+
+	captureOutput(t, func() { err = cli.NewCLIProcessor().DecryptDirectory(opts) })
+	if err == nil {
+		t.Fatal("Expected a tampered index to be refused")
+	}
+	helpers.AssertFileNotExists(t, filepath.Join(tmpDir, "a.txt"))
+}
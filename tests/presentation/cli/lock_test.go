@@ -0,0 +1,61 @@
+package cli
+
+import (
+	"errors"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/hambosto/hexwarden/internal/constants"
+	"github.com/hambosto/hexwarden/internal/data/files"
+	"github.com/hambosto/hexwarden/internal/presentation/cli"
+	"github.com/hambosto/hexwarden/tests/helpers"
+)
+
+func TestLock_SecondOperationFailsFast(t *testing.T) {
+	if runtime.GOOS != "linux" && runtime.GOOS != "darwin" && runtime.GOOS != "freebsd" && runtime.GOOS != "windows" {
+		t.Skipf("File locking is not implemented on %s", runtime.GOOS)
+	}
+
+	tmpDir := helpers.CreateTempDir(t)
+	defer helpers.CleanupTempDir(t, tmpDir)
+
+	inputPath := filepath.Join(tmpDir, "input.txt")
+	outputPath := inputPath + constants.FileExtension
+	helpers.WriteFileContent(t, inputPath, []byte("locked source"))
+
+	// Another run is busy with the same source
+	held, err := files.NewManager().LockFile(inputPath)
+	helpers.AssertNoError(t, err)
+
+	opts := cli.Options{InputFile: inputPath, OutputFile: outputPath, Password: testPassword, Lock: true}
+	err = cli.NewCLIProcessor().Encrypt(opts)
+	if !errors.Is(err, constants.ErrFileInUse) {
+		t.Fatalf("Expected %v, got %v", constants.ErrFileInUse, err)
+	}
+	helpers.AssertFileNotExists(t, outputPath)
+
+	// Once released the same operation succeeds and leaves no lock behind
+	helpers.AssertNoError(t, held.Unlock())
+	helpers.AssertNoError(t, cli.NewCLIProcessor().Encrypt(opts))
+	helpers.AssertFileExists(t, outputPath)
+
+	relocked, err := files.NewManager().LockFile(inputPath)
+	helpers.AssertNoError(t, err)
+	helpers.AssertNoError(t, relocked.Unlock())
+}
+
+func TestLock_FailedOperationRemovesClaimedOutput(t *testing.T) {
+	tmpDir := helpers.CreateTempDir(t)
+	defer helpers.CleanupTempDir(t, tmpDir)
+
+	inputPath := filepath.Join(tmpDir, "input.txt.hex")
+	outputPath := filepath.Join(tmpDir, "input.txt")
+	helpers.WriteFileContent(t, inputPath, []byte("not an encrypted file"))
+
+	err := cli.NewCLIProcessor().Decrypt(cli.Options{InputFile: inputPath, OutputFile: outputPath, Password: testPassword, Lock: true})
+	if err == nil {
+		t.Fatal("Expected decryption of a non-encrypted file to fail")
+	}
+	helpers.AssertFileNotExists(t, outputPath)
+}
@@ -0,0 +1,66 @@
+package cli
+
+import (
+	"bytes"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/hambosto/hexwarden/internal/constants"
+	"github.com/hambosto/hexwarden/internal/infrastructure/crypto"
+	"github.com/hambosto/hexwarden/internal/presentation/cli"
+	"github.com/hambosto/hexwarden/tests/helpers"
+)
+
+func TestMatch_NullDelimitedOutput(t *testing.T) {
+	dir := t.TempDir()
+	names := []string{"line one\nline two.txt", "plain.txt"}
+
+	var want []string
+	for _, name := range names {
+		input := filepath.Join(dir, name)
+		helpers.WriteFileContent(t, input, []byte("content of "+name))
+		helpers.AssertNoError(t, cli.NewCLIProcessor().Encrypt(cli.Options{
+			InputFile: input, OutputFile: input + constants.FileExtension, Password: testPassword,
+		}))
+		want = append(want, input+constants.FileExtension)
+	}
+
+	tests := []struct {
+		name string
+		null bool
+	}{
+		{name: "NUL-delimited", null: true},
+		{name: "Human-readable", null: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var err error
+			stdout, stderr := captureOutput(t, func() {
+				err = cli.NewCLIProcessor().Match([]string{dir}, testPassword, "", tt.null, crypto.DefaultKDFParams())
+			})
+			helpers.AssertNoError(t, err)
+
+			if !tt.null {
+				if !bytes.Contains(stdout, []byte("2 of 2 file(s) unlock")) {
+					t.Fatalf("Expected a summary on standard output, got %q", stdout)
+				}
+				return
+			}
+
+			// Every path ends in NUL, so splitting leaves an empty last field
+			if !bytes.HasSuffix(stdout, []byte{0}) {
+				t.Fatalf("Expected output to end in a NUL byte, got %q", stdout)
+			}
+			got := strings.Split(strings.TrimSuffix(string(stdout), "\x00"), "\x00")
+			slices.Sort(got)
+			helpers.AssertEqual(t, strings.Join(want, "|"), strings.Join(got, "|"))
+
+			if !bytes.Contains(stderr, []byte("2 of 2 file(s) unlock")) {
+				t.Fatalf("Expected the summary on standard error, got %q", stderr)
+			}
+		})
+	}
+}
@@ -0,0 +1,121 @@
+package cli
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/hambosto/hexwarden/internal/presentation/cli"
+	"github.com/hambosto/hexwarden/tests/helpers"
+)
+
+func TestOutDir_Layout(t *testing.T) {
+	tree := map[string]string{
+		"top.txt":                 "top level",
+		"a/notes.txt":             "notes in a",
+		"b/notes.txt":             "notes in b",
+		"b/deeper/archive.tar":    "archive",
+		"c/archive.tar.gz":        "compressed archive",
+		"c/deeper/archive.tar.gz": "another compressed archive",
+	}
+	shortHash := func(rel string) string {
+		sum := sha256.Sum256([]byte(rel))
+		return hex.EncodeToString(sum[:])[:8]
+	}
+
+	tests := []struct {
+		name    string
+		flatten bool
+		want    map[string]string // Output path under the out-dir, mapped to the source it decrypts to
+	}{
+		{
+			name: "Mirror",
+			want: map[string]string{
+				"top.txt.hex":                 "top.txt",
+				"a/notes.txt.hex":             "a/notes.txt",
+				"b/notes.txt.hex":             "b/notes.txt",
+				"b/deeper/archive.tar.hex":    "b/deeper/archive.tar",
+				"c/archive.tar.gz.hex":        "c/archive.tar.gz",
+				"c/deeper/archive.tar.gz.hex": "c/deeper/archive.tar.gz",
+			},
+		},
+		{
+			name:    "Flatten with collisions",
+			flatten: true,
+			want: map[string]string{
+				"top.txt.hex":        "top.txt",
+				"notes.txt.hex":      "a/notes.txt",
+				"archive.tar.hex":    "b/deeper/archive.tar",
+				"archive.tar.gz.hex": "c/archive.tar.gz",
+				"notes-" + shortHash("b/notes.txt") + ".txt.hex":                  "b/notes.txt",
+				"archive-" + shortHash("c/deeper/archive.tar.gz") + ".tar.gz.hex": "c/deeper/archive.tar.gz",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			src := t.TempDir()
+			outDir := filepath.Join(t.TempDir(), "vault")
+			writeTree(t, src, tree)
+
+			opts := cli.Options{RecursiveDir: src, OutDir: outDir, Flatten: tt.flatten, Password: testPassword}
+			var err error
+			captureOutput(t, func() { err = cli.NewCLIProcessor().EncryptDirectory(opts) })
+			helpers.AssertNoError(t, err)
+
+			var files []string
+			for _, path := range listTree(t, outDir) {
+				if info, err := os.Stat(filepath.Join(outDir, path)); err == nil && !info.IsDir() {
+					files = append(files, path)
+				}
+			}
+			if tt.flatten {
+				for _, path := range files {
+					if strings.Contains(path, "/") {
+						t.Errorf("Expected a flat out-dir, found %s", path)
+					}
+				}
+			}
+			helpers.AssertEqual(t, len(tt.want), len(files))
+
+			// Every output decrypts to its own source, and no source is left encrypted in place
+			for out, source := range tt.want {
+				encrypted := filepath.Join(outDir, filepath.FromSlash(out))
+				if !slices.Contains(files, out) {
+					t.Fatalf("Expected output %s, got %v", out, files)
+				}
+				decrypted := filepath.Join(t.TempDir(), "plain")
+				captureOutput(t, func() {
+					err = cli.NewCLIProcessor().Decrypt(cli.Options{InputFile: encrypted, OutputFile: decrypted, Password: testPassword})
+				})
+				helpers.AssertNoError(t, err)
+				data, err := os.ReadFile(decrypted)
+				helpers.AssertNoError(t, err)
+				helpers.AssertEqual(t, tree[source], string(data))
+				helpers.AssertFileNotExists(t, filepath.Join(src, filepath.FromSlash(source))+".hex")
+			}
+
+			// Running again picks the same names, so every file is skipped as already done
+			captureOutput(t, func() { err = cli.NewCLIProcessor().EncryptDirectory(opts) })
+			helpers.AssertNoError(t, err)
+			helpers.AssertEqual(t, len(files), len(listTree(t, outDir))-countDirs(t, outDir))
+		})
+	}
+}
+
+// countDirs returns how many directories there are below root
+func countDirs(t *testing.T, root string) int {
+	t.Helper()
+	dirs := 0
+	for _, path := range listTree(t, root) {
+		if info, err := os.Stat(filepath.Join(root, path)); err == nil && info.IsDir() {
+			dirs++
+		}
+	}
+	return dirs
+}
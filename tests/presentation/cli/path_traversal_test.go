@@ -0,0 +1,79 @@
+package cli
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/hambosto/hexwarden/internal/constants"
+	"github.com/hambosto/hexwarden/internal/presentation/cli"
+	"github.com/hambosto/hexwarden/internal/usecase/operations"
+	"github.com/hambosto/hexwarden/tests/helpers"
+)
+
+func TestRestoreName_RefusesMaliciousStoredName(t *testing.T) {
+	root := helpers.CreateTempDir(t)
+	defer helpers.CleanupTempDir(t, root)
+
+	// The encrypted files live two levels below root, so an escape would land in it
+	outDir := filepath.Join(root, "a", "b")
+	helpers.AssertNoError(t, os.MkdirAll(outDir, 0o700))
+
+	inputPath := filepath.Join(root, "plain.txt")
+	helpers.WriteFileContent(t, inputPath, []byte("payload"))
+
+	tests := []struct {
+		name   string
+		stored string
+		escape string
+	}{
+		{"Parent escape", "../../evil.txt", filepath.Join(root, "evil.txt")},
+		{"Absolute", filepath.Join(root, "abs.txt"), filepath.Join(root, "abs.txt")},
+		{"Nested escape", "../evil.txt", filepath.Join(root, "a", "evil.txt")},
+		{"Parent only", "..", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			encryptedPath := filepath.Join(outDir, "crafted.hex")
+			defer os.Remove(encryptedPath) //nolint:errcheck
+
+			_, err := operations.NewEncryptor().EncryptFile(inputPath, encryptedPath, testPassword, operations.EncryptOptions{Filename: tt.stored})
+			helpers.AssertNoError(t, err)
+
+			err = cli.NewCLIProcessor().Decrypt(cli.Options{InputFile: encryptedPath, Password: testPassword, RestoreName: true})
+			if !errors.Is(err, constants.ErrUnsafePath) {
+				t.Fatalf("Expected ErrUnsafePath, got %v", err)
+			}
+			if tt.escape != "" {
+				helpers.AssertFileNotExists(t, tt.escape)
+			}
+
+			entries, err := os.ReadDir(outDir)
+			helpers.AssertNoError(t, err)
+			helpers.AssertEqual(t, 1, len(entries))
+		})
+	}
+}
+
+func TestSetName_RefusesPathNames(t *testing.T) {
+	tmpDir := helpers.CreateTempDir(t)
+	defer helpers.CleanupTempDir(t, tmpDir)
+
+	inputPath := filepath.Join(tmpDir, "plain.txt")
+	helpers.WriteFileContent(t, inputPath, []byte("contents"))
+	encryptedPath := inputPath + constants.FileExtension
+	helpers.AssertNoError(t, cli.NewCLIProcessor().Encrypt(cli.Options{
+		InputFile: inputPath, OutputFile: encryptedPath, Password: testPassword,
+	}))
+	before := helpers.ReadFileContent(t, encryptedPath)
+
+	for _, name := range []string{"../../etc/cron.d/x", "/etc/passwd", "sub/x.txt", ".."} {
+		err := cli.NewCLIProcessor().SetName(encryptedPath, name, testPassword)
+		if !errors.Is(err, constants.ErrUnsafePath) {
+			t.Errorf("%q: expected ErrUnsafePath, got %v", name, err)
+		}
+	}
+	helpers.AssertBytesEqual(t, before, helpers.ReadFileContent(t, encryptedPath))
+}
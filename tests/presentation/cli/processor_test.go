@@ -0,0 +1,86 @@
+package cli
+
+import (
+	"errors"
+	"math"
+	"path/filepath"
+	"testing"
+
+	"github.com/hambosto/hexwarden/internal/constants"
+	"github.com/hambosto/hexwarden/internal/presentation/cli"
+	"github.com/hambosto/hexwarden/tests/helpers"
+)
+
+func TestEncrypt_MinPasswordLengthWithPasswordFlag(t *testing.T) {
+	tests := []struct {
+		name        string
+		password    string
+		expectedErr error
+	}{
+		{name: "Too short", password: "short-pw", expectedErr: constants.ErrPasswordTooShort},
+		{name: "Long enough", password: "long-enough-pw"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpDir := helpers.CreateTempDir(t)
+			defer helpers.CleanupTempDir(t, tmpDir)
+
+			inputPath := filepath.Join(tmpDir, "input.txt")
+			outputPath := inputPath + constants.FileExtension
+			helpers.WriteFileContent(t, inputPath, []byte("cli policy test data"))
+
+			err := cli.NewCLIProcessor().Encrypt(cli.Options{
+				InputFile:         inputPath,
+				OutputFile:        outputPath,
+				Password:          tt.password,
+				MinPasswordLength: 12,
+			})
+
+			if tt.expectedErr == nil {
+				helpers.AssertNoError(t, err)
+				helpers.AssertFileExists(t, outputPath)
+				return
+			}
+			if !errors.Is(err, tt.expectedErr) {
+				t.Fatalf("Expected %v, got %v", tt.expectedErr, err)
+			}
+			helpers.AssertFileNotExists(t, outputPath)
+		})
+	}
+}
+
+func TestEncrypt_KDFFlagsAboveLimit(t *testing.T) {
+	tests := []struct {
+		name   string
+		time   uint32
+		memory uint32
+	}{
+		{name: "Time cost", time: constants.MaxArgonTime + 1},
+		{name: "Memory", memory: constants.MaxArgonMemory + 1},
+		{name: "Both at most", time: math.MaxUint32, memory: math.MaxUint32},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpDir := helpers.CreateTempDir(t)
+			defer helpers.CleanupTempDir(t, tmpDir)
+
+			inputPath := filepath.Join(tmpDir, "input.txt")
+			outputPath := inputPath + constants.FileExtension
+			helpers.WriteFileContent(t, inputPath, []byte("cli kdf limit test data"))
+
+			err := cli.NewCLIProcessor().Encrypt(cli.Options{
+				InputFile:  inputPath,
+				OutputFile: outputPath,
+				Password:   testPassword,
+				KDFTime:    tt.time,
+				KDFMemory:  tt.memory,
+			})
+			if !errors.Is(err, constants.ErrInvalidKDFParams) {
+				t.Fatalf("Expected %v, got %v", constants.ErrInvalidKDFParams, err)
+			}
+			helpers.AssertFileNotExists(t, outputPath)
+		})
+	}
+}
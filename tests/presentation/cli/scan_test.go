@@ -0,0 +1,57 @@
+package cli
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/hambosto/hexwarden/internal/constants"
+	"github.com/hambosto/hexwarden/internal/presentation/cli"
+	"github.com/hambosto/hexwarden/internal/usecase/operations"
+	"github.com/hambosto/hexwarden/tests/helpers"
+)
+
+func TestScan_NullDelimitedOutput(t *testing.T) {
+	dir := t.TempDir()
+	names := []string{"line one\nline two.txt", "clean.txt", "damaged.txt"}
+
+	paths := make(map[string]string)
+	for _, name := range names {
+		input := filepath.Join(dir, name)
+		helpers.WriteFileContent(t, input, bytes.Repeat([]byte("content of "+name), 1000))
+		helpers.AssertNoError(t, cli.NewCLIProcessor().Encrypt(cli.Options{
+			InputFile: input, OutputFile: input + constants.FileExtension, Password: testPassword,
+		}))
+		paths[name] = input + constants.FileExtension
+	}
+
+	// The last byte belongs to a parity shard of the last chunk
+	for _, name := range []string{"line one\nline two.txt", "damaged.txt"} {
+		data := helpers.ReadFileContent(t, paths[name])
+		data[len(data)-1] ^= 0xFF
+		helpers.AssertNoError(t, os.WriteFile(paths[name], data, 0o600))
+	}
+
+	var err error
+	stdout, stderr := captureOutput(t, func() {
+		err = cli.NewCLIProcessor().Scan([]string{dir}, operations.ScanOptions{}, true)
+	})
+	helpers.AssertNoError(t, err)
+
+	// Every path ends in NUL, so splitting leaves an empty last field
+	if !bytes.HasSuffix(stdout, []byte{0}) {
+		t.Fatalf("Expected output to end in a NUL byte, got %q", stdout)
+	}
+	got := strings.Split(strings.TrimSuffix(string(stdout), "\x00"), "\x00")
+	slices.Sort(got)
+	want := []string{paths["damaged.txt"], paths["line one\nline two.txt"]}
+	slices.Sort(want)
+	helpers.AssertEqual(t, strings.Join(want, "|"), strings.Join(got, "|"))
+
+	if !bytes.Contains(stderr, []byte("Scanning: "+paths["clean.txt"])) {
+		t.Fatalf("Expected status lines on standard error, got %q", stderr)
+	}
+}
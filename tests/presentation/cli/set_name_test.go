@@ -0,0 +1,111 @@
+package cli
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/hambosto/hexwarden/internal/constants"
+	"github.com/hambosto/hexwarden/internal/data/files"
+	"github.com/hambosto/hexwarden/internal/infrastructure/crypto"
+	"github.com/hambosto/hexwarden/internal/presentation/cli"
+	"github.com/hambosto/hexwarden/tests/helpers"
+)
+
+// encryptedBody returns the bytes following the header of an encrypted file
+func encryptedBody(t *testing.T, path string) []byte {
+	t.Helper()
+
+	data, err := os.ReadFile(path)
+	helpers.AssertNoError(t, err)
+	header, err := crypto.ReadHeader(bytes.NewReader(data))
+	helpers.AssertNoError(t, err)
+	return data[header.Size():]
+}
+
+func TestSetName_RestoreUsesNewName(t *testing.T) {
+	tmpDir := helpers.CreateTempDir(t)
+	defer helpers.CleanupTempDir(t, tmpDir)
+
+	plaintext := []byte("renamed report contents")
+	inputPath := filepath.Join(tmpDir, "a.txt")
+	helpers.WriteFileContent(t, inputPath, plaintext)
+
+	encryptedPath := filepath.Join(tmpDir, "a.hex")
+	helpers.AssertNoError(t, cli.NewCLIProcessor().Encrypt(cli.Options{
+		InputFile: inputPath, OutputFile: encryptedPath, Password: testPassword, StoreName: true,
+	}))
+	helpers.AssertNoError(t, os.Remove(inputPath))
+
+	renamedPath := filepath.Join(tmpDir, "b.hex")
+	helpers.AssertNoError(t, os.Rename(encryptedPath, renamedPath))
+	body := encryptedBody(t, renamedPath)
+
+	helpers.AssertNoError(t, cli.NewCLIProcessor().SetName(renamedPath, "b.txt", testPassword))
+	helpers.AssertBytesEqual(t, body, encryptedBody(t, renamedPath))
+
+	helpers.AssertNoError(t, cli.NewCLIProcessor().Decrypt(cli.Options{
+		InputFile: renamedPath, Password: testPassword, RestoreName: true,
+	}))
+
+	helpers.AssertFileNotExists(t, inputPath)
+	helpers.AssertBytesEqual(t, plaintext, helpers.ReadFileContent(t, filepath.Join(tmpDir, "b.txt")))
+}
+
+func TestSetName_Failures(t *testing.T) {
+	tmpDir := helpers.CreateTempDir(t)
+	defer helpers.CleanupTempDir(t, tmpDir)
+
+	inputPath := filepath.Join(tmpDir, "plain.txt")
+	helpers.WriteFileContent(t, inputPath, []byte("no stored name"))
+	encryptedPath := inputPath + constants.FileExtension
+	helpers.AssertNoError(t, cli.NewCLIProcessor().Encrypt(cli.Options{
+		InputFile: inputPath, OutputFile: encryptedPath, Password: testPassword,
+	}))
+	before := helpers.ReadFileContent(t, encryptedPath)
+
+	err := cli.NewCLIProcessor().Decrypt(cli.Options{InputFile: encryptedPath, Password: testPassword, RestoreName: true})
+	if !errors.Is(err, constants.ErrNoStoredName) {
+		t.Errorf("Expected ErrNoStoredName, got %v", err)
+	}
+
+	err = cli.NewCLIProcessor().SetName(encryptedPath, "other.txt", "wrong password")
+	if !errors.Is(err, constants.ErrAuthFailure) {
+		t.Errorf("Expected ErrAuthFailure, got %v", err)
+	}
+	helpers.AssertBytesEqual(t, before, helpers.ReadFileContent(t, encryptedPath))
+
+	// No temporary file is left behind
+	entries, err := os.ReadDir(tmpDir)
+	helpers.AssertNoError(t, err)
+	helpers.AssertEqual(t, 2, len(entries))
+}
+
+func TestSetName_UsesTempDir(t *testing.T) {
+	tmpDir := helpers.CreateTempDir(t)
+	defer helpers.CleanupTempDir(t, tmpDir)
+	tempDir := helpers.CreateTempDir(t)
+	defer helpers.CleanupTempDir(t, tempDir)
+
+	helpers.AssertNoError(t, files.SetTempDir(tempDir))
+	defer files.SetTempDir("") //nolint:errcheck
+
+	inputPath := filepath.Join(tmpDir, "plain.txt")
+	helpers.WriteFileContent(t, inputPath, []byte("temp dir contents"))
+	encryptedPath := inputPath + constants.FileExtension
+	helpers.AssertNoError(t, cli.NewCLIProcessor().Encrypt(cli.Options{
+		InputFile: inputPath, OutputFile: encryptedPath, Password: testPassword,
+	}))
+	body := encryptedBody(t, encryptedPath)
+
+	helpers.AssertNoError(t, cli.NewCLIProcessor().SetName(encryptedPath, "renamed.txt", testPassword))
+	helpers.AssertBytesEqual(t, body, encryptedBody(t, encryptedPath))
+
+	for dir, want := range map[string]int{tmpDir: 2, tempDir: 0} {
+		entries, err := os.ReadDir(dir)
+		helpers.AssertNoError(t, err)
+		helpers.AssertEqual(t, want, len(entries))
+	}
+}
@@ -0,0 +1,83 @@
+package cli
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/hambosto/hexwarden/internal/constants"
+	"github.com/hambosto/hexwarden/internal/presentation/cli"
+	"github.com/hambosto/hexwarden/internal/presentation/ui"
+	"github.com/hambosto/hexwarden/tests/helpers"
+)
+
+// redirect points *target at a pipe, returning a function that restores it
+// and yields everything written in between
+func redirect(t *testing.T, target **os.File) func() []byte {
+	t.Helper()
+
+	r, w, err := os.Pipe()
+	helpers.AssertNoError(t, err)
+
+	original := *target
+	*target = w
+
+	done := make(chan []byte, 1)
+	go func() {
+		data, _ := io.ReadAll(r)
+		done <- data
+	}()
+
+	return func() []byte {
+		*target = original
+		_ = w.Close()
+		defer r.Close() //nolint:errcheck
+		return <-done
+	}
+}
+
+// captureOutput returns what fn writes to standard output and standard error
+func captureOutput(t *testing.T, fn func()) (stdout, stderr []byte) {
+	t.Helper()
+
+	finishStdout := redirect(t, &os.Stdout)
+	finishStderr := redirect(t, &os.Stderr)
+	fn()
+	return finishStdout(), finishStderr()
+}
+
+func TestDecrypt_ToStdoutCarriesOnlyPlaintext(t *testing.T) {
+	defer ui.SetProgressEnabled(true)
+
+	tmpDir := helpers.CreateTempDir(t)
+	defer helpers.CleanupTempDir(t, tmpDir)
+
+	plaintext := bytes.Repeat([]byte("piped plaintext line\n"), 3*constants.DefaultChunkSize/16)
+	inputPath := filepath.Join(tmpDir, "input.txt")
+	encryptedPath := inputPath + constants.FileExtension
+	helpers.WriteFileContent(t, inputPath, plaintext)
+
+	helpers.AssertNoError(t, cli.NewCLIProcessor().Encrypt(cli.Options{
+		InputFile: inputPath, OutputFile: encryptedPath, Password: testPassword,
+	}))
+
+	var err error
+	stdout, stderr := captureOutput(t, func() {
+		err = cli.NewCLIProcessor().Decrypt(cli.Options{
+			InputFile: encryptedPath, OutputFile: "-", Password: testPassword,
+		})
+	})
+	helpers.AssertNoError(t, err)
+
+	if !bytes.Equal(plaintext, stdout) {
+		t.Fatalf("Standard output holds %d bytes that are not just the %d byte plaintext", len(stdout), len(plaintext))
+	}
+	if !strings.Contains(string(stderr), "Decrypting:") {
+		t.Errorf("Expected status lines on standard error, got %q", stderr)
+	}
+	helpers.AssertFileExists(t, encryptedPath)
+	helpers.AssertFileNotExists(t, filepath.Join(tmpDir, "-"))
+}
@@ -0,0 +1,92 @@
+package cli
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/hambosto/hexwarden/internal/constants"
+	"github.com/hambosto/hexwarden/internal/presentation/cli"
+	"github.com/hambosto/hexwarden/tests/helpers"
+)
+
+func TestStripMetadata_InfoShowsNoneAndStillDecrypts(t *testing.T) {
+	tmpDir := t.TempDir()
+	plaintext := []byte("shared without its name or notes")
+	inputPath := filepath.Join(tmpDir, "payroll.xlsx")
+	helpers.WriteFileContent(t, inputPath, plaintext)
+
+	encryptedPath := filepath.Join(tmpDir, "shared.hex")
+	var err error
+	captureOutput(t, func() {
+		err = cli.NewCLIProcessor().Encrypt(cli.Options{
+			InputFile: inputPath, OutputFile: encryptedPath, Password: testPassword,
+			StoreName: true, Comment: "Q3 numbers for Dana",
+		})
+	})
+	helpers.AssertNoError(t, err)
+	helpers.AssertNoError(t, os.Remove(inputPath))
+	body := encryptedBody(t, encryptedPath)
+
+	info := func() string {
+		stdout, _ := captureOutput(t, func() { err = cli.NewCLIProcessor().Info(encryptedPath, testPassword) })
+		helpers.AssertNoError(t, err)
+		return string(stdout)
+	}
+	before := info()
+	for _, line := range []string{"Filename:       payroll.xlsx", "Comment:        Q3 numbers for Dana"} {
+		if !strings.Contains(before, line) {
+			t.Fatalf("Expected info to show %q before stripping, got:\n%s", line, before)
+		}
+	}
+
+	stdout, _ := captureOutput(t, func() { err = cli.NewCLIProcessor().StripMetadata(encryptedPath, testPassword) })
+	helpers.AssertNoError(t, err)
+	if !strings.Contains(string(stdout), "Stripped filename, comment") {
+		t.Fatalf("Expected the stripped fields to be listed, got %q", stdout)
+	}
+	helpers.AssertBytesEqual(t, body, encryptedBody(t, encryptedPath))
+
+	after := info()
+	for _, field := range []string{"Filename:", "Comment:", "Password hint:", "Thumbnail:", "payroll", "Dana"} {
+		if strings.Contains(after, field) {
+			t.Fatalf("Expected info to show no %q after stripping, got:\n%s", field, after)
+		}
+	}
+
+	// Stripping again finds nothing, and the body still decrypts
+	stdout, _ = captureOutput(t, func() { err = cli.NewCLIProcessor().StripMetadata(encryptedPath, testPassword) })
+	helpers.AssertNoError(t, err)
+	if !strings.Contains(string(stdout), "No metadata to strip") {
+		t.Fatalf("Expected nothing left to strip, got %q", stdout)
+	}
+
+	decryptedPath := filepath.Join(tmpDir, "decrypted")
+	captureOutput(t, func() {
+		err = cli.NewCLIProcessor().Decrypt(cli.Options{InputFile: encryptedPath, OutputFile: decryptedPath, Password: testPassword})
+	})
+	helpers.AssertNoError(t, err)
+	helpers.AssertBytesEqual(t, plaintext, helpers.ReadFileContent(t, decryptedPath))
+}
+
+func TestStripMetadata_WrongPasswordLeavesFile(t *testing.T) {
+	tmpDir := t.TempDir()
+	inputPath := filepath.Join(tmpDir, "notes.txt")
+	helpers.WriteFileContent(t, inputPath, []byte("kept as it was"))
+
+	encryptedPath := filepath.Join(tmpDir, "notes.hex")
+	var err error
+	captureOutput(t, func() {
+		err = cli.NewCLIProcessor().Encrypt(cli.Options{InputFile: inputPath, OutputFile: encryptedPath, Password: testPassword, Comment: "keep me"})
+	})
+	helpers.AssertNoError(t, err)
+	original := helpers.ReadFileContent(t, encryptedPath)
+
+	captureOutput(t, func() { err = cli.NewCLIProcessor().StripMetadata(encryptedPath, "wrong password") })
+	if !errors.Is(err, constants.ErrAuthFailure) {
+		t.Fatalf("Expected %v, got %v", constants.ErrAuthFailure, err)
+	}
+	helpers.AssertBytesEqual(t, original, helpers.ReadFileContent(t, encryptedPath))
+}
@@ -0,0 +1,41 @@
+package cli
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/hambosto/hexwarden/internal/constants"
+	"github.com/hambosto/hexwarden/internal/infrastructure/crypto"
+	"github.com/hambosto/hexwarden/internal/presentation/cli"
+	"github.com/hambosto/hexwarden/tests/helpers"
+)
+
+func TestCLIProcessor_Version(t *testing.T) {
+	appLine := fmt.Sprintf("%s %s", constants.AppName, constants.AppVersion)
+
+	stdout, _ := captureOutput(t, func() {
+		helpers.AssertNoError(t, cli.NewCLIProcessor().Version(false))
+	})
+	helpers.AssertEqual(t, appLine+"\n", string(stdout))
+
+	stdout, _ = captureOutput(t, func() {
+		helpers.AssertNoError(t, cli.NewCLIProcessor().Version(true))
+	})
+	lines := strings.Split(strings.TrimSpace(string(stdout)), "\n")
+	helpers.AssertEqual(t, 2, len(lines))
+	helpers.AssertEqual(t, appLine, lines[0])
+
+	var write uint8
+	var read string
+	_, err := fmt.Sscanf(lines[1], "format_write=%d format_read=%s", &write, &read)
+	helpers.AssertNoError(t, err)
+	helpers.AssertEqual(t, crypto.DefaultFormatVersion, write)
+
+	versions := strings.Split(strings.Trim(read, "[]"), ",")
+	readable := crypto.ReadableFormatVersions()
+	helpers.AssertEqual(t, len(readable), len(versions))
+	for i, version := range readable {
+		helpers.AssertEqual(t, fmt.Sprint(version), versions[i])
+	}
+}
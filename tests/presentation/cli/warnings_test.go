@@ -0,0 +1,52 @@
+package cli
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/hambosto/hexwarden/internal/constants"
+	"github.com/hambosto/hexwarden/internal/presentation/cli"
+	"github.com/hambosto/hexwarden/tests/helpers"
+)
+
+func TestFailOnWarning(t *testing.T) {
+	tests := []struct {
+		name          string
+		password      string
+		failOnWarning bool
+		wantWarnings  int
+		wantErr       error
+	}{
+		{name: "Weak password without flag", password: "short", wantWarnings: 1},
+		{name: "Weak password with flag", password: "short", failOnWarning: true, wantWarnings: 1, wantErr: constants.ErrWarnings},
+		{name: "Strong password with flag", password: "long-enough-pw", failOnWarning: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpDir := helpers.CreateTempDir(t)
+			defer helpers.CleanupTempDir(t, tmpDir)
+
+			inputPath := filepath.Join(tmpDir, "input.txt")
+			outputPath := inputPath + constants.FileExtension
+			helpers.WriteFileContent(t, inputPath, []byte("warning test data"))
+
+			processor := cli.NewCLIProcessor()
+			processor.SetFailOnWarning(tt.failOnWarning)
+			err := processor.Encrypt(cli.Options{InputFile: inputPath, OutputFile: outputPath, Password: tt.password})
+
+			// The warning never stops the work itself
+			helpers.AssertNoError(t, err)
+			helpers.AssertFileExists(t, outputPath)
+			helpers.AssertEqual(t, tt.wantWarnings, len(processor.Warnings()))
+
+			err = processor.CheckWarnings()
+			if tt.wantErr == nil {
+				helpers.AssertNoError(t, err)
+			} else if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Expected %v, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
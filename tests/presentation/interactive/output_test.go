@@ -0,0 +1,74 @@
+package interactive
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/AlecAivazis/survey/v2"
+
+	"github.com/hambosto/hexwarden/internal/constants"
+	"github.com/hambosto/hexwarden/internal/presentation/interactive"
+	"github.com/hambosto/hexwarden/internal/presentation/ui"
+	"github.com/hambosto/hexwarden/tests/helpers"
+)
+
+// existsActionStub answers the "already exists" prompt with action, counting the questions
+func existsActionStub(t *testing.T, action constants.ExistsAction, asked *int) ui.AskFunc {
+	return func(prompt survey.Prompt, response interface{}, _ ...survey.AskOpt) error {
+		if _, ok := prompt.(*survey.Select); !ok {
+			t.Fatalf("Expected a Select prompt, got %T", prompt)
+		}
+		*asked++
+		*response.(*string) = string(action)
+		return nil
+	}
+}
+
+func TestInteractiveApp_ResolveOutputPath(t *testing.T) {
+	tests := []struct {
+		name     string
+		existing []string
+		action   constants.ExistsAction
+		expected string
+		wantErr  error
+		asked    int
+	}{
+		{name: "Free path is not asked about", expected: "file.txt.hex"},
+		{name: "Overwrite", existing: []string{"file.txt.hex"}, action: constants.ExistsOverwrite, expected: "file.txt.hex", asked: 1},
+		{name: "Rename", existing: []string{"file.txt.hex"}, action: constants.ExistsRename, expected: "file.txt (1).hex", asked: 1},
+		{name: "Rename past taken numbers", existing: []string{"file.txt.hex", "file.txt (1).hex", "file.txt (2).hex"}, action: constants.ExistsRename, expected: "file.txt (3).hex", asked: 1},
+		{name: "Skip", existing: []string{"file.txt.hex"}, action: constants.ExistsSkip, wantErr: constants.ErrFileSkipped, asked: 1},
+		{name: "Cancel", existing: []string{"file.txt.hex"}, action: constants.ExistsCancel, wantErr: constants.ErrUserCanceled, asked: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			for _, name := range tt.existing {
+				helpers.AssertNoError(t, os.WriteFile(filepath.Join(dir, name), []byte("existing"), 0o600))
+			}
+
+			asked := 0
+			app := interactive.NewInteractiveAppWithPrompt(ui.NewPromptWithAsker(existsActionStub(t, tt.action, &asked)))
+
+			path, err := app.ResolveOutputPath(filepath.Join(dir, "file.txt.hex"))
+			helpers.AssertEqual(t, tt.asked, asked)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("Expected %v, got %v", tt.wantErr, err)
+				}
+				return
+			}
+
+			helpers.AssertNoError(t, err)
+			helpers.AssertEqual(t, filepath.Join(dir, tt.expected), path)
+			for _, name := range tt.existing {
+				if name != tt.expected && path == filepath.Join(dir, name) {
+					t.Errorf("Renamed output %s collides with an existing file", path)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,117 @@
+package interactive
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/AlecAivazis/survey/v2"
+
+	"github.com/hambosto/hexwarden/internal/constants"
+	"github.com/hambosto/hexwarden/internal/presentation/interactive"
+	"github.com/hambosto/hexwarden/internal/presentation/ui"
+	"github.com/hambosto/hexwarden/tests/helpers"
+)
+
+// planAsker answers the deletion question with remove, picking the first
+// deletion method, and the "Proceed?" question with proceed
+func planAsker(t *testing.T, remove, proceed bool) ui.AskFunc {
+	return func(prompt survey.Prompt, response interface{}, _ ...survey.AskOpt) error {
+		switch p := prompt.(type) {
+		case *survey.Confirm:
+			if p.Message == "Proceed?" {
+				*response.(*bool) = proceed
+			} else {
+				*response.(*bool) = remove
+			}
+		case *survey.Select:
+			*response.(*string) = p.Options[0]
+		default:
+			t.Fatalf("Unexpected prompt %T", prompt)
+		}
+		return nil
+	}
+}
+
+// captureStdout returns what fn writes to standard output
+func captureStdout(t *testing.T, fn func()) string {
+	t.Helper()
+
+	r, w, err := os.Pipe()
+	helpers.AssertNoError(t, err)
+	original := os.Stdout
+	os.Stdout = w
+
+	done := make(chan []byte, 1)
+	go func() {
+		data, _ := io.ReadAll(r)
+		done <- data
+	}()
+
+	fn()
+	os.Stdout = original
+	_ = w.Close()
+	defer r.Close() //nolint:errcheck
+	return string(<-done)
+}
+
+func TestInteractiveApp_ProcessFile_Plan(t *testing.T) {
+	tests := []struct {
+		name       string
+		dryRun     bool
+		remove     bool
+		wantErr    error
+		wantAsked  int
+		wantOutput []string
+	}{
+		{name: "Declined", wantErr: constants.ErrUserCanceled, wantAsked: 1, wantOutput: []string{"Delete source:  no"}},
+		{name: "Declined with deletion", remove: true, wantErr: constants.ErrUserCanceled, wantAsked: 1, wantOutput: []string{"Delete source:  yes"}},
+		{name: "Dry run", dryRun: true, wantOutput: []string{"Dry run: nothing was changed"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			input := filepath.Join(dir, "file.txt")
+			content := []byte("plan before acting")
+			helpers.AssertNoError(t, os.WriteFile(input, content, 0o600))
+
+			app := interactive.NewInteractiveAppWithPrompt(ui.NewPromptWithAsker(planAsker(t, tt.remove, false)))
+			app.SetDryRun(tt.dryRun)
+
+			asked := 0
+			getPassword := func() (string, error) {
+				asked++
+				return "correct horse battery staple", nil
+			}
+
+			var err error
+			output := captureStdout(t, func() {
+				err = app.ProcessFile(input, constants.ModeEncrypt, getPassword)
+			})
+
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("Expected %v, got %v", tt.wantErr, err)
+				}
+			} else {
+				helpers.AssertNoError(t, err)
+			}
+			helpers.AssertEqual(t, tt.wantAsked, asked)
+
+			for _, want := range append([]string{"Plan:", "Encrypt", input, input + ".hex"}, tt.wantOutput...) {
+				if !strings.Contains(output, want) {
+					t.Errorf("Expected plan output to contain %q, got:\n%s", want, output)
+				}
+			}
+
+			helpers.AssertFileNotExists(t, input+".hex")
+			data, err := os.ReadFile(input)
+			helpers.AssertNoError(t, err)
+			helpers.AssertBytesEqual(t, content, data)
+		})
+	}
+}
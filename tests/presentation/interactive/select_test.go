@@ -0,0 +1,85 @@
+package interactive
+
+import (
+	"os"
+	"testing"
+
+	"github.com/AlecAivazis/survey/v2"
+
+	"github.com/hambosto/hexwarden/internal/constants"
+	"github.com/hambosto/hexwarden/internal/presentation/interactive"
+	"github.com/hambosto/hexwarden/internal/presentation/ui"
+	"github.com/hambosto/hexwarden/tests/helpers"
+)
+
+// removingAsker declines multiple selection and picks the first listed file,
+// removing it before answering while remove is positive
+func removingAsker(t *testing.T, remove int, listings *[][]string) ui.AskFunc {
+	return func(prompt survey.Prompt, response interface{}, _ ...survey.AskOpt) error {
+		switch p := prompt.(type) {
+		case *survey.Confirm:
+			*response.(*bool) = false
+		case *survey.Select:
+			*listings = append(*listings, p.Options)
+			choice := p.Options[0]
+			if remove > 0 {
+				remove--
+				helpers.AssertNoError(t, os.Remove(choice))
+			}
+			*response.(*string) = choice
+		default:
+			t.Fatalf("Unexpected prompt %T", prompt)
+		}
+		return nil
+	}
+}
+
+func TestInteractiveApp_SelectFiles_FileRemovedAfterSelection(t *testing.T) {
+	tests := []struct {
+		name     string
+		files    []string
+		remove   int
+		expected string
+		listings int
+	}{
+		{name: "Still present", files: []string{"a.txt", "b.txt"}, expected: "a.txt", listings: 1},
+		{name: "Removed once", files: []string{"a.txt", "b.txt"}, remove: 1, expected: "b.txt", listings: 2},
+		{name: "Removed twice", files: []string{"a.txt", "b.txt", "c.txt"}, remove: 2, expected: "c.txt", listings: 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Chdir(t.TempDir())
+			for _, name := range tt.files {
+				helpers.AssertNoError(t, os.WriteFile(name, []byte("content"), 0o600))
+			}
+
+			var listings [][]string
+			app := interactive.NewInteractiveAppWithPrompt(ui.NewPromptWithAsker(removingAsker(t, tt.remove, &listings)))
+
+			selected, multiple, err := app.SelectFiles(constants.ModeEncrypt)
+			helpers.AssertNoError(t, err)
+			helpers.AssertEqual(t, false, multiple)
+			helpers.AssertEqual(t, 1, len(selected))
+			helpers.AssertEqual(t, tt.expected, selected[0])
+
+			helpers.AssertEqual(t, tt.listings, len(listings))
+			for i, listing := range listings {
+				helpers.AssertEqual(t, len(tt.files)-i, len(listing))
+			}
+		})
+	}
+
+	// When the last eligible file disappears there is nothing left to offer
+	t.Run("Last file removed", func(t *testing.T) {
+		t.Chdir(t.TempDir())
+		helpers.AssertNoError(t, os.WriteFile("only.txt", []byte("content"), 0o600))
+
+		var listings [][]string
+		app := interactive.NewInteractiveAppWithPrompt(ui.NewPromptWithAsker(removingAsker(t, 1, &listings)))
+		if _, _, err := app.SelectFiles(constants.ModeEncrypt); err == nil {
+			t.Fatal("Expected an error once no eligible files remain")
+		}
+		helpers.AssertEqual(t, 1, len(listings))
+	})
+}
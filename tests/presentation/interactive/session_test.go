@@ -0,0 +1,153 @@
+package interactive
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/AlecAivazis/survey/v2"
+	"github.com/AlecAivazis/survey/v2/terminal"
+
+	"github.com/hambosto/hexwarden/internal/constants"
+	"github.com/hambosto/hexwarden/internal/infrastructure/crypto"
+	"github.com/hambosto/hexwarden/internal/presentation/interactive"
+	"github.com/hambosto/hexwarden/internal/presentation/ui"
+	"github.com/hambosto/hexwarden/internal/usecase/operations"
+	"github.com/hambosto/hexwarden/tests/helpers"
+)
+
+const sessionPassword = "correct horse battery staple"
+
+// scriptedAsker answers each prompt with the next answer queued for its message,
+// and fails the test when a prompt has no answers left. An error answer is
+// returned as the prompt's failure
+func scriptedAsker(t *testing.T, answers map[string][]any) ui.AskFunc {
+	return func(prompt survey.Prompt, response interface{}, _ ...survey.AskOpt) error {
+		var message string
+		switch p := prompt.(type) {
+		case *survey.Select:
+			message = p.Message
+		case *survey.Confirm:
+			message = p.Message
+		case *survey.Password:
+			message = p.Message
+		default:
+			t.Fatalf("Unexpected prompt %T", prompt)
+		}
+		if message != "Select Operation:" && message != "Select file:" && message != "Enter password:" && message != "Proceed?" {
+			message = "other"
+		}
+
+		queue := answers[message]
+		if len(queue) == 0 {
+			t.Fatalf("No answer left for %q", message)
+		}
+		answer := queue[0]
+		answers[message] = queue[1:]
+
+		switch a := answer.(type) {
+		case error:
+			return a
+		case string:
+			*response.(*string) = a
+		case bool:
+			*response.(*bool) = a
+		}
+		return nil
+	}
+}
+
+// encryptForSession writes an encrypted file into the working directory with
+// cheap key derivation parameters, which decryption reads from the header
+func encryptForSession(t *testing.T, content []byte) string {
+	t.Helper()
+
+	dir := t.TempDir()
+	t.Chdir(dir)
+	helpers.AssertNoError(t, os.WriteFile(filepath.Join(dir, "notes.txt"), content, 0o600))
+
+	opts := operations.EncryptOptions{KDFParams: crypto.KDFParams{Time: 1, Memory: 8 * 1024, Threads: 1}}
+	_, err := operations.NewEncryptor().EncryptFile("notes.txt", "notes.txt.hex", sessionPassword, opts)
+	helpers.AssertNoError(t, err)
+	helpers.AssertNoError(t, os.Remove("notes.txt"))
+	return "notes.txt.hex"
+}
+
+func TestInteractiveApp_RunSession_Resume(t *testing.T) {
+	content := []byte("recovered within one session")
+	interrupted := terminal.InterruptErr
+
+	tests := []struct {
+		name    string
+		answers map[string][]any
+		wantErr error
+		wantOut bool
+	}{
+		{
+			name: "Wrong password then the right one",
+			answers: map[string][]any{
+				"Select Operation:": {string(constants.ModeDecrypt)},
+				"Select file:":      {"notes.txt.hex"},
+				"Enter password:":   {"not the password", sessionPassword},
+				"other":             {false, false},
+				"Proceed?":          {true, true},
+			},
+			wantOut: true,
+		},
+		{
+			name: "Declined plan goes back to choosing the file",
+			answers: map[string][]any{
+				"Select Operation:": {string(constants.ModeDecrypt)},
+				"Select file:":      {"notes.txt.hex", "notes.txt.hex"},
+				"Enter password:":   {sessionPassword, sessionPassword},
+				"other":             {false, false},
+				"Proceed?":          {false, true},
+			},
+			wantOut: true,
+		},
+		{
+			name: "Interrupted prompt ends the session",
+			answers: map[string][]any{
+				"Select Operation:": {string(constants.ModeDecrypt)},
+				"Select file:":      {"notes.txt.hex"},
+				"Enter password:":   {interrupted},
+			},
+			wantErr: constants.ErrPromptFailed,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			input := encryptForSession(t, content)
+
+			app := interactive.NewInteractiveAppWithPrompt(ui.NewPromptWithAsker(scriptedAsker(t, tt.answers)))
+			app.SetRetryPolicy(operations.RetryPolicy{Attempts: 1})
+
+			var err error
+			captureStdout(t, func() { err = app.RunSession() })
+
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("Expected %v, got %v", tt.wantErr, err)
+				}
+			} else {
+				helpers.AssertNoError(t, err)
+			}
+			for message, left := range tt.answers {
+				if len(left) > 0 {
+					t.Errorf("Expected every answer for %q to be used, %d left", message, len(left))
+				}
+			}
+
+			if tt.wantOut {
+				data, err := os.ReadFile("notes.txt")
+				helpers.AssertNoError(t, err)
+				helpers.AssertBytesEqual(t, content, data)
+			} else {
+				helpers.AssertFileNotExists(t, "notes.txt")
+			}
+			helpers.AssertFileExists(t, input)
+		})
+	}
+}
@@ -0,0 +1,63 @@
+package ui
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/hambosto/hexwarden/internal/constants"
+	"github.com/hambosto/hexwarden/internal/presentation/ui"
+	"github.com/hambosto/hexwarden/tests/helpers"
+)
+
+func TestPasswordReader_PipedPasswordSkipsConfirmation(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		confirm bool
+	}{
+		{name: "Confirmation requested", input: "s3cret\n", confirm: true},
+		{name: "Confirmation disabled", input: "s3cret\n", confirm: false},
+		{name: "Windows line ending", input: "s3cret\r\n", confirm: true},
+		{name: "No trailing newline", input: "s3cret", confirm: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			reader := ui.NewPasswordReaderFrom(strings.NewReader(tt.input), &out)
+			helpers.AssertEqual(t, false, reader.Interactive())
+
+			password, err := reader.EncryptionPassword(tt.confirm)
+			helpers.AssertNoError(t, err)
+			helpers.AssertEqual(t, "s3cret", password)
+
+			if out.Len() != 0 {
+				t.Errorf("Expected no prompts for a piped password, got %q", out.String())
+			}
+		})
+	}
+}
+
+func TestPasswordReader_PipedPasswordReadOnce(t *testing.T) {
+	// A second read would consume the next line if confirmation were attempted
+	reader := ui.NewPasswordReaderFrom(strings.NewReader("first\nsecond\n"), &bytes.Buffer{})
+
+	password, err := reader.EncryptionPassword(true)
+	helpers.AssertNoError(t, err)
+	helpers.AssertEqual(t, "first", password)
+
+	password, err = reader.DecryptionPassword()
+	helpers.AssertNoError(t, err)
+	helpers.AssertEqual(t, "second", password)
+}
+
+func TestPasswordReader_EmptyPipe(t *testing.T) {
+	reader := ui.NewPasswordReaderFrom(strings.NewReader(""), &bytes.Buffer{})
+
+	_, err := reader.DecryptionPassword()
+	if !errors.Is(err, constants.ErrEmptyPassword) {
+		t.Fatalf("Expected %v, got %v", constants.ErrEmptyPassword, err)
+	}
+}
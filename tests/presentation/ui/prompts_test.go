@@ -0,0 +1,112 @@
+package ui
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/AlecAivazis/survey/v2"
+
+	"github.com/hambosto/hexwarden/internal/constants"
+	"github.com/hambosto/hexwarden/internal/presentation/ui"
+	"github.com/hambosto/hexwarden/tests/helpers"
+)
+
+// multiSelectStub answers a MultiSelect prompt by picking the options at indexes
+func multiSelectStub(t *testing.T, indexes ...int) ui.AskFunc {
+	return func(prompt survey.Prompt, response interface{}, _ ...survey.AskOpt) error {
+		multi, ok := prompt.(*survey.MultiSelect)
+		if !ok {
+			t.Fatalf("Expected a MultiSelect prompt, got %T", prompt)
+		}
+
+		selected := []string{}
+		for _, i := range indexes {
+			selected = append(selected, multi.Options[i])
+		}
+		*response.(*[]string) = selected
+		return nil
+	}
+}
+
+func TestPrompt_ChooseFiles(t *testing.T) {
+	files := []string{"a.txt", "b.txt", "c.txt", "d.txt"}
+
+	tests := []struct {
+		name     string
+		indexes  []int
+		expected []string
+		wantErr  error
+	}{
+		{name: "Several entries", indexes: []int{0, 2, 3}, expected: []string{"a.txt", "c.txt", "d.txt"}},
+		{name: "Single entry", indexes: []int{1}, expected: []string{"b.txt"}},
+		{name: "Nothing selected", wantErr: constants.ErrNoFilesSelected},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			selected, err := ui.NewPromptWithAsker(multiSelectStub(t, tt.indexes...)).ChooseFiles(files)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("Expected %v, got %v", tt.wantErr, err)
+				}
+				return
+			}
+
+			helpers.AssertNoError(t, err)
+			helpers.AssertEqual(t, len(tt.expected), len(selected))
+			for i := range tt.expected {
+				helpers.AssertEqual(t, tt.expected[i], selected[i])
+			}
+		})
+	}
+
+	if _, err := ui.NewPromptWithAsker(multiSelectStub(t)).ChooseFiles(nil); !errors.Is(err, constants.ErrNoFilesAvailable) {
+		t.Errorf("Expected %v, got %v", constants.ErrNoFilesAvailable, err)
+	}
+
+	failing := func(survey.Prompt, interface{}, ...survey.AskOpt) error { return errors.New("interrupt") }
+	if _, err := ui.NewPromptWithAsker(failing).ChooseFiles(files); !errors.Is(err, constants.ErrPromptFailed) {
+		t.Errorf("Expected %v, got %v", constants.ErrPromptFailed, err)
+	}
+}
+
+func TestPrompt_ShowPassword(t *testing.T) {
+	tests := []struct {
+		name    string
+		show    bool
+		visible bool
+	}{
+		{name: "Masked by default", show: false, visible: false},
+		{name: "Shown when enabled", show: true, visible: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var asked []survey.Prompt
+			ask := func(prompt survey.Prompt, response interface{}, _ ...survey.AskOpt) error {
+				asked = append(asked, prompt)
+				*response.(*string) = "long passphrase"
+				return nil
+			}
+
+			prompt := ui.NewPromptWithAsker(ask)
+			prompt.SetShowPassword(tt.show)
+
+			password, err := prompt.GetEncryptionPassword()
+			helpers.AssertNoError(t, err)
+			helpers.AssertEqual(t, "long passphrase", password)
+
+			password, err = prompt.GetDecryptionPassword()
+			helpers.AssertNoError(t, err)
+			helpers.AssertEqual(t, "long passphrase", password)
+
+			helpers.AssertEqual(t, 3, len(asked))
+			for _, p := range asked {
+				_, masked := p.(*survey.Password)
+				_, plain := p.(*survey.Input)
+				helpers.AssertEqual(t, !tt.visible, masked)
+				helpers.AssertEqual(t, tt.visible, plain)
+			}
+		})
+	}
+}
@@ -0,0 +1,56 @@
+package ui
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/hambosto/hexwarden/internal/infrastructure/crypto"
+	"github.com/hambosto/hexwarden/internal/presentation/ui"
+	"github.com/hambosto/hexwarden/tests/helpers"
+)
+
+func TestSpinner_WrappedDerivationReturnsSameKey(t *testing.T) {
+	testData := helpers.NewTestData()
+	password := []byte("spinner password")
+	params := crypto.KDFParams{Time: 1, Memory: 8 * 1024, Threads: 1}
+
+	expected, err := crypto.DeriveKeyWithParams(password, testData.ValidSalt, params)
+	helpers.AssertNoError(t, err)
+
+	tests := []struct {
+		name        string
+		enabled     bool
+		expectDrawn bool
+	}{
+		{name: "Enabled", enabled: true, expectDrawn: true},
+		{name: "Disabled", enabled: false, expectDrawn: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			var key []byte
+
+			ui.NewSpinnerWriter(&out, tt.enabled).Run("Deriving key...", func() {
+				key, err = crypto.DeriveKeyWithParams(password, testData.ValidSalt, params)
+			})
+
+			helpers.AssertNoError(t, err)
+			helpers.AssertBytesEqual(t, expected, key)
+			helpers.AssertEqual(t, tt.expectDrawn, strings.Contains(out.String(), "Deriving key..."))
+			if !tt.enabled {
+				helpers.AssertEqual(t, 0, out.Len())
+			}
+		})
+	}
+}
+
+func TestSpinner_ClearsLineWhenDone(t *testing.T) {
+	var out bytes.Buffer
+	ui.NewSpinnerWriter(&out, true).Run("Working", func() {})
+
+	if !strings.HasSuffix(out.String(), "\r") {
+		t.Errorf("Expected output to end by returning to the start of the line, got %q", out.String())
+	}
+}
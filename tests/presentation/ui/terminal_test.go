@@ -0,0 +1,62 @@
+package ui
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/hambosto/hexwarden/internal/presentation/ui"
+	"github.com/hambosto/hexwarden/tests/helpers"
+)
+
+func TestColorSupported(t *testing.T) {
+	tests := []struct {
+		name       string
+		noColor    string
+		term       string
+		isTerminal bool
+		expected   bool
+	}{
+		{name: "Terminal", term: "xterm-256color", isTerminal: true, expected: true},
+		{name: "Not a terminal", term: "xterm-256color", isTerminal: false, expected: false},
+		{name: "NO_COLOR set", noColor: "1", term: "xterm-256color", isTerminal: true, expected: false},
+		{name: "Empty NO_COLOR ignored", noColor: "", term: "xterm-256color", isTerminal: true, expected: true},
+		{name: "Dumb terminal", term: "dumb", isTerminal: true, expected: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("TERM", tt.term)
+			t.Setenv("NO_COLOR", tt.noColor)
+			helpers.AssertEqual(t, tt.expected, ui.ColorSupported(tt.isTerminal))
+		})
+	}
+}
+
+func TestTerminal_NoColorOutputHasNoEscapes(t *testing.T) {
+	t.Setenv("NO_COLOR", "1")
+
+	var buf bytes.Buffer
+	terminal := ui.NewTerminalWriter(&buf)
+
+	terminal.Clear()
+	terminal.MoveTopLeft()
+	terminal.SetTitle("HexWarden")
+	terminal.PrintBanner()
+	terminal.PrintSeparator()
+	terminal.PrintSuccess("done")
+	terminal.PrintError("failed")
+	terminal.PrintWarning("careful")
+	terminal.PrintInfo("note")
+	terminal.Cleanup()
+
+	output := buf.String()
+	if strings.Contains(output, "\x1b") {
+		t.Fatalf("Expected no escape sequences, got %q", output)
+	}
+	for _, expected := range []string{"HexWarden", "[ERROR] failed", "[OK] done"} {
+		if !strings.Contains(output, expected) {
+			t.Errorf("Expected output to contain %q, got %q", expected, output)
+		}
+	}
+}
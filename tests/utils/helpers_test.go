@@ -1,9 +1,12 @@
 package utils
 
 import (
+	"errors"
 	"fmt"
 	"testing"
+	"time"
 
+	"github.com/hambosto/hexwarden/internal/constants"
 	"github.com/hambosto/hexwarden/internal/infrastructure/utils"
 	"github.com/hambosto/hexwarden/tests/helpers"
 )
@@ -344,3 +347,139 @@ func TestMinFunctions_Transitivity(t *testing.T) {
 		}
 	})
 }
+
+func TestParseSince(t *testing.T) {
+	now := time.Date(2024, 6, 15, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name        string
+		value       string
+		expected    time.Time
+		expectError bool
+	}{
+		{
+			name:     "Date only",
+			value:    "2024-01-01",
+			expected: time.Date(2024, 1, 1, 0, 0, 0, 0, time.Local),
+		},
+		{
+			name:     "Date and time",
+			value:    "2024-01-01T08:30:00",
+			expected: time.Date(2024, 1, 1, 8, 30, 0, 0, time.Local),
+		},
+		{
+			name:     "RFC 3339",
+			value:    "2024-01-01T08:30:00Z",
+			expected: time.Date(2024, 1, 1, 8, 30, 0, 0, time.UTC),
+		},
+		{
+			name:     "Hours",
+			value:    "24h",
+			expected: now.Add(-24 * time.Hour),
+		},
+		{
+			name:     "Minutes",
+			value:    "90m",
+			expected: now.Add(-90 * time.Minute),
+		},
+		{
+			name:     "Days",
+			value:    "7d",
+			expected: now.AddDate(0, 0, -7),
+		},
+		{
+			name:        "Empty value",
+			value:       "",
+			expectError: true,
+		},
+		{
+			name:        "Garbage",
+			value:       "yesterday",
+			expectError: true,
+		},
+		{
+			name:        "Negative duration",
+			value:       "-24h",
+			expectError: true,
+		},
+		{
+			name:        "Invalid day count",
+			value:       "xd",
+			expectError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := utils.ParseSince(tt.value, now)
+			if tt.expectError {
+				if !errors.Is(err, constants.ErrInvalidTimeFilter) {
+					t.Fatalf("Expected ErrInvalidTimeFilter, got %v", err)
+				}
+				return
+			}
+
+			helpers.AssertNoError(t, err)
+			if !result.Equal(tt.expected) {
+				t.Errorf("Expected %v, got %v", tt.expected, result)
+			}
+		})
+	}
+}
+
+func TestParseUntil(t *testing.T) {
+	now := time.Date(2024, 6, 15, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		value    string
+		expected time.Time
+	}{
+		{name: "RFC 3339", value: "2025-03-01T09:00:00Z", expected: time.Date(2025, 3, 1, 9, 0, 0, 0, time.UTC)},
+		{name: "Hours", value: "72h", expected: now.Add(72 * time.Hour)},
+		{name: "Days", value: "7d", expected: now.AddDate(0, 0, 7)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := utils.ParseUntil(tt.value, now)
+			helpers.AssertNoError(t, err)
+			if !result.Equal(tt.expected) {
+				t.Errorf("Expected %v, got %v", tt.expected, result)
+			}
+		})
+	}
+
+	if _, err := utils.ParseUntil("-24h", now); !errors.Is(err, constants.ErrInvalidTimeFilter) {
+		t.Errorf("Expected ErrInvalidTimeFilter, got %v", err)
+	}
+}
+
+func TestFormatTimestamp(t *testing.T) {
+	instant := time.Date(2031, 3, 1, 9, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		local    *time.Location
+		expected string
+	}{
+		{name: "UTC", local: time.UTC, expected: "2031-03-01T09:00:00Z"},
+		{name: "East of UTC", local: time.FixedZone("UTC+9", 9*60*60), expected: "2031-03-01T18:00:00+09:00"},
+		{name: "West of UTC", local: time.FixedZone("UTC-5", -5*60*60), expected: "2031-03-01T04:00:00-05:00"},
+	}
+
+	original := time.Local
+	defer func() { time.Local = original }()
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			time.Local = tt.local
+			formatted := utils.FormatTimestamp(instant)
+			helpers.AssertEqual(t, tt.expected, formatted)
+
+			parsed, err := time.Parse(time.RFC3339, formatted)
+			helpers.AssertNoError(t, err)
+			helpers.AssertEqual(t, instant.Unix(), parsed.Unix())
+		})
+	}
+}
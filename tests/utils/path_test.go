@@ -0,0 +1,48 @@
+package utils
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/hambosto/hexwarden/internal/constants"
+	"github.com/hambosto/hexwarden/internal/infrastructure/utils"
+	"github.com/hambosto/hexwarden/tests/helpers"
+)
+
+func TestSafeJoin(t *testing.T) {
+	root := filepath.Join("out", "dir")
+
+	tests := []struct {
+		name     string
+		stored   string
+		expected string
+		unsafe   bool
+	}{
+		{name: "Plain name", stored: "report.pdf", expected: filepath.Join(root, "report.pdf")},
+		{name: "Leading dots", stored: "..hidden", expected: filepath.Join(root, "..hidden")},
+		{name: "Empty", stored: "", unsafe: true},
+		{name: "Dot", stored: ".", unsafe: true},
+		{name: "Parent", stored: "..", unsafe: true},
+		{name: "Parent escape", stored: "../../etc/cron.d/x", unsafe: true},
+		{name: "Absolute", stored: "/etc/passwd", unsafe: true},
+		{name: "Subdirectory", stored: "sub/file.txt", unsafe: true},
+		{name: "Windows escape", stored: `..\..\windows\x.dll`, unsafe: true},
+		{name: "Windows drive", stored: `C:\x.dll`, unsafe: true},
+		{name: "NUL byte", stored: "a\x00b", unsafe: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path, err := utils.SafeJoin(root, tt.stored)
+			if tt.unsafe {
+				if !errors.Is(err, constants.ErrUnsafePath) {
+					t.Fatalf("Expected ErrUnsafePath, got %v (path %q)", err, path)
+				}
+				return
+			}
+			helpers.AssertNoError(t, err)
+			helpers.AssertEqual(t, tt.expected, path)
+		})
+	}
+}